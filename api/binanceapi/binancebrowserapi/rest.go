@@ -29,8 +29,13 @@ var headersVer int64
 var cookiesVer int64
 var account string
 var password string
+var httpClient *network.HttpClient
 
 func Init(redisAddr, redisPass string, redisDb int, acc, pwd string) {
+	// 网页接口需要带cookie，使用独立的http客户端
+	httpClient = network.NewHttpClient("binance-browser", network.DefaultHttpClientConfig())
+	network.SetHttpClient(httpClient, "www.binance.com")
+
 	rc = new(util.RedisClient)
 	rc.Init(redisAddr, redisPass, redisDb, false)
 	account = acc
@@ -80,7 +85,7 @@ func refreshCookiesAndHeaders() bool {
 								cookies = append(cookies, pc.ToCookie())
 							}
 							cookiesVer = ver
-							network.SetCookies(cookies)
+							httpClient.SetCookies(cookies)
 						} else {
 							fmt.Println(err.Error())
 							return false
@@ -532,3 +532,31 @@ func GetPositionRisk(symbolOrPair string, ac APIClass) (*[]binanceapi.PositionRi
 
 	return rst, err
 }
+
+// 倒计时撤销某个合约的所有挂单。countdown内未再次调用则触发撤单，0表示取消倒计时
+// 交易所建议每隔一段时间（小于countdown）调用一次以续期
+func CountdownCancelAll(symbol string, countdown time.Duration, ac APIClass) (*binanceapi.CountdownCancelAllResp, error) {
+	action := "/fapi/v1/countdownCancelAll"
+	method := "POST"
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("countdownTime", strconv.FormatInt(countdown.Milliseconds(), 10))
+
+	header, paramstr, err := binanceapi.SignerIns.Sign(params)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s%s?%s", rootUrl, action, paramstr)
+
+	rst, err := network.ParseHttpResult[binanceapi.CountdownCancelAllResp](
+		restLogPrefix,
+		"CountdownCancelAll",
+		realUrl(url, ac),
+		method,
+		"",
+		header, func(resp *http.Response, body []byte) {
+			binanceapi.ProcessResponse(resp, body, apiType(ac))
+		}, binanceapi.ErrorCallback)
+
+	return rst, err
+}
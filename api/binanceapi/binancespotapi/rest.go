@@ -10,6 +10,7 @@
 package binancespotapi
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -404,6 +405,11 @@ func GetAccountInfo() (*binanceapi.AccountInfo, error) {
 // STOP_LOSS 止损单/STOP_LOSS_LIMIT 限价止损单/TAKE_PROFIT 止盈单/TAKE_PROFIT_LIMIT 限价止盈单
 // LIMIT_MAKER 限价只挂单
 func MakeOrder(symbol, side, orderType, clientOrderID string, price, quantity decimal.Decimal) (*binanceapi.MakeOrderResponse_Ack, error) {
+	return MakeOrderCtx(context.Background(), symbol, side, orderType, clientOrderID, price, quantity)
+}
+
+// 同MakeOrder，ctx取消或超时时请求立即返回
+func MakeOrderCtx(ctx context.Context, symbol, side, orderType, clientOrderID string, price, quantity decimal.Decimal) (*binanceapi.MakeOrderResponse_Ack, error) {
 	action := "/api/v3/order"
 	method := "POST"
 
@@ -420,7 +426,8 @@ func MakeOrder(symbol, side, orderType, clientOrderID string, price, quantity de
 	header, paramstr, err := binanceapi.SignerIns.Sign(params)
 	ep := fmt.Sprintf("%s%s?%s", rootUrl, action, paramstr)
 
-	rest, err := network.ParseHttpResult[binanceapi.MakeOrderResponse_Ack](
+	rest, err := network.ParseHttpResultCtx[binanceapi.MakeOrderResponse_Ack](
+		ctx,
 		restLogPrefix,
 		"MakeOrder",
 		ep,
@@ -437,6 +444,11 @@ func MakeOrder(symbol, side, orderType, clientOrderID string, price, quantity de
 // 撤单
 // 有orderId则优先使用orderId
 func CancelOrder(symbol string, orderId int64, clientOrderId string) (*binanceapi.CancelOrderResponse, error) {
+	return CancelOrderCtx(context.Background(), symbol, orderId, clientOrderId)
+}
+
+// 同CancelOrder，ctx取消或超时时请求立即返回
+func CancelOrderCtx(ctx context.Context, symbol string, orderId int64, clientOrderId string) (*binanceapi.CancelOrderResponse, error) {
 	action := "/api/v3/order"
 	method := "DELETE"
 
@@ -453,7 +465,8 @@ func CancelOrder(symbol string, orderId int64, clientOrderId string) (*binanceap
 	header, paramstr, err := binanceapi.SignerIns.Sign(params)
 	ep := fmt.Sprintf("%s%s?%s", rootUrl, action, paramstr)
 
-	rest, err := network.ParseHttpResult[binanceapi.CancelOrderResponse](
+	rest, err := network.ParseHttpResultCtx[binanceapi.CancelOrderResponse](
+		ctx,
 		restLogPrefix,
 		"CancelOrder",
 		ep,
@@ -499,6 +512,11 @@ func CancelOpenOrders(symbol string) (*binanceapi.CancelOpenOrdersResponse, *bin
 
 // 查询订单
 func GetOrder(symbol string, orderId int64, clientOrderId string) (*binanceapi.GetOrderResponse, error) {
+	return GetOrderCtx(context.Background(), symbol, orderId, clientOrderId)
+}
+
+// 同GetOrder，ctx取消或超时时请求立即返回
+func GetOrderCtx(ctx context.Context, symbol string, orderId int64, clientOrderId string) (*binanceapi.GetOrderResponse, error) {
 	action := "/api/v3/order"
 	method := "GET"
 
@@ -516,7 +534,8 @@ func GetOrder(symbol string, orderId int64, clientOrderId string) (*binanceapi.G
 	header, paramstr, err := binanceapi.SignerIns.Sign(params)
 	ep := fmt.Sprintf("%s%s?%s", rootUrl, action, paramstr)
 
-	resp, err := network.ParseHttpResult[binanceapi.GetOrderResponse](
+	resp, err := network.ParseHttpResultCtx[binanceapi.GetOrderResponse](
+		ctx,
 		restLogPrefix,
 		"GetOrder",
 		ep,
@@ -81,6 +81,33 @@ func (ws *WsClient) UnsubscribeDepth(pair string) {
 	}
 }
 
+func (ws *WsClient) SubscribeAggTrade(pair string, fn api.OnRecvWSMsg) *api.WsSubscriber {
+	pair = strings.ToLower(pair)
+	streamName := fmt.Sprintf("%s@aggTrade", pair)
+	s, stream := binanceapi.SubscribeWithStream[binanceapi.WSPayload_AggTrade](binanceapi.SpotBaseUrl, streamName, wsLogPrefix, fn)
+	ws.publicStreams[streamName] = stream
+	return s
+}
+
+func (ws *WsClient) UnsubscribeAggTrade(pair string) {
+	pair = strings.ToLower(pair)
+	streamName := fmt.Sprintf("%s@aggTrade", pair)
+	if stream, ok := ws.publicStreams[streamName]; ok {
+		stream.Stop()
+		delete(ws.publicStreams, streamName)
+	}
+}
+
+func (ws *WsClient) AggTradeConnected(pair string) bool {
+	pair = strings.ToLower(pair)
+	streamName := fmt.Sprintf("%s@aggTrade", pair)
+	if stream, ok := ws.publicStreams[streamName]; ok {
+		return stream.Connected()
+	} else {
+		return false
+	}
+}
+
 // 订阅用户信息需要先获取ListenKey，并且每间隔一段时间就保活这个ListenKey
 // 暂时每处理保活失败的情况，仅输出日志
 func (ws *WsClient) SubscribeUserData(fnAccountUpdate, fnOrderUpdate api.OnRecvWSMsg) *api.WsSubscriber {
@@ -0,0 +1,44 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 23:48:40
+ * @Description: 币安的rest请求使用独立的http客户端，现货、合约、统一账户的域名共用
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package binanceapi
+
+import (
+	"github.com/aztecqt/dagger/util/network"
+)
+
+var httpHosts = []string{"api.binance.com", "fapi.binance.com", "dapi.binance.com", "papi.binance.com"}
+var httpClientConfig = network.DefaultHttpClientConfig()
+var httpClient *network.HttpClient
+var proxySelector network.ProxySelector
+
+// 修改http客户端配置，应在Init之前调用
+func SetHttpClientConfig(cfg network.HttpClientConfig) {
+	httpClientConfig = cfg
+}
+
+// 设置rest和ws使用的代理选择器（如代理池），应在Init之前调用。已经建立的ws在下次重连时生效
+func SetProxySelector(s network.ProxySelector) {
+	proxySelector = s
+	if httpClient != nil {
+		httpClient.SetProxySelector(s)
+	}
+}
+
+func HttpClient() *network.HttpClient {
+	return httpClient
+}
+
+func initHttpClient() {
+	if httpClient != nil {
+		httpClient.Close()
+	}
+
+	httpClient = network.NewHttpClient("binance", httpClientConfig)
+	httpClient.SetProxySelector(proxySelector)
+	network.SetHttpClient(httpClient, httpHosts...)
+}
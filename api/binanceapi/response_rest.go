@@ -415,3 +415,10 @@ type SpotTradeFee struct {
 
 // 获取交易手续费
 type GetSpotTradeFeeResp []SpotTradeFee
+
+// 合约倒计时撤单
+type CountdownCancelAllResp struct {
+	ErrorMessage
+	Symbol        string `json:"symbol"`
+	CountdownTime string `json:"countdownTime"`
+}
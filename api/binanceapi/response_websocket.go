@@ -41,6 +41,17 @@ type WSPayload_Ticker struct {
 	Sell1Size   decimal.Decimal `json:"A"`
 }
 
+// 归集成交
+type WSPayload_AggTrade struct {
+	WSPayload_Common
+	Pair      string          `json:"s"`
+	Id        int64           `json:"a"` // 归集成交id，连续递增
+	Price     decimal.Decimal `json:"p"`
+	Quantity  decimal.Decimal `json:"q"`
+	TradeTime int64           `json:"T"`
+	IsSell    bool            `json:"m"` // 买方是否为maker，即主动卖出
+}
+
 // 有限档深度信息
 type WSPayload_Depth struct {
 	Bids [][]decimal.Decimal `json:"bids"`
@@ -2,24 +2,108 @@
  * @Author: aztec
  * @Date: 2022-03-26 10:22:43
  * @Description: binance消息签名器
- *
+ * 支持HMAC-SHA256、RSA-PKCS1v15-SHA256、Ed25519三种api key。后两者的secret为pem格式私钥，签名为base64
+ * 币安推荐Ed25519，ws api的session.logon也只支持Ed25519
  * Copyright (c) 2022 by aztec, All Rights Reserved.
  */
 package binanceapi
 
 import (
+	"crypto/ed25519"
 	"crypto/hmac"
+	"crypto/rsa"
 	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
+	"github.com/aztecqt/dagger/util/crypto"
 	"github.com/aztecqt/dagger/util/logger"
 )
 
+// api key类型
+type KeyType string
+
+const (
+	KeyType_HMAC    KeyType = "hmac"
+	KeyType_RSA     KeyType = "rsa"
+	KeyType_Ed25519 KeyType = "ed25519"
+)
+
+// 对待签名字符串签名，返回可直接放入signature参数的字符串
+type payloadSigner interface {
+	sign(payload string) (string, error)
+}
+
+type hmacSigner struct {
+	secret string
+}
+
+func (s hmacSigner) sign(payload string) (string, error) {
+	return getParamHmacSHA256Sign(payload, s.secret)
+}
+
+type rsaSigner struct {
+	key *rsa.PrivateKey
+}
+
+func (s rsaSigner) sign(payload string) (string, error) {
+	b, err := crypto.RSASignSHA256(s.key, []byte(payload))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+func (s ed25519Signer) sign(payload string) (string, error) {
+	return base64.StdEncoding.EncodeToString(crypto.Ed25519Sign(s.key, []byte(payload))), nil
+}
+
+// 按key类型创建签名器。keyType为空时，secret是pem私钥则依次尝试Ed25519和RSA，否则视为HMAC
+func newPayloadSigner(keyType KeyType, secret string) (payloadSigner, KeyType, error) {
+	if len(keyType) == 0 {
+		if !strings.HasPrefix(strings.TrimSpace(secret), "-----BEGIN") {
+			return hmacSigner{secret: secret}, KeyType_HMAC, nil
+		} else if key, err := crypto.ParseEd25519PrivateKey([]byte(secret)); err == nil {
+			return ed25519Signer{key: key}, KeyType_Ed25519, nil
+		} else if key, err := crypto.ParseRSAPrivateKey([]byte(secret)); err == nil {
+			return rsaSigner{key: key}, KeyType_RSA, nil
+		} else {
+			return nil, keyType, fmt.Errorf("unknown private key: %s", err.Error())
+		}
+	}
+
+	switch keyType {
+	case KeyType_HMAC:
+		return hmacSigner{secret: secret}, keyType, nil
+	case KeyType_RSA:
+		key, err := crypto.ParseRSAPrivateKey([]byte(secret))
+		if err != nil {
+			return nil, keyType, err
+		}
+		return rsaSigner{key: key}, keyType, nil
+	case KeyType_Ed25519:
+		key, err := crypto.ParseEd25519PrivateKey([]byte(secret))
+		if err != nil {
+			return nil, keyType, err
+		}
+		return ed25519Signer{key: key}, keyType, nil
+	default:
+		return nil, keyType, fmt.Errorf("unknown key type: %s", keyType)
+	}
+}
+
 type signer struct {
 	key        string
 	secret     string
+	keyType    KeyType
+	ps         payloadSigner
 	serverTsFn func() int64
 }
 
@@ -29,10 +113,18 @@ var signerLogPrefix = "bn_signer"
 var inited bool = false
 
 func Init(key string, secret string, serverTsFn func() int64) {
-	SignerIns = new(signer)
-	SignerIns.key = key
-	SignerIns.secret = secret
-	SignerIns.serverTsFn = serverTsFn
+	InitWithKeyType(key, secret, "", serverTsFn)
+}
+
+// keyType为空时根据secret自动识别
+func InitWithKeyType(key string, secret string, keyType KeyType, serverTsFn func() int64) {
+	initHttpClient()
+	s, err := newSigner(key, secret, keyType, serverTsFn)
+	if err != nil {
+		logger.LogPanic(signerLogPrefix, "init signer failed: %s", err.Error())
+	}
+	SignerIns = s
+	logger.LogInfo(signerLogPrefix, "key type: %s", s.keyType)
 
 	// 获取服务器时间跟本地时间的差
 	for {
@@ -47,6 +139,28 @@ func Init(key string, secret string, serverTsFn func() int64) {
 	inited = true
 }
 
+// 没有secret时（只访问公共接口）使用空secret的HMAC签名器，签名接口仍可调用，只是请求会被交易所拒绝
+func newSigner(key, secret string, keyType KeyType, serverTsFn func() int64) (*signer, error) {
+	s := new(signer)
+	s.key = key
+	s.secret = secret
+	s.serverTsFn = serverTsFn
+
+	if len(secret) == 0 {
+		s.ps = hmacSigner{}
+		s.keyType = KeyType_HMAC
+		return s, nil
+	}
+
+	ps, kt, err := newPayloadSigner(keyType, secret)
+	if err != nil {
+		return nil, err
+	}
+	s.ps = ps
+	s.keyType = kt
+	return s, nil
+}
+
 func HasKey() bool {
 	return len(SignerIns.key) > 0 && len(SignerIns.secret) > 0
 }
@@ -67,7 +181,7 @@ func (s *signer) Sign(param url.Values) (header map[string]string, paramStr stri
 	param.Set("recvWindow", "10000")
 	payload := param.Encode()
 
-	signature, err := getParamHmacSHA256Sign(payload, s.secret)
+	signature, err := s.ps.sign(payload)
 	if err != nil {
 		logger.LogPanic(signerLogPrefix, "sign error!")
 		return
@@ -87,7 +201,7 @@ func (s *signer) Sign2(param url.Values) (header map[string]string, paramStr str
 	param.Set("recvWindow", "10000")
 	payload := param.Encode()
 
-	signature, err := getParamHmacSHA256Sign(payload, s.secret)
+	signature, err := s.ps.sign(payload)
 	if err != nil {
 		logger.LogPanic(signerLogPrefix, "sign error!")
 		return
@@ -102,6 +216,15 @@ func (s *signer) Sign2(param url.Values) (header map[string]string, paramStr str
 	return
 }
 
+func (s *signer) KeyType() KeyType {
+	return s.keyType
+}
+
+// 对任意待签名字符串签名，用于ws api的session.logon等不经过Sign的场景
+func (s *signer) SignPayload(payload string) (string, error) {
+	return s.ps.sign(payload)
+}
+
 func (s *signer) HeaderWithApiKey() map[string]string {
 	header := make(map[string]string)
 	header["X-MBX-APIKEY"] = s.key
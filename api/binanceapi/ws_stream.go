@@ -30,6 +30,7 @@ type WsStream struct {
 func (ws *WsStream) Start(baseUrl, streamName string, fnOnRawMsg api.OnRecvWSRawMsg) *api.WsSubscriber {
 	logger.LogImportant(wsLogPrefix, "starting...")
 	url := fmt.Sprintf("%s%s", baseUrl, streamName)
+	ws.wsConn.SetProxySelector(proxySelector)
 	ws.wsConn.Start(url, wsLogPrefix, fnOnRawMsg)
 
 	id := wsSubscribeId
@@ -50,6 +51,10 @@ func (ws *WsStream) Stop() {
 	ws.wsConn.Stop()
 }
 
+func (ws *WsStream) Connected() bool {
+	return ws.wsConn.Connected()
+}
+
 func SubscribeWithStream[T any](baseUrl, streamName, logPrefix string, fn api.OnRecvWSMsg) (*api.WsSubscriber, *WsStream) {
 	stream := new(WsStream)
 	s := stream.Start(baseUrl, streamName, func(rawMsg api.WSRawMsg) {
@@ -0,0 +1,46 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 23:48:02
+ * @Description: okx的rest请求使用独立的http客户端，超时、连接池、代理和统计与其他api分开
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package okexv5api
+
+import (
+	"net/url"
+
+	"github.com/aztecqt/dagger/util/network"
+)
+
+var httpClientConfig = network.DefaultHttpClientConfig()
+var httpClient *network.HttpClient
+var proxySelector network.ProxySelector
+
+// 修改http客户端配置，应在Init之前调用
+func SetHttpClientConfig(cfg network.HttpClientConfig) {
+	httpClientConfig = cfg
+}
+
+// 设置rest和ws使用的代理选择器（如代理池），应在Init之前调用。ws在下次重连时生效
+func SetProxySelector(s network.ProxySelector) {
+	proxySelector = s
+	if httpClient != nil {
+		httpClient.SetProxySelector(s)
+	}
+}
+
+func HttpClient() *network.HttpClient {
+	return httpClient
+}
+
+func initHttpClient() {
+	if httpClient != nil {
+		httpClient.Close()
+	}
+
+	httpClient = network.NewHttpClient("okexv5", httpClientConfig)
+	httpClient.SetProxySelector(proxySelector)
+	u, _ := url.Parse(rootUrl)
+	network.SetHttpClient(httpClient, u.Host)
+}
@@ -0,0 +1,73 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 20:40:12
+ * @Description: 交易请求的往返延迟统计。按通道(ws/rest)和操作分别统计
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package okexv5api
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/api"
+)
+
+type OpLatency struct {
+	Count    int64         // 成功次数
+	Fails    int64         // 失败次数（超时、网络错误等）
+	Last     time.Duration // 最近一次成功的延迟
+	Avg      time.Duration // 成功请求的平均延迟
+	Max      time.Duration // 成功请求的最大延迟
+	total    time.Duration
+	LastTime time.Time
+}
+
+func (l OpLatency) String() string {
+	return fmt.Sprintf("count=%d fails=%d last=%v avg=%v max=%v", l.Count, l.Fails, l.Last, l.Avg, l.Max)
+}
+
+var opLatency = make(map[string]*OpLatency)
+var muOpLatency sync.Mutex
+
+// 记录一次请求的延迟。channel为ws或rest，op为ws的操作名
+func RecordOpLatency(channel, op string, d time.Duration, ok bool) {
+	api.RecordOrderOp("OKEx", channel, op, d, ok)
+
+	key := channel + "." + op
+	muOpLatency.Lock()
+	defer muOpLatency.Unlock()
+
+	l, exist := opLatency[key]
+	if !exist {
+		l = new(OpLatency)
+		opLatency[key] = l
+	}
+
+	l.LastTime = time.Now()
+	if ok {
+		l.Count++
+		l.Last = d
+		l.total += d
+		l.Avg = l.total / time.Duration(l.Count)
+		if d > l.Max {
+			l.Max = d
+		}
+	} else {
+		l.Fails++
+	}
+}
+
+// 所有操作的延迟统计（拷贝），key形如ws.order、rest.cancel-order
+func OrderOpLatency() map[string]OpLatency {
+	muOpLatency.Lock()
+	defer muOpLatency.Unlock()
+
+	m := make(map[string]OpLatency)
+	for k, v := range opLatency {
+		m[k] = *v
+	}
+	return m
+}
@@ -476,10 +476,22 @@ type CancelOrderRestResp struct {
 	} `json:"data"`
 }
 
-// 批量撤单请求单元
+// 批量撤单请求单元。ordId和clOrdId至少填一个
 type CancelBatchOrderRestReq struct {
-	InstId  string `json:"instId"`
-	OrderId string `json:"ordId"`
+	InstId        string `json:"instId"`
+	OrderId       string `json:"ordId,omitempty"`
+	ClientOrderId string `json:"clOrdId,omitempty"`
+}
+
+// 批量改单请求单元。ordId和clOrdId至少填一个，newSz和newPx至少填一个
+type AmendBatchOrderRestReq struct {
+	InstId        string `json:"instId"`
+	OrderId       string `json:"ordId,omitempty"`
+	ClientOrderId string `json:"clOrdId,omitempty"`
+	ReqId         string `json:"reqId,omitempty"`
+	CxlOnFail     bool   `json:"cxlOnFail"`
+	NewSize       string `json:"newSz,omitempty"`
+	NewPrice      string `json:"newPx,omitempty"`
 }
 
 // 修改订单返回
@@ -494,6 +506,18 @@ type AmendOrderRestResp struct {
 	} `json:"data"`
 }
 
+// 定时撤单（cancel-all-after）返回
+type CancelAllAfterResp struct {
+	TriggerTime string `json:"triggerTime"` // 触发时间，0表示已解除
+	Tag         string `json:"tag"`
+	Ts          string `json:"ts"`
+}
+
+type CancelAllAfterRestResp struct {
+	CommonRestResp
+	Data []CancelAllAfterResp `json:"data"`
+}
+
 // 查询订单
 type OrderResp struct {
 	InstId        string `json:"instId"`
@@ -505,6 +529,7 @@ type OrderResp struct {
 	AccFillSize   string `json:"accFillSz"`
 	AvgPrice      string `json:"avgPx"`
 	Status        string `json:"state"` // alive/canceled/partially_filled/filled
+	CancelSource  string `json:"cancelSource"`
 	UTime         string `json:"uTime"`
 }
 
@@ -521,13 +546,15 @@ type OrderWsResp struct {
 
 // 查询成交
 type Fills struct {
-	InstType    string          `json:"instType"`
-	InstId      string          `json:"instId"`
-	Price       decimal.Decimal `json:"fillPx"`
-	Size        decimal.Decimal `json:"fillSz"`
-	Side        string          `json:"side"`
-	FillTimeStr string          `json:"fillTime"`
-	FillTime    time.Time
+	InstType      string          `json:"instType"`
+	InstId        string          `json:"instId"`
+	OrderId       string          `json:"ordId"`
+	ClientOrderId string          `json:"clOrdId"`
+	Price         decimal.Decimal `json:"fillPx"`
+	Size          decimal.Decimal `json:"fillSz"`
+	Side          string          `json:"side"`
+	FillTimeStr   string          `json:"fillTime"`
+	FillTime      time.Time
 }
 
 func (f *Fills) Parse() {
@@ -344,6 +344,7 @@ type TradesWsResp struct {
 		Size      decimal.Decimal `json:"sz"`
 		Side      string          `json:"side"`
 		TimeStamp string          `json:"ts"`
+		Count     string          `json:"count"` // 聚合的成交笔数
 	} `json:"data"`
 }
 
@@ -8,6 +8,7 @@
 package okexv5api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -592,6 +593,11 @@ func GetWithdrawHistory(clientId string) (*WithdrawHistoryResp, error) {
 
 // 下单
 func MakeOrder(instID, clientOrderId, tag, side, posSide, orderType, tradeMode string, reduceOnly bool, price, size decimal.Decimal) (*MakeorderRestResp, error) {
+	return MakeOrderCtx(context.Background(), instID, clientOrderId, tag, side, posSide, orderType, tradeMode, reduceOnly, price, size)
+}
+
+// 同MakeOrder，ctx取消或超时时请求立即返回
+func MakeOrderCtx(ctx context.Context, instID, clientOrderId, tag, side, posSide, orderType, tradeMode string, reduceOnly bool, price, size decimal.Decimal) (*MakeorderRestResp, error) {
 	action := "/api/v5/trade/order"
 	method := "POST"
 	url := rootUrl + action
@@ -611,12 +617,19 @@ func MakeOrder(instID, clientOrderId, tag, side, posSide, orderType, tradeMode s
 
 	b, _ := json.Marshal(req)
 	postStr := string(b)
-	resp, err := network.ParseHttpResult[MakeorderRestResp](restLogPrefix, "MakeOrder", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
+	t0 := time.Now()
+	resp, err := network.ParseHttpResultCtx[MakeorderRestResp](ctx, restLogPrefix, "MakeOrder", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
+	RecordOpLatency("rest", WsOp_Order, time.Since(t0), err == nil)
 	return resp, err
 }
 
 // 撤单
 func CancelOrder(instID, clientOrderId string, orderId int64) (*CancelOrderRestResp, error) {
+	return CancelOrderCtx(context.Background(), instID, clientOrderId, orderId)
+}
+
+// 同CancelOrder，ctx取消或超时时请求立即返回
+func CancelOrderCtx(ctx context.Context, instID, clientOrderId string, orderId int64) (*CancelOrderRestResp, error) {
 	action := "/api/v5/trade/cancel-order"
 	method := "POST"
 	url := rootUrl + action
@@ -632,12 +645,38 @@ func CancelOrder(instID, clientOrderId string, orderId int64) (*CancelOrderRestR
 
 	b, _ := json.Marshal(req)
 	postStr := string(b)
-	resp, err := network.ParseHttpResult[CancelOrderRestResp](restLogPrefix, "CancelOrder", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
+	t0 := time.Now()
+	resp, err := network.ParseHttpResultCtx[CancelOrderRestResp](ctx, restLogPrefix, "CancelOrder", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
+	RecordOpLatency("rest", WsOp_CancelOrder, time.Since(t0), err == nil)
+	return resp, err
+}
+
+// 定时撤单。timeOutSec秒后撤销所有挂单，取值10~120，0表示解除。tag非空时只撤销该tag的订单
+// 需要在倒计时结束前再次调用以续期
+func CancelAllAfter(timeOutSec int, tag string) (*CancelAllAfterRestResp, error) {
+	action := "/api/v5/trade/cancel-all-after"
+	method := "POST"
+	url := rootUrl + action
+
+	req := make(map[string]string)
+	req["timeOut"] = strconv.Itoa(timeOutSec)
+	if len(tag) > 0 {
+		req["tag"] = tag
+	}
+
+	b, _ := json.Marshal(req)
+	postStr := string(b)
+	resp, err := network.ParseHttpResult[CancelAllAfterRestResp](restLogPrefix, "CancelAllAfter", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
 	return resp, err
 }
 
 // 批量撤销订单
 func CancelOrderBatch(orders []CancelBatchOrderRestReq) (*CancelOrderRestResp, error) {
+	return CancelOrderBatchCtx(context.Background(), orders)
+}
+
+// 同CancelOrderBatch，ctx取消或超时时请求立即返回
+func CancelOrderBatchCtx(ctx context.Context, orders []CancelBatchOrderRestReq) (*CancelOrderRestResp, error) {
 	if len(orders) > 20 {
 		orders = orders[:20]
 	}
@@ -647,12 +686,63 @@ func CancelOrderBatch(orders []CancelBatchOrderRestReq) (*CancelOrderRestResp, e
 	url := rootUrl + action
 	b, _ := json.Marshal(orders)
 	postStr := string(b)
-	resp, err := network.ParseHttpResult[CancelOrderRestResp](restLogPrefix, "CancelOrderBatch", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
+	t0 := time.Now()
+	resp, err := network.ParseHttpResultCtx[CancelOrderRestResp](ctx, restLogPrefix, "CancelOrderBatch", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
+	RecordOpLatency("rest", WsOp_BatchCancelOrders, time.Since(t0), err == nil)
+	return resp, err
+}
+
+// 批量下单，最多20个
+func MakeOrderBatch(orders []MakeorderRestReq) (*MakeorderRestResp, error) {
+	return MakeOrderBatchCtx(context.Background(), orders)
+}
+
+// 同MakeOrderBatch，ctx取消或超时时请求立即返回
+func MakeOrderBatchCtx(ctx context.Context, orders []MakeorderRestReq) (*MakeorderRestResp, error) {
+	if len(orders) > 20 {
+		orders = orders[:20]
+	}
+
+	action := "/api/v5/trade/batch-orders"
+	method := "POST"
+	url := rootUrl + action
+	b, _ := json.Marshal(orders)
+	postStr := string(b)
+	t0 := time.Now()
+	resp, err := network.ParseHttpResultCtx[MakeorderRestResp](ctx, restLogPrefix, "MakeOrderBatch", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
+	RecordOpLatency("rest", WsOp_BatchOrders, time.Since(t0), err == nil)
+	return resp, err
+}
+
+// 批量修改订单，最多20个
+func AmendOrderBatch(orders []AmendBatchOrderRestReq) (*AmendOrderRestResp, error) {
+	return AmendOrderBatchCtx(context.Background(), orders)
+}
+
+// 同AmendOrderBatch，ctx取消或超时时请求立即返回
+func AmendOrderBatchCtx(ctx context.Context, orders []AmendBatchOrderRestReq) (*AmendOrderRestResp, error) {
+	if len(orders) > 20 {
+		orders = orders[:20]
+	}
+
+	action := "/api/v5/trade/amend-batch-orders"
+	method := "POST"
+	url := rootUrl + action
+	b, _ := json.Marshal(orders)
+	postStr := string(b)
+	t0 := time.Now()
+	resp, err := network.ParseHttpResultCtx[AmendOrderRestResp](ctx, restLogPrefix, "AmendOrderBatch", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
+	RecordOpLatency("rest", WsOp_BatchAmendOrders, time.Since(t0), err == nil)
 	return resp, err
 }
 
 // 修改订单
 func AmendOrder(instID, clientOrderId, reqId string, orderId int64, newPrice, newSize decimal.Decimal) (*AmendOrderRestResp, error) {
+	return AmendOrderCtx(context.Background(), instID, clientOrderId, reqId, orderId, newPrice, newSize)
+}
+
+// 同AmendOrder，ctx取消或超时时请求立即返回
+func AmendOrderCtx(ctx context.Context, instID, clientOrderId, reqId string, orderId int64, newPrice, newSize decimal.Decimal) (*AmendOrderRestResp, error) {
 	action := "/api/v5/trade/amend-order"
 	method := "POST"
 	url := rootUrl + action
@@ -681,12 +771,19 @@ func AmendOrder(instID, clientOrderId, reqId string, orderId int64, newPrice, ne
 
 	b, _ := json.Marshal(req)
 	postStr := string(b)
-	resp, err := network.ParseHttpResult[AmendOrderRestResp](restLogPrefix, "AmendOrder", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
+	t0 := time.Now()
+	resp, err := network.ParseHttpResultCtx[AmendOrderRestResp](ctx, restLogPrefix, "AmendOrder", url, method, postStr, signerIns.getHttpHeaderWithSign(method, action, postStr), nil, ErrorCallback)
+	RecordOpLatency("rest", WsOp_AmendOrder, time.Since(t0), err == nil)
 	return resp, err
 }
 
 // 查询订单
 func GetOrderInfo(instId string, orderId int64, clientOrderId string) (*OrderRestResp, error) {
+	return GetOrderInfoCtx(context.Background(), instId, orderId, clientOrderId)
+}
+
+// 同GetOrderInfo，ctx取消或超时时请求立即返回
+func GetOrderInfoCtx(ctx context.Context, instId string, orderId int64, clientOrderId string) (*OrderRestResp, error) {
 	action := "/api/v5/trade/order"
 	method := "GET"
 
@@ -701,24 +798,38 @@ func GetOrderInfo(instId string, orderId int64, clientOrderId string) (*OrderRes
 	action = action + "?" + params.Encode()
 	url := rootUrl + action
 
-	resp, err := network.ParseHttpResult[OrderRestResp](restLogPrefix, "GetOrderInfo", url, method, "", signerIns.getHttpHeaderWithSign(method, action, ""), nil, ErrorCallback)
+	resp, err := network.ParseHttpResultCtx[OrderRestResp](ctx, restLogPrefix, "GetOrderInfo", url, method, "", signerIns.getHttpHeaderWithSign(method, action, ""), nil, ErrorCallback)
 	resp.LocalTime = time.Now()
 	return resp, err
 }
 
 // 获取未成交的订单
 func GetPendingOrders(instId string) (*OrderRestResp, error) {
+	return GetPendingOrdersAfter(instId, "")
+}
+
+// 分页获取未成交的订单，每页最多100条，按订单id倒序
+// after为上一页最后一个订单的ordId，为空表示第一页
+func GetPendingOrdersAfter(instId, after string) (*OrderRestResp, error) {
 	action := "/api/v5/trade/orders-pending"
 	method := "GET"
 
+	params := url.Values{}
 	if len(instId) > 0 {
-		params := url.Values{}
 		params.Set("instId", instId)
+	}
+	if len(after) > 0 {
+		params.Set("after", after)
+	}
+	if len(params) > 0 {
 		action = action + "?" + params.Encode()
 	}
 
 	url := rootUrl + action
 	resp, err := network.ParseHttpResult[OrderRestResp](restLogPrefix, "GetPendingOrders", url, method, "", signerIns.getHttpHeaderWithSign(method, action, ""), nil, ErrorCallback)
+	if err == nil {
+		resp.LocalTime = time.Now()
+	}
 	return resp, err
 }
 
@@ -877,7 +988,9 @@ func GetMarketHistoryTrades(instId string, typ int, after, before int64) (*GetMa
 	action = action + "?" + params.Encode()
 	url := rootUrl + action
 	resp, err := network.ParseHttpResult[GetMarketTradesResp](restLogPrefix, "GetMarketHistoryTrades", url, method, "", nil, nil, ErrorCallback)
-	resp.Parse()
+	if err == nil {
+		resp.Parse()
+	}
 	return resp, err
 }
 
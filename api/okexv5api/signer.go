@@ -36,6 +36,7 @@ var signerLogPrefix = "okexv5_signer"
 var inited bool = false
 
 func Init(key string, secret string, pass string) {
+	initHttpClient()
 	signerIns = new(signer)
 	signerIns.key = key
 	signerIns.secret = secret
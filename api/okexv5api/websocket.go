@@ -17,8 +17,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aztecqt/dagger/api"
 	"github.com/aztecqt/dagger/util"
@@ -32,7 +34,8 @@ const wsLogPrefixPublic = "okexv5_public_ws"
 const wsLogPrefixPrivate = "okexv5_private_ws"
 
 type WsClient struct {
-	publicWsConn  api.WsConnection
+	publicWsPool  api.WsConnectionPool
+	publicPoolCfg *api.WsPoolConfig
 	privateWsConn api.WsConnection
 
 	// 内部数据解析（unmarshal）
@@ -52,14 +55,30 @@ type WsClient struct {
 	accountBalanceRespFn api.OnRecvWSMsg
 	positionRespFn       api.OnRecvWSMsg
 	ordersRespFn         api.OnRecvWSMsg
+
+	// websocket交易
+	trade wsTrade
+}
+
+// 公共频道连接池的配置，在Start之前调用。默认单连接
+func (ws *WsClient) SetPublicPoolConfig(cfg api.WsPoolConfig) {
+	ws.publicPoolCfg = &cfg
 }
 
 func (ws *WsClient) Start() {
 	logger.LogImportant(wsLogPrefix, "starting...")
-	ws.publicWsConn.Start(publicURL, wsLogPrefixPublic, ws.onRecvMsg)
-	p1 := api.Pinger{}
-	p1.Start(&ws.publicWsConn, wsLogPrefix, "ping", 25, 50)
-
+	cfg := api.DefaultWsPoolConfig()
+	if ws.publicPoolCfg != nil {
+		cfg = *ws.publicPoolCfg
+	}
+	ws.publicWsPool.SetProxySelector(proxySelector)
+	ws.publicWsPool.Start(publicURL, wsLogPrefixPublic, cfg, parseWsMsg, ws.onRecvMsg)
+	ws.publicWsPool.StartPinger("ping", 25, 50)
+
+	ws.trade.init()
+	ws.privateWsConn.SetCompression(cfg.Compression)
+	ws.privateWsConn.SetMsgParser(parseWsMsg)
+	ws.privateWsConn.SetProxySelector(proxySelector)
 	ws.privateWsConn.Start(privateURL, wsLogPrefixPrivate, ws.onRecvMsg)
 	p2 := api.Pinger{}
 	p2.Start(&ws.privateWsConn, wsLogPrefix, "ping", 25, 50)
@@ -90,6 +109,50 @@ func (ws *WsClient) Start() {
 }
 
 // #region public channels
+// 只在有事件时推送的频道，安静不代表卡住，不做卡住检测。其他频道使用连接池的默认设置
+var wsStallTimeouts = map[string]time.Duration{
+	"trades":             0,
+	"funding-rate":       0,
+	"price-limit":        0,
+	"liquidation-orders": 0,
+}
+
+// 频道key与订阅器名称一致：channel(instId)或channel(instType)
+func wsChannelKey(channel, inst string) string {
+	return fmt.Sprintf("%s(%s)", channel, inst)
+}
+
+// 解析频道key和服务器时间戳，用于连接池的延迟统计和卡住检测
+func parseWsMsg(msg api.WSRawMsg) (key string, serverTime time.Time) {
+	if !strings.Contains(msg.Str, `"data"`) {
+		return // 订阅/登录等事件回报，不算频道消息
+	}
+
+	arg := util.FetchMiddleString(&msg.Str, `"arg":{`, `}`)
+	channel := util.FetchMiddleString(&arg, `"channel":"`, `"`)
+	inst := util.FetchMiddleString(&arg, `"instId":"`, `"`)
+	if len(inst) == 0 {
+		inst = util.FetchMiddleString(&arg, `"instType":"`, `"`)
+	}
+	if len(channel) > 0 && len(inst) > 0 {
+		key = wsChannelKey(channel, inst)
+	}
+
+	if ts, err := strconv.ParseInt(util.FetchMiddleString(&msg.Str, `"ts":"`, `"`), 10, 64); err == nil {
+		serverTime = time.UnixMilli(ts)
+	}
+	return
+}
+
+func (ws *WsClient) subscribePublic(channel, inst string, s *api.WsSubscriber) {
+	key := wsChannelKey(channel, inst)
+	if d, ok := wsStallTimeouts[channel]; ok {
+		ws.publicWsPool.SubscribeWithStallTimeout(key, s, d)
+	} else {
+		ws.publicWsPool.Subscribe(key, s)
+	}
+}
+
 func (ws *WsClient) subscribePublicChannelWithInstID(channel string, instID string, fn api.OnRecvWSMsg, fnMap *map[string]api.OnRecvWSMsg) *api.WsSubscriber {
 	s := api.WsSubscriber{}
 	s.Init(
@@ -98,7 +161,7 @@ func (ws *WsClient) subscribePublicChannelWithInstID(channel string, instID stri
 		true,
 		nil,
 		[]string{"subscribe", channel, instID})
-	ws.publicWsConn.Subscribe(&s)
+	ws.subscribePublic(channel, instID, &s)
 
 	ws.muFns.Lock()
 	(*fnMap)[instID] = fn
@@ -115,7 +178,7 @@ func (ws *WsClient) unsubscribePublicChannelWithInstID(channel string, instID st
 		false,
 		nil,
 		[]string{"unsubscribe", channel, instID})
-	ws.publicWsConn.Subscribe(&s)
+	ws.publicWsPool.Unsubscribe(wsChannelKey(channel, instID), &s)
 }
 
 func (ws *WsClient) subscribePublicChannelWithInstType(channel string, instType string, fn api.OnRecvWSMsg, fnMap *map[string]api.OnRecvWSMsg) *api.WsSubscriber {
@@ -126,7 +189,7 @@ func (ws *WsClient) subscribePublicChannelWithInstType(channel string, instType
 		true,
 		nil,
 		[]string{"subscribe", channel, instType})
-	ws.publicWsConn.Subscribe(&s)
+	ws.subscribePublic(channel, instType, &s)
 
 	ws.muFns.Lock()
 	(*fnMap)[instType] = fn
@@ -143,7 +206,7 @@ func (ws *WsClient) unsubscribePublicChannelWithInstType(channel string, instTyp
 		false,
 		nil,
 		[]string{"unsubscribe", channel, instType})
-	ws.publicWsConn.Subscribe(&s)
+	ws.publicWsPool.Unsubscribe(wsChannelKey(channel, instType), &s)
 }
 
 func (ws *WsClient) findFromFnMap(fnMap map[string]api.OnRecvWSMsg, instId string) api.OnRecvWSMsg {
@@ -169,7 +232,7 @@ func (ws *WsClient) subscribePublicChannelWithInstIDMulti(channel string, instID
 		true,
 		nil,
 		[]string{"subscribe", channel, instID})
-	ws.publicWsConn.Subscribe(&s)
+	ws.subscribePublic(channel, instID, &s)
 
 	(*fnMap)[instID] = append((*fnMap)[instID], fn)
 	return &s
@@ -265,6 +328,30 @@ func (ws *WsClient) UnsubscribeLiquidationOrders(instType string) {
 	ws.unsubscribePublicChannelWithInstType("liquidation-orders", instType)
 }
 
+// 公共频道连接状态
+func (ws *WsClient) PublicConnected() bool {
+	return ws.publicWsPool.Connected()
+}
+
+// 公共频道各连接的统计
+func (ws *WsClient) PublicWsStats() []api.WsConnStat {
+	return ws.publicWsPool.Stats()
+}
+
+// 私有频道连接的统计
+func (ws *WsClient) PrivateWsStats() api.WsConnStat {
+	return ws.privateWsConn.Stats()
+}
+
+// 公共频道每次(重新)连接成功后，会向c写入重连次数
+func (ws *WsClient) AddPublicConnChan(c chan int) {
+	ws.publicWsPool.AddConnChans(c)
+}
+
+func (ws *WsClient) RemovePublicConnChan(c chan int) {
+	ws.publicWsPool.RemoveConnChans(c)
+}
+
 // #endregion
 
 // #region private channels
@@ -283,14 +370,16 @@ func (ws *WsClient) Login() {
 		[]string{`"login"`}) //{"event":"login", "msg" : "", "code": "0"}
 	ws.privateWsConn.Login(&s1)
 
-	s2 := api.WsSubscriber{}
-	s2.Init(
-		"login",
-		"",
-		true,
-		ws.loginStrGen,
-		[]string{`"login"`}) //{"event":"login", "msg" : "", "code": "0"}
-	ws.publicWsConn.Login(&s2)
+	ws.publicWsPool.Login(func() *api.WsSubscriber {
+		s2 := api.WsSubscriber{}
+		s2.Init(
+			"login",
+			"",
+			true,
+			ws.loginStrGen,
+			[]string{`"login"`}) //{"event":"login", "msg" : "", "code": "0"}
+		return &s2
+	})
 }
 
 // 账户数据
@@ -377,6 +466,9 @@ func (ws *WsClient) onRecvMsg(msg api.WSRawMsg) {
 		channel := util.FetchMiddleString(&msg.Str, `"arg":{"channel":"`, `"`)
 		if fn, ok := ws.rawRespFns[channel]; ok && fn != nil {
 			fn(msg)
+		} else if len(channel) == 0 && strings.Contains(msg.Str, `"op":"`) {
+			// 交易请求的返回，没有arg节点
+			ws.onOpResp(msg)
 		}
 	}
 }
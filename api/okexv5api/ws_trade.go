@@ -0,0 +1,281 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 20:31:45
+ * @Description: 通过私有websocket下单/改单/撤单
+ * 每个请求带有唯一id，服务器的返回以id与请求对应。超时未返回则返回错误，由调用方决定是否改用rest
+ * 返回值复用rest接口的结构，调用方可以用同样的逻辑处理
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package okexv5api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aztecqt/dagger/api"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	WsOp_Order       = "order"
+	WsOp_AmendOrder  = "amend-order"
+	WsOp_CancelOrder = "cancel-order"
+	WsOp_BatchOrders = "batch-orders"
+
+	WsOp_BatchAmendOrders  = "batch-amend-orders"
+	WsOp_BatchCancelOrders = "batch-cancel-orders"
+)
+
+// 批量操作单次最多包含的订单数
+const BatchOrderLimit = 20
+
+var ErrWsNotReady = errors.New("private websocket not ready")
+var ErrWsOpTimeout = errors.New("websocket op timeout")
+var ErrBatchTooLarge = fmt.Errorf("batch size exceeds %d", BatchOrderLimit)
+
+// 默认超时时间
+var WsOpTimeout = time.Second * 3
+
+type wsOpReq struct {
+	Id   string        `json:"id"`
+	Op   string        `json:"op"`
+	Args []interface{} `json:"args"`
+}
+
+type wsOpResp struct {
+	Id   string          `json:"id"`
+	Op   string          `json:"op"`
+	Code string          `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// websocket交易请求的上下文
+type wsTrade struct {
+	accId   int64
+	pending map[string]chan wsOpResp
+	mu      sync.Mutex
+}
+
+func (t *wsTrade) init() {
+	t.pending = make(map[string]chan wsOpResp)
+}
+
+func (t *wsTrade) newId() string {
+	return strconv.FormatInt(atomic.AddInt64(&t.accId, 1), 10)
+}
+
+func (t *wsTrade) register(id string) chan wsOpResp {
+	ch := make(chan wsOpResp, 1)
+	t.mu.Lock()
+	t.pending[id] = ch
+	t.mu.Unlock()
+	return ch
+}
+
+func (t *wsTrade) unregister(id string) {
+	t.mu.Lock()
+	delete(t.pending, id)
+	t.mu.Unlock()
+}
+
+func (t *wsTrade) dispatch(resp wsOpResp) bool {
+	t.mu.Lock()
+	ch, ok := t.pending[resp.Id]
+	delete(t.pending, resp.Id)
+	t.mu.Unlock()
+
+	if ok {
+		ch <- resp
+	}
+	return ok
+}
+
+// 私有连接是否可用于交易（已连接且已登录）
+func (ws *WsClient) PrivateReady() bool {
+	return ws.privateWsConn.LoggedIn()
+}
+
+// 发送一个交易请求，并等待服务器返回
+func (ws *WsClient) sendOp(op string, args []interface{}, timeout time.Duration) (*wsOpResp, error) {
+	if !ws.PrivateReady() {
+		return nil, ErrWsNotReady
+	}
+
+	req := wsOpReq{Id: ws.trade.newId(), Op: op, Args: args}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := ws.trade.register(req.Id)
+	defer ws.trade.unregister(req.Id)
+
+	t0 := time.Now()
+	ws.privateWsConn.Send(string(b))
+	select {
+	case resp := <-ch:
+		RecordOpLatency("ws", op, time.Since(t0), true)
+		return &resp, nil
+	case <-time.After(timeout):
+		RecordOpLatency("ws", op, time.Since(t0), false)
+		logger.LogImportant(wsLogPrefixPrivate, "%s(id=%s) timeout after %v", op, req.Id, timeout)
+		return nil, ErrWsOpTimeout
+	}
+}
+
+// 把返回的data解析到rest结构中
+func parseWsOpResp[T any](resp *wsOpResp) (*T, error) {
+	msg := fmt.Sprintf(`{"code":"%s","msg":%s,"data":%s}`, resp.Code, strconv.Quote(resp.Msg), util.ValueIf(len(resp.Data) > 0, string(resp.Data), "[]"))
+	t := new(T)
+	err := json.Unmarshal([]byte(msg), t)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// 下单
+func (ws *WsClient) MakeOrder(instID, clientOrderId, tag, side, posSide, orderType, tradeMode string, reduceOnly bool, price, size decimal.Decimal, timeout time.Duration) (*MakeorderRestResp, error) {
+	req := MakeorderRestReq{
+		InstId:        instID,
+		TradeMode:     tradeMode,
+		ClientOrderId: clientOrderId,
+		Tag:           tag,
+		Side:          side,
+		PosSide:       posSide,
+		OrderType:     orderType,
+		ReduceOnly:    reduceOnly,
+		Price:         price.String(),
+		Size:          size.String(),
+	}
+
+	resp, err := ws.sendOp(WsOp_Order, []interface{}{req}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseWsOpResp[MakeorderRestResp](resp)
+}
+
+// 批量下单，最多20个，超出时返回ErrBatchTooLarge，由调用方分批
+func (ws *WsClient) MakeOrders(reqs []MakeorderRestReq, timeout time.Duration) (*MakeorderRestResp, error) {
+	if len(reqs) > BatchOrderLimit {
+		return nil, ErrBatchTooLarge
+	}
+
+	args := make([]interface{}, 0, len(reqs))
+	for _, r := range reqs {
+		args = append(args, r)
+	}
+
+	resp, err := ws.sendOp(WsOp_BatchOrders, args, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseWsOpResp[MakeorderRestResp](resp)
+}
+
+// 修改订单
+func (ws *WsClient) AmendOrder(instID, clientOrderId, reqId string, orderId int64, newPrice, newSize decimal.Decimal, timeout time.Duration) (*AmendOrderRestResp, error) {
+	req := make(map[string]interface{})
+	req["instId"] = instID
+	req["cxlOnFail"] = true
+
+	if newPrice.IsPositive() {
+		req["newPx"] = newPrice.String()
+	}
+
+	if newSize.IsPositive() {
+		req["newSz"] = newSize.String()
+	}
+
+	if orderId > 0 {
+		req["ordId"] = strconv.FormatInt(orderId, 10)
+	}
+	if len(clientOrderId) > 0 {
+		req["clOrdId"] = clientOrderId
+	}
+	if len(reqId) > 0 {
+		req["reqId"] = reqId
+	}
+
+	resp, err := ws.sendOp(WsOp_AmendOrder, []interface{}{req}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseWsOpResp[AmendOrderRestResp](resp)
+}
+
+// 撤单
+func (ws *WsClient) CancelOrder(instID, clientOrderId string, orderId int64, timeout time.Duration) (*CancelOrderRestResp, error) {
+	req := make(map[string]string)
+	req["instId"] = instID
+	if orderId > 0 {
+		req["ordId"] = strconv.FormatInt(orderId, 10)
+	}
+	if len(clientOrderId) > 0 {
+		req["clOrdId"] = clientOrderId
+	}
+
+	resp, err := ws.sendOp(WsOp_CancelOrder, []interface{}{req}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseWsOpResp[CancelOrderRestResp](resp)
+}
+
+// 批量修改订单，最多20个，超出时返回ErrBatchTooLarge
+func (ws *WsClient) AmendOrders(reqs []AmendBatchOrderRestReq, timeout time.Duration) (*AmendOrderRestResp, error) {
+	if len(reqs) > BatchOrderLimit {
+		return nil, ErrBatchTooLarge
+	}
+
+	args := make([]interface{}, 0, len(reqs))
+	for _, r := range reqs {
+		args = append(args, r)
+	}
+
+	resp, err := ws.sendOp(WsOp_BatchAmendOrders, args, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseWsOpResp[AmendOrderRestResp](resp)
+}
+
+// 批量撤单，最多20个，超出时返回ErrBatchTooLarge
+func (ws *WsClient) CancelOrders(reqs []CancelBatchOrderRestReq, timeout time.Duration) (*CancelOrderRestResp, error) {
+	if len(reqs) > BatchOrderLimit {
+		return nil, ErrBatchTooLarge
+	}
+
+	args := make([]interface{}, 0, len(reqs))
+	for _, r := range reqs {
+		args = append(args, r)
+	}
+
+	resp, err := ws.sendOp(WsOp_BatchCancelOrders, args, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseWsOpResp[CancelOrderRestResp](resp)
+}
+
+// 交易请求的返回。返回true表示已处理
+func (ws *WsClient) onOpResp(msg api.WSRawMsg) bool {
+	resp := wsOpResp{}
+	if err := json.Unmarshal(msg.Data, &resp); err != nil || len(resp.Id) == 0 || len(resp.Op) == 0 {
+		return false
+	}
+
+	if !ws.trade.dispatch(resp) {
+		logger.LogInfo(wsLogPrefixPrivate, "recv %s response(id=%s) without pending request, maybe timeout", resp.Op, resp.Id)
+	}
+	return true
+}
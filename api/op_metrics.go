@@ -0,0 +1,34 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 02:52:18
+ * @Description: 交易请求（下单、撤单、改单）往返延迟的监控指标，各交易所的api共用
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package api
+
+import (
+	"time"
+
+	"github.com/aztecqt/dagger/util/metrics"
+)
+
+var orderOpLatency = metrics.NewHistogram(
+	"dagger_order_op_latency_seconds",
+	"Round trip time of successful order requests.",
+	nil, "exchange", "channel", "op")
+
+var orderOps = metrics.NewCounter(
+	"dagger_order_ops_total",
+	"Order requests by result.",
+	"exchange", "channel", "op", "result")
+
+// 记录一次交易请求。channel为ws或rest，op为操作名，如order、cancel-order
+func RecordOrderOp(exchange, channel, op string, d time.Duration, ok bool) {
+	if ok {
+		orderOpLatency.With(exchange, channel, op).Observe(d.Seconds())
+		orderOps.With(exchange, channel, op, "ok").Inc()
+	} else {
+		orderOps.With(exchange, channel, op, "fail").Inc()
+	}
+}
@@ -6,7 +6,11 @@
 
 package proxyprovider
 
-import "time"
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
 
 // 协议定义
 type Proxy struct {
@@ -25,6 +29,20 @@ func (p *Proxy) Merge(other *Proxy) {
 	*p = *other
 }
 
+// ip:port，作为代理在池中的唯一标识
+func (p *Proxy) Addr() string {
+	return fmt.Sprintf("%s:%d", p.IP, p.Port)
+}
+
+// socks5代理地址，可直接用于http.Transport和websocket.Dialer
+func (p *Proxy) URL() *url.URL {
+	u := &url.URL{Scheme: "socks5", Host: p.Addr()}
+	if len(p.UserName) > 0 {
+		u.User = url.UserPassword(p.UserName, p.Password)
+	}
+	return u
+}
+
 type Provider interface {
 	Name() string
 	GetAllProxies() ([]Proxy, error)                                       // 取得所有可用的Proxy
@@ -32,3 +50,19 @@ type Provider interface {
 	RenewProxies(ids []int, period int) ([]Proxy, error)                   // 续费
 	GetBalance() (float64, error)                                          // 获得当前余额
 }
+
+// 按名称创建供应商，用于从配置构建代理池
+func NewProvider(name string) (Provider, error) {
+	switch name {
+	case "proxyline":
+		p := new(ProxyLineApi)
+		p.Init()
+		return p, nil
+	case "iproyal":
+		p := new(IpRoyalApi)
+		p.Init()
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown proxy provider: %s", name)
+	}
+}
@@ -0,0 +1,410 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 23:52:40
+ * @Description: 代理池。从供应商或静态文件加载代理，定期健康检查，到期前自动续费
+ * 按host轮换：同一host的请求在健康的代理间轮流使用，分摊按ip计算的限频
+ * 按账号固定：交易所要求ip白名单时，账号固定使用同一个代理，即使该代理暂时不健康也不切换（切换会导致鉴权失败）
+ * ProxyPool和ForAccount返回的选择器都实现了network.ProxySelector，可直接用于HttpClient和WsConnection
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+
+package proxyprovider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/network"
+)
+
+type PoolConfig struct {
+	StaticFile    string        // 静态代理列表文件，json数组，格式同Proxy。为空则只从供应商加载
+	CheckUrl      string        // 健康检查通过代理访问的地址
+	CheckTimeout  time.Duration // 单次健康检查超时
+	CheckInterval time.Duration // 健康检查间隔
+	MaxFail       int           // 连续失败多少次视为不健康
+	LoadInterval  time.Duration // 从供应商重新拉取代理列表的间隔
+	RenewBefore   time.Duration // 到期前多久续费，为0则不自动续费
+	RenewPeriod   int           // 每次续费的时长（天）
+}
+
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		CheckUrl:      "https://api.ipify.org",
+		CheckTimeout:  time.Second * 10,
+		CheckInterval: time.Minute,
+		MaxFail:       3,
+		LoadInterval:  time.Hour,
+		RenewBefore:   time.Hour * 24 * 3,
+		RenewPeriod:   30,
+	}
+}
+
+type poolEntry struct {
+	px        Proxy
+	u         *url.URL
+	healthy   bool
+	fails     int
+	latency   time.Duration
+	lastCheck time.Time
+}
+
+// 代理状态（拷贝）
+type ProxyStatus struct {
+	Proxy
+	Healthy   bool
+	Latency   time.Duration
+	LastCheck time.Time
+}
+
+type ProxyPool struct {
+	cfg       PoolConfig
+	logPrefix string
+	providers map[string]Provider
+
+	entries map[string] /*ip:port*/ *poolEntry
+	pins    map[string] /*account*/ string /*ip:port*/
+	rotate  map[string] /*host*/ int
+	mu      sync.Mutex
+}
+
+func NewProxyPool(cfg PoolConfig, providers ...Provider) *ProxyPool {
+	p := new(ProxyPool)
+	p.cfg = cfg
+	p.logPrefix = "ProxyPool"
+	p.providers = make(map[string]Provider)
+	for _, pv := range providers {
+		p.providers[pv.Name()] = pv
+	}
+	p.entries = make(map[string]*poolEntry)
+	p.pins = make(map[string]string)
+	p.rotate = make(map[string]int)
+	return p
+}
+
+// 加载代理并做一次健康检查，然后启动后台的检查、加载、续费
+func (p *ProxyPool) Start() error {
+	if err := p.Load(); err != nil {
+		return err
+	}
+	p.Check()
+	go p.update()
+	return nil
+}
+
+func (p *ProxyPool) update() {
+	tkCheck := time.NewTicker(p.cfg.CheckInterval)
+	tkLoad := time.NewTicker(p.cfg.LoadInterval)
+	tkRenew := time.NewTicker(time.Minute)
+	for {
+		select {
+		case <-tkCheck.C:
+			p.Check()
+		case <-tkLoad.C:
+			p.Load()
+		case <-tkRenew.C:
+			if p.cfg.RenewBefore > 0 {
+				p.Renew()
+			}
+		}
+	}
+}
+
+// #region 加载
+// 从静态文件和所有供应商加载代理。已有代理只更新信息，保留健康状态
+// 已过期且未被固定的代理会被移除
+func (p *ProxyPool) Load() error {
+	pxs := make([]Proxy, 0)
+	if len(p.cfg.StaticFile) > 0 {
+		static := make([]Proxy, 0)
+		if !util.ObjectFromFile(p.cfg.StaticFile, &static) {
+			return fmt.Errorf("load proxies from %s failed", p.cfg.StaticFile)
+		}
+		pxs = append(pxs, static...)
+	}
+
+	for name, pv := range p.providers {
+		if ps, err := pv.GetAllProxies(); err == nil {
+			pxs = append(pxs, ps...)
+		} else {
+			logger.LogImportant(p.logPrefix, "load proxies from %s failed: %s", name, err.Error())
+		}
+	}
+
+	p.merge(pxs)
+	p.removeExpired()
+
+	p.mu.Lock()
+	n := len(p.entries)
+	p.mu.Unlock()
+	logger.LogInfo(p.logPrefix, "loaded, %d proxies in pool", n)
+
+	if n == 0 {
+		return errors.New("no proxy available")
+	}
+	return nil
+}
+
+func (p *ProxyPool) merge(pxs []Proxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range pxs {
+		px := &pxs[i]
+		if e, ok := p.entries[px.Addr()]; ok {
+			e.px.Merge(px)
+			e.u = px.URL()
+		} else {
+			// 新代理在检查之前视为健康
+			p.entries[px.Addr()] = &poolEntry{px: *px, u: px.URL(), healthy: true}
+		}
+	}
+}
+
+func (p *ProxyPool) removeExpired() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pinned := make(map[string]bool)
+	for _, addr := range p.pins {
+		pinned[addr] = true
+	}
+
+	now := time.Now()
+	for addr, e := range p.entries {
+		if !e.px.ExpireTime.IsZero() && e.px.ExpireTime.Before(now) && !pinned[addr] {
+			logger.LogImportant(p.logPrefix, "proxy %s(%s) expired, removed", addr, e.px.Provider)
+			delete(p.entries, addr)
+		}
+	}
+}
+
+// #endregion
+
+// #region 健康检查
+// 并发检查所有代理
+func (p *ProxyPool) Check() {
+	p.mu.Lock()
+	entries := make([]*poolEntry, 0, len(p.entries))
+	urls := make([]*url.URL, 0, len(p.entries))
+	for _, e := range p.entries {
+		entries = append(entries, e)
+		urls = append(urls, e.u)
+	}
+	p.mu.Unlock()
+
+	wg := sync.WaitGroup{}
+	for i := range entries {
+		wg.Add(1)
+		go func(e *poolEntry, u *url.URL) {
+			defer wg.Done()
+			latency, err := p.check(u)
+			p.onChecked(e, latency, err)
+		}(entries[i], urls[i])
+	}
+	wg.Wait()
+}
+
+func (p *ProxyPool) check(u *url.URL) (time.Duration, error) {
+	transport := &http.Transport{Proxy: http.ProxyURL(u), DisableKeepAlives: true}
+	client := &http.Client{Transport: transport, Timeout: p.cfg.CheckTimeout}
+	t0 := time.Now()
+	resp, err := client.Get(p.cfg.CheckUrl)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return time.Since(t0), nil
+}
+
+func (p *ProxyPool) onChecked(e *poolEntry, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.lastCheck = time.Now()
+	if err == nil {
+		if !e.healthy {
+			logger.LogImportant(p.logPrefix, "proxy %s recovered, latency=%v", e.px.Addr(), latency)
+		}
+		e.healthy = true
+		e.fails = 0
+		e.latency = latency
+	} else {
+		e.fails++
+		if e.healthy && e.fails >= p.cfg.MaxFail {
+			e.healthy = false
+			logger.LogImportant(p.logPrefix, "proxy %s unhealthy: %s", e.px.Addr(), err.Error())
+		}
+	}
+}
+
+// #endregion
+
+// #region 续费
+// 续费即将到期的代理。同一订单的多个代理只续费一次
+func (p *ProxyPool) Renew() {
+	deadline := time.Now().Add(p.cfg.RenewBefore)
+	ids := make(map[string] /*provider*/ map[int]bool)
+
+	p.mu.Lock()
+	for _, e := range p.entries {
+		if _, ok := p.providers[e.px.Provider]; !ok {
+			continue // 静态代理无法续费
+		}
+
+		if !e.px.ExpireTime.IsZero() && e.px.ExpireTime.Before(deadline) {
+			if _, ok := ids[e.px.Provider]; !ok {
+				ids[e.px.Provider] = make(map[int]bool)
+			}
+			ids[e.px.Provider][e.px.OrderID] = true
+		}
+	}
+	p.mu.Unlock()
+
+	for name, idset := range ids {
+		idlist := make([]int, 0, len(idset))
+		for id := range idset {
+			idlist = append(idlist, id)
+		}
+		sort.Ints(idlist)
+
+		pxs, err := p.providers[name].RenewProxies(idlist, p.cfg.RenewPeriod)
+		if err != nil {
+			logger.LogImportant(p.logPrefix, "renew %s proxies %v failed: %s", name, idlist, err.Error())
+			continue
+		}
+
+		logger.LogImportant(p.logPrefix, "renewed %s proxies %v for %d days", name, idlist, p.cfg.RenewPeriod)
+		p.merge(pxs)
+	}
+}
+
+// #endregion
+
+// #region 选择
+// 按host轮换健康的代理。没有健康代理时返回nil
+func (p *ProxyPool) ProxyFor(host string) *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	healthy := p.healthyAddrs()
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	i := p.rotate[host] % len(healthy)
+	p.rotate[host] = i + 1
+	return p.entries[healthy[i]].u
+}
+
+// 排序后的健康代理，保证轮换顺序稳定
+func (p *ProxyPool) healthyAddrs() []string {
+	addrs := make([]string, 0, len(p.entries))
+	for addr, e := range p.entries {
+		if e.healthy {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// 将账号固定到指定ip的代理（交易所白名单中的ip）
+func (p *ProxyPool) Pin(account, ip string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for addr, e := range p.entries {
+		if e.px.IP == ip {
+			p.pins[account] = addr
+			logger.LogInfo(p.logPrefix, "account %s pinned to %s", account, addr)
+			return nil
+		}
+	}
+	return fmt.Errorf("no proxy with ip %s", ip)
+}
+
+// 账号固定使用的代理。未固定时，选择被固定次数最少的健康代理并固定下来
+// 被固定的代理不健康时仍然返回它，保持出口ip不变
+func (p *ProxyPool) ProxyForAccount(account string) *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if addr, ok := p.pins[account]; ok {
+		if e, ok := p.entries[addr]; ok {
+			return e.u
+		}
+	}
+
+	pinCount := make(map[string]int)
+	for _, addr := range p.pins {
+		pinCount[addr]++
+	}
+
+	best := ""
+	for _, addr := range p.healthyAddrs() {
+		if len(best) == 0 || pinCount[addr] < pinCount[best] {
+			best = addr
+		}
+	}
+
+	if len(best) == 0 {
+		return nil
+	}
+
+	p.pins[account] = best
+	logger.LogInfo(p.logPrefix, "account %s pinned to %s", account, best)
+	return p.entries[best].u
+}
+
+// 账号固定的出口ip，未固定时返回空
+func (p *ProxyPool) PinnedIP(account string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if addr, ok := p.pins[account]; ok {
+		if e, ok := p.entries[addr]; ok {
+			return e.px.IP
+		}
+	}
+	return ""
+}
+
+type accountSelector struct {
+	pool    *ProxyPool
+	account string
+}
+
+func (s accountSelector) ProxyFor(host string) *url.URL {
+	return s.pool.ProxyForAccount(s.account)
+}
+
+// 账号的代理选择器，该账号的所有host都使用同一个固定代理
+func (p *ProxyPool) ForAccount(account string) network.ProxySelector {
+	return accountSelector{pool: p, account: account}
+}
+
+// #endregion
+
+// 所有代理的状态，按地址排序
+func (p *ProxyPool) Status() []ProxyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sts := make([]ProxyStatus, 0, len(p.entries))
+	for _, e := range p.entries {
+		sts = append(sts, ProxyStatus{Proxy: e.px, Healthy: e.healthy, Latency: e.latency, LastCheck: e.lastCheck})
+	}
+	sort.Slice(sts, func(i, j int) bool { return sts[i].Addr() < sts[j].Addr() })
+	return sts
+}
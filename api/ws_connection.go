@@ -13,11 +13,13 @@ package api
 
 import (
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
 	"github.com/aztecqt/dagger/util"
 	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/network"
 	"github.com/gorilla/websocket"
 )
 
@@ -56,6 +58,27 @@ type WsConnection struct {
 
 	// 消息接收回调，主要用于给消息处理
 	onRecv OnRecvWSRawMsg
+
+	// 代理选择器，为空时使用环境变量中的代理
+	proxySelector network.ProxySelector
+
+	// 是否协商permessage-deflate压缩
+	compression bool
+
+	// 消息统计
+	parser  WsMsgParser
+	stat    wsStat
+	muStats sync.Mutex
+}
+
+// 设置连接使用的代理，在Start之前调用，或在下次重连时生效
+func (ws *WsConnection) SetProxySelector(s network.ProxySelector) {
+	ws.proxySelector = s
+}
+
+// 是否协商permessage-deflate压缩，在Start之前调用，或在下次重连时生效
+func (ws *WsConnection) SetCompression(enable bool) {
+	ws.compression = enable
 }
 
 // 启动
@@ -69,6 +92,7 @@ func (ws *WsConnection) Start(url string, logPrefix string, onRecv OnRecvWSRawMs
 
 	// 启动主循环
 	logger.LogImportant(logPrefix, "websocket starting...")
+	registerConn(ws)
 	go ws.keepConnecting()
 	go ws.keepSubscribing()
 }
@@ -76,6 +100,7 @@ func (ws *WsConnection) Start(url string, logPrefix string, onRecv OnRecvWSRawMs
 func (ws *WsConnection) Stop() {
 	logger.LogImportant(ws.logPrefix, "stopping...")
 	ws.needStop = true
+	unregisterConn(ws)
 	if ws.Conn != nil {
 		ws.Conn.Close()
 		ws.Conn = nil
@@ -86,6 +111,11 @@ func (ws *WsConnection) Connected() bool {
 	return ws.Conn != nil
 }
 
+// 已连接且已登录
+func (ws *WsConnection) LoggedIn() bool {
+	return ws.Connected() && ws.subLogin != nil && ws.subLogin.Successed()
+}
+
 func (ws *WsConnection) Reconnect(reason string) {
 	logger.LogImportant(ws.logPrefix, "need reconnect, reason=[%s], close current connection", reason)
 	if ws.Conn != nil {
@@ -131,7 +161,12 @@ func (ws *WsConnection) connect() {
 	logger.LogImportant(ws.logPrefix, "connecting...(%d) url=%s", ws.reConnCount, ws.url)
 	ws.reConnCount++
 
-	dialer := websocket.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: 5 * time.Second}
+	selector := ws.proxySelector
+	dialer := websocket.Dialer{
+		Proxy:             func(req *http.Request) (*url.URL, error) { return network.ProxyOf(selector, req) },
+		HandshakeTimeout:  5 * time.Second,
+		EnableCompression: ws.compression,
+	}
 	for i := 0; ; i++ {
 		logger.LogImportant(ws.logPrefix, "dialing....(%d)", i)
 		c, _, err := dialer.Dial(ws.url, nil)
@@ -239,6 +274,7 @@ func (ws *WsConnection) readMessage() {
 						logger.LogDebug(ws.logPrefix, "recv: %s", msgStr)
 					}
 
+					ws.record(msg)
 					ws.onRecv(msg)
 					ws.notifyMessageToChans(msg)
 				}
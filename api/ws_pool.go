@@ -0,0 +1,273 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 00:38:15
+ * @Description: ws连接池。同一个url开多条连接，按频道key把订阅分散到各条连接上
+ * 用于绕开交易所对单连接订阅数的限制，同时避免一个处理慢的频道拖住所有频道（每条连接有独立的读取协程）
+ * 订阅优先分配给订阅数最少的连接，同一个key始终在同一条连接上，退订也发往这条连接
+ * 每个频道超过卡住检测时间（默认StallTimeout，可按频道指定）没有消息视为静默卡住，通过Reset让WsSubscriber重新走一遍订阅流程
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+
+package api
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/network"
+)
+
+type WsPoolConfig struct {
+	Shards         int           // 连接数
+	MaxSubsPerConn int           // 每条连接的最大订阅数，0表示不限。超出时仍然分配到订阅最少的连接，并输出日志
+	Compression    bool          // 是否协商permessage-deflate压缩
+	StallTimeout   time.Duration // 频道多久没有消息视为卡住，0表示不检测
+}
+
+func DefaultWsPoolConfig() WsPoolConfig {
+	return WsPoolConfig{
+		Shards:       1,
+		StallTimeout: time.Minute,
+	}
+}
+
+type wsPoolSub struct {
+	key          string
+	s            *WsSubscriber
+	shard        int
+	stallTimeout time.Duration
+	stallLogged  bool
+}
+
+type WsConnectionPool struct {
+	cfg       WsPoolConfig
+	logPrefix string
+	conns     []*WsConnection
+
+	subs      map[string] /*key*/ *wsPoolSub
+	subCounts []int
+	mu        sync.Mutex
+
+	proxySelector network.ProxySelector
+}
+
+// 启动所有连接。parser用于统计延迟和检测频道卡住，可以为nil
+func (p *WsConnectionPool) Start(url, logPrefix string, cfg WsPoolConfig, parser WsMsgParser, onRecv OnRecvWSRawMsg) {
+	p.cfg = cfg
+	p.cfg.Shards = max(cfg.Shards, 1)
+	p.logPrefix = logPrefix
+	p.subs = make(map[string]*wsPoolSub)
+	p.subCounts = make([]int, p.cfg.Shards)
+	p.conns = make([]*WsConnection, p.cfg.Shards)
+
+	for i := range p.conns {
+		c := new(WsConnection)
+		c.SetCompression(cfg.Compression)
+		c.SetMsgParser(parser)
+		c.SetProxySelector(p.proxySelector)
+		c.Start(url, p.connLogPrefix(i), onRecv)
+		p.conns[i] = c
+	}
+
+	if cfg.StallTimeout > 0 && parser != nil {
+		go p.monitor()
+	}
+}
+
+func (p *WsConnectionPool) connLogPrefix(i int) string {
+	if len(p.conns) == 1 {
+		return p.logPrefix
+	} else {
+		return fmt.Sprintf("%s-%d", p.logPrefix, i)
+	}
+}
+
+func (p *WsConnectionPool) Stop() {
+	for _, c := range p.conns {
+		c.Stop()
+	}
+}
+
+func (p *WsConnectionPool) Conns() []*WsConnection {
+	return p.conns
+}
+
+// 所有连接都已连接
+func (p *WsConnectionPool) Connected() bool {
+	for _, c := range p.conns {
+		if !c.Connected() {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *WsConnectionPool) Ready() bool {
+	for _, c := range p.conns {
+		if !c.Ready() {
+			return false
+		}
+	}
+	return true
+}
+
+// 所有连接使用同一个代理选择器。在Start之前调用，或在下次重连时生效
+func (p *WsConnectionPool) SetProxySelector(s network.ProxySelector) {
+	p.proxySelector = s
+	for _, c := range p.conns {
+		c.SetProxySelector(s)
+	}
+}
+
+// 为每条连接启动Pinger
+func (p *WsConnectionPool) StartPinger(sendStr string, sendInterval, reconnectInterval int64) {
+	for i, c := range p.conns {
+		pinger := Pinger{}
+		pinger.Start(c, p.connLogPrefix(i), sendStr, sendInterval, reconnectInterval)
+	}
+}
+
+// 每条连接各自登录，gen为每条连接生成一个登录订阅器
+func (p *WsConnectionPool) Login(gen func() *WsSubscriber) {
+	for _, c := range p.conns {
+		c.Login(gen())
+	}
+}
+
+// 任意一条连接(重新)连接成功后，向c写入该连接的重连次数
+func (p *WsConnectionPool) AddConnChans(c chan int) {
+	for _, conn := range p.conns {
+		conn.AddConnChans(c)
+	}
+}
+
+func (p *WsConnectionPool) RemoveConnChans(c chan int) {
+	for _, conn := range p.conns {
+		conn.RemoveConnChans(c)
+	}
+}
+
+// 订阅。key需要与parser解析出的频道key一致，才能检测卡住
+func (p *WsConnectionPool) Subscribe(key string, s *WsSubscriber) {
+	p.SubscribeWithStallTimeout(key, s, p.cfg.StallTimeout)
+}
+
+// 订阅并指定该频道的卡住检测时间。只在有事件时才推送的频道（如成交、资金费率）应传0，否则安静时会被反复重新订阅
+func (p *WsConnectionPool) SubscribeWithStallTimeout(key string, s *WsSubscriber, stallTimeout time.Duration) {
+	p.mu.Lock()
+	sub, ok := p.subs[key]
+	if ok {
+		sub.s = s
+		sub.stallTimeout = stallTimeout
+	} else {
+		sub = &wsPoolSub{key: key, s: s, shard: p.pickShard(key), stallTimeout: stallTimeout}
+		p.subs[key] = sub
+		p.subCounts[sub.shard]++
+	}
+	conn := p.conns[sub.shard]
+	p.mu.Unlock()
+
+	conn.Subscribe(s)
+}
+
+// 退订。退订请求发往订阅所在的连接，并停止检测该频道
+func (p *WsConnectionPool) Unsubscribe(key string, s *WsSubscriber) {
+	p.mu.Lock()
+	shard := 0
+	if sub, ok := p.subs[key]; ok {
+		shard = sub.shard
+		p.subCounts[shard]--
+		delete(p.subs, key)
+	}
+	conn := p.conns[shard]
+	p.mu.Unlock()
+
+	conn.Subscribe(s)
+}
+
+// 修改某个频道的卡住检测时间，适用于推送频率较低的频道。0表示不检测
+func (p *WsConnectionPool) SetStallTimeout(key string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sub, ok := p.subs[key]; ok {
+		sub.stallTimeout = d
+	}
+}
+
+func (p *WsConnectionPool) pickShard(key string) int {
+	best := 0
+	for i, n := range p.subCounts {
+		if n < p.subCounts[best] {
+			best = i
+		}
+	}
+
+	if p.cfg.MaxSubsPerConn > 0 && p.subCounts[best] >= p.cfg.MaxSubsPerConn {
+		logger.LogImportant(p.logPrefix, "all connections reached max subscriptions(%d), %s assigned to conn %d", p.cfg.MaxSubsPerConn, key, best)
+	}
+	return best
+}
+
+// 各连接的统计
+func (p *WsConnectionPool) Stats() []WsConnStat {
+	sts := make([]WsConnStat, 0, len(p.conns))
+	for i, c := range p.conns {
+		st := c.Stats()
+		st.Url = fmt.Sprintf("%s#%d", st.Url, i)
+		sts = append(sts, st)
+	}
+	return sts
+}
+
+// 订阅所在连接的编号，未订阅时返回-1
+func (p *WsConnectionPool) ShardOf(key string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sub, ok := p.subs[key]; ok {
+		return sub.shard
+	}
+	return -1
+}
+
+func (p *WsConnectionPool) monitor() {
+	ticker := time.NewTicker(time.Second)
+	for range ticker.C {
+		p.checkStall()
+	}
+}
+
+// 订阅成功后超过stallTimeout没有消息的频道，重新订阅
+// 连接断开时由重连流程处理，这里不管。Reset会访问连接，在锁外进行
+func (p *WsConnectionPool) checkStall() {
+	now := time.Now()
+	stalled := make([]*WsSubscriber, 0)
+	p.mu.Lock()
+	for key, sub := range p.subs {
+		conn := p.conns[sub.shard]
+		if sub.stallTimeout <= 0 || !conn.Connected() || !sub.s.Successed() {
+			continue
+		}
+
+		last := conn.LastRecvOf(key)
+		if t := sub.s.SuccessTime(); t.After(last) {
+			last = t
+		}
+
+		if now.Sub(last) > sub.stallTimeout {
+			if !sub.stallLogged {
+				logger.LogImportant(p.connLogPrefix(sub.shard), "channel %s stalled for %v, resubscribing", key, now.Sub(last).Truncate(time.Second))
+				sub.stallLogged = true
+			}
+			stalled = append(stalled, sub.s)
+		} else {
+			sub.stallLogged = false
+		}
+	}
+	p.mu.Unlock()
+
+	for _, s := range stalled {
+		s.Reset()
+	}
+}
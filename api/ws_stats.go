@@ -0,0 +1,182 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 00:21:36
+ * @Description: ws连接的消息统计：消息速率、流量、延迟（服务器时间戳与本地接收时间之差），以及每个频道最后收到消息的时间
+ * 频道和服务器时间戳的解析与交易所相关，由WsMsgParser提供。没有设置parser时只统计速率和流量
+ * 所有已启动的连接登记在一起，AllWsStats可以取到全部连接的统计，供监控使用
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+
+package api
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 从消息中解析出频道key和服务器时间戳。不属于任何频道的消息返回空key，没有时间戳的返回零值
+type WsMsgParser func(msg WSRawMsg) (key string, serverTime time.Time)
+
+// 统计窗口。速率、平均延迟、最大延迟都按窗口计算
+const wsStatWindow = time.Second * 5
+
+type WsConnStat struct {
+	Name        string // 连接的日志前缀
+	Url         string
+	Connected   bool
+	ReconnCount int
+	MsgCount    int64         // 累计消息数
+	Bytes       int64         // 累计字节数（解压后）
+	MsgRate     float64       // 最近一个窗口的消息速率（条/秒）
+	LagLast     time.Duration // 最近一条消息的延迟
+	LagAvg      time.Duration // 最近一个窗口的平均延迟
+	LagMax      time.Duration // 最近一个窗口的最大延迟
+	LastRecv    time.Time
+}
+
+func (s WsConnStat) String() string {
+	return fmt.Sprintf("connected=%v reconn=%d msgs=%d bytes=%d rate=%.1f/s lag=%v avg=%v max=%v",
+		s.Connected, s.ReconnCount, s.MsgCount, s.Bytes, s.MsgRate, s.LagLast, s.LagAvg, s.LagMax)
+}
+
+type wsStat struct {
+	msgCount int64
+	bytes    int64
+	lastRecv time.Time
+	lagLast  time.Duration
+
+	// 当前窗口
+	winStart time.Time
+	winMsgs  int64
+	winLag   time.Duration
+	winLagN  int64
+	winLagMx time.Duration
+
+	// 上一个完整窗口的结果
+	rate   float64
+	lagAvg time.Duration
+	lagMax time.Duration
+
+	keyRecv map[string]time.Time
+}
+
+// 设置消息解析器，用于计算延迟和按频道记录最后接收时间
+func (ws *WsConnection) SetMsgParser(p WsMsgParser) {
+	ws.parser = p
+}
+
+func (ws *WsConnection) record(msg WSRawMsg) {
+	key, serverTime := "", time.Time{}
+	if ws.parser != nil {
+		key, serverTime = ws.parser(msg)
+	}
+
+	ws.muStats.Lock()
+	defer ws.muStats.Unlock()
+
+	st := &ws.stat
+	if st.winStart.IsZero() {
+		st.winStart = msg.LocalTime
+		st.keyRecv = make(map[string]time.Time)
+	}
+
+	st.msgCount++
+	st.bytes += int64(len(msg.Str))
+	st.lastRecv = msg.LocalTime
+	st.winMsgs++
+
+	if len(key) > 0 {
+		st.keyRecv[key] = msg.LocalTime
+	}
+
+	if !serverTime.IsZero() {
+		lag := msg.LocalTime.Sub(serverTime)
+		st.lagLast = lag
+		st.winLag += lag
+		st.winLagN++
+		st.winLagMx = max(st.winLagMx, lag)
+	}
+
+	if elapsed := msg.LocalTime.Sub(st.winStart); elapsed >= wsStatWindow {
+		st.rate = float64(st.winMsgs) / elapsed.Seconds()
+		st.lagAvg, st.lagMax = 0, st.winLagMx
+		if st.winLagN > 0 {
+			st.lagAvg = st.winLag / time.Duration(st.winLagN)
+		}
+		st.winStart = msg.LocalTime
+		st.winMsgs, st.winLag, st.winLagN, st.winLagMx = 0, 0, 0, 0
+	}
+}
+
+func (ws *WsConnection) Stats() WsConnStat {
+	ws.muStats.Lock()
+	defer ws.muStats.Unlock()
+
+	st := &ws.stat
+	s := WsConnStat{
+		Name:        ws.logPrefix,
+		Url:         ws.url,
+		Connected:   ws.Connected(),
+		ReconnCount: ws.reConnCount,
+		MsgCount:    st.msgCount,
+		Bytes:       st.bytes,
+		MsgRate:     st.rate,
+		LagLast:     st.lagLast,
+		LagAvg:      st.lagAvg,
+		LagMax:      st.lagMax,
+		LastRecv:    st.lastRecv,
+	}
+
+	// 长时间没有消息时，上一个窗口的速率已经过时
+	if time.Since(st.lastRecv) > wsStatWindow*2 {
+		s.MsgRate = 0
+	}
+	return s
+}
+
+// 某个频道最后收到消息的时间，从未收到时返回零值
+func (ws *WsConnection) LastRecvOf(key string) time.Time {
+	ws.muStats.Lock()
+	defer ws.muStats.Unlock()
+	return ws.stat.keyRecv[key]
+}
+
+// 已启动的连接，Start时登记，Stop时移除
+var allConns = make(map[*WsConnection]bool)
+var muAllConns sync.Mutex
+
+func registerConn(ws *WsConnection) {
+	muAllConns.Lock()
+	defer muAllConns.Unlock()
+	allConns[ws] = true
+}
+
+func unregisterConn(ws *WsConnection) {
+	muAllConns.Lock()
+	defer muAllConns.Unlock()
+	delete(allConns, ws)
+}
+
+// 所有连接的统计，按名称排序
+func AllWsStats() []WsConnStat {
+	muAllConns.Lock()
+	conns := make([]*WsConnection, 0, len(allConns))
+	for ws := range allConns {
+		conns = append(conns, ws)
+	}
+	muAllConns.Unlock()
+
+	sts := make([]WsConnStat, 0, len(conns))
+	for _, ws := range conns {
+		sts = append(sts, ws.Stats())
+	}
+	sort.Slice(sts, func(i, j int) bool {
+		if sts[i].Name != sts[j].Name {
+			return sts[i].Name < sts[j].Name
+		}
+		return sts[i].Url < sts[j].Url
+	})
+	return sts
+}
@@ -32,6 +32,7 @@ type WsSubscriber struct {
 	gen        SubscribeTextGen
 	succKeys   []string
 	status     int // Subscriber_status_xxx
+	succTime   time.Time
 	onRecv     chan WSRawMsg
 }
 
@@ -66,6 +67,15 @@ func (s *WsSubscriber) Successed() bool {
 	return s.status == Subscriber_status_successed
 }
 
+func (s *WsSubscriber) Name() string {
+	return s.name
+}
+
+// 最近一次订阅成功的时间
+func (s *WsSubscriber) SuccessTime() time.Time {
+	return s.succTime
+}
+
 // 订阅器的逻辑：
 // ws连接成功后，发送订阅字符串
 // 然后等待服务器的特定返回
@@ -89,6 +99,7 @@ func (s *WsSubscriber) run(ws *WsConnection) {
 				}
 				if allMatch {
 					s.status = Subscriber_status_successed
+					s.succTime = time.Now()
 					ws.RemoveRecvChans(s.onRecv)
 					logger.LogInfo(ws.logPrefix, "%s [%s] success", s.actionName, s.name)
 				}
@@ -0,0 +1,257 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 20:05:17
+ * @Description: K线聚合器。订阅逐笔成交，同时构建多个周期/多种类型的K线，收线时回调
+ * 启动时用历史K线回填时间K线；成交数据有遗漏时（断线重连等），先用rest接口补全再继续处理
+ * 补全回来的成交若属于已收线的时间K线，则修正该K线并通过修正回调通知
+ * 所有成交和定时事件都在同一个协程里串行处理，回调也在该协程中执行
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package adv
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+)
+
+type OnBar func(b Bar)
+
+type BarAggregatorConfig struct {
+	HistoryLen    int           // 每种K线保留的历史长度
+	BackfillBars  int           // 启动时回填的时间K线数量，0表示不回填
+	CloseDelay    time.Duration // 时间K线在周期结束后多久收线，用于等待迟到的成交
+	CheckInterval time.Duration // 时间K线的收线检查间隔
+}
+
+func DefaultBarAggregatorConfig() BarAggregatorConfig {
+	return BarAggregatorConfig{
+		HistoryLen:    1000,
+		BackfillBars:  200,
+		CloseDelay:    time.Second * 2,
+		CheckInterval: time.Millisecond * 200,
+	}
+}
+
+type barEvent struct {
+	trade common.MarketTrade
+	gap   bool
+}
+
+type BarAggregator struct {
+	logPrefix string
+	feed      common.TradeFeed
+	cfg       BarAggregatorConfig
+	builders  []barBuilder
+	mu        sync.Mutex
+
+	lastId   int64 // 已处理的最大成交id，用于去重和补全
+	chEvent  chan barEvent
+	chStop   chan bool
+	running  bool
+	fnClose  OnBar
+	fnRevise OnBar
+}
+
+func (a *BarAggregator) Init(feed common.TradeFeed, cfg BarAggregatorConfig, specs ...BarSpec) {
+	a.logPrefix = fmt.Sprintf("bar-aggregator-%s", feed.InstId())
+	a.feed = feed
+	a.cfg = cfg
+	a.chEvent = make(chan barEvent, 4096)
+	a.chStop = make(chan bool, 1)
+
+	for _, s := range specs {
+		if s.Kind == BarKind_Time {
+			if s.IntervalSec <= 0 {
+				logger.LogPanic(a.logPrefix, "invalid bar spec: %s", s.String())
+			}
+			a.builders = append(a.builders, newTimeBarBuilder(s, cfg.HistoryLen, cfg.CloseDelay))
+		} else {
+			if s.Threshold <= 0 {
+				logger.LogPanic(a.logPrefix, "invalid bar spec: %s", s.String())
+			}
+			a.builders = append(a.builders, newThresholdBarBuilder(s, cfg.HistoryLen))
+		}
+	}
+}
+
+// 收线回调
+func (a *BarAggregator) SetBarCloseFn(fn OnBar) {
+	a.fnClose = fn
+}
+
+// 已收线K线被迟到成交修正时的回调
+func (a *BarAggregator) SetBarReviseFn(fn OnBar) {
+	a.fnRevise = fn
+}
+
+func (a *BarAggregator) Start() {
+	if a.running {
+		return
+	}
+
+	a.running = true
+	a.backfill()
+	a.feed.Start(
+		func(t common.MarketTrade) { a.chEvent <- barEvent{trade: t} },
+		func() { a.chEvent <- barEvent{gap: true} })
+	go a.run()
+	logger.LogImportant(a.logPrefix, "started")
+}
+
+func (a *BarAggregator) Stop() {
+	if !a.running {
+		return
+	}
+
+	a.running = false
+	a.feed.Stop()
+	a.chStop <- true
+	logger.LogImportant(a.logPrefix, "stopped")
+}
+
+// 已完成的K线（拷贝）
+func (a *BarAggregator) Bars(spec BarSpec) []Bar {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if b := a.findBuilder(spec); b != nil {
+		return b.history()
+	}
+	return nil
+}
+
+// 正在进行中的K线
+func (a *BarAggregator) Current(spec BarSpec) (Bar, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if b := a.findBuilder(spec); b != nil {
+		return b.current()
+	}
+	return Bar{}, false
+}
+
+func (a *BarAggregator) findBuilder(spec BarSpec) barBuilder {
+	for _, b := range a.builders {
+		if b.spec() == spec {
+			return b
+		}
+	}
+	return nil
+}
+
+// 用历史K线回填时间K线
+func (a *BarAggregator) backfill() {
+	if a.cfg.BackfillBars <= 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, b := range a.builders {
+		if tb, ok := b.(*timeBarBuilder); ok {
+			t0 := now.Add(-time.Duration(tb.s.IntervalSec*a.cfg.BackfillBars) * time.Second)
+			kus, ok := a.feed.GetKline(t0, now, tb.s.IntervalSec)
+			if !ok {
+				logger.LogImportant(a.logPrefix, "backfill %s failed", tb.s.String())
+			}
+
+			a.mu.Lock()
+			tb.backfill(kus, now)
+			a.mu.Unlock()
+			logger.LogInfo(a.logPrefix, "backfilled %d bars for %s", len(tb.bars), tb.s.String())
+		}
+	}
+}
+
+func (a *BarAggregator) run() {
+	defer util.DefaultRecover()
+	ticker := time.NewTicker(util.ValueIf(a.cfg.CheckInterval > 0, a.cfg.CheckInterval, time.Millisecond*200))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e := <-a.chEvent:
+			if e.gap {
+				a.fillGap()
+			} else {
+				a.processTrades([]common.MarketTrade{e.trade})
+			}
+		case <-ticker.C:
+			// 断线期间不收线，等补全后再处理
+			if a.feed.Connected() {
+				a.processTime(time.Now())
+			}
+		case <-a.chStop:
+			return
+		}
+	}
+}
+
+// 补全lastId之后遗漏的成交
+func (a *BarAggregator) fillGap() {
+	if a.lastId == 0 {
+		return
+	}
+
+	trades, ok := a.feed.FetchTrades(a.lastId, time.Now())
+	logger.LogImportant(a.logPrefix, "filling gap after trade %d, %d trades fetched, complete=%v", a.lastId, len(trades), ok)
+	if !ok {
+		// 仍有遗漏，当前K线标记为不完整
+		a.mu.Lock()
+		for _, b := range a.builders {
+			b.markPartial()
+		}
+		a.mu.Unlock()
+	}
+
+	a.processTrades(trades)
+}
+
+func (a *BarAggregator) processTrades(trades []common.MarketTrade) {
+	var closed, revised []Bar
+	a.mu.Lock()
+	for _, t := range trades {
+		// 去重。补全的成交和实时推送可能有重叠
+		if t.Id <= a.lastId {
+			continue
+		}
+		a.lastId = t.Id
+
+		for _, b := range a.builders {
+			c, r := b.onTrade(t)
+			closed = append(closed, c...)
+			revised = append(revised, r...)
+		}
+	}
+	a.mu.Unlock()
+
+	a.notify(closed, revised)
+}
+
+func (a *BarAggregator) processTime(now time.Time) {
+	var closed []Bar
+	a.mu.Lock()
+	for _, b := range a.builders {
+		closed = append(closed, b.onTime(now)...)
+	}
+	a.mu.Unlock()
+
+	a.notify(closed, nil)
+}
+
+func (a *BarAggregator) notify(closed, revised []Bar) {
+	if a.fnClose != nil {
+		for _, b := range closed {
+			a.fnClose(b)
+		}
+	}
+
+	if a.fnRevise != nil {
+		for _, b := range revised {
+			a.fnRevise(b)
+		}
+	}
+}
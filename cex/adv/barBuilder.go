@@ -0,0 +1,364 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 19:48:30
+ * @Description: 由逐笔成交构建K线。支持时间K线、成交量K线、成交额K线、笔数K线
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package adv
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/shopspring/decimal"
+)
+
+type BarKind int
+
+const (
+	BarKind_Time   BarKind = iota // 固定时间周期
+	BarKind_Volume                // 固定成交量（基础币）
+	BarKind_Dollar                // 固定成交额（计价币）
+	BarKind_Tick                  // 固定成交笔数
+)
+
+func BarKind2Str(k BarKind) string {
+	switch k {
+	case BarKind_Time:
+		return "time"
+	case BarKind_Volume:
+		return "volume"
+	case BarKind_Dollar:
+		return "dollar"
+	case BarKind_Tick:
+		return "tick"
+	default:
+		return "unknown"
+	}
+}
+
+type BarSpec struct {
+	Kind        BarKind
+	IntervalSec int     // 时间K线的周期
+	Threshold   float64 // 其他K线的收线阈值
+}
+
+func TimeBarSpec(intervalSec int) BarSpec {
+	return BarSpec{Kind: BarKind_Time, IntervalSec: intervalSec}
+}
+
+func VolumeBarSpec(volume float64) BarSpec {
+	return BarSpec{Kind: BarKind_Volume, Threshold: volume}
+}
+
+func DollarBarSpec(value float64) BarSpec {
+	return BarSpec{Kind: BarKind_Dollar, Threshold: value}
+}
+
+func TickBarSpec(count int) BarSpec {
+	return BarSpec{Kind: BarKind_Tick, Threshold: float64(count)}
+}
+
+func (s BarSpec) String() string {
+	if s.Kind == BarKind_Time {
+		return fmt.Sprintf("time_%ds", s.IntervalSec)
+	} else {
+		return fmt.Sprintf("%s_%v", BarKind2Str(s.Kind), s.Threshold)
+	}
+}
+
+type Bar struct {
+	Spec      BarSpec
+	T0        time.Time // 开始时间。时间K线为周期起点，其他K线为第一笔成交时间
+	T1        time.Time // 结束时间。时间K线为周期终点，其他K线为最后一笔成交时间
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	Volume    float64 // 成交量（基础币）
+	BuyVolume float64 // 主动买入成交量
+	Value     float64 // 成交额（计价币）
+	Trades    int     // 成交笔数
+	FirstId   int64
+	LastId    int64
+	Partial   bool // 数据不完整。如启动时正在进行中的时间K线，启动之前的成交无法获得
+}
+
+func (b Bar) String() string {
+	return fmt.Sprintf(
+		"%s [%s] o=%v h=%v l=%v c=%v v=%v n=%d",
+		b.Spec.String(), b.T0.Format(time.DateTime), b.Open, b.High, b.Low, b.Close, b.Volume, b.Trades)
+}
+
+func (b Bar) KUnit() common.KUnit {
+	return common.KUnit{
+		Time:         b.T0,
+		OpenPrice:    decimal.NewFromFloat(b.Open),
+		ClosePrice:   decimal.NewFromFloat(b.Close),
+		HighestPrice: decimal.NewFromFloat(b.High),
+		LowestPrice:  decimal.NewFromFloat(b.Low),
+		VolumeUSD:    decimal.NewFromFloat(b.Value),
+	}
+}
+
+// 用一笔成交更新K线
+func (b *Bar) add(t common.MarketTrade) {
+	px := t.Price.InexactFloat64()
+	sz := t.Size.InexactFloat64()
+	if b.Trades == 0 {
+		b.Open, b.High, b.Low, b.Close = px, px, px, px
+		b.FirstId = t.Id
+		b.LastId = t.Id
+	} else {
+		if px > b.High {
+			b.High = px
+		}
+		if px < b.Low {
+			b.Low = px
+		}
+		if t.Id < b.FirstId {
+			b.FirstId = t.Id
+			b.Open = px
+		}
+		if t.Id > b.LastId {
+			b.LastId = t.Id
+			b.Close = px
+		}
+	}
+
+	b.Volume += sz
+	b.Value += t.Value.InexactFloat64()
+	if t.Dir == common.OrderDir_Buy {
+		b.BuyVolume += sz
+	}
+	b.Trades++
+}
+
+func barFromKUnit(spec BarSpec, ku common.KUnit) Bar {
+	return Bar{
+		Spec:   spec,
+		T0:     ku.Time,
+		T1:     ku.Time.Add(time.Duration(spec.IntervalSec) * time.Second),
+		Open:   ku.OpenPrice.InexactFloat64(),
+		High:   ku.HighestPrice.InexactFloat64(),
+		Low:    ku.LowestPrice.InexactFloat64(),
+		Close:  ku.ClosePrice.InexactFloat64(),
+		Value:  ku.VolumeUSD.InexactFloat64(),
+		Trades: -1, // 来自历史K线，没有成交笔数信息
+	}
+}
+
+// K线构建器
+type barBuilder interface {
+	spec() BarSpec
+	history() []Bar
+	current() (Bar, bool)
+	onTrade(t common.MarketTrade) (closed []Bar, revised []Bar)
+	onTime(now time.Time) (closed []Bar)
+	markPartial() // 成交数据有遗漏，当前K线不完整
+}
+
+// 保存已完成的K线，超长时舍弃一半
+type barHistory struct {
+	bars   []Bar
+	maxLen int
+}
+
+func (h *barHistory) append(b Bar) {
+	h.bars = append(h.bars, b)
+	if h.maxLen > 0 && len(h.bars) >= h.maxLen*2 {
+		h.bars = append(h.bars[:0], h.bars[len(h.bars)-h.maxLen:]...)
+	}
+}
+
+func (h *barHistory) history() []Bar {
+	bars := make([]Bar, len(h.bars))
+	copy(bars, h.bars)
+	return bars
+}
+
+// #region 时间K线
+// 周期内没有成交的K线，以上一根收盘价为开高低收，成交量为0
+type timeBarBuilder struct {
+	barHistory
+	s          BarSpec
+	intervalMs int64
+	cur        Bar
+	hasCur     bool
+	closeDelay time.Duration // 周期结束后等待迟到成交的时间
+}
+
+func newTimeBarBuilder(s BarSpec, maxLen int, closeDelay time.Duration) *timeBarBuilder {
+	b := new(timeBarBuilder)
+	b.s = s
+	b.maxLen = maxLen
+	b.intervalMs = int64(s.IntervalSec) * 1000
+	b.closeDelay = closeDelay
+	return b
+}
+
+func (b *timeBarBuilder) spec() BarSpec {
+	return b.s
+}
+
+func (b *timeBarBuilder) current() (Bar, bool) {
+	return b.cur, b.hasCur
+}
+
+func (b *timeBarBuilder) bucketStart(t time.Time) time.Time {
+	ms := t.UnixMilli()
+	return time.UnixMilli(ms - ms%b.intervalMs)
+}
+
+// 开始一根新K线。若指定了前收盘价，则用其填充开高低收
+func (b *timeBarBuilder) startBar(t0 time.Time, prevClose float64) {
+	b.cur = Bar{
+		Spec:  b.s,
+		T0:    t0,
+		T1:    t0.Add(time.Duration(b.intervalMs) * time.Millisecond),
+		Open:  prevClose,
+		High:  prevClose,
+		Low:   prevClose,
+		Close: prevClose,
+	}
+	b.hasCur = true
+}
+
+// 用历史K线回填，并以当前时间开始一根不完整的K线
+func (b *timeBarBuilder) backfill(kus []common.KUnit, now time.Time) {
+	t0 := b.bucketStart(now)
+	for _, ku := range kus {
+		if ku.Time.Before(t0) {
+			b.append(barFromKUnit(b.s, ku))
+		}
+	}
+
+	prevClose := 0.0
+	if len(b.bars) > 0 {
+		prevClose = b.bars[len(b.bars)-1].Close
+	}
+	b.startBar(t0, prevClose)
+	b.cur.Partial = true
+}
+
+// 收掉当前K线，并开始下一根
+func (b *timeBarBuilder) closeCurrent() Bar {
+	closed := b.cur
+	b.append(closed)
+	b.startBar(closed.T1, closed.Close)
+	return closed
+}
+
+func (b *timeBarBuilder) onTrade(t common.MarketTrade) (closed []Bar, revised []Bar) {
+	t0 := b.bucketStart(t.Time)
+	if !b.hasCur {
+		b.startBar(t0, 0)
+		b.cur.Partial = true
+	}
+
+	// 进入新周期，依次收掉之前的K线
+	for t0.After(b.cur.T0) {
+		closed = append(closed, b.closeCurrent())
+	}
+
+	if t0.Equal(b.cur.T0) {
+		b.cur.add(t)
+	} else {
+		// 迟到的成交，所属K线已收线，修正之
+		for i := len(b.bars) - 1; i >= 0; i-- {
+			if b.bars[i].T0.Equal(t0) {
+				b.bars[i].add(t)
+				revised = append(revised, b.bars[i])
+				break
+			} else if b.bars[i].T0.Before(t0) {
+				break
+			}
+		}
+	}
+
+	return
+}
+
+func (b *timeBarBuilder) markPartial() {
+	if b.hasCur {
+		b.cur.Partial = true
+	}
+}
+
+func (b *timeBarBuilder) onTime(now time.Time) (closed []Bar) {
+	for b.hasCur && !now.Before(b.cur.T1.Add(b.closeDelay)) {
+		closed = append(closed, b.closeCurrent())
+	}
+	return
+}
+
+// #endregion
+
+// #region 阈值K线（成交量、成交额、笔数）
+// 累计量达到阈值的那笔成交计入当前K线，不做拆分
+type thresholdBarBuilder struct {
+	barHistory
+	s       BarSpec
+	cur     Bar
+	hasCur  bool
+	partial bool // 下一根K线不完整
+}
+
+func newThresholdBarBuilder(s BarSpec, maxLen int) *thresholdBarBuilder {
+	b := new(thresholdBarBuilder)
+	b.s = s
+	b.maxLen = maxLen
+	return b
+}
+
+func (b *thresholdBarBuilder) spec() BarSpec {
+	return b.s
+}
+
+func (b *thresholdBarBuilder) current() (Bar, bool) {
+	return b.cur, b.hasCur
+}
+
+func (b *thresholdBarBuilder) measure() float64 {
+	switch b.s.Kind {
+	case BarKind_Volume:
+		return b.cur.Volume
+	case BarKind_Dollar:
+		return b.cur.Value
+	default:
+		return float64(b.cur.Trades)
+	}
+}
+
+func (b *thresholdBarBuilder) onTrade(t common.MarketTrade) (closed []Bar, revised []Bar) {
+	if !b.hasCur {
+		b.cur = Bar{Spec: b.s, T0: t.Time, Partial: b.partial}
+		b.hasCur = true
+		b.partial = false
+	}
+
+	b.cur.add(t)
+	b.cur.T1 = t.Time
+	if b.measure() >= b.s.Threshold {
+		closed = append(closed, b.cur)
+		b.append(b.cur)
+		b.hasCur = false
+	}
+	return
+}
+
+func (b *thresholdBarBuilder) markPartial() {
+	if b.hasCur {
+		b.cur.Partial = true
+	} else {
+		b.partial = true
+	}
+}
+
+func (b *thresholdBarBuilder) onTime(now time.Time) []Bar {
+	return nil
+}
+
+// #endregion
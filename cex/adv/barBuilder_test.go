@@ -0,0 +1,367 @@
+package adv
+
+// 直接驱动K线构建器和聚合器的内部处理函数，不启动协程，不依赖真实行情
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+func TestMain(m *testing.M) {
+	logger.Setup(logger.NewStdoutSink(logger.ConsoleEncoder{}, logger.LogLevel_Important))
+	os.Exit(m.Run())
+}
+
+var barT0 = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+func sec(s float64) time.Time {
+	return barT0.Add(time.Duration(s * float64(time.Second)))
+}
+
+func trade(id int64, t time.Time, px, sz float64) common.MarketTrade {
+	return common.MarketTrade{
+		Id:    id,
+		Time:  t,
+		Price: decimal.NewFromFloat(px),
+		Size:  decimal.NewFromFloat(sz),
+		Value: decimal.NewFromFloat(px * sz),
+		Dir:   common.OrderDir_Buy,
+	}
+}
+
+type barWant struct {
+	t0     time.Time
+	o      float64
+	h      float64
+	l      float64
+	c      float64
+	v      float64
+	trades int
+}
+
+func checkBar(t *testing.T, b Bar, w barWant) {
+	t.Helper()
+	if !b.T0.Equal(w.t0) || b.Open != w.o || b.High != w.h || b.Low != w.l || b.Close != w.c || b.Volume != w.v || b.Trades != w.trades {
+		t.Errorf("bar = %s, t0=%s, want t0=%s o=%v h=%v l=%v c=%v v=%v n=%d",
+			b.String(), b.T0.Format(time.TimeOnly), w.t0.Format(time.TimeOnly), w.o, w.h, w.l, w.c, w.v, w.trades)
+	}
+}
+
+func TestTimeBarBuilder(t *testing.T) {
+	cases := []struct {
+		name    string
+		trades  []common.MarketTrade
+		now     time.Time // 最后调用一次onTime
+		closed  []barWant
+		revised []barWant
+	}{
+		{
+			name:   "same bucket",
+			trades: []common.MarketTrade{trade(1, sec(0), 10, 1), trade(2, sec(30), 12, 1), trade(3, sec(59.999), 9, 1)},
+			now:    sec(59.999),
+		},
+		{
+			name:   "trade on boundary opens next bar",
+			trades: []common.MarketTrade{trade(1, sec(10), 10, 1), trade(2, sec(60), 11, 2)},
+			now:    sec(60),
+			closed: []barWant{{sec(0), 10, 10, 10, 10, 1, 1}},
+		},
+		{
+			name:   "empty buckets filled with previous close",
+			trades: []common.MarketTrade{trade(1, sec(10), 10, 1), trade(2, sec(20), 11, 1), trade(3, sec(190), 12, 1)},
+			now:    sec(190),
+			closed: []barWant{
+				{sec(0), 10, 11, 10, 11, 2, 2},
+				{sec(60), 11, 11, 11, 11, 0, 0},
+				{sec(120), 11, 11, 11, 11, 0, 0},
+			},
+		},
+		{
+			name:   "close waits for delay",
+			trades: []common.MarketTrade{trade(1, sec(10), 10, 1)},
+			now:    sec(61.999),
+		},
+		{
+			name:   "close after delay",
+			trades: []common.MarketTrade{trade(1, sec(10), 10, 1)},
+			now:    sec(62),
+			closed: []barWant{{sec(0), 10, 10, 10, 10, 1, 1}},
+		},
+		{
+			name:    "late trade revises closed bar",
+			trades:  []common.MarketTrade{trade(1, sec(10), 10, 1), trade(3, sec(70), 11, 1), trade(2, sec(50), 8, 1)},
+			now:     sec(70),
+			closed:  []barWant{{sec(0), 10, 10, 10, 10, 1, 1}},
+			revised: []barWant{{sec(0), 10, 10, 8, 8, 2, 2}},
+		},
+		{
+			name:   "out of order trades keep open and close by id",
+			trades: []common.MarketTrade{trade(5, sec(10), 10, 1), trade(4, sec(5), 9, 1), trade(6, sec(20), 11, 1)},
+			now:    sec(65),
+			closed: []barWant{{sec(0), 9, 11, 9, 11, 3, 3}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := newTimeBarBuilder(TimeBarSpec(60), 100, time.Second*2)
+			var closed, revised []Bar
+			for _, tr := range c.trades {
+				cl, rv := b.onTrade(tr)
+				closed = append(closed, cl...)
+				revised = append(revised, rv...)
+			}
+			closed = append(closed, b.onTime(c.now)...)
+
+			if len(closed) != len(c.closed) {
+				t.Fatalf("closed %d bars, want %d", len(closed), len(c.closed))
+			}
+			for i, w := range c.closed {
+				checkBar(t, closed[i], w)
+			}
+
+			if len(revised) != len(c.revised) {
+				t.Fatalf("revised %d bars, want %d", len(revised), len(c.revised))
+			}
+			for i, w := range c.revised {
+				checkBar(t, revised[i], w)
+			}
+		})
+	}
+}
+
+func TestTimeBarBuilderBackfill(t *testing.T) {
+	kus := []common.KUnit{
+		{Time: sec(0), OpenPrice: decimal.NewFromInt(10), HighestPrice: decimal.NewFromInt(12), LowestPrice: decimal.NewFromInt(9), ClosePrice: decimal.NewFromInt(11)},
+		{Time: sec(60), OpenPrice: decimal.NewFromInt(11), HighestPrice: decimal.NewFromInt(13), LowestPrice: decimal.NewFromInt(10), ClosePrice: decimal.NewFromInt(12)},
+		{Time: sec(120), OpenPrice: decimal.NewFromInt(12), HighestPrice: decimal.NewFromInt(12), LowestPrice: decimal.NewFromInt(12), ClosePrice: decimal.NewFromInt(12)},
+	}
+
+	b := newTimeBarBuilder(TimeBarSpec(60), 100, 0)
+	b.backfill(kus, sec(150))
+
+	// 当前周期的历史K线不完整，不计入历史，由实时成交继续构建
+	if len(b.bars) != 2 {
+		t.Fatalf("backfilled %d bars, want 2", len(b.bars))
+	}
+	cur, ok := b.current()
+	if !ok || !cur.Partial || !cur.T0.Equal(sec(120)) || cur.Open != 12 {
+		t.Fatalf("current = %s, partial=%v", cur.String(), cur.Partial)
+	}
+
+	closed, _ := b.onTrade(trade(1, sec(170), 13, 1))
+	if len(closed) != 0 {
+		t.Fatalf("closed %d bars, want 0", len(closed))
+	}
+	closed = b.onTime(sec(180))
+	if len(closed) != 1 || !closed[0].Partial {
+		t.Fatalf("closed = %v", closed)
+	}
+	// 有成交后开高低收以成交为准，不再沿用前收盘价
+	checkBar(t, closed[0], barWant{sec(120), 13, 13, 13, 13, 1, 1})
+}
+
+func TestThresholdBarBuilder(t *testing.T) {
+	cases := []struct {
+		name   string
+		spec   BarSpec
+		trades []common.MarketTrade
+		closed []barWant
+		cur    int // 当前K线的成交笔数
+	}{
+		{
+			name:   "volume",
+			spec:   VolumeBarSpec(3),
+			trades: []common.MarketTrade{trade(1, sec(1), 10, 1), trade(2, sec(2), 11, 1), trade(3, sec(3), 12, 1), trade(4, sec(4), 13, 1)},
+			closed: []barWant{{sec(1), 10, 12, 10, 12, 3, 3}},
+			cur:    1,
+		},
+		{
+			name:   "volume crossing trade is not split",
+			spec:   VolumeBarSpec(3),
+			trades: []common.MarketTrade{trade(1, sec(1), 10, 2), trade(2, sec(2), 11, 5)},
+			closed: []barWant{{sec(1), 10, 11, 10, 11, 7, 2}},
+		},
+		{
+			name:   "dollar",
+			spec:   DollarBarSpec(100),
+			trades: []common.MarketTrade{trade(1, sec(1), 10, 5), trade(2, sec(2), 10, 5), trade(3, sec(3), 20, 1)},
+			closed: []barWant{{sec(1), 10, 10, 10, 10, 10, 2}},
+			cur:    1,
+		},
+		{
+			name:   "tick",
+			spec:   TickBarSpec(2),
+			trades: []common.MarketTrade{trade(1, sec(1), 10, 1), trade(2, sec(2), 9, 1), trade(3, sec(3), 8, 1), trade(4, sec(4), 7, 1)},
+			closed: []barWant{{sec(1), 10, 10, 9, 9, 2, 2}, {sec(3), 8, 8, 7, 7, 2, 2}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := newThresholdBarBuilder(c.spec, 100)
+			var closed []Bar
+			for _, tr := range c.trades {
+				cl, _ := b.onTrade(tr)
+				closed = append(closed, cl...)
+			}
+
+			if len(closed) != len(c.closed) {
+				t.Fatalf("closed %d bars, want %d", len(closed), len(c.closed))
+			}
+			for i, w := range c.closed {
+				checkBar(t, closed[i], w)
+			}
+
+			cur, ok := b.current()
+			if c.cur == 0 && ok {
+				t.Fatalf("unexpected current bar %s", cur.String())
+			} else if c.cur > 0 && (!ok || cur.Trades != c.cur) {
+				t.Fatalf("current = %s, want %d trades", cur.String(), c.cur)
+			}
+		})
+	}
+}
+
+func TestThresholdBarBuilderPartial(t *testing.T) {
+	b := newThresholdBarBuilder(TickBarSpec(1), 100)
+	b.markPartial()
+	closed, _ := b.onTrade(trade(1, sec(1), 10, 1))
+	closed2, _ := b.onTrade(trade(2, sec(2), 10, 1))
+	if len(closed) != 1 || !closed[0].Partial {
+		t.Fatalf("first bar after gap should be partial: %v", closed)
+	}
+	if len(closed2) != 1 || closed2[0].Partial {
+		t.Fatalf("second bar should be complete: %v", closed2)
+	}
+}
+
+// 补全接口返回固定的成交
+type fakeTradeFeed struct {
+	fetched  []common.MarketTrade
+	complete bool
+	afterId  int64
+}
+
+func (f *fakeTradeFeed) InstId() string                                       { return "TEST" }
+func (f *fakeTradeFeed) Start(fnTrade func(common.MarketTrade), fnGap func()) {}
+func (f *fakeTradeFeed) Stop()                                                {}
+func (f *fakeTradeFeed) Connected() bool                                      { return true }
+func (f *fakeTradeFeed) GetKline(t0, t1 time.Time, intervalSec int) ([]common.KUnit, bool) {
+	return nil, true
+}
+
+func (f *fakeTradeFeed) FetchTrades(afterId int64, t1 time.Time) ([]common.MarketTrade, bool) {
+	f.afterId = afterId
+	return f.fetched, f.complete
+}
+
+func TestBarAggregatorGapFill(t *testing.T) {
+	// 每个用例的实时成交都跨过第一个周期，使断线时的当前K线是完整的
+	cases := []struct {
+		name     string
+		live     []common.MarketTrade // 断线前收到的实时成交
+		now      time.Time            // 断线前最后一次收线检查，零值表示不检查
+		fetched  []common.MarketTrade // 补全拿到的成交
+		complete bool
+		after    []common.MarketTrade // 补全后收到的实时成交（与补全有重叠）
+		closed   []barWant
+		revised  []barWant
+		partial  bool // 当前K线是否标记为不完整
+	}{
+		{
+			name:     "fill into current bar",
+			live:     []common.MarketTrade{trade(1, sec(10), 10, 1), trade(2, sec(65), 12, 1)},
+			fetched:  []common.MarketTrade{trade(3, sec(70), 13, 1), trade(4, sec(80), 14, 1)},
+			complete: true,
+			after:    []common.MarketTrade{trade(4, sec(80), 14, 1), trade(5, sec(90), 15, 1)},
+			closed:   []barWant{{sec(0), 10, 10, 10, 10, 1, 1}},
+		},
+		{
+			name:     "fill closes bars across boundary",
+			live:     []common.MarketTrade{trade(1, sec(10), 10, 1), trade(2, sec(65), 12, 1)},
+			fetched:  []common.MarketTrade{trade(3, sec(70), 13, 1), trade(4, sec(125), 14, 1)},
+			complete: true,
+			after:    []common.MarketTrade{trade(4, sec(125), 14, 1), trade(5, sec(126), 15, 1)},
+			closed:   []barWant{{sec(0), 10, 10, 10, 10, 1, 1}, {sec(60), 12, 13, 12, 13, 2, 2}},
+		},
+		{
+			name:     "late trade from fill revises closed bar",
+			live:     []common.MarketTrade{trade(1, sec(10), 10, 1), trade(2, sec(65), 12, 1)},
+			now:      sec(122),
+			fetched:  []common.MarketTrade{trade(3, sec(119), 11, 1)},
+			complete: true,
+			after:    []common.MarketTrade{trade(3, sec(119), 11, 1), trade(4, sec(130), 13, 1)},
+			closed:   []barWant{{sec(0), 10, 10, 10, 10, 1, 1}, {sec(60), 12, 12, 12, 12, 1, 1}},
+			revised:  []barWant{{sec(60), 12, 12, 11, 11, 2, 2}},
+		},
+		{
+			name:     "incomplete fill marks partial",
+			live:     []common.MarketTrade{trade(1, sec(10), 10, 1), trade(2, sec(65), 12, 1)},
+			fetched:  []common.MarketTrade{trade(3, sec(70), 13, 1)},
+			complete: false,
+			closed:   []barWant{{sec(0), 10, 10, 10, 10, 1, 1}},
+			partial:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			feed := &fakeTradeFeed{fetched: c.fetched, complete: c.complete}
+			spec := TimeBarSpec(60)
+			a := new(BarAggregator)
+			a.Init(feed, BarAggregatorConfig{HistoryLen: 100}, spec)
+
+			var closed, revised []Bar
+			a.SetBarCloseFn(func(b Bar) { closed = append(closed, b) })
+			a.SetBarReviseFn(func(b Bar) { revised = append(revised, b) })
+
+			a.processTrades(c.live)
+			if !c.now.IsZero() {
+				a.processTime(c.now)
+			}
+			a.fillGap()
+			if feed.afterId != c.live[len(c.live)-1].Id {
+				t.Fatalf("fetch after %d, want %d", feed.afterId, c.live[len(c.live)-1].Id)
+			}
+			a.processTrades(c.after)
+
+			if len(closed) != len(c.closed) {
+				t.Fatalf("closed %d bars, want %d", len(closed), len(c.closed))
+			}
+			for i, w := range c.closed {
+				checkBar(t, closed[i], w)
+			}
+			if len(revised) != len(c.revised) {
+				t.Fatalf("revised %d bars, want %d", len(revised), len(c.revised))
+			}
+			for i, w := range c.revised {
+				checkBar(t, revised[i], w)
+			}
+
+			// 补全和实时推送重叠的成交只计一次
+			uniq := map[int64]bool{}
+			for _, tr := range append(append(append([]common.MarketTrade{}, c.live...), c.fetched...), c.after...) {
+				uniq[tr.Id] = true
+			}
+			total := 0
+			for _, b := range a.Bars(spec) {
+				total += b.Trades
+			}
+			cur, _ := a.Current(spec)
+			total += cur.Trades
+			if total != len(uniq) {
+				t.Fatalf("%d trades in bars, want %d", total, len(uniq))
+			}
+
+			if cur.Partial != c.partial {
+				t.Fatalf("current partial = %v, want %v", cur.Partial, c.partial)
+			}
+		})
+	}
+}
@@ -134,6 +134,7 @@ func (g *Grid) Init(
 	g.pm = new(PositionManager)
 	g.pm.Init(g.trader, g.onOrderDeal, g.logPrefix, true, true)
 	g.pm.SetTaker(false, false)
+	g.pm.SetBatchMode(true)
 
 	g.p2pOpenBuy = NewPriceMap2Position(trader.Market())
 	g.p2pCloseBuy = NewPriceMap2Position(trader.Market())
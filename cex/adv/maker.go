@@ -52,6 +52,10 @@ type Maker struct {
 	autoReborn        bool    // 订单结束后，是否自动创建新订单
 	running           bool
 	purpose           string
+	batchMode         bool      // 批量模式。Modify只设置目标，由UpdateMakersBatch统一提交
+	batchOpTime       time.Time // 批量模式下最近一次改单/撤单的时间
+	failCount         int       // 连续出错的订单数
+	retryAt           time.Time // 订单出错后，按错误分类退避到这个时间再重新下单
 
 	chStop chan bool
 	fnDeal OnMakerOrderDeal // 成交回调
@@ -87,7 +91,9 @@ func (d *Maker) Modify(price, size decimal.Decimal, dir common.OrderDir, reduceO
 	d.size = size
 	d.dir = dir
 	d.reduceOnly = reduceOnly
-	d.updateOrder(true)
+	if !d.batchMode {
+		d.updateOrder(true)
+	}
 }
 
 func (d *Maker) ModifyWithoutOrderModify(price, size decimal.Decimal, dir common.OrderDir, reduceOnly bool) {
@@ -96,6 +102,11 @@ func (d *Maker) ModifyWithoutOrderModify(price, size decimal.Decimal, dir common
 	d.dir = dir
 	d.reduceOnly = reduceOnly
 
+	// 批量模式下只设置目标。此时是否改单由enableModify决定，不希望改单的Maker需要关闭它
+	if d.batchMode {
+		return
+	}
+
 	temp := d.enableModify
 	d.enableModify = false
 	d.updateOrder(true)
@@ -106,6 +117,11 @@ func (d *Maker) Cancel() {
 	d.Modify(decimal.Zero, decimal.Zero, common.OrderDir_None, false)
 }
 
+// 批量模式下的Maker不需要调用Go，其订单由UpdateMakersBatch统一更新
+func (d *Maker) SetBatchMode(batch bool) {
+	d.batchMode = batch
+}
+
 func (d *Maker) Go() {
 	d.running = true
 	go d.update()
@@ -148,6 +164,15 @@ func (d *Maker) OnDeal(deal common.Deal) {
 	}
 }
 
+type makerOp int
+
+const (
+	makerOp_None makerOp = iota
+	makerOp_Create
+	makerOp_Modify
+	makerOp_Cancel
+)
+
 // 暂停、量价为0等情况，关闭订单
 // 否则执行订单修改逻辑
 func (d *Maker) updateOrder(reborn bool) {
@@ -155,55 +180,74 @@ func (d *Maker) updateOrder(reborn bool) {
 	defer d.mu.Unlock()
 	defer d.autoUpdateTicker.Reset(time.Millisecond * 10)
 
+	d.clearFinished()
+	op, px, sz := d.nextOp(reborn)
+	switch op {
+	case makerOp_Create:
+		d.O = d.trader.MakeOrder(px, sz, d.dir, d.makeOnly, d.reduceOnly, d.purpose, d)
+		if d.O == nil {
+			time.Sleep(time.Second) // 订单创建未通过本地验证
+		}
+	case makerOp_Modify:
+		d.O.Modify(px, sz)
+	case makerOp_Cancel:
+		d.O.Cancel()
+	}
+}
+
+// 清理已结束的订单。订单出错时，根据错误分类决定多久之后再重新下单
+func (d *Maker) clearFinished() {
 	if d.O != nil && d.O.IsFinished() {
 		if d.O.HasFatalError() {
-			time.Sleep(time.Second)
+			d.failCount++
+			delay := common.RetryPolicyOf(d.O.LastError()).Delay(d.failCount)
+			d.retryAt = time.Now().Add(delay)
+			logger.LogInfo(d.logPrefix, "order failed(%v), retry after %v", d.O.LastError(), delay)
+		} else {
+			d.failCount = 0
 		}
-
 		d.O = nil
 	}
+}
 
-	px := d.trader.Market().AlignPrice(d.price, d.dir, d.makeOnly)
-	sz := d.trader.Market().AlignSize(d.size) // 注意！这里的size仅指未成交数量，不包括已成交数量
+// 根据目标量价决定订单的下一步操作
+// 创建时px、sz为订单量价；修改时px、sz为新的量价，不需要修改的部分为0
+func (d *Maker) nextOp(reborn bool) (op makerOp, px, sz decimal.Decimal) {
+	px = d.trader.Market().AlignPrice(d.price, d.dir, d.makeOnly)
+	sz = d.trader.Market().AlignSize(d.size) // 注意！这里的size仅指未成交数量，不包括已成交数量
 	if !px.IsPositive() || !sz.IsPositive() || d.dir == common.OrderDir_None || !common.PriceInRange(px, d.dir, d.trader) {
 		if d.O != nil {
-			d.O.Cancel()
+			return makerOp_Cancel, decimal.Zero, decimal.Zero
 		}
 	} else if reborn {
 		if d.O == nil {
 			// 创建订单
-			d.O = d.trader.MakeOrder(px, sz, d.dir, d.makeOnly, d.reduceOnly, d.purpose, d)
-			if d.O == nil {
-				time.Sleep(time.Second) // 订单创建未通过本地验证
+			if time.Now().Before(d.retryAt) {
+				return makerOp_None, decimal.Zero, decimal.Zero
 			}
+			return makerOp_Create, px, sz
 		} else {
 			priceDv := util.DecimalDeviationAbs(d.O.GetPrice(), px).InexactFloat64()
 			sizeDv := util.DecimalDeviationAbs(d.O.GetUnfilled(), sz).InexactFloat64()
 			if d.dir != d.O.GetDir() {
-				d.O.Cancel()
+				return makerOp_Cancel, decimal.Zero, decimal.Zero
 			} else if priceDv > d.maxPriceDeviation || sizeDv > d.maxSizeDeviation {
-				/*logger.LogInfo(
-				d.logPrefix,
-				"do_price:%v, price:%v, do_size:%v, size:%v, need cancel/modify",
-				px,
-				d.O.GetPrice(),
-				sz,
-				d.O.GetUnfilled())*/
-
 				if d.enableModify && d.O.IsSupportModify() {
 					priceNeedModify := priceDv > d.maxPriceDeviation
 					sizeNeedModify := sizeDv > d.maxSizeDeviation
 					if priceNeedModify && !sizeNeedModify {
-						d.O.Modify(px, decimal.Zero)
+						return makerOp_Modify, px, decimal.Zero
 					} else if !priceNeedModify && sizeNeedModify {
-						d.O.Modify(decimal.Zero, sz.Add(d.O.GetFilled()))
+						return makerOp_Modify, decimal.Zero, sz.Add(d.O.GetFilled())
 					} else if priceNeedModify && sizeNeedModify {
-						d.O.Modify(px, sz.Add(d.O.GetFilled()))
+						return makerOp_Modify, px, sz.Add(d.O.GetFilled())
 					}
 				} else {
-					d.O.Cancel()
+					return makerOp_Cancel, decimal.Zero, decimal.Zero
 				}
 			}
 		}
 	}
+
+	return makerOp_None, decimal.Zero, decimal.Zero
 }
@@ -0,0 +1,111 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 21:34:50
+ * @Description: 批量更新一组Maker。用于同时管理大量订单的场景（如网格）
+ * 各Maker只设置目标量价，由这里统一计算需要的下单/改单/撤单操作，合并为批量请求提交
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package adv
+
+import (
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util/logger"
+)
+
+const makerBatchLogPrefix = "maker-batch"
+
+// 同一个Maker两次操作之间的最小间隔，避免在订单状态刷新前重复提交
+const makerBatchOpInterval = time.Millisecond * 500
+
+// makers需为批量模式（SetBatchMode）且不调用Go
+func UpdateMakersBatch(trader common.CommonTrader, makers []*Maker) {
+	creates := make([]common.OrderRequest, 0)
+	createMakers := make([]*Maker, 0)
+	modifies := make([]common.OrderModifyRequest, 0)
+	cancels := make([]common.Order, 0)
+
+	now := time.Now()
+	for _, d := range makers {
+		d.mu.Lock()
+		d.clearFinished()
+		op, px, sz := d.nextOp(true)
+		if op != makerOp_None {
+			if now.Sub(d.batchOpTime) < makerBatchOpInterval {
+				op = makerOp_None
+			} else {
+				d.batchOpTime = now
+			}
+		}
+
+		switch op {
+		case makerOp_Create:
+			creates = append(creates, common.OrderRequest{
+				Price:      px,
+				Amount:     sz,
+				Dir:        d.dir,
+				MakeOnly:   d.makeOnly,
+				ReduceOnly: d.reduceOnly,
+				Purpose:    d.purpose,
+				Observer:   d,
+			})
+			createMakers = append(createMakers, d)
+		case makerOp_Modify:
+			modifies = append(modifies, common.OrderModifyRequest{O: d.O, NewPrice: px, NewSize: sz})
+		case makerOp_Cancel:
+			cancels = append(cancels, d.O)
+		}
+		d.mu.Unlock()
+	}
+
+	// 先撤单再下单，释放冻结的资金
+	if len(cancels) > 0 {
+		for _, r := range trader.CancelOrders(cancels) {
+			if r.Err != nil && r.Err != common.ErrOrderFinished {
+				logger.LogInfo(makerBatchLogPrefix, "batch cancel failed: %s, order=%s", r.Err.Error(), r.O.String())
+			}
+		}
+	}
+
+	if len(modifies) > 0 {
+		for _, r := range trader.ModifyOrders(modifies) {
+			if r.Err != nil && r.Err != common.ErrOrderFinished {
+				logger.LogInfo(makerBatchLogPrefix, "batch modify failed: %s, order=%s", r.Err.Error(), r.O.String())
+			}
+		}
+	}
+
+	if len(creates) > 0 {
+		results := trader.MakeOrders(creates)
+		for i, r := range results {
+			d := createMakers[i]
+			d.mu.Lock()
+			d.O = r.O
+			d.mu.Unlock()
+
+			if r.Err != nil {
+				logger.LogInfo(d.logPrefix, "batch create failed: %s", r.Err.Error())
+			}
+		}
+	}
+}
+
+// 批量撤掉一组Maker的订单，并清空其目标。不受操作间隔限制，用于撤退、停止等场景
+func CancelMakersBatch(trader common.CommonTrader, makers []*Maker) {
+	cancels := make([]common.Order, 0)
+	for _, d := range makers {
+		d.Cancel()
+		d.mu.Lock()
+		d.clearFinished()
+		if d.O != nil {
+			cancels = append(cancels, d.O)
+			d.batchOpTime = time.Now()
+		}
+		d.mu.Unlock()
+	}
+
+	if len(cancels) > 0 {
+		trader.CancelOrders(cancels)
+	}
+}
@@ -0,0 +1,435 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 15:20:44
+ * @Description: 双边做市引擎（Avellaneda–Stoikov模型）
+ * 保留价 r = mid * (1 - q * gamma * sigma^2 * T)，q为以档位数量计的库存偏离
+ * 半价差 = max(最小价差, (gamma * sigma^2 * T + 2/gamma * ln(1 + gamma/kappa)) / 2) * 逆向选择放大系数
+ * 以上均以价格比例计算。sigma为秒级收益率的标准差，由DataLine.Std估算
+ * 成交后一段时间计算markout，markout均值为负（被逆向选择）时放大价差
+ * 深度长时间未更新时撤掉全部挂单
+ * 挂单使用批量模式的Maker，锁内只计算目标报价，提交在锁外统一进行
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package adv
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/framework"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+// 参数
+type MarketMakerConfig struct {
+	LevelCount      int     `json:"levels"`        // 每边挂单档数
+	LevelSize       float64 `json:"level_sz"`      // 每档数量（币数或合约张数）
+	LevelGap        float64 `json:"level_gap"`     // 相邻两档的价格间距（比例）
+	MinSpread       float64 `json:"min_spread"`    // 最小半价差（比例）
+	Gamma           float64 `json:"gamma"`         // 风险厌恶系数
+	Kappa           float64 `json:"kappa"`         // 订单到达强度
+	HorizonSec      float64 `json:"horizon_sec"`   // 持仓周期（秒），即模型中的T-t
+	VolWindow       int     `json:"vol_window"`    // 波动率估算窗口（秒）
+	TargetInventory float64 `json:"target_inv"`    // 目标库存。库存偏离目标时调整保留价
+	MinInventory    float64 `json:"min_inv"`       // 最小库存。低于此值不再挂卖单
+	MaxInventory    float64 `json:"max_inv"`       // 最大库存。高于此值不再挂买单
+	MarkoutSec      int     `json:"markout_sec"`   // 成交后多少秒计算markout
+	MarkoutCount    int     `json:"markout_count"` // 参与统计的最近markout数量
+	AdverseWiden    float64 `json:"adverse_widen"` // 逆向选择放大系数。widen = 1 + AdverseWiden * (-markout均值) / MinSpread
+	MaxWiden        float64 `json:"max_widen"`     // 价差最大放大倍数
+	StaleBookMs     int64   `json:"stale_ms"`      // 深度超过这个时间未更新，视为过期，撤掉所有挂单
+	RequoteRatio    float64 `json:"requote"`       // 挂单价格偏离目标价格超过此比例时重新挂单
+}
+
+func (c *MarketMakerConfig) String() string {
+	return fmt.Sprintf(
+		"levels:%d, sz:%v, gap:%.4f%%, min_spread:%.4f%%, gamma:%v, kappa:%v, T:%vs, inv:[%v, %v]",
+		c.LevelCount,
+		c.LevelSize,
+		c.LevelGap*100,
+		c.MinSpread*100,
+		c.Gamma,
+		c.Kappa,
+		c.HorizonSec,
+		c.MinInventory,
+		c.MaxInventory)
+}
+
+// 单档挂单状态
+type MarketMakerQuote struct {
+	Dir   string  `json:"dir"`
+	Level int     `json:"level"`
+	Price float64 `json:"px"`
+	Size  float64 `json:"sz"`
+	Live  bool    `json:"live"`
+}
+
+// 状态。可直接作为策略Status()的返回值，从而写入stratergy.Detail
+type MarketMakerStatus struct {
+	InstId           string             `json:"inst_id"`
+	Running          bool               `json:"running"`
+	Paused           string             `json:"paused"` // 暂停报价的原因，空表示正常报价
+	MidPrice         float64            `json:"mid_px"`
+	ReservationPrice float64            `json:"rsv_px"`
+	HalfSpread       float64            `json:"half_spread"`
+	Volatility       float64            `json:"vol"`
+	Widen            float64            `json:"widen"`
+	Inventory        float64            `json:"inv"`
+	MarkoutAvg       float64            `json:"markout_avg"`
+	MarkoutCount     int                `json:"markout_count"`
+	BookAgeMs        int64              `json:"book_age_ms"`
+	BuyVolume        float64            `json:"buy_vol"`
+	SellVolume       float64            `json:"sell_vol"`
+	Quotes           []MarketMakerQuote `json:"quotes"`
+	Config           MarketMakerConfig  `json:"config"`
+}
+
+// 一次等待计算markout的成交
+type pendingMarkout struct {
+	t   time.Time
+	dir common.OrderDir
+	px  float64
+}
+
+type MarketMaker struct {
+	logPrefix string
+	mu        sync.Mutex
+	cfg       MarketMakerConfig
+	trader    common.CommonTrader
+	onDeal    OnMakerOrderDeal
+
+	bids []*Maker
+	asks []*Maker
+
+	dlReturn  *framework.DataLine // 秒级收益率
+	lastMidMs int64
+	lastMid   float64
+
+	pendingMarkouts []pendingMarkout
+	markouts        []float64 // 最近的markout（比例，正数表示对我方有利）
+
+	running     bool
+	pauseReason string
+	status      MarketMakerStatus
+	buyVolume   float64
+	sellVolume  float64
+}
+
+func (m *MarketMaker) Init(trader common.CommonTrader, cfg MarketMakerConfig, onDeal OnMakerOrderDeal) {
+	m.logPrefix = fmt.Sprintf("mm-%s", trader.Market().Type())
+	m.trader = trader
+	m.cfg = cfg
+	m.onDeal = onDeal
+	m.dlReturn = new(framework.DataLine)
+	m.dlReturn.Init("ret", util.MaxInt(cfg.VolWindow, 2)*2, 1000, 0)
+
+	for i := 0; i < cfg.LevelCount; i++ {
+		bid := new(Maker)
+		bid.Init(trader, true, true, true, cfg.RequoteRatio, 0.5, fmt.Sprintf("mm_bid%d", i))
+		bid.Usderdata = i
+		bid.SetDealFn(m.onMakerDeal)
+		bid.SetBatchMode(true)
+		m.bids = append(m.bids, bid)
+
+		ask := new(Maker)
+		ask.Init(trader, true, true, true, cfg.RequoteRatio, 0.5, fmt.Sprintf("mm_ask%d", i))
+		ask.Usderdata = i
+		ask.SetDealFn(m.onMakerDeal)
+		ask.SetBatchMode(true)
+		m.asks = append(m.asks, ask)
+	}
+
+	logger.LogImportant(m.logPrefix, "inited: %s", cfg.String())
+}
+
+func (m *MarketMaker) Uninit() {
+	m.Stop()
+}
+
+func (m *MarketMaker) GetConfig() MarketMakerConfig {
+	return m.cfg
+}
+
+// 修改配置。档数不能修改
+func (m *MarketMaker) SetConfig(cfg MarketMakerConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cfg.LevelCount = m.cfg.LevelCount
+	m.cfg = cfg
+}
+
+// 开始报价
+func (m *MarketMaker) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running = true
+}
+
+// 停止报价，撤掉所有挂单
+func (m *MarketMaker) Stop() {
+	m.mu.Lock()
+	m.running = false
+	makers := m.makers()
+	m.mu.Unlock()
+	CancelMakersBatch(m.trader, makers)
+}
+
+func (m *MarketMaker) Status() MarketMakerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+func (m *MarketMaker) StatusStr() string {
+	s := m.Status()
+	b, _ := json.MarshalIndent(s, "", "  ")
+	return string(b)
+}
+
+// 由外部驱动，建议100ms左右调用一次
+func (m *MarketMaker) Update() {
+	m.mu.Lock()
+	m.updateQuotes()
+	makers := m.makers()
+	m.mu.Unlock()
+
+	// 下单/改单/撤单涉及网络请求，不能持有m.mu，否则会阻塞成交回调
+	UpdateMakersBatch(m.trader, makers)
+}
+
+// 计算报价，设置各Maker的目标量价。需持有m.mu
+func (m *MarketMaker) updateQuotes() {
+	ob := m.trader.Market().OrderBook()
+	mid := ob.MiddlePrice().InexactFloat64()
+	now := time.Now()
+
+	m.updateVolatility(now, mid)
+	m.updateMarkouts(now, mid)
+
+	// 计算报价
+	cfg := m.cfg
+	sigma := m.volatility()
+	inv := m.inventory()
+	widen := m.widen()
+	q := 0.0
+	if cfg.LevelSize > 0 {
+		q = (inv - cfg.TargetInventory) / cfg.LevelSize
+	}
+
+	variance := sigma * sigma * cfg.HorizonSec
+	rsv := mid * (1 - q*cfg.Gamma*variance)
+	halfSpread := cfg.MinSpread
+	if cfg.Gamma > 0 && cfg.Kappa > 0 {
+		halfSpread = math.Max(cfg.MinSpread, (cfg.Gamma*variance+2/cfg.Gamma*math.Log(1+cfg.Gamma/cfg.Kappa))/2)
+	}
+	halfSpread *= widen
+
+	// 记录状态
+	m.status = MarketMakerStatus{
+		InstId:           m.trader.Market().Type(),
+		Running:          m.running,
+		MidPrice:         mid,
+		ReservationPrice: rsv,
+		HalfSpread:       halfSpread,
+		Volatility:       sigma,
+		Widen:            widen,
+		Inventory:        inv,
+		MarkoutAvg:       m.markoutAvg(),
+		MarkoutCount:     len(m.markouts),
+		BookAgeMs:        ob.Age().Milliseconds(),
+		BuyVolume:        m.buyVolume,
+		SellVolume:       m.sellVolume,
+		Config:           cfg,
+	}
+
+	// 判断是否需要暂停报价
+	m.pauseReason = ""
+	if !m.running {
+		m.pauseReason = "stopped"
+	} else if !m.trader.Ready() {
+		m.pauseReason = fmt.Sprintf("trader not ready: %s", m.trader.UnreadyReason())
+	} else if cfg.StaleBookMs > 0 && ob.Age().Milliseconds() > cfg.StaleBookMs {
+		m.pauseReason = "stale book"
+	} else if mid <= 0 || ob.Empty() {
+		m.pauseReason = "empty book"
+	}
+
+	if len(m.pauseReason) > 0 {
+		m.status.Paused = m.pauseReason
+		m.cancelAll()
+		return
+	}
+
+	// 库存限制：买单总量不超过MaxInventory-inv，卖单总量不超过inv-MinInventory
+	buyQuota := math.Max(0, cfg.MaxInventory-inv)
+	sellQuota := math.Max(0, inv-cfg.MinInventory)
+	for i := 0; i < cfg.LevelCount; i++ {
+		offset := halfSpread + cfg.LevelGap*float64(i)
+
+		bidPx := rsv * (1 - offset)
+		bidSz := math.Min(cfg.LevelSize, buyQuota)
+		buyQuota -= bidSz
+		m.quote(m.bids[i], i, bidPx, bidSz, common.OrderDir_Buy)
+
+		askPx := rsv * (1 + offset)
+		askSz := math.Min(cfg.LevelSize, sellQuota)
+		sellQuota -= askSz
+		m.quote(m.asks[i], i, askPx, askSz, common.OrderDir_Sell)
+	}
+}
+
+func (m *MarketMaker) quote(mk *Maker, level int, px, sz float64, dir common.OrderDir) {
+	market := m.trader.Market()
+	dpx := market.AlignPrice(decimal.NewFromFloat(px), dir, true)
+	dsz := market.AlignSize(decimal.NewFromFloat(sz))
+	if dsz.LessThan(market.MinSize()) {
+		dsz = decimal.Zero
+	}
+
+	// 未成交数量由Maker管理，这里给出的是期望的挂单数量
+	mk.Modify(dpx, dsz, dir, false)
+	m.status.Quotes = append(m.status.Quotes, MarketMakerQuote{
+		Dir:   common.OrderDir2Str(dir),
+		Level: level,
+		Price: dpx.InexactFloat64(),
+		Size:  dsz.InexactFloat64(),
+		Live:  mk.O != nil && mk.O.IsAlive(),
+	})
+}
+
+func (m *MarketMaker) makers() []*Maker {
+	makers := make([]*Maker, 0, len(m.bids)+len(m.asks))
+	makers = append(makers, m.bids...)
+	return append(makers, m.asks...)
+}
+
+// 清空所有Maker的目标，实际撤单由UpdateMakersBatch完成
+func (m *MarketMaker) cancelAll() {
+	for _, mk := range m.bids {
+		mk.Cancel()
+	}
+	for _, mk := range m.asks {
+		mk.Cancel()
+	}
+}
+
+func (m *MarketMaker) onMakerDeal(deal MakerOrderDeal) {
+	m.mu.Lock()
+	dir := deal.Deal.O.GetDir()
+	amount := deal.Deal.Amount.InexactFloat64()
+	if dir == common.OrderDir_Buy {
+		m.buyVolume += amount
+	} else {
+		m.sellVolume += amount
+	}
+
+	if m.cfg.MarkoutSec > 0 {
+		m.pendingMarkouts = append(m.pendingMarkouts, pendingMarkout{t: time.Now(), dir: dir, px: deal.Deal.Price.InexactFloat64()})
+	}
+	m.mu.Unlock()
+
+	logger.LogInfo(m.logPrefix, "deal: %s %v@%v", common.OrderDir2Str(dir), deal.Deal.Amount, deal.Deal.Price)
+	if m.onDeal != nil {
+		m.onDeal(deal)
+	}
+}
+
+// #region 内部函数
+// 库存。合约用净仓位，现货用基础币权益
+func (m *MarketMaker) inventory() float64 {
+	switch t := m.trader.(type) {
+	case common.FutureTrader:
+		if p := t.Position(); p != nil {
+			return p.Net().InexactFloat64()
+		}
+		return 0
+	case common.SpotTrader:
+		return t.BaseBalance().Rights().InexactFloat64()
+	default:
+		return 0
+	}
+}
+
+// 每秒采样一次中间价，记录收益率
+func (m *MarketMaker) updateVolatility(now time.Time, mid float64) {
+	if mid <= 0 {
+		return
+	}
+
+	nowMs := now.UnixMilli()
+	if m.lastMid > 0 && nowMs-m.lastMidMs >= 1000 {
+		m.dlReturn.Update(nowMs, mid/m.lastMid-1)
+		m.lastMid = mid
+		m.lastMidMs = nowMs
+	} else if m.lastMid == 0 {
+		m.lastMid = mid
+		m.lastMidMs = nowMs
+	}
+}
+
+func (m *MarketMaker) volatility() float64 {
+	if m.dlReturn.Length() < 2 {
+		return 0
+	}
+	return m.dlReturn.Std(m.cfg.VolWindow)
+}
+
+// 到期的成交计算markout：买单为(mid-px)/px，卖单为(px-mid)/px
+func (m *MarketMaker) updateMarkouts(now time.Time, mid float64) {
+	if mid <= 0 || len(m.pendingMarkouts) == 0 {
+		return
+	}
+
+	remain := m.pendingMarkouts[:0]
+	for _, pm := range m.pendingMarkouts {
+		if now.Sub(pm.t) < time.Duration(m.cfg.MarkoutSec)*time.Second {
+			remain = append(remain, pm)
+			continue
+		}
+
+		mo := (mid - pm.px) / pm.px
+		if pm.dir == common.OrderDir_Sell {
+			mo = -mo
+		}
+		m.markouts = append(m.markouts, mo)
+	}
+	m.pendingMarkouts = remain
+
+	if n := util.MaxInt(m.cfg.MarkoutCount, 1); len(m.markouts) > n {
+		m.markouts = m.markouts[len(m.markouts)-n:]
+	}
+}
+
+func (m *MarketMaker) markoutAvg() float64 {
+	if len(m.markouts) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, mo := range m.markouts {
+		sum += mo
+	}
+	return sum / float64(len(m.markouts))
+}
+
+// 逆向选择放大系数
+func (m *MarketMaker) widen() float64 {
+	avg := m.markoutAvg()
+	if avg >= 0 || m.cfg.MinSpread <= 0 {
+		return 1
+	}
+
+	w := 1 + m.cfg.AdverseWiden*(-avg)/m.cfg.MinSpread
+	if m.cfg.MaxWiden > 1 {
+		w = math.Min(w, m.cfg.MaxWiden)
+	}
+	return w
+}
+
+// #endregion
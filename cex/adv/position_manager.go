@@ -76,6 +76,9 @@ type PositionManager struct {
 	// 吃单冷却
 	takerCD time.Time
 
+	// 批量模式。各Maker只设置目标，在Update/Cancel中统一提交
+	batchMode bool
+
 	// 外部成交回调
 	onDeal OnMakerOrderDeal
 }
@@ -246,20 +249,46 @@ func (p *PositionManager) Quit() {
 	logger.LogInfo(p.logPrefix, "quit toggled to %v", p.quiting)
 }
 
+// 批量模式下，下单/改单/撤单经由交易器的批量接口提交
+// 吃单Maker不改单，每次提交后清空目标，未成交的部分在下一次提交时撤掉
+func (p *PositionManager) SetBatchMode(batch bool) {
+	p.batchMode = batch
+	p.mkOpen.SetBatchMode(batch)
+	p.mkClose.SetBatchMode(batch)
+	p.tkOpen.SetBatchMode(batch)
+	p.tkClose.SetBatchMode(batch)
+	p.tkOpen.enableModify = !batch
+	p.tkClose.enableModify = !batch
+}
+
 func (p *PositionManager) Cancel() {
-	p.mkOpen.Cancel()
-	p.mkClose.Cancel()
-	p.tkOpen.Cancel()
-	p.tkClose.Cancel()
+	if p.batchMode {
+		CancelMakersBatch(p.trader, p.makers())
+	} else {
+		p.mkOpen.Cancel()
+		p.mkClose.Cancel()
+		p.tkOpen.Cancel()
+		p.tkClose.Cancel()
+	}
 	logger.LogInfo(p.logPrefix, "all order canceled")
 }
 
 func (p *PositionManager) Update() {
-	if p.dealDone {
+	if !p.enabled {
 		return
 	}
 
-	if !p.enabled {
+	p.update()
+
+	if p.batchMode {
+		UpdateMakersBatch(p.trader, p.makers())
+		p.tkOpen.Cancel()
+		p.tkClose.Cancel()
+	}
+}
+
+func (p *PositionManager) update() {
+	if p.dealDone {
 		return
 	}
 
@@ -366,10 +395,20 @@ func (p *PositionManager) Update() {
 		}
 	} else {
 		p.dealDone = true
+
+		// 批量模式下目标会被反复提交，达到目标后要清空，否则订单结束后会被重新创建
+		if p.batchMode {
+			p.mkOpen.Cancel()
+			p.mkClose.Cancel()
+		}
 	}
 }
 
 // #region 内部逻辑
+func (p *PositionManager) makers() []*Maker {
+	return []*Maker{p.mkOpen, p.mkClose, p.tkOpen, p.tkClose}
+}
+
 func (p *PositionManager) long() decimal.Decimal {
 	return p.trader.Position().Long()
 }
@@ -134,6 +134,7 @@ func (g *ReversedGrid) Init(
 	g.pm = new(PositionManager)
 	g.pm.Init(g.trader, g.onOrderDeal, g.logPrefix, false, false)
 	g.pm.SetTaker(true, true)
+	g.pm.SetBatchMode(true)
 
 	g.p2pOpenBuy = NewPriceMap2Position(trader.Market())
 	g.p2pCloseBuy = NewPriceMap2Position(trader.Market())
@@ -0,0 +1,515 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 12:48:03
+ * @Description: 现货正向网格。固定范围，每格独立挂单，带止损
+ * 每一格在buyPrice挂买单，买满后在上一格价格（sellPrice）挂卖单，卖完算一轮，记录该格利润
+ * 库存通过SpotTrader的BaseBalance/QuoteBalance管理
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package adv
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/stratergy/datamanager"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+// 参数
+type SpotGridConfig struct {
+	MaxValue     int64       `json:"max_value"`    // 网格总投入（以计价币计算）
+	BasePrice    float64     `json:"base_px"`      // 中心点价格
+	GridRange    float64     `json:"grid_range"`   // 网格范围（以单边计）
+	GridStep     float64     `json:"grid_step"`    // 网格格子大小
+	Spacing      GridSpacing `json:"spacing"`      // 格子间距方式
+	SLStep       int         `json:"sl_step"`      // 止损价格子数。在超出网格下沿多少格之后止损
+	UseInventory bool        `json:"use_inv"`      // 启动时，用账户中已有的基础币填充当前价格之上的格子
+	FeeRate      float64     `json:"fee_rate"`     // 记账用的挂单手续费率。为0时使用交易器提供的费率
+	FeeInQuote   bool        `json:"fee_in_quote"` // 买入手续费以计价币收取。默认按基础币收取（OKX/Binance现货的默认方式）
+}
+
+func (c *SpotGridConfig) String() string {
+	return fmt.Sprintf(
+		"maxValue: %d, range:%.2f%%, step:%.2f%%(%s), sl at %d step",
+		c.MaxValue,
+		c.GridRange*100,
+		c.GridStep*100,
+		GridSpacing2Str(c.Spacing),
+		c.SLStep)
+}
+
+// 状态
+type SpotGridStatus struct {
+	InstId        string           `json:"inst_id"`
+	Phase         string           `json:"phase"`
+	TryRetreat    bool             `json:"try_retreat"`
+	ActiveTime    string           `json:"active_time"`
+	BasePrice     float64          `json:"base_px"`
+	CurrentPrice  float64          `json:"cur_px"`
+	StopLossPrice float64          `json:"sl_px"`
+	LevelCount    int              `json:"level_count"`
+	BaseAvailable float64          `json:"base_avail"`
+	QuoteAvail    float64          `json:"quote_avail"`
+	Profit        GridProfitStatus `json:"profit"`
+	Levels        []string         `json:"levels"`
+	FrameIndex    int              `json:"frame_index"`
+	Config        SpotGridConfig   `json:"config"`
+}
+
+type SpotGrid struct {
+	logPrefix         string
+	dealType          string
+	mu                sync.Mutex
+	infContext        *datamanager.InfluxContext // 数据存储
+	cfg               SpotGridConfig             // 配置
+	cfgDirty          bool                       // 配置更新过
+	trader            common.SpotTrader          // 交易器
+	activeTime        time.Time                  // 自动激活时间，过了这个时间会自动激活
+	onDeal            OnMakerOrderDeal           // 成交回调
+	levels            []*spotGridLevel           // 格子
+	mkRetreat         *Maker                     // 撤退时用于卖出全部库存
+	stopLossPrice     float64
+	stopLossStartTime time.Time
+	phase             GridPhase // 交易阶段，与合约网格共用
+	tryRetreat        bool      // 下次清仓后撤退
+	frameIndex        int
+}
+
+func (g *SpotGrid) Init(
+	trader common.SpotTrader,
+	cfg SpotGridConfig,
+	onDeal OnMakerOrderDeal,
+	activeTime time.Time,
+	autoUpdate bool,
+	dealType string) {
+	g.dealType = dealType
+	g.logPrefix = fmt.Sprintf("sgrid-%s", trader.Market().Type())
+	g.cfg = cfg
+	g.trader = trader
+	g.onDeal = onDeal
+	g.infContext = datamanager.NewInfluxContext("sgrid", trader.Market().Type())
+	g.activeTime = activeTime
+
+	g.mkRetreat = new(Maker)
+	g.mkRetreat.Init(trader, true, true, true, 0.0001, 0.1, "retreat")
+	g.mkRetreat.SetDealFn(g.onRetreatDeal)
+	g.mkRetreat.Go()
+
+	g.generatePlan()
+
+	if g.cfg.UseInventory {
+		g.assignInventory()
+	}
+
+	if len(g.levels) == 0 || g.px() < g.stopLossPrice {
+		// 非法参数。保护。
+		g.phase = GridPhase_Finished
+	} else {
+		g.phase = GridPhase_WaitActive
+		if autoUpdate {
+			go g.autoUpdate()
+		}
+
+		go g.autoSaveData()
+	}
+}
+
+func (g *SpotGrid) uninit() {
+	g.cancelLevels()
+	g.mkRetreat.Cancel()
+	g.mkRetreat.Stop()
+}
+
+func (g *SpotGrid) GetConfig() SpotGridConfig {
+	return g.cfg
+}
+
+// 修改配置。若格子中还有库存，新的格子计划会在库存清空后才生效
+func (g *SpotGrid) SetConfig(cfg SpotGridConfig) {
+	g.cfg = cfg
+	g.cfgDirty = true
+}
+
+func (g *SpotGrid) Status() SpotGridStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	status := SpotGridStatus{}
+	status.InstId = g.trader.Market().Type()
+	status.Phase = GridPhase2Str(g.phase)
+	status.TryRetreat = g.tryRetreat
+	status.ActiveTime = g.activeTime.Format("2006-01-02 15:04:05")
+	status.BasePrice = g.cfg.BasePrice
+	status.CurrentPrice = g.px()
+	status.StopLossPrice = g.stopLossPrice
+	status.LevelCount = len(g.levels)
+	status.BaseAvailable = g.trader.BaseBalance().Available().InexactFloat64()
+	status.QuoteAvail = g.trader.QuoteBalance().Available().InexactFloat64()
+	status.Profit = sumGridProfit(g.levels)
+	for _, l := range g.levels {
+		status.Levels = append(status.Levels, l.String())
+	}
+	status.FrameIndex = g.frameIndex
+	status.Config = g.cfg
+	return status
+}
+
+func (g *SpotGrid) StatusStr() string {
+	s := g.Status()
+	b, _ := json.MarshalIndent(s, "", "  ")
+	return string(b)
+}
+
+// 利润统计
+func (g *SpotGrid) Profit() GridProfitStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return sumGridProfit(g.levels)
+}
+
+func (g *SpotGrid) GetTrader() common.SpotTrader {
+	return g.trader
+}
+
+func (g *SpotGrid) GetMarket() common.SpotMarket {
+	return g.trader.SpotMarket()
+}
+
+func (g *SpotGrid) onLevelDeal(deal MakerOrderDeal) {
+	g.mu.Lock()
+	l, ok := deal.UserData.(*spotGridLevel)
+	if ok {
+		if deal.Deal.O.GetDir() == common.OrderDir_Buy {
+			l.onBuy(deal.Deal.Price, deal.Deal.Amount, g.feeRate(), !g.cfg.FeeInQuote)
+		} else if l.onSell(deal.Deal.Price, deal.Deal.Amount, g.feeRate(), g.trader.Market()) {
+			logger.LogInfo(g.logPrefix, "level %d round finished, total profit of level: %.6f", l.index, l.profit.Profit)
+		}
+	}
+	g.mu.Unlock()
+
+	g.afterDeal(deal)
+}
+
+func (g *SpotGrid) onRetreatDeal(deal MakerOrderDeal) {
+	g.mu.Lock()
+	// 撤退卖出的数量，从高价格子开始依次扣除
+	remain := deal.Deal.Amount
+	for i := len(g.levels) - 1; i >= 0 && remain.IsPositive(); i-- {
+		l := g.levels[i]
+		if l.holding.IsPositive() {
+			amount := decimal.Min(l.holding, remain)
+			l.onSell(deal.Deal.Price, amount, g.feeRate(), g.trader.Market())
+			remain = remain.Sub(amount)
+		}
+	}
+	g.mu.Unlock()
+
+	g.afterDeal(deal)
+}
+
+func (g *SpotGrid) afterDeal(deal MakerOrderDeal) {
+	logger.LogInfo(g.logPrefix, "dealing: %s", deal.Deal.O.String())
+
+	// 撤退
+	if g.tryRetreat && g.holding().IsZero() {
+		g.switchPhase(GridPhase_Retreat)
+	}
+
+	// 保存数据
+	if g.infContext != nil {
+		g.infContext.AddDeal(deal.Deal, g.dealType)
+	}
+
+	// 回调外部
+	if g.onDeal != nil {
+		g.onDeal(deal)
+	}
+}
+
+func (g *SpotGrid) Active() {
+	if g.phase == GridPhase_WaitActive {
+		// 进入交易状态
+		g.switchPhase(GridPhase_Dealing)
+	}
+}
+
+func (g *SpotGrid) Stop() {
+	g.cancelLevels()
+}
+
+func (g *SpotGrid) Retreat() {
+	g.switchPhase(GridPhase_Retreat)
+}
+
+func (g *SpotGrid) TryRetreat() {
+	g.tryRetreat = true
+
+	// 立刻判断一次
+	if g.tryRetreat && g.holding().IsZero() {
+		g.switchPhase(GridPhase_Retreat)
+	}
+}
+
+func (g *SpotGrid) Detach() {
+	g.switchPhase(GridPhase_Finished)
+}
+
+func (g *SpotGrid) Finished() bool {
+	return g.phase == GridPhase_Finished
+}
+
+func (g *SpotGrid) autoUpdate() {
+	ticker := time.NewTicker(time.Millisecond * 10)
+	for !g.Finished() {
+		<-ticker.C
+		g.Update()
+	}
+	g.uninit()
+}
+
+func (g *SpotGrid) Update() {
+	switch g.phase {
+	case GridPhase_WaitActive:
+		g.update_WaitActive()
+	case GridPhase_Dealing:
+		g.update_Dealing()
+	case GridPhase_Retreat:
+		g.update_Retreat()
+	}
+
+	g.frameIndex++
+}
+
+func (g *SpotGrid) autoSaveData() {
+	ticker := time.NewTicker(time.Second)
+	for {
+		<-ticker.C
+		profit := g.Profit()
+		points := make(map[string]float64)
+		points["px"] = g.px()
+		points["holding"] = profit.Holding
+		points["profit"] = profit.Profit
+		points["rounds"] = float64(profit.Rounds)
+		g.infContext.AddDataPoints(points, time.Now())
+
+		if g.Finished() {
+			break
+		}
+	}
+}
+
+func (g *SpotGrid) update_WaitActive() {
+	if time.Now().After(g.activeTime) {
+		g.Active()
+	}
+}
+
+func (g *SpotGrid) update_Dealing() {
+	// 止损判断
+	markPrice := g.px()
+	if markPrice < g.stopLossPrice {
+		if g.stopLossStartTime.IsZero() {
+			g.stopLossStartTime = time.Now()
+		} else if time.Now().Unix()-g.stopLossStartTime.Unix() > 10 {
+			// 价格超标，切换到平仓状态
+			g.switchPhase(GridPhase_Retreat)
+			return
+		}
+	} else if !g.stopLossStartTime.IsZero() {
+		g.stopLossStartTime = time.Time{}
+	}
+
+	// 锁内只计算各格子的目标挂单，提交放在锁外，避免网络请求期间阻塞成交回调
+	g.mu.Lock()
+
+	// 适时更新计划表。格子里还有库存时不能重建，否则会丢失成本信息
+	var oldMakers []*Maker
+	if g.cfgDirty && sumGridProfit(g.levels).Holding == 0 {
+		oldMakers = g.levelMakers()
+		g.generatePlan()
+		g.cfgDirty = false
+	}
+
+	// 每格独立挂单：未满仓的格子挂买单，满仓的格子挂卖单
+	m := g.trader.Market()
+	quoteAvail := g.trader.QuoteBalance().Available()
+	baseAvail := g.trader.BaseBalance().Available()
+	for _, l := range g.levels {
+		if l.full(m) || !l.empty(m) && l.mkSell.O != nil {
+			// 卖出阶段（部分卖出后，继续卖完剩余库存）
+			l.mkBuy.Cancel()
+			sz := l.holding
+			if l.mkSell.O == nil && sz.GreaterThan(baseAvail) {
+				sz = baseAvail
+			}
+			l.mkSell.Modify(decimal.NewFromFloat(l.sellPrice), sz, common.OrderDir_Sell, false)
+			if l.mkSell.O == nil {
+				baseAvail = baseAvail.Sub(sz)
+			}
+		} else if l.buyPrice <= markPrice {
+			// 买入阶段，只在价格之下挂买单
+			l.mkSell.Cancel()
+			sz := l.size.Sub(l.holding)
+			px := decimal.NewFromFloat(l.buyPrice)
+			if l.mkBuy.O == nil && px.Mul(sz).GreaterThan(quoteAvail) {
+				sz = quoteAvail.Div(px)
+			}
+			l.mkBuy.Modify(px, sz, common.OrderDir_Buy, false)
+			if l.mkBuy.O == nil {
+				quoteAvail = quoteAvail.Sub(px.Mul(sz))
+			}
+		} else {
+			l.mkBuy.Cancel()
+			l.mkSell.Cancel()
+		}
+	}
+
+	makers := g.levelMakers()
+	g.mu.Unlock()
+
+	if len(oldMakers) > 0 {
+		CancelMakersBatch(g.trader, oldMakers)
+	}
+	UpdateMakersBatch(g.trader, makers)
+}
+
+func (g *SpotGrid) update_Retreat() {
+	h := g.holding()
+
+	// 结束条件
+	if h.IsZero() {
+		logger.LogInfo(g.logPrefix, "no holding in retreat status, finish")
+		g.switchPhase(GridPhase_Finished)
+		return
+	}
+
+	// 跟随卖一价挂单卖出全部库存
+	sz := decimal.Min(h, g.trader.BaseBalance().Available().Add(g.retreatUnfilled()))
+	g.mkRetreat.Modify(g.trader.Market().OrderBook().Sell1Price(), sz, common.OrderDir_Sell, false)
+}
+
+func (g *SpotGrid) generatePlan() {
+	// 计算中点价格
+	if g.cfg.BasePrice == 0 {
+		g.cfg.BasePrice = g.trader.Market().OrderBook().MiddlePrice().InexactFloat64()
+	}
+
+	basePx := g.cfg.BasePrice
+	px0 := basePx * (1 - g.cfg.GridRange)
+	px1 := basePx * (1 + g.cfg.GridRange)
+	if g.cfg.Spacing == GridSpacing_Geometric {
+		px0 = basePx / (1 + g.cfg.GridRange)
+	}
+
+	prices := genGridPrices(g.trader.Market(), basePx, px0, px1, g.cfg.GridStep, g.cfg.Spacing)
+	g.levels = nil
+	if len(prices) < 2 {
+		logger.LogImportant(g.logPrefix, "invalid grid config: %s", g.cfg.String())
+		return
+	}
+
+	// 每格投入相同的计价币
+	valuePerLevel := float64(g.cfg.MaxValue) / float64(len(prices)-1)
+	for i := 0; i < len(prices)-1; i++ {
+		l := &spotGridLevel{index: i, buyPrice: prices[i], sellPrice: prices[i+1]}
+		l.size = g.trader.Market().AlignSize(decimal.NewFromFloat(valuePerLevel / prices[i]))
+		l.mkBuy = new(Maker)
+		l.mkBuy.Init(g.trader, true, true, true, 0, 0.1, fmt.Sprintf("buy%d", i))
+		l.mkBuy.Usderdata = l
+		l.mkBuy.SetDealFn(g.onLevelDeal)
+		l.mkBuy.SetBatchMode(true)
+		l.mkSell = new(Maker)
+		l.mkSell.Init(g.trader, true, true, true, 0, 0.1, fmt.Sprintf("sell%d", i))
+		l.mkSell.Usderdata = l
+		l.mkSell.SetDealFn(g.onLevelDeal)
+		l.mkSell.SetBatchMode(true)
+		g.levels = append(g.levels, l)
+	}
+
+	g.stopLossPrice = gridPriceBeyond(prices[0], basePx, g.cfg.GridStep, g.cfg.SLStep, g.cfg.Spacing, false)
+	g.stopLossPrice = g.trader.Market().AlignPriceNumber(decimal.NewFromFloat(g.stopLossPrice)).InexactFloat64()
+
+	logger.LogImportant(g.logPrefix, "plan generated: %s, %d levels, sl=%v", g.cfg.String(), len(g.levels), g.stopLossPrice)
+}
+
+// 用已有的基础币填充当前价格之上的格子（这些格子按计划应该已经买入）
+func (g *SpotGrid) assignInventory() {
+	px := g.px()
+	avail := g.trader.BaseBalance().Available()
+	for _, l := range g.levels {
+		if l.buyPrice > px && avail.GreaterThanOrEqual(l.size) {
+			l.assignInventory(l.size, l.buyPrice)
+			avail = avail.Sub(l.size)
+		}
+	}
+	logger.LogImportant(g.logPrefix, "inventory assigned, holding=%v", sumGridProfit(g.levels).Holding)
+}
+
+func (g *SpotGrid) feeRate() decimal.Decimal {
+	return gridFeeRate(g.cfg.FeeRate, g.trader.FeeMaker())
+}
+
+// 所有格子的Maker。格子Maker均为批量模式，由UpdateMakersBatch统一提交
+func (g *SpotGrid) levelMakers() []*Maker {
+	makers := make([]*Maker, 0, len(g.levels)*2)
+	for _, l := range g.levels {
+		makers = append(makers, l.mkBuy, l.mkSell)
+	}
+	return makers
+}
+
+// 撤掉所有格子的挂单。调用方不能持有g.mu
+func (g *SpotGrid) cancelLevels() {
+	g.mu.Lock()
+	makers := g.levelMakers()
+	g.mu.Unlock()
+	CancelMakersBatch(g.trader, makers)
+}
+
+func (g *SpotGrid) switchPhase(phase GridPhase) {
+	if g.phase != phase {
+		// 状态切换逻辑
+		if phase == GridPhase_Retreat {
+			g.cancelLevels()
+		}
+
+		logger.LogInfo(
+			g.logPrefix,
+			"phase switching from %s to %s",
+			GridPhase2Str(g.phase),
+			GridPhase2Str(phase))
+		g.phase = phase
+	}
+}
+
+// #region 内部函数
+func (g *SpotGrid) px() float64 {
+	return g.trader.Market().OrderBook().MiddlePrice().InexactFloat64()
+}
+
+// 网格自身持有的基础币（不含账户中的其他库存）
+func (g *SpotGrid) holding() decimal.Decimal {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	h := decimal.Zero
+	for _, l := range g.levels {
+		if !l.empty(g.trader.Market()) {
+			h = h.Add(l.holding)
+		}
+	}
+	return g.trader.Market().AlignSize(h)
+}
+
+func (g *SpotGrid) retreatUnfilled() decimal.Decimal {
+	if o := g.mkRetreat.O; o != nil && o.IsAlive() {
+		return o.GetUnfilled()
+	}
+	return decimal.Zero
+}
+
+// #endregion
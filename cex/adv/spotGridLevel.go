@@ -0,0 +1,209 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 12:10:26
+ * @Description: 现货网格的公共部分：格子价格生成（等差/等比），以及单个格子的库存和利润记账
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package adv
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/shopspring/decimal"
+)
+
+// 格子间距
+type GridSpacing int
+
+const (
+	GridSpacing_Arithmetic GridSpacing = iota // 等差，每格价差 = 中心价 * GridStep
+	GridSpacing_Geometric                     // 等比，每格价格 = 上一格 * (1 + GridStep)
+)
+
+func GridSpacing2Str(s GridSpacing) string {
+	switch s {
+	case GridSpacing_Arithmetic:
+		return "arithmetic"
+	case GridSpacing_Geometric:
+		return "geometric"
+	default:
+		return "unknown"
+	}
+}
+
+// 生成[px0, px1]范围内的格子价格，从低到高排列，价格已对齐
+// basePx为中心价，格子从中心价开始向两侧延伸，保证中心价本身是一个格子
+func genGridPrices(m common.CommonMarket, basePx, px0, px1, step float64, spacing GridSpacing) []float64 {
+	if basePx <= 0 || step <= 0 || px0 >= px1 {
+		return nil
+	}
+
+	down := []float64{}
+	up := []float64{}
+	if spacing == GridSpacing_Geometric {
+		for px := basePx; px >= px0*(1-1e-9); px /= 1 + step {
+			down = append(down, px)
+		}
+		for px := basePx * (1 + step); px <= px1*(1+1e-9); px *= 1 + step {
+			up = append(up, px)
+		}
+	} else {
+		d := basePx * step
+		for px := basePx; px >= px0-d*1e-9; px -= d {
+			down = append(down, px)
+		}
+		for px := basePx + d; px <= px1+d*1e-9; px += d {
+			up = append(up, px)
+		}
+	}
+
+	prices := make([]float64, 0, len(down)+len(up))
+	for i := len(down) - 1; i >= 0; i-- {
+		prices = append(prices, down[i])
+	}
+	prices = append(prices, up...)
+
+	// 对齐并去重（价格精度不足时，相邻格子可能对齐到同一价格）
+	aligned := make([]float64, 0, len(prices))
+	for _, px := range prices {
+		apx := m.AlignPriceNumber(decimal.NewFromFloat(px)).InexactFloat64()
+		if apx > 0 && (len(aligned) == 0 || apx > aligned[len(aligned)-1]) {
+			aligned = append(aligned, apx)
+		}
+	}
+
+	return aligned
+}
+
+// 止损/止盈价：在边界价格外再延伸n格
+func gridPriceBeyond(px, basePx, step float64, n int, spacing GridSpacing, upward bool) float64 {
+	if spacing == GridSpacing_Geometric {
+		if upward {
+			return px * math.Pow(1+step, float64(n))
+		}
+		return px / math.Pow(1+step, float64(n))
+	}
+
+	if upward {
+		return px + basePx*step*float64(n)
+	}
+	return px - basePx*step*float64(n)
+}
+
+// 格子利润统计
+type GridProfitStatus struct {
+	Rounds    int     `json:"rounds"`     // 完成的买卖轮次
+	Profit    float64 `json:"profit"`     // 已实现利润（计价币，已扣除手续费）
+	Fee       float64 `json:"fee"`        // 累计手续费（计价币）
+	Holding   float64 `json:"holding"`    // 当前持有的基础币数量
+	CostValue float64 `json:"cost_value"` // 当前持有部分的买入成本（计价币）
+}
+
+// 单个格子的记账
+// 一个格子的一轮：买入size数量基础币，再全部卖出，利润 = 卖出所得 - 买入成本 - 手续费
+type spotGridLevel struct {
+	index     int
+	buyPrice  float64
+	sellPrice float64
+	size      decimal.Decimal // 每格交易数量（基础币）
+	holding   decimal.Decimal // 本格当前持有的基础币
+	cost      float64         // 本轮买入成本
+	revenue   float64         // 本轮卖出所得
+	fee       float64         // 本轮手续费
+	mkBuy     *Maker
+	mkSell    *Maker
+	profit    GridProfitStatus
+}
+
+func (l *spotGridLevel) String() string {
+	return fmt.Sprintf("[%d] buy@%v sell@%v size=%v holding=%v", l.index, l.buyPrice, l.sellPrice, l.size, l.holding)
+}
+
+// 本格是否已经满仓（剩余不足最小下单量也算满）
+func (l *spotGridLevel) full(m common.CommonMarket) bool {
+	return m.AlignSize(l.size.Sub(l.holding)).LessThan(m.MinSize()) || l.holding.GreaterThanOrEqual(l.size)
+}
+
+// 本格是否已经清空
+func (l *spotGridLevel) empty(m common.CommonMarket) bool {
+	return m.AlignSize(l.holding).LessThan(m.MinSize())
+}
+
+// 买入成交。feeInBase表示手续费以基础币收取，此时实际到账的数量要扣除手续费，
+// 否则后续按holding卖出会超过真实余额。这部分手续费已经体现在卖出所得中，不再计入本轮fee
+func (l *spotGridLevel) onBuy(px, amount, feeRate decimal.Decimal, feeInBase bool) {
+	value := px.Mul(amount).InexactFloat64()
+	l.cost += value
+	if feeInBase {
+		feeAmount := amount.Mul(feeRate)
+		l.holding = l.holding.Add(amount.Sub(feeAmount))
+		l.profit.Fee += px.Mul(feeAmount).InexactFloat64()
+	} else {
+		fee := value * feeRate.InexactFloat64()
+		l.holding = l.holding.Add(amount)
+		l.fee += fee
+		l.profit.Fee += fee
+	}
+}
+
+// 卖出成交。返回本轮是否结束
+func (l *spotGridLevel) onSell(px, amount, feeRate decimal.Decimal, m common.CommonMarket) bool {
+	l.holding = l.holding.Sub(amount)
+	if l.holding.IsNegative() {
+		l.holding = decimal.Zero
+	}
+
+	value := px.Mul(amount).InexactFloat64()
+	fee := value * feeRate.InexactFloat64()
+	l.revenue += value
+	l.fee += fee
+	l.profit.Fee += fee
+
+	if l.empty(m) {
+		l.profit.Rounds++
+		l.profit.Profit += l.revenue - l.cost - l.fee
+		l.cost = 0
+		l.revenue = 0
+		l.fee = 0
+		return true
+	}
+
+	return false
+}
+
+// 记账用的手续费率。配置了就用配置值，否则用交易器提供的费率
+func gridFeeRate(cfgRate float64, traderRate decimal.Decimal) decimal.Decimal {
+	if cfgRate > 0 {
+		return decimal.NewFromFloat(cfgRate)
+	}
+	return traderRate
+}
+
+// 带库存启动时，按指定价格计入成本
+func (l *spotGridLevel) assignInventory(amount decimal.Decimal, px float64) {
+	l.holding = amount
+	l.cost = amount.InexactFloat64() * px
+}
+
+func (l *spotGridLevel) status() GridProfitStatus {
+	s := l.profit
+	s.Holding = l.holding.InexactFloat64()
+	s.CostValue = l.cost
+	return s
+}
+
+func sumGridProfit(levels []*spotGridLevel) GridProfitStatus {
+	total := GridProfitStatus{}
+	for _, l := range levels {
+		s := l.status()
+		total.Rounds += s.Rounds
+		total.Profit += s.Profit
+		total.Fee += s.Fee
+		total.Holding += s.Holding
+		total.CostValue += s.CostValue
+	}
+	return total
+}
@@ -0,0 +1,535 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 14:05:19
+ * @Description: 现货反向网格。价格向上突破时逐格追买，回落时逐格卖出，超出范围后止盈撤退
+ * 与合约反向网格一样使用吃单，每格独立记账
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package adv
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/framework"
+	"github.com/aztecqt/dagger/stratergy/datamanager"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+// 参数
+type SpotReversedGridConfig struct {
+	StartPriceAvgPeriod int64       `json:"start_px_avg_period"` // 起始平均价的计算时长
+	MaxValue            int64       `json:"max_value"`           // 网格总投入（以计价币计算）
+	GridRange           float64     `json:"grid_range"`          // 网格范围（以单边计）
+	GridStep            float64     `json:"grid_step"`           // 网格格子大小
+	Spacing             GridSpacing `json:"spacing"`             // 格子间距方式
+	TPStep              int         `json:"tp_step"`             // 止盈价格子数。在超出网格范围多少格之后止盈
+	FeeRate             float64     `json:"fee_rate"`            // 记账用的吃单手续费率。为0时使用交易器提供的费率
+	FeeInQuote          bool        `json:"fee_in_quote"`        // 买入手续费以计价币收取。默认按基础币收取（OKX/Binance现货的默认方式）
+}
+
+func (c *SpotReversedGridConfig) String() string {
+	return fmt.Sprintf(
+		"maxValue: %d, range:%.2f%%, step:%.2f%%(%s), tp at %d step",
+		c.MaxValue,
+		c.GridRange*100,
+		c.GridStep*100,
+		GridSpacing2Str(c.Spacing),
+		c.TPStep)
+}
+
+// 状态
+type SpotReversedGridStatus struct {
+	InstId          string                 `json:"inst_id"`
+	Phase           string                 `json:"phase"`
+	ActiveTime      string                 `json:"active_time"`
+	BasePrice       float64                `json:"base_px"`
+	CurrentPrice    float64                `json:"cur_px"`
+	TakeProfitPrice float64                `json:"tp_px"`
+	LevelCount      int                    `json:"level_count"`
+	Profit          GridProfitStatus       `json:"profit"`
+	Levels          []string               `json:"levels"`
+	FrameIndex      int                    `json:"frame_index"`
+	Config          SpotReversedGridConfig `json:"config"`
+}
+
+type SpotReversedGrid struct {
+	logPrefix       string
+	dealType        string
+	mu              sync.Mutex
+	infContext      *datamanager.InfluxContext // 数据存储
+	cfg             SpotReversedGridConfig     // 配置
+	cfgDirty        bool                       // 配置更新过
+	trader          common.SpotTrader          // 交易器
+	activeTime      time.Time                  // 自动激活时间，过了这个时间会自动激活
+	onDeal          OnMakerOrderDeal           // 成交回调
+	dlPrice         *framework.DataLine        // waitActive阶段用于计算价格平均值
+	basePrice       float64
+	takeProfitPrice float64
+	levels          []*spotGridLevel // 格子。第i格在prices[i+1]买入，在prices[i]卖出
+	profitHistory   GridProfitStatus // 重建格子前的利润累计
+	mkRetreat       *Maker           // 撤退时用于卖出全部库存
+	phase           GridPhase        // 交易阶段，与合约网格共用
+	frameIndex      int
+}
+
+func (g *SpotReversedGrid) Init(
+	trader common.SpotTrader,
+	cfg SpotReversedGridConfig,
+	onDeal OnMakerOrderDeal,
+	activeTime time.Time,
+	autoUpdate bool,
+	dealType string) {
+	if cfg.GridStep >= cfg.GridRange {
+		logger.LogPanic(g.logPrefix, "invalid param")
+		return
+	}
+
+	g.dealType = dealType
+	g.logPrefix = fmt.Sprintf("srGrid-%s", trader.Market().Type())
+	g.cfg = cfg
+	g.trader = trader
+	g.onDeal = onDeal
+	g.infContext = datamanager.NewInfluxContext("srgrid", trader.Market().Type())
+	g.activeTime = activeTime
+	g.dlPrice = new(framework.DataLine)
+	g.dlPrice.Init("price", 120, 1000, 0)
+
+	g.mkRetreat = new(Maker)
+	g.mkRetreat.Init(trader, true, true, true, 0.0001, 0.1, "retreat")
+	g.mkRetreat.SetDealFn(g.onRetreatDeal)
+	g.mkRetreat.Go()
+
+	g.generatePlan(true)
+	g.phase = GridPhase_WaitActive
+
+	if autoUpdate {
+		go g.autoUpdate()
+	}
+
+	go g.autoSaveData()
+}
+
+func (g *SpotReversedGrid) uninit() {
+	g.cancelLevels()
+	g.mkRetreat.Cancel()
+	g.mkRetreat.Stop()
+}
+
+func (g *SpotReversedGrid) GetConfig() SpotReversedGridConfig {
+	return g.cfg
+}
+
+func (g *SpotReversedGrid) SetConfig(cfg SpotReversedGridConfig) {
+	g.cfg = cfg
+	g.cfgDirty = true
+}
+
+func (g *SpotReversedGrid) Status() SpotReversedGridStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	status := SpotReversedGridStatus{}
+	status.InstId = g.trader.Market().Type()
+	status.Phase = GridPhase2Str(g.phase)
+	status.ActiveTime = g.activeTime.Format("2006-01-02 15:04:05")
+	status.BasePrice = g.basePrice
+	status.CurrentPrice = g.px()
+	status.TakeProfitPrice = g.takeProfitPrice
+	status.LevelCount = len(g.levels)
+	status.Profit = g.profitLocked()
+	for _, l := range g.levels {
+		status.Levels = append(status.Levels, l.String())
+	}
+	status.FrameIndex = g.frameIndex
+	status.Config = g.cfg
+	return status
+}
+
+func (g *SpotReversedGrid) StatusStr() string {
+	s := g.Status()
+	b, _ := json.MarshalIndent(s, "", "  ")
+	return string(b)
+}
+
+// 利润统计
+func (g *SpotReversedGrid) Profit() GridProfitStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.profitLocked()
+}
+
+func (g *SpotReversedGrid) profitLocked() GridProfitStatus {
+	p := sumGridProfit(g.levels)
+	p.Rounds += g.profitHistory.Rounds
+	p.Profit += g.profitHistory.Profit
+	p.Fee += g.profitHistory.Fee
+	return p
+}
+
+func (g *SpotReversedGrid) GetTrader() common.SpotTrader {
+	return g.trader
+}
+
+func (g *SpotReversedGrid) GetMarket() common.SpotMarket {
+	return g.trader.SpotMarket()
+}
+
+func (g *SpotReversedGrid) onLevelDeal(deal MakerOrderDeal) {
+	g.mu.Lock()
+	if l, ok := deal.UserData.(*spotGridLevel); ok {
+		if deal.Deal.O.GetDir() == common.OrderDir_Buy {
+			l.onBuy(deal.Deal.Price, deal.Deal.Amount, g.feeRate(), !g.cfg.FeeInQuote)
+		} else {
+			l.onSell(deal.Deal.Price, deal.Deal.Amount, g.feeRate(), g.trader.Market())
+		}
+	}
+	g.mu.Unlock()
+
+	g.afterDeal(deal)
+}
+
+func (g *SpotReversedGrid) onRetreatDeal(deal MakerOrderDeal) {
+	g.mu.Lock()
+	remain := deal.Deal.Amount
+	for i := len(g.levels) - 1; i >= 0 && remain.IsPositive(); i-- {
+		l := g.levels[i]
+		if l.holding.IsPositive() {
+			amount := decimal.Min(l.holding, remain)
+			l.onSell(deal.Deal.Price, amount, g.trader.FeeMaker(), g.trader.Market())
+			remain = remain.Sub(amount)
+		}
+	}
+	g.mu.Unlock()
+
+	g.afterDeal(deal)
+}
+
+func (g *SpotReversedGrid) afterDeal(deal MakerOrderDeal) {
+	logger.LogInfo(g.logPrefix, "dealing: %s", deal.Deal.O.String())
+
+	// 保存数据
+	if g.infContext != nil {
+		g.infContext.AddDeal(deal.Deal, g.dealType)
+	}
+
+	// 回调外部
+	if g.onDeal != nil {
+		g.onDeal(deal)
+	}
+}
+
+func (g *SpotReversedGrid) Active() {
+	if g.phase == GridPhase_WaitActive {
+		// 进入交易状态
+		g.switchPhase(GridPhase_Dealing)
+	}
+}
+
+func (g *SpotReversedGrid) Stop() {
+	g.cancelLevels()
+}
+
+func (g *SpotReversedGrid) Retreat() {
+	g.switchPhase(GridPhase_Retreat)
+}
+
+func (g *SpotReversedGrid) Detach() {
+	g.switchPhase(GridPhase_Finished)
+}
+
+func (g *SpotReversedGrid) Finished() bool {
+	return g.phase == GridPhase_Finished
+}
+
+func (g *SpotReversedGrid) autoUpdate() {
+	ticker := time.NewTicker(time.Millisecond * 10)
+	for !g.Finished() {
+		<-ticker.C
+		g.Update()
+	}
+	g.uninit()
+}
+
+func (g *SpotReversedGrid) Update() {
+	switch g.phase {
+	case GridPhase_WaitActive:
+		g.update_WaitActive()
+	case GridPhase_Dealing:
+		g.update_Dealing()
+	case GridPhase_Retreat:
+		g.update_Retreat()
+	}
+
+	g.frameIndex++
+}
+
+func (g *SpotReversedGrid) autoSaveData() {
+	ticker := time.NewTicker(time.Second)
+	for {
+		<-ticker.C
+		profit := g.Profit()
+		points := make(map[string]float64)
+		points["px"] = g.px()
+		points["holding"] = profit.Holding
+		points["profit"] = profit.Profit
+		points["rounds"] = float64(profit.Rounds)
+		g.infContext.AddDataPoints(points, time.Now())
+
+		if g.Finished() {
+			break
+		}
+	}
+}
+
+func (g *SpotReversedGrid) update_WaitActive() {
+	g.dlPrice.Update(time.Now().UnixMilli(), g.px())
+	if avg := g.calcuAvgPrice(); avg > 0 {
+		g.basePrice = avg
+	}
+	CancelMakersBatch(g.trader, g.generatePlan(true))
+
+	if time.Now().After(g.activeTime) {
+		g.Active()
+	}
+}
+
+func (g *SpotReversedGrid) update_Dealing() {
+	// 适时更新计划表
+	var oldMakers []*Maker
+	if g.cfgDirty {
+		oldMakers = g.generatePlan(true)
+		g.cfgDirty = false
+	} else {
+		oldMakers = g.generatePlan(false)
+	}
+	CancelMakersBatch(g.trader, oldMakers)
+
+	// 价格达标，切换到平仓状态
+	markPrice := g.px()
+	if len(g.levels) > 0 && markPrice > g.takeProfitPrice {
+		g.switchPhase(GridPhase_Retreat)
+		return
+	}
+
+	// 价格突破某格的买入价则追买，跌破该格的卖出价则卖出
+	// 锁内只计算各格子的目标挂单，提交放在锁外，避免网络请求期间阻塞成交回调
+	g.mu.Lock()
+	m := g.trader.Market()
+	ob := m.OrderBook()
+	quoteAvail := g.trader.QuoteBalance().Available()
+	baseAvail := g.trader.BaseBalance().Available()
+	for _, l := range g.levels {
+		if markPrice >= l.buyPrice && !l.full(m) {
+			l.mkSell.Cancel()
+			sz := l.size.Sub(l.holding)
+			px := ob.Sell1Price()
+			if l.mkBuy.O == nil && px.Mul(sz).GreaterThan(quoteAvail) {
+				sz = quoteAvail.Div(px)
+			}
+			l.mkBuy.Modify(px, sz, common.OrderDir_Buy, false)
+			if l.mkBuy.O == nil {
+				quoteAvail = quoteAvail.Sub(px.Mul(sz))
+			}
+		} else if markPrice <= l.sellPrice && !l.empty(m) {
+			l.mkBuy.Cancel()
+			sz := l.holding
+			if l.mkSell.O == nil && sz.GreaterThan(baseAvail) {
+				sz = baseAvail
+			}
+			l.mkSell.Modify(ob.Buy1Price(), sz, common.OrderDir_Sell, false)
+			if l.mkSell.O == nil {
+				baseAvail = baseAvail.Sub(sz)
+			}
+		} else {
+			l.mkBuy.Cancel()
+			l.mkSell.Cancel()
+		}
+	}
+
+	makers := g.levelMakers()
+	g.mu.Unlock()
+	UpdateMakersBatch(g.trader, makers)
+}
+
+func (g *SpotReversedGrid) update_Retreat() {
+	h := g.holding()
+
+	// 结束条件
+	if h.IsZero() {
+		logger.LogInfo(g.logPrefix, "no holding in retreat status, finish")
+		g.switchPhase(GridPhase_Finished)
+		return
+	}
+
+	// 跟随卖一价挂单卖出全部库存
+	unfilled := decimal.Zero
+	if o := g.mkRetreat.O; o != nil && o.IsAlive() {
+		unfilled = o.GetUnfilled()
+	}
+	sz := decimal.Min(h, g.trader.BaseBalance().Available().Add(unfilled))
+	g.mkRetreat.Modify(g.trader.Market().OrderBook().Sell1Price(), sz, common.OrderDir_Sell, false)
+}
+
+// 重建格子，返回被替换下来的旧格子Maker，由调用方在锁外撤单
+func (g *SpotReversedGrid) generatePlan(forceRegen bool) []*Maker {
+	// 计算中点价格
+	needGen := false
+	if g.basePrice == 0 {
+		g.basePrice = g.trader.Market().OrderBook().MiddlePrice().InexactFloat64()
+		needGen = true
+	} else if g.holding().IsZero() {
+		// 空仓时，基准价跟随价格下移
+		sell1 := g.trader.Market().OrderBook().Sell1Price().InexactFloat64()
+		if sell1 > 0 && sell1 < g.basePrice {
+			g.basePrice = sell1
+			needGen = true
+		}
+	}
+
+	if !needGen && !forceRegen {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// 格子里还有库存时不重建
+	if sumGridProfit(g.levels).Holding > 0 {
+		return nil
+	}
+
+	basePx := g.basePrice
+	px1 := basePx * (1 + g.cfg.GridRange)
+	prices := genGridPrices(g.trader.Market(), basePx, basePx, px1, g.cfg.GridStep, g.cfg.Spacing)
+	if len(prices) < 2 || g.samePrices(prices) {
+		return nil
+	}
+
+	// 保留旧格子的利润
+	old := sumGridProfit(g.levels)
+	g.profitHistory.Rounds += old.Rounds
+	g.profitHistory.Profit += old.Profit
+	g.profitHistory.Fee += old.Fee
+	oldMakers := g.levelMakers()
+	g.levels = nil
+
+	valuePerLevel := float64(g.cfg.MaxValue) / float64(len(prices)-1)
+	for i := 1; i < len(prices); i++ {
+		l := &spotGridLevel{index: i - 1, buyPrice: prices[i], sellPrice: prices[i-1]}
+		l.size = g.trader.Market().AlignSize(decimal.NewFromFloat(valuePerLevel / prices[i]))
+		l.mkBuy = new(Maker)
+		l.mkBuy.Init(g.trader, false, true, true, 0.0001, 0.1, fmt.Sprintf("buy%d", i))
+		l.mkBuy.Usderdata = l
+		l.mkBuy.SetDealFn(g.onLevelDeal)
+		l.mkBuy.SetBatchMode(true)
+		l.mkSell = new(Maker)
+		l.mkSell.Init(g.trader, false, true, true, 0.0001, 0.1, fmt.Sprintf("sell%d", i))
+		l.mkSell.Usderdata = l
+		l.mkSell.SetDealFn(g.onLevelDeal)
+		l.mkSell.SetBatchMode(true)
+		g.levels = append(g.levels, l)
+	}
+
+	g.takeProfitPrice = gridPriceBeyond(prices[len(prices)-1], basePx, g.cfg.GridStep, g.cfg.TPStep, g.cfg.Spacing, true)
+	g.takeProfitPrice = g.trader.Market().AlignPriceNumber(decimal.NewFromFloat(g.takeProfitPrice)).InexactFloat64()
+	return oldMakers
+}
+
+// 格子价格是否没有变化，没变化就不需要重建
+func (g *SpotReversedGrid) samePrices(prices []float64) bool {
+	if len(g.levels) != len(prices)-1 {
+		return false
+	}
+
+	for i, l := range g.levels {
+		if l.sellPrice != prices[i] || l.buyPrice != prices[i+1] {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *SpotReversedGrid) feeRate() decimal.Decimal {
+	return gridFeeRate(g.cfg.FeeRate, g.trader.FeeTaker())
+}
+
+// 所有格子的Maker。格子Maker均为批量模式，由UpdateMakersBatch统一提交
+func (g *SpotReversedGrid) levelMakers() []*Maker {
+	makers := make([]*Maker, 0, len(g.levels)*2)
+	for _, l := range g.levels {
+		makers = append(makers, l.mkBuy, l.mkSell)
+	}
+	return makers
+}
+
+// 撤掉所有格子的挂单。调用方不能持有g.mu
+func (g *SpotReversedGrid) cancelLevels() {
+	g.mu.Lock()
+	makers := g.levelMakers()
+	g.mu.Unlock()
+	CancelMakersBatch(g.trader, makers)
+}
+
+func (g *SpotReversedGrid) switchPhase(phase GridPhase) {
+	if g.phase != phase {
+		// 状态切换逻辑
+		if phase == GridPhase_Retreat {
+			g.cancelLevels()
+		}
+
+		logger.LogInfo(
+			g.logPrefix,
+			"phase switching from %s to %s",
+			GridPhase2Str(g.phase),
+			GridPhase2Str(phase))
+		g.phase = phase
+	}
+}
+
+// #region 内部函数
+func (g *SpotReversedGrid) px() float64 {
+	return g.trader.Market().OrderBook().MiddlePrice().InexactFloat64()
+}
+
+func (g *SpotReversedGrid) holding() decimal.Decimal {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	h := decimal.Zero
+	for _, l := range g.levels {
+		if !l.empty(g.trader.Market()) {
+			h = h.Add(l.holding)
+		}
+	}
+	return g.trader.Market().AlignSize(h)
+}
+
+// 计算近期价格平均值
+func (g *SpotReversedGrid) calcuAvgPrice() float64 {
+	nowMs := time.Now().UnixMilli()
+	total := 0.0
+	count := 0
+	for i := g.dlPrice.Length() - 1; i >= 0; i-- {
+		if du, ok := g.dlPrice.GetData(i); ok {
+			if nowMs-du.MS <= g.cfg.StartPriceAvgPeriod*1000 {
+				total += du.V
+				count++
+			} else {
+				break
+			}
+		}
+	}
+
+	if count > 0 {
+		avg := total / float64(count)
+		return g.trader.Market().AlignPrice(decimal.NewFromFloat(avg), common.OrderDir_Buy, false).InexactFloat64()
+	} else {
+		return 0
+	}
+}
+
+// #endregion
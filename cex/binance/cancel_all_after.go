@@ -0,0 +1,75 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 16:05:42
+ * @Description: 币安的定时撤单，实现common.CancelAllAfterSupporter
+ * 合约有交易所端的countdownCancelAll，但只能按symbol逐个设置，所以记录下所有在用的合约，逐个续期
+ * 现货没有对应接口，用本地定时器模拟：续期时重置定时器，到期则撤销所有现货挂单
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package binance
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/api/binanceapi/binancefutureapi"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+)
+
+type futureCountdown struct {
+	symbols   map[string]binancefutureapi.APIClass
+	spotTimer *time.Timer
+	mu        sync.Mutex
+}
+
+// 把合约加入定时撤单的范围。本交易所暂未提供合约交易器，合约由外部下单时，需要调用此函数
+func (e *Exchange) UseFutureCountdown(symbol string, ac binancefutureapi.APIClass) {
+	e.countdown.mu.Lock()
+	defer e.countdown.mu.Unlock()
+	if e.countdown.symbols == nil {
+		e.countdown.symbols = make(map[string]binancefutureapi.APIClass)
+	}
+	e.countdown.symbols[symbol] = ac
+}
+
+// 实现common.CancelAllAfterSupporter
+// 合约逐个symbol设置交易所端倒计时（会撤销该合约的所有挂单，不区分策略），现货重置本地定时器
+func (e *Exchange) CancelAllAfter(timeout time.Duration) error {
+	e.countdown.mu.Lock()
+	symbols := make(map[string]binancefutureapi.APIClass, len(e.countdown.symbols))
+	for s, ac := range e.countdown.symbols {
+		symbols[s] = ac
+	}
+
+	if e.countdown.spotTimer != nil {
+		e.countdown.spotTimer.Stop()
+		e.countdown.spotTimer = nil
+	}
+	if timeout > 0 {
+		e.countdown.spotTimer = time.AfterFunc(timeout, func() {
+			defer util.DefaultRecover()
+			logger.LogImportant(logPrefix, "cancel-all-after expired, closing spot orders")
+			e.CloseAllOrders()
+		})
+	}
+	e.countdown.mu.Unlock()
+
+	var firstErr error
+	for symbol, ac := range symbols {
+		resp, err := binancefutureapi.CountdownCancelAll(symbol, timeout, ac)
+		if err == nil && resp.Code != 0 {
+			err = fmt.Errorf("code=%d, msg=%s", resp.Code, resp.Message)
+		}
+
+		if err != nil {
+			logger.LogImportant(logPrefix, "countdown cancel all failed, symbol=%s, err=%s", symbol, err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,69 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 23:18:52
+ * @Description: 币安错误码到common.ErrorCategory的映射
+ * -2010(下单被拒)、-2011(撤单被拒)含义宽泛，需要结合错误消息区分
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package binance
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aztecqt/dagger/api/binanceapi"
+	"github.com/aztecqt/dagger/cex/common"
+)
+
+var errorCategories = map[int]common.ErrorCategory{
+	-1001: common.ErrorCategory_Network,     // DISCONNECTED
+	-1007: common.ErrorCategory_Network,     // TIMEOUT
+	-1003: common.ErrorCategory_RateLimited, // TOO_MANY_REQUESTS
+	-1015: common.ErrorCategory_RateLimited, // TOO_MANY_ORDERS
+	-1002: common.ErrorCategory_AuthFailure, // UNAUTHORIZED
+	-1021: common.ErrorCategory_AuthFailure, // INVALID_TIMESTAMP
+	-1022: common.ErrorCategory_AuthFailure, // INVALID_SIGNATURE
+	-2014: common.ErrorCategory_AuthFailure, // BAD_API_KEY_FMT
+	-2015: common.ErrorCategory_AuthFailure, // REJECTED_MBX_KEY
+	-2013: common.ErrorCategory_OrderNotFound,
+	-2018: common.ErrorCategory_InsufficientBalance, // 余额不足
+	-2019: common.ErrorCategory_InsufficientBalance, // 保证金不足
+}
+
+// 由错误消息区分的分类
+var errorMessageCategories = []struct {
+	msg      string
+	category common.ErrorCategory
+}{
+	{"insufficient balance", common.ErrorCategory_InsufficientBalance},
+	{"immediately match and take", common.ErrorCategory_PostOnlyCross},
+	{"unknown order", common.ErrorCategory_OrderNotFound},
+	{"does not exist", common.ErrorCategory_OrderNotFound},
+	{"market is closed", common.ErrorCategory_InstrumentSuspended},
+	{"trading is disabled", common.ErrorCategory_InstrumentSuspended},
+}
+
+// 由币安的错误消息生成错误。没有错误时返回nil
+func newExchangeError(em binanceapi.ErrorMessage) error {
+	if em.Code == 0 && len(em.Message) == 0 {
+		return nil
+	}
+
+	category, ok := errorCategories[em.Code]
+	if !ok {
+		category = common.ErrorCategory_Unknown
+		lower := strings.ToLower(em.Message)
+		for _, mc := range errorMessageCategories {
+			if strings.Contains(lower, mc.msg) {
+				category = mc.category
+				break
+			}
+		}
+	}
+
+	return common.NewExchangeError(exchangeName, category, fmt.Sprintf("%d", em.Code), em.Message)
+}
+
+func newNetworkError(err error) error {
+	return common.NewNetworkError(exchangeName, err)
+}
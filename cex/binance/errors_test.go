@@ -0,0 +1,82 @@
+package binance
+
+// 检查币安错误码、错误消息到错误分类、重试策略的映射
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aztecqt/dagger/api/binanceapi"
+	"github.com/aztecqt/dagger/cex/common"
+)
+
+func TestNewExchangeError(t *testing.T) {
+	cases := []struct {
+		name      string
+		em        binanceapi.ErrorMessage
+		category  common.ErrorCategory
+		retryable bool
+	}{
+		{"disconnected", binanceapi.ErrorMessage{Code: -1001}, common.ErrorCategory_Network, true},
+		{"timeout", binanceapi.ErrorMessage{Code: -1007}, common.ErrorCategory_Network, true},
+		{"too many requests", binanceapi.ErrorMessage{Code: -1003}, common.ErrorCategory_RateLimited, true},
+		{"too many orders", binanceapi.ErrorMessage{Code: -1015}, common.ErrorCategory_RateLimited, true},
+		{"invalid timestamp", binanceapi.ErrorMessage{Code: -1021}, common.ErrorCategory_AuthFailure, false},
+		{"rejected key", binanceapi.ErrorMessage{Code: -2015}, common.ErrorCategory_AuthFailure, false},
+		{"order not found", binanceapi.ErrorMessage{Code: -2013}, common.ErrorCategory_OrderNotFound, false},
+		{"insufficient margin", binanceapi.ErrorMessage{Code: -2019}, common.ErrorCategory_InsufficientBalance, false},
+
+		// -2010/-2011需要按消息区分，大小写不敏感
+		{"new order insufficient", binanceapi.ErrorMessage{Code: -2010, Message: "Account has insufficient balance for requested action."}, common.ErrorCategory_InsufficientBalance, false},
+		{"post only cross", binanceapi.ErrorMessage{Code: -2010, Message: "Order would immediately match and take."}, common.ErrorCategory_PostOnlyCross, true},
+		{"market closed", binanceapi.ErrorMessage{Code: -2010, Message: "Market is closed."}, common.ErrorCategory_InstrumentSuspended, false},
+		{"cancel unknown order", binanceapi.ErrorMessage{Code: -2011, Message: "Unknown order sent."}, common.ErrorCategory_OrderNotFound, false},
+		{"unmatched message", binanceapi.ErrorMessage{Code: -2010, Message: "Filter failure: PRICE_FILTER"}, common.ErrorCategory_Unknown, true},
+
+		// 错误码优先于消息
+		{"code before message", binanceapi.ErrorMessage{Code: -1003, Message: "unknown order"}, common.ErrorCategory_RateLimited, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := newExchangeError(c.em)
+			if err == nil {
+				t.Fatalf("nil error")
+			}
+			if got := common.ErrorCategoryOf(err); got != c.category {
+				t.Fatalf("category = %v, want %v", got, c.category)
+			}
+			if p := common.RetryPolicyOf(err); p.Retryable != c.retryable || p.Delay(1) <= 0 {
+				t.Fatalf("retry policy = %+v, want retryable=%v", p, c.retryable)
+			}
+
+			var ee *common.ExchangeError
+			if !errors.As(err, &ee) || ee.Exchange != exchangeName || ee.Msg != c.em.Message {
+				t.Fatalf("error = %v", err)
+			}
+		})
+	}
+}
+
+func TestNewExchangeErrorSuccess(t *testing.T) {
+	if err := newExchangeError(binanceapi.ErrorMessage{}); err != nil {
+		t.Fatalf("empty message returns %v", err)
+	}
+
+	// 只有消息没有错误码时仍然是错误
+	err := newExchangeError(binanceapi.ErrorMessage{Message: "Unknown order sent."})
+	if !common.IsErrorCategory(err, common.ErrorCategory_OrderNotFound) {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestNewNetworkError(t *testing.T) {
+	inner := errors.New("connection reset")
+	err := newNetworkError(inner)
+	if !common.IsErrorCategory(err, common.ErrorCategory_Network) || !errors.Is(err, inner) {
+		t.Fatalf("error = %v", err)
+	}
+	if !common.RetryPolicyOf(err).Retryable {
+		t.Fatalf("network error not retryable")
+	}
+}
@@ -8,7 +8,9 @@
 package binance
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -29,6 +31,13 @@ const exchangeName = "Binance"
 
 var exchangeReady = false
 
+// 订单标签，作为clientOrderId的前缀，用来区分交易所上属于本策略的挂单。为空时不加前缀
+var StratergyName string = ""
+
+func orderTag() string {
+	return util.ToLetterNumberOnly(StratergyName, 8)
+}
+
 type OnOrderSnapshotFn func(OrderSnapshot)
 
 type Exchange struct {
@@ -48,9 +57,21 @@ type Exchange struct {
 	// 现货权益
 	spotBalanceMgr *common.BalanceMgr
 
-	// 现货订单更新的分发
+	// 现货订单更新的分发。trader只做归属检查，订单刷新由订单管理器按clientId分发
 	spotOrderSnapshotFns map[string] /*spot-symbol*/ OnOrderSnapshotFn
 	muSpotOSFn           sync.Mutex
+	orderMgr             *orderManager
+
+	// api key的签名方式，为空时自动识别
+	keyType binanceapi.KeyType
+
+	// 定时撤单
+	countdown futureCountdown
+}
+
+// 指定api key的签名方式(hmac/rsa/ed25519)，在Init之前调用
+func (e *Exchange) SetKeyType(keyType string) {
+	e.keyType = binanceapi.KeyType(strings.ToLower(keyType))
 }
 
 func (e *Exchange) Init(key, secret string, ecb func(e error)) {
@@ -65,10 +86,11 @@ func (e *Exchange) Init(key, secret string, ecb func(e error)) {
 	e.spotBalanceMgr = common.NewBalanceMgr(false)
 	e.instrumentMgr = common.NewInstrumentMgr(logPrefix)
 	e.spotOrderSnapshotFns = make(map[string]OnOrderSnapshotFn)
+	e.orderMgr = newOrderManager()
 
 	// 初始化api
 	logger.LogImportant(logPrefix, "init api...")
-	binanceapi.Init(key, secret, binancespotapi.ServerTs)
+	binanceapi.InitWithKeyType(key, secret, e.keyType, binancespotapi.ServerTs)
 	binanceapi.ErrorCallback = ecb
 
 	// 获取所有交易对列表
@@ -91,6 +113,7 @@ func (e *Exchange) Init(key, secret string, ecb func(e error)) {
 
 		// 订阅
 		e.wsSpot.SubscribeUserData(e.onWsAccountUpdate, e.onWsOrderUpdate)
+		e.orderMgr.start()
 	}
 
 	exchangeReady = true
@@ -202,10 +225,11 @@ func (e *Exchange) onWsOrderUpdate(msg interface{}) {
 	e.muSpotOSFn.Lock()
 	defer e.muSpotOSFn.Unlock()
 	ou := msg.(binanceapi.WSPayload_OrderUpdate)
+	os := NewOrderSnapshotFromWsResponse(ou)
 	if fn, ok := e.spotOrderSnapshotFns[ou.Symblo]; ok {
-		os := NewOrderSnapshotFromWsResponse(ou)
 		fn(os)
 	}
+	e.orderMgr.onSnapshot(os)
 }
 
 // 撤销所有订单
@@ -241,6 +265,50 @@ func (e *Exchange) CloseAllOrders() {
 	logger.LogImportant(logPrefix, "all open orders closed")
 }
 
+// 实现common.TaggedOrdersReconciler。按clientOrderId的标签前缀筛选，未设置订单标签时无法区分，返回错误
+func (e *Exchange) PendingTaggedOrders() ([]common.PendingOrder, error) {
+	prefix := clientOrderIdPrefix()
+	if len(prefix) == 0 {
+		return nil, errors.New("order tag not set")
+	}
+
+	resp, emsg, err := e.orderMgr.getOpenOrders("")
+	if err != nil {
+		return nil, newNetworkError(err)
+	} else if emsg != nil {
+		return nil, newExchangeError(*emsg)
+	}
+
+	orders := make([]common.PendingOrder, 0)
+	for _, os := range *resp {
+		if strings.HasPrefix(os.ClientOrderID, prefix) {
+			orders = append(orders, common.PendingOrder{
+				InstId:        os.Symbol,
+				OrderId:       strconv.FormatInt(os.OrderId, 10),
+				ClientOrderId: os.ClientOrderID,
+				Price:         os.Price,
+				Size:          os.Size,
+				Filled:        os.FilledSize,
+			})
+		}
+	}
+	return orders, nil
+}
+
+// 实现common.TaggedOrdersReconciler。现货没有批量撤单接口，逐个撤销
+func (e *Exchange) CancelPendingOrders(orders []common.PendingOrder) []common.OrderResult {
+	results := make([]common.OrderResult, len(orders))
+	for i, o := range orders {
+		resp, err := binancespotapi.CancelOrder(o.InstId, 0, o.ClientOrderId)
+		if err != nil {
+			results[i].Err = newNetworkError(err)
+		} else {
+			results[i].Err = newExchangeError(resp.ErrorMessage)
+		}
+	}
+	return results
+}
+
 // #region 实现common.CEx接口
 func (e *Exchange) Name() string {
 	return exchangeName
@@ -489,6 +557,7 @@ func GetKline(
 func (e *Exchange) Exit() {
 	// 这样会停止一切下单行为
 	exchangeReady = false
+	e.orderMgr.stop()
 
 	// 撤销所有订单
 	e.CloseAllOrders()
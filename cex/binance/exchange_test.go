@@ -0,0 +1,86 @@
+package binance
+
+// 替换订单管理器的rest请求，检查按订单标签筛选交易所端挂单
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aztecqt/dagger/api/binanceapi"
+	"github.com/shopspring/decimal"
+)
+
+func TestNewClientOrderIdTag(t *testing.T) {
+	defer func(name string) { StratergyName = name }(StratergyName)
+
+	cases := []struct {
+		tag    string
+		prefix string
+	}{
+		{"", ""},
+		{"grid1", "grid1_"},
+		{"my-long-strategy", "mylongst_"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			StratergyName = c.tag
+			id := NewClientOrderId("open long position")
+			if !strings.HasPrefix(id, c.prefix) || len(id) > 32 || strings.Contains(id[len(c.prefix):], "_") {
+				t.Fatalf("id = %s, want prefix %q", id, c.prefix)
+			}
+		})
+	}
+}
+
+func TestPendingTaggedOrders(t *testing.T) {
+	defer func(name string) { StratergyName = name }(StratergyName)
+
+	cases := []struct {
+		name string
+		tag  string
+		want string // 筛选出的clientOrderId，为error时应返回错误
+	}{
+		{"filtered by tag", "grid", "[grid_00001buy grid_00003sell]"},
+		{"tag is a prefix of another tag", "gri", "[]"},
+		{"no tag", "", "error"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			StratergyName = c.tag
+			m := newOrderManager()
+			defer m.stop()
+			rest := fakeOrderRest{open: map[string][]binanceapi.OrderStatus{"": {
+				orderStatus("BTCUSDT", "grid_00001buy", 1, 1, 0, binanceapi.OrderStatus_New),
+				orderStatus("BTCUSDT", "other_00002buy", 2, 1, 0, binanceapi.OrderStatus_New),
+				orderStatus("ETHUSDT", "grid_00003sell", 3, 2, 0.5, binanceapi.OrderStatus_PartiallyFilled),
+				orderStatus("ETHUSDT", "web_abc", 4, 1, 0, binanceapi.OrderStatus_New),
+			}}}
+			rest.install(m)
+
+			e := &Exchange{orderMgr: m}
+			orders, err := e.PendingTaggedOrders()
+			if c.want == "error" {
+				if err == nil {
+					t.Fatalf("no error, orders %v", orders)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			cids := []string{}
+			for _, o := range orders {
+				cids = append(cids, o.ClientOrderId)
+			}
+			if fmt.Sprint(cids) != c.want {
+				t.Fatalf("orders %v, want %s", cids, c.want)
+			}
+			if len(orders) == 2 && (orders[1].InstId != "ETHUSDT" || orders[1].OrderId != "3" || !orders[1].Size.Equal(decimal.NewFromInt(2)) || !orders[1].Filled.Equal(decimal.NewFromFloat(0.5))) {
+				t.Fatalf("order %+v", orders[1])
+			}
+		})
+	}
+}
@@ -92,7 +92,15 @@ func CCyCttypeToInstId(symbol, contractType string) string {
 
 var accClientOrderId int32
 
+// 设置了订单标签时，以"标签_"开头
 func NewClientOrderId(purpose string) string {
 	newId := atomic.AddInt32(&accClientOrderId, 1)
-	return util.ToLetterNumberOnly(fmt.Sprintf("%05d%s", newId, purpose), 32)
+	return clientOrderIdPrefix() + util.ToLetterNumberOnly(fmt.Sprintf("%05d%s", newId, purpose), 32-len(clientOrderIdPrefix()))
+}
+
+func clientOrderIdPrefix() string {
+	if tag := orderTag(); len(tag) > 0 {
+		return tag + "_"
+	}
+	return ""
 }
@@ -0,0 +1,230 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 22:41:07
+ * @Description: 币安现货订单管理器。每个Exchange一个，持有所有存活订单，取代每个订单各自的刷新协程
+ * ws订单推送按clientId分发给订单；长时间没有推送或需要立即刷新的订单，按交易对批量拉取当前挂单对账，
+ * 不在挂单列表中的订单再逐个查询。同时检查卡住的订单和未知订单
+ * 不带symbol的openOrders权重很高，所以只拉取有订单需要对账的交易对
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package binance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/api/binanceapi"
+	"github.com/aztecqt/dagger/api/binanceapi/binancespotapi"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+)
+
+const (
+	orderRefreshTimeout     = time.Second * 10 // 超过这个时间没有收到推送，需要rest对账
+	orderStuckTimeout       = time.Minute      // 超过这个时间仍未确认订单id，视为卡住
+	orderQueryLimitPerRound = 10               // 每轮最多单独查询的订单数，其余留到下一轮
+	orderUnknownConfirm     = 3                // 未知订单连续出现这么多轮才报告
+)
+
+type managedOrder struct {
+	o           *SpotOrder
+	addTime     time.Time
+	creating    bool      // 正在创建，创建结束前不对账
+	refreshTime time.Time // 最近一次收到推送或发起对账的时间
+	refreshImm  bool      // 需要立即对账
+	stuckLogged time.Time
+}
+
+type orderManager struct {
+	logPrefix string
+	orders    map[string] /*clientId*/ *managedOrder
+	mu        sync.Mutex
+
+	// 订单的创建、改单、查询请求都派生自这个ctx，退出时取消
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// 只在对账协程中访问
+	unknown map[string] /*symbol*/ map[string] /*clientId*/ int
+
+	// 对账用到的rest请求，默认使用binancespotapi，测试时替换
+	getOpenOrders func(symbol string) (*binanceapi.GetOpenOrdersResponse, *binanceapi.ErrorMessage, error)
+	getOrder      func(symbol, clientId string) (*binanceapi.GetOrderResponse, error)
+}
+
+func newOrderManager() *orderManager {
+	m := new(orderManager)
+	m.logPrefix = fmt.Sprintf("%s-OrderMgr", logPrefix)
+	m.orders = make(map[string]*managedOrder)
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.unknown = make(map[string]map[string]int)
+	m.getOpenOrders = binancespotapi.GetOpenOrders
+	m.getOrder = func(symbol, clientId string) (*binanceapi.GetOrderResponse, error) {
+		return binancespotapi.GetOrderCtx(m.ctx, symbol, 0, clientId)
+	}
+	return m
+}
+
+func (m *orderManager) start() {
+	go m.update()
+}
+
+// 停止对账，并取消进行中的订单请求
+func (m *orderManager) stop() {
+	m.cancel()
+}
+
+// 登记订单并启动创建。订单结束后自动移除
+func (m *orderManager) add(o *SpotOrder) {
+	now := time.Now()
+	mo := &managedOrder{o: o, addTime: now, refreshTime: now, creating: o.OrderId == 0}
+
+	m.mu.Lock()
+	m.orders[o.CltOrderId.(string)] = mo
+	m.mu.Unlock()
+
+	if mo.creating {
+		go m.create(mo)
+	}
+}
+
+func (m *orderManager) create(mo *managedOrder) {
+	mo.o.create()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mo.creating = false
+	mo.refreshTime = time.Now()
+}
+
+// 下一轮立即对账
+func (m *orderManager) refreshImm(clientId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mo, ok := m.orders[clientId]; ok {
+		mo.refreshImm = true
+	}
+}
+
+// ws订单推送，按clientId分发
+func (m *orderManager) onSnapshot(os OrderSnapshot) {
+	m.mu.Lock()
+	mo, ok := m.orders[os.ClientOrderID]
+	if ok {
+		mo.refreshTime = time.Now()
+	}
+	m.mu.Unlock()
+
+	if ok {
+		mo.o.onSnapshot(os)
+	}
+}
+
+func (m *orderManager) update() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.reconcile()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// 一轮对账
+func (m *orderManager) reconcile() {
+	defer util.DefaultRecover()
+
+	now := time.Now()
+	due := make(map[string] /*symbol*/ []*managedOrder)
+	m.mu.Lock()
+	for cid, mo := range m.orders {
+		if mo.o.IsFinished() {
+			delete(m.orders, cid)
+		} else if !mo.creating && (mo.refreshImm || now.Sub(mo.refreshTime) >= orderRefreshTimeout) {
+			due[mo.o.InstId] = append(due[mo.o.InstId], mo)
+		}
+	}
+	m.mu.Unlock()
+
+	queries := make([]*managedOrder, 0)
+	for symbol, mos := range due {
+		resp, emsg, err := m.getOpenOrders(symbol)
+		if err != nil {
+			logger.LogImportant(m.logPrefix, "get open orders of %s failed: %s", symbol, err.Error())
+			continue // 下一轮重试
+		} else if emsg != nil {
+			logger.LogImportant(m.logPrefix, "get open orders of %s failed, code=%d, msg=%s", symbol, emsg.Code, emsg.Message)
+			continue
+		}
+
+		localTime := time.Now()
+		open := make(map[string]binanceapi.OrderStatus)
+		for _, os := range *resp {
+			open[os.ClientOrderID] = os
+		}
+
+		for _, mo := range mos {
+			m.mu.Lock()
+			mo.refreshImm = false
+			mo.refreshTime = now
+			m.mu.Unlock()
+
+			if os, ok := open[mo.o.CltOrderId.(string)]; ok {
+				mo.o.onSnapshot(NewOrderSnapShotFromRestResponse(binanceapi.GetOrderResponse{OrderStatus: os, LocalTime: localTime}))
+			} else {
+				// 已结束或尚未被交易所受理
+				queries = append(queries, mo)
+			}
+		}
+
+		m.checkUnknown(symbol, open)
+	}
+
+	for i, mo := range queries {
+		if i < orderQueryLimitPerRound {
+			mo.o.doRestRefresh()
+		} else {
+			m.refreshImm(mo.o.CltOrderId.(string))
+		}
+	}
+
+	m.checkStuck(now)
+}
+
+// 交易所有、本地没有的订单。连续出现多轮后报告一次
+func (m *orderManager) checkUnknown(symbol string, open map[string]binanceapi.OrderStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev := m.unknown[symbol]
+	cur := make(map[string]int)
+	for cid, os := range open {
+		if _, ok := m.orders[cid]; ok {
+			continue
+		}
+
+		cur[cid] = prev[cid] + 1
+		if cur[cid] == orderUnknownConfirm {
+			logger.LogImportant(m.logPrefix, "unknown order on exchange: symbol=%s, id=%d, cid=%s, px=%v, sz=%v", symbol, os.OrderId, cid, os.Price, os.Size)
+		}
+	}
+	m.unknown[symbol] = cur
+}
+
+// 创建结束后长时间拿不到订单id的订单。每分钟报告一次
+func (m *orderManager) checkStuck(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for cid, mo := range m.orders {
+		if mo.o.OrderId == 0 && !mo.o.IsFinished() && now.Sub(mo.addTime) > orderStuckTimeout && now.Sub(mo.stuckLogged) > orderStuckTimeout {
+			mo.stuckLogged = now
+			logger.LogImportant(m.logPrefix, "order stuck for %v, creating=%v, cid=%s, o=%s", now.Sub(mo.addTime).Truncate(time.Second), mo.creating, cid, mo.o.String())
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package binance
+
+// 替换订单管理器的rest请求，直接调用reconcile，检查对账和遗漏订单的处理
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aztecqt/dagger/api/binanceapi"
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+func TestMain(m *testing.M) {
+	logger.Setup(logger.NewStdoutSink(logger.ConsoleEncoder{}, logger.LogLevel_Important))
+	os.Exit(m.Run())
+}
+
+// 交易所端的假数据
+type fakeOrderRest struct {
+	open    map[string] /*symbol*/ []binanceapi.OrderStatus
+	openErr error
+	orders  map[string]binanceapi.OrderStatus // 单独查询的结果，不在其中的返回订单不存在
+	symbols []string                          // 拉取过挂单的交易对
+	queried []string
+}
+
+func (f *fakeOrderRest) install(m *orderManager) {
+	m.getOpenOrders = func(symbol string) (*binanceapi.GetOpenOrdersResponse, *binanceapi.ErrorMessage, error) {
+		f.symbols = append(f.symbols, symbol)
+		if f.openErr != nil {
+			return nil, nil, f.openErr
+		}
+		resp := binanceapi.GetOpenOrdersResponse(f.open[symbol])
+		return &resp, nil, nil
+	}
+	m.getOrder = func(symbol, clientId string) (*binanceapi.GetOrderResponse, error) {
+		f.queried = append(f.queried, clientId)
+		resp := &binanceapi.GetOrderResponse{LocalTime: time.Now()}
+		if os, ok := f.orders[clientId]; ok {
+			resp.OrderStatus = os
+		} else {
+			resp.Code = -2013
+			resp.Message = "Order does not exist."
+		}
+		return resp, nil
+	}
+}
+
+func orderStatus(symbol, cid string, id int64, size, filled float64, status string) binanceapi.OrderStatus {
+	return binanceapi.OrderStatus{
+		Symbol:           symbol,
+		OrderId:          id,
+		ClientOrderID:    cid,
+		Status:           status,
+		RefreshTimestamp: time.Now().UnixMilli(),
+		Price:            decimal.NewFromInt(100),
+		Size:             decimal.NewFromFloat(size),
+		FilledSize:       decimal.NewFromFloat(filled),
+	}
+}
+
+// 登记一个已创建、等待对账的订单
+func addTestOrder(m *orderManager, symbol, cid string, id int64, size float64) *SpotOrder {
+	o := new(SpotOrder)
+	o.LogPrefix = "test-" + cid
+	o.InstId = symbol
+	o.CltOrderId = cid
+	o.OrderId = id
+	o.Price = decimal.NewFromInt(100)
+	o.Size = decimal.NewFromFloat(size)
+	o.Dir = common.OrderDir_Buy
+	o.mgr = m
+
+	m.add(o)
+	m.orders[cid].refreshTime = time.Now().Add(-orderRefreshTimeout)
+	return o
+}
+
+func TestOrderManagerReconcile(t *testing.T) {
+	type want struct {
+		finished bool
+		fatal    bool
+		filled   float64
+		errCount int
+	}
+
+	cases := []struct {
+		name    string
+		rest    fakeOrderRest
+		want    want
+		queried []string
+	}{
+		{
+			name: "open order updated from list",
+			rest: fakeOrderRest{open: map[string][]binanceapi.OrderStatus{
+				"BTCUSDT": {orderStatus("BTCUSDT", "a", 1, 2, 0.5, binanceapi.OrderStatus_PartiallyFilled)},
+			}},
+			want: want{filled: 0.5},
+		},
+		{
+			name:    "missing order queried and finished",
+			rest:    fakeOrderRest{orders: map[string]binanceapi.OrderStatus{"a": orderStatus("BTCUSDT", "a", 1, 2, 2, binanceapi.OrderStatus_Filled)}},
+			want:    want{finished: true, filled: 2},
+			queried: []string{"a"},
+		},
+		{
+			// 刚创建的订单可能短时间查不到，不能一次就判死
+			name:    "missing order not found once",
+			want:    want{errCount: 1},
+			queried: []string{"a"},
+		},
+		{
+			name: "open orders failure leaves order untouched",
+			rest: fakeOrderRest{openErr: errors.New("timeout")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newOrderManager()
+			defer m.stop()
+			rest := c.rest
+			rest.install(m)
+
+			o := addTestOrder(m, "BTCUSDT", "a", 1, 2)
+			m.reconcile()
+
+			w := c.want
+			if o.Finished != w.finished || o.FatalError != w.fatal || !o.Filled.Equal(decimal.NewFromFloat(w.filled)) || o.restRefreshErrorCount != w.errCount {
+				t.Errorf("finished=%v fatal=%v filled=%v errCount=%d, want %+v", o.Finished, o.FatalError, o.Filled, o.restRefreshErrorCount, w)
+			}
+
+			if fmt.Sprint(rest.queried) != fmt.Sprint(c.queried) {
+				t.Errorf("queried %v, want %v", rest.queried, c.queried)
+			}
+		})
+	}
+}
+
+func TestOrderManagerMissingNotFound(t *testing.T) {
+	m := newOrderManager()
+	defer m.stop()
+	rest := fakeOrderRest{}
+	rest.install(m)
+
+	// 连续3次查不到才结束订单
+	o := addTestOrder(m, "BTCUSDT", "a", 1, 2)
+	for i := 1; i <= 3; i++ {
+		m.orders["a"].refreshImm = true
+		m.reconcile()
+		if o.FatalError != (i == 3) {
+			t.Fatalf("round %d: fatal=%v", i, o.FatalError)
+		}
+	}
+
+	m.reconcile()
+	if _, ok := m.orders["a"]; ok {
+		t.Fatalf("finished order not removed")
+	}
+}
+
+func TestOrderManagerDueSymbols(t *testing.T) {
+	m := newOrderManager()
+	defer m.stop()
+	rest := fakeOrderRest{open: map[string][]binanceapi.OrderStatus{
+		"BTCUSDT": {orderStatus("BTCUSDT", "a", 1, 1, 0, binanceapi.OrderStatus_New)},
+		"ETHUSDT": {orderStatus("ETHUSDT", "b", 2, 1, 0, binanceapi.OrderStatus_New)},
+	}}
+	rest.install(m)
+
+	// 只拉取有订单需要对账的交易对
+	addTestOrder(m, "BTCUSDT", "a", 1, 1)
+	addTestOrder(m, "ETHUSDT", "b", 2, 1)
+	m.orders["b"].refreshTime = time.Now()
+	m.reconcile()
+
+	if fmt.Sprint(rest.symbols) != "[BTCUSDT]" {
+		t.Fatalf("fetched %v, want [BTCUSDT]", rest.symbols)
+	}
+	if len(rest.queried) != 0 {
+		t.Fatalf("queried %v, want none", rest.queried)
+	}
+}
+
+func TestOrderManagerQueryLimit(t *testing.T) {
+	m := newOrderManager()
+	defer m.stop()
+	rest := fakeOrderRest{orders: map[string]binanceapi.OrderStatus{}}
+	rest.install(m)
+
+	n := orderQueryLimitPerRound + 3
+	for i := 0; i < n; i++ {
+		cid := fmt.Sprintf("o%d", i)
+		addTestOrder(m, "BTCUSDT", cid, int64(i+1), 1)
+		rest.orders[cid] = orderStatus("BTCUSDT", cid, int64(i+1), 1, 0, binanceapi.OrderStatus_New)
+	}
+
+	// 超出单轮查询上限的订单留到下一轮立即对账
+	m.reconcile()
+	if len(rest.queried) != orderQueryLimitPerRound {
+		t.Fatalf("queried %d orders, want %d", len(rest.queried), orderQueryLimitPerRound)
+	}
+
+	m.reconcile()
+	if len(rest.queried) != n {
+		t.Fatalf("queried %d orders after 2 rounds, want %d", len(rest.queried), n)
+	}
+}
+
+func TestOrderManagerUnknown(t *testing.T) {
+	cases := []struct {
+		name   string
+		rounds [][]string // 每轮交易所上的挂单
+		want   map[string]int
+	}{
+		{"counted per round", [][]string{{"x"}, {"x"}}, map[string]int{"x": 2}},
+		{"reset when gone", [][]string{{"x", "y"}, {"y"}, {"x", "y"}}, map[string]int{"x": 1, "y": 3}},
+		{"local orders ignored", [][]string{{"local"}, {"local"}}, map[string]int{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newOrderManager()
+			defer m.stop()
+			addTestOrder(m, "BTCUSDT", "local", 1, 1)
+
+			for _, round := range c.rounds {
+				open := map[string]binanceapi.OrderStatus{}
+				for _, cid := range round {
+					open[cid] = orderStatus("BTCUSDT", cid, 100, 1, 0, binanceapi.OrderStatus_New)
+				}
+				m.checkUnknown("BTCUSDT", open)
+			}
+
+			if fmt.Sprint(m.unknown["BTCUSDT"]) != fmt.Sprint(c.want) {
+				t.Fatalf("unknown = %v, want %v", m.unknown["BTCUSDT"], c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 09:36:52
+ * @Description: 注册到交易所注册表。目前没有交易所配置
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package binance
+
+import (
+	"github.com/aztecqt/dagger/api/binanceapi"
+	"github.com/aztecqt/dagger/cex/common"
+)
+
+const RegistryName = "binance"
+
+type ExchangeConfig struct{}
+
+func init() {
+	common.RegisterExchange(
+		RegistryName,
+		common.Credential_KeySecret,
+		true,
+		func() ExchangeConfig { return ExchangeConfig{} },
+		func(cfg ExchangeConfig, cred common.Credential, opt common.ExchangeOptions) (common.CEx, error) {
+			if len(opt.OrderTag) > 0 {
+				StratergyName = opt.OrderTag
+			}
+			if opt.ProxySelector != nil {
+				binanceapi.SetProxySelector(opt.ProxySelector)
+			}
+			ex := new(Exchange)
+			ex.SetKeyType(cred.KeyType)
+			ex.Init(cred.Key, cred.Secret, opt.ErrorNotifier)
+			return ex, nil
+		})
+}
@@ -10,11 +10,13 @@ package binance
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/aztecqt/dagger/util/logger"
 
+	"github.com/aztecqt/dagger/api"
 	"github.com/aztecqt/dagger/api/binanceapi"
 	"github.com/aztecqt/dagger/api/binanceapi/binancespotapi"
 	"github.com/aztecqt/dagger/cex/common"
@@ -30,10 +32,9 @@ type SpotOrder struct {
 	refreshCount          int  // 刷新次数
 	restRefreshErrorCount int  // rest调用错误次数
 
-	// 刷新
-	muRefresh        sync.Mutex
-	tkRefreshTimeout *time.Ticker
-	chRefreshImm     chan int
+	// 刷新。创建和对账由订单管理器统一调度
+	muRefresh sync.Mutex
+	mgr       *orderManager
 }
 
 // 初始化
@@ -44,6 +45,7 @@ func (o *SpotOrder) Init(
 	makeOnly bool,
 	purpose string) bool {
 	o.CltOrderId = NewClientOrderId(purpose)
+	o.mgr = trader.exchange.orderMgr
 	return o.OrderImpl.Init(
 		trader,
 		trader.exchange.instrumentMgr,
@@ -57,8 +59,12 @@ func (o *SpotOrder) Init(
 }
 
 func (o *SpotOrder) Go() {
-	o.tkRefreshTimeout = time.NewTicker(time.Second * 10)
-	go o.update()
+	o.mgr.add(o)
+}
+
+// 订单日志，带有交易所、交易对、自定义订单Id字段
+func (o *SpotOrder) log() *slog.Logger {
+	return o.Logger(exchangeName)
 }
 
 // #region 实现common.Order
@@ -101,30 +107,33 @@ func (o *SpotOrder) create() {
 		side = "SELL"
 	}
 
-	logger.LogInfo(o.LogPrefix, "creating [%s]", o.String())
-	resp, err := binancespotapi.MakeOrder(o.InstId, side, "LIMIT", o.CltOrderId.(string), o.Price, o.Size)
+	o.log().Info("creating", "order", o.String())
+	t0 := time.Now()
+	resp, err := binancespotapi.MakeOrderCtx(o.mgr.ctx, o.InstId, side, "LIMIT", o.CltOrderId.(string), o.Price, o.Size)
+	api.RecordOrderOp(exchangeName, "rest", "order", time.Since(t0), err == nil && resp.Code == 0 && len(resp.Message) == 0)
 	if err == nil {
 		if resp.Code == 0 && len(resp.Message) == 0 {
 			if resp.OrderID > 0 {
 				// 创建成功
 				o.OrderId = resp.OrderID
-				logger.LogInfo(o.LogPrefix, "create success, order id = %v", o.OrderId)
+				o.log().Info("create success", "orderId", o.OrderId)
 			} else {
 				// 订单id缺失，应该是不会出现这种情况
 				o.ErrMsg = "create success but missing order id"
 				o.FatalError = true
-				logger.LogImportant(o.LogPrefix, "create order error, missing order id ")
+				o.log().Warn("create order error, missing order id")
 			}
 		} else {
 			// 订单创建失败
-			o.ErrMsg = fmt.Sprintf("create failed, code=%d, msg=%s", resp.Code, resp.Message)
+			o.SetError(newExchangeError(resp.ErrorMessage))
 			o.FatalError = true
-			logger.LogImportant(o.LogPrefix, "create order error: %s", o.ErrMsg)
+			o.log().Warn("create order error", "err", o.ErrMsg)
 		}
 	} else {
 		// 网络错误不代表订单未创建成功
 		// 应该查询时返回“订单不存在”作为订单错误的触发条件
-		logger.LogImportant(o.LogPrefix, "create order with rest error: %s", err.Error())
+		o.SetError(newNetworkError(err))
+		o.log().Warn("create order with rest error", "err", err)
 	}
 }
 
@@ -138,18 +147,20 @@ func (o *SpotOrder) cancel() {
 			o.canceling = false
 		}()
 
-		logger.LogInfo(o.LogPrefix, "canceling [%s]", o.String())
+		o.log().Info("canceling", "order", o.String())
+		t0 := time.Now()
 		resp, err := binancespotapi.CancelOrder(o.InstId, 0, o.CltOrderId.(string))
+		api.RecordOrderOp(exchangeName, "rest", "cancel-order", time.Since(t0), err == nil && resp.Code == 0 && len(resp.Message) == 0)
 		if err == nil {
 			if resp.Code != 0 || len(resp.Message) > 0 {
-				o.ErrMsg = fmt.Sprintf("code:%d, msg:%s", resp.Code, resp.Message)
-				logger.LogImportant(o.LogPrefix, "cancel order error: %s", o.ErrMsg)
+				o.SetError(newExchangeError(resp.ErrorMessage))
+				o.log().Warn("cancel order error", "err", o.ErrMsg)
 				time.Sleep(time.Second)
 			} else {
-				logger.LogInfo(o.LogPrefix, "cancel responsed")
+				o.log().Info("cancel responsed")
 			}
 		} else {
-			logger.LogImportant(o.LogPrefix, "cancel order with rest error: %s", err.Error())
+			o.log().Warn("cancel order with rest error", "err", err)
 			time.Sleep(time.Second)
 		}
 	}
@@ -157,7 +168,6 @@ func (o *SpotOrder) cancel() {
 
 // 刷新订单
 func (o *SpotOrder) onSnapshot(os OrderSnapshot) {
-	o.tkRefreshTimeout.Reset(time.Second * 10)
 	defer util.DefaultRecover()
 
 	deal := common.Deal{}
@@ -177,7 +187,7 @@ func (o *SpotOrder) onSnapshot(os OrderSnapshot) {
 		}
 
 		// 刷新数据
-		logger.LogInfo(o.LogPrefix, "recv order snapshot:%s", os.String())
+		o.log().Info("recv order snapshot", "snapshot", os.String())
 		if os.UpdateTime.UnixMilli() >= o.UpdateTime.UnixMilli() && os.FilledSize.GreaterThanOrEqual(o.Filled) {
 
 			deal = common.Deal{O: o, LocalTime: os.LocalTime, UTime: os.UpdateTime}
@@ -204,6 +214,7 @@ func (o *SpotOrder) onSnapshot(os OrderSnapshot) {
 					common.OrderDir2Str(o.Dir), deal.Price, deal.Amount, deal.UTime)
 
 				// 回调外部
+				common.RecordDeal(exchangeName, deal)
 				for _, obs := range o.Observers {
 					if obs != nil {
 						obs.OnDeal(deal)
@@ -215,9 +226,9 @@ func (o *SpotOrder) onSnapshot(os OrderSnapshot) {
 			finished := o.Status == binanceapi.OrderStatus_Canceled || o.Status == binanceapi.OrderStatus_Filled
 			if !o.Finished && finished {
 				o.Finished = finished
-				logger.LogInfo(o.LogPrefix, "order finished")
+				o.log().Info("order finished")
 			} else if o.Finished && !finished {
-				logger.LogImportant(o.LogPrefix, "order already finished but try set to unfinished? impossible!")
+				o.log().Warn("order already finished but try set to unfinished? impossible!")
 			}
 
 			o.refreshCount++
@@ -227,48 +238,35 @@ func (o *SpotOrder) onSnapshot(os OrderSnapshot) {
 
 // 立即刷新订单
 func (o *SpotOrder) refreshImm() {
-	o.chRefreshImm <- 0
+	o.mgr.refreshImm(o.CltOrderId.(string))
 }
 
 func (o *SpotOrder) doRestRefresh() {
-	logger.LogInfo(o.LogPrefix, "geting order info from rest...")
-	resp, err := binancespotapi.GetOrder(o.InstId, 0, o.CltOrderId.(string))
+	o.log().Info("geting order info from rest...")
+	resp, err := o.mgr.getOrder(o.InstId, o.CltOrderId.(string))
 	b, _ := json.Marshal(resp)
-	logger.LogInfo(o.LogPrefix, "getted order info from rest, resp=%s", string(b))
+	o.log().Info("getted order info from rest", "resp", string(b))
 	if err == nil {
 		if resp.Code == 0 && len(resp.Message) == 0 {
 			os := NewOrderSnapShotFromRestResponse(*resp)
 			o.onSnapshot(os)
 		} else {
-			// 其他错误连续出现3次则认为订单异常，强制结束
-			o.restRefreshErrorCount++
-			if o.restRefreshErrorCount >= 3 {
-				o.ErrMsg = fmt.Sprintf("code:%d, msg:%s", resp.Code, resp.Message)
-				o.FatalError = true
+			e := newExchangeError(resp.ErrorMessage)
+			o.SetError(e)
+			switch common.ErrorCategoryOf(e) {
+			case common.ErrorCategory_RateLimited, common.ErrorCategory_Network:
+				// 与订单本身无关，下次对账再查
+			default:
+				// 其他错误（包括订单不存在）连续出现3次则认为订单异常，强制结束
+				// 刚创建的订单可能短时间内查不到，不能一次就判死
+				o.restRefreshErrorCount++
+				if o.restRefreshErrorCount >= 3 {
+					o.FatalError = true
+				}
 			}
 		}
-	}
-}
-
-func (o *SpotOrder) update() {
-	defer logger.LogInfo(o.LogPrefix, "update exit")
-
-	// go o.create()
-	o.create()
-
-	tkRepeat := time.NewTicker(time.Second)
-	for {
-		if o.IsFinished() {
-			break
-		}
-
-		select {
-		case <-o.chRefreshImm:
-			o.doRestRefresh()
-		case <-o.tkRefreshTimeout.C:
-			o.doRestRefresh()
-		case <-tkRepeat.C:
-		}
+	} else {
+		o.SetError(newNetworkError(err))
 	}
 }
 
@@ -51,22 +51,12 @@ func (t *SpotTrader) Init(ex *Exchange, stratergyId int, m *SpotMarket) {
 	t.quoteBalance = ex.spotBalanceMgr.FindBalance(t.market.QuoteCurrency())
 
 	// 订阅order信息
+	// 订单本身的刷新由订单管理器负责，这里只检查订单归属
 	ex.RegSpotOrderSnapshot(m.instId, func(os OrderSnapshot) {
-		var o *SpotOrder = nil
-		var ok bool = false
-
 		if os.StratergyId > 0 && os.StratergyId != stratergyId {
 			t.errorlock = true
 			logger.LogPanic(t.logPrefix, "found order from other stratergy!")
 		}
-
-		t.muOrders.RLock()
-		o, ok = t.orders[os.ClientOrderID]
-		t.muOrders.RUnlock()
-
-		if ok {
-			o.onSnapshot(os)
-		}
 	})
 
 	// 清理finished orders
@@ -191,6 +181,19 @@ func (t *SpotTrader) MakeOrder(
 	}
 }
 
+// 币安现货没有批量下单/改单接口，逐个执行
+func (t *SpotTrader) MakeOrders(reqs []common.OrderRequest) []common.OrderResult {
+	return common.MakeOrdersOneByOne(t, reqs)
+}
+
+func (t *SpotTrader) ModifyOrders(reqs []common.OrderModifyRequest) []common.OrderResult {
+	return common.ModifyOrdersOneByOne(reqs)
+}
+
+func (t *SpotTrader) CancelOrders(orders []common.Order) []common.OrderResult {
+	return common.CancelOrdersOneByOne(orders)
+}
+
 func (t *SpotTrader) Orders() []common.Order {
 	orders := make([]common.Order, 0, len(t.orders))
 
@@ -0,0 +1,137 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 19:34:52
+ * @Description: 币安现货的逐笔成交数据源。实现common.TradeFeed
+ * 实时数据来自aggTrade频道。归集成交id是连续的，出现跳号即说明有遗漏，通知使用者补全
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package binance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aztecqt/dagger/api/binanceapi"
+	"github.com/aztecqt/dagger/api/binanceapi/binancespotapi"
+	"github.com/aztecqt/dagger/api/binanceapi/cachedbn"
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+// 补全成交时最多请求多少次（每次1000条）
+const tradeFeedMaxFetchPages = 20
+
+type TradeFeed struct {
+	ws        *binancespotapi.WsClient
+	instId    string
+	logPrefix string
+	lastId    int64
+}
+
+func (e *Exchange) NewTradeFeed(instId string) *TradeFeed {
+	f := new(TradeFeed)
+	f.ws = e.wsSpot
+	f.instId = instId
+	f.logPrefix = fmt.Sprintf("%s-TradeFeed-%s", logPrefix, instId)
+	return f
+}
+
+func (f *TradeFeed) InstId() string {
+	return f.instId
+}
+
+func (f *TradeFeed) Start(fnTrade func(common.MarketTrade), fnGap func()) {
+	f.ws.SubscribeAggTrade(f.instId, func(resp interface{}) {
+		at := resp.(*binanceapi.WSPayload_AggTrade)
+		if f.lastId > 0 && at.Id > f.lastId+1 {
+			logger.LogImportant(f.logPrefix, "agg trade id jumped from %d to %d", f.lastId, at.Id)
+			fnGap()
+		}
+
+		if at.Id > f.lastId {
+			f.lastId = at.Id
+		}
+
+		fnTrade(f.convert(at.Id, at.TradeTime, at.Price, at.Quantity, at.IsSell))
+	})
+
+	logger.LogImportant(f.logPrefix, "started")
+}
+
+func (f *TradeFeed) Stop() {
+	f.ws.UnsubscribeAggTrade(f.instId)
+	logger.LogImportant(f.logPrefix, "stopped")
+}
+
+func (f *TradeFeed) Connected() bool {
+	return f.ws.AggTradeConnected(f.instId)
+}
+
+func (f *TradeFeed) FetchTrades(afterId int64, t1 time.Time) ([]common.MarketTrade, bool) {
+	trades := make([]common.MarketTrade, 0)
+	fromId := afterId + 1
+	for page := 0; page < tradeFeedMaxFetchPages; page++ {
+		resp, err := binancespotapi.GetMarketTrades(f.instId, time.Time{}, time.Time{}, fromId, 1000)
+		if err != nil {
+			logger.LogImportant(f.logPrefix, "fetch trades failed: %s", err.Error())
+			return nil, false
+		} else if len(*resp) == 0 {
+			return trades, true
+		}
+
+		for _, mt := range *resp {
+			if mt.Timestamp > t1.UnixMilli() {
+				return trades, true
+			}
+			trades = append(trades, f.convert(mt.Id, mt.Timestamp, mt.Price, mt.Quantity, mt.IsSell))
+		}
+
+		if len(*resp) < 1000 {
+			return trades, true
+		}
+		fromId = (*resp)[len(*resp)-1].Id + 1
+	}
+
+	logger.LogImportant(f.logPrefix, "fetch trades: too many trades after id %d, %d fetched", afterId, len(trades))
+	return trades, false
+}
+
+func (f *TradeFeed) GetKline(t0, t1 time.Time, intervalSec int) ([]common.KUnit, bool) {
+	kusRaw, ok := cachedbn.GetSpotKline(f.instId, t0, t1, intervalSec, nil)
+	if !ok {
+		return nil, false
+	}
+
+	kus := make([]common.KUnit, 0, len(kusRaw))
+	for _, ku := range kusRaw {
+		kus = append(
+			kus,
+			common.KUnit{
+				Time:         ku.Time,
+				OpenPrice:    ku.Open,
+				ClosePrice:   ku.Close,
+				HighestPrice: ku.High,
+				LowestPrice:  ku.Low,
+				VolumeUSD:    ku.VolumeUSD,
+			})
+	}
+	return kus, true
+}
+
+func (f *TradeFeed) convert(id, ms int64, px, sz decimal.Decimal, isSell bool) common.MarketTrade {
+	t := common.MarketTrade{
+		Id:    id,
+		Time:  time.UnixMilli(ms),
+		Price: px,
+		Size:  sz,
+		Value: px.Mul(sz),
+		Dir:   common.OrderDir_Buy,
+	}
+
+	if isSell {
+		t.Dir = common.OrderDir_Sell
+	}
+
+	return t
+}
@@ -0,0 +1,91 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 21:02:37
+ * @Description: 批量下单/改单/撤单的请求和结果定义，以及不支持批量接口时的逐个执行
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package common
+
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrOrderInvalid = errors.New("order not passed local validation")
+var ErrTraderNotReady = errors.New("trader not ready")
+var ErrOrderFinished = errors.New("order already finished")
+var ErrModifyNotSupported = errors.New("modify not supported")
+
+// 批量下单请求
+type OrderRequest struct {
+	Price      decimal.Decimal
+	Amount     decimal.Decimal
+	Dir        OrderDir
+	MakeOnly   bool
+	ReduceOnly bool
+	Purpose    string
+	Observer   OrderObserver
+}
+
+// 批量改单请求。不修改的填0
+type OrderModifyRequest struct {
+	O        Order
+	NewPrice decimal.Decimal
+	NewSize  decimal.Decimal
+}
+
+// 批量操作中单个订单的结果
+// Err不为nil表示该操作明确失败；为nil表示交易所已接受或结果未知（如网络错误），订单最终状态以订单对象为准
+type OrderResult struct {
+	O   Order
+	Err error
+}
+
+// 逐个下单。用于不支持批量下单的交易所
+func MakeOrdersOneByOne(t CommonTrader, reqs []OrderRequest) []OrderResult {
+	results := make([]OrderResult, len(reqs))
+	for i, r := range reqs {
+		if !t.Ready() {
+			results[i].Err = ErrTraderNotReady
+			continue
+		}
+
+		results[i].O = t.MakeOrder(r.Price, r.Amount, r.Dir, r.MakeOnly, r.ReduceOnly, r.Purpose, r.Observer)
+		if results[i].O == nil {
+			results[i].Err = ErrOrderInvalid
+		}
+	}
+	return results
+}
+
+// 逐个改单
+func ModifyOrdersOneByOne(reqs []OrderModifyRequest) []OrderResult {
+	results := make([]OrderResult, len(reqs))
+	for i, r := range reqs {
+		results[i].O = r.O
+		if r.O.IsFinished() {
+			results[i].Err = ErrOrderFinished
+		} else if !r.O.IsSupportModify() {
+			results[i].Err = ErrModifyNotSupported
+		} else {
+			r.O.Modify(r.NewPrice, r.NewSize)
+		}
+	}
+	return results
+}
+
+// 逐个撤单
+func CancelOrdersOneByOne(orders []Order) []OrderResult {
+	results := make([]OrderResult, len(orders))
+	for i, o := range orders {
+		results[i].O = o
+		if o.IsFinished() {
+			results[i].Err = ErrOrderFinished
+		} else {
+			o.Cancel()
+		}
+	}
+	return results
+}
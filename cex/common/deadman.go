@@ -0,0 +1,159 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 21:58:16
+ * @Description: 死亡开关。策略进程卡死或断网时，撤销其挂单，避免订单无人看管
+ * 交易所支持定时撤单时（如okx的cancel-all-after、币安合约的countdownCancelAll），由心跳续期交易所端的倒计时；
+ * 不支持时，改用本地看门狗，心跳超时后调用CloseAllOrders
+ * 心跳由框架主循环驱动，进程卡死、退出或断网时开关触发
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package common
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+)
+
+// 支持交易所端定时撤单的交易所实现此接口
+type CancelAllAfterSupporter interface {
+	CancelAllAfter(timeout time.Duration) error // timeout后撤销所有挂单，重复调用会重新计时。0表示解除
+}
+
+// 能撤销全部挂单的交易所实现此接口
+type AllOrdersCloser interface {
+	CloseAllOrders()
+}
+
+type DeadManSwitch struct {
+	logPrefix string
+	timeout   time.Duration
+	native    CancelAllAfterSupporter
+	closer    AllOrdersCloser
+
+	mu          sync.Mutex
+	armed       bool
+	lastBeat    time.Time
+	lastRefresh time.Time
+	refreshing  bool
+	fired       bool // 本地看门狗已触发，等待心跳恢复
+	chStop      chan bool
+}
+
+func NewDeadManSwitch(ex CEx, timeout time.Duration) *DeadManSwitch {
+	d := new(DeadManSwitch)
+	d.logPrefix = fmt.Sprintf("deadman-%s", ex.Name())
+	d.timeout = timeout
+	d.native, _ = ex.(CancelAllAfterSupporter)
+	d.closer, _ = ex.(AllOrdersCloser)
+	return d
+}
+
+func (d *DeadManSwitch) Armed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.armed
+}
+
+// 由框架主循环调用。首次调用时启用开关
+func (d *DeadManSwitch) Heartbeat() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	d.lastBeat = now
+	if !d.armed {
+		d.armed = true
+		if d.native != nil {
+			logger.LogImportant(d.logPrefix, "armed with exchange cancel-all-after, timeout=%v", d.timeout)
+		} else {
+			d.chStop = make(chan bool, 1)
+			go d.watchdog()
+			logger.LogImportant(d.logPrefix, "exchange not support cancel-all-after, armed with local watchdog, timeout=%v", d.timeout)
+		}
+	}
+
+	if d.fired {
+		d.fired = false
+		logger.LogImportant(d.logPrefix, "heartbeat resumed")
+	}
+
+	// 倒计时过去1/3就续期，留出重试的余地
+	if d.native != nil && !d.refreshing && now.Sub(d.lastRefresh) >= d.timeout/3 {
+		d.refreshing = true
+		go d.refresh()
+	}
+}
+
+// 解除开关。交易所端的倒计时会被取消
+func (d *DeadManSwitch) Disarm() {
+	d.mu.Lock()
+	if !d.armed {
+		d.mu.Unlock()
+		return
+	}
+	d.armed = false
+	chStop := d.chStop
+	d.mu.Unlock()
+
+	// 网络请求不持有锁。与refresh并发时，refresh完成后发现已解除，会补一次解除
+	if d.native != nil {
+		if err := d.native.CancelAllAfter(0); err != nil {
+			logger.LogImportant(d.logPrefix, "disarm failed: %s", err.Error())
+		}
+	} else {
+		chStop <- true
+	}
+	logger.LogImportant(d.logPrefix, "disarmed")
+}
+
+func (d *DeadManSwitch) refresh() {
+	defer util.DefaultRecover()
+	err := d.native.CancelAllAfter(d.timeout)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.refreshing = false
+	if err != nil {
+		logger.LogImportant(d.logPrefix, "refresh cancel-all-after failed: %s", err.Error())
+	} else if d.armed {
+		d.lastRefresh = time.Now()
+	} else {
+		// 刷新过程中被解除了，补一次解除
+		go d.native.CancelAllAfter(0)
+	}
+}
+
+// 本地看门狗。心跳超时则撤销所有挂单
+func (d *DeadManSwitch) watchdog() {
+	defer util.DefaultRecover()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.mu.Lock()
+			expired := d.armed && !d.fired && time.Since(d.lastBeat) > d.timeout
+			if expired {
+				d.fired = true
+			}
+			d.mu.Unlock()
+
+			if expired {
+				logger.LogImportant(d.logPrefix, "no heartbeat for %v, closing all orders", d.timeout)
+				if d.closer != nil {
+					func() {
+						defer util.DefaultRecover()
+						d.closer.CloseAllOrders()
+					}()
+				}
+			}
+		case <-d.chStop:
+			return
+		}
+	}
+}
@@ -28,6 +28,16 @@ type KUnit struct {
 	VolumeUSD    decimal.Decimal // 以USD计算的交易量
 }
 
+// 市场成交（逐笔）
+type MarketTrade struct {
+	Id    int64     // 成交id，同一交易对内递增
+	Time  time.Time // 成交时间（服务器）
+	Price decimal.Decimal
+	Size  decimal.Decimal // 成交数量，统一为基础币数量（合约已按面值换算）
+	Value decimal.Decimal // 成交金额，以计价币/USD计
+	Dir   OrderDir        // 主动成交方向（taker方向）
+}
+
 type ContractType string
 
 const (
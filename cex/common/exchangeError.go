@@ -0,0 +1,132 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 23:02:15
+ * @Description: 交易所错误的统一分类，以及按分类决定的重试/退避策略
+ * 各交易所把自己的错误码映射到这里的分类，上层逻辑只根据分类做判断，不再关心具体错误码
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type ErrorCategory int
+
+const (
+	ErrorCategory_Unknown             ErrorCategory = iota
+	ErrorCategory_InsufficientBalance               // 余额/保证金不足
+	ErrorCategory_PostOnlyCross                     // 只挂单订单会立即成交
+	ErrorCategory_RateLimited                       // 触发限频
+	ErrorCategory_OrderNotFound                     // 订单不存在
+	ErrorCategory_InstrumentSuspended               // 交易品种暂停交易、交割/结算中或不存在
+	ErrorCategory_AuthFailure                       // 鉴权失败（key、签名、时间戳、ip等）
+	ErrorCategory_Network                           // 网络错误或服务端超时/繁忙
+)
+
+func (c ErrorCategory) String() string {
+	switch c {
+	case ErrorCategory_InsufficientBalance:
+		return "insufficient_balance"
+	case ErrorCategory_PostOnlyCross:
+		return "post_only_cross"
+	case ErrorCategory_RateLimited:
+		return "rate_limited"
+	case ErrorCategory_OrderNotFound:
+		return "order_not_found"
+	case ErrorCategory_InstrumentSuspended:
+		return "instrument_suspended"
+	case ErrorCategory_AuthFailure:
+		return "auth_failure"
+	case ErrorCategory_Network:
+		return "network"
+	default:
+		return "unknown"
+	}
+}
+
+// 交易所返回的错误，或访问交易所时的网络错误
+type ExchangeError struct {
+	Exchange string
+	Category ErrorCategory
+	Code     string // 交易所原始错误码，网络错误时为空
+	Msg      string
+	Err      error // 底层错误，仅网络错误时有
+}
+
+func NewExchangeError(exchange string, category ErrorCategory, code, msg string) *ExchangeError {
+	return &ExchangeError{Exchange: exchange, Category: category, Code: code, Msg: msg}
+}
+
+func NewNetworkError(exchange string, err error) *ExchangeError {
+	return &ExchangeError{Exchange: exchange, Category: ErrorCategory_Network, Msg: err.Error(), Err: err}
+}
+
+func (e *ExchangeError) Error() string {
+	if len(e.Code) > 0 {
+		return fmt.Sprintf("[%s] code=%s, msg=%s", e.Category.String(), e.Code, e.Msg)
+	} else {
+		return fmt.Sprintf("[%s] %s", e.Category.String(), e.Msg)
+	}
+}
+
+func (e *ExchangeError) Unwrap() error {
+	return e.Err
+}
+
+// 取错误的分类。非ExchangeError一律视为Unknown
+func ErrorCategoryOf(err error) ErrorCategory {
+	var e *ExchangeError
+	if errors.As(err, &e) {
+		return e.Category
+	}
+	return ErrorCategory_Unknown
+}
+
+func IsErrorCategory(err error, category ErrorCategory) bool {
+	return err != nil && ErrorCategoryOf(err) == category
+}
+
+// 重试策略。Delay(n)为第n次重试前的等待时间，从BaseDelay开始翻倍，不超过MaxDelay
+type RetryPolicy struct {
+	Retryable bool          // 原样重试是否可能成功。为false时，需要等外部条件变化（如余额、行情）后再尝试
+	BaseDelay time.Duration // 首次重试前的等待
+	MaxDelay  time.Duration // 退避上限
+}
+
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay)
+}
+
+var retryPolicies = map[ErrorCategory]RetryPolicy{
+	ErrorCategory_Unknown:             {Retryable: true, BaseDelay: time.Second, MaxDelay: time.Second * 10},
+	ErrorCategory_InsufficientBalance: {Retryable: false, BaseDelay: time.Second * 5, MaxDelay: time.Minute},
+	ErrorCategory_PostOnlyCross:       {Retryable: true, BaseDelay: time.Millisecond * 100, MaxDelay: time.Second},
+	ErrorCategory_RateLimited:         {Retryable: true, BaseDelay: time.Second, MaxDelay: time.Second * 30},
+	ErrorCategory_OrderNotFound:       {Retryable: false, BaseDelay: time.Second, MaxDelay: time.Second},
+	ErrorCategory_InstrumentSuspended: {Retryable: false, BaseDelay: time.Second * 30, MaxDelay: time.Minute * 5},
+	ErrorCategory_AuthFailure:         {Retryable: false, BaseDelay: time.Minute, MaxDelay: time.Minute * 10},
+	ErrorCategory_Network:             {Retryable: true, BaseDelay: time.Millisecond * 500, MaxDelay: time.Second * 10},
+}
+var muRetryPolicies sync.RWMutex
+
+// 按错误分类取重试策略。err为nil时返回Unknown的策略
+func RetryPolicyOf(err error) RetryPolicy {
+	muRetryPolicies.RLock()
+	defer muRetryPolicies.RUnlock()
+	return retryPolicies[ErrorCategoryOf(err)]
+}
+
+// 修改某个分类的重试策略
+func SetRetryPolicy(category ErrorCategory, p RetryPolicy) {
+	muRetryPolicies.Lock()
+	defer muRetryPolicies.Unlock()
+	retryPolicies[category] = p
+}
@@ -111,6 +111,7 @@ type Order interface {
 	IsAlive() bool
 	IsFinished() bool
 	HasFatalError() bool // 错误订单一定会Finished，换句话说FatalError是Finished的子集
+	LastError() error    // 最近一次错误，用ErrorCategoryOf取分类。没有错误时为nil
 	AddObserver(obs OrderObserver)
 }
 
@@ -175,6 +176,18 @@ type SpotMarket interface {
 	QuoteCurrency() string // 计价货币币种，如 BTC-USDT 中的USDT
 }
 
+// 市场逐笔成交数据源
+// 实时推送经由fnTrade回调；数据可能有遗漏时（如断线重连、成交id不连续）会回调fnGap
+// 使用者收到fnGap后，应使用FetchTrades补全缺失的成交
+type TradeFeed interface {
+	InstId() string
+	Start(fnTrade func(MarketTrade), fnGap func())
+	Stop()
+	Connected() bool
+	FetchTrades(afterId int64, t1 time.Time) ([]MarketTrade, bool) // 返回id大于afterId、时间不晚于t1的成交，按id升序
+	GetKline(t0, t1 time.Time, intervalSec int) ([]KUnit, bool)    // 历史K线，用于回填
+}
+
 // 通用交易接口
 type CommonTrader interface {
 	Uninit()
@@ -185,6 +198,12 @@ type CommonTrader interface {
 	BuyPriceRange() (min, max decimal.Decimal)
 	SellPriceRange() (min, max decimal.Decimal)
 	MakeOrder(price, amount decimal.Decimal, dir OrderDir, makeOnly, reduceOnly bool, purpose string, observer OrderObserver) Order
+
+	// 批量操作，同步返回，结果与请求一一对应。按交易所限制自动分批，交易所不支持批量接口时逐个执行
+	MakeOrders(reqs []OrderRequest) []OrderResult
+	ModifyOrders(reqs []OrderModifyRequest) []OrderResult
+	CancelOrders(orders []Order) []OrderResult
+
 	Orders() []Order
 	FeeTaker() decimal.Decimal
 	FeeMaker() decimal.Decimal
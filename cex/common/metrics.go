@@ -0,0 +1,43 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 03:01:45
+ * @Description: 成交相关的监控指标。成交延迟为成交到达本地的时间(LocalTime)与服务器成交时间(UTime)之差
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package common
+
+import (
+	"time"
+
+	"github.com/aztecqt/dagger/util/metrics"
+)
+
+var dealLatency = metrics.NewHistogram(
+	"dagger_deal_latency_seconds",
+	"Delay between the exchange deal time and the local receive time.",
+	nil, "exchange")
+
+var deals = metrics.NewCounter(
+	"dagger_deals_total",
+	"Number of order deals received.",
+	"exchange", "dir")
+
+var lastDealTime = metrics.NewGauge(
+	"dagger_last_deal_timestamp_seconds",
+	"Unix time of the latest deal received.",
+	"exchange")
+
+// 以启动时间作为最后成交时间的初始值。否则一直没有成交时该指标不存在，基于age的告警永远不会触发
+func SeedLastDealTime(exchange string, t time.Time) {
+	lastDealTime.With(exchange).Set(float64(t.Unix()))
+}
+
+// 记录一次成交，由各交易所的订单在回调观察者之前调用
+func RecordDeal(exchange string, d Deal) {
+	deals.With(exchange, OrderDir2Str(d.O.GetDir())).Inc()
+	lastDealTime.With(exchange).Set(float64(d.LocalTime.Unix()))
+	if !d.LocalTime.IsZero() && !d.UTime.IsZero() {
+		dealLatency.With(exchange).Observe(d.LocalTime.Sub(d.UTime).Seconds())
+	}
+}
@@ -9,6 +9,7 @@ package common
 
 import (
 	"fmt"
+	"log/slog"
 	"strconv"
 	"time"
 
@@ -38,6 +39,7 @@ type OrderImpl struct {
 	Status        string          // 根据不同交易所的规则，一般有不同的定义
 	Finished      bool            // 是否完结
 	ErrMsg        string          // 最近的错误消息（仅用于记录，不用于判断订单是否失败）
+	LastErr       error           // 最近的错误，一般为*ExchangeError
 	FatalError    bool            // 是否出现致命错误
 
 	// 成交回调
@@ -95,6 +97,23 @@ func (o *OrderImpl) Init(
 	return true
 }
 
+// 记录错误，同时更新ErrMsg
+func (o *OrderImpl) SetError(err error) {
+	o.LastErr = err
+	if err != nil {
+		o.ErrMsg = err.Error()
+	}
+}
+
+// 带有交易所、交易对、自定义订单Id字段的日志对象
+func (o *OrderImpl) Logger(exchange string) *slog.Logger {
+	return logger.New(
+		o.LogPrefix,
+		logger.FieldKey_Exchange, exchange,
+		logger.FieldKey_InstId, o.InstId,
+		logger.FieldKey_ClientOrderId, fmt.Sprintf("%v", o.CltOrderId))
+}
+
 // #region 实现common.Order
 func (o *OrderImpl) AddObserver(obs OrderObserver) {
 	o.Observers = append(o.Observers, obs)
@@ -178,4 +197,8 @@ func (o *OrderImpl) HasFatalError() bool {
 	return o.FatalError
 }
 
+func (o *OrderImpl) LastError() error {
+	return o.LastErr
+}
+
 // #endregion
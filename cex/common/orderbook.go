@@ -15,6 +15,8 @@ import (
 	"bytes"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aztecqt/dagger/util"
 	"github.com/emirpasic/gods/maps/treemap"
@@ -29,6 +31,7 @@ type Orderbook struct {
 	buy1Sz  decimal.Decimal
 	sell1Px decimal.Decimal
 	sell1Sz decimal.Decimal
+	uTime   atomic.Int64 // 最近一次更新的本地时间(UnixNano)，用于判断深度是否过期。读取时不加锁
 }
 
 func NewOrderBook() *Orderbook {
@@ -52,6 +55,23 @@ func (ob *Orderbook) Unlock() {
 	ob.mu.Unlock()
 }
 
+// 最近一次更新的时间
+func (ob *Orderbook) UpdateTime() time.Time {
+	if ns := ob.uTime.Load(); ns > 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// 距离最近一次更新过了多久。从未更新过则返回一个极大值
+func (ob *Orderbook) Age() time.Duration {
+	ns := ob.uTime.Load()
+	if ns == 0 {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Since(time.Unix(0, ns))
+}
+
 // 最高买价/量
 func (ob *Orderbook) Buy1() (px, sz decimal.Decimal) {
 	return ob.buy1Px, ob.buy1Sz
@@ -232,6 +252,7 @@ func (ob *Orderbook) Clear() {
 func (ob *Orderbook) UpdateAsk(price, amount decimal.Decimal) {
 	ob.Lock()
 	defer ob.Unlock()
+	ob.uTime.Store(time.Now().UnixNano())
 
 	if amount.IsZero() {
 		ob.Asks.Remove(price)
@@ -252,6 +273,7 @@ func (ob *Orderbook) UpdateAsk(price, amount decimal.Decimal) {
 func (ob *Orderbook) UpdateBids(price, amount decimal.Decimal) {
 	ob.Lock()
 	defer ob.Unlock()
+	ob.uTime.Store(time.Now().UnixNano())
 
 	if amount.IsZero() {
 		ob.Bids.Remove(price)
@@ -273,6 +295,7 @@ func (ob *Orderbook) UpdateBids(price, amount decimal.Decimal) {
 func (ob *Orderbook) Rebuild(asks, bids []decimal.Decimal) {
 	ob.Lock()
 	defer ob.Unlock()
+	ob.uTime.Store(time.Now().UnixNano())
 
 	ob.Asks.Clear()
 	ob.Bids.Clear()
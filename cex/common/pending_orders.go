@@ -0,0 +1,25 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 14:20:11
+ * @Description: 交易所端的挂单。本地交易器只知道自己下的订单，进程重启或主备切换后，
+ * 旧进程留下的挂单需要按订单标签从交易所查出来处理
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package common
+
+import "github.com/shopspring/decimal"
+
+type PendingOrder struct {
+	InstId        string
+	OrderId       string
+	ClientOrderId string
+	Price         decimal.Decimal
+	Size          decimal.Decimal
+	Filled        decimal.Decimal
+}
+
+// 能按订单标签查询、撤销交易所端挂单的交易所实现此接口
+type TaggedOrdersReconciler interface {
+	PendingTaggedOrders() ([]PendingOrder, error)            // 本策略（订单标签）在交易所的全部挂单
+	CancelPendingOrders(orders []PendingOrder) []OrderResult // 结果与orders一一对应，OrderResult.O为nil
+}
@@ -0,0 +1,182 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 09:20:44
+ * @Description: 交易所注册表。各交易所包在init中注册构造函数、配置类型和所需凭证，上层按名称创建交易所，不再直接依赖具体的交易所包
+ * 配置从json对象解析到注册时给出的默认配置上，未出现的字段保留默认值
+ * 测试和模拟交易所也可以用同样的方式注册
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aztecqt/dagger/util/network"
+)
+
+// 交易所需要的凭证
+type CredentialRequirement int
+
+const (
+	Credential_None              CredentialRequirement = iota // 不需要，如通过本地网关连接
+	Credential_KeySecret                                      // key+secret
+	Credential_KeySecretPassword                              // key+secret+password
+)
+
+func CredentialRequirement2String(c CredentialRequirement) string {
+	switch c {
+	case Credential_None:
+		return "none"
+	case Credential_KeySecret:
+		return "key+secret"
+	case Credential_KeySecretPassword:
+		return "key+secret+password"
+	default:
+		return "unknown"
+	}
+}
+
+// 凭证
+type Credential struct {
+	Key      string
+	Secret   string
+	Password string
+	KeyType  string // 签名方式(hmac/rsa/ed25519)，不支持多种方式的交易所忽略
+}
+
+// 创建交易所时的公共选项
+type ExchangeOptions struct {
+	OrderTag      string                // 标识订单归属，不支持的交易所忽略
+	ErrorNotifier func(error)           // 交易所错误回调
+	ProxySelector network.ProxySelector // rest和ws使用的代理，为nil时使用环境变量中的代理
+}
+
+type ExchangeFactory struct {
+	Name       string
+	Credential CredentialRequirement
+
+	// api的key是进程级的，一个进程只能创建一个该交易所对象
+	Singleton bool
+
+	// 返回带默认值的配置对象（指针）
+	NewConfig func() interface{}
+
+	// cfg为NewConfig返回的对象
+	New func(cfg interface{}, cred Credential, opt ExchangeOptions) (CEx, error)
+}
+
+var exchangeFactories = make(map[string]ExchangeFactory)
+var exchangeCreated = make(map[string]bool)
+var muExchangeFactories sync.Mutex
+
+// 注册交易所。名称不区分大小写，重复注册时后者覆盖前者
+// T为配置类型，defaultCfg返回默认配置
+func RegisterExchange[T any](name string, cred CredentialRequirement, singleton bool, defaultCfg func() T, ctor func(cfg T, cred Credential, opt ExchangeOptions) (CEx, error)) {
+	f := ExchangeFactory{
+		Name:       strings.ToLower(name),
+		Credential: cred,
+		Singleton:  singleton,
+		NewConfig: func() interface{} {
+			cfg := defaultCfg()
+			return &cfg
+		},
+		New: func(cfg interface{}, cred Credential, opt ExchangeOptions) (CEx, error) {
+			c, ok := cfg.(*T)
+			if !ok {
+				return nil, fmt.Errorf("invalid config type %T for exchange %s", cfg, name)
+			}
+			return ctor(*c, cred, opt)
+		},
+	}
+
+	muExchangeFactories.Lock()
+	defer muExchangeFactories.Unlock()
+	exchangeFactories[f.Name] = f
+}
+
+func GetExchangeFactory(name string) (ExchangeFactory, bool) {
+	muExchangeFactories.Lock()
+	defer muExchangeFactories.Unlock()
+	f, ok := exchangeFactories[strings.ToLower(name)]
+	return f, ok
+}
+
+// 所有已注册的交易所
+func ExchangeNames() []string {
+	muExchangeFactories.Lock()
+	defer muExchangeFactories.Unlock()
+	names := make([]string, 0, len(exchangeFactories))
+	for n := range exchangeFactories {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 把json对象（通常是从配置文件读出的map）解析为交易所的配置类型。raw为nil时返回默认配置
+func (f ExchangeFactory) DecodeConfig(raw interface{}) (interface{}, error) {
+	cfg := f.NewConfig()
+	if raw == nil {
+		return cfg, nil
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("decode config of %s failed: %s", f.Name, err.Error())
+	}
+	return cfg, nil
+}
+
+// 检查凭证是否满足要求
+func (f ExchangeFactory) CheckCredential(cred Credential) error {
+	switch f.Credential {
+	case Credential_KeySecret:
+		if len(cred.Key) == 0 || len(cred.Secret) == 0 {
+			return fmt.Errorf("%s requires %s", f.Name, CredentialRequirement2String(f.Credential))
+		}
+	case Credential_KeySecretPassword:
+		if len(cred.Key) == 0 || len(cred.Secret) == 0 || len(cred.Password) == 0 {
+			return fmt.Errorf("%s requires %s", f.Name, CredentialRequirement2String(f.Credential))
+		}
+	}
+	return nil
+}
+
+// 按名称创建交易所。rawCfg为json对象，见DecodeConfig
+// 不检查凭证，只读行情时可以不提供
+func NewExchange(name string, rawCfg interface{}, cred Credential, opt ExchangeOptions) (CEx, error) {
+	f, ok := GetExchangeFactory(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown exchange: %s (registered: %s)", name, strings.Join(ExchangeNames(), ","))
+	}
+
+	cfg, err := f.DecodeConfig(rawCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Singleton {
+		muExchangeFactories.Lock()
+		created := exchangeCreated[f.Name]
+		exchangeCreated[f.Name] = true
+		muExchangeFactories.Unlock()
+		if created {
+			return nil, fmt.Errorf("exchange %s can only be created once in a process", f.Name)
+		}
+	}
+
+	ex, err := f.New(cfg, cred, opt)
+	if err != nil && f.Singleton {
+		muExchangeFactories.Lock()
+		delete(exchangeCreated, f.Name)
+		muExchangeFactories.Unlock()
+	}
+	return ex, err
+}
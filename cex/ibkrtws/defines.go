@@ -17,10 +17,11 @@ import (
 	"github.com/aztecqt/dagger/api/ibkr/twsapi/twsmodel"
 	"github.com/aztecqt/dagger/cex/common"
 	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
 	"github.com/shopspring/decimal"
 )
 
-// 日志接口（将来应该封装起来）
+// 日志接口。Init时传入nil则使用logger包
 type fnLog func(msg string)
 
 var logInfoFn fnLog
@@ -36,18 +37,33 @@ var toleratedErrorRecordsMu sync.Mutex
 var toleratedErrorRecords map[string]*tolerateRecord
 
 func logDebug(logPrefix string, format string, params ...interface{}) {
+	if logDebugFn == nil {
+		logger.LogDebug(logPrefix, format, params...)
+		return
+	}
+
 	msg := fmt.Sprintf(format, params...)
 	msg = fmt.Sprintf("[%s] %s", logPrefix, msg)
 	logDebugFn(msg)
 }
 
 func logInfo(logPrefix string, format string, params ...interface{}) {
+	if logInfoFn == nil {
+		logger.LogInfo(logPrefix, format, params...)
+		return
+	}
+
 	msg := fmt.Sprintf(format, params...)
 	msg = fmt.Sprintf("[%s] %s", logPrefix, msg)
 	logInfoFn(msg)
 }
 
 func logError(logPrefix string, format string, params ...interface{}) {
+	if logErrorFn == nil {
+		logger.LogImportant(logPrefix, format, params...)
+		return
+	}
+
 	msg := fmt.Sprintf(format, params...)
 	msg = fmt.Sprintf("[%s] %s", logPrefix, msg)
 	logErrorFn(msg)
@@ -0,0 +1,32 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 09:38:27
+- @Description: 注册到交易所注册表。通过本地tws/gateway连接，不需要apikey
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package ibkrtws
+
+import (
+	"fmt"
+
+	"github.com/aztecqt/dagger/cex/common"
+)
+
+const RegistryName = "ibkrtws"
+
+func init() {
+	common.RegisterExchange(
+		RegistryName,
+		common.Credential_None,
+		true,
+		func() ExchangeConfig { return ExchangeConfig{Addr: "127.0.0.1"} },
+		func(cfg ExchangeConfig, cred common.Credential, opt common.ExchangeOptions) (common.CEx, error) {
+			if cfg.Port == 0 {
+				return nil, fmt.Errorf("ibkrtws port not configured")
+			}
+			ex := new(Exchange)
+			ex.Init(cfg, nil, nil, nil)
+			return ex, nil
+		})
+}
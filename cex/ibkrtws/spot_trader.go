@@ -175,6 +175,19 @@ func (t *SpotTrader) MakeOrder(
 	}
 }
 
+// tws没有批量接口，逐个执行
+func (t *SpotTrader) MakeOrders(reqs []common.OrderRequest) []common.OrderResult {
+	return common.MakeOrdersOneByOne(t, reqs)
+}
+
+func (t *SpotTrader) ModifyOrders(reqs []common.OrderModifyRequest) []common.OrderResult {
+	return common.ModifyOrdersOneByOne(reqs)
+}
+
+func (t *SpotTrader) CancelOrders(orders []common.Order) []common.OrderResult {
+	return common.CancelOrdersOneByOne(orders)
+}
+
 func (t *SpotTrader) Orders() []common.Order {
 	orders := make([]common.Order, 0, len(t.orders))
 
@@ -0,0 +1,285 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 21:15:08
+ * @Description: okx批量下单/改单/撤单。FutureTrader和SpotTrader共用
+ * 每批最多20个订单，私有ws可用时优先走ws，否则走rest。结果按clOrdId与订单对应
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package okexv5
+
+import (
+	"context"
+	"github.com/aztecqt/dagger/api/okexv5api"
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util/logger"
+)
+
+// 从common.Order取出CommonOrder
+func commonOrderOf(o common.Order) *CommonOrder {
+	switch co := o.(type) {
+	case *ContractOrder:
+		return &co.CommonOrder
+	case *SpotOrder:
+		return &co.CommonOrder
+	default:
+		return nil
+	}
+}
+
+// 批量下单。orders已完成本地初始化，并已在订单管理器中登记（尚未提交）
+// 返回的错误与orders一一对应。无论结果如何，所有订单都会交给订单管理器，由对账确认最终状态
+func createOrdersBatch(ctx context.Context, ws *okexv5api.WsClient, orders []*CommonOrder) []error {
+	errs := make([]error, len(orders))
+	for i0 := 0; i0 < len(orders); i0 += okexv5api.BatchOrderLimit {
+		i1 := min(i0+okexv5api.BatchOrderLimit, len(orders))
+		chunk := orders[i0:i1]
+
+		reqs := make([]okexv5api.MakeorderRestReq, 0, len(chunk))
+		index := make(map[string]int)
+		for i, o := range chunk {
+			o.submitted = true
+			reqs = append(reqs, o.makeorderReq())
+			index[o.CltOrderId.(string)] = i0 + i
+			o.log().Info("creating in batch", "order", o.String())
+		}
+
+		var resp *okexv5api.MakeorderRestResp
+		var err error
+		if ws != nil && ws.PrivateReady() {
+			resp, err = ws.MakeOrders(reqs, okexv5api.WsOpTimeout)
+			if err == okexv5api.ErrWsOpTimeout {
+				// 超时的请求可能已经生效，不能再用rest重复下单，交给订单刷新确认
+				logger.LogImportant(logPrefix, "batch create with ws timeout, %d orders wait for refresh", len(chunk))
+				continue
+			} else if err != nil {
+				logger.LogImportant(logPrefix, "batch create with ws error: %s, fallback to rest", err.Error())
+			}
+		}
+
+		if resp == nil {
+			resp, err = okexv5api.MakeOrderBatchCtx(ctx, reqs)
+		}
+
+		if err != nil {
+			// 网络错误不代表订单未创建成功，交给订单刷新确认
+			logger.LogImportant(logPrefix, "batch create error: %s, %d orders wait for refresh", err.Error(), len(chunk))
+			continue
+		}
+
+		for _, d := range resp.Data {
+			if i, ok := index[d.ClientOrderId]; ok {
+				orders[i].onCreateResult(d.SCode, d.SMsg, d.OrderId, false)
+				errs[i] = newExchangeError(d.SCode, d.SMsg)
+			}
+		}
+
+		if len(resp.Data) == 0 {
+			logger.LogImportant(logPrefix, "batch create failed, code=%s, msg=%s", resp.Code, resp.Msg)
+			for i := i0; i < i1; i++ {
+				errs[i] = newExchangeError(resp.Code, resp.Msg)
+				orders[i].SetError(errs[i])
+				orders[i].FatalError = true
+			}
+		}
+	}
+
+	return errs
+}
+
+// 批量改单。返回的错误与reqs一一对应
+func modifyOrdersBatch(ctx context.Context, ws *okexv5api.WsClient, reqs []common.OrderModifyRequest) []common.OrderResult {
+	results := make([]common.OrderResult, len(reqs))
+	orders := make([]*CommonOrder, len(reqs))
+	amends := make([]okexv5api.AmendBatchOrderRestReq, 0, len(reqs))
+	index := make([]int, 0, len(reqs)) // amends[k]对应reqs[index[k]]
+	for i, r := range reqs {
+		results[i].O = r.O
+		o := commonOrderOf(r.O)
+		if o == nil {
+			results[i].Err = common.ErrModifyNotSupported
+			continue
+		} else if o.IsFinished() {
+			results[i].Err = common.ErrOrderFinished
+			continue
+		}
+
+		orders[i] = o
+		px, sz, needCancel := o.alignModify(r.NewPrice, r.NewSize)
+		if needCancel {
+			o.Cancel()
+			continue
+		} else if !px.IsPositive() && !sz.IsPositive() {
+			continue
+		}
+
+		req := okexv5api.AmendBatchOrderRestReq{
+			InstId:        o.InstId,
+			ClientOrderId: o.CltOrderId.(string),
+			ReqId:         NewAmendId(),
+			CxlOnFail:     true,
+		}
+		if px.IsPositive() {
+			req.NewPrice = px.String()
+		}
+		if sz.IsPositive() {
+			req.NewSize = sz.String()
+		}
+		amends = append(amends, req)
+		index = append(index, i)
+		o.log().Info("modifying in batch", "order", o.String(), "newPrice", px, "newSize", sz)
+	}
+
+	for k0 := 0; k0 < len(amends); k0 += okexv5api.BatchOrderLimit {
+		k1 := min(k0+okexv5api.BatchOrderLimit, len(amends))
+		chunk := amends[k0:k1]
+
+		var resp *okexv5api.AmendOrderRestResp
+		var err error
+		if ws != nil && ws.PrivateReady() {
+			resp, err = ws.AmendOrders(chunk, okexv5api.WsOpTimeout)
+			if err == okexv5api.ErrWsOpTimeout {
+				// 超时的改单可能已经生效，不能再用rest重复改单，由订单刷新确认最终价格和数量
+				logger.LogImportant(logPrefix, "batch modify with ws timeout, %d orders wait for refresh", len(chunk))
+				for k := k0; k < k1; k++ {
+					results[index[k]].Err = newNetworkError(err)
+				}
+				continue
+			} else if err != nil {
+				logger.LogImportant(logPrefix, "batch modify with ws error: %s, fallback to rest", err.Error())
+				resp = nil
+			}
+		}
+
+		if resp == nil {
+			resp, err = okexv5api.AmendOrderBatchCtx(ctx, chunk)
+		}
+
+		if err != nil {
+			logger.LogImportant(logPrefix, "batch modify error: %s", err.Error())
+			for k := k0; k < k1; k++ {
+				results[index[k]].Err = newNetworkError(err)
+			}
+			continue
+		}
+
+		byClientId := make(map[string]int)
+		for k := k0; k < k1; k++ {
+			byClientId[amends[k].ClientOrderId] = index[k]
+		}
+
+		for _, d := range resp.Data {
+			if i, ok := byClientId[d.ClientOrderId]; ok {
+				orders[i].onModifyResult(d.SCode, d.SMsg)
+				results[i].Err = newExchangeError(d.SCode, d.SMsg)
+			}
+		}
+
+		if len(resp.Data) == 0 {
+			for k := k0; k < k1; k++ {
+				results[index[k]].Err = newExchangeError(resp.Code, resp.Msg)
+			}
+		}
+	}
+
+	return results
+}
+
+// 批量撤单。返回的错误与orders一一对应
+// 撤单不受订单管理器的ctx限制，退出时仍需要撤单
+func cancelOrdersBatch(ws *okexv5api.WsClient, orders []common.Order) []common.OrderResult {
+	results := make([]common.OrderResult, len(orders))
+	cancels := make([]okexv5api.CancelBatchOrderRestReq, 0, len(orders))
+	byClientId := make(map[string]int)
+	for i, ord := range orders {
+		results[i].O = ord
+		o := commonOrderOf(ord)
+		if o == nil {
+			ord.Cancel()
+			continue
+		} else if o.IsFinished() {
+			results[i].Err = common.ErrOrderFinished
+			continue
+		}
+
+		cancels = append(cancels, okexv5api.CancelBatchOrderRestReq{InstId: o.InstId, ClientOrderId: o.CltOrderId.(string)})
+		byClientId[o.CltOrderId.(string)] = i
+		o.log().Info("canceling in batch", "order", o.String())
+	}
+
+	for k0 := 0; k0 < len(cancels); k0 += okexv5api.BatchOrderLimit {
+		k1 := min(k0+okexv5api.BatchOrderLimit, len(cancels))
+		chunk := cancels[k0:k1]
+
+		var resp *okexv5api.CancelOrderRestResp
+		var err error
+		if ws != nil && ws.PrivateReady() {
+			resp, err = ws.CancelOrders(chunk, okexv5api.WsOpTimeout)
+			if err != nil {
+				logger.LogImportant(logPrefix, "batch cancel with ws error: %s, fallback to rest", err.Error())
+				resp = nil
+			}
+		}
+
+		if resp == nil {
+			resp, err = okexv5api.CancelOrderBatch(chunk)
+		}
+
+		if err != nil {
+			logger.LogImportant(logPrefix, "batch cancel error: %s", err.Error())
+			for _, c := range chunk {
+				results[byClientId[c.ClientOrderId]].Err = newNetworkError(err)
+			}
+			continue
+		}
+
+		for _, d := range resp.Data {
+			if i, ok := byClientId[d.ClientOrderId]; ok {
+				commonOrderOf(orders[i]).onCancelResult(d.SCode, d.SMsg)
+				results[i].Err = newExchangeError(d.SCode, d.SMsg)
+			}
+		}
+
+		if len(resp.Data) == 0 {
+			for _, c := range chunk {
+				results[byClientId[c.ClientOrderId]].Err = newExchangeError(resp.Code, resp.Msg)
+			}
+		}
+	}
+
+	return results
+}
+
+// 批量下单的公共部分。newOrder负责创建并初始化订单（不启动），本地验证未通过返回nil
+func makeOrdersBatch(
+	ctx context.Context,
+	ws *okexv5api.WsClient,
+	reqs []common.OrderRequest,
+	newOrder func(r common.OrderRequest) (common.Order, *CommonOrder)) []common.OrderResult {
+	results := make([]common.OrderResult, len(reqs))
+	orders := make([]*CommonOrder, 0, len(reqs))
+	index := make([]int, 0, len(reqs))
+	for i, r := range reqs {
+		o, co := newOrder(r)
+		if o == nil {
+			results[i].Err = common.ErrOrderInvalid
+			continue
+		}
+
+		results[i].O = o
+		orders = append(orders, co)
+		index = append(index, i)
+	}
+
+	// 提交前登记，避免提交后、登记前到达的ws快照因clientId未知被丢弃
+	for _, co := range orders {
+		co.mgr.register(co)
+	}
+
+	errs := createOrdersBatch(ctx, ws, orders)
+	for k, co := range orders {
+		results[index[k]].Err = errs[k]
+		co.mgr.onCreated(co)
+	}
+
+	return results
+}
@@ -14,6 +14,7 @@ package okexv5
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -35,19 +36,28 @@ type CommonOrder struct {
 	restRefreshErrorCount int    // rest调用错误次数
 	refreshCount          int    // 刷新次数
 
+	// 私有ws可用时，优先通过ws下单/改单/撤单，否则使用rest。为nil则只用rest
+	ws *okexv5api.WsClient
+
+	// 已通过批量接口提交，不再单独创建
+	submitted bool
+
 	// 子类提供
 	getPosSide func() string
 	tradeMode  func() string
 
-	// 刷新
-	muRefresh        sync.Mutex
-	tkRefreshTimeout *time.Ticker
-	chRefreshImm     chan int
+	// 刷新。创建和对账由订单管理器统一调度
+	muRefresh sync.Mutex
+	mgr       *orderManager
 }
 
 func (o *CommonOrder) Go() {
-	o.tkRefreshTimeout = time.NewTicker(time.Second * 10)
-	go o.update()
+	o.mgr.add(o)
+}
+
+// 订单日志，带有交易所、交易对、自定义订单Id字段
+func (o *CommonOrder) log() *slog.Logger {
+	return o.Logger(exchangeName)
 }
 
 // #region 实现common.Order
@@ -82,10 +92,32 @@ func (o *CommonOrder) create() {
 	defer util.DefaultRecover()
 
 	// 已经创建的订单不会再次被创建
-	if o.OrderId > 0 {
+	if o.OrderId > 0 || o.submitted {
 		return
 	}
 
+	// 调用api
+	o.log().Info("creating", "order", o.String())
+	req := o.makeorderReq()
+	resp, wsTimeout, err := o.makeOrder(req)
+	if err == nil {
+		if len(resp.Data) > 0 {
+			o.onCreateResult(resp.Data[0].SCode, resp.Data[0].SMsg, resp.Data[0].OrderId, wsTimeout)
+		} else {
+			o.ErrMsg = "response error, no data"
+			o.FatalError = true // 这种情况应该是服务器还没准备好，订单可以尝试重新创建
+			o.log().Info("create order error, no data")
+		}
+	} else {
+		// 网络错误不代表订单未创建成功
+		// 应该查询时返回“订单不存在”作为订单错误的触发条件
+		o.SetError(newNetworkError(err))
+		o.log().Warn("create order error", "err", err)
+	}
+}
+
+// 下单请求
+func (o *CommonOrder) makeorderReq() okexv5api.MakeorderRestReq {
 	side := "buy"
 	if o.Dir == common.OrderDir_Sell {
 		side = "sell"
@@ -98,41 +130,36 @@ func (o *CommonOrder) create() {
 		orderType = "post_only"
 	}
 
-	// 调用api
-	logger.LogInfo(o.LogPrefix, "creating [%s]", o.String())
-	resp, err := okexv5api.MakeOrder(
-		o.InstId,
-		o.CltOrderId.(string),
-		orderTag(),
-		side,
-		o.posSide,
-		orderType,
-		o.tradeMode(),
-		o.ReduceOnly,
-		o.Price,
-		o.Size)
-	if err == nil {
-		if len(resp.Data) > 0 {
-			if resp.Data[0].SCode != "0" {
-				o.ErrMsg = fmt.Sprintf("code=%s, msg=%s", resp.Data[0].SCode, resp.Data[0].SMsg)
-				o.FatalError = true // 只有这种情况可以明确的认为订单已经失败了
-				logger.LogImportant(o.LogPrefix, "create order error: %s", o.ErrMsg)
-			} else if resp.Data[0].OrderId != "0" {
-				o.OrderId = util.String2Int64Panic(resp.Data[0].OrderId)
-				logger.LogInfo(o.LogPrefix, "create success, order id = %v", o.OrderId)
-			} else {
-				o.ErrMsg = "create success but missing order id"
-				logger.LogPanic(o.LogPrefix, "create order error, invalid order id")
-			}
-		} else {
-			o.ErrMsg = "response error, no data"
-			o.FatalError = true // 这种情况应该是服务器还没准备好，订单可以尝试重新创建
-			logger.LogInfo(o.LogPrefix, "create order error, no data")
-		}
+	return okexv5api.MakeorderRestReq{
+		InstId:        o.InstId,
+		TradeMode:     o.tradeMode(),
+		ClientOrderId: o.CltOrderId.(string),
+		Tag:           orderTag(),
+		Side:          side,
+		PosSide:       o.posSide,
+		OrderType:     orderType,
+		ReduceOnly:    o.ReduceOnly,
+		Price:         o.Price.String(),
+		Size:          o.Size.String(),
+	}
+}
+
+// 处理下单结果。wsTimeout表示之前的ws请求已超时，订单可能已经创建
+func (o *CommonOrder) onCreateResult(sCode, sMsg, orderId string, wsTimeout bool) {
+	if wsTimeout && sCode == "51016" /*clOrdId重复*/ {
+		// ws请求超时但实际已下单成功，以查询结果为准
+		o.log().Warn("order already created by timeout ws request, refresh it")
+		o.doRestRefresh()
+	} else if sCode != "0" {
+		o.SetError(newExchangeError(sCode, sMsg))
+		o.FatalError = true // 只有这种情况可以明确的认为订单已经失败了
+		o.log().Warn("create order error", "err", o.ErrMsg)
+	} else if orderId != "0" && len(orderId) > 0 {
+		o.OrderId = util.String2Int64Panic(orderId)
+		o.log().Info("create success", "orderId", o.OrderId)
 	} else {
-		// 网络错误不代表订单未创建成功
-		// 应该查询时返回“订单不存在”作为订单错误的触发条件
-		logger.LogImportant(o.LogPrefix, "create order with rest error: %s", err.Error())
+		o.ErrMsg = "create success but missing order id"
+		logger.LogPanic(o.LogPrefix, "create order error, invalid order id")
 	}
 }
 
@@ -146,28 +173,36 @@ func (o *CommonOrder) cancel() {
 			o.canceling = false
 		}()
 
-		logger.LogInfo(o.LogPrefix, "canceling [%s]", o.String())
-		resp, err := okexv5api.CancelOrder(o.InstId, o.CltOrderId.(string), 0)
+		o.log().Info("canceling", "order", o.String())
+		resp, err := o.cancelOrder()
 		if err == nil {
-			if resp.Data[0].SCode != "0" {
-				o.ErrMsg = fmt.Sprintf("code:%s, msg:%s", resp.Data[0].SCode, resp.Data[0].SMsg)
-				code := util.String2IntPanic(resp.Data[0].SCode)
-				if code == 51400 /*不存在*/ || code == 51401 /*已撤销*/ || code != 51402 /*已完成*/ {
-					o.refreshImm()
-				} else if code != 51410 /*撤销中*/ && code != 51405 /*没有未成交的订单*/ && code != 51404 /*不可撤单*/ {
-					logger.LogImportant(o.LogPrefix, "cancel order error: %s", o.ErrMsg)
-				}
+			if !o.onCancelResult(resp.Data[0].SCode, resp.Data[0].SMsg) {
 				time.Sleep(time.Second)
-			} else {
-				logger.LogInfo(o.LogPrefix, "cancel responsed")
 			}
 		} else {
-			logger.LogImportant(o.LogPrefix, "cancel order with rest error: %s", err.Error())
+			o.log().Warn("cancel order error", "err", err)
 			time.Sleep(time.Second)
 		}
 	}
 }
 
+// 处理撤单结果，返回是否成功
+func (o *CommonOrder) onCancelResult(sCode, sMsg string) bool {
+	if sCode != "0" {
+		o.SetError(newExchangeError(sCode, sMsg))
+		code := util.String2IntPanic(sCode)
+		if code == 51400 /*不存在*/ || code == 51401 /*已撤销*/ || code != 51402 /*已完成*/ {
+			o.refreshImm()
+		} else if code != 51410 /*撤销中*/ && code != 51405 /*没有未成交的订单*/ && code != 51404 /*不可撤单*/ {
+			o.log().Warn("cancel order error", "err", o.ErrMsg)
+		}
+		return false
+	} else {
+		o.log().Info("cancel responsed")
+		return true
+	}
+}
+
 // 修改订单
 // 无论修改成功与否，都直接返回。逻辑层如果觉得仍有必要修改，再次调用即可
 func (o *CommonOrder) modify(newPrice, newSize decimal.Decimal) {
@@ -178,51 +213,117 @@ func (o *CommonOrder) modify(newPrice, newSize decimal.Decimal) {
 			o.modifying = false
 		}()
 
-		if newPrice.IsPositive() {
-			newPrice = o.InstrumentMgr.AlignPrice(
-				o.InstId,
-				newPrice,
-				o.Dir,
-				o.MakeOnly,
-				o.Trader.Market().OrderBook().Buy1Price(),
-				o.Trader.Market().OrderBook().Sell1Price())
-		}
-
-		if newSize.IsPositive() {
-			newSize = o.InstrumentMgr.AlignSize(o.InstId, newSize)
-			minSize := o.InstrumentMgr.MinSize(o.InstId, o.Price)
-			if newSize.LessThan(minSize) {
-				o.Cancel()
-				return
-			}
+		newPrice, newSize, needCancel := o.alignModify(newPrice, newSize)
+		if needCancel {
+			o.Cancel()
+			return
 		}
 
 		if newSize.IsPositive() || newPrice.IsPositive() {
-			logger.LogInfo(o.LogPrefix, "modifying [%s], newPrice=%v, newSize=%v", o.String(), newPrice, newSize)
-			resp, err := okexv5api.AmendOrder(o.InstId, o.CltOrderId.(string), NewAmendId(), 0, newPrice, newSize)
+			o.log().Info("modifying", "order", o.String(), "newPrice", newPrice, "newSize", newSize)
+			resp, err := o.amendOrder(newPrice, newSize)
 			if err == nil {
-				if resp.Data[0].SCode != "0" {
-					o.ErrMsg = fmt.Sprintf("code:%s, msg:%s", resp.Data[0].SCode, resp.Data[0].SMsg)
-					code := util.String2IntPanic(resp.Data[0].SCode)
-					if code == 51509 /*已撤销*/ || code == 51510 /*已完成*/ || code == 51503 /*订单不存在*/ {
-						o.refreshImm()
-					} else {
-						logger.LogImportant(o.LogPrefix, "modify order error: %s", o.ErrMsg)
-					}
+				if !o.onModifyResult(resp.Data[0].SCode, resp.Data[0].SMsg) {
 					time.Sleep(time.Second)
-				} else {
-					logger.LogInfo(o.LogPrefix, "modify responsed")
 				}
 			} else {
-				logger.LogImportant(o.LogPrefix, "modify order with rest error: %s", err.Error())
+				o.log().Warn("modify order error", "err", err)
 				time.Sleep(time.Second)
 			}
 		}
 	}
 }
 
+// 对齐改单的价格和数量。数量不足最小下单量时，needCancel为true
+func (o *CommonOrder) alignModify(newPrice, newSize decimal.Decimal) (px, sz decimal.Decimal, needCancel bool) {
+	if newPrice.IsPositive() {
+		newPrice = o.InstrumentMgr.AlignPrice(
+			o.InstId,
+			newPrice,
+			o.Dir,
+			o.MakeOnly,
+			o.Trader.Market().OrderBook().Buy1Price(),
+			o.Trader.Market().OrderBook().Sell1Price())
+	}
+
+	if newSize.IsPositive() {
+		newSize = o.InstrumentMgr.AlignSize(o.InstId, newSize)
+		minSize := o.InstrumentMgr.MinSize(o.InstId, o.Price)
+		if newSize.LessThan(minSize) {
+			return newPrice, newSize, true
+		}
+	}
+
+	return newPrice, newSize, false
+}
+
+// 处理改单结果，返回是否成功
+func (o *CommonOrder) onModifyResult(sCode, sMsg string) bool {
+	if sCode != "0" {
+		o.SetError(newExchangeError(sCode, sMsg))
+		code := util.String2IntPanic(sCode)
+		if code == 51509 /*已撤销*/ || code == 51510 /*已完成*/ || common.IsErrorCategory(o.LastErr, common.ErrorCategory_OrderNotFound) {
+			o.refreshImm()
+		} else {
+			o.log().Warn("modify order error", "err", o.ErrMsg)
+		}
+		return false
+	} else {
+		o.log().Info("modify responsed")
+		return true
+	}
+}
+
+// ws是否可用于交易
+func (o *CommonOrder) wsReady() bool {
+	return o.ws != nil && o.ws.PrivateReady()
+}
+
+// 下单。ws请求失败时改用rest，wsTimeout表示ws请求已发出但超时，此时订单可能已经创建
+func (o *CommonOrder) makeOrder(req okexv5api.MakeorderRestReq) (resp *okexv5api.MakeorderRestResp, wsTimeout bool, err error) {
+	if o.wsReady() {
+		resp, err = o.ws.MakeOrder(req.InstId, req.ClientOrderId, req.Tag, req.Side, req.PosSide, req.OrderType, req.TradeMode, req.ReduceOnly, o.Price, o.Size, okexv5api.WsOpTimeout)
+		if err == nil {
+			return
+		}
+
+		wsTimeout = err == okexv5api.ErrWsOpTimeout
+		o.log().Warn("create order with ws error, fallback to rest", "err", err)
+	}
+
+	resp, err = okexv5api.MakeOrderCtx(o.mgr.ctx, req.InstId, req.ClientOrderId, req.Tag, req.Side, req.PosSide, req.OrderType, req.TradeMode, req.ReduceOnly, o.Price, o.Size)
+	return
+}
+
+func (o *CommonOrder) cancelOrder() (*okexv5api.CancelOrderRestResp, error) {
+	if o.wsReady() {
+		resp, err := o.ws.CancelOrder(o.InstId, o.CltOrderId.(string), 0, okexv5api.WsOpTimeout)
+		if err == nil {
+			return resp, nil
+		}
+		o.log().Warn("cancel order with ws error, fallback to rest", "err", err)
+	}
+
+	return okexv5api.CancelOrder(o.InstId, o.CltOrderId.(string), 0)
+}
+
+func (o *CommonOrder) amendOrder(newPrice, newSize decimal.Decimal) (*okexv5api.AmendOrderRestResp, error) {
+	if o.wsReady() {
+		resp, err := o.ws.AmendOrder(o.InstId, o.CltOrderId.(string), NewAmendId(), 0, newPrice, newSize, okexv5api.WsOpTimeout)
+		if err == nil {
+			return resp, nil
+		} else if err == okexv5api.ErrWsOpTimeout {
+			// 超时的改单可能已经生效，不能再用rest重复改单，由订单刷新确认最终价格和数量
+			o.log().Warn("modify order with ws timeout, wait for refresh")
+			return nil, err
+		}
+		o.log().Warn("modify order with ws error, fallback to rest", "err", err)
+	}
+
+	return okexv5api.AmendOrderCtx(o.mgr.ctx, o.InstId, o.CltOrderId.(string), NewAmendId(), 0, newPrice, newSize)
+}
+
 func (o *CommonOrder) onSnapshot(os orderSnapshot) {
-	o.tkRefreshTimeout.Reset(time.Second * 10)
 	defer util.DefaultRecover()
 
 	deal := common.Deal{}
@@ -242,7 +343,7 @@ func (o *CommonOrder) onSnapshot(os orderSnapshot) {
 		}
 
 		// 刷新数据
-		logger.LogInfo(o.LogPrefix, "recv order snapshot:%s", os.String())
+		o.log().Info("recv order snapshot", "snapshot", os.String())
 		if os.updateTime.UnixMilli() >= o.UpdateTime.UnixMilli() && os.filled.GreaterThanOrEqual(o.Filled) {
 			filledOld := o.Filled
 			avgPriceOld := o.AvgPrice
@@ -258,11 +359,14 @@ func (o *CommonOrder) onSnapshot(os orderSnapshot) {
 
 			price, amount := common.CalculateOrderDeal(filledOld, avgPriceOld, filledNew, avgPricNew)
 			if price.IsPositive() && amount.IsPositive() {
-				logger.LogInfo(o.LogPrefix, "order dealing, dir=%s, price=%v, amount=%v, time=%v", common.OrderDir2Str(o.Dir), price, amount, os.updateTime)
+				o.log().Info("order dealing", "dir", common.OrderDir2Str(o.Dir), "price", price, "amount", amount, "time", os.updateTime)
 				deal = common.Deal{O: o, Price: price, Amount: amount, LocalTime: os.localTime, UTime: os.updateTime}
 			}
 
 			// 回调外部
+			if deal.Price.IsPositive() && deal.Amount.IsPositive() {
+				common.RecordDeal(exchangeName, deal)
+			}
 			if deal.Price.IsPositive() && deal.Amount.IsPositive() && len(o.Observers) > 0 {
 				for _, obs := range o.Observers {
 					if obs != nil {
@@ -274,10 +378,13 @@ func (o *CommonOrder) onSnapshot(os orderSnapshot) {
 			// 注意一定要等外部回调结束后，再置订单完成状态
 			finished := o.Status == okexv5api.OrderStatus_Canceled || o.Status == okexv5api.OrderStatus_Filled
 			if !o.Finished && finished {
+				if os.cancelSrc == cancelSource_PostOnlyCross {
+					o.SetError(common.NewExchangeError(exchangeName, common.ErrorCategory_PostOnlyCross, "", "post-only order canceled by system as it would take liquidity"))
+				}
 				o.Finished = finished
-				logger.LogInfo(o.LogPrefix, "order finished")
+				o.log().Info("order finished")
 			} else if o.Finished && !finished {
-				logger.LogImportant(o.LogPrefix, "order already finished but try set to unfinished? impossible!")
+				o.log().Warn("order already finished but try set to unfinished? impossible!")
 			}
 
 			o.refreshCount++
@@ -287,14 +394,14 @@ func (o *CommonOrder) onSnapshot(os orderSnapshot) {
 
 // 立即刷新订单
 func (o *CommonOrder) refreshImm() {
-	o.chRefreshImm <- 0
+	o.mgr.refreshImm(o.CltOrderId.(string))
 }
 
 func (o *CommonOrder) doRestRefresh() {
-	logger.LogInfo(o.LogPrefix, "geting order info from rest...")
-	resp, err := okexv5api.GetOrderInfo(o.InstId, 0, o.CltOrderId.(string))
+	o.log().Info("geting order info from rest...")
+	resp, err := o.mgr.getOrder(o.InstId, o.CltOrderId.(string))
 	b, _ := json.Marshal(resp)
-	logger.LogInfo(o.LogPrefix, "getted order info from rest, resp=%s", string(b))
+	o.log().Info("getted order info from rest", "resp", string(b))
 
 	if err == nil {
 		if resp.Code == "0" {
@@ -302,39 +409,24 @@ func (o *CommonOrder) doRestRefresh() {
 			os.localTime = resp.LocalTime
 			os.Parse(resp.Data[0], "rest")
 			o.onSnapshot(os)
-		} else if resp.Code == "51603" { // 订单不存在
-			o.ErrMsg = fmt.Sprintf("code:%s, msg:%s", resp.Code, resp.Msg)
-			o.FatalError = true // 此时订单生命周期可以结束了
 		} else {
-			// 其他错误连续出现3次则认为订单异常，强制结束
-			o.restRefreshErrorCount++
-			if o.restRefreshErrorCount >= 3 {
-				o.ErrMsg = fmt.Sprintf("code:%s, msg:%s", resp.Code, resp.Msg)
-				o.FatalError = true
+			e := newExchangeError(resp.Code, resp.Msg)
+			o.SetError(e)
+			switch common.ErrorCategoryOf(e) {
+			case common.ErrorCategory_OrderNotFound:
+				o.FatalError = true // 此时订单生命周期可以结束了
+			case common.ErrorCategory_RateLimited, common.ErrorCategory_Network:
+				// 与订单本身无关，下次对账再查
+			default:
+				// 其他错误连续出现3次则认为订单异常，强制结束
+				o.restRefreshErrorCount++
+				if o.restRefreshErrorCount >= 3 {
+					o.FatalError = true
+				}
 			}
 		}
-	}
-}
-
-func (o *CommonOrder) update() {
-	defer logger.LogInfo(o.LogPrefix, "update exit")
-
-	// go o.create()
-	o.create()
-
-	tkRepeat := time.NewTicker(time.Second)
-	for {
-		if o.IsFinished() {
-			break
-		}
-
-		select {
-		case <-o.chRefreshImm:
-			o.doRestRefresh()
-		case <-o.tkRefreshTimeout.C:
-			o.doRestRefresh()
-		case <-tkRepeat.C:
-		}
+	} else {
+		o.SetError(newNetworkError(err))
 	}
 }
 
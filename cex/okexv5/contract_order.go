@@ -34,6 +34,10 @@ func (o *ContractOrder) Init(
 	if o.CommonOrder.Init(trader, trader.exchange.instrumentMgr, trader.market.instId, price, amount, dir, makeOnly, reduceOnly, purpose) {
 		o.CommonOrder.getPosSide = o.getPosSide
 		o.CommonOrder.tradeMode = o.tradeMode
+		o.CommonOrder.mgr = o.trader.exchange.orderMgr
+		if !o.trader.exchange.excfg.RestOrderOnly {
+			o.CommonOrder.ws = o.trader.exchange.ws
+		}
 		return true
 	} else {
 		return false
@@ -44,6 +44,15 @@ type ExchangeConfig struct {
 	// 是否订阅资金费率
 	SubscribeFundingFeeRate bool `json:"sub_ffr"`
 
+	// 公共频道的ws连接数，订阅按频道分散到各连接。默认1
+	PublicWsShards int `json:"public_ws_shards"`
+
+	// ws是否协商permessage-deflate压缩
+	WsCompression bool `json:"ws_compression"`
+
+	// 只用rest下单/改单/撤单。默认在私有ws可用时优先使用ws
+	RestOrderOnly bool `json:"rest_order_only"`
+
 	// 账号模式。见相应枚举
 	AccLevel okexv5api.AccLevel `json:"acc_level"`
 
@@ -83,6 +92,7 @@ type orderSnapshot struct {
 	filled     decimal.Decimal
 	avgPrice   decimal.Decimal
 	status     string
+	cancelSrc  string // 系统撤单的原因
 	updateTime time.Time
 	source     string
 }
@@ -97,6 +107,7 @@ func (os *orderSnapshot) Parse(resp okexv5api.OrderResp, source string) {
 	os.filled = util.String2DecimalPanic(resp.AccFillSize)
 	os.avgPrice = util.String2DecimalPanicUnless(resp.AvgPrice, "")
 	os.status = resp.Status
+	os.cancelSrc = resp.CancelSource
 	os.updateTime = util.ConvetUnix13StrToTimePanic(resp.UTime)
 }
 
@@ -0,0 +1,73 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 23:10:33
+ * @Description: okx错误码到common.ErrorCategory的映射
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package okexv5
+
+import (
+	"github.com/aztecqt/dagger/cex/common"
+)
+
+var errorCategories = map[string]common.ErrorCategory{
+	// 余额/保证金不足
+	"51008": common.ErrorCategory_InsufficientBalance,
+	"51119": common.ErrorCategory_InsufficientBalance,
+	"51127": common.ErrorCategory_InsufficientBalance,
+	"51131": common.ErrorCategory_InsufficientBalance,
+	"51502": common.ErrorCategory_InsufficientBalance,
+
+	// 限频
+	"50011": common.ErrorCategory_RateLimited,
+	"50061": common.ErrorCategory_RateLimited,
+
+	// 订单不存在（查询/撤单/改单）
+	"51603": common.ErrorCategory_OrderNotFound,
+	"51400": common.ErrorCategory_OrderNotFound,
+	"51503": common.ErrorCategory_OrderNotFound,
+
+	// 品种不存在、已到期、交割/结算中
+	"51001": common.ErrorCategory_InstrumentSuspended,
+	"51027": common.ErrorCategory_InstrumentSuspended,
+	"51028": common.ErrorCategory_InstrumentSuspended,
+	"51029": common.ErrorCategory_InstrumentSuspended,
+	"51030": common.ErrorCategory_InstrumentSuspended,
+
+	// 鉴权
+	"50100": common.ErrorCategory_AuthFailure,
+	"50101": common.ErrorCategory_AuthFailure,
+	"50102": common.ErrorCategory_AuthFailure,
+	"50103": common.ErrorCategory_AuthFailure,
+	"50104": common.ErrorCategory_AuthFailure,
+	"50105": common.ErrorCategory_AuthFailure,
+	"50110": common.ErrorCategory_AuthFailure,
+	"50111": common.ErrorCategory_AuthFailure,
+	"50113": common.ErrorCategory_AuthFailure,
+
+	// 服务端不可用/超时/繁忙
+	"50001": common.ErrorCategory_Network,
+	"50004": common.ErrorCategory_Network,
+	"50013": common.ErrorCategory_Network,
+}
+
+// 只挂单订单因会立即成交而被系统撤销时的cancelSource
+const cancelSource_PostOnlyCross = "31"
+
+// 由okx的code/sCode生成错误。code为"0"时返回nil
+func newExchangeError(code, msg string) error {
+	if code == "0" {
+		return nil
+	}
+
+	category, ok := errorCategories[code]
+	if !ok {
+		category = common.ErrorCategory_Unknown
+	}
+	return common.NewExchangeError(exchangeName, category, code, msg)
+}
+
+func newNetworkError(err error) error {
+	return common.NewNetworkError(exchangeName, err)
+}
@@ -0,0 +1,72 @@
+package okexv5
+
+// 检查okx错误码到错误分类、重试策略的映射
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aztecqt/dagger/cex/common"
+)
+
+func TestNewExchangeError(t *testing.T) {
+	cases := []struct {
+		code      string
+		category  common.ErrorCategory
+		retryable bool
+	}{
+		{"51008", common.ErrorCategory_InsufficientBalance, false},
+		{"50011", common.ErrorCategory_RateLimited, true},
+		{"50061", common.ErrorCategory_RateLimited, true},
+		{"51603", common.ErrorCategory_OrderNotFound, false},
+		{"51400", common.ErrorCategory_OrderNotFound, false},
+		{"51001", common.ErrorCategory_InstrumentSuspended, false},
+		{"50100", common.ErrorCategory_AuthFailure, false},
+		{"50113", common.ErrorCategory_AuthFailure, false},
+		{"50001", common.ErrorCategory_Network, true},
+		{"59999", common.ErrorCategory_Unknown, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.code, func(t *testing.T) {
+			err := newExchangeError(c.code, "msg")
+			if err == nil {
+				t.Fatalf("nil error")
+			}
+			if got := common.ErrorCategoryOf(err); got != c.category {
+				t.Fatalf("category = %v, want %v", got, c.category)
+			}
+			if !common.IsErrorCategory(err, c.category) {
+				t.Fatalf("IsErrorCategory false")
+			}
+			if p := common.RetryPolicyOf(err); p.Retryable != c.retryable || p.Delay(1) <= 0 {
+				t.Fatalf("retry policy = %+v, want retryable=%v", p, c.retryable)
+			}
+
+			var ee *common.ExchangeError
+			if !errors.As(err, &ee) || ee.Code != c.code || ee.Exchange != exchangeName {
+				t.Fatalf("error = %v", err)
+			}
+		})
+	}
+}
+
+func TestNewExchangeErrorSuccess(t *testing.T) {
+	if err := newExchangeError("0", ""); err != nil {
+		t.Fatalf("code 0 returns %v", err)
+	}
+}
+
+func TestNewNetworkError(t *testing.T) {
+	inner := errors.New("timeout")
+	err := newNetworkError(inner)
+	if !common.IsErrorCategory(err, common.ErrorCategory_Network) || !errors.Is(err, inner) {
+		t.Fatalf("error = %v", err)
+	}
+
+	// 退避从BaseDelay开始翻倍，不超过MaxDelay
+	p := common.RetryPolicyOf(err)
+	if p.Delay(2) != p.BaseDelay*2 || p.Delay(100) != p.MaxDelay {
+		t.Fatalf("delay(2)=%v delay(100)=%v, policy %+v", p.Delay(2), p.Delay(100), p)
+	}
+}
@@ -8,6 +8,7 @@
 package okexv5
 
 import (
+	"errors"
 	"fmt"
 	"maps"
 	"strconv"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/aztecqt/dagger/util/logger"
 
+	"github.com/aztecqt/dagger/api"
 	"github.com/aztecqt/dagger/api/okexv5api"
 	"github.com/aztecqt/dagger/api/okexv5api/cachedok"
 	"github.com/aztecqt/dagger/cex/common"
@@ -90,11 +92,12 @@ type Exchange struct {
 	contractInstIdsForMaxAvail []string
 	muMaxAvailable             sync.RWMutex
 
-	// 订单。订单保存在trader中，ex不需要持有
-	// ex负责分发现货订单更新
+	// 订单。订单保存在trader中，同时登记在订单管理器里
+	// 订单更新由订单管理器按clientId分发，trader只做归属检查
 	// instId->fn
 	orderSnapshotFns map[string]OnOrderSnapshotFn
 	muOSFn           sync.RWMutex
+	orderMgr         *orderManager
 
 	// 所有交易对的行情信息的拉取和通知。根据配置决定是否启用
 	// 行情推送给注册过的回调函数
@@ -130,6 +133,7 @@ func (e *Exchange) Init(key, secret, pass string, excfg *ExchangeConfig, ecb fun
 	e.ctPositions = make(map[string]*common.PositionImpl)
 	e.positionInstTypes = make(map[string]int)
 	e.orderSnapshotFns = make(map[string]OnOrderSnapshotFn)
+	e.orderMgr = newOrderManager()
 	e.contractObservers = make(map[string]*ContractObserver)
 	e.tickerCallbacks = make(map[string][]func(t okexv5api.TickerResp))
 	e.tickerCallbacksOfInstType = make(map[string][]func(tks []okexv5api.TickerResp))
@@ -159,6 +163,10 @@ func (e *Exchange) Init(key, secret, pass string, excfg *ExchangeConfig, ecb fun
 	// 启动ws
 	logger.LogImportant(logPrefix, "starting websocket...")
 	e.ws = new(okexv5api.WsClient)
+	wscfg := api.DefaultWsPoolConfig()
+	wscfg.Shards = max(e.excfg.PublicWsShards, 1)
+	wscfg.Compression = e.excfg.WsCompression
+	e.ws.SetPublicPoolConfig(wscfg)
 	e.ws.Start()
 
 	// 启动rest拉取ticker
@@ -183,6 +191,7 @@ func (e *Exchange) Init(key, secret, pass string, excfg *ExchangeConfig, ecb fun
 
 		// 订阅订单，处理逻辑类似。区别是instId放在每个order数据单元里，而不是消息头部
 		go e.updateOrders()
+		e.orderMgr.start()
 
 		// 订阅市场爆仓订单
 		go e.updateLiquidationOrders()
@@ -556,6 +565,7 @@ func GetKline(instId string, t0, t1 time.Time, intervalSec int) []common.KUnit {
 func (e *Exchange) Exit() {
 	// 这样会停止一切下单行为
 	exchangeReady = false
+	e.orderMgr.stop()
 
 	// 撤销所有订单
 	e.CloseAllOrders()
@@ -735,15 +745,16 @@ func (e *Exchange) updateOrders() {
 			defer e.muOSFn.RUnlock()
 			defer timeout.Reset(time.Second * 20)
 
-			// 根据instId进行推送
+			// 根据instId推送给trader做归属检查，再根据clientId推送给订单
 			for i := 0; i < len(arr); i++ {
 				d := arr[i]
+				os := orderSnapshot{}
+				os.localTime = r.LocalTime
+				os.Parse(d, "ws")
 				if fn, ok := e.orderSnapshotFns[d.InstId]; ok {
-					os := orderSnapshot{}
-					os.localTime = r.LocalTime
-					os.Parse(d, "ws")
 					fn(os)
 				}
+				e.orderMgr.onSnapshot(os)
 			}
 		}()
 	})
@@ -986,6 +997,79 @@ func (e *Exchange) CloseAllOrders() {
 	}
 }
 
+// 实现common.TaggedOrdersReconciler
+func (e *Exchange) PendingTaggedOrders() ([]common.PendingOrder, error) {
+	pending, _, ok := e.orderMgr.fetchPending()
+	if !ok {
+		return nil, errors.New("get pending orders failed")
+	}
+
+	orders := make([]common.PendingOrder, 0, len(pending))
+	for _, d := range pending {
+		orders = append(orders, common.PendingOrder{
+			InstId:        d.InstId,
+			OrderId:       d.OrderId,
+			ClientOrderId: d.ClientOrderId,
+			Price:         util.String2DecimalPanicUnless(d.Price, ""),
+			Size:          util.String2DecimalPanicUnless(d.Size, ""),
+			Filled:        util.String2DecimalPanicUnless(d.AccFillSize, ""),
+		})
+	}
+	return orders, nil
+}
+
+// 实现common.TaggedOrdersReconciler。每20个一批
+func (e *Exchange) CancelPendingOrders(orders []common.PendingOrder) []common.OrderResult {
+	results := make([]common.OrderResult, len(orders))
+	for i0 := 0; i0 < len(orders); i0 += 20 {
+		i1 := util.MinInt(i0+20, len(orders))
+		reqs := make([]okexv5api.CancelBatchOrderRestReq, 0, i1-i0)
+		byOrderId := make(map[string]int)
+		for i := i0; i < i1; i++ {
+			reqs = append(reqs, okexv5api.CancelBatchOrderRestReq{InstId: orders[i].InstId, OrderId: orders[i].OrderId})
+			byOrderId[orders[i].OrderId] = i
+		}
+
+		resp, err := okexv5api.CancelOrderBatch(reqs)
+		if err != nil {
+			for i := i0; i < i1; i++ {
+				results[i].Err = newNetworkError(err)
+			}
+			continue
+		}
+
+		for _, d := range resp.Data {
+			if i, ok := byOrderId[d.OrderId]; ok {
+				results[i].Err = newExchangeError(d.SCode, d.SMsg)
+			}
+		}
+
+		if len(resp.Data) == 0 {
+			for i := i0; i < i1; i++ {
+				results[i].Err = newExchangeError(resp.Code, resp.Msg)
+			}
+		}
+	}
+	return results
+}
+
+// 实现common.CancelAllAfterSupporter。只撤销本策略（tag）的订单
+// okx的倒计时范围为10~120秒
+func (e *Exchange) CancelAllAfter(timeout time.Duration) error {
+	sec := 0
+	if timeout > 0 {
+		sec = util.ClampInt(int(timeout.Seconds()), 10, 120)
+	}
+
+	resp, err := okexv5api.CancelAllAfter(sec, orderTag())
+	if err != nil {
+		return err
+	} else if resp.Code != "0" {
+		return fmt.Errorf("code=%s, msg=%s", resp.Code, resp.Msg)
+	}
+	return nil
+}
+
 func (e *Exchange) getMaxAvailable(instId string) (okexv5api.MaxAvailableSizeResp, bool) {
 	// usdt合约只查询一次，统一按btc来
 	if strings.Contains(instId, "USDT-SWAP") {
@@ -94,22 +94,12 @@ func (t *FutureTrader) Init(ex *Exchange, orderTag string, m *FutureMarket, leve
 	t.pos = ex.findPosition(m.instId)
 
 	// 订阅order信息
+	// 订单本身的刷新由订单管理器负责，这里只检查订单归属
 	ex.RegOrderSnapshot(m.instId, func(os orderSnapshot) {
-		var o *ContractOrder = nil
-		var ok bool = false
-
 		if len(os.tag) > 0 && os.tag != orderTag {
 			t.errorlock = true
 			logger.LogPanic(t.logPrefix, "found order from other stratergy(%s)!", os.tag)
 		}
-
-		t.muOrders.RLock()
-		o, ok = t.orders[os.clientId]
-		t.muOrders.RUnlock()
-
-		if ok {
-			o.onSnapshot(os)
-		}
 	})
 
 	// 清理finished orders
@@ -249,6 +239,40 @@ func (t *FutureTrader) MakeOrder(
 	}
 }
 
+// 批量下单。每20个一批提交
+func (t *FutureTrader) MakeOrders(reqs []common.OrderRequest) []common.OrderResult {
+	if !t.Ready() {
+		logger.LogInfo(t.logPrefix, "trader not ready, can't Makeorders. reason=%s", t.UnreadyReason())
+		results := make([]common.OrderResult, len(reqs))
+		for i := range results {
+			results[i].Err = common.ErrTraderNotReady
+		}
+		return results
+	}
+
+	return makeOrdersBatch(t.exchange.orderMgr.ctx, t.exchange.ws, reqs, func(r common.OrderRequest) (common.Order, *CommonOrder) {
+		o := new(ContractOrder)
+		if o.Init(t, r.Price, r.Amount, r.Dir, r.MakeOnly, r.ReduceOnly, r.Purpose) {
+			t.muOrders.Lock()
+			t.orders[o.CltOrderId.(string)] = o
+			t.muOrders.Unlock()
+			o.AddObserver(t)          // 先内部处理
+			o.AddObserver(r.Observer) // 再外部处理
+			return o, &o.CommonOrder
+		} else {
+			return nil, nil
+		}
+	})
+}
+
+func (t *FutureTrader) ModifyOrders(reqs []common.OrderModifyRequest) []common.OrderResult {
+	return modifyOrdersBatch(t.exchange.orderMgr.ctx, t.exchange.ws, reqs)
+}
+
+func (t *FutureTrader) CancelOrders(orders []common.Order) []common.OrderResult {
+	return cancelOrdersBatch(t.exchange.ws, orders)
+}
+
 func (t *FutureTrader) Orders() []common.Order {
 	orders := make([]common.Order, 0, len(t.orders))
 	for _, o := range t.orders {
@@ -328,6 +352,10 @@ func (t *FutureTrader) AssetId() int {
 }
 
 func (t *FutureTrader) Position() common.Position {
+	// 避免把nil指针包装成非nil接口
+	if t.pos == nil {
+		return nil
+	}
 	return t.pos
 }
 
@@ -0,0 +1,364 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 22:20:41
+ * @Description: okx订单管理器。每个Exchange一个，持有所有存活订单，取代每个订单各自的刷新协程
+ * ws订单快照按clientId分发给订单；长时间没有快照或需要立即刷新的订单，统一通过未成交订单列表对账，
+ * 不在列表中的订单先用成交明细判断是否已完全成交，仍无法确认的才逐个查询
+ * 同时检查卡住的订单（长时间无法确认状态）和未知订单（交易所有、本地没有）
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package okexv5
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/api/okexv5api"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	orderRefreshTimeout     = time.Second * 10 // 超过这个时间没有收到快照，需要rest对账
+	orderStuckTimeout       = time.Minute      // 超过这个时间仍未确认订单id，视为卡住
+	orderQueryLimitPerRound = 10               // 每轮最多单独查询的订单数，其余留到下一轮
+	orderUnknownConfirm     = 3                // 未知订单连续出现这么多轮才报告，避免误报刚提交、尚未登记的订单
+	pendingOrdersPageSize   = 100
+)
+
+type managedOrder struct {
+	o           *CommonOrder
+	addTime     time.Time
+	creating    bool      // 正在创建，创建结束前不对账
+	refreshTime time.Time // 最近一次收到快照或发起对账的时间
+	refreshImm  bool      // 需要立即对账
+	stuckLogged time.Time
+}
+
+type orderManager struct {
+	logPrefix string
+	orders    map[string] /*clientId*/ *managedOrder
+	mu        sync.Mutex
+
+	// 订单的创建、改单、查询请求都派生自这个ctx，退出时取消
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// 以下只在对账协程中访问
+	unknown       map[string] /*clientId*/ int
+	lastFullCheck time.Time
+
+	// 对账用到的rest请求，默认使用okexv5api，测试时替换
+	getPending func(after string) (*okexv5api.OrderRestResp, error)
+	getFills   func(instId string, t0 time.Time) (*okexv5api.FillsResp, error)
+	getOrder   func(instId, clientId string) (*okexv5api.OrderRestResp, error)
+}
+
+func newOrderManager() *orderManager {
+	m := new(orderManager)
+	m.logPrefix = fmt.Sprintf("%s-OrderMgr", logPrefix)
+	m.orders = make(map[string]*managedOrder)
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.unknown = make(map[string]int)
+	m.getPending = func(after string) (*okexv5api.OrderRestResp, error) {
+		return okexv5api.GetPendingOrdersAfter("", after)
+	}
+	m.getFills = func(instId string, t0 time.Time) (*okexv5api.FillsResp, error) {
+		return okexv5api.GetFills(instId, t0, time.Time{})
+	}
+	m.getOrder = func(instId, clientId string) (*okexv5api.OrderRestResp, error) {
+		return okexv5api.GetOrderInfoCtx(m.ctx, instId, 0, clientId)
+	}
+	return m
+}
+
+func (m *orderManager) start() {
+	go m.update()
+}
+
+// 停止对账，并取消进行中的订单请求
+func (m *orderManager) stop() {
+	m.cancel()
+}
+
+// 登记订单并启动创建。订单结束后自动移除
+func (m *orderManager) add(o *CommonOrder) {
+	mo := m.register(o)
+	if mo.creating {
+		go m.create(mo)
+	}
+}
+
+// 只登记订单，不启动创建。批量下单在提交前登记，提交期间到达的ws快照才能分发到订单
+// 提交结果确定后调用onCreated，订单才开始参与对账
+func (m *orderManager) register(o *CommonOrder) *managedOrder {
+	now := time.Now()
+	mo := &managedOrder{o: o, addTime: now, refreshTime: now}
+	mo.creating = o.OrderId == 0 && !o.submitted
+
+	m.mu.Lock()
+	m.orders[o.CltOrderId.(string)] = mo
+	m.mu.Unlock()
+	return mo
+}
+
+func (m *orderManager) create(mo *managedOrder) {
+	mo.o.create()
+	m.onCreated(mo.o)
+}
+
+// 创建结束（无论成败），开始对账
+func (m *orderManager) onCreated(o *CommonOrder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mo, ok := m.orders[o.CltOrderId.(string)]; ok {
+		mo.creating = false
+		mo.refreshTime = time.Now()
+	}
+}
+
+// 下一轮立即对账
+func (m *orderManager) refreshImm(clientId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mo, ok := m.orders[clientId]; ok {
+		mo.refreshImm = true
+	}
+}
+
+// ws订单快照，按clientId分发
+func (m *orderManager) onSnapshot(os orderSnapshot) {
+	m.mu.Lock()
+	mo, ok := m.orders[os.clientId]
+	if ok {
+		mo.refreshTime = time.Now()
+	}
+	m.mu.Unlock()
+
+	if ok {
+		mo.o.onSnapshot(os)
+	}
+}
+
+func (m *orderManager) update() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.reconcile()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// 一轮对账
+func (m *orderManager) reconcile() {
+	defer util.DefaultRecover()
+
+	now := time.Now()
+	due := make([]*managedOrder, 0)
+	m.mu.Lock()
+	for cid, mo := range m.orders {
+		if mo.o.IsFinished() {
+			delete(m.orders, cid)
+		} else if !mo.creating && (mo.refreshImm || now.Sub(mo.refreshTime) >= orderRefreshTimeout) {
+			due = append(due, mo)
+		}
+	}
+	m.mu.Unlock()
+
+	// 没有需要对账的订单时，也定期拉一次未成交列表，用于检查未知订单
+	if len(due) == 0 && now.Sub(m.lastFullCheck) < orderRefreshTimeout {
+		return
+	}
+
+	pending, localTime, ok := m.fetchPending()
+	if !ok {
+		return // 下一轮重试
+	}
+	m.lastFullCheck = now
+
+	missing := make([]*managedOrder, 0)
+	for _, mo := range due {
+		m.mu.Lock()
+		mo.refreshImm = false
+		mo.refreshTime = now
+		m.mu.Unlock()
+
+		if d, ok := pending[mo.o.CltOrderId.(string)]; ok {
+			os := orderSnapshot{}
+			os.localTime = localTime
+			os.Parse(d, "rest")
+			mo.o.onSnapshot(os)
+		} else {
+			missing = append(missing, mo)
+		}
+	}
+
+	m.resolveMissing(missing)
+	m.checkUnknown(pending)
+	m.checkStuck(now)
+}
+
+// 拉取本策略的全部未成交订单
+func (m *orderManager) fetchPending() (map[string]okexv5api.OrderResp, time.Time, bool) {
+	pending := make(map[string]okexv5api.OrderResp)
+	localTime := time.Time{}
+	after := ""
+	for {
+		resp, err := m.getPending(after)
+		if err != nil {
+			logger.LogImportant(m.logPrefix, "get pending orders failed: %s", err.Error())
+			return nil, localTime, false
+		} else if resp.Code != "0" {
+			logger.LogImportant(m.logPrefix, "get pending orders failed, code=%s, msg=%s", resp.Code, resp.Msg)
+			return nil, localTime, false
+		}
+
+		if localTime.IsZero() {
+			localTime = resp.LocalTime
+		}
+
+		for _, d := range resp.Data {
+			if d.Tag == orderTag() {
+				pending[d.ClientOrderId] = d
+			}
+		}
+
+		if len(resp.Data) < pendingOrdersPageSize {
+			break
+		}
+		after = resp.Data[len(resp.Data)-1].OrderId
+	}
+
+	return pending, localTime, true
+}
+
+// 处理不在未成交列表中的订单（已结束，或尚未被交易所受理）
+// 每个品种查一次成交明细，完全成交的订单直接结束；其余订单逐个查询
+func (m *orderManager) resolveMissing(missing []*managedOrder) {
+	if len(missing) == 0 {
+		return
+	}
+
+	byInstId := make(map[string][]*managedOrder)
+	for _, mo := range missing {
+		byInstId[mo.o.InstId] = append(byInstId[mo.o.InstId], mo)
+	}
+
+	queries := make([]*managedOrder, 0)
+	for instId, mos := range byInstId {
+		t0 := mos[0].addTime
+		for _, mo := range mos {
+			if mo.addTime.Before(t0) {
+				t0 = mo.addTime
+			}
+		}
+
+		fills := make(map[string][]okexv5api.Fills)
+		resp, err := m.getFills(instId, t0.Add(-time.Second*5))
+		if err != nil {
+			logger.LogImportant(m.logPrefix, "get fills of %s failed: %s", instId, err.Error())
+		} else if resp.Code != "0" {
+			logger.LogImportant(m.logPrefix, "get fills of %s failed, code=%s, msg=%s", instId, resp.Code, resp.Msg)
+		} else {
+			for _, f := range resp.Data {
+				fills[f.ClientOrderId] = append(fills[f.ClientOrderId], f)
+			}
+		}
+
+		for _, mo := range mos {
+			if fs, ok := fills[mo.o.CltOrderId.(string)]; ok {
+				if os, ok := snapshotFromFills(mo.o, fs); ok {
+					mo.o.onSnapshot(os)
+				}
+			}
+
+			if !mo.o.IsFinished() {
+				queries = append(queries, mo)
+			}
+		}
+	}
+
+	for i, mo := range queries {
+		if i < orderQueryLimitPerRound {
+			mo.o.doRestRefresh()
+		} else {
+			m.refreshImm(mo.o.CltOrderId.(string))
+		}
+	}
+}
+
+// 由成交明细合成订单快照。只有累计成交达到订单数量时才能确认订单已完全成交
+func snapshotFromFills(o *CommonOrder, fs []okexv5api.Fills) (orderSnapshot, bool) {
+	os := orderSnapshot{}
+	filled := decimal.Zero
+	notional := decimal.Zero
+	for _, f := range fs {
+		filled = filled.Add(f.Size)
+		notional = notional.Add(f.Size.Mul(f.Price))
+		if f.FillTime.After(os.updateTime) {
+			os.updateTime = f.FillTime
+		}
+		if os.id == 0 && len(f.OrderId) > 0 {
+			os.id = util.String2Int64Panic(f.OrderId)
+		}
+	}
+
+	if !o.Size.IsPositive() || filled.LessThan(o.Size) {
+		return os, false
+	}
+
+	os.source = "fills"
+	os.localTime = time.Now()
+	os.clientId = o.CltOrderId.(string)
+	os.tag = orderTag()
+	os.price = o.Price
+	os.size = o.Size
+	os.filled = filled
+	os.avgPrice = notional.Div(filled)
+	os.status = okexv5api.OrderStatus_Filled
+	return os, true
+}
+
+// 交易所有、本地没有的订单。连续出现多轮后报告一次
+func (m *orderManager) checkUnknown(pending map[string]okexv5api.OrderResp) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for cid := range m.unknown {
+		if _, ok := pending[cid]; !ok {
+			delete(m.unknown, cid)
+		}
+	}
+
+	for cid, d := range pending {
+		if _, ok := m.orders[cid]; ok {
+			delete(m.unknown, cid)
+			continue
+		}
+
+		m.unknown[cid]++
+		if m.unknown[cid] == orderUnknownConfirm {
+			logger.LogImportant(m.logPrefix, "unknown order on exchange: instId=%s, ordId=%s, clOrdId=%s, px=%s, sz=%s", d.InstId, d.OrderId, cid, d.Price, d.Size)
+		}
+	}
+}
+
+// 创建结束后长时间拿不到订单id的订单。每分钟报告一次
+func (m *orderManager) checkStuck(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, mo := range m.orders {
+		if mo.o.OrderId == 0 && !mo.o.IsFinished() && now.Sub(mo.addTime) > orderStuckTimeout && now.Sub(mo.stuckLogged) > orderStuckTimeout {
+			mo.stuckLogged = now
+			logger.LogImportant(m.logPrefix, "order stuck for %v, creating=%v, o=%s", now.Sub(mo.addTime).Truncate(time.Second), mo.creating, mo.o.String())
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package okexv5
+
+// 替换订单管理器的rest请求，直接调用reconcile，检查对账和遗漏订单的处理
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aztecqt/dagger/api/okexv5api"
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+func TestMain(m *testing.M) {
+	logger.Setup(logger.NewStdoutSink(logger.ConsoleEncoder{}, logger.LogLevel_Important))
+	os.Exit(m.Run())
+}
+
+const testInstId = "BTC-USDT"
+
+// 交易所端的假数据
+type fakeOrderRest struct {
+	pending    []okexv5api.OrderResp
+	pendingErr error
+	fills      []okexv5api.Fills
+	orders     map[string]okexv5api.OrderResp // 单独查询的结果，不在其中的返回订单不存在
+	queried    []string
+}
+
+func (f *fakeOrderRest) install(m *orderManager) {
+	m.getPending = func(after string) (*okexv5api.OrderRestResp, error) {
+		if f.pendingErr != nil {
+			return nil, f.pendingErr
+		}
+		resp := &okexv5api.OrderRestResp{LocalTime: time.Now()}
+		resp.Code = "0"
+		resp.Data = f.pending
+		return resp, nil
+	}
+	m.getFills = func(instId string, t0 time.Time) (*okexv5api.FillsResp, error) {
+		resp := &okexv5api.FillsResp{}
+		resp.Code = "0"
+		resp.Data = f.fills
+		return resp, nil
+	}
+	m.getOrder = func(instId, clientId string) (*okexv5api.OrderRestResp, error) {
+		f.queried = append(f.queried, clientId)
+		resp := &okexv5api.OrderRestResp{LocalTime: time.Now()}
+		if d, ok := f.orders[clientId]; ok {
+			resp.Code = "0"
+			resp.Data = []okexv5api.OrderResp{d}
+		} else {
+			resp.Code = "51603"
+			resp.Msg = "Order does not exist"
+		}
+		return resp, nil
+	}
+}
+
+func orderResp(cid string, id int64, size, filled float64, status string) okexv5api.OrderResp {
+	avgPx := ""
+	if filled > 0 {
+		avgPx = "100"
+	}
+	return okexv5api.OrderResp{
+		InstId:        testInstId,
+		OrderId:       strconv.FormatInt(id, 10),
+		ClientOrderId: cid,
+		Tag:           orderTag(),
+		Price:         "100",
+		Size:          fmt.Sprint(size),
+		AccFillSize:   fmt.Sprint(filled),
+		AvgPrice:      avgPx,
+		Status:        status,
+		UTime:         strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}
+}
+
+func fill(cid string, id int64, px, sz float64) okexv5api.Fills {
+	return okexv5api.Fills{
+		InstId:        testInstId,
+		OrderId:       strconv.FormatInt(id, 10),
+		ClientOrderId: cid,
+		Price:         decimal.NewFromFloat(px),
+		Size:          decimal.NewFromFloat(sz),
+		FillTime:      time.Now(),
+	}
+}
+
+// 登记一个已创建、等待对账的订单
+func addTestOrder(m *orderManager, cid string, id int64, size float64) *CommonOrder {
+	o := new(CommonOrder)
+	o.LogPrefix = "test-" + cid
+	o.InstId = testInstId
+	o.CltOrderId = cid
+	o.OrderId = id
+	o.Price = decimal.NewFromInt(100)
+	o.Size = decimal.NewFromFloat(size)
+	o.Dir = common.OrderDir_Buy
+	o.mgr = m
+
+	mo := m.register(o)
+	mo.creating = false
+	mo.refreshTime = time.Now().Add(-orderRefreshTimeout)
+	return o
+}
+
+func TestOrderManagerReconcile(t *testing.T) {
+	type want struct {
+		finished bool
+		fatal    bool
+		filled   float64
+	}
+
+	cases := []struct {
+		name    string
+		rest    fakeOrderRest
+		orders  map[string]float64 // clientId-订单数量
+		want    map[string]want
+		queried []string
+	}{
+		{
+			name:   "pending order updated from list",
+			rest:   fakeOrderRest{pending: []okexv5api.OrderResp{orderResp("a", 1, 2, 0.5, okexv5api.OrderStatus_PartiallyFilled)}},
+			orders: map[string]float64{"a": 2},
+			want:   map[string]want{"a": {filled: 0.5}},
+		},
+		{
+			name:   "missing order fully filled by fills",
+			rest:   fakeOrderRest{fills: []okexv5api.Fills{fill("a", 1, 100, 1), fill("a", 1, 100, 1)}},
+			orders: map[string]float64{"a": 2},
+			want:   map[string]want{"a": {finished: true, filled: 2}},
+		},
+		{
+			name: "missing order partly filled is queried",
+			rest: fakeOrderRest{
+				fills:  []okexv5api.Fills{fill("a", 1, 100, 1)},
+				orders: map[string]okexv5api.OrderResp{"a": orderResp("a", 1, 2, 1, okexv5api.OrderStatus_Canceled)},
+			},
+			orders:  map[string]float64{"a": 2},
+			want:    map[string]want{"a": {finished: true, filled: 1}},
+			queried: []string{"a"},
+		},
+		{
+			name:    "missing order not found on exchange",
+			orders:  map[string]float64{"a": 2},
+			want:    map[string]want{"a": {fatal: true}},
+			queried: []string{"a"},
+		},
+		{
+			name:   "pending list failure leaves orders untouched",
+			rest:   fakeOrderRest{pendingErr: errors.New("timeout")},
+			orders: map[string]float64{"a": 2},
+			want:   map[string]want{"a": {}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newOrderManager()
+			defer m.stop()
+			rest := c.rest
+			rest.install(m)
+
+			orders := map[string]*CommonOrder{}
+			id := int64(1)
+			for cid, sz := range c.orders {
+				orders[cid] = addTestOrder(m, cid, id, sz)
+				id++
+			}
+
+			m.reconcile()
+
+			for cid, w := range c.want {
+				o := orders[cid]
+				if o.Finished != w.finished || o.FatalError != w.fatal || !o.Filled.Equal(decimal.NewFromFloat(w.filled)) {
+					t.Errorf("%s: finished=%v fatal=%v filled=%v, want %+v", cid, o.Finished, o.FatalError, o.Filled, w)
+				}
+			}
+
+			if fmt.Sprint(rest.queried) != fmt.Sprint(c.queried) {
+				t.Errorf("queried %v, want %v", rest.queried, c.queried)
+			}
+		})
+	}
+}
+
+func TestOrderManagerDueOrders(t *testing.T) {
+	m := newOrderManager()
+	defer m.stop()
+	rest := fakeOrderRest{}
+	rest.install(m)
+
+	// 创建中、刚刷新过的订单不参与对账；需要立即对账的订单参与
+	creating := addTestOrder(m, "creating", 1, 1)
+	m.orders["creating"].creating = true
+	fresh := addTestOrder(m, "fresh", 2, 1)
+	m.orders["fresh"].refreshTime = time.Now()
+	imm := addTestOrder(m, "imm", 3, 1)
+	m.orders["imm"].refreshTime = time.Now()
+	m.refreshImm("imm")
+
+	// 已结束的订单从管理器中移除
+	done := addTestOrder(m, "done", 4, 1)
+	done.Finished = true
+
+	m.reconcile()
+
+	if fmt.Sprint(rest.queried) != "[imm]" {
+		t.Fatalf("queried %v, want [imm]", rest.queried)
+	}
+	if creating.IsFinished() || fresh.IsFinished() || !imm.IsFinished() {
+		t.Fatalf("creating=%v fresh=%v imm=%v", creating.IsFinished(), fresh.IsFinished(), imm.IsFinished())
+	}
+	if _, ok := m.orders["done"]; ok {
+		t.Fatalf("finished order not removed")
+	}
+	if m.orders["imm"].refreshImm {
+		t.Fatalf("refreshImm not cleared")
+	}
+}
+
+func TestOrderManagerQueryLimit(t *testing.T) {
+	m := newOrderManager()
+	defer m.stop()
+	rest := fakeOrderRest{orders: map[string]okexv5api.OrderResp{}}
+	rest.install(m)
+
+	n := orderQueryLimitPerRound + 3
+	for i := 0; i < n; i++ {
+		cid := fmt.Sprintf("o%d", i)
+		addTestOrder(m, cid, int64(i+1), 1)
+		rest.orders[cid] = orderResp(cid, int64(i+1), 1, 0, okexv5api.OrderStatus_Alive)
+	}
+
+	// 超出单轮查询上限的订单留到下一轮立即对账
+	m.reconcile()
+	if len(rest.queried) != orderQueryLimitPerRound {
+		t.Fatalf("queried %d orders, want %d", len(rest.queried), orderQueryLimitPerRound)
+	}
+
+	imm := 0
+	for _, mo := range m.orders {
+		if mo.refreshImm {
+			imm++
+		}
+	}
+	if imm != n-orderQueryLimitPerRound {
+		t.Fatalf("%d orders deferred, want %d", imm, n-orderQueryLimitPerRound)
+	}
+
+	m.reconcile()
+	if len(rest.queried) != n {
+		t.Fatalf("queried %d orders after 2 rounds, want %d", len(rest.queried), n)
+	}
+}
+
+func TestOrderManagerUnknown(t *testing.T) {
+	cases := []struct {
+		name   string
+		rounds [][]string // 每轮交易所上的挂单
+		want   map[string]int
+	}{
+		{"counted per round", [][]string{{"x"}, {"x"}}, map[string]int{"x": 2}},
+		{"reset when gone", [][]string{{"x", "y"}, {"y"}, {"x", "y"}}, map[string]int{"x": 1, "y": 3}},
+		{"local orders ignored", [][]string{{"local"}, {"local"}}, map[string]int{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newOrderManager()
+			defer m.stop()
+			addTestOrder(m, "local", 1, 1)
+
+			for _, round := range c.rounds {
+				pending := map[string]okexv5api.OrderResp{}
+				for _, cid := range round {
+					pending[cid] = orderResp(cid, 100, 1, 0, okexv5api.OrderStatus_Alive)
+				}
+				m.checkUnknown(pending)
+			}
+
+			if fmt.Sprint(m.unknown) != fmt.Sprint(c.want) {
+				t.Fatalf("unknown = %v, want %v", m.unknown, c.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotFromFills(t *testing.T) {
+	cases := []struct {
+		name  string
+		size  float64
+		fills []okexv5api.Fills
+		ok    bool
+		avg   float64
+	}{
+		{"single full fill", 1, []okexv5api.Fills{fill("a", 1, 100, 1)}, true, 100},
+		{"multiple fills", 2, []okexv5api.Fills{fill("a", 1, 100, 1), fill("a", 1, 102, 1)}, true, 101},
+		{"partial fill", 2, []okexv5api.Fills{fill("a", 1, 100, 1)}, false, 0},
+		{"unknown size", 0, []okexv5api.Fills{fill("a", 1, 100, 1)}, false, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := new(CommonOrder)
+			o.CltOrderId = "a"
+			o.Size = decimal.NewFromFloat(c.size)
+			os, ok := snapshotFromFills(o, c.fills)
+			if ok != c.ok {
+				t.Fatalf("ok = %v, want %v", ok, c.ok)
+			}
+			if ok && (!os.avgPrice.Equal(decimal.NewFromFloat(c.avg)) || os.status != okexv5api.OrderStatus_Filled || os.id != 1) {
+				t.Fatalf("snapshot = %s", os.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 09:34:10
+ * @Description: 注册到交易所注册表
+ * 配置类型为*ExchangeConfig：未配置时为nil，使用newExchangeConfig的默认值；配置了则以配置为准，与原来的行为一致
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package okexv5
+
+import (
+	"github.com/aztecqt/dagger/api/okexv5api"
+	"github.com/aztecqt/dagger/cex/common"
+)
+
+const RegistryName = "okex"
+
+func init() {
+	common.RegisterExchange(
+		RegistryName,
+		common.Credential_KeySecretPassword,
+		true,
+		func() *ExchangeConfig { return nil },
+		func(cfg *ExchangeConfig, cred common.Credential, opt common.ExchangeOptions) (common.CEx, error) {
+			if len(opt.OrderTag) > 0 {
+				StratergyName = opt.OrderTag
+			}
+			if opt.ProxySelector != nil {
+				okexv5api.SetProxySelector(opt.ProxySelector)
+			}
+			ex := new(Exchange)
+			ex.Init(cred.Key, cred.Secret, cred.Password, cfg, opt.ErrorNotifier)
+			return ex, nil
+		})
+}
@@ -33,6 +33,10 @@ func (o *SpotOrder) Init(
 	if o.CommonOrder.Init(trader, trader.ex.instrumentMgr, trader.market.instId, price, amount, dir, makeOnly, false, purpose) {
 		o.CommonOrder.getPosSide = o.getPosSide
 		o.CommonOrder.tradeMode = o.tradeMode
+		o.CommonOrder.mgr = o.trader.ex.orderMgr
+		if !o.trader.ex.excfg.RestOrderOnly {
+			o.CommonOrder.ws = o.trader.ex.ws
+		}
 		return true
 	} else {
 		return false
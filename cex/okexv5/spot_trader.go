@@ -54,22 +54,12 @@ func (t *SpotTrader) Init(ex *Exchange, orderTag string, m *SpotMarket) {
 	t.quoteBalance = ex.balanceMgr.FindBalance(t.market.QuoteCurrency())
 
 	// 订阅order信息
+	// 订单本身的刷新由订单管理器负责，这里只检查订单归属
 	ex.RegOrderSnapshot(m.instId, func(os orderSnapshot) {
-		var o *SpotOrder = nil
-		var ok bool = false
-
 		if len(os.tag) > 0 && os.tag != orderTag {
 			t.errorlock = true
 			logger.LogPanic(t.logPrefix, "found order from other stratergy(%s)!", os.tag)
 		}
-
-		t.muOrders.RLock()
-		o, ok = t.orders[os.clientId]
-		t.muOrders.RUnlock()
-
-		if ok {
-			o.onSnapshot(os)
-		}
 	})
 
 	// 清理finished orders
@@ -199,6 +189,40 @@ func (t *SpotTrader) MakeOrder(
 	}
 }
 
+// 批量下单。每20个一批提交
+func (t *SpotTrader) MakeOrders(reqs []common.OrderRequest) []common.OrderResult {
+	if !t.Ready() {
+		logger.LogInfo(t.logPrefix, "trader not ready, can't Makeorders. reason=%s", t.UnreadyReason())
+		results := make([]common.OrderResult, len(reqs))
+		for i := range results {
+			results[i].Err = common.ErrTraderNotReady
+		}
+		return results
+	}
+
+	return makeOrdersBatch(t.ex.orderMgr.ctx, t.ex.ws, reqs, func(r common.OrderRequest) (common.Order, *CommonOrder) {
+		o := new(SpotOrder)
+		if o.Init(t, r.Price, r.Amount, r.Dir, r.MakeOnly, r.Purpose) {
+			t.muOrders.Lock()
+			t.orders[o.CltOrderId.(string)] = o
+			t.muOrders.Unlock()
+			o.AddObserver(t)          // 先内部处理
+			o.AddObserver(r.Observer) // 再外部处理
+			return o, &o.CommonOrder
+		} else {
+			return nil, nil
+		}
+	})
+}
+
+func (t *SpotTrader) ModifyOrders(reqs []common.OrderModifyRequest) []common.OrderResult {
+	return modifyOrdersBatch(t.ex.orderMgr.ctx, t.ex.ws, reqs)
+}
+
+func (t *SpotTrader) CancelOrders(orders []common.Order) []common.OrderResult {
+	return cancelOrdersBatch(t.ex.ws, orders)
+}
+
 func (t *SpotTrader) Orders() []common.Order {
 	orders := make([]common.Order, 0, len(t.orders))
 
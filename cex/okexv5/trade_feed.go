@@ -0,0 +1,180 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 19:20:14
+ * @Description: okx的逐笔成交数据源。实现common.TradeFeed
+ * 实时数据来自trades频道，公共连接重连后通知使用者补全，补全数据来自history-trades接口
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package okexv5
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aztecqt/dagger/api/okexv5api"
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+// 补全成交时最多翻多少页（每页100条）
+const tradeFeedMaxFetchPages = 100
+
+type TradeFeed struct {
+	ws        *okexv5api.WsClient
+	instId    string
+	inst      common.Instruments
+	logPrefix string
+	connChan  chan int
+	running   bool
+}
+
+func (e *Exchange) NewTradeFeed(instId string) *TradeFeed {
+	inst := e.instrumentMgr.Get(instId)
+	if inst == nil {
+		logger.LogImportant(logPrefix, "trade feed: instrument %s not found", instId)
+		return nil
+	}
+
+	f := new(TradeFeed)
+	f.ws = e.ws
+	f.instId = instId
+	f.inst = *inst
+	f.logPrefix = fmt.Sprintf("%s-TradeFeed-%s", logPrefix, instId)
+	return f
+}
+
+func (f *TradeFeed) InstId() string {
+	return f.instId
+}
+
+func (f *TradeFeed) Start(fnTrade func(common.MarketTrade), fnGap func()) {
+	f.running = true
+
+	// 重连通知。若启动时连接尚未建立，首次连接也会收到，多余的通知不影响结果
+	f.connChan = make(chan int, 8)
+	f.ws.AddPublicConnChan(f.connChan)
+	go func() {
+		for f.running {
+			if _, ok := <-f.connChan; !ok {
+				break
+			}
+
+			if f.running {
+				logger.LogImportant(f.logPrefix, "public ws reconnected, trades may be missing")
+				fnGap()
+			}
+		}
+	}()
+
+	f.ws.SubscribeTrades(f.instId, func(resp interface{}) {
+		r := resp.(okexv5api.TradesWsResp)
+		for _, d := range r.Data {
+			id, ok := util.String2Int64(d.TradeID)
+			if !ok {
+				continue
+			}
+
+			ms, ok := util.String2Int64(d.TimeStamp)
+			if !ok {
+				continue
+			}
+
+			fnTrade(f.convert(id, ms, d.Price, d.Size, d.Side))
+		}
+	})
+
+	logger.LogImportant(f.logPrefix, "started")
+}
+
+func (f *TradeFeed) Stop() {
+	f.running = false
+	f.ws.UnsubscribeTrades(f.instId)
+	if f.connChan != nil {
+		f.ws.RemovePublicConnChan(f.connChan)
+	}
+	logger.LogImportant(f.logPrefix, "stopped")
+}
+
+func (f *TradeFeed) Connected() bool {
+	return f.ws.PublicConnected()
+}
+
+// 从最新的成交往前翻页，直到覆盖afterId
+func (f *TradeFeed) FetchTrades(afterId int64, t1 time.Time) ([]common.MarketTrade, bool) {
+	trades := make([]common.MarketTrade, 0)
+	cursor := int64(0) // 返回早于此id的成交，0表示从最新开始
+	for page := 0; page < tradeFeedMaxFetchPages; page++ {
+		resp, err := okexv5api.GetMarketHistoryTrades(f.instId, 1, cursor, 0)
+		if err != nil {
+			logger.LogImportant(f.logPrefix, "fetch trades failed: %s", err.Error())
+			return nil, false
+		} else if resp.Code != "0" {
+			logger.LogImportant(f.logPrefix, "fetch trades failed, code=%s, msg=%s", resp.Code, resp.Msg)
+			return nil, false
+		} else if len(resp.Data) == 0 {
+			break
+		}
+
+		// 返回数据为新->旧
+		for _, d := range resp.Data {
+			if d.TradeId <= afterId {
+				slices.Reverse(trades)
+				return trades, true
+			}
+
+			if d.TimeStamp <= t1.UnixMilli() {
+				trades = append(trades, f.convert(d.TradeId, d.TimeStamp, d.Price, d.Size, d.Side))
+			}
+		}
+
+		cursor = resp.Data[len(resp.Data)-1].TradeId
+		time.Sleep(time.Millisecond * 100) // 限速20次/2s
+	}
+
+	// 翻页上限内没有追上afterId，中间仍有遗漏
+	logger.LogImportant(f.logPrefix, "fetch trades: too many trades after id %d, %d fetched", afterId, len(trades))
+	slices.Reverse(trades)
+	return trades, false
+}
+
+func (f *TradeFeed) GetKline(t0, t1 time.Time, intervalSec int) ([]common.KUnit, bool) {
+	kus := GetKline(f.instId, t0, t1, intervalSec)
+	return kus, kus != nil
+}
+
+// 合约张数换算成基础币数量和金额
+func (f *TradeFeed) convert(id, ms int64, px, sz decimal.Decimal, side string) common.MarketTrade {
+	t := common.MarketTrade{
+		Id:    id,
+		Time:  time.UnixMilli(ms),
+		Price: px,
+	}
+
+	if strings.ToLower(side) == "buy" {
+		t.Dir = common.OrderDir_Buy
+	} else {
+		t.Dir = common.OrderDir_Sell
+	}
+
+	if f.inst.CtVal.IsZero() {
+		// 现货
+		t.Size = sz
+		t.Value = sz.Mul(px)
+	} else if f.inst.IsUsdtContract {
+		// U本位：面值为币
+		t.Size = sz.Mul(f.inst.CtVal)
+		t.Value = t.Size.Mul(px)
+	} else {
+		// 币本位：面值为USD
+		t.Value = sz.Mul(f.inst.CtVal)
+		if px.IsPositive() {
+			t.Size = t.Value.Div(px)
+		}
+	}
+
+	return t
+}
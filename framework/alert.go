@@ -0,0 +1,69 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 05:31:20
+- @Description: 策略的告警。按LaunchConfig.Alert创建告警引擎，并固定提供一个名为intel的渠道，通过中央服务器发出
+- 交易所错误以exchange_error事件发出（intel本身已由errorNotifier发送）。未配置对应规则时自动添加一条，发往配置的所有渠道
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"time"
+
+	"github.com/aztecqt/center_server/server/intel"
+	"github.com/aztecqt/dagger/util/alert"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/metrics"
+)
+
+const alertEvent_ExchangeError = "exchange_error"
+const alertChannel_Intel = "intel"
+
+func (s *StrategyBase) startAlert() {
+	cfg := alert.Config{}
+	if s.LC.Alert != nil {
+		cfg = *s.LC.Alert
+	}
+
+	hasErrorRule := false
+	for _, r := range cfg.Rules {
+		if r.Type == "event" && r.Metric == alertEvent_ExchangeError {
+			hasErrorRule = true
+			break
+		}
+	}
+	if !hasErrorRule && len(cfg.Channels) > 0 {
+		chs := make([]string, 0, len(cfg.Channels))
+		for _, c := range cfg.Channels {
+			chs = append(chs, c.Name)
+		}
+		cfg.Rules = append(cfg.Rules, alert.RuleConfig{
+			Name:     alertEvent_ExchangeError,
+			Type:     "event",
+			Metric:   alertEvent_ExchangeError,
+			Severity: "warning",
+			Channels: chs,
+		})
+	}
+
+	s.Alert = alert.NewEngine(metrics.Default)
+	s.Alert.AddChannel(alertChannel_Intel, alert.ChannelFunc(s.sendAlertIntel), alert.Severity_Info)
+	if err := s.Alert.Load(cfg); err != nil {
+		logger.LogPanic(s.LogPrefix, "load alert config failed: %s", err.Error())
+	}
+}
+
+func (s *StrategyBase) sendAlertIntel(a *alert.Alert, kind alert.NotifyKind) error {
+	it := intel.Intel{
+		Time:     time.Now(),
+		Level:    0,
+		Type:     "stratergy",
+		SubType:  s.LC.Name,
+		DingType: intel.DingType_Text,
+		Title:    a.Title,
+		Content:  a.Text(kind),
+	}
+	s.IntelClient.SendIntel(it)
+	return nil
+}
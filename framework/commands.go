@@ -0,0 +1,437 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 11:26:14
+- @Description: 策略命令。StrategyBase的内置命令注册在Commands中，策略可以继续注册自己的命令
+- 未注册的命令交给策略的onCommand处理，远程调用时需要LaunchConfig.Cmd.LegacyLevel的权限
+- 本地命令行为admin权限；中央服务器发来的命令为Cmd.RemoteLevel；web服务的/cmd接口按apikey的标签取Cmd.WebLevels
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aztecqt/dagger/api"
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/terminal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/shopspring/decimal"
+)
+
+const cmdHistoryPath = "log/cmd_history"
+
+// 未配置时的默认权限
+const defaultRemoteCmdLevel = terminal.Perm_Admin
+const defaultWebCmdLevel = terminal.Perm_Read
+const defaultLegacyCmdLevel = terminal.Perm_Trade
+
+// flatten命令的价格偏移，与adv.Taker相同
+var flattenSlippage = decimal.NewFromFloat(0.01)
+
+func permLevelOrDefault(s string, def terminal.PermLevel) terminal.PermLevel {
+	if len(s) == 0 {
+		return def
+	}
+	return terminal.String2PermLevel(s)
+}
+
+// 执行命令。help会合并注册的命令和策略自己的帮助
+func (s *StrategyBase) execCommand(cmdLine string, level terminal.PermLevel, remote bool, onResp func(string)) {
+	if strings.TrimSpace(cmdLine) == "help" {
+		sb := strings.Builder{}
+		sb.WriteString(s.Commands.Help(level))
+		if s.onCommand != nil && level >= permLevelOrDefault(s.LC.Cmd.LegacyLevel, defaultLegacyCmdLevel) {
+			s.onCommand("help", func(resp string) {
+				sb.WriteString("\n")
+				sb.WriteString(resp)
+			})
+		}
+		onResp(sb.String())
+		return
+	}
+
+	if s.Commands.Execute(cmdLine, level, remote, onResp) {
+		return
+	}
+
+	if len(strings.TrimSpace(cmdLine)) == 0 || s.onCommand == nil {
+		return
+	}
+
+	if legacy := permLevelOrDefault(s.LC.Cmd.LegacyLevel, defaultLegacyCmdLevel); level < legacy {
+		onResp(fmt.Sprintf("permission denied: requires %s", terminal.PermLevel2String(legacy)))
+		return
+	}
+	s.onCommand(cmdLine, onResp)
+}
+
+// 本地命令行，带补全和历史
+func (s *StrategyBase) runRepl() {
+	repl := terminal.NewRepl(">", cmdHistoryPath)
+	repl.Complete = s.Commands.Complete
+	repl.Run(func(line string) {
+		s.execCommand(line, terminal.Perm_Admin, false, func(resp string) {
+			fmt.Println(resp)
+		})
+	})
+}
+
+// web服务的命令接口。GET/POST参数cmd
+func (s *StrategyBase) onHttpCmd(w http.ResponseWriter, r *http.Request) {
+	ok, tag := s.WebService.Auth(w, r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, tag)
+		return
+	}
+
+	level := permLevelOrDefault(s.LC.Cmd.WebLevel, defaultWebCmdLevel)
+	if lv, ok := s.LC.Cmd.WebLevels[tag]; ok {
+		level = terminal.String2PermLevel(lv)
+	}
+
+	r.ParseForm()
+	cmdLine := r.Form.Get("cmd")
+	resps := make([]string, 0)
+	mu := sync.Mutex{}
+	s.execCommand(cmdLine, level, true, func(resp string) {
+		mu.Lock()
+		defer mu.Unlock()
+		resps = append(resps, resp)
+	})
+
+	logger.LogImportant(s.LogPrefix, "web command from %s(%s): %s", tag, terminal.PermLevel2String(level), cmdLine)
+	mu.Lock()
+	defer mu.Unlock()
+	io.WriteString(w, util.Object2String(map[string]interface{}{"cmd": cmdLine, "resp": resps}))
+}
+
+// 命令用到的交易所。托管进程中为所有共享的交易所
+func (s *StrategyBase) cmdExchanges() map[string]common.CEx {
+	if s.exchangesFn != nil {
+		return s.exchangesFn()
+	}
+	return s.Exs
+}
+
+func sortedExchangeNames(exs map[string]common.CEx) []string {
+	names := make([]string, 0, len(exs))
+	for name := range exs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// #region 内置命令
+func (s *StrategyBase) registerBuiltinCommands() {
+	logLevels := []string{"debug", "info", "important", "none"}
+	symbolFlag := terminal.Flag{Name: "symbol", Short: "s", Help: "only this symbol, e.g. btc"}
+	confirmFlag := terminal.Flag{Name: "yes", Short: "y", Type: terminal.ArgType_Bool, Help: "confirm the operation"}
+
+	s.Commands.Register(
+		&terminal.Command{
+			Name: "cs",
+			Help: "print out all call stack",
+			Perm: terminal.Perm_Operate,
+			Run:  s.cmdCallStack,
+		},
+		&terminal.Command{
+			Name:    "quit",
+			Aliases: []string{"exit"},
+			Help:    "stop stratergy and quit",
+			Perm:    terminal.Perm_Admin,
+			Run:     func(c *terminal.CmdCall) { s.Quit(c.RespFn()) },
+		},
+		&terminal.Command{
+			Name: "wslog",
+			Help: "switch websocket log on/off",
+			Perm: terminal.Perm_Operate,
+			Run: func(c *terminal.CmdCall) {
+				api.LogWebsocketDetail = !api.LogWebsocketDetail
+				c.Resp("websocket log switch to %s", util.ValueIf(api.LogWebsocketDetail, "on", "off"))
+			},
+		},
+		&terminal.Command{
+			Name: "loglv",
+			Help: "show/set log level of module, or the global console/file level",
+			Perm: terminal.Perm_Operate,
+			Args: []terminal.Arg{
+				{Name: "module", Optional: true, Help: "module(log prefix)"},
+				{Name: "level", Optional: true, Choices: append(logLevels, "reset")},
+			},
+			Flags: []terminal.Flag{
+				{Name: "console", Help: "set console log level", Choices: logLevels},
+				{Name: "file", Help: "set file log level", Choices: logLevels},
+			},
+			Run: s.cmdLogLevel,
+		},
+		(&terminal.Command{
+			Name: "alert",
+			Help: "show active alerts, alert ack [id] to acknowledge",
+			Perm: terminal.Perm_Read,
+			Run:  func(c *terminal.CmdCall) { c.Resp(s.alertCommand(nil, c)) },
+		}).Add(&terminal.Command{
+			Name: "ack",
+			Help: "acknowledge an alert",
+			Perm: terminal.Perm_Operate,
+			Args: []terminal.Arg{{Name: "id", Type: terminal.ArgType_Int}},
+			Run:  func(c *terminal.CmdCall) { c.Resp(s.alertCommand([]string{"ack", c.String("id")}, c)) },
+		}),
+		&terminal.Command{
+			Name:  "orders",
+			Help:  "show unfinished orders",
+			Perm:  terminal.Perm_Read,
+			Flags: []terminal.Flag{symbolFlag},
+			Run:   s.cmdOrders,
+		},
+		&terminal.Command{
+			Name:  "positions",
+			Help:  "show positions",
+			Perm:  terminal.Perm_Read,
+			Flags: []terminal.Flag{symbolFlag},
+			Run:   s.cmdPositions,
+		},
+		&terminal.Command{
+			Name:  "balances",
+			Help:  "show balances",
+			Perm:  terminal.Perm_Read,
+			Flags: []terminal.Flag{{Name: "all", Short: "a", Type: terminal.ArgType_Bool, Help: "include zero balances"}},
+			Run:   s.cmdBalances,
+		},
+		&terminal.Command{
+			Name:  "cancel-all",
+			Help:  "cancel all unfinished orders of the traders in use",
+			Perm:  terminal.Perm_Trade,
+			Flags: []terminal.Flag{symbolFlag, confirmFlag},
+			Run:   s.cmdCancelAll,
+		},
+		&terminal.Command{
+			Name:  "flatten",
+			Help:  "close future positions with reduce-only taker orders, run again if not fully dealed",
+			Perm:  terminal.Perm_Trade,
+			Flags: []terminal.Flag{symbolFlag, confirmFlag},
+			Run:   s.cmdFlatten,
+		},
+	)
+}
+
+func (s *StrategyBase) cmdCallStack(c *terminal.CmdCall) {
+	pf := pprof.Lookup("goroutine")
+	if !c.Remote {
+		pf.WriteTo(os.Stdout, 1)
+	}
+
+	file, err := os.Create("log/callstack.log")
+	if err == nil {
+		pf.WriteTo(file, 1)
+		file.Close()
+		c.Resp("call stack saved to %s\n", file.Name())
+	} else {
+		c.Resp("call stack save to file failed")
+	}
+}
+
+func (s *StrategyBase) cmdLogLevel(c *terminal.CmdCall) {
+	if c.Has("console") || c.Has("file") {
+		if c.Has("console") {
+			logger.ConsleLogLevel = logger.String2LogLevel(c.String("console"))
+		}
+		if c.Has("file") {
+			logger.FileLogLevel = logger.String2LogLevel(c.String("file"))
+		}
+		c.Resp("console level: %s, file level: %s", logger.LogLevel2String(logger.ConsleLogLevel), logger.LogLevel2String(logger.FileLogLevel))
+		return
+	}
+
+	args := make([]string, 0, 2)
+	if c.Has("module") {
+		args = append(args, c.String("module"))
+	}
+	if c.Has("level") {
+		args = append(args, c.String("level"))
+	}
+	c.Resp(logger.OnCommand(args))
+}
+
+func (s *StrategyBase) alertCommand(args []string, c *terminal.CmdCall) string {
+	if s.Alert == nil {
+		return "alert not enabled"
+	}
+	return s.Alert.OnCommand(args, util.ValueIf(c.Remote, "remote", "terminal"))
+}
+
+// 所有交易器，按交易所名称排序
+func (s *StrategyBase) cmdTraders(symbol string) []common.CommonTrader {
+	traders := make([]common.CommonTrader, 0)
+	exs := s.cmdExchanges()
+	for _, name := range sortedExchangeNames(exs) {
+		for _, t := range exs[name].FutureTraders() {
+			if len(symbol) == 0 || t.FutureMarket().Symbol() == symbol {
+				traders = append(traders, t)
+			}
+		}
+		for _, t := range exs[name].SpotTraders() {
+			if len(symbol) == 0 || t.SpotMarket().BaseCurrency() == symbol {
+				traders = append(traders, t)
+			}
+		}
+	}
+	return traders
+}
+
+func (s *StrategyBase) cmdOrders(c *terminal.CmdCall) {
+	tw := terminal.GenTableWriter(true)
+	tw.AppendHeader(table.Row{"trader", "id", "client id", "dir", "price", "size", "filled", "status"})
+	n := 0
+	for _, t := range s.cmdTraders(c.String("symbol")) {
+		for _, o := range t.Orders() {
+			if o.IsFinished() {
+				continue
+			}
+			id, cid := o.GetID()
+			tw.AppendRow(table.Row{t.String(), id, cid, common.OrderDir2Str(o.GetDir()), o.GetPrice(), o.GetSize(), o.GetFilled(), o.GetStatus()})
+			n++
+		}
+	}
+
+	if n == 0 {
+		c.Resp("no unfinished order")
+	} else {
+		c.Resp(tw.Render())
+	}
+}
+
+func (s *StrategyBase) cmdPositions(c *terminal.CmdCall) {
+	tw := terminal.GenTableWriter(true)
+	tw.AppendHeader(table.Row{"exchange", "symbol", "contract", "long", "long px", "short", "short px", "net"})
+	symbol := c.String("symbol")
+	n := 0
+	exs := s.cmdExchanges()
+	for _, name := range sortedExchangeNames(exs) {
+		for _, p := range exs[name].GetAllPositions() {
+			if p == nil || (len(symbol) > 0 && p.Symbol() != symbol) {
+				continue
+			}
+			if p.Long().IsZero() && p.Short().IsZero() {
+				continue
+			}
+			tw.AppendRow(table.Row{name, p.Symbol(), p.ContractType(), p.Long(), p.LongAvgPx(), p.Short(), p.ShortAvgPx(), p.Net()})
+			n++
+		}
+	}
+
+	if n == 0 {
+		c.Resp("no position")
+	} else {
+		c.Resp(tw.Render())
+	}
+}
+
+func (s *StrategyBase) cmdBalances(c *terminal.CmdCall) {
+	tw := terminal.GenTableWriter(true)
+	tw.AppendHeader(table.Row{"exchange", "ccy", "rights", "frozen", "available"})
+	exs := s.cmdExchanges()
+	for _, name := range sortedExchangeNames(exs) {
+		for _, b := range exs[name].GetAllBalances() {
+			if b == nil || (b.Rights().IsZero() && !c.Bool("all")) {
+				continue
+			}
+			tw.AppendRow(table.Row{name, b.Ccy(), b.Rights(), b.Frozen(), b.Available()})
+		}
+	}
+	c.Resp(tw.Render())
+}
+
+func (s *StrategyBase) cmdCancelAll(c *terminal.CmdCall) {
+	orders := make([]common.Order, 0)
+	for _, t := range s.cmdTraders(c.String("symbol")) {
+		for _, o := range t.Orders() {
+			if o.IsAlive() {
+				orders = append(orders, o)
+			}
+		}
+	}
+
+	if !c.Bool("yes") {
+		c.Resp("%d orders will be canceled, add -y to confirm", len(orders))
+		return
+	}
+
+	for _, o := range orders {
+		o.Cancel()
+	}
+	logger.LogImportant(s.LogPrefix, "cancel-all by command: %d orders", len(orders))
+	c.Resp("%d orders canceling", len(orders))
+}
+
+func (s *StrategyBase) cmdFlatten(c *terminal.CmdCall) {
+	type closeTask struct {
+		t      common.FutureTrader
+		amount decimal.Decimal
+		dir    common.OrderDir
+	}
+
+	tasks := make([]closeTask, 0)
+	for _, t := range s.cmdTraders(c.String("symbol")) {
+		ft, ok := t.(common.FutureTrader)
+		if !ok || ft.Position() == nil {
+			continue
+		}
+		p := ft.Position()
+		if p.Long().IsPositive() {
+			tasks = append(tasks, closeTask{t: ft, amount: p.Long(), dir: common.OrderDir_Sell})
+		}
+		if p.Short().IsPositive() {
+			tasks = append(tasks, closeTask{t: ft, amount: p.Short(), dir: common.OrderDir_Buy})
+		}
+	}
+
+	sb := strings.Builder{}
+	for _, task := range tasks {
+		sb.WriteString(fmt.Sprintf("%s %s %v\n", task.t.String(), common.OrderDir2Str(task.dir), task.amount))
+	}
+	if len(tasks) == 0 {
+		c.Resp("no position to close")
+		return
+	}
+	if !c.Bool("yes") {
+		c.Resp("%sadd -y to confirm", sb.String())
+		return
+	}
+
+	for _, task := range tasks {
+		if o := takeOrder(task.t, task.amount, task.dir, flattenSlippage, "flatten"); o == nil {
+			sb.WriteString(fmt.Sprintf("%s make order failed\n", task.t.String()))
+		}
+	}
+	logger.LogImportant(s.LogPrefix, "flatten by command:\n%s", sb.String())
+	c.Resp("closing:\n%s", sb.String())
+}
+
+// 以对手价偏移slippage下一笔只减仓单，用于平仓
+func takeOrder(t common.CommonTrader, amount decimal.Decimal, dir common.OrderDir, slippage decimal.Decimal, purpose string) common.Order {
+	ob := t.Market().OrderBook()
+	price := ob.Buy1Price().Mul(decimal.NewFromInt(1).Sub(slippage))
+	if dir == common.OrderDir_Buy {
+		price = ob.Sell1Price().Mul(decimal.NewFromInt(1).Add(slippage))
+	}
+	price = t.Market().AlignPrice(price, dir, false)
+	amount = t.Market().AlignSize(amount)
+	if price.IsZero() || amount.IsZero() {
+		return nil
+	}
+	return t.MakeOrder(price, amount, dir, false, true, purpose, nil)
+}
+
+// #endregion
@@ -0,0 +1,584 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 06:02:15
+- @Description: 策略的标准看板。由StrategyBase在web服务启动时挂载，路径都在/dashboard下：
+- /dashboard                  内嵌的单页查看器
+- /dashboard/api/status      策略状态
+- /dashboard/api/params      GET读取param.json，POST写入并通知策略
+- /dashboard/api/orders      各交易器的活跃订单
+- /dashboard/api/positions   仓位
+- /dashboard/api/balances    权益
+- /dashboard/api/datalines   ?name=&from=&limit=，数据线
+- /dashboard/api/deals       ?name=&from=&limit=，成交记录
+- /dashboard/ws              推送，连接后先推送全量，之后只推送变化的部分和新增的数据
+- 所有接口都经过Service.Auth鉴权。ws连接无法自定义header，apikey可以放在url参数apikey中
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	_ "embed"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/webservice"
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+)
+
+//go:embed dashboard.html
+var dashboardHtml string
+
+const dashboardPath = "/dashboard"
+const dashboardPushInterval = time.Second
+const dashboardLimitDefault = 500
+const dashboardLimitMax = 5000
+
+type Dashboard struct {
+	s         *StrategyBase
+	svc       *webservice.Service
+	logPrefix string
+
+	datalines      map[string][]*DataLine
+	deals          map[string]*DealRecords
+	onParamChanged func(raw []byte) error
+
+	upgrader websocket.Upgrader
+	clients  map[*dashboardClient]bool
+	mu       sync.Mutex
+}
+
+// 推送的消息
+type DashboardMsg struct {
+	Topic string      `json:"topic"` // status/params/orders/positions/balances/datalines/deals，或策略自定义
+	Name  string      `json:"name,omitempty"`
+	Ts    int64       `json:"ts"`
+	Data  interface{} `json:"data"`
+}
+
+type dashboardClient struct {
+	conn      *websocket.Conn
+	tag       string
+	dlFrom    map[string]int64  // 每组数据线已推送到的时间
+	dealFrom  map[string]int64  // 每组成交已推送到的时间
+	snapshots map[string]string // 快照类数据上次推送的内容，内容不变时不再推送
+	pushMu    sync.Mutex        // 保护上面几个推送状态
+	mu        sync.Mutex        // 保护写连接
+}
+
+func newDashboard(s *StrategyBase, svc *webservice.Service) *Dashboard {
+	d := &Dashboard{
+		s:         s,
+		svc:       svc,
+		logPrefix: s.LogPrefix + ".dashboard",
+		datalines: make(map[string][]*DataLine),
+		deals:     make(map[string]*DealRecords),
+		upgrader:  websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+		clients:   make(map[*dashboardClient]bool),
+	}
+
+	svc.RegisterPath(dashboardPath, d.onHtml)
+	svc.RegisterPath(dashboardPath+"/api/status", d.withAuth(d.onStatus))
+	svc.RegisterPath(dashboardPath+"/api/params", d.withAuth(d.onParams))
+	svc.RegisterPath(dashboardPath+"/api/orders", d.withAuth(d.onOrders))
+	svc.RegisterPath(dashboardPath+"/api/positions", d.withAuth(d.onPositions))
+	svc.RegisterPath(dashboardPath+"/api/balances", d.withAuth(d.onBalances))
+	svc.RegisterPath(dashboardPath+"/api/datalines", d.withAuth(d.onDatalines))
+	svc.RegisterPath(dashboardPath+"/api/deals", d.withAuth(d.onDeals))
+	svc.RegisterPath(dashboardPath+"/ws", d.onWs)
+
+	go d.pushLoop()
+	return d
+}
+
+// #region 数据源注册
+
+// 注册一组数据线。同一组的数据线末端需要对齐
+func (d *Dashboard) AddDatalines(name string, dls ...*DataLine) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.datalines[name] = dls
+}
+
+// 保存所有数据线（需设置了文件路径），退出时调用
+func (d *Dashboard) saveDatalines() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, dls := range d.datalines {
+		for _, dl := range dls {
+			dl.Save()
+		}
+	}
+}
+
+// 注册一组成交记录
+func (d *Dashboard) AddDealRecords(name string, drs *DealRecords) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deals[name] = drs
+}
+
+// 通过看板修改参数时的回调，在写入param.json之前调用。返回错误时不写入，并告知前端
+func (d *Dashboard) SetOnParamChanged(fn func(raw []byte) error) {
+	d.onParamChanged = fn
+}
+
+// 向所有ws客户端推送一条自定义消息
+func (d *Dashboard) Push(topic, name string, data interface{}) {
+	msg := DashboardMsg{Topic: topic, Name: name, Ts: time.Now().UnixMilli(), Data: data}
+	for _, c := range d.allClients() {
+		c.send(msg)
+	}
+}
+
+// #endregion
+
+// #region http
+func (d *Dashboard) withAuth(h webservice.HttpHandler) webservice.HttpHandler {
+	return d.svc.WithAuth(h)
+}
+
+func (d *Dashboard) writeData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, util.Object2StringWithoutIntent(map[string]interface{}{
+		"ok":   true,
+		"data": data,
+	}))
+}
+
+func (d *Dashboard) onHtml(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != dashboardPath && r.URL.Path != dashboardPath+"/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, dashboardHtml)
+}
+
+func (d *Dashboard) onStatus(w http.ResponseWriter, r *http.Request) {
+	d.writeData(w, d.status())
+}
+
+func (d *Dashboard) onParams(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			webservice.WriteError(w, err.Error())
+			return
+		}
+		if err := d.saveParams(b); err != nil {
+			webservice.WriteError(w, err.Error())
+			return
+		}
+		logger.LogImportant(d.logPrefix, "params changed by dashboard: %s", string(b))
+		d.Push("params", "", d.params())
+	}
+	d.writeData(w, d.params())
+}
+
+func (d *Dashboard) onOrders(w http.ResponseWriter, r *http.Request) {
+	d.writeData(w, d.orders())
+}
+
+func (d *Dashboard) onPositions(w http.ResponseWriter, r *http.Request) {
+	d.writeData(w, d.positions())
+}
+
+func (d *Dashboard) onBalances(w http.ResponseWriter, r *http.Request) {
+	d.writeData(w, d.balances())
+}
+
+func (d *Dashboard) onDatalines(w http.ResponseWriter, r *http.Request) {
+	from, limit, ok := ReadFromAndLimit(w, r, dashboardLimitDefault, dashboardLimitMax)
+	if !ok {
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if len(name) == 0 {
+		d.writeData(w, d.datalineNames())
+		return
+	}
+
+	d.mu.Lock()
+	dls, exist := d.datalines[name]
+	d.mu.Unlock()
+	if !exist {
+		webservice.WriteError(w, "unknown dataline group: "+name)
+		return
+	}
+
+	if en, ok := EncodeDatalines(dls, from, limit); ok {
+		d.writeData(w, en)
+	} else {
+		webservice.WriteError(w, "datalines not aligned")
+	}
+}
+
+func (d *Dashboard) onDeals(w http.ResponseWriter, r *http.Request) {
+	from, limit, ok := ReadFromAndLimit(w, r, dashboardLimitDefault, dashboardLimitMax)
+	if !ok {
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if len(name) == 0 {
+		d.writeData(w, d.dealNames())
+		return
+	}
+
+	d.mu.Lock()
+	drs, exist := d.deals[name]
+	d.mu.Unlock()
+	if !exist {
+		webservice.WriteError(w, "unknown deal records: "+name)
+		return
+	}
+
+	d.writeData(w, EncodeDealRecords(drs.Deals, from, limit))
+}
+
+// #endregion
+
+// #region 数据
+func (d *Dashboard) status() map[string]interface{} {
+	s := d.s
+	st := map[string]interface{}{
+		"name":    s.Name(),
+		"class":   s.Class(),
+		"running": s.running,
+		"errors":  s.errorCount,
+	}
+
+	if s.DeadMan != nil {
+		st["deadman_armed"] = s.DeadMan.Armed()
+	}
+
+	if s.Alert != nil {
+		st["alerts"] = len(s.Alert.Active())
+	}
+
+	if s.Ex != nil {
+		st["exchange"] = s.Ex.Name()
+		risk := s.Ex.GetUniAccRisk()
+		st["risk_level"] = risk.Level
+		st["position_value"] = risk.PositionValue
+		st["total_margin"] = risk.TotalMargin
+		st["maintain_margin"] = risk.MaintainMargin
+
+		ready := map[string]bool{}
+		for _, t := range d.traders() {
+			ready[t.String()] = t.Ready()
+		}
+		st["traders"] = ready
+	}
+
+	return st
+}
+
+func (d *Dashboard) params() interface{} {
+	var p interface{}
+	if !util.ObjectFromFile(d.s.LC.ParamPath, &p) {
+		return nil
+	}
+	return p
+}
+
+func (d *Dashboard) saveParams(raw []byte) error {
+	var p interface{}
+	if err := util.ObjectFromString(string(raw), &p); err != nil {
+		return err
+	}
+
+	if d.onParamChanged != nil {
+		if err := d.onParamChanged(raw); err != nil {
+			return err
+		}
+	}
+
+	if !util.ObjectToFile(d.s.LC.ParamPath, p) {
+		return errors.New("save params failed")
+	}
+	return nil
+}
+
+func (d *Dashboard) traders() []common.CommonTrader {
+	traders := make([]common.CommonTrader, 0)
+	if d.s.Ex == nil {
+		return traders
+	}
+
+	for _, t := range d.s.Ex.FutureTraders() {
+		traders = append(traders, t)
+	}
+	for _, t := range d.s.Ex.SpotTraders() {
+		traders = append(traders, t)
+	}
+	return traders
+}
+
+type dashboardOrder struct {
+	Trader   string          `json:"trader"`
+	Id       string          `json:"id"`
+	CltId    string          `json:"cid"`
+	Dir      string          `json:"dir"`
+	Price    decimal.Decimal `json:"price"`
+	Size     decimal.Decimal `json:"size"`
+	Filled   decimal.Decimal `json:"filled"`
+	AvgPrice decimal.Decimal `json:"avg_px"`
+	Status   string          `json:"status"`
+	Born     int64           `json:"born"`
+}
+
+func (d *Dashboard) orders() []dashboardOrder {
+	ords := make([]dashboardOrder, 0)
+	for _, t := range d.traders() {
+		for _, o := range t.Orders() {
+			id, cid := o.GetID()
+			ords = append(ords, dashboardOrder{
+				Trader:   t.String(),
+				Id:       id,
+				CltId:    cid,
+				Dir:      common.OrderDir2Str(o.GetDir()),
+				Price:    o.GetPrice(),
+				Size:     o.GetSize(),
+				Filled:   o.GetFilled(),
+				AvgPrice: o.GetAvgPrice(),
+				Status:   o.GetStatus(),
+				Born:     o.GetBornTime().UnixMilli(),
+			})
+		}
+	}
+	sort.Slice(ords, func(i, j int) bool { return ords[i].Born < ords[j].Born })
+	return ords
+}
+
+type dashboardPosition struct {
+	Symbol       string          `json:"symbol"`
+	ContractType string          `json:"contract"`
+	Long         decimal.Decimal `json:"long"`
+	Short        decimal.Decimal `json:"short"`
+	LongAvgPx    decimal.Decimal `json:"long_px"`
+	ShortAvgPx   decimal.Decimal `json:"short_px"`
+	Net          decimal.Decimal `json:"net"`
+}
+
+func (d *Dashboard) positions() []dashboardPosition {
+	ps := make([]dashboardPosition, 0)
+	if d.s.Ex == nil {
+		return ps
+	}
+
+	for _, p := range d.s.Ex.GetAllPositions() {
+		if p == nil {
+			continue
+		}
+		ps = append(ps, dashboardPosition{
+			Symbol:       p.Symbol(),
+			ContractType: p.ContractType(),
+			Long:         p.Long(),
+			Short:        p.Short(),
+			LongAvgPx:    p.LongAvgPx(),
+			ShortAvgPx:   p.ShortAvgPx(),
+			Net:          p.Net(),
+		})
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Symbol+ps[i].ContractType < ps[j].Symbol+ps[j].ContractType })
+	return ps
+}
+
+type dashboardBalance struct {
+	Ccy       string          `json:"ccy"`
+	Rights    decimal.Decimal `json:"rights"`
+	Frozen    decimal.Decimal `json:"frozen"`
+	Available decimal.Decimal `json:"available"`
+}
+
+func (d *Dashboard) balances() []dashboardBalance {
+	bs := make([]dashboardBalance, 0)
+	if d.s.Ex == nil {
+		return bs
+	}
+
+	for _, b := range d.s.Ex.GetAllBalances() {
+		if b == nil || b.Rights().IsZero() {
+			continue
+		}
+		bs = append(bs, dashboardBalance{Ccy: b.Ccy(), Rights: b.Rights(), Frozen: b.Frozen(), Available: b.Available()})
+	}
+	sort.Slice(bs, func(i, j int) bool { return bs[i].Ccy < bs[j].Ccy })
+	return bs
+}
+
+func (d *Dashboard) datalineNames() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := make([]string, 0, len(d.datalines))
+	for name := range d.datalines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *Dashboard) dealNames() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := make([]string, 0, len(d.deals))
+	for name := range d.deals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// #endregion
+
+// #region websocket
+func (d *Dashboard) onWs(w http.ResponseWriter, r *http.Request) {
+	if len(r.Header.Get("API-KEY")) == 0 {
+		r.Header.Set("API-KEY", r.URL.Query().Get("apikey"))
+	}
+	ok, tag := d.svc.Auth(w, r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		webservice.WriteError(w, tag)
+		return
+	}
+
+	conn, err := d.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.LogInfo(d.logPrefix, "upgrade websocket failed: %s", err.Error())
+		return
+	}
+
+	c := &dashboardClient{
+		conn:      conn,
+		tag:       tag,
+		dlFrom:    make(map[string]int64),
+		dealFrom:  make(map[string]int64),
+		snapshots: make(map[string]string),
+	}
+	d.mu.Lock()
+	d.clients[c] = true
+	d.mu.Unlock()
+	logger.LogInfo(d.logPrefix, "ws client connected: %s %s", conn.RemoteAddr().String(), tag)
+
+	d.pushTo(c, time.Now())
+	go d.readLoop(c)
+}
+
+// 客户端只用于保持连接，不处理上行消息
+func (d *Dashboard) readLoop(c *dashboardClient) {
+	defer util.DefaultRecover()
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	d.mu.Lock()
+	delete(d.clients, c)
+	d.mu.Unlock()
+	c.conn.Close()
+	logger.LogInfo(d.logPrefix, "ws client disconnected: %s %s", c.conn.RemoteAddr().String(), c.tag)
+}
+
+func (d *Dashboard) allClients() []*dashboardClient {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cs := make([]*dashboardClient, 0, len(d.clients))
+	for c := range d.clients {
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+func (d *Dashboard) pushLoop() {
+	defer util.DefaultRecover()
+	ticker := time.NewTicker(dashboardPushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		cs := d.allClients()
+		if len(cs) == 0 {
+			continue
+		}
+
+		now := time.Now()
+		for _, c := range cs {
+			d.pushTo(c, now)
+		}
+	}
+}
+
+// 推送变化的快照和新增的数据线、成交
+func (d *Dashboard) pushTo(c *dashboardClient, now time.Time) {
+	c.pushMu.Lock()
+	defer c.pushMu.Unlock()
+
+	ts := now.UnixMilli()
+	snapshots := map[string]interface{}{
+		"status":    d.status(),
+		"params":    d.params(),
+		"orders":    d.orders(),
+		"positions": d.positions(),
+		"balances":  d.balances(),
+	}
+	for topic, data := range snapshots {
+		str := util.Object2StringWithoutIntent(data)
+		if c.snapshots[topic] != str {
+			c.snapshots[topic] = str
+			c.send(DashboardMsg{Topic: topic, Ts: ts, Data: data})
+		}
+	}
+
+	d.mu.Lock()
+	dlGroups := make(map[string][]*DataLine, len(d.datalines))
+	for name, dls := range d.datalines {
+		dlGroups[name] = dls
+	}
+	dealGroups := make(map[string]*DealRecords, len(d.deals))
+	for name, drs := range d.deals {
+		dealGroups[name] = drs
+	}
+	d.mu.Unlock()
+
+	for name, dls := range dlGroups {
+		from := c.dlFrom[name]
+		limit := util.ValueIf(from == 0, dashboardLimitDefault, dashboardLimitMax)
+		if en, ok := EncodeDatalines(dls, from, limit); ok && len(en.TimeStamps) > 0 {
+			c.dlFrom[name] = en.TimeStamps[len(en.TimeStamps)-1]
+			c.send(DashboardMsg{Topic: "datalines", Name: name, Ts: ts, Data: en})
+		}
+	}
+
+	for name, drs := range dealGroups {
+		from := c.dealFrom[name]
+		deals := drs.Deals
+		if len(deals) == 0 || deals[len(deals)-1].TimeStamp <= from {
+			continue
+		}
+		limit := util.ValueIf(from == 0, dashboardLimitDefault, dashboardLimitMax)
+		c.dealFrom[name] = deals[len(deals)-1].TimeStamp
+		c.send(DashboardMsg{Topic: "deals", Name: name, Ts: ts, Data: EncodeDealRecords(deals, from, limit)})
+	}
+}
+
+func (c *dashboardClient) send(msg DashboardMsg) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second * 5))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		c.conn.Close()
+	}
+}
+
+// #endregion
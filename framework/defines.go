@@ -7,6 +7,14 @@
 */
 package framework
 
+import (
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/alert"
+)
+
+// 本地加密key文件的口令，读取后立即从环境变量中清除
+const keystorePassEnv = "DAGGER_KEYSTORE_PASS"
+
 type LaunchConfig struct {
 	Name           string      `json:"name"`
 	Class          string      `json:"class"`
@@ -14,19 +22,15 @@ type LaunchConfig struct {
 	Account        string      `json:"acc"`
 	ExchangeConfig interface{} `json:"ex_cfg"`
 
+	// 多个交易所。名称 -> 交易所设置，策略通过StrategyBase.Exs[名称]访问
+	// ex/acc/ex_cfg配置的交易所也在Exs中，名称为ex
+	Exchanges map[string]ExchangeLaunchConfig `json:"exchanges"`
+
 	// 日志设置
-	LogConfig struct {
-		PeriodConfig    string `json:"period"`     // d7, h12, m120
-		ConsoleLogLevel string `json:"console_lv"` // info, debug, important
-		FileLogLevel    string `json:"file_lv"`    // info, debug, important
-	} `json:"log"`
+	LogConfig LogConfig `json:"log"`
 
 	// 交易密钥服务
-	Key struct {
-		ServerAddr string `json:"addr"`
-		ServerPort int    `json:"port"`
-		Share      bool   `json:"share"`
-	} `json:"key"`
+	Key KeyConfig `json:"key"`
 
 	// 中央服务器
 	CsConfig struct {
@@ -41,9 +45,102 @@ type LaunchConfig struct {
 	// web服务的端口号。用于搭建策略前端
 	WebServerPort int `json:"web_port"`
 
+	// web服务鉴权用的redis（keymgr管理的apikey）。未配置时web服务不鉴权，也不开放/metrics
+	WebAuth util.RedisConfig `json:"web_auth"`
+
+	// 死亡开关超时秒数。0表示不启用
+	// 启用后由框架主循环每秒发送心跳，进程卡死或退出导致心跳超时后撤销所有挂单
+	DeadManTimeoutSec int `json:"deadman_sec"`
+
+	// 告警规则和通知渠道。为nil时只把交易所错误通过中央服务器发出
+	Alert *alert.Config `json:"alert"`
+
+	// 命令权限
+	Cmd CmdConfig `json:"cmd"`
+
+	// 退出流程
+	Shutdown ShutdownConfig `json:"shutdown"`
+
+	// 主备模式
+	Standby StandbyConfig `json:"standby"`
+
+	// 代理池。启用后交易所的rest和ws都经由代理池连接
+	Proxy ProxyConfig `json:"proxy"`
+
 	// 配置根目录
 	ProfileRoot string
 
 	// 参数不再在StratergyParam中配置，而是使用专门的param.json，配合load/save函数，方便参数的在线调整
 	ParamPath string
 }
+
+// 日志设置
+type LogConfig struct {
+	PeriodConfig    string            `json:"period"`      // d7, h12, m120, s10(只按大小切分)
+	ConsoleLogLevel string            `json:"console_lv"`  // info, debug, important
+	FileLogLevel    string            `json:"file_lv"`     // info, debug, important
+	Format          string            `json:"format"`      // 文件日志格式：console(默认)/json
+	MaxSizeMB       int               `json:"max_size_mb"` // 单个日志文件的最大大小，0表示不限
+	Compress        bool              `json:"compress"`    // 压缩切换下来的旧文件
+	ModuleLevels    map[string]string `json:"module_lv"`   // 模块(前缀)->等级
+}
+
+// 交易密钥服务
+type KeyConfig struct {
+	ServerAddr string `json:"addr"`
+	ServerPort int    `json:"port"`
+	Share      bool   `json:"share"`
+	Type       string `json:"type"`       // 签名方式(hmac/rsa/ed25519)。为空时使用key服务器下发的值
+	ClientKey  string `json:"client_key"` // 本机身份私钥文件。设置后使用v2协议与key服务器通信
+	ServerPub  string `json:"server_pub"` // key服务器公钥文件
+	Keystore   string `json:"keystore"`   // 本地加密key文件。设置后不再访问key服务器，口令从环境变量DAGGER_KEYSTORE_PASS读取
+}
+
+// 交易所设置。ex为交易所注册表中的名称
+type ExchangeLaunchConfig struct {
+	ExchangeName string      `json:"ex"`
+	Account      string      `json:"acc"`
+	Config       interface{} `json:"cfg"`
+}
+
+// 命令权限。等级为read/operate/trade/admin，本地命令行始终为admin
+type CmdConfig struct {
+	RemoteLevel string            `json:"remote_lv"` // 中央服务器发来的命令，默认admin
+	WebLevel    string            `json:"web_lv"`    // web服务/cmd接口，默认read
+	WebLevels   map[string]string `json:"web_lvs"`   // web服务按apikey标签指定等级，优先于web_lv
+	LegacyLevel string            `json:"legacy_lv"` // 未注册的命令（交给策略onCmd处理）所需等级，默认trade
+}
+
+// 退出流程。超时为0时使用默认值
+type ShutdownConfig struct {
+	Policy             string  `json:"policy"`       // hold(默认)/cancel/flatten
+	Slippage           float64 `json:"slippage"`     // flatten的滑点上限，相对每笔平仓单下单时的对手价，默认0.01
+	StrategyTimeoutSec int     `json:"strategy_sec"` // 策略onQuit的超时，默认30秒
+	CancelTimeoutSec   int     `json:"cancel_sec"`   // 撤单的超时，默认10秒
+	FlattenTimeoutSec  int     `json:"flatten_sec"`  // 平仓的超时，默认30秒
+	FlushTimeoutSec    int     `json:"flush_sec"`    // 刷新数据的超时，默认10秒
+	ReportPath         string  `json:"report"`       // 退出报告的路径，默认为log/shutdown_<时间>.json
+}
+
+// 代理池
+type ProxyConfig struct {
+	Enabled    bool              `json:"enabled"`
+	StaticFile string            `json:"static"`      // 静态代理列表文件，json数组
+	Providers  []string          `json:"providers"`   // 代理供应商：proxyline/iproyal
+	PinAccount bool              `json:"pin_account"` // 每个账号固定使用一个代理（交易所ip白名单），否则按host轮换
+	Pins       map[string]string `json:"pins"`        // 账号->出口ip，这些账号固定到指定ip的代理
+	Renew      bool              `json:"renew"`       // 到期前自动续费
+}
+
+// 主备模式。两个进程使用相同的key竞争redis中的租约，持有租约的主节点才能下单
+type StandbyConfig struct {
+	Enabled     bool             `json:"enabled"`
+	Redis       util.RedisConfig `json:"redis"`
+	Key         string           `json:"key"`          // 租约的key，默认dagger:standby:<name>
+	NodeId      string           `json:"node"`         // 节点标识，默认<hostname>-<pid>
+	LeaseMs     int              `json:"lease_ms"`     // 租约有效期，默认10000毫秒
+	RenewMs     int              `json:"renew_ms"`     // 续期/竞争间隔，默认为有效期的1/3，不能超过有效期的1/2
+	StrictFence bool             `json:"strict_fence"` // 每次下单前到redis校验token，多一次网络往返
+	KeepOrphans bool             `json:"keep_orphans"` // 接管时保留旧主节点留下的挂单，只记录
+	StayOnLost  bool             `json:"stay_on_lost"` // 失去租约后作为备节点继续运行，默认退出
+}
@@ -0,0 +1,220 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 14:31:26
+- @Description: 主备模式下策略看到的交易所视图。交易器包了一层，下单/改单前检查本节点是否持有租约（fencing）：
+- 1. 本节点是主节点，且租约在本地没有过期（到期时间留出了安全余量）
+- 2. strict_fence时，还要到redis校验token仍是当前任期
+- 不满足时MakeOrder返回nil，批量操作返回ErrNotActive。撤单不受限制
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+var ErrNotActive = errors.New("not active node")
+
+// #region fencing
+type fence struct {
+	lease  *util.RedisLease
+	strict bool
+
+	active   bool
+	token    int64
+	deadline time.Time // 本地认为租约有效的最后时间
+	mu       sync.RWMutex
+}
+
+func (f *fence) isActive() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.active
+}
+
+func (f *fence) term() (active bool, token int64, deadline time.Time) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.active, f.token, f.deadline
+}
+
+// 是否允许下单
+func (f *fence) allow() bool {
+	active, token, deadline := f.term()
+	if !active || time.Now().After(deadline) {
+		return false
+	}
+	if f.strict && !f.lease.Check(token) {
+		return false
+	}
+	return true
+}
+
+// #endregion
+
+// #region 交易所视图
+type fencedEx struct {
+	common.CEx
+	f         *fence
+	logPrefix string
+
+	futureTraders map[common.FutureTrader]*fencedFutureTrader
+	spotTraders   map[common.SpotTrader]*fencedSpotTrader
+	mu            sync.Mutex
+}
+
+func newFencedEx(ex common.CEx, f *fence, logPrefix string) *fencedEx {
+	return &fencedEx{
+		CEx:           ex,
+		f:             f,
+		logPrefix:     logPrefix,
+		futureTraders: make(map[common.FutureTrader]*fencedFutureTrader),
+		spotTraders:   make(map[common.SpotTrader]*fencedSpotTrader),
+	}
+}
+
+// 去掉fencing，取得原始的交易所对象
+func unwrapFencedEx(ex common.CEx) common.CEx {
+	if fe, ok := ex.(*fencedEx); ok {
+		return fe.CEx
+	}
+	return ex
+}
+
+func (e *fencedEx) wrapFuture(t common.FutureTrader) common.FutureTrader {
+	if t == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ft, ok := e.futureTraders[t]
+	if !ok {
+		ft = &fencedFutureTrader{FutureTrader: t, g: orderGate{t: t, ex: e}}
+		e.futureTraders[t] = ft
+	}
+	return ft
+}
+
+func (e *fencedEx) wrapSpot(t common.SpotTrader) common.SpotTrader {
+	if t == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st, ok := e.spotTraders[t]
+	if !ok {
+		st = &fencedSpotTrader{SpotTrader: t, g: orderGate{t: t, ex: e}}
+		e.spotTraders[t] = st
+	}
+	return st
+}
+
+func (e *fencedEx) UseFutureTrader(symbol, contractType string, lever int) common.FutureTrader {
+	return e.wrapFuture(e.CEx.UseFutureTrader(symbol, contractType, lever))
+}
+
+func (e *fencedEx) UseSpotTrader(baseCcy, quoteCcy string) common.SpotTrader {
+	return e.wrapSpot(e.CEx.UseSpotTrader(baseCcy, quoteCcy))
+}
+
+func (e *fencedEx) FutureTraders() []common.FutureTrader {
+	traders := e.CEx.FutureTraders()
+	for i, t := range traders {
+		traders[i] = e.wrapFuture(t)
+	}
+	return traders
+}
+
+func (e *fencedEx) SpotTraders() []common.SpotTrader {
+	traders := e.CEx.SpotTraders()
+	for i, t := range traders {
+		traders[i] = e.wrapSpot(t)
+	}
+	return traders
+}
+
+// #endregion
+
+// #region 交易器
+type fencedFutureTrader struct {
+	common.FutureTrader
+	g orderGate
+}
+
+func (t *fencedFutureTrader) MakeOrder(price, amount decimal.Decimal, dir common.OrderDir, makeOnly, reduceOnly bool, purpose string, observer common.OrderObserver) common.Order {
+	return t.g.makeOrder(price, amount, dir, makeOnly, reduceOnly, purpose, observer)
+}
+
+func (t *fencedFutureTrader) MakeOrders(reqs []common.OrderRequest) []common.OrderResult {
+	return t.g.makeOrders(reqs)
+}
+
+func (t *fencedFutureTrader) ModifyOrders(reqs []common.OrderModifyRequest) []common.OrderResult {
+	return t.g.modifyOrders(reqs)
+}
+
+type fencedSpotTrader struct {
+	common.SpotTrader
+	g orderGate
+}
+
+func (t *fencedSpotTrader) MakeOrder(price, amount decimal.Decimal, dir common.OrderDir, makeOnly, reduceOnly bool, purpose string, observer common.OrderObserver) common.Order {
+	return t.g.makeOrder(price, amount, dir, makeOnly, reduceOnly, purpose, observer)
+}
+
+func (t *fencedSpotTrader) MakeOrders(reqs []common.OrderRequest) []common.OrderResult {
+	return t.g.makeOrders(reqs)
+}
+
+func (t *fencedSpotTrader) ModifyOrders(reqs []common.OrderModifyRequest) []common.OrderResult {
+	return t.g.modifyOrders(reqs)
+}
+
+type orderGate struct {
+	t  common.CommonTrader
+	ex *fencedEx
+}
+
+func (g *orderGate) makeOrder(price, amount decimal.Decimal, dir common.OrderDir, makeOnly, reduceOnly bool, purpose string, observer common.OrderObserver) common.Order {
+	if !g.ex.f.allow() {
+		logger.LogInfo(g.ex.logPrefix, "order rejected, not active node: %s %s %v@%v", g.t.String(), common.OrderDir2Str(dir), amount, price)
+		return nil
+	}
+	return g.t.MakeOrder(price, amount, dir, makeOnly, reduceOnly, purpose, observer)
+}
+
+func (g *orderGate) makeOrders(reqs []common.OrderRequest) []common.OrderResult {
+	if !g.ex.f.allow() {
+		logger.LogInfo(g.ex.logPrefix, "%d orders rejected, not active node: %s", len(reqs), g.t.String())
+		return rejectAll(len(reqs))
+	}
+	return g.t.MakeOrders(reqs)
+}
+
+func (g *orderGate) modifyOrders(reqs []common.OrderModifyRequest) []common.OrderResult {
+	if !g.ex.f.allow() {
+		logger.LogInfo(g.ex.logPrefix, "%d modifies rejected, not active node: %s", len(reqs), g.t.String())
+		return rejectAll(len(reqs))
+	}
+	return g.t.ModifyOrders(reqs)
+}
+
+func rejectAll(n int) []common.OrderResult {
+	results := make([]common.OrderResult, n)
+	for i := range results {
+		results[i].Err = ErrNotActive
+	}
+	return results
+}
+
+// #endregion
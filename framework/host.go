@@ -0,0 +1,307 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 08:45:06
+- @Description: 多策略托管进程。从profile下的host.json加载多个策略实例（按class从注册表创建），同一账户的实例共用一个CEx
+- 命令行：ls/classes/start/stop/restart管理实例，"<实例名> <命令>"转发给对应实例
+- web：/s/<实例名>/...转发给对应实例注册的路由
+- 注意：交易所api的key是进程级的，一个进程内每个交易所只能使用一个账户；死亡开关不在托管模式下提供
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/apikey"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/metrics"
+	"github.com/aztecqt/dagger/util/terminal"
+)
+
+// 订单标签的最大长度。clientId最长32位，还要留给序号和purpose
+const maxOrderTagLen = 8
+
+// 托管进程的启动参数
+// name/class/log/key/cs/pprof/web/alert等进程级设置与LaunchConfig相同，ex/acc/ex_cfg不使用
+type HostConfig struct {
+	LaunchConfig
+
+	// 交易所名称 -> 交易所配置
+	ExchangeConfigs map[string]interface{} `json:"ex_cfgs"`
+
+	Strategies []HostStrategyConfig `json:"strategies"`
+}
+
+type HostStrategyConfig struct {
+	Name         string     `json:"name"`
+	Class        string     `json:"class"`
+	ExchangeName string     `json:"ex"`
+	Account      string     `json:"acc"`
+	OrderTag     string     `json:"tag"`      // 订单标签，为空时取name。只保留字母和数字，最长8位
+	ParamPath    string     `json:"param"`    // 运行参数路径，为空时为profile下的<name>/param.json
+	Budget       RiskBudget `json:"budget"`   // 风险预算
+	Disabled     bool       `json:"disabled"` // 进程启动时不启动，之后可以用start命令启动
+}
+
+type Host struct {
+	StrategyBase
+	HC HostConfig
+
+	accounts   map[string] /*ex*/ *hostAccount
+	muAccounts sync.Mutex
+
+	instances map[string]*strategyInstance
+	names     []string // 按配置顺序
+}
+
+// 共享的交易所连接
+type hostAccount struct {
+	exName  string
+	account string
+	kreq    *apikey.Requester // 不需要凭证的交易所为nil
+	ex      common.CEx
+}
+
+func (h *Host) Start() {
+	h.onCommand = h.onHostCommand
+	h.onQuit = h.onHostQuit
+
+	// 根据profile加载启动参数
+	profileDir, profileDirOk := util.GetProfileDir()
+	if !profileDirOk {
+		fmt.Println("load profile failed")
+		return
+	}
+
+	hc := HostConfig{}
+	hc.ProfileRoot = profileDir
+	util.ObjectFromFile(fmt.Sprintf("%s/host.json", profileDir), &hc)
+	if len(hc.Class) == 0 {
+		hc.Class = "host"
+	}
+	h.HC = hc
+	h.LC = hc.LaunchConfig
+
+	// 初始化Log
+	h.LogPrefix = fmt.Sprintf("%s.%s", hc.Class, hc.Name)
+	initLogger(hc.LogConfig, h.LogPrefix)
+	h.checkShutdownConfig()
+	if hc.Standby.Enabled {
+		logger.LogPanic(h.LogPrefix, "standby is not supported by host, run the strategy standalone")
+	}
+	h.proxyPool = startProxyPool(hc.Proxy, h.LogPrefix)
+
+	// 检查配置，创建实例
+	h.accounts = make(map[string]*hostAccount)
+	h.instances = make(map[string]*strategyInstance)
+	exAccounts := make(map[string]string)
+	for _, cfg := range hc.Strategies {
+		if len(cfg.Name) == 0 {
+			logger.LogPanic(h.LogPrefix, "strategy name is empty")
+		}
+		if _, ok := h.instances[cfg.Name]; ok {
+			logger.LogPanic(h.LogPrefix, "duplicated strategy name: %s", cfg.Name)
+		}
+		if _, ok := GetStrategyFactory(cfg.Class); !ok {
+			logger.LogPanic(h.LogPrefix, "unknown strategy class: %s (registered: %s)", cfg.Class, strings.Join(StrategyClasses(), ","))
+		}
+		if _, ok := common.GetExchangeFactory(cfg.ExchangeName); !ok {
+			logger.LogPanic(h.LogPrefix, "unknown exchange: %s (registered: %s)", cfg.ExchangeName, strings.Join(common.ExchangeNames(), ","))
+		}
+		exName := strings.ToLower(cfg.ExchangeName)
+		if acc, ok := exAccounts[exName]; ok && acc != cfg.Account {
+			logger.LogPanic(h.LogPrefix, "only one account per exchange is supported, %s: %s/%s", cfg.ExchangeName, acc, cfg.Account)
+		}
+		exAccounts[exName] = cfg.Account
+
+		h.instances[cfg.Name] = newStrategyInstance(h, cfg)
+		h.names = append(h.names, cfg.Name)
+	}
+
+	// 命令行、cs、监控、告警、web等公共服务
+	h.exchangesFn = h.sharedExchanges
+	h.startServices()
+	h.registerHostCommands()
+	if h.WebService != nil {
+		for _, name := range h.names {
+			h.WebService.RegisterPath(hostedRoutePrefix+name, h.instances[name].onHttp)
+		}
+	}
+
+	// 启动实例
+	for _, name := range h.names {
+		inst := h.instances[name]
+		if inst.cfg.Disabled {
+			continue
+		}
+		if err := inst.start(); err != nil {
+			logger.LogImportant(h.LogPrefix, err.Error())
+		}
+	}
+
+	h.running = true
+	for h.running {
+		time.Sleep(time.Second)
+	}
+}
+
+// 获取交易所连接，不存在时创建
+func (h *Host) useAccount(exName, account string) (*hostAccount, error) {
+	h.muAccounts.Lock()
+	defer h.muAccounts.Unlock()
+
+	key := strings.ToLower(exName)
+	if acc, ok := h.accounts[key]; ok {
+		if acc.account != account {
+			return nil, fmt.Errorf("%s is using account %s, can't use %s", exName, acc.account, account)
+		}
+		return acc, nil
+	}
+
+	acc := &hostAccount{exName: exName, account: account}
+	proxy := proxySelectorOf(h.proxyPool, h.LC.Proxy, account)
+	acc.ex, acc.kreq = newExchange(exName, h.HC.ExchangeConfigs[exName], h.LC.Key, account, h.Name(), h.Name(), proxy, h.errorNotifier, h.LogPrefix)
+	h.accounts[key] = acc
+	metrics.RegisterCollector("host.ex."+key, func(e *metrics.Emitter) {
+		collectExchangeMetrics(e, acc.ex, h.Name())
+	})
+	common.SeedLastDealTime(acc.ex.Name(), time.Now())
+	return acc, nil
+}
+
+// 启动实例
+func (h *Host) StartStrategy(name string) error {
+	if inst, ok := h.instances[name]; ok {
+		return inst.start()
+	} else {
+		return fmt.Errorf("strategy %s not found", name)
+	}
+}
+
+// 停止实例
+func (h *Host) StopStrategy(name string) error {
+	if inst, ok := h.instances[name]; ok {
+		return inst.stop()
+	} else {
+		return fmt.Errorf("strategy %s not found", name)
+	}
+}
+
+// #region 命令行
+func (h *Host) registerHostCommands() {
+	nameArg := terminal.Arg{Name: "name", Help: "strategy name", Complete: func() []string { return h.names }}
+	respErr := func(c *terminal.CmdCall, err error, okMsg string) {
+		if err != nil {
+			c.Resp(err.Error())
+		} else {
+			c.Resp(okMsg)
+		}
+	}
+
+	h.Commands.Register(
+		&terminal.Command{
+			Name: "ls",
+			Help: "list all strategies",
+			Perm: terminal.Perm_Read,
+			Run: func(c *terminal.CmdCall) {
+				sb := strings.Builder{}
+				for _, name := range h.names {
+					sb.WriteString(h.instances[name].status())
+					sb.WriteString("\n")
+				}
+				c.Resp(sb.String())
+			},
+		},
+		&terminal.Command{
+			Name: "classes",
+			Help: "list registered strategy classes",
+			Perm: terminal.Perm_Read,
+			Run:  func(c *terminal.CmdCall) { c.Resp(strings.Join(StrategyClasses(), "\n")) },
+		},
+		&terminal.Command{
+			Name: "start",
+			Help: "start strategy",
+			Perm: terminal.Perm_Trade,
+			Args: []terminal.Arg{nameArg},
+			Run: func(c *terminal.CmdCall) {
+				name := c.String("name")
+				respErr(c, h.StartStrategy(name), fmt.Sprintf("%s started", name))
+			},
+		},
+		&terminal.Command{
+			Name: "stop",
+			Help: "stop strategy and cancel its orders",
+			Perm: terminal.Perm_Trade,
+			Args: []terminal.Arg{nameArg},
+			Run: func(c *terminal.CmdCall) {
+				name := c.String("name")
+				respErr(c, h.StopStrategy(name), fmt.Sprintf("%s stopped", name))
+			},
+		},
+		&terminal.Command{
+			Name: "restart",
+			Help: "restart strategy",
+			Perm: terminal.Perm_Trade,
+			Args: []terminal.Arg{nameArg},
+			Run: func(c *terminal.CmdCall) {
+				name := c.String("name")
+				if err := h.StopStrategy(name); err != nil {
+					c.Resp(err.Error())
+				}
+				respErr(c, h.StartStrategy(name), fmt.Sprintf("%s restarted", name))
+			},
+		},
+	)
+}
+
+// 未注册的命令："<实例名> <命令>"转发给对应实例
+func (h *Host) onHostCommand(cmdLine string, onResp func(string)) {
+	args := strings.Fields(cmdLine)
+	if len(args) == 0 {
+		return
+	}
+
+	if args[0] == "help" {
+		onResp("[name] [cmd]:   send command to strategy, [name] help for details")
+	} else if inst, ok := h.instances[args[0]]; ok {
+		inst.onCommand(strings.Join(args[1:], " "), onResp)
+	} else {
+		onResp(fmt.Sprintf("unknown command: %s", cmdLine))
+	}
+}
+
+// 所有共享的交易所，用于orders/positions等命令
+func (h *Host) sharedExchanges() map[string]common.CEx {
+	h.muAccounts.Lock()
+	defer h.muAccounts.Unlock()
+	exs := make(map[string]common.CEx)
+	for key, acc := range h.accounts {
+		exs[key] = acc.ex
+	}
+	return exs
+}
+
+// #endregion
+
+// 停止所有实例，释放apikey
+func (h *Host) onHostQuit() {
+	for _, name := range h.names {
+		if inst := h.instances[name]; inst.running {
+			inst.stop()
+		}
+	}
+
+	h.muAccounts.Lock()
+	defer h.muAccounts.Unlock()
+	for _, acc := range h.accounts {
+		if acc.kreq != nil {
+			acc.kreq.Quit()
+		}
+	}
+}
@@ -0,0 +1,261 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 08:31:52
+- @Description: 托管策略实例的运行环境。每个实例有独立的日志前缀、订单标签、风险预算、命令行命名空间和web路由(/s/<name>/...)
+- 同一账户的实例共用一个CEx；消息通知、告警、web服务由Host提供
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/center_server/csclient"
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/alert"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/metrics"
+	"github.com/aztecqt/dagger/util/webservice"
+)
+
+const hostedRoutePrefix = "/s/"
+
+type StrategyContext struct {
+	Name      string
+	Class     string
+	LogPrefix string
+	OrderTag  string
+	Budget    RiskBudget
+
+	// 本策略的交易所视图。交易器只能看到本策略的订单，下单受风险预算约束
+	Ex common.CEx
+
+	// 运行参数路径
+	ParamPath string
+
+	// Host提供的公共服务
+	IntelClient *csclient.IntelClient
+	Alert       *alert.Engine
+
+	inst *strategyInstance
+}
+
+// 策略主循环需检查此值，为false时退出
+func (c *StrategyContext) Running() bool {
+	return c.inst.running
+}
+
+// 注册本策略的web路由，实际路径为/s/<name><path>。Host未启用web服务时无效
+func (c *StrategyContext) RegisterPath(path string, h webservice.HttpHandler) {
+	c.inst.muRoutes.Lock()
+	defer c.inst.muRoutes.Unlock()
+	c.inst.routes[path] = h
+}
+
+// 本策略web路由的完整路径
+func (c *StrategyContext) RoutePath(path string) string {
+	return hostedRoutePrefix + c.Name + path
+}
+
+// 未完成订单的数量和总价值
+func (c *StrategyContext) BudgetUsage() (n int, value float64) {
+	return c.inst.ex.budget.usage(c.inst.ex)
+}
+
+// #region 实例
+type strategyInstance struct {
+	host      *Host
+	cfg       HostStrategyConfig
+	logPrefix string
+	orderTag  string
+
+	running  bool
+	strategy HostedStrategy
+	ctx      *StrategyContext
+	ex       *hostedEx
+	mu       sync.Mutex // 启动/停止互斥
+
+	routes   map[string]webservice.HttpHandler
+	muRoutes sync.RWMutex
+}
+
+func newStrategyInstance(h *Host, cfg HostStrategyConfig) *strategyInstance {
+	inst := &strategyInstance{
+		host:      h,
+		cfg:       cfg,
+		logPrefix: fmt.Sprintf("%s.%s", cfg.Class, cfg.Name),
+		orderTag:  util.ToLetterNumberOnly(util.ValueIf(len(cfg.OrderTag) > 0, cfg.OrderTag, cfg.Name), maxOrderTagLen),
+		routes:    make(map[string]webservice.HttpHandler),
+	}
+
+	if len(inst.cfg.ParamPath) == 0 {
+		inst.cfg.ParamPath = fmt.Sprintf("%s/%s/param.json", h.LC.ProfileRoot, cfg.Name)
+	}
+
+	metrics.RegisterCollector("hosted."+cfg.Name, inst.collectMetrics)
+	return inst
+}
+
+func (inst *strategyInstance) start() error {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	if inst.running {
+		return fmt.Errorf("%s is already running", inst.cfg.Name)
+	}
+
+	factory, ok := GetStrategyFactory(inst.cfg.Class)
+	if !ok {
+		return fmt.Errorf("unknown strategy class: %s", inst.cfg.Class)
+	}
+
+	acc, err := inst.host.useAccount(inst.cfg.ExchangeName, inst.cfg.Account)
+	if err != nil {
+		return err
+	}
+
+	logger.LogImportant(inst.logPrefix, "starting, order tag=%s", inst.orderTag)
+	inst.ex = newHostedEx(acc.ex, inst.orderTag, inst.cfg.Budget, inst.logPrefix)
+	inst.muRoutes.Lock()
+	inst.routes = make(map[string]webservice.HttpHandler)
+	inst.muRoutes.Unlock()
+	inst.ctx = &StrategyContext{
+		Name:        inst.cfg.Name,
+		Class:       inst.cfg.Class,
+		LogPrefix:   inst.logPrefix,
+		OrderTag:    inst.orderTag,
+		Budget:      inst.cfg.Budget,
+		Ex:          inst.ex,
+		ParamPath:   inst.cfg.ParamPath,
+		IntelClient: inst.host.IntelClient,
+		Alert:       inst.host.Alert,
+		inst:        inst,
+	}
+	inst.strategy = factory()
+	inst.running = true
+
+	// 单个策略启动失败不影响其他策略
+	startOk := false
+	func() {
+		defer util.DefaultRecoverWithCallback(func(e string) {
+			err = fmt.Errorf("%s start failed: %s", inst.cfg.Name, e)
+		})
+		inst.strategy.OnStart(inst.ctx)
+		startOk = true
+	}()
+
+	if !startOk {
+		inst.running = false
+		inst.cancelOrders()
+		return err
+	}
+
+	logger.LogImportant(inst.logPrefix, "started")
+	return nil
+}
+
+func (inst *strategyInstance) stop() error {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	if !inst.running {
+		return fmt.Errorf("%s is not running", inst.cfg.Name)
+	}
+
+	logger.LogImportant(inst.logPrefix, "stopping...")
+	inst.running = false
+	func() {
+		defer util.DefaultRecover()
+		inst.strategy.OnQuit()
+	}()
+	inst.cancelOrders()
+
+	inst.muRoutes.Lock()
+	inst.routes = make(map[string]webservice.HttpHandler)
+	inst.muRoutes.Unlock()
+	logger.LogImportant(inst.logPrefix, "stopped")
+	return nil
+}
+
+// 撤销本策略剩余的订单，最多等待几秒
+func (inst *strategyInstance) cancelOrders() {
+	for i := 0; i < 10; i++ {
+		alive := inst.ex.aliveOrders()
+		if len(alive) == 0 {
+			return
+		}
+
+		if i == 0 {
+			logger.LogImportant(inst.logPrefix, "canceling %d orders", len(alive))
+		}
+		for _, o := range alive {
+			if o.IsAlive() {
+				o.Cancel()
+			}
+		}
+		time.Sleep(time.Millisecond * 500)
+	}
+
+	logger.LogImportant(inst.logPrefix, "%d orders not finished after cancel", len(inst.ex.aliveOrders()))
+}
+
+func (inst *strategyInstance) onCommand(cmdLine string, onResp func(string)) {
+	if !inst.running {
+		onResp(fmt.Sprintf("%s is not running", inst.cfg.Name))
+		return
+	}
+
+	defer util.DefaultRecover()
+	inst.strategy.OnCommand(cmdLine, onResp)
+}
+
+// /s/<name>/...的请求，去掉前缀后按注册的路由分发，匹配规则与webservice相同
+func (inst *strategyInstance) onHttp(w http.ResponseWriter, r *http.Request) {
+	if !inst.running {
+		http.Error(w, fmt.Sprintf("%s is not running", inst.cfg.Name), http.StatusServiceUnavailable)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, hostedRoutePrefix+inst.cfg.Name)
+	inst.muRoutes.RLock()
+	h, ok := inst.routes[path]
+	ss := strings.Split(path, "/")
+	for !ok && len(ss) >= 2 {
+		h, ok = inst.routes[strings.Join(ss, "/")]
+		ss = ss[:len(ss)-1]
+	}
+	inst.muRoutes.RUnlock()
+
+	if ok {
+		h(w, r)
+	} else {
+		http.NotFound(w, r)
+	}
+}
+
+func (inst *strategyInstance) status() string {
+	st := "stopped"
+	if inst.running {
+		n, v := inst.ex.budget.usage(inst.ex)
+		st = fmt.Sprintf("running, orders=%d, open value=%.2f", n, v)
+	}
+	return fmt.Sprintf("%-16s %-16s %s/%s tag=%s %s", inst.cfg.Name, inst.cfg.Class, inst.cfg.ExchangeName, inst.cfg.Account, inst.orderTag, st)
+}
+
+func (inst *strategyInstance) collectMetrics(e *metrics.Emitter) {
+	st := inst.cfg.Name
+	e.Gauge("dagger_strategy_up", "Whether the strategy is running.", b2f(inst.running), "strategy", st, "class", inst.cfg.Class)
+	if inst.running {
+		n, v := inst.ex.budget.usage(inst.ex)
+		e.Gauge("dagger_strategy_open_orders", "Unfinished orders of the hosted strategy.", float64(n), "strategy", st)
+		e.Gauge("dagger_strategy_open_value", "Value of unfinished orders of the hosted strategy.", v, "strategy", st)
+	}
+}
+
+// #endregion
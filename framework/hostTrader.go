@@ -0,0 +1,308 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 08:20:15
+- @Description: 托管策略看到的交易所视图。多个策略共用同一个CEx，每个策略拿到的交易器都包了一层：
+- 1. 下单时purpose前加上策略的订单标签，clientId中可以看出订单属于哪个策略
+- 2. Orders()只返回本策略下的订单
+- 3. 下单前检查风险预算（单笔价值、未完成订单总价值、订单数量），reduceOnly订单不检查价值
+- 订单价值按价格*数量计算，与交易所的合约面值无关
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+var ErrRiskBudgetExceeded = errors.New("risk budget exceeded")
+
+// 风险预算，0表示不限
+type RiskBudget struct {
+	MaxOrderValue float64 `json:"max_order_value"` // 单笔订单价值上限
+	MaxOpenValue  float64 `json:"max_open_value"`  // 未完成订单的总价值上限
+	MaxOrders     int     `json:"max_orders"`      // 未完成订单数量上限
+}
+
+// #region 交易所视图
+type hostedEx struct {
+	common.CEx
+	budget    *orderBudget
+	orderTag  string
+	logPrefix string
+
+	futureTraders map[common.FutureTrader]*hostedFutureTrader
+	spotTraders   map[common.SpotTrader]*hostedSpotTrader
+	mu            sync.Mutex
+}
+
+func newHostedEx(ex common.CEx, orderTag string, budget RiskBudget, logPrefix string) *hostedEx {
+	return &hostedEx{
+		CEx:           ex,
+		budget:        &orderBudget{cfg: budget},
+		orderTag:      orderTag,
+		logPrefix:     logPrefix,
+		futureTraders: make(map[common.FutureTrader]*hostedFutureTrader),
+		spotTraders:   make(map[common.SpotTrader]*hostedSpotTrader),
+	}
+}
+
+func (e *hostedEx) UseFutureTrader(symbol, contractType string, lever int) common.FutureTrader {
+	t := e.CEx.UseFutureTrader(symbol, contractType, lever)
+	if t == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ht, ok := e.futureTraders[t]
+	if !ok {
+		ht = &hostedFutureTrader{FutureTrader: t, ot: orderTracker{t: t, ex: e}}
+		e.futureTraders[t] = ht
+	}
+	return ht
+}
+
+func (e *hostedEx) UseSpotTrader(baseCcy, quoteCcy string) common.SpotTrader {
+	t := e.CEx.UseSpotTrader(baseCcy, quoteCcy)
+	if t == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ht, ok := e.spotTraders[t]
+	if !ok {
+		ht = &hostedSpotTrader{SpotTrader: t, ot: orderTracker{t: t, ex: e}}
+		e.spotTraders[t] = ht
+	}
+	return ht
+}
+
+// 只返回本策略用过的交易器
+func (e *hostedEx) FutureTraders() []common.FutureTrader {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	traders := make([]common.FutureTrader, 0, len(e.futureTraders))
+	for _, t := range e.futureTraders {
+		traders = append(traders, t)
+	}
+	return traders
+}
+
+func (e *hostedEx) SpotTraders() []common.SpotTrader {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	traders := make([]common.SpotTrader, 0, len(e.spotTraders))
+	for _, t := range e.spotTraders {
+		traders = append(traders, t)
+	}
+	return traders
+}
+
+// 交易所由Host管理，策略不能关闭
+func (e *hostedEx) Exit() {}
+
+// 本策略所有未完成的订单
+func (e *hostedEx) aliveOrders() []common.Order {
+	orders := make([]common.Order, 0)
+	for _, t := range e.FutureTraders() {
+		orders = append(orders, t.Orders()...)
+	}
+	for _, t := range e.SpotTraders() {
+		orders = append(orders, t.Orders()...)
+	}
+
+	alive := orders[:0]
+	for _, o := range orders {
+		if !o.IsFinished() {
+			alive = append(alive, o)
+		}
+	}
+	return alive
+}
+
+// #endregion
+
+// #region 交易器
+type hostedFutureTrader struct {
+	common.FutureTrader
+	ot orderTracker
+}
+
+func (t *hostedFutureTrader) MakeOrder(price, amount decimal.Decimal, dir common.OrderDir, makeOnly, reduceOnly bool, purpose string, observer common.OrderObserver) common.Order {
+	return t.ot.makeOrder(price, amount, dir, makeOnly, reduceOnly, purpose, observer)
+}
+
+func (t *hostedFutureTrader) MakeOrders(reqs []common.OrderRequest) []common.OrderResult {
+	return t.ot.makeOrders(reqs)
+}
+
+func (t *hostedFutureTrader) Orders() []common.Order {
+	return t.ot.orders()
+}
+
+type hostedSpotTrader struct {
+	common.SpotTrader
+	ot orderTracker
+}
+
+func (t *hostedSpotTrader) MakeOrder(price, amount decimal.Decimal, dir common.OrderDir, makeOnly, reduceOnly bool, purpose string, observer common.OrderObserver) common.Order {
+	return t.ot.makeOrder(price, amount, dir, makeOnly, reduceOnly, purpose, observer)
+}
+
+func (t *hostedSpotTrader) MakeOrders(reqs []common.OrderRequest) []common.OrderResult {
+	return t.ot.makeOrders(reqs)
+}
+
+func (t *hostedSpotTrader) Orders() []common.Order {
+	return t.ot.orders()
+}
+
+// 记录本策略通过某个交易器下的订单
+type orderTracker struct {
+	t  common.CommonTrader
+	ex *hostedEx
+
+	mine map[common.Order]bool
+	mu   sync.Mutex
+}
+
+func (ot *orderTracker) makeOrder(price, amount decimal.Decimal, dir common.OrderDir, makeOnly, reduceOnly bool, purpose string, observer common.OrderObserver) common.Order {
+	b := ot.ex.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.check(ot.ex, price, amount, reduceOnly, 0, 0); err != nil {
+		logger.LogImportant(ot.ex.logPrefix, "order rejected by risk budget: %s %s %v@%v", ot.t.String(), common.OrderDir2Str(dir), amount, price)
+		return nil
+	}
+
+	o := ot.t.MakeOrder(price, amount, dir, makeOnly, reduceOnly, ot.ex.orderTag+purpose, observer)
+	ot.add(o)
+	return o
+}
+
+// 超出预算的请求直接返回错误，其余的一起提交
+func (ot *orderTracker) makeOrders(reqs []common.OrderRequest) []common.OrderResult {
+	b := ot.ex.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	results := make([]common.OrderResult, len(reqs))
+	passed := make([]common.OrderRequest, 0, len(reqs))
+	passedIndex := make([]int, 0, len(reqs))
+	passedValue := 0.0
+	for i, r := range reqs {
+		if err := b.check(ot.ex, r.Price, r.Amount, r.ReduceOnly, len(passed), passedValue); err != nil {
+			results[i].Err = err
+		} else {
+			r.Purpose = ot.ex.orderTag + r.Purpose
+			passed = append(passed, r)
+			passedIndex = append(passedIndex, i)
+			if !r.ReduceOnly {
+				passedValue += r.Price.Mul(r.Amount).Abs().InexactFloat64()
+			}
+		}
+	}
+
+	if len(passed) > 0 {
+		for i, r := range ot.t.MakeOrders(passed) {
+			results[passedIndex[i]] = r
+			ot.add(r.O)
+		}
+	}
+	return results
+}
+
+func (ot *orderTracker) add(o common.Order) {
+	if o == nil {
+		return
+	}
+
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+	if ot.mine == nil {
+		ot.mine = make(map[common.Order]bool)
+	}
+	ot.mine[o] = true
+}
+
+// 交易器已经清理掉的订单，这里也一并清理
+func (ot *orderTracker) orders() []common.Order {
+	ot.mu.Lock()
+	defer ot.mu.Unlock()
+
+	all := ot.t.Orders()
+	exists := make(map[common.Order]bool, len(all))
+	orders := make([]common.Order, 0)
+	for _, o := range all {
+		exists[o] = true
+		if ot.mine[o] {
+			orders = append(orders, o)
+		}
+	}
+
+	for o := range ot.mine {
+		if !exists[o] && o.IsFinished() {
+			delete(ot.mine, o)
+		}
+	}
+	return orders
+}
+
+// #endregion
+
+// #region 风险预算
+type orderBudget struct {
+	cfg RiskBudget
+	mu  sync.Mutex // 检查和下单之间不能有其他下单
+}
+
+// 检查本笔订单是否超出预算。pendingN/pendingValue为同一批中已通过检查、尚未提交的订单
+func (b *orderBudget) check(ex *hostedEx, price, amount decimal.Decimal, reduceOnly bool, pendingN int, pendingValue float64) error {
+	if b.cfg.MaxOrders <= 0 && b.cfg.MaxOrderValue <= 0 && b.cfg.MaxOpenValue <= 0 {
+		return nil
+	}
+
+	alive := ex.aliveOrders()
+	if b.cfg.MaxOrders > 0 && len(alive)+pendingN+1 > b.cfg.MaxOrders {
+		return ErrRiskBudgetExceeded
+	}
+
+	if reduceOnly {
+		return nil
+	}
+
+	value := price.Mul(amount).Abs().InexactFloat64()
+	if b.cfg.MaxOrderValue > 0 && value > b.cfg.MaxOrderValue {
+		return ErrRiskBudgetExceeded
+	}
+
+	if b.cfg.MaxOpenValue > 0 {
+		open := value + pendingValue
+		for _, o := range alive {
+			open += o.GetPrice().Mul(o.GetUnfilled()).Abs().InexactFloat64()
+		}
+		if open > b.cfg.MaxOpenValue {
+			return ErrRiskBudgetExceeded
+		}
+	}
+	return nil
+}
+
+// 未完成订单的数量和总价值
+func (b *orderBudget) usage(ex *hostedEx) (n int, value float64) {
+	alive := ex.aliveOrders()
+	for _, o := range alive {
+		value += o.GetPrice().Mul(o.GetUnfilled()).Abs().InexactFloat64()
+	}
+	return len(alive), value
+}
+
+// #endregion
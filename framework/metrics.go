@@ -0,0 +1,155 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 03:10:26
+- @Description: 策略的监控指标，通过web服务的/metrics以Prometheus文本格式输出
+- 包括：策略状态、仓位、权益、统一账户风险、资金费率、各状态的订单数、交易器就绪状态、http请求统计、ws连接统计、go运行时
+- 下单/撤单延迟和成交延迟由api、cex/common主动记录
+- 策略自定义指标直接用metrics.NewGauge/NewCounter注册即可，或用metrics.RegisterCollector在抓取时计算
+- /metrics和看板一样经过Service.Auth鉴权。Prometheus抓取时header带API-KEY，url参数signature为空payload的HMAC-SHA256（不带timestamp）
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aztecqt/dagger/api"
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util/metrics"
+	"github.com/aztecqt/dagger/util/network"
+)
+
+func (s *StrategyBase) metricsCollectorName() string {
+	return "strategy." + s.Name()
+}
+
+// 注册采集回调。/metrics由web服务提供
+func (s *StrategyBase) registerMetrics() {
+	metrics.RegisterRuntimeCollector()
+	metrics.RegisterCollector("network", collectNetworkMetrics)
+	metrics.RegisterCollector(s.metricsCollectorName(), s.collectMetrics)
+
+	now := time.Now()
+	for _, ex := range s.Exs {
+		common.SeedLastDealTime(ex.Name(), now)
+	}
+}
+
+func (s *StrategyBase) collectMetrics(e *metrics.Emitter) {
+	st := s.Name()
+	e.Gauge("dagger_strategy_up", "Whether the strategy is running.", b2f(s.running), "strategy", st, "class", s.Class())
+	e.Counter("dagger_strategy_errors_total", "Errors reported by the exchange to the strategy.", float64(s.errorCount), "strategy", st)
+	if s.standby != nil {
+		e.Gauge("dagger_strategy_active", "Whether the node holds the standby lease.", b2f(s.Active()), "strategy", st, "node", s.standby.lease.Owner())
+	}
+
+	if s.Ex == nil {
+		return
+	}
+
+	if s.DeadMan != nil {
+		e.Gauge("dagger_deadman_armed", "Whether the dead man switch is armed.", b2f(s.DeadMan.Armed()), "strategy", st, "exchange", s.Ex.Name())
+	}
+
+	collectExchangeMetrics(e, s.Ex, st)
+	for _, ex := range s.Exs {
+		if ex != s.Ex {
+			collectExchangeMetrics(e, ex, st)
+		}
+	}
+}
+
+// 交易所相关指标：账户风险、仓位、权益、资金费率、交易器和订单
+func collectExchangeMetrics(e *metrics.Emitter, cex common.CEx, st string) {
+	ex := cex.Name()
+
+	// 统一账户风险
+	risk := cex.GetUniAccRisk()
+	e.Gauge("dagger_uniacc_risk_level", "Unified account risk level, 0=safe 1=warning 2=danger.", float64(risk.Level), "strategy", st, "exchange", ex)
+	e.Gauge("dagger_uniacc_position_value", "Unified account position value.", risk.PositionValue.InexactFloat64(), "strategy", st, "exchange", ex)
+	e.Gauge("dagger_uniacc_total_margin", "Unified account total margin.", risk.TotalMargin.InexactFloat64(), "strategy", st, "exchange", ex)
+	e.Gauge("dagger_uniacc_maintain_margin", "Unified account maintenance margin.", risk.MaintainMargin.InexactFloat64(), "strategy", st, "exchange", ex)
+
+	// 仓位
+	for _, p := range cex.GetAllPositions() {
+		if p == nil {
+			continue
+		}
+		labels := []string{"strategy", st, "exchange", ex, "symbol", p.Symbol(), "contract", p.ContractType()}
+		e.Gauge("dagger_position_long", "Long position size.", p.Long().InexactFloat64(), labels...)
+		e.Gauge("dagger_position_short", "Short position size.", p.Short().InexactFloat64(), labels...)
+		e.Gauge("dagger_position_net", "Net position size.", p.Net().InexactFloat64(), labels...)
+	}
+
+	// 权益
+	for _, b := range cex.GetAllBalances() {
+		if b == nil {
+			continue
+		}
+		labels := []string{"strategy", st, "exchange", ex, "ccy", b.Ccy()}
+		e.Gauge("dagger_balance_rights", "Currency equity.", b.Rights().InexactFloat64(), labels...)
+		e.Gauge("dagger_balance_frozen", "Frozen currency amount.", b.Frozen().InexactFloat64(), labels...)
+		e.Gauge("dagger_balance_available", "Available currency amount.", b.Available().InexactFloat64(), labels...)
+	}
+
+	// 资金费率
+	for _, m := range cex.FutureMarkets() {
+		if rate, _, t, _ := m.FundingInfo(); !t.IsZero() {
+			e.Gauge("dagger_funding_rate", "Current funding rate.", rate.InexactFloat64(), "strategy", st, "exchange", ex, "symbol", m.Symbol(), "contract", m.ContractType())
+		}
+	}
+
+	// 交易器就绪状态和订单数
+	traders := make([]common.CommonTrader, 0)
+	for _, t := range cex.FutureTraders() {
+		traders = append(traders, t)
+	}
+	for _, t := range cex.SpotTraders() {
+		traders = append(traders, t)
+	}
+
+	for _, t := range traders {
+		name := t.String()
+		e.Gauge("dagger_trader_ready", "Whether the trader is ready.", b2f(t.Ready()), "strategy", st, "exchange", ex, "trader", name)
+
+		byStatus := make(map[string]int)
+		for _, o := range t.Orders() {
+			byStatus[o.GetStatus()]++
+		}
+		for status, n := range byStatus {
+			e.Gauge("dagger_orders", "Orders held by the trader, by status.", float64(n), "strategy", st, "exchange", ex, "trader", name, "status", status)
+		}
+	}
+}
+
+// http和ws的统计是进程级的，与策略无关
+func collectNetworkMetrics(e *metrics.Emitter) {
+	for name, hs := range network.AllHttpStats() {
+		e.Counter("dagger_http_requests_total", "Http requests including retries.", float64(hs.Count), "client", name)
+		e.Counter("dagger_http_errors_total", "Http requests failed with network errors.", float64(hs.Errors), "client", name)
+		e.Gauge("dagger_http_latency_avg_seconds", "Average http request latency.", hs.Avg.Seconds(), "client", name)
+		e.Gauge("dagger_http_latency_max_seconds", "Max http request latency.", hs.Max.Seconds(), "client", name)
+		for code, n := range hs.StatusCodes {
+			e.Counter("dagger_http_responses_total", "Http responses by status code.", float64(n), "client", name, "code", strconv.Itoa(code))
+		}
+	}
+
+	for _, ws := range api.AllWsStats() {
+		labels := []string{"conn", ws.Name, "url", ws.Url}
+		e.Gauge("dagger_ws_connected", "Whether the websocket is connected.", b2f(ws.Connected), labels...)
+		e.Counter("dagger_ws_reconnects_total", "Websocket connect attempts.", float64(ws.ReconnCount), labels...)
+		e.Counter("dagger_ws_messages_total", "Websocket messages received.", float64(ws.MsgCount), labels...)
+		e.Counter("dagger_ws_received_bytes_total", "Websocket bytes received after decompression.", float64(ws.Bytes), labels...)
+		e.Gauge("dagger_ws_message_rate", "Websocket messages per second in the last window.", ws.MsgRate, labels...)
+		e.Gauge("dagger_ws_lag_avg_seconds", "Average delay between server time and local receive time in the last window.", ws.LagAvg.Seconds(), labels...)
+		e.Gauge("dagger_ws_lag_max_seconds", "Max delay between server time and local receive time in the last window.", ws.LagMax.Seconds(), labels...)
+	}
+}
+
+func b2f(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 16:48:20
+- @Description: 从启动参数构建代理池，为每个账号生成代理选择器，交给交易所的rest客户端和ws连接使用
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"github.com/aztecqt/dagger/api/proxyprovider"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/network"
+)
+
+// 启动代理池。未启用时返回nil
+func startProxyPool(cfg ProxyConfig, logPrefix string) *proxyprovider.ProxyPool {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	pcfg := proxyprovider.DefaultPoolConfig()
+	pcfg.StaticFile = cfg.StaticFile
+	if !cfg.Renew {
+		pcfg.RenewBefore = 0
+	}
+
+	providers := make([]proxyprovider.Provider, 0, len(cfg.Providers))
+	for _, name := range cfg.Providers {
+		pv, err := proxyprovider.NewProvider(name)
+		if err != nil {
+			logger.LogPanic(logPrefix, err.Error())
+		}
+		providers = append(providers, pv)
+	}
+
+	pool := proxyprovider.NewProxyPool(pcfg, providers...)
+	if err := pool.Start(); err != nil {
+		logger.LogPanic(logPrefix, "start proxy pool failed: %s", err.Error())
+	}
+
+	for account, ip := range cfg.Pins {
+		if err := pool.Pin(account, ip); err != nil {
+			logger.LogPanic(logPrefix, "pin account %s failed: %s", account, err.Error())
+		}
+	}
+
+	logger.LogImportant(logPrefix, "proxy pool started, %d proxies", len(pool.Status()))
+	return pool
+}
+
+// 账号使用的代理选择器。指定了固定ip或pin_account时账号固定使用一个代理，否则按host轮换
+func proxySelectorOf(pool *proxyprovider.ProxyPool, cfg ProxyConfig, account string) network.ProxySelector {
+	if pool == nil {
+		return nil
+	}
+
+	if _, pinned := cfg.Pins[account]; pinned || (cfg.PinAccount && len(account) > 0) {
+		return pool.ForAccount(account)
+	}
+	return pool
+}
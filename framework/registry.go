@@ -0,0 +1,58 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 08:12:40
+- @Description: 策略类注册表。托管进程(Host)根据配置中的class找到工厂函数，创建策略实例
+- 策略包一般在init中调用RegisterStrategyClass完成注册
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"sort"
+	"sync"
+)
+
+// 由Host托管的策略
+// 每次启动都会通过工厂函数创建一个新对象，停止后该对象不再使用
+type HostedStrategy interface {
+	// 启动。策略在这里初始化，并自行启动主循环（主循环需检查ctx.Running()）
+	OnStart(ctx *StrategyContext)
+
+	// 停止。策略在这里结束自己的逻辑，未完成的订单由Host在之后统一撤销
+	OnQuit()
+
+	// 命令行。Host已去掉了命令前面的实例名
+	OnCommand(cmdLine string, onResp func(string))
+}
+
+type StrategyFactory func() HostedStrategy
+
+var strategyClasses = make(map[string]StrategyFactory)
+var muStrategyClasses sync.RWMutex
+
+// 注册策略类，重复注册时后者覆盖前者
+func RegisterStrategyClass(class string, factory StrategyFactory) {
+	muStrategyClasses.Lock()
+	defer muStrategyClasses.Unlock()
+	strategyClasses[class] = factory
+}
+
+func GetStrategyFactory(class string) (StrategyFactory, bool) {
+	muStrategyClasses.RLock()
+	defer muStrategyClasses.RUnlock()
+	f, ok := strategyClasses[class]
+	return f, ok
+}
+
+// 所有已注册的策略类
+func StrategyClasses() []string {
+	muStrategyClasses.RLock()
+	defer muStrategyClasses.RUnlock()
+	classes := make([]string, 0, len(strategyClasses))
+	for c := range strategyClasses {
+		classes = append(classes, c)
+	}
+	sort.Strings(classes)
+	return classes
+}
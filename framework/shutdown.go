@@ -0,0 +1,534 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 13:20:18
+- @Description: 退出流程。按LaunchConfig.Shutdown配置的策略依次执行以下阶段，每个阶段有独立的超时：
+- strategy: 调用策略的onQuit，策略应在其中停止交易逻辑
+- cancel:   撤销所有活动订单（cancel/flatten）
+- flatten:  以对手价偏移滑点的只减仓单平掉期货持仓（flatten），未成交部分持续补单直到平完或超时
+- flush:    执行策略注册的刷新函数（OnShutdownFlush/SaveOnShutdown），保存看板的数据线
+- 最后核对剩余订单和持仓，生成退出报告，与策略预期不符时发出告警
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/shopspring/decimal"
+)
+
+const alertEvent_ShutdownUnclean = "shutdown_unclean"
+
+// 退出策略
+type ShutdownPolicy int
+
+const (
+	ShutdownPolicy_Hold    ShutdownPolicy = iota // 保留订单和持仓，只保存状态
+	ShutdownPolicy_Cancel                        // 撤销所有订单
+	ShutdownPolicy_Flatten                       // 撤销所有订单并平掉期货持仓
+)
+
+func ShutdownPolicy2String(p ShutdownPolicy) string {
+	switch p {
+	case ShutdownPolicy_Hold:
+		return "hold"
+	case ShutdownPolicy_Cancel:
+		return "cancel"
+	case ShutdownPolicy_Flatten:
+		return "flatten"
+	default:
+		return "unknown"
+	}
+}
+
+// 无法识别时返回ShutdownPolicy_Hold，与没有退出策略时的行为一致
+func String2ShutdownPolicy(s string) ShutdownPolicy {
+	switch strings.ToLower(s) {
+	case "cancel":
+		return ShutdownPolicy_Cancel
+	case "flatten":
+		return ShutdownPolicy_Flatten
+	default:
+		return ShutdownPolicy_Hold
+	}
+}
+
+// #region 平仓器
+
+// 连续这么多笔平仓单没有任何成交就放弃（如被交易所拒绝）
+const closerMaxEmptyOrders = 3
+
+// 平仓任务。用takeOrder下单，结束后按剩余数量补单。由flattenAndWait轮询驱动，不单独开协程
+type positionCloser struct {
+	t         common.FutureTrader
+	amount    decimal.Decimal
+	dir       common.OrderDir
+	slippage  decimal.Decimal
+	purpose   string
+	logPrefix string
+
+	o           common.Order
+	dealed      decimal.Decimal // 已结束订单的成交量
+	emptyOrders int
+	finished    bool
+	failed      bool
+	stopped     bool
+}
+
+func newPositionCloser(t common.FutureTrader, amount decimal.Decimal, dir common.OrderDir, slippage decimal.Decimal, purpose, logPrefix string) *positionCloser {
+	c := &positionCloser{t: t, amount: amount, dir: dir, slippage: slippage, purpose: purpose, logPrefix: logPrefix}
+	c.update()
+	return c
+}
+
+func (c *positionCloser) update() {
+	if c.done() || c.stopped {
+		return
+	}
+
+	if c.o != nil {
+		if !c.o.IsFinished() {
+			return
+		}
+
+		filled := c.o.GetFilled()
+		c.dealed = c.dealed.Add(filled)
+		c.o = nil
+		if filled.IsPositive() {
+			c.emptyOrders = 0
+		} else if c.emptyOrders++; c.emptyOrders >= closerMaxEmptyOrders {
+			logger.LogImportant(c.logPrefix, "close %s %s gave up after %d orders without deal", c.t.String(), common.OrderDir2Str(c.dir), c.emptyOrders)
+			c.failed = true
+			return
+		}
+	}
+
+	// 剩余数量不超过当前持仓，持仓可能已被别的订单减少
+	remain := c.amount.Sub(c.dealed)
+	if p := c.t.Position(); p != nil {
+		remain = decimal.Min(remain, util.ValueIf(c.dir == common.OrderDir_Sell, p.Long(), p.Short()))
+	}
+	if remain.LessThan(c.t.Market().MinSize()) {
+		c.finished = true
+		return
+	}
+
+	c.o = takeOrder(c.t, remain, c.dir, c.slippage, c.purpose)
+}
+
+func (c *positionCloser) Finished() bool {
+	return c.finished
+}
+
+// 平完或已放弃，不再需要等待
+func (c *positionCloser) done() bool {
+	return c.finished || c.failed
+}
+
+func (c *positionCloser) Dealed() decimal.Decimal {
+	if c.o != nil {
+		return c.dealed.Add(c.o.GetFilled())
+	}
+	return c.dealed
+}
+
+// 停止补单，撤销未成交的平仓单
+func (c *positionCloser) Stop() {
+	c.stopped = true
+	if c.o != nil && c.o.IsAlive() {
+		c.o.Cancel()
+	}
+}
+
+// #endregion
+
+// #region 退出报告
+type ShutdownReport struct {
+	Name      string                `json:"name"`
+	Policy    string                `json:"policy"`
+	StartTime time.Time             `json:"start"`
+	EndTime   time.Time             `json:"end"`
+	Phases    []ShutdownPhaseResult `json:"phases"`
+	Closes    []ShutdownClose       `json:"closes"`    // 平仓任务
+	Orders    []ShutdownOrder       `json:"orders"`    // 剩余的活动订单
+	Positions []ShutdownPosition    `json:"positions"` // 剩余持仓
+	Clean     bool                  `json:"clean"`     // 剩余订单和持仓是否符合退出策略
+	Issues    []string              `json:"issues"`
+}
+
+type ShutdownPhaseResult struct {
+	Name      string `json:"name"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+	TimedOut  bool   `json:"timeout"`
+	Error     string `json:"error"`
+}
+
+type ShutdownClose struct {
+	Trader   string          `json:"trader"`
+	Dir      string          `json:"dir"`
+	Amount   decimal.Decimal `json:"amount"`
+	Dealed   decimal.Decimal `json:"dealed"`
+	Finished bool            `json:"finished"`
+}
+
+type ShutdownOrder struct {
+	Trader string          `json:"trader"`
+	ID     string          `json:"id"`
+	CID    string          `json:"cid"`
+	Dir    string          `json:"dir"`
+	Price  decimal.Decimal `json:"px"`
+	Size   decimal.Decimal `json:"sz"`
+	Filled decimal.Decimal `json:"filled"`
+}
+
+type ShutdownPosition struct {
+	Exchange string          `json:"ex"`
+	Symbol   string          `json:"symbol"`
+	Contract string          `json:"contract"`
+	Long     decimal.Decimal `json:"long"`
+	Short    decimal.Decimal `json:"short"`
+}
+
+func (r *ShutdownReport) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("shutdown report, policy=%s, clean=%v, cost=%v\n", r.Policy, r.Clean, r.EndTime.Sub(r.StartTime)))
+	for _, p := range r.Phases {
+		sb.WriteString(fmt.Sprintf("  phase %-10s%6dms", p.Name, p.ElapsedMs))
+		if p.TimedOut {
+			sb.WriteString(" timeout")
+		}
+		if len(p.Error) > 0 {
+			sb.WriteString(" error: " + p.Error)
+		}
+		sb.WriteString("\n")
+	}
+	for _, c := range r.Closes {
+		sb.WriteString(fmt.Sprintf("  close %s %s %v, dealed %v\n", c.Trader, c.Dir, c.Amount, c.Dealed))
+	}
+	sb.WriteString(fmt.Sprintf("  alive orders: %d, positions: %d", len(r.Orders), len(r.Positions)))
+	for _, issue := range r.Issues {
+		sb.WriteString("\n  issue: " + issue)
+	}
+	return sb.String()
+}
+
+// #endregion
+
+// #region 刷新
+type shutdownFlush struct {
+	name string
+	fn   func()
+}
+
+// 注册退出时的刷新函数，如状态保存、数据管理器的Flush等。所有退出策略都会执行，按注册顺序
+func (s *StrategyBase) OnShutdownFlush(name string, fn func()) {
+	s.muFlush.Lock()
+	defer s.muFlush.Unlock()
+	s.flushes = append(s.flushes, shutdownFlush{name: name, fn: fn})
+}
+
+// 退出时保存数据线（需设置了文件路径）
+func (s *StrategyBase) SaveOnShutdown(dls ...*DataLine) {
+	for _, dl := range dls {
+		s.OnShutdownFlush("dataline."+dl.Name(), dl.Save)
+	}
+}
+
+// #endregion
+
+func (c *ShutdownConfig) timeout(sec int, def time.Duration) time.Duration {
+	if sec > 0 {
+		return time.Second * time.Duration(sec)
+	}
+	return def
+}
+
+// 启动时检查退出策略是否可用
+func (s *StrategyBase) checkShutdownConfig() {
+	policy := String2ShutdownPolicy(s.LC.Shutdown.Policy)
+	if len(s.LC.Shutdown.Policy) > 0 && ShutdownPolicy2String(policy) != strings.ToLower(s.LC.Shutdown.Policy) {
+		logger.LogPanic(s.LogPrefix, "unknown shutdown policy: %s", s.LC.Shutdown.Policy)
+	}
+}
+
+// 按退出策略执行各阶段，返回退出报告
+func (s *StrategyBase) shutdown() *ShutdownReport {
+	cfg := s.LC.Shutdown
+	policy := String2ShutdownPolicy(cfg.Policy)
+	if policy != ShutdownPolicy_Hold && !s.Active() {
+		// 备节点不能动主节点的订单和持仓
+		logger.LogImportant(s.LogPrefix, "not active node, shutdown policy %s downgraded to hold", ShutdownPolicy2String(policy))
+		policy = ShutdownPolicy_Hold
+	}
+	report := &ShutdownReport{Name: s.Name(), Policy: ShutdownPolicy2String(policy), StartTime: time.Now()}
+	logger.LogImportant(s.LogPrefix, "shutdown with policy %s", report.Policy)
+
+	// 退出期间不再有心跳，避免死亡开关在hold策略下撤单
+	if s.DeadMan != nil {
+		s.DeadMan.Disarm()
+	}
+
+	report.Phases = append(report.Phases, runShutdownPhase("strategy", cfg.timeout(cfg.StrategyTimeoutSec, time.Second*30), func(stop <-chan struct{}) {
+		if s.onQuit != nil {
+			s.onQuit()
+		}
+	}))
+
+	if policy == ShutdownPolicy_Cancel || policy == ShutdownPolicy_Flatten {
+		report.Phases = append(report.Phases, runShutdownPhase("cancel", cfg.timeout(cfg.CancelTimeoutSec, time.Second*10), func(stop <-chan struct{}) {
+			s.cancelAndWait(stop)
+		}))
+	}
+
+	if policy == ShutdownPolicy_Flatten {
+		// 阶段超时多留出停止平仓任务的时间
+		flattenTimeout := cfg.timeout(cfg.FlattenTimeoutSec, time.Second*30)
+		closes := []ShutdownClose{}
+		closesMu := sync.Mutex{}
+		report.Phases = append(report.Phases, runShutdownPhase("flatten", flattenTimeout+time.Second*5, func(stop <-chan struct{}) {
+			c := s.flattenAndWait(flattenTimeout, stop)
+			closesMu.Lock()
+			closes = c
+			closesMu.Unlock()
+		}))
+
+		// 未成交的平仓单
+		report.Phases = append(report.Phases, runShutdownPhase("cancel", cfg.timeout(cfg.CancelTimeoutSec, time.Second*10), func(stop <-chan struct{}) {
+			s.cancelAndWait(stop)
+		}))
+
+		closesMu.Lock()
+		report.Closes = closes
+		closesMu.Unlock()
+	}
+
+	report.Phases = append(report.Phases, runShutdownPhase("flush", cfg.timeout(cfg.FlushTimeoutSec, time.Second*10), func(stop <-chan struct{}) {
+		s.flushAll()
+	}))
+
+	s.reconcile(report, policy)
+	report.EndTime = time.Now()
+	s.saveShutdownReport(report)
+	return report
+}
+
+// 执行一个阶段，超时后不再等待（fn仍可能在后台运行，应检查stop尽快返回）
+func runShutdownPhase(name string, timeout time.Duration, fn func(stop <-chan struct{})) ShutdownPhaseResult {
+	result := ShutdownPhaseResult{Name: name}
+	start := time.Now()
+	stop := make(chan struct{})
+	done := make(chan string, 1)
+	go func() {
+		errMsg := ""
+		defer func() { done <- errMsg }()
+		defer util.DefaultRecoverWithCallback(func(e string) { errMsg = e })
+		fn(stop)
+	}()
+
+	select {
+	case result.Error = <-done:
+	case <-time.After(timeout):
+		result.TimedOut = true
+		close(stop)
+	}
+	result.ElapsedMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// 所有活动订单
+func (s *StrategyBase) aliveOrders() []common.Order {
+	orders := make([]common.Order, 0)
+	for _, t := range s.cmdTraders("") {
+		for _, o := range t.Orders() {
+			if o.IsAlive() {
+				orders = append(orders, o)
+			}
+		}
+	}
+	return orders
+}
+
+// 撤销所有活动订单，等待撤单完成
+func (s *StrategyBase) cancelAndWait(stop <-chan struct{}) {
+	orders := s.aliveOrders()
+	logger.LogImportant(s.LogPrefix, "canceling %d orders", len(orders))
+	for _, o := range orders {
+		o.Cancel()
+	}
+
+	for len(s.aliveOrders()) > 0 {
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Millisecond * 200):
+		}
+	}
+}
+
+// 为每个期货持仓创建平仓任务，等待全部结束或超时
+func (s *StrategyBase) flattenAndWait(timeout time.Duration, stop <-chan struct{}) []ShutdownClose {
+	slippage := decimal.NewFromFloat(util.ValueIf(s.LC.Shutdown.Slippage > 0, s.LC.Shutdown.Slippage, 0.01))
+
+	type closeTask struct {
+		ShutdownClose
+		c *positionCloser
+	}
+
+	tasks := make([]*closeTask, 0)
+	for _, t := range s.cmdTraders("") {
+		ft, ok := t.(common.FutureTrader)
+		if !ok || ft.Position() == nil {
+			continue
+		}
+
+		p := ft.Position()
+		for _, side := range []struct {
+			amount decimal.Decimal
+			dir    common.OrderDir
+		}{{p.Long(), common.OrderDir_Sell}, {p.Short(), common.OrderDir_Buy}} {
+			if !side.amount.IsPositive() {
+				continue
+			}
+			logger.LogImportant(s.LogPrefix, "closing %s: %s %v", ft.String(), common.OrderDir2Str(side.dir), side.amount)
+			tasks = append(tasks, &closeTask{
+				ShutdownClose: ShutdownClose{Trader: ft.String(), Dir: common.OrderDir2Str(side.dir), Amount: side.amount},
+				c:             newPositionCloser(ft, side.amount, side.dir, slippage, "shutdown", s.LogPrefix),
+			})
+		}
+	}
+
+	allFinished := func() bool {
+		for _, task := range tasks {
+			task.c.update()
+			if !task.c.done() {
+				return false
+			}
+		}
+		return true
+	}
+
+	deadline := time.After(timeout)
+	for waiting := true; waiting && !allFinished(); {
+		select {
+		case <-stop:
+			waiting = false
+		case <-deadline:
+			waiting = false
+		case <-time.After(time.Millisecond * 200):
+		}
+	}
+
+	closes := make([]ShutdownClose, 0, len(tasks))
+	for _, task := range tasks {
+		task.c.Stop()
+		task.Dealed = task.c.Dealed()
+		task.Finished = task.c.Finished()
+		closes = append(closes, task.ShutdownClose)
+	}
+	return closes
+}
+
+// 执行所有刷新函数，单个失败不影响其他。看板注册的数据线也会保存
+func (s *StrategyBase) flushAll() {
+	s.muFlush.Lock()
+	flushes := append([]shutdownFlush{}, s.flushes...)
+	s.muFlush.Unlock()
+	if s.Dashboard != nil {
+		flushes = append(flushes, shutdownFlush{name: "dashboard.datalines", fn: s.Dashboard.saveDatalines})
+	}
+
+	for _, f := range flushes {
+		func() {
+			defer util.DefaultRecoverWithCallback(func(e string) {
+				logger.LogImportant(s.LogPrefix, "flush %s failed: %s", f.name, e)
+			})
+			f.fn()
+			logger.LogInfo(s.LogPrefix, "flush %s done", f.name)
+		}()
+	}
+}
+
+// 核对剩余订单和持仓
+func (s *StrategyBase) reconcile(report *ShutdownReport, policy ShutdownPolicy) {
+	for _, t := range s.cmdTraders("") {
+		for _, o := range t.Orders() {
+			if !o.IsAlive() {
+				continue
+			}
+			id, cid := o.GetID()
+			report.Orders = append(report.Orders, ShutdownOrder{
+				Trader: t.String(),
+				ID:     id,
+				CID:    cid,
+				Dir:    common.OrderDir2Str(o.GetDir()),
+				Price:  o.GetPrice(),
+				Size:   o.GetSize(),
+				Filled: o.GetFilled(),
+			})
+		}
+	}
+
+	exs := s.cmdExchanges()
+	for _, name := range sortedExchangeNames(exs) {
+		for _, p := range exs[name].GetAllPositions() {
+			if p == nil || (p.Long().IsZero() && p.Short().IsZero()) {
+				continue
+			}
+			report.Positions = append(report.Positions, ShutdownPosition{
+				Exchange: name,
+				Symbol:   p.Symbol(),
+				Contract: p.ContractType(),
+				Long:     p.Long(),
+				Short:    p.Short(),
+			})
+		}
+	}
+
+	for _, p := range report.Phases {
+		if p.TimedOut {
+			report.Issues = append(report.Issues, fmt.Sprintf("phase %s timeout", p.Name))
+		}
+		if len(p.Error) > 0 {
+			report.Issues = append(report.Issues, fmt.Sprintf("phase %s failed: %s", p.Name, p.Error))
+		}
+	}
+	if policy != ShutdownPolicy_Hold && len(report.Orders) > 0 {
+		report.Issues = append(report.Issues, fmt.Sprintf("%d orders still alive", len(report.Orders)))
+	}
+	if policy == ShutdownPolicy_Flatten {
+		if len(report.Positions) > 0 {
+			report.Issues = append(report.Issues, fmt.Sprintf("%d positions remain", len(report.Positions)))
+		}
+		for _, c := range report.Closes {
+			if !c.Finished {
+				report.Issues = append(report.Issues, fmt.Sprintf("close %s %s not finished, dealed %v/%v", c.Trader, c.Dir, c.Dealed, c.Amount))
+			}
+		}
+	}
+	report.Clean = len(report.Issues) == 0
+}
+
+// 报告写入日志目录，不干净时发出告警
+func (s *StrategyBase) saveShutdownReport(report *ShutdownReport) {
+	path := s.LC.Shutdown.ReportPath
+	if len(path) == 0 {
+		path = fmt.Sprintf("log/shutdown_%s.json", report.StartTime.Format("20060102_150405"))
+	}
+	util.MakeSureDirForFile(path)
+	if err := os.WriteFile(path, []byte(util.Object2String(report)), 0644); err != nil {
+		logger.LogImportant(s.LogPrefix, "save shutdown report failed: %s", err.Error())
+	}
+
+	logger.LogImportant(s.LogPrefix, report.String())
+	if !report.Clean && s.Alert != nil {
+		s.Alert.Event(alertEvent_ShutdownUnclean, "退出异常", strings.Join(report.Issues, "\n"), "strategy", s.LC.Name)
+	}
+}
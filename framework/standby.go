@@ -0,0 +1,321 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 14:52:08
+- @Description: 主备模式。同一策略的两个进程用相同的standby.key竞争redis中的租约：
+- 1. 两个进程都正常启动、订阅行情、执行onStart，只有持有租约的主节点能下单（见fencedTrader.go）
+- 2. 主节点定期续期。续期失败且本地租约到期后停止下单，默认随后退出进程
+- 3. 备节点在租约过期后获得租约，按订单标签查出旧主节点留下的挂单并撤销，然后开始交易
+- 每次获得租约时token加一，下单前检查token，保证任何时刻最多只有一个节点能下单
+- 本地租约到期时间 = 发起续期的时间 + 有效期*0.8，留出时钟误差和网络延迟的余量
+- 策略可以用Active判断自己是否主节点，或用OnActive注册成为主节点时的回调
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package framework
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/terminal"
+)
+
+const (
+	alertEvent_StandbyTakeover = "standby_takeover"
+	alertEvent_StandbyLost     = "standby_lost"
+
+	defaultStandbyLeaseMs = 10000
+	standbySafetyRatio    = 0.8 // 本地租约有效期占租约有效期的比例
+	standbyReconcileRetry = 3
+)
+
+type standbyNode struct {
+	s         *StrategyBase
+	cfg       StandbyConfig
+	lease     *util.RedisLease
+	f         *fence
+	renew     time.Duration
+	logPrefix string
+
+	onActive []func()
+	muHooks  sync.Mutex
+	chStop   chan struct{}
+	stopOnce sync.Once
+}
+
+func newStandbyNode(s *StrategyBase, cfg StandbyConfig) *standbyNode {
+	if len(cfg.Redis.Addr) == 0 {
+		logger.LogPanic(s.LogPrefix, "standby needs redis address")
+	}
+	if len(cfg.Key) == 0 {
+		cfg.Key = fmt.Sprintf("dagger:standby:%s", s.Name())
+	}
+	if len(cfg.NodeId) == 0 {
+		host, _ := os.Hostname()
+		cfg.NodeId = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if cfg.LeaseMs <= 0 {
+		cfg.LeaseMs = defaultStandbyLeaseMs
+	}
+
+	ttl := time.Millisecond * time.Duration(cfg.LeaseMs)
+	renew := time.Millisecond * time.Duration(cfg.RenewMs)
+	if renew <= 0 || renew > ttl/2 {
+		renew = ttl / 3
+	}
+
+	rc := &util.RedisClient{}
+	rc.InitFromConfig(cfg.Redis)
+	lease := util.NewRedisLease(rc, cfg.Key, cfg.NodeId, ttl)
+
+	n := &standbyNode{
+		s:         s,
+		cfg:       cfg,
+		lease:     lease,
+		f:         &fence{lease: lease, strict: cfg.StrictFence},
+		renew:     renew,
+		logPrefix: s.LogPrefix + ".standby",
+		chStop:    make(chan struct{}),
+	}
+	return n
+}
+
+// 同步尝试一次获取租约，然后在后台续期/竞争
+func (n *standbyNode) start() {
+	logger.LogImportant(n.logPrefix, "standby node %s started, key=%s, lease=%dms, renew=%v", n.lease.Owner(), n.cfg.Key, n.cfg.LeaseMs, n.renew)
+	n.tick()
+	if !n.f.isActive() {
+		owner, token, _ := n.lease.Holder()
+		logger.LogImportant(n.logPrefix, "running as standby, active node: %s(token=%d)", owner, token)
+	}
+
+	go func() {
+		defer util.DefaultRecover()
+		ticker := time.NewTicker(n.renew)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-n.chStop:
+				return
+			case <-ticker.C:
+				n.tick()
+			}
+		}
+	}()
+}
+
+// 停止续期，释放租约让备节点尽快接管
+func (n *standbyNode) stop() {
+	n.stopOnce.Do(func() {
+		close(n.chStop)
+		active, token, _ := n.f.term()
+		n.f.mu.Lock()
+		n.f.active = false
+		n.f.mu.Unlock()
+		if active && n.lease.Release(token) {
+			logger.LogImportant(n.logPrefix, "lease released, token=%d", token)
+		}
+	})
+}
+
+func (n *standbyNode) tick() {
+	select {
+	case <-n.chStop:
+		return
+	default:
+	}
+
+	start := time.Now()
+	token, ok := n.lease.TryAcquire()
+	active, curToken, deadline := n.f.term()
+
+	if !ok {
+		// redis访问失败时无法续期，本地租约到期后降级
+		if active && time.Now().After(deadline) {
+			n.demote("lease renew failed", true)
+		}
+		return
+	}
+
+	if token == 0 {
+		if active {
+			n.demote("lease taken by other node", true)
+		}
+		return
+	}
+
+	if active && token != curToken {
+		// 租约曾经过期并被自己重新获得，期间可能有其他节点下过单，按新任期重新接管
+		n.demote(fmt.Sprintf("lease token changed %d -> %d", curToken, token), false)
+	}
+
+	n.f.mu.Lock()
+	n.f.token = token
+	n.f.deadline = start.Add(time.Duration(float64(n.lease.TTL()) * standbySafetyRatio))
+	n.f.mu.Unlock()
+
+	if !active || token != curToken {
+		n.takeover(token)
+	}
+}
+
+// 成为主节点：先处理旧主节点留下的挂单，再允许下单
+func (n *standbyNode) takeover(token int64) {
+	logger.LogImportant(n.logPrefix, "lease acquired, token=%d, taking over", token)
+	summary := n.reconcileOrphans()
+
+	n.f.mu.Lock()
+	n.f.active = true
+	n.f.mu.Unlock()
+
+	n.muHooks.Lock()
+	hooks := append([]func(){}, n.onActive...)
+	n.muHooks.Unlock()
+	for _, fn := range hooks {
+		func() {
+			defer util.DefaultRecover()
+			fn()
+		}()
+	}
+
+	logger.LogImportant(n.logPrefix, "now active, token=%d. %s", token, summary)
+	if n.s.Alert != nil {
+		n.s.Alert.Event(alertEvent_StandbyTakeover, "主备切换", fmt.Sprintf("%s成为主节点(token=%d)。%s", n.lease.Owner(), token, summary), "strategy", n.s.Name())
+	}
+}
+
+// 失去租约：停止下单，撤销本节点的挂单。lost为true时（租约已被他人持有或无法续期）默认随后退出进程
+func (n *standbyNode) demote(reason string, lost bool) {
+	n.f.mu.Lock()
+	n.f.active = false
+	n.f.mu.Unlock()
+	logger.LogImportant(n.logPrefix, "no longer active: %s", reason)
+
+	if n.s.DeadMan != nil {
+		n.s.DeadMan.Disarm()
+	}
+	orders := n.s.aliveOrders()
+	for _, o := range orders {
+		o.Cancel()
+	}
+
+	if n.s.Alert != nil {
+		n.s.Alert.Event(alertEvent_StandbyLost, "主节点降级", fmt.Sprintf("%s失去租约：%s，撤销%d个订单", n.lease.Owner(), reason, len(orders)), "strategy", n.s.Name())
+	}
+
+	if lost && !n.cfg.StayOnLost {
+		go n.s.Quit(func(resp string) { logger.LogInfo(n.logPrefix, resp) })
+	}
+}
+
+// 按订单标签查出交易所端本策略的全部挂单并撤销（keep_orphans时只记录）
+// 备节点从未下过单，此时交易所上本策略的挂单都是旧主节点留下的
+func (n *standbyNode) reconcileOrphans() string {
+	sb := strings.Builder{}
+	exs := n.s.cmdExchanges()
+	for _, name := range sortedExchangeNames(exs) {
+		raw := unwrapFencedEx(exs[name])
+		if r, ok := raw.(common.TaggedOrdersReconciler); ok {
+			var orders []common.PendingOrder
+			var err error
+			for i := 0; i < standbyReconcileRetry; i++ {
+				if orders, err = r.PendingTaggedOrders(); err == nil {
+					break
+				}
+				time.Sleep(time.Second)
+			}
+			if err != nil {
+				logger.LogImportant(n.logPrefix, "%s: query pending orders failed: %s", name, err.Error())
+				sb.WriteString(fmt.Sprintf("%s: 查询挂单失败; ", name))
+				continue
+			}
+
+			for _, o := range orders {
+				logger.LogInfo(n.logPrefix, "%s: orphan order %s %s(%s) %v@%v filled=%v", name, o.InstId, o.OrderId, o.ClientOrderId, o.Size, o.Price, o.Filled)
+			}
+			if n.cfg.KeepOrphans || len(orders) == 0 {
+				sb.WriteString(fmt.Sprintf("%s: %d个遗留挂单; ", name, len(orders)))
+				continue
+			}
+
+			failed := 0
+			for i, rst := range r.CancelPendingOrders(orders) {
+				if rst.Err != nil {
+					failed++
+					logger.LogImportant(n.logPrefix, "%s: cancel orphan order %s failed: %s", name, orders[i].OrderId, rst.Err.Error())
+				}
+			}
+			sb.WriteString(fmt.Sprintf("%s: 撤销%d个遗留挂单，失败%d个; ", name, len(orders), failed))
+		} else {
+			// 无法按标签区分订单时保留遗留挂单，不能撤销账户上其他策略的订单
+			logger.LogImportant(n.logPrefix, "%s: can't query tagged orders, orphan orders not reconciled", name)
+			sb.WriteString(fmt.Sprintf("%s: 未处理遗留挂单; ", name))
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "; ")
+}
+
+// #region StrategyBase接口
+
+// 是否主节点。未启用主备模式时始终为true
+func (s *StrategyBase) Active() bool {
+	return s.standby == nil || s.standby.f.isActive()
+}
+
+// 注册成为主节点时的回调，在撤销遗留挂单之后、允许下单之后调用。已经是主节点时立即调用
+func (s *StrategyBase) OnActive(fn func()) {
+	if s.standby == nil {
+		fn()
+		return
+	}
+
+	s.standby.muHooks.Lock()
+	s.standby.onActive = append(s.standby.onActive, fn)
+	s.standby.muHooks.Unlock()
+	if s.standby.f.isActive() {
+		fn()
+	}
+}
+
+// 启用主备模式：交易所换成带fencing的视图
+func (s *StrategyBase) enableStandby() {
+	s.standby = newStandbyNode(s, s.LC.Standby)
+	for name, ex := range s.Exs {
+		fe := newFencedEx(ex, s.standby.f, s.LogPrefix)
+		if s.Ex == ex {
+			s.Ex = fe
+		}
+		s.Exs[name] = fe
+	}
+}
+
+func (s *StrategyBase) registerStandbyCommand() {
+	s.Commands.Register(&terminal.Command{
+		Name: "standby",
+		Help: "show active/standby state",
+		Perm: terminal.Perm_Read,
+		Run: func(c *terminal.CmdCall) {
+			active, token, deadline := s.standby.f.term()
+			owner, holderToken, ok := s.standby.lease.Holder()
+			sb := strings.Builder{}
+			sb.WriteString(fmt.Sprintf("node: %s\nrole: %s\n", s.standby.lease.Owner(), util.ValueIf(active, "active", "standby")))
+			if active {
+				sb.WriteString(fmt.Sprintf("token: %d\nlocal deadline: %s\n", token, deadline.Format(time.RFC3339Nano)))
+			}
+			if ok {
+				sb.WriteString(fmt.Sprintf("holder: %s(token=%d)", owner, holderToken))
+			} else {
+				sb.WriteString("holder: none")
+			}
+			c.Resp("%s", sb.String())
+		},
+	})
+}
+
+// #endregion
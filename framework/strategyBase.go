@@ -8,36 +8,43 @@
 package framework
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"os/signal"
-	"runtime/pprof"
+	"sort"
 	"strings"
-	"syscall"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aztecqt/center_server/csclient"
 	"github.com/aztecqt/center_server/server/activestatus"
 	"github.com/aztecqt/center_server/server/file"
 	"github.com/aztecqt/center_server/server/intel"
-	"github.com/aztecqt/dagger/api"
-	"github.com/aztecqt/dagger/cex/binance"
+	"github.com/aztecqt/dagger/api/proxyprovider"
 	"github.com/aztecqt/dagger/cex/common"
-	"github.com/aztecqt/dagger/cex/okexv5"
 	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/alert"
 	"github.com/aztecqt/dagger/util/apikey"
+	"github.com/aztecqt/dagger/util/crypto"
 	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/metrics"
+	"github.com/aztecqt/dagger/util/network"
+	"github.com/aztecqt/dagger/util/terminal"
 	"github.com/aztecqt/dagger/util/webservice"
+
+	// 注册交易所
+	_ "github.com/aztecqt/dagger/cex/binance"
+	_ "github.com/aztecqt/dagger/cex/ibkrtws"
+	_ "github.com/aztecqt/dagger/cex/okexv5"
 )
 
 type StrategyBase struct {
 	running    bool
 	LC         LaunchConfig
-	Ex         common.CEx
+	Ex         common.CEx            // 主交易所。ex/acc配置的交易所，未配置时为Exs中名称排序第一个
+	Exs        map[string]common.CEx // 所有交易所，名称见LaunchConfig.Exchanges
 	LogPrefix  string
 	errorCount int
 	csAddr     string
@@ -54,11 +61,42 @@ type StrategyBase struct {
 	// web服务
 	WebService *webservice.Service
 
+	// 标准看板，随web服务启动。策略在onStart中注册数据线、成交记录
+	Dashboard *Dashboard
+
+	// 死亡开关。LaunchConfig中未启用时为nil
+	DeadMan *common.DeadManSwitch
+
+	// 告警引擎。策略可以通过Alert.Event发出自定义事件
+	Alert *alert.Engine
+
+	// 命令注册表。策略可以在onStart中注册自己的命令，未注册的命令仍交给onCmd处理
+	Commands *terminal.Commands
+
+	// 主备模式。未启用时为nil
+	standby *standbyNode
+
+	// 命令使用的交易所，为nil时使用Exs
+	exchangesFn func() map[string]common.CEx
+
+	// 退出时的刷新函数
+	flushes  []shutdownFlush
+	muFlush  sync.Mutex
+	quitting atomic.Bool
+
+	// apikey，退出时清零
+	keyReqs []*apikey.Requester
+
+	// 代理池，未启用时为nil
+	proxyPool *proxyprovider.ProxyPool
+
 	// 子类实现
 	onCommand func(cmdLine string, onResp func(string))
 	onQuit    func()
 }
 
+const minDeadManTimeoutSec = 5
+
 func (s *StrategyBase) Start(onStart func(), onQuit func(), onCmd func(cmdLine string, onResp func(string))) {
 	s.onQuit = onQuit
 	s.onCommand = onCmd
@@ -81,44 +119,169 @@ func (s *StrategyBase) Start(onStart func(), onQuit func(), onCmd func(cmdLine s
 	s.LC = lc
 
 	// 初始化Log
-	logger.InitByStr(lc.LogConfig.PeriodConfig)
-	logger.ConsleLogLevel = logger.String2LogLevel(lc.LogConfig.ConsoleLogLevel)
-	logger.FileLogLevel = logger.String2LogLevel(lc.LogConfig.FileLogLevel)
 	s.LogPrefix = fmt.Sprintf("%s.%s", lc.Class, lc.Name)
+	initLogger(lc.LogConfig, s.LogPrefix)
+	s.checkShutdownConfig()
 
-	// 获取apikey
-	kreq := apikey.Requester{}
-	if len(lc.Account) > 0 {
-		logger.LogImportant(s.LogPrefix, "get apikey from server...")
-		kreq.Go(lc.ExchangeName, lc.Account, lc.Name, lc.Key.Share, lc.Key.ServerAddr, lc.Key.ServerPort)
-		logger.LogImportant(s.LogPrefix, "get apikey success")
-	} else {
-		logger.LogImportant(s.LogPrefix, "no need to get apikey")
-	}
+	// 代理池需要在创建交易所之前启动
+	s.proxyPool = startProxyPool(lc.Proxy, s.LogPrefix)
 
 	// 创建交易所对象
-	logger.LogImportant(s.LogPrefix, "starting exchange %s ...", lc.ExchangeName)
-	if strings.ToLower(lc.ExchangeName) == "okex" {
-		okex := new(okexv5.Exchange)
-		okexv5.StratergyName = lc.Name // 用于标识订单归属
-		var excfg *okexv5.ExchangeConfig
-		if lc.ExchangeConfig != nil {
-			excfg = &okexv5.ExchangeConfig{}
-			b, _ := json.Marshal(lc.ExchangeConfig)
-			if json.Unmarshal(b, excfg) != nil {
-				excfg = nil
+	s.Exs = make(map[string]common.CEx)
+	if len(lc.ExchangeName) > 0 {
+		s.Ex = s.openExchange(lc.ExchangeName, lc.ExchangeName, lc.Account, lc.ExchangeConfig)
+	}
+	names := make([]string, 0, len(lc.Exchanges))
+	for name := range lc.Exchanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		elc := lc.Exchanges[name]
+		ex := s.openExchange(name, elc.ExchangeName, elc.Account, elc.Config)
+		if s.Ex == nil {
+			s.Ex = ex
+		}
+	}
+
+	// 死亡开关，首次心跳时启用
+	// 心跳每秒一次，超时时间太短会误触发
+	if lc.DeadManTimeoutSec > 0 && s.Ex != nil {
+		if lc.DeadManTimeoutSec < minDeadManTimeoutSec {
+			logger.LogImportant(s.LogPrefix, "deadman timeout %ds too short, use %ds", lc.DeadManTimeoutSec, minDeadManTimeoutSec)
+			lc.DeadManTimeoutSec = minDeadManTimeoutSec
+		}
+		s.DeadMan = common.NewDeadManSwitch(s.Ex, time.Second*time.Duration(lc.DeadManTimeoutSec))
+	}
+
+	// 主备模式下交易所换成带fencing的视图，死亡开关仍使用原始交易所
+	if lc.Standby.Enabled {
+		s.enableStandby()
+	}
+
+	// 命令行、cs、监控、告警、web等公共服务
+	s.startServices()
+
+	// 竞争租约。成为主节点前会先处理遗留挂单
+	if s.standby != nil {
+		s.registerStandbyCommand()
+		s.standby.start()
+	}
+
+	// 启动策略
+	onStart()
+
+	s.running = true
+	for s.running {
+		s.heartbeat()
+		time.Sleep(time.Second)
+	}
+}
+
+// 初始化日志
+func initLogger(cfg LogConfig, module string) {
+	logOpt := logger.InitOptions{
+		MaxSize:  int64(cfg.MaxSizeMB) * 1024 * 1024,
+		Compress: cfg.Compress,
+	}
+	if cfg.Format == "json" {
+		logOpt.Encoder = logger.JSONEncoder{}
+	}
+	logger.InitByStrWithOptions(cfg.PeriodConfig, logOpt)
+	logger.ConsleLogLevel = logger.String2LogLevel(cfg.ConsoleLogLevel)
+	logger.FileLogLevel = logger.String2LogLevel(cfg.FileLogLevel)
+	for module, lv := range cfg.ModuleLevels {
+		logger.SetModuleLevel(module, logger.String2LogLevel(lv))
+	}
+	logger.SetSlogDefault(module)
+}
+
+// 获取apikey。account为空时不需要key，返回的Requester也是可用的（Key()等为空）
+func requestApiKey(kc KeyConfig, exName, account, user, logPrefix string) *apikey.Requester {
+	kreq := &apikey.Requester{}
+	if len(account) > 0 && len(kc.Keystore) > 0 {
+		logger.LogImportant(logPrefix, "load apikey from keystore...")
+		pass := []byte(os.Getenv(keystorePassEnv))
+		os.Unsetenv(keystorePassEnv)
+		err := kreq.LoadFromKeystore(kc.Keystore, pass, exName, account)
+		crypto.Zero(pass)
+		if err != nil {
+			logger.LogPanic(logPrefix, "load apikey from keystore failed: %s", err.Error())
+		}
+		logger.LogImportant(logPrefix, "load apikey success")
+	} else if len(account) > 0 {
+		logger.LogImportant(logPrefix, "get apikey from server...")
+		if len(kc.ClientKey) > 0 {
+			if err := kreq.SetIdentity(kc.ClientKey, kc.ServerPub); err != nil {
+				logger.LogPanic(logPrefix, "load key server identity failed: %s", err.Error())
+			}
+			kreq.OnKeyRotated = func(k, sec, p string) {
+				logger.LogImportant(logPrefix, "apikey rotated, restart to apply the new key")
 			}
 		}
-		okex.Init(kreq.Key, kreq.Secret, kreq.Password, excfg, s.errorNotifier)
-		s.Ex = okex
-	} else if strings.ToLower(lc.ExchangeName) == "binance" {
-		binance := new(binance.Exchange)
-		binance.Init(kreq.Key, kreq.Secret, s.errorNotifier)
-		s.Ex = binance
+		kreq.Go(exName, account, user, kc.Share, kc.ServerAddr, kc.ServerPort)
+		logger.LogImportant(logPrefix, "get apikey success")
 	} else {
-		logger.LogPanic("unknown exchange: %s", lc.ExchangeName)
+		logger.LogImportant(logPrefix, "no need to get apikey")
+	}
+	return kreq
+}
+
+// 创建交易所对象，交易所需要凭证且account不为空时先获取apikey
+// orderTag用于标识订单归属，proxy为nil时不使用代理池。返回的Requester在不需要凭证时为nil
+func newExchange(exName string, exCfg interface{}, kc KeyConfig, account, user, orderTag string, proxy network.ProxySelector, errNotifier func(error), logPrefix string) (common.CEx, *apikey.Requester) {
+	f, ok := common.GetExchangeFactory(exName)
+	if !ok {
+		logger.LogPanic(logPrefix, "unknown exchange: %s (registered: %s)", exName, strings.Join(common.ExchangeNames(), ","))
 	}
-	logger.LogImportant(s.LogPrefix, "%s started", lc.ExchangeName)
+
+	// 获取apikey
+	var kreq *apikey.Requester
+	cred := common.Credential{}
+	if f.Credential != common.Credential_None {
+		kreq = requestApiKey(kc, exName, account, user, logPrefix)
+		cred.Key = kreq.Key()
+		cred.Secret = kreq.Secret()
+		cred.Password = kreq.Password()
+		cred.KeyType = util.ValueIf(len(kc.Type) > 0, kc.Type, kreq.KeyType)
+		if len(account) > 0 {
+			if err := f.CheckCredential(cred); err != nil {
+				logger.LogPanic(logPrefix, err.Error())
+			}
+		}
+	}
+
+	logger.LogImportant(logPrefix, "starting exchange %s ...", exName)
+	ex, err := common.NewExchange(exName, exCfg, cred, common.ExchangeOptions{OrderTag: orderTag, ErrorNotifier: errNotifier, ProxySelector: proxy})
+	if err != nil {
+		logger.LogPanic(logPrefix, "create exchange %s failed: %s", exName, err.Error())
+	}
+	logger.LogImportant(logPrefix, "%s started", exName)
+	return ex, kreq
+}
+
+// 创建交易所并记录到Exs中
+func (s *StrategyBase) openExchange(name, exName, account string, exCfg interface{}) common.CEx {
+	if _, ok := s.Exs[name]; ok {
+		logger.LogPanic(s.LogPrefix, "duplicated exchange name: %s", name)
+	}
+
+	proxy := proxySelectorOf(s.proxyPool, s.LC.Proxy, account)
+	ex, kreq := newExchange(exName, exCfg, s.LC.Key, account, s.LC.Name, s.LC.Name, proxy, s.errorNotifier, s.LogPrefix)
+	if kreq != nil {
+		s.keyReqs = append(s.keyReqs, kreq)
+	}
+	s.Exs[name] = ex
+	return ex
+}
+
+// 启动命令行、cs组件、监控告警、pprof和web服务
+func (s *StrategyBase) startServices() {
+	lc := s.LC
+
+	// 内置命令
+	s.Commands = terminal.NewCommands()
+	s.registerBuiltinCommands()
 
 	// 启动命令行
 	s.runTerminal()
@@ -135,6 +298,10 @@ func (s *StrategyBase) Start(onStart func(), onQuit func(), onCmd func(cmdLine s
 		lc.Class,
 		s)
 
+	// 监控指标和告警
+	s.registerMetrics()
+	s.startAlert()
+
 	// 启动PProf
 	if lc.PProfPort > 0 {
 		pprofAddr := fmt.Sprintf("localhost:%d", lc.PProfPort)
@@ -150,6 +317,11 @@ func (s *StrategyBase) Start(onStart func(), onQuit func(), onCmd func(cmdLine s
 	if lc.WebServerPort > 0 {
 		s.WebService = &webservice.Service{}
 		s.WebService.Start(lc.WebServerPort)
+		if len(lc.WebAuth.Addr) > 0 {
+			rc := &util.RedisClient{}
+			rc.InitFromConfig(lc.WebAuth)
+			s.WebService.EnableAuth(rc)
+		}
 		s.WebService.RegisterPath("/ping", func(w http.ResponseWriter, r *http.Request) {
 			io.WriteString(w, util.Object2String(
 				map[string]interface{}{
@@ -159,15 +331,23 @@ func (s *StrategyBase) Start(onStart func(), onQuit func(), onCmd func(cmdLine s
 				},
 			))
 		})
+		if len(lc.WebAuth.Addr) > 0 {
+			s.WebService.RegisterPath("/metrics", s.WebService.WithAuth(metrics.Handler()))
+		} else {
+			logger.LogImportant(s.LogPrefix, "web auth not configured, /metrics not mounted")
+		}
+		s.WebService.RegisterPath("/cmd", s.onHttpCmd)
+		if s.Ex != nil {
+			s.Dashboard = newDashboard(s, s.WebService)
+		}
 		logger.LogInfo(s.LogPrefix, "web-service started at port %d", lc.WebServerPort)
 	}
+}
 
-	// 启动策略
-	onStart()
-
-	s.running = true
-	for s.running {
-		time.Sleep(time.Second)
+// 由框架主循环调用，表示进程仍在正常运行。备节点不启用死亡开关
+func (s *StrategyBase) heartbeat() {
+	if s.DeadMan != nil && s.Active() {
+		s.DeadMan.Heartbeat()
 	}
 }
 
@@ -183,28 +363,20 @@ func (s *StrategyBase) runTerminal() {
 	// 本地命令行输入
 	go func() {
 		defer util.DefaultRecover()
-		input := bufio.NewScanner(os.Stdin)
-		for {
-			fmt.Print(">")
-			input.Scan()
-			line := input.Text()
-			s.OnCommand(line, func(resp string) {
-				fmt.Println(resp)
-			})
-		}
+		s.runRepl()
 	}()
 
-	go func() {
-		// 监控两个信号
-		// TERM信号（kill + 进程号 触发）
-		// 中断信号（ctrl + c 触发）
-		osc := make(chan os.Signal, 1)
-		signal.Notify(osc, syscall.SIGTERM, syscall.SIGINT)
-		<-osc
+	// TERM信号（kill + 进程号 触发）、中断信号（ctrl + c 触发）时按退出策略退出
+	// 退出过程中再次收到信号则立即退出
+	go util.OnProgramQuit(func() {
+		go util.OnProgramQuit(func() {
+			logger.LogImportant(s.LogPrefix, "force quit")
+			os.Exit(1)
+		})
 		s.Quit(func(resp string) {
 			fmt.Println(resp)
 		})
-	}()
+	})
 }
 
 // 错误通知
@@ -233,6 +405,10 @@ func (s *StrategyBase) errorNotifier(e error) {
 		}
 		s.IntelClient.SendIntel(it)
 	}
+
+	if s.Alert != nil {
+		s.Alert.Event(alertEvent_ExchangeError, "策略异常", e.Error(), "strategy", s.LC.Name)
+	}
 }
 
 // #region 实现strategy接口
@@ -244,49 +420,31 @@ func (s *StrategyBase) Class() string {
 	return s.LC.Class
 }
 
-// 同时也实现terminal.Terminal接口
+// 同时也实现terminal.Terminal接口。中央服务器发来的命令权限为Cmd.RemoteLevel
 func (s *StrategyBase) OnCommand(cmdLine string, onResp func(string)) {
-	switch cmdLine {
-	case "help":
-		sb := strings.Builder{}
-		sb.WriteString("cs:             print put all call stack\n")
-		sb.WriteString("exit/quit:      stop stratergy and quit\n")
-		sb.WriteString("wslog:          switch websocket log on/off\n")
-		s.onCommand("help", func(resp string) {
-			sb.WriteString("\n")
-			sb.WriteString(resp)
-		})
-		onResp(sb.String())
-	case "cs":
-		pf := pprof.Lookup("goroutine")
-		pf.WriteTo(os.Stdout, 1)
-
-		file, err := os.Create("log/callstack.log")
-		if err == nil {
-			pf.WriteTo(file, 1)
-			file.Close()
-			onResp(fmt.Sprintf("call stack saved to %s\n", file.Name()))
-		} else {
-			onResp("call stack save to file failed")
-		}
-	case "wslog":
-		api.LogWebsocketDetail = !api.LogWebsocketDetail
-		if api.LogWebsocketDetail {
-			onResp("websocket log switch to on")
-		} else {
-			onResp("websocket log switch to off")
-		}
-	case "quit":
-		s.Quit(onResp)
-	default:
-		s.onCommand(cmdLine, onResp)
-	}
+	s.execCommand(cmdLine, permLevelOrDefault(s.LC.Cmd.RemoteLevel, defaultRemoteCmdLevel), true, onResp)
 }
 
+// 按退出策略退出，重复调用时直接返回
 func (s *StrategyBase) Quit(onResp func(string)) {
+	if !s.quitting.CompareAndSwap(false, true) {
+		onResp("strategy is quiting")
+		return
+	}
+
 	onResp("strategy quiting...")
 	s.csClient.OnQuit()
-	s.onQuit()
+	report := s.shutdown()
+	onResp(report.String())
+	if s.standby != nil {
+		s.standby.stop()
+	}
+	if s.Alert != nil {
+		s.Alert.Stop()
+	}
+	for _, kreq := range s.keyReqs {
+		kreq.Quit()
+	}
 	onResp("strategy quited")
 	time.Sleep(time.Second)
 	s.running = false
@@ -21,8 +21,8 @@ require (
 	github.com/russross/blackfriday/v2 v2.1.0
 	github.com/shirou/gopsutil v3.21.11+incompatible
 	github.com/shopspring/decimal v1.3.1
+	golang.org/x/crypto v0.14.0
 	golang.org/x/net v0.17.0
-
 )
 
 require (
@@ -45,7 +45,6 @@ require (
 	github.com/tklauser/go-sysconf v0.3.13 // indirect
 	github.com/tklauser/numcpus v0.7.0 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
-	golang.org/x/crypto v0.14.0 // indirect
 	gopkg.in/ini.v1 v1.42.0 // indirect
 )
 
@@ -63,7 +62,7 @@ require (
 	github.com/onsi/gomega v1.19.0 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
 	golang.org/x/exp v0.0.0-20230817173708-d852ddb80c63
-	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/sys v0.15.0
 	golang.org/x/text v0.13.0 // indirect
 	gonum.org/v1/gonum v0.14.0
 )
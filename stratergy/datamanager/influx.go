@@ -100,6 +100,7 @@ type Influx struct {
 	dgToSave        map[string]*dataGroup
 	dgToClearPoints *hashset.Set
 	dgMu            sync.Mutex
+	writeMu         sync.Mutex // 定时写入和Flush互斥
 }
 
 var influxIns *Influx = nil
@@ -133,10 +134,16 @@ func (m *Influx) Start() {
 	go m.update()
 }
 
+// 写入剩余数据后停止
 func (m *Influx) Stop() {
 	m.chStop <- 0
 }
 
+// 立即写入缓存的数据，退出前调用以免丢失最后一段数据
+func (m *Influx) Flush() {
+	m.writeInflux()
+}
+
 func (m *Influx) connected() bool {
 	return m.ifdbConn != nil
 }
@@ -225,7 +232,9 @@ func (i *Influx) update() {
 		case <-ticker.C:
 			i.writeInflux()
 		case <-i.chStop:
+			ticker.Stop()
 			i.writeInflux()
+			return
 		}
 	}
 }
@@ -249,6 +258,9 @@ func (i *Influx) checkConnection() bool {
 
 // 将缓存的成交数据，和point数据，都写入influx中
 func (i *Influx) writeInflux() {
+	i.writeMu.Lock()
+	defer i.writeMu.Unlock()
+
 	if !i.checkConnection() {
 		return
 	}
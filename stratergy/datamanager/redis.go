@@ -62,10 +62,17 @@ func (m *Redis) Start() {
 	go m.update()
 }
 
+// 写入最新状态后停止
 func (m *Redis) Stop() {
 	m.chStop <- 0
 }
 
+// 立即写入最新状态，退出前调用
+func (m *Redis) Flush() {
+	m.writeBrief()
+	m.writeDetail()
+}
+
 func (m *Redis) AddExchange(ex common.CEx) {
 	m.exchanges = append(m.exchanges, ex)
 }
@@ -89,8 +96,9 @@ func (m *Redis) update() {
 			}
 
 		case <-m.chStop:
-			m.writeBrief()
-			m.writeDetail()
+			ticker.Stop()
+			m.Flush()
+			return
 		}
 	}
 }
@@ -15,10 +15,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/signal"
 	"runtime/pprof"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/aztecqt/dagger/api"
@@ -45,14 +43,11 @@ func (t *Terminal) Run(s Stratergy) {
 		}
 	}()
 
-	// 监控两个信号
-	// TERM信号（kill + 进程号 触发）
-	// 中断信号（ctrl + c 触发）
-	osc := make(chan os.Signal, 1)
-	signal.Notify(osc, syscall.SIGTERM, syscall.SIGINT)
-	<-osc
-	quit(s, func(resp string) {
-		fmt.Println(resp)
+	// 阻塞直到收到TERM/中断信号
+	util.OnProgramQuit(func() {
+		quit(s, func(resp string) {
+			fmt.Println(resp)
+		})
 	})
 }
 
@@ -0,0 +1,158 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 04:55:02
+ * @Description: 告警通知渠道：钉钉工作消息、webhook(slack/telegram兼容的json)、smtp邮件，以及用函数实现的自定义渠道
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/dingtalk"
+	"github.com/aztecqt/dagger/util/network"
+)
+
+type Channel interface {
+	Send(a *Alert, kind NotifyKind) error
+}
+
+// 用函数实现的渠道
+type ChannelFunc func(a *Alert, kind NotifyKind) error
+
+func (f ChannelFunc) Send(a *Alert, kind NotifyKind) error {
+	return f(a, kind)
+}
+
+// 根据配置创建渠道
+func NewChannel(cfg ChannelConfig) (Channel, error) {
+	switch cfg.Type {
+	case "dingtalk":
+		n := &dingtalk.Notifier{}
+		n.Init(dingtalk.NotifierConfig{Name: cfg.DingName, AgentId: cfg.DingAgentId, Key: cfg.DingKey, Secret: cfg.DingSecret})
+		return NewDingTalkChannel(n, cfg.DingMobiles...), nil
+	case "webhook":
+		if len(cfg.Url) == 0 {
+			return nil, errors.New("webhook url is empty")
+		}
+		return &WebhookChannel{Url: cfg.Url, Format: cfg.Format, ChatId: cfg.ChatId}, nil
+	case "email":
+		if len(cfg.SmtpHost) == 0 || len(cfg.To) == 0 {
+			return nil, errors.New("smtp host or recipients is empty")
+		}
+		return &EmailChannel{Host: cfg.SmtpHost, Port: cfg.SmtpPort, User: cfg.SmtpUser, Password: cfg.SmtpPass, From: cfg.From, To: cfg.To}, nil
+	default:
+		return nil, fmt.Errorf("unknown channel type: %s", cfg.Type)
+	}
+}
+
+// #region 钉钉
+type DingTalkChannel struct {
+	n    *dingtalk.Notifier
+	mobs []int64
+}
+
+func NewDingTalkChannel(n *dingtalk.Notifier, mobs ...int64) *DingTalkChannel {
+	return &DingTalkChannel{n: n, mobs: mobs}
+}
+
+func (c *DingTalkChannel) Send(a *Alert, kind NotifyKind) error {
+	if c.n.SendTextByMob(a.Text(kind), c.mobs...) == nil {
+		return errors.New("no dingtalk user found")
+	}
+	return nil
+}
+
+// #endregion
+
+// #region webhook
+type WebhookChannel struct {
+	Url    string
+	Format string // slack:{"text":...}，telegram:{"chat_id":...,"text":...}，其他：告警的完整json
+	ChatId string
+}
+
+func (c *WebhookChannel) Send(a *Alert, kind NotifyKind) error {
+	var body interface{}
+	switch c.Format {
+	case "slack":
+		body = map[string]string{"text": a.Text(kind)}
+	case "telegram":
+		body = map[string]string{"chat_id": c.ChatId, "text": a.Text(kind)}
+	default:
+		body = map[string]interface{}{
+			"kind":     NotifyKind2String(kind),
+			"id":       a.Id,
+			"rule":     a.Rule,
+			"severity": Severity2String(a.Severity),
+			"title":    a.Title,
+			"content":  a.Content,
+			"labels":   a.Labels,
+			"value":    a.Value,
+			"since":    a.FirstTime,
+			"count":    a.Count,
+			"acked":    a.Acked,
+		}
+	}
+
+	var err error
+	network.HttpCall(c.Url, "POST", util.Object2StringWithoutIntent(body), network.JsonHeaders(), func(resp *http.Response, e error) {
+		if e != nil {
+			err = e
+		} else if resp.StatusCode/100 != 2 {
+			err = fmt.Errorf("webhook responsed %s", resp.Status)
+		}
+	})
+	return err
+}
+
+// #endregion
+
+// #region 邮件
+type EmailChannel struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	From     string
+	To       []string
+}
+
+func (c *EmailChannel) Send(a *Alert, kind NotifyKind) error {
+	port := c.Port
+	if port == 0 {
+		port = 587
+	}
+	from := c.From
+	if len(from) == 0 {
+		from = c.User
+	}
+
+	subject := fmt.Sprintf("[%s][%s] %s", strings.ToUpper(NotifyKind2String(kind)), Severity2String(a.Severity), a.Title)
+	msg := strings.Builder{}
+	msg.WriteString("From: " + from + "\r\n")
+	msg.WriteString("To: " + strings.Join(c.To, ",") + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(a.Text(kind), "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if len(c.User) > 0 {
+		auth = smtp.PlainAuth("", c.User, c.Password, c.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(c.Host, strconv.Itoa(port)), auth, from, c.To, []byte(msg.String()))
+}
+
+// #endregion
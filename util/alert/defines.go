@@ -0,0 +1,189 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 04:40:18
+ * @Description: 告警的基础定义：严重程度、告警、配置
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 严重程度
+type Severity int
+
+const (
+	Severity_Info Severity = iota
+	Severity_Warning
+	Severity_Critical
+)
+
+func Severity2String(s Severity) string {
+	switch s {
+	case Severity_Info:
+		return "info"
+	case Severity_Warning:
+		return "warning"
+	case Severity_Critical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+func String2Severity(str string) Severity {
+	switch strings.ToLower(str) {
+	case "warning":
+		return Severity_Warning
+	case "critical":
+		return Severity_Critical
+	default:
+		return Severity_Info
+	}
+}
+
+// 通知的类型
+type NotifyKind int
+
+const (
+	NotifyKind_Firing    NotifyKind = iota // 首次触发或重复提醒
+	NotifyKind_Escalated                   // 超时未确认，已升级
+	NotifyKind_Resolved                    // 已恢复
+)
+
+func NotifyKind2String(k NotifyKind) string {
+	switch k {
+	case NotifyKind_Firing:
+		return "firing"
+	case NotifyKind_Escalated:
+		return "escalated"
+	case NotifyKind_Resolved:
+		return "resolved"
+	default:
+		return "unknown"
+	}
+}
+
+// 一条告警。同一规则、同一组标签只有一条活跃的告警
+type Alert struct {
+	Id         int // 自增编号，用于命令行确认
+	Key        string
+	Rule       string
+	Severity   Severity
+	Labels     map[string]string
+	Title      string
+	Content    string
+	Value      float64 // 触发时的指标值，事件告警为0
+	FirstTime  time.Time
+	LastTime   time.Time // 最近一次满足条件（或收到事件）的时间
+	NotifyTime time.Time // 最近一次发出通知的时间
+	Count      int       // 满足条件（或收到事件）的次数
+	Acked      bool
+	AckBy      string
+	AckTime    time.Time
+	Escalated  bool
+	Suppressed int // 被限流丢弃的通知数
+}
+
+func (a *Alert) String() string {
+	s := fmt.Sprintf("#%d [%s] %s", a.Id, Severity2String(a.Severity), a.Title)
+	if a.Acked {
+		s += fmt.Sprintf(" (acked by %s)", a.AckBy)
+	}
+	return s
+}
+
+// 通知正文
+func (a *Alert) Text(kind NotifyKind) string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("[%s][%s] %s\n", strings.ToUpper(NotifyKind2String(kind)), Severity2String(a.Severity), a.Title))
+	if len(a.Content) > 0 {
+		sb.WriteString(a.Content)
+		sb.WriteString("\n")
+	}
+	if len(a.Labels) > 0 {
+		sb.WriteString(formatLabels(a.Labels))
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("since %s, count=%d, id=%d", a.FirstTime.Format(time.DateTime), a.Count, a.Id))
+	if a.Suppressed > 0 {
+		sb.WriteString(fmt.Sprintf(", %d notifications suppressed", a.Suppressed))
+	}
+	return sb.String()
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, k+"="+labels[k])
+	}
+	return strings.Join(kvs, " ")
+}
+
+// #region 配置
+type Config struct {
+	IntervalSec     int             `json:"interval_sec"`       // 规则计算间隔，默认10秒
+	RateLimitPerMin int             `json:"rate_limit_per_min"` // 每个通知渠道每分钟最多发送的条数，0表示不限
+	Channels        []ChannelConfig `json:"channels"`
+	Rules           []RuleConfig    `json:"rules"`
+}
+
+// 规则。Type为metric时按指标计算，为event时匹配Engine.Event发出的同名事件
+type RuleConfig struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`     // metric/event
+	Metric   string            `json:"metric"`   // 指标名，或事件名
+	Labels   map[string]string `json:"labels"`   // 只计算标签匹配的序列
+	Op       string            `json:"op"`       // >, >=, <, <=, ==, !=, age>(值为unix秒，距今超过Value秒), flip(正负号翻转)
+	Abs      bool              `json:"abs"`      // 比较前取绝对值
+	Value    float64           `json:"value"`    //
+	ForSec   int               `json:"for_sec"`  // 条件需要持续多久才触发
+	Severity string            `json:"severity"` // info/warning/critical
+	Title    string            `json:"title"`    // 为空时使用规则名。可用{{label}}引用标签，{{value}}引用值
+	Content  string            `json:"content"`  // 同上
+
+	Channels    []string `json:"channels"`     // 通知渠道，为空时发往所有渠道
+	RepeatSec   int      `json:"repeat_sec"`   // 未确认时重复提醒的间隔，0表示不重复
+	EscalateSec int      `json:"escalate_sec"` // 超过这个时间未确认则升级（严重程度+1，并通知EscalateTo），0表示不升级
+	EscalateTo  []string `json:"escalate_to"`  // 升级后追加的通知渠道
+	DedupSec    int      `json:"dedup_sec"`    // 事件告警：同一事件在这个时间内只通知一次，之后视为新的告警。默认300秒
+}
+
+type ChannelConfig struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`         // dingtalk/webhook/email
+	MinSeverity string `json:"min_severity"` // 低于此严重程度的告警不发往这个渠道
+
+	// dingtalk
+	DingName    string  `json:"ding_name"`
+	DingAgentId int64   `json:"ding_agent_id"`
+	DingKey     string  `json:"ding_key"`
+	DingSecret  string  `json:"ding_secret"`
+	DingMobiles []int64 `json:"ding_mobiles"`
+
+	// webhook
+	Url    string `json:"url"`
+	Format string `json:"format"`  // slack/telegram/json
+	ChatId string `json:"chat_id"` // telegram
+
+	// email
+	SmtpHost string   `json:"smtp_host"`
+	SmtpPort int      `json:"smtp_port"`
+	SmtpUser string   `json:"smtp_user"`
+	SmtpPass string   `json:"smtp_pass"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// #endregion
@@ -0,0 +1,529 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 05:08:44
+ * @Description: 告警引擎。定时按规则计算metrics中的指标，也接收程序主动发出的事件
+ * 同一规则、同一组标签只保留一条活跃告警（去重）。指标告警在条件不再满足时恢复并通知；事件告警在DedupSec内没有新事件时自动结束
+ * 未确认的告警可以按RepeatSec重复提醒，超过EscalateSec升级。每个渠道有独立的限流，被限流的通知计入Suppressed，在下一次通知中提示
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/metrics"
+)
+
+const logPrefix = "alert"
+const defaultDedupSec = 300
+
+type rule struct {
+	cfg       RuleConfig
+	severity  Severity
+	pending   map[string]time.Time // 序列key->开始满足条件的时间
+	lastValue map[string]float64   // flip用
+}
+
+type channel struct {
+	c           Channel
+	minSeverity Severity
+	sent        []time.Time // 最近一分钟的发送时间，用于限流
+}
+
+type Engine struct {
+	registry        *metrics.Registry
+	rateLimitPerMin int
+	rules           []*rule
+	channels        map[string]*channel
+	active          map[string] /*key*/ *Alert
+	nextId          int
+	chStop          chan int
+	mu              sync.Mutex
+}
+
+func NewEngine(registry *metrics.Registry) *Engine {
+	if registry == nil {
+		registry = metrics.Default
+	}
+	return &Engine{
+		registry: registry,
+		channels: make(map[string]*channel),
+		active:   make(map[string]*Alert),
+	}
+}
+
+// 按配置创建渠道和规则，然后启动
+func (e *Engine) Load(cfg Config) error {
+	e.SetRateLimit(cfg.RateLimitPerMin)
+	for _, cc := range cfg.Channels {
+		c, err := NewChannel(cc)
+		if err != nil {
+			return fmt.Errorf("channel %s: %s", cc.Name, err.Error())
+		}
+		e.AddChannel(cc.Name, c, String2Severity(cc.MinSeverity))
+	}
+
+	for _, rc := range cfg.Rules {
+		if err := e.AddRule(rc); err != nil {
+			return fmt.Errorf("rule %s: %s", rc.Name, err.Error())
+		}
+	}
+
+	interval := time.Second * 10
+	if cfg.IntervalSec > 0 {
+		interval = time.Second * time.Duration(cfg.IntervalSec)
+	}
+	e.Start(interval)
+	return nil
+}
+
+// 每个渠道每分钟最多发送的条数，0表示不限
+func (e *Engine) SetRateLimit(perMin int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rateLimitPerMin = perMin
+}
+
+func (e *Engine) AddChannel(name string, c Channel, minSeverity Severity) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.channels[name] = &channel{c: c, minSeverity: minSeverity}
+}
+
+func (e *Engine) AddRule(cfg RuleConfig) error {
+	if len(cfg.Name) == 0 || len(cfg.Metric) == 0 {
+		return errors.New("name and metric are required")
+	}
+
+	switch cfg.Type {
+	case "metric":
+		switch cfg.Op {
+		case ">", ">=", "<", "<=", "==", "!=", "age>", "flip":
+		default:
+			return fmt.Errorf("invalid op: %s", cfg.Op)
+		}
+	case "event":
+	default:
+		return fmt.Errorf("invalid type: %s", cfg.Type)
+	}
+
+	if cfg.DedupSec <= 0 {
+		cfg.DedupSec = defaultDedupSec
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = append(e.rules, &rule{
+		cfg:       cfg,
+		severity:  String2Severity(cfg.Severity),
+		pending:   make(map[string]time.Time),
+		lastValue: make(map[string]float64),
+	})
+	return nil
+}
+
+func (e *Engine) Start(interval time.Duration) {
+	e.chStop = make(chan int)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.evaluate(time.Now())
+			case <-e.chStop:
+				return
+			}
+		}
+	}()
+	logger.LogImportant(logPrefix, "started, %d rules, %d channels", len(e.rules), len(e.channels))
+}
+
+func (e *Engine) Stop() {
+	if e.chStop != nil {
+		close(e.chStop)
+		e.chStop = nil
+	}
+}
+
+// 发出一个事件，匹配Type为event、Metric为name的规则。labels为成对的标签名和标签值
+func (e *Engine) Event(name, title, content string, labels ...string) {
+	lm := make(map[string]string)
+	for i := 0; i+1 < len(labels); i += 2 {
+		lm[labels[i]] = labels[i+1]
+	}
+
+	e.event(name, title, content, lm, time.Now())
+}
+
+func (e *Engine) event(name, title, content string, labels map[string]string, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.rules {
+		if r.cfg.Type == "event" && r.cfg.Metric == name && matchLabels(r.cfg.Labels, labels) {
+			e.fireEvent(r, labels, 0, title, content, now)
+		}
+	}
+}
+
+// 确认告警，之后不再重复提醒和升级
+func (e *Engine) Ack(id int, by string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, a := range e.active {
+		if a.Id == id {
+			a.Acked, a.AckBy, a.AckTime = true, by, time.Now()
+			logger.LogImportant(logPrefix, "alert %d acked by %s", id, by)
+			return true
+		}
+	}
+	return false
+}
+
+// 当前活跃的告警（拷贝），按编号排序
+func (e *Engine) Active() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	as := make([]Alert, 0, len(e.active))
+	for _, a := range e.active {
+		as = append(as, *a)
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].Id < as[j].Id })
+	return as
+}
+
+// 处理命令行：alert 列出活跃告警；alert ack <id> 确认告警
+func (e *Engine) OnCommand(args []string, by string) string {
+	if len(args) == 0 {
+		as := e.Active()
+		if len(as) == 0 {
+			return "no active alert"
+		}
+		sb := strings.Builder{}
+		for _, a := range as {
+			sb.WriteString(a.String())
+			sb.WriteString("\n")
+		}
+		return sb.String()
+	} else if len(args) == 2 && args[0] == "ack" {
+		if id, err := strconv.Atoi(args[1]); err == nil && e.Ack(id, by) {
+			return fmt.Sprintf("alert %d acked", id)
+		} else {
+			return "alert not found: " + args[1]
+		}
+	} else {
+		return "usage: alert [ack <id>]"
+	}
+}
+
+// #region 计算
+func (e *Engine) evaluate(now time.Time) {
+	samples := e.registry.Gather()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, r := range e.rules {
+		if r.cfg.Type == "metric" {
+			e.evaluateRule(r, samples, now)
+		}
+	}
+
+	for key, a := range e.active {
+		r := e.findRule(a.Rule)
+		if r == nil {
+			delete(e.active, key)
+			continue
+		}
+
+		// 事件告警自动结束
+		if (r.cfg.Type == "event" || r.cfg.Op == "flip") && now.Sub(a.LastTime) >= time.Second*time.Duration(r.cfg.DedupSec) {
+			delete(e.active, key)
+			continue
+		}
+
+		if a.Acked {
+			continue
+		}
+
+		if r.cfg.EscalateSec > 0 && !a.Escalated && now.Sub(a.FirstTime) >= time.Second*time.Duration(r.cfg.EscalateSec) {
+			a.Escalated = true
+			a.Severity = min(a.Severity+1, Severity_Critical)
+			logger.LogImportant(logPrefix, "alert escalated: %s", a.String())
+			e.notify(a, NotifyKind_Escalated, append(append([]string{}, r.cfg.Channels...), r.cfg.EscalateTo...), now)
+		} else if r.cfg.RepeatSec > 0 && now.Sub(a.NotifyTime) >= time.Second*time.Duration(r.cfg.RepeatSec) {
+			e.notify(a, NotifyKind_Firing, e.channelsOf(r, a), now)
+		}
+	}
+}
+
+func (e *Engine) evaluateRule(r *rule, samples []metrics.Sample, now time.Time) {
+	seen := make(map[string]bool)
+	for _, s := range samples {
+		if s.Name != r.cfg.Metric || !matchLabels(r.cfg.Labels, s.Labels) {
+			continue
+		}
+
+		v := s.Value
+		if r.cfg.Abs {
+			v = math.Abs(v)
+		}
+
+		key := alertKey(r.cfg.Name, s.Labels)
+		seen[key] = true
+
+		if r.cfg.Op == "flip" {
+			prev, ok := r.lastValue[key]
+			r.lastValue[key] = v
+			if ok && prev*v < 0 {
+				e.fireEvent(r, s.Labels, v, "", "", now)
+			}
+			continue
+		}
+
+		if r.check(v, now) {
+			t0, ok := r.pending[key]
+			if !ok {
+				t0 = now
+				r.pending[key] = now
+			}
+			if now.Sub(t0) >= time.Second*time.Duration(r.cfg.ForSec) {
+				e.fireMetric(r, key, s.Labels, v, now)
+			}
+		} else {
+			delete(r.pending, key)
+			e.resolve(key, now)
+		}
+	}
+
+	// 序列消失，视为恢复
+	for key := range r.pending {
+		if !seen[key] {
+			delete(r.pending, key)
+			e.resolve(key, now)
+		}
+	}
+	for key := range r.lastValue {
+		if !seen[key] {
+			delete(r.lastValue, key)
+		}
+	}
+}
+
+func (r *rule) check(v float64, now time.Time) bool {
+	switch r.cfg.Op {
+	case ">":
+		return v > r.cfg.Value
+	case ">=":
+		return v >= r.cfg.Value
+	case "<":
+		return v < r.cfg.Value
+	case "<=":
+		return v <= r.cfg.Value
+	case "==":
+		return v == r.cfg.Value
+	case "!=":
+		return v != r.cfg.Value
+	case "age>":
+		return float64(now.Unix())-v > r.cfg.Value
+	default:
+		return false
+	}
+}
+
+func (e *Engine) findRule(name string) *rule {
+	for _, r := range e.rules {
+		if r.cfg.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func (e *Engine) fireMetric(r *rule, key string, labels map[string]string, v float64, now time.Time) {
+	a, ok := e.active[key]
+	if ok {
+		a.Value, a.LastTime = v, now
+		a.Count++
+		return
+	}
+
+	a = e.newAlert(r, key, labels, v, "", "", now)
+	logger.LogImportant(logPrefix, "alert firing: %s", a.String())
+	e.notify(a, NotifyKind_Firing, e.channelsOf(r, a), now)
+}
+
+func (e *Engine) fireEvent(r *rule, labels map[string]string, v float64, title, content string, now time.Time) {
+	key := alertKey(r.cfg.Name, labels)
+	if a, ok := e.active[key]; ok && now.Sub(a.FirstTime) < time.Second*time.Duration(r.cfg.DedupSec) {
+		a.Value, a.LastTime = v, now
+		a.Count++
+		if len(content) > 0 {
+			a.Content = content
+		}
+		return
+	}
+
+	a := e.newAlert(r, key, labels, v, title, content, now)
+	logger.LogImportant(logPrefix, "alert firing: %s", a.String())
+	e.notify(a, NotifyKind_Firing, e.channelsOf(r, a), now)
+}
+
+func (e *Engine) newAlert(r *rule, key string, labels map[string]string, v float64, title, content string, now time.Time) *Alert {
+	if len(title) == 0 {
+		title = r.cfg.Title
+		if len(title) == 0 {
+			title = r.cfg.Name
+		}
+	}
+	if len(content) == 0 {
+		content = r.cfg.Content
+	}
+
+	e.nextId++
+	a := &Alert{
+		Id:        e.nextId,
+		Key:       key,
+		Rule:      r.cfg.Name,
+		Severity:  r.severity,
+		Labels:    labels,
+		Title:     render(title, labels, v),
+		Content:   render(content, labels, v),
+		Value:     v,
+		FirstTime: now,
+		LastTime:  now,
+		Count:     1,
+	}
+	e.active[key] = a
+	return a
+}
+
+func (e *Engine) resolve(key string, now time.Time) {
+	a, ok := e.active[key]
+	if !ok {
+		return
+	}
+
+	delete(e.active, key)
+	logger.LogImportant(logPrefix, "alert resolved: %s", a.String())
+	if r := e.findRule(a.Rule); r != nil {
+		e.notify(a, NotifyKind_Resolved, e.channelsOf(r, a), now)
+	}
+}
+
+// 升级后的告警，后续通知也发往升级渠道
+func (e *Engine) channelsOf(r *rule, a *Alert) []string {
+	if a.Escalated && len(r.cfg.EscalateTo) > 0 {
+		return append(append([]string{}, r.cfg.Channels...), r.cfg.EscalateTo...)
+	}
+	return r.cfg.Channels
+}
+
+// 发送通知，names为空时发往所有渠道。调用方持有锁
+func (e *Engine) notify(a *Alert, kind NotifyKind, names []string, now time.Time) {
+	if len(names) == 0 {
+		for name := range e.channels {
+			names = append(names, name)
+		}
+	}
+
+	a.NotifyTime = now
+	sentAny := false
+	for _, name := range uniq(names) {
+		ch, ok := e.channels[name]
+		if !ok {
+			logger.LogImportant(logPrefix, "channel %s not found", name)
+			continue
+		}
+		if a.Severity < ch.minSeverity {
+			continue
+		}
+
+		if !ch.allow(now, e.rateLimitPerMin) {
+			a.Suppressed++
+			logger.LogInfo(logPrefix, "channel %s rate limited, alert %d suppressed", name, a.Id)
+			continue
+		}
+
+		ac := *a
+		sentAny = true
+		go func(name string, c Channel) {
+			if err := c.Send(&ac, kind); err != nil {
+				logger.LogImportant(logPrefix, "send alert %d to %s failed: %s", ac.Id, name, err.Error())
+			}
+		}(name, ch.c)
+	}
+
+	if sentAny {
+		a.Suppressed = 0
+	}
+}
+
+func (ch *channel) allow(now time.Time, perMin int) bool {
+	if perMin <= 0 {
+		return true
+	}
+
+	i := 0
+	for i < len(ch.sent) && now.Sub(ch.sent[i]) >= time.Minute {
+		i++
+	}
+	ch.sent = ch.sent[i:]
+
+	if len(ch.sent) >= perMin {
+		return false
+	}
+	ch.sent = append(ch.sent, now)
+	return true
+}
+
+// #endregion
+
+// #region 工具
+func alertKey(rule string, labels map[string]string) string {
+	return rule + "|" + formatLabels(labels)
+}
+
+func matchLabels(filter, labels map[string]string) bool {
+	for k, v := range filter {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// 替换{{label}}和{{value}}
+func render(tmpl string, labels map[string]string, v float64) string {
+	if !strings.Contains(tmpl, "{{") {
+		return tmpl
+	}
+
+	kvs := []string{"{{value}}", strconv.FormatFloat(v, 'f', -1, 64)}
+	for k, lv := range labels {
+		kvs = append(kvs, "{{"+k+"}}", lv)
+	}
+	return strings.NewReplacer(kvs...).Replace(tmpl)
+}
+
+func uniq(ss []string) []string {
+	seen := make(map[string]bool)
+	out := make([]string, 0, len(ss))
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// #endregion
@@ -0,0 +1,318 @@
+package alert
+
+// 用记录型渠道代替真实渠道，以固定的时间驱动引擎，检查去重、限流和升级
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/metrics"
+)
+
+func TestMain(m *testing.M) {
+	logger.Setup(logger.NewStdoutSink(logger.ConsoleEncoder{}, logger.LogLevel_Important))
+	os.Exit(m.Run())
+}
+
+var t0 = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// 记录发出的通知，格式为"秒数 渠道 类型 严重程度"
+type recorder struct {
+	mu   sync.Mutex
+	sent []string
+	last map[string]Alert // 渠道->最近收到的告警
+}
+
+func (r *recorder) channel(name string) Channel {
+	return ChannelFunc(func(a *Alert, kind NotifyKind) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.sent = append(r.sent, fmt.Sprintf("%d %s %s %s", int(a.NotifyTime.Sub(t0).Seconds()), name, NotifyKind2String(kind), Severity2String(a.Severity)))
+		if r.last == nil {
+			r.last = make(map[string]Alert)
+		}
+		r.last[name] = *a
+		return nil
+	})
+}
+
+// 通知是异步发出的，等待收到n条，再稍等确认没有多余的
+func (r *recorder) wait(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		r.mu.Lock()
+		got := len(r.sent)
+		r.mu.Unlock()
+		if got >= n || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(time.Millisecond * 20)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sent := append([]string{}, r.sent...)
+	sort.Strings(sent)
+	return sent
+}
+
+func TestMetricRule(t *testing.T) {
+	type step struct {
+		sec   int
+		value float64 // NaN表示序列消失
+	}
+
+	cases := []struct {
+		name  string
+		rule  RuleConfig
+		steps []step
+		want  []string
+		count int // 最终活跃告警的Count，0表示没有活跃告警
+	}{
+		{
+			name:  "fires once while condition holds",
+			steps: []step{{0, 2}, {10, 2}, {20, 2}},
+			want:  []string{"0 main firing warning"},
+			count: 3,
+		},
+		{
+			name:  "for_sec delays firing",
+			rule:  RuleConfig{ForSec: 15},
+			steps: []step{{0, 2}, {10, 2}, {20, 2}},
+			want:  []string{"20 main firing warning"},
+			count: 1,
+		},
+		{
+			name:  "for_sec restarts after condition breaks",
+			rule:  RuleConfig{ForSec: 15},
+			steps: []step{{0, 2}, {10, 0}, {20, 2}, {30, 2}},
+		},
+		{
+			name:  "resolved when condition breaks",
+			steps: []step{{0, 2}, {10, 0}},
+			want:  []string{"0 main firing warning", "10 main resolved warning"},
+		},
+		{
+			name:  "resolved when series disappears",
+			steps: []step{{0, 2}, {10, math.NaN()}},
+			want:  []string{"0 main firing warning", "10 main resolved warning"},
+		},
+		{
+			name:  "repeat while unacked",
+			rule:  RuleConfig{RepeatSec: 30},
+			steps: []step{{0, 2}, {20, 2}, {40, 2}},
+			want:  []string{"0 main firing warning", "40 main firing warning"},
+			count: 3,
+		},
+		{
+			// 升级前oncall不接收warning，升级后收到critical
+			name:  "escalate once to extra channel",
+			rule:  RuleConfig{EscalateSec: 30, EscalateTo: []string{"oncall"}},
+			steps: []step{{0, 2}, {40, 2}, {80, 2}},
+			want:  []string{"0 main firing warning", "40 main escalated critical", "40 oncall escalated critical"},
+			count: 3,
+		},
+		{
+			name:  "repeat after escalation goes to extra channel",
+			rule:  RuleConfig{EscalateSec: 30, EscalateTo: []string{"oncall"}, RepeatSec: 50},
+			steps: []step{{0, 2}, {40, 2}, {90, 2}},
+			want: []string{
+				"0 main firing warning",
+				"40 main escalated critical", "40 oncall escalated critical",
+				"90 main firing critical", "90 oncall firing critical",
+			},
+			count: 3,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			reg := metrics.NewRegistry()
+			g := reg.NewGauge("lag", "", "inst")
+			e := NewEngine(reg)
+			rec := &recorder{}
+			e.AddChannel("main", rec.channel("main"), Severity_Info)
+			e.AddChannel("oncall", rec.channel("oncall"), Severity_Critical)
+
+			r := c.rule
+			r.Name, r.Type, r.Metric, r.Op, r.Value, r.Severity = "lag", "metric", "lag", ">", 1, "warning"
+			r.Channels = []string{"main"}
+			if err := e.AddRule(r); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, s := range c.steps {
+				if math.IsNaN(s.value) {
+					g.Delete("x")
+				} else {
+					g.With("x").Set(s.value)
+				}
+				e.evaluate(t0.Add(time.Second * time.Duration(s.sec)))
+			}
+
+			if got := rec.wait(t, len(c.want)); fmt.Sprint(got) != fmt.Sprint(c.want) {
+				t.Errorf("sent %q, want %q", got, c.want)
+			}
+
+			as := e.Active()
+			if c.count == 0 && len(as) != 0 {
+				t.Errorf("active %v, want none", as)
+			} else if c.count > 0 && (len(as) != 1 || as[0].Count != c.count) {
+				t.Errorf("active %v, want one alert with count %d", as, c.count)
+			}
+		})
+	}
+}
+
+func TestEventDedup(t *testing.T) {
+	type event struct {
+		sec  int
+		inst string
+	}
+
+	cases := []struct {
+		name   string
+		events []event
+		evalAt int // 最后一次计算的时间，0表示不计算
+		want   []string
+		active []int // 活跃告警的Count
+	}{
+		{
+			name:   "same event within dedup window",
+			events: []event{{0, "a"}, {10, "a"}, {50, "a"}},
+			want:   []string{"0 main firing warning"},
+			active: []int{3},
+		},
+		{
+			name:   "new alert after dedup window",
+			events: []event{{0, "a"}, {70, "a"}},
+			want:   []string{"0 main firing warning", "70 main firing warning"},
+			active: []int{1},
+		},
+		{
+			name:   "different labels are separate alerts",
+			events: []event{{0, "a"}, {10, "b"}, {20, "a"}},
+			want:   []string{"0 main firing warning", "10 main firing warning"},
+			active: []int{2, 1},
+		},
+		{
+			name:   "ends silently without new events",
+			events: []event{{0, "a"}, {30, "a"}},
+			evalAt: 90,
+			want:   []string{"0 main firing warning"},
+		},
+		{
+			name:   "kept while events keep coming",
+			events: []event{{0, "a"}, {30, "a"}},
+			evalAt: 80,
+			want:   []string{"0 main firing warning"},
+			active: []int{2},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := NewEngine(metrics.NewRegistry())
+			rec := &recorder{}
+			e.AddChannel("main", rec.channel("main"), Severity_Info)
+			err := e.AddRule(RuleConfig{Name: "reject", Type: "event", Metric: "reject", Severity: "warning", DedupSec: 60})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, ev := range c.events {
+				e.event("reject", "", "", map[string]string{"inst": ev.inst}, t0.Add(time.Second*time.Duration(ev.sec)))
+			}
+			if c.evalAt > 0 {
+				e.evaluate(t0.Add(time.Second * time.Duration(c.evalAt)))
+			}
+
+			if got := rec.wait(t, len(c.want)); fmt.Sprint(got) != fmt.Sprint(c.want) {
+				t.Errorf("sent %q, want %q", got, c.want)
+			}
+
+			counts := []int{}
+			for _, a := range e.Active() {
+				counts = append(counts, a.Count)
+			}
+			if fmt.Sprint(counts) != fmt.Sprint(c.active) && !(len(counts) == 0 && len(c.active) == 0) {
+				t.Errorf("active counts %v, want %v", counts, c.active)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	e := NewEngine(metrics.NewRegistry())
+	rec := &recorder{}
+	e.AddChannel("main", rec.channel("main"), Severity_Info)
+	e.SetRateLimit(1)
+	err := e.AddRule(RuleConfig{Name: "reject", Type: "event", Metric: "reject", Severity: "warning", RepeatSec: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二条告警被限流
+	e.event("reject", "", "", map[string]string{"inst": "a"}, t0)
+	e.event("reject", "", "", map[string]string{"inst": "b"}, t0.Add(time.Second))
+	if got := rec.wait(t, 1); fmt.Sprint(got) != "[0 main firing warning]" {
+		t.Fatalf("sent %q", got)
+	}
+
+	as := e.Active()
+	if len(as) != 2 || as[0].Suppressed != 0 || as[1].Suppressed != 1 {
+		t.Fatalf("active %+v", as)
+	}
+
+	// 确认第一条后，只有被限流的那条重复提醒，并带上被丢弃的通知数
+	e.Ack(as[0].Id, "test")
+	e.evaluate(t0.Add(time.Second * 61))
+	if got := rec.wait(t, 2); fmt.Sprint(got) != "[0 main firing warning 61 main firing warning]" {
+		t.Fatalf("sent %q", got)
+	}
+
+	rec.mu.Lock()
+	last := rec.last["main"]
+	rec.mu.Unlock()
+	if last.Id != as[1].Id || last.Suppressed != 1 {
+		t.Fatalf("last notification %+v", last)
+	}
+	if as = e.Active(); as[1].Suppressed != 0 {
+		t.Fatalf("suppressed not reset: %+v", as[1])
+	}
+}
+
+func TestChannelAllow(t *testing.T) {
+	cases := []struct {
+		name   string
+		perMin int
+		sent   []int // 之前发送的秒数
+		now    int
+		want   bool
+	}{
+		{"unlimited", 0, []int{0, 1, 2}, 3, true},
+		{"under limit", 2, []int{0}, 10, true},
+		{"at limit", 2, []int{0, 10}, 20, false},
+		{"old sends expire", 2, []int{0, 10}, 60, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ch := &channel{}
+			for _, s := range c.sent {
+				ch.sent = append(ch.sent, t0.Add(time.Second*time.Duration(s)))
+			}
+			if got := ch.allow(t0.Add(time.Second*time.Duration(c.now)), c.perMin); got != c.want {
+				t.Fatalf("allow = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
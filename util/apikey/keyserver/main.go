@@ -0,0 +1,222 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-19 16:40:12
+- @Description: v2协议的参考key服务器。key保存在本地加密key文件中，口令由环境变量DAGGER_KEYSTORE_PASS提供
+- 服务器私钥文件不存在时自动生成。客户端公钥需要写进launch.json的clients，或运行时用allow登记
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/apikey"
+	"github.com/aztecqt/dagger/util/crypto"
+	"github.com/aztecqt/dagger/util/logger"
+)
+
+const passEnv = "DAGGER_KEYSTORE_PASS"
+
+type LaunchConfig struct {
+	Port      int      `json:"port"`
+	Keystore  string   `json:"keystore"`
+	ServerKey string   `json:"server_key"` // 服务器私钥文件(hex)
+	Clients   []string `json:"clients"`    // 允许的客户端公钥(hex)
+}
+
+func main() {
+	fmt.Println("===apikey server===")
+	logger.Init(logger.SplitMode_ByDays, 7)
+	input := bufio.NewScanner(os.Stdin)
+
+	lc := LaunchConfig{}
+	if !util.ObjectFromFile("launch.json", &lc) {
+		fmt.Println("load launch.json failed")
+		return
+	}
+
+	// 打开key文件，不存在时新建
+	pass := []byte(os.Getenv(passEnv))
+	os.Unsetenv(passEnv)
+	if len(pass) == 0 {
+		fmt.Printf("%s not set\n", passEnv)
+		return
+	}
+
+	var ks *apikey.Keystore
+	var err error
+	if _, e := os.Stat(lc.Keystore); e == nil {
+		ks, err = apikey.OpenKeystore(lc.Keystore, pass)
+	} else {
+		ks, err = apikey.CreateKeystore(lc.Keystore, pass)
+	}
+	crypto.Zero(pass)
+	if err != nil {
+		fmt.Printf("open keystore failed: %s\n", err.Error())
+		return
+	}
+	defer ks.Close()
+
+	priv, err := loadOrGenServerKey(lc.ServerKey)
+	if err != nil {
+		fmt.Printf("load server key failed: %s\n", err.Error())
+		return
+	}
+
+	svr, err := apikey.NewServer(ks, priv)
+	if err != nil {
+		fmt.Printf("create server failed: %s\n", err.Error())
+		return
+	}
+	defer svr.Close()
+
+	for _, c := range lc.Clients {
+		if pub, err := crypto.ParseBoxKey(c); err == nil {
+			svr.AllowClient(pub)
+		} else {
+			fmt.Printf("invalid client key %s: %s\n", c, err.Error())
+		}
+	}
+
+	if !svr.Listen(lc.Port) {
+		fmt.Printf("listen at %d failed\n", lc.Port)
+		return
+	}
+
+	if pub, err := crypto.BoxPublicKey(priv); err == nil {
+		fmt.Printf("server public key: %s\n", pub.Hex())
+	}
+
+	for {
+		fmt.Print(">")
+		if !input.Scan() {
+			return
+		}
+		ss := strings.Fields(input.Text())
+		if len(ss) == 0 {
+			continue
+		}
+
+		switch ss[0] {
+		case "help":
+			fmt.Println("ls")
+			fmt.Println("put exchange account [key_type]")
+			fmt.Println("prune exchange account keep")
+			fmt.Println("genid file")
+			fmt.Println("allow public_key")
+		case "ls":
+			for _, id := range ks.Accounts() {
+				exAcc := strings.SplitN(id, "/", 2)
+				fmt.Printf("%s versions=%v\n", id, ks.Versions(exAcc[0], exAcc[1]))
+			}
+		case "put":
+			if len(ss) < 3 {
+				fmt.Println("missing exchange/account")
+				continue
+			}
+
+			s := apikey.Secret{}
+			if len(ss) > 3 {
+				s.KeyType = ss[3]
+			}
+			s.Key = []byte(prompt(input, "key: "))
+			s.Secret = []byte(prompt(input, "secret(or @file): "))
+			s.Password = []byte(prompt(input, "password: "))
+			if len(s.Secret) > 0 && s.Secret[0] == '@' {
+				b, err := os.ReadFile(string(s.Secret[1:]))
+				if err != nil {
+					fmt.Printf("read secret file failed: %s\n", err.Error())
+					s.Zero()
+					continue
+				}
+				crypto.Zero(s.Secret)
+				s.Secret = b
+			}
+
+			if v, err := ks.Put(ss[1], ss[2], &s); err == nil {
+				fmt.Printf("saved, version %d\n", v)
+			} else {
+				fmt.Printf("save failed: %s\n", err.Error())
+			}
+			s.Zero()
+		case "prune":
+			if len(ss) < 4 {
+				fmt.Println("missing exchange/account/keep")
+				continue
+			}
+
+			keep, err := strconv.Atoi(ss[3])
+			if err != nil {
+				fmt.Println("invalid keep")
+				continue
+			}
+
+			if err := ks.Prune(ss[1], ss[2], keep); err == nil {
+				fmt.Printf("versions=%v\n", ks.Versions(ss[1], ss[2]))
+			} else {
+				fmt.Printf("prune failed: %s\n", err.Error())
+			}
+		case "genid":
+			// 生成一个客户端身份：私钥写入文件交给客户端，公钥需要登记
+			if len(ss) < 2 {
+				fmt.Println("missing file")
+				continue
+			}
+
+			pub, cpriv, err := crypto.GenerateBoxKeyPair()
+			if err != nil {
+				fmt.Printf("gen key pair failed: %s\n", err.Error())
+				continue
+			}
+
+			err = os.WriteFile(ss[1], []byte(cpriv.Hex()), 0600)
+			cpriv.Zero()
+			if err != nil {
+				fmt.Printf("write %s failed: %s\n", ss[1], err.Error())
+				continue
+			}
+			svr.AllowClient(pub)
+			fmt.Printf("client public key: %s (allowed, add it to launch.json to keep it)\n", pub.Hex())
+		case "allow":
+			if len(ss) < 2 {
+				fmt.Println("missing public key")
+				continue
+			}
+
+			if pub, err := crypto.ParseBoxKey(ss[1]); err == nil {
+				svr.AllowClient(pub)
+				fmt.Println("allowed")
+			} else {
+				fmt.Printf("invalid public key: %s\n", err.Error())
+			}
+		}
+	}
+}
+
+func prompt(input *bufio.Scanner, msg string) string {
+	fmt.Print(msg)
+	input.Scan()
+	return strings.TrimSpace(input.Text())
+}
+
+// 读取服务器私钥，文件不存在时生成一个
+func loadOrGenServerKey(path string) (*crypto.BoxKey, error) {
+	if _, err := os.Stat(path); err == nil {
+		return crypto.LoadBoxKey(path)
+	}
+
+	_, priv, err := crypto.GenerateBoxKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(priv.Hex()), 0600); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
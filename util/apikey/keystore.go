@@ -0,0 +1,272 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 01:48:26
+ * @Description: 离线加密的key文件，单机部署时代替key服务器
+ * 主密钥由口令经scrypt派生，每条key用AES-256-GCM单独加密，附加数据为"交易所/账号#版本"，防止密文被挪用
+ * 同一账号可以保存多个版本，Put即轮换，Get默认取最新版本
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/util/crypto"
+)
+
+const keystoreFormat = 1
+const keystoreCheck = "dagger-keystore"
+
+// 一个版本的key。用完后调用Zero
+type Secret struct {
+	Key        []byte    `json:"key"`
+	Secret     []byte    `json:"secret"`
+	Password   []byte    `json:"password"`
+	KeyType    string    `json:"key_type"`
+	Version    int       `json:"-"`
+	CreateTime time.Time `json:"-"`
+}
+
+func (s *Secret) Zero() {
+	crypto.Zero(s.Key)
+	crypto.Zero(s.Secret)
+	crypto.Zero(s.Password)
+}
+
+type keystoreEntry struct {
+	Version    int       `json:"version"`
+	CreateTime time.Time `json:"time"`
+	Data       []byte    `json:"data"`
+}
+
+type keystoreFile struct {
+	Format  int                        `json:"format"`
+	Salt    []byte                     `json:"salt"`
+	Check   []byte                     `json:"check"` // 用于校验口令
+	Entries map[string][]keystoreEntry `json:"entries"`
+}
+
+type Keystore struct {
+	path   string
+	master []byte
+	file   keystoreFile
+	mu     sync.Mutex
+}
+
+func keystoreId(exchange, account string) string {
+	return fmt.Sprintf("%s/%s", exchange, account)
+}
+
+func entryAad(id string, version int) []byte {
+	return []byte(fmt.Sprintf("%s#%d", id, version))
+}
+
+// 创建新的key文件，文件已存在时返回错误
+func CreateKeystore(path string, password []byte) (*Keystore, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+
+	salt, err := crypto.RandomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &Keystore{path: path}
+	ks.file = keystoreFile{Format: keystoreFormat, Salt: salt, Entries: make(map[string][]keystoreEntry)}
+	if ks.master, err = crypto.DeriveKey(password, salt); err != nil {
+		return nil, err
+	}
+	if ks.file.Check, err = crypto.AesGcmSeal(ks.master, []byte(keystoreCheck), nil); err != nil {
+		return nil, err
+	}
+
+	if err := ks.save(); err != nil {
+		ks.Close()
+		return nil, err
+	}
+	return ks, nil
+}
+
+// 打开key文件，口令错误时返回错误
+func OpenKeystore(path string, password []byte) (*Keystore, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &Keystore{path: path}
+	if err := json.Unmarshal(b, &ks.file); err != nil {
+		return nil, err
+	}
+	if ks.file.Format != keystoreFormat {
+		return nil, fmt.Errorf("unsupported keystore format %d", ks.file.Format)
+	}
+	if ks.file.Entries == nil {
+		ks.file.Entries = make(map[string][]keystoreEntry)
+	}
+
+	if ks.master, err = crypto.DeriveKey(password, ks.file.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := crypto.AesGcmOpen(ks.master, ks.file.Check, nil); err != nil {
+		ks.Close()
+		return nil, errors.New("wrong keystore password")
+	}
+	return ks, nil
+}
+
+// 清除内存中的主密钥。之后不能再读写
+func (ks *Keystore) Close() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	crypto.Zero(ks.master)
+	ks.master = nil
+}
+
+// 保存新版本的key（轮换），返回版本号
+func (ks *Keystore) Put(exchange, account string, s *Secret) (int, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.master == nil {
+		return 0, errors.New("keystore closed")
+	}
+
+	id := keystoreId(exchange, account)
+	entries := ks.file.Entries[id]
+	version := 1
+	if len(entries) > 0 {
+		version = entries[len(entries)-1].Version + 1
+	}
+
+	plain, err := json.Marshal(s)
+	if err != nil {
+		return 0, err
+	}
+	defer crypto.Zero(plain)
+
+	data, err := crypto.AesGcmSeal(ks.master, plain, entryAad(id, version))
+	if err != nil {
+		return 0, err
+	}
+
+	ks.file.Entries[id] = append(entries, keystoreEntry{Version: version, CreateTime: time.Now(), Data: data})
+	if err := ks.save(); err != nil {
+		ks.file.Entries[id] = entries
+		return 0, err
+	}
+	return version, nil
+}
+
+// 取key，version为0表示最新版本
+func (ks *Keystore) Get(exchange, account string, version int) (*Secret, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.master == nil {
+		return nil, errors.New("keystore closed")
+	}
+
+	id := keystoreId(exchange, account)
+	entries := ks.file.Entries[id]
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no key for %s", id)
+	}
+
+	var e *keystoreEntry
+	if version == 0 {
+		e = &entries[len(entries)-1]
+	} else {
+		for i := range entries {
+			if entries[i].Version == version {
+				e = &entries[i]
+			}
+		}
+	}
+	if e == nil {
+		return nil, fmt.Errorf("no key for %s version %d", id, version)
+	}
+
+	plain, err := crypto.AesGcmOpen(ks.master, e.Data, entryAad(id, e.Version))
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Zero(plain)
+
+	s := new(Secret)
+	if err := json.Unmarshal(plain, s); err != nil {
+		return nil, err
+	}
+	s.Version = e.Version
+	s.CreateTime = e.CreateTime
+	return s, nil
+}
+
+// 某账号所有的版本号，升序
+func (ks *Keystore) Versions(exchange, account string) []int {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	vs := make([]int, 0)
+	for _, e := range ks.file.Entries[keystoreId(exchange, account)] {
+		vs = append(vs, e.Version)
+	}
+	sort.Ints(vs)
+	return vs
+}
+
+// 所有保存了key的"交易所/账号"，升序
+func (ks *Keystore) Accounts() []string {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ids := make([]string, 0, len(ks.file.Entries))
+	for id, entries := range ks.file.Entries {
+		if len(entries) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// 只保留最新的keep个版本
+func (ks *Keystore) Prune(exchange, account string, keep int) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	id := keystoreId(exchange, account)
+	entries := ks.file.Entries[id]
+	if keep <= 0 || len(entries) <= keep {
+		return nil
+	}
+
+	ks.file.Entries[id] = entries[len(entries)-keep:]
+	if err := ks.save(); err != nil {
+		ks.file.Entries[id] = entries
+		return err
+	}
+	return nil
+}
+
+// 先写临时文件再替换，避免写到一半损坏
+func (ks *Keystore) save() error {
+	b, err := json.MarshalIndent(ks.file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := ks.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ks.path)
+}
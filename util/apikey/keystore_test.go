@@ -0,0 +1,151 @@
+package apikey
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// key文件的保存、读取、轮换、清理，以及口令和附加数据的校验
+
+func newTestKeystore(t *testing.T) (*Keystore, string) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ks, err := CreateKeystore(path, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ks.Close)
+	return ks, path
+}
+
+func putTestKey(t *testing.T, ks *Keystore, ex, acc, key string) int {
+	v, err := ks.Put(ex, acc, &Secret{Key: []byte(key), Secret: []byte(key + "-secret"), KeyType: "ed25519"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestKeystorePutGet(t *testing.T) {
+	ks, path := newTestKeystore(t)
+	if v := putTestKey(t, ks, "okx", "acc", "k1"); v != 1 {
+		t.Fatalf("first version %d", v)
+	}
+	if v := putTestKey(t, ks, "okx", "acc", "k2"); v != 2 {
+		t.Fatalf("second version %d", v)
+	}
+	putTestKey(t, ks, "binance", "acc", "b1")
+
+	// 重新打开，确认已落盘
+	ks.Close()
+	ks, err := OpenKeystore(path, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ks.Close()
+
+	s, err := ks.Get("okx", "acc", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(s.Key) != "k2" || string(s.Secret) != "k2-secret" || s.KeyType != "ed25519" || s.Version != 2 {
+		t.Fatalf("latest key mismatch: %+v", s)
+	}
+
+	if s, err = ks.Get("okx", "acc", 1); err != nil || string(s.Key) != "k1" {
+		t.Fatalf("version 1: %v", err)
+	}
+	if _, err := ks.Get("okx", "acc", 3); err == nil {
+		t.Fatal("missing version should fail")
+	}
+	if _, err := ks.Get("okx", "other", 0); err == nil {
+		t.Fatal("missing account should fail")
+	}
+	if got := ks.Accounts(); !reflect.DeepEqual(got, []string{"binance/acc", "okx/acc"}) {
+		t.Fatalf("accounts %v", got)
+	}
+
+	ks.Close()
+	if _, err := ks.Get("okx", "acc", 0); err == nil {
+		t.Fatal("closed keystore should fail")
+	}
+}
+
+func TestKeystorePrune(t *testing.T) {
+	ks, path := newTestKeystore(t)
+	for _, k := range []string{"k1", "k2", "k3", "k4"} {
+		putTestKey(t, ks, "okx", "acc", k)
+	}
+
+	if err := ks.Prune("okx", "acc", 2); err != nil {
+		t.Fatal(err)
+	}
+	if vs := ks.Versions("okx", "acc"); !reflect.DeepEqual(vs, []int{3, 4}) {
+		t.Fatalf("versions after prune %v", vs)
+	}
+	if _, err := ks.Get("okx", "acc", 1); err == nil {
+		t.Fatal("pruned version should be gone")
+	}
+
+	// 清理后版本号继续递增，不会复用
+	if v := putTestKey(t, ks, "okx", "acc", "k5"); v != 5 {
+		t.Fatalf("version after prune %d", v)
+	}
+
+	ks2, err := OpenKeystore(path, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ks2.Close()
+	if vs := ks2.Versions("okx", "acc"); !reflect.DeepEqual(vs, []int{3, 4, 5}) {
+		t.Fatalf("versions on disk %v", vs)
+	}
+}
+
+func TestKeystoreWrongPassword(t *testing.T) {
+	ks, path := newTestKeystore(t)
+	putTestKey(t, ks, "okx", "acc", "k1")
+
+	if _, err := OpenKeystore(path, []byte("Pass")); err == nil {
+		t.Fatal("wrong password should fail")
+	}
+	if _, err := CreateKeystore(path, []byte("pass")); err == nil {
+		t.Fatal("create over existing file should fail")
+	}
+}
+
+// 把一个账号的密文挪给另一个账号，附加数据对不上，解不开
+func TestKeystoreEntryBoundToAccount(t *testing.T) {
+	ks, path := newTestKeystore(t)
+	putTestKey(t, ks, "okx", "a", "ka")
+	putTestKey(t, ks, "okx", "b", "kb")
+	ks.Close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := keystoreFile{}
+	if err := json.Unmarshal(b, &f); err != nil {
+		t.Fatal(err)
+	}
+	f.Entries["okx/b"][0].Data = f.Entries["okx/a"][0].Data
+	b, _ = json.Marshal(f)
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err = OpenKeystore(path, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ks.Close()
+	if _, err := ks.Get("okx", "b", 0); err == nil {
+		t.Fatal("moved entry should not decrypt")
+	}
+	if s, err := ks.Get("okx", "a", 0); err != nil || string(s.Key) != "ka" {
+		t.Fatalf("untouched entry: %v", err)
+	}
+}
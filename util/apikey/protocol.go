@@ -12,7 +12,9 @@ package apikey
 
 import (
 	"encoding/json"
+	"time"
 
+	"github.com/aztecqt/dagger/util/crypto"
 	"github.com/aztecqt/dagger/util/udpsocket"
 )
 
@@ -22,6 +24,14 @@ const opReleaseKeyReq = "release_key_req"
 const opKeepKeyReq = "keep_key_req"
 const opKeepKeyAck = "keep_key_ack"
 
+// v2协议。消息体用NaCl box加密，双方用静态密钥对互相认证，每条消息使用新的随机nonce
+// 请求中带随机challenge，回报必须原样带回，防止旧回报被重放
+const opGetKeyReqV2 = "get_key_req_v2"
+const opGetKeyAckV2 = "get_key_ack_v2"
+const opReleaseKeyReqV2 = "release_key_req_v2"
+const opKeepKeyReqV2 = "keep_key_req_v2"
+const opKeepKeyAckV2 = "keep_key_ack_v2"
+
 type getKeyReq struct {
 	udpsocket.Header
 	Exchange string `json:"ex"`
@@ -37,6 +47,7 @@ type getKeyAck struct {
 	Key      string `json:"key"`
 	Secret   string `json:"secret"`
 	Password string `json:"password"`
+	KeyType  string `json:"key_type"` // 签名方式，如hmac/rsa/ed25519。为空表示交易所默认方式
 }
 
 type releaseKeyReq struct {
@@ -102,3 +113,92 @@ func newKeepKeyReq(acc, ex, user, keyenc string, share bool) string {
 		return ""
 	}
 }
+
+// #region v2
+// v2消息外层。Client为发送方(客户端)公钥，服务器据此找到已登记的客户端并打开密文
+type sealedMsg struct {
+	udpsocket.Header
+	Client string `json:"client"`
+	Data   []byte `json:"data"` // nonce|密文
+}
+
+type getKeyReqV2 struct {
+	Exchange  string `json:"ex"`
+	Account   string `json:"acc"`
+	User      string `json:"user"`
+	Share     bool   `json:"share"`
+	Challenge []byte `json:"challenge"`
+	Timestamp int64  `json:"ts"` // 服务器据此拒绝过期的请求
+}
+
+type getKeyAckV2 struct {
+	Result    bool   `json:"rst"`
+	Message   string `json:"msg"`
+	Key       []byte `json:"key"`
+	Secret    []byte `json:"secret"`
+	Password  []byte `json:"password"`
+	KeyType   string `json:"key_type"`
+	Version   int    `json:"version"`
+	Challenge []byte `json:"challenge"`
+}
+
+type releaseKeyReqV2 struct {
+	User      string `json:"user"`
+	Timestamp int64  `json:"ts"`
+}
+
+type keepKeyReqV2 struct {
+	Exchange  string `json:"ex"`
+	Account   string `json:"acc"`
+	User      string `json:"user"`
+	Share     bool   `json:"share"`
+	Version   int    `json:"version"` // 当前持有的版本
+	Challenge []byte `json:"challenge"`
+	Timestamp int64  `json:"ts"`
+}
+
+type keepKeyAckV2 struct {
+	Result    bool   `json:"rst"`
+	Message   string `json:"msg"`
+	Version   int    `json:"version"` // 服务器上的最新版本，比持有的新时需要重新获取
+	Challenge []byte `json:"challenge"`
+}
+
+// 加密并封装成v2消息。客户端和服务器共用，self为发送方密钥对，peerPub为接收方公钥
+func sealMsg(op string, body interface{}, selfPub, selfPriv, peerPub *crypto.BoxKey) (string, error) {
+	plain, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	defer crypto.Zero(plain)
+
+	msg := sealedMsg{Client: selfPub.Hex()}
+	msg.OP = op
+	if msg.Data, err = crypto.BoxSeal(plain, peerPub, selfPriv); err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(msg)
+	return string(b), err
+}
+
+// 解开服务器发来的v2消息。只有持有服务器私钥的一方能生成可以打开的密文，因此同时完成了对服务器的认证
+func openMsg(data []byte, body interface{}, clientPriv, serverPub *crypto.BoxKey) error {
+	msg := sealedMsg{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+
+	plain, err := crypto.BoxOpen(msg.Data, serverPub, clientPriv)
+	if err != nil {
+		return err
+	}
+	defer crypto.Zero(plain)
+	return json.Unmarshal(plain, body)
+}
+
+func nowTs() int64 {
+	return time.Now().UnixMilli()
+}
+
+// #endregion
@@ -4,18 +4,24 @@
   - @LastEditors: Please set LastEditors
  * @FilePath: \dagger\util\apikey\requester.go
  * @Description: 从apikey服务器请求一个符合条件的Key
- *
+ * 设置了身份密钥(SetIdentity)时使用v2协议：NaCl box加密、双向认证、支持key轮换；否则使用旧的DES协议
+ * 也可以从本地加密key文件加载(LoadFromKeystore)，用于单机部署
+ * key以[]byte保存，Quit时清零。已经通过Key()/Secret()取出的string无法清零
+ * 注意：原来的Key/Secret/Password字段已改为同名方法，调用方需要由r.Key改为r.Key()
+ * 服务器端见server.go，参考实现见keyserver
  * Copyright (c) 2022 by aztec, All Rights Reserved.
 */
 
 package apikey
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/aztecqt/dagger/util"
@@ -28,42 +34,156 @@ type FnKeyAquired func(k, s, p string)
 
 type Requester struct {
 	logPrefix string
-	Key       string
-	Secret    string
-	Password  string
+	key       []byte
+	secret    []byte
+	password  []byte
+	KeyType   string // 签名方式，由key服务器下发
+	Version   int    // key版本，仅v2协议和key文件有
 	key_enc   string // 加密过的key，用于keepkey
 	deskey    string //解密密钥
 	chStop    chan int
 	share     bool // 共享模式。共享模式不会独占锁死所用的key
+	mu        sync.Mutex
+
+	// v2协议
+	clientPub     *crypto.BoxKey
+	clientPriv    *crypto.BoxKey
+	serverPub     *crypto.BoxKey
+	getChallenge  []byte
+	keepChallenge []byte
+	needRefetch   bool
+	exchange      string
+	account       string
+	user          string
+	fromServer    bool
+	chDone        chan int
+
+	// key轮换后的回调
+	OnKeyRotated FnKeyAquired
+}
+
+// 设置v2协议的身份：本机私钥文件、服务器公钥文件（均为hex）。需在Go之前调用
+func (r *Requester) SetIdentity(clientKeyFile, serverPubFile string) error {
+	priv, err := crypto.LoadBoxKey(clientKeyFile)
+	if err != nil {
+		return fmt.Errorf("load client key failed: %s", err.Error())
+	}
+
+	pub, err := crypto.BoxPublicKey(priv)
+	if err != nil {
+		return err
+	}
+
+	serverPub, err := crypto.LoadBoxKey(serverPubFile)
+	if err != nil {
+		return fmt.Errorf("load server public key failed: %s", err.Error())
+	}
+
+	r.clientPriv, r.clientPub, r.serverPub = priv, pub, serverPub
+	return nil
 }
 
 func (r *Requester) Go(exchange, account, user string, share bool, serverAddr string, serverPort int) {
 	r.logPrefix = fmt.Sprintf("apikey-%s-%s", exchange, account)
 	r.share = share
+	r.exchange, r.account, r.user = exchange, account, user
 	logger.LogInfo(r.logPrefix, "start")
 
-	us := udpsocket.Socket{}
+	us := &udpsocket.Socket{}
 	if !us.Connect(serverAddr, serverPort, r.onRecvUDPMessage) {
 		logger.LogPanic(r.logPrefix, "connect to key server failed!")
 	}
 
 	r.chStop = make(chan int)
+	r.chDone = make(chan int)
+	r.fromServer = true
 
-	if b, err := os.ReadFile("des.private"); err == nil {
-		r.deskey = string(b)
-	} else {
-		logger.LogPanic(r.logPrefix, "read deskey failed")
+	if r.clientPriv == nil {
+		logger.LogImportant(r.logPrefix, "no identity set, using legacy des protocol")
+		if b, err := os.ReadFile("des.private"); err == nil {
+			r.deskey = string(b)
+		} else {
+			logger.LogPanic(r.logPrefix, "read deskey failed")
+		}
 	}
 
-	go r.update(exchange, account, user, &us)
+	go r.update(us)
 
 	for !r.Ready() {
 		time.Sleep(100)
 	}
 }
 
+// 从本地加密key文件加载最新版本的key
+func (r *Requester) LoadFromKeystore(path string, password []byte, exchange, account string) error {
+	r.logPrefix = fmt.Sprintf("apikey-%s-%s", exchange, account)
+	ks, err := OpenKeystore(path, password)
+	if err != nil {
+		return err
+	}
+	defer ks.Close()
+
+	s, err := ks.Get(exchange, account, 0)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.setKey(s.Key, s.Secret, s.Password)
+	r.KeyType = s.KeyType
+	r.Version = s.Version
+	r.mu.Unlock()
+	logger.LogInfo(r.logPrefix, "key loaded from keystore, version=%d", s.Version)
+	return nil
+}
+
 func (r *Requester) Ready() bool {
-	return len(r.Key) > 0 && len(r.Secret) > 0
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.key) > 0 && len(r.secret) > 0
+}
+
+func (r *Requester) Key() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return string(r.key)
+}
+
+func (r *Requester) Secret() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return string(r.secret)
+}
+
+func (r *Requester) Password() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return string(r.password)
+}
+
+// 归还key（从服务器获取时）并清零内存中的key和身份私钥
+func (r *Requester) Quit() {
+	if r.fromServer {
+		close(r.chStop)
+		<-r.chDone
+		r.fromServer = false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setKey(nil, nil, nil)
+	if r.clientPriv != nil {
+		r.clientPriv.Zero()
+	}
+	logger.LogInfo(r.logPrefix, "key zeroed")
+}
+
+// 替换key，旧的key清零。调用方持有锁
+func (r *Requester) setKey(k, s, p []byte) {
+	crypto.Zero(r.key)
+	crypto.Zero(r.secret)
+	crypto.Zero(r.password)
+	r.key, r.secret, r.password = k, s, p
 }
 
 func (r *Requester) onRecvUDPMessage(op string, data []byte, addr *net.UDPAddr) {
@@ -80,10 +200,14 @@ func (r *Requester) onRecvUDPMessage(op string, data []byte, addr *net.UDPAddr)
 			bpass, err3 := hex.DecodeString(ack.Password)
 			if err1 == nil && err2 == nil && err3 == nil {
 				key := r.deskey
-				r.Key = string(crypto.DesCBCDecrypter(bkey, []byte(key), []byte(key)))
-				r.Secret = string(crypto.DesCBCDecrypter(bsec, []byte(key), []byte(key)))
-				r.Password = string(crypto.DesCBCDecrypter(bpass, []byte(key), []byte(key)))
+				r.mu.Lock()
+				r.setKey(
+					crypto.DesCBCDecrypter(bkey, []byte(key), []byte(key)),
+					crypto.DesCBCDecrypter(bsec, []byte(key), []byte(key)),
+					crypto.DesCBCDecrypter(bpass, []byte(key), []byte(key)))
+				r.KeyType = ack.KeyType
 				r.key_enc = ack.Key
+				r.mu.Unlock()
 				logger.LogInfo(r.logPrefix, "get key success!")
 			} else {
 				logger.LogInfo(r.logPrefix, "decrypt msg failed")
@@ -100,40 +224,159 @@ func (r *Requester) onRecvUDPMessage(op string, data []byte, addr *net.UDPAddr)
 		if !ack.Result {
 			logger.LogImportant(r.logPrefix, "keep apikey failed!")
 		}
+	case opGetKeyAckV2:
+		r.onGetKeyAckV2(data)
+	case opKeepKeyAckV2:
+		r.onKeepKeyAckV2(data)
 	}
 }
 
-func (r *Requester) update(exchange, account, user string, us *udpsocket.Socket) {
+func (r *Requester) onGetKeyAckV2(data []byte) {
+	ack := getKeyAckV2{}
+	if err := openMsg(data, &ack, r.clientPriv, r.serverPub); err != nil {
+		logger.LogImportant(r.logPrefix, "open get-key ack failed(server not authenticated?): %s", err.Error())
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.getChallenge) == 0 || !bytes.Equal(ack.Challenge, r.getChallenge) {
+		logger.LogImportant(r.logPrefix, "get-key ack challenge mismatch, ignored")
+		return
+	}
+	r.getChallenge = nil
+
+	if !ack.Result {
+		logger.LogImportant(r.logPrefix, "get key failed: %s", ack.Message)
+		return
+	}
+
+	rotated := len(r.key) > 0
+	if rotated && ack.Version <= r.Version {
+		r.needRefetch = false
+		return // 重复的回报
+	}
+
+	r.setKey(ack.Key, ack.Secret, ack.Password)
+	r.KeyType = ack.KeyType
+	r.Version = ack.Version
+	r.needRefetch = false
+	logger.LogInfo(r.logPrefix, "get key success, version=%d", ack.Version)
+
+	if rotated {
+		logger.LogImportant(r.logPrefix, "key rotated to version %d", ack.Version)
+		if r.OnKeyRotated != nil {
+			go r.OnKeyRotated(string(r.key), string(r.secret), string(r.password))
+		}
+	}
+}
+
+func (r *Requester) onKeepKeyAckV2(data []byte) {
+	ack := keepKeyAckV2{}
+	if err := openMsg(data, &ack, r.clientPriv, r.serverPub); err != nil {
+		logger.LogImportant(r.logPrefix, "open keep-key ack failed(server not authenticated?): %s", err.Error())
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.keepChallenge) == 0 || !bytes.Equal(ack.Challenge, r.keepChallenge) {
+		return // 过期的回报
+	}
+	r.keepChallenge = nil
+
+	if !ack.Result {
+		logger.LogImportant(r.logPrefix, "keep apikey failed: %s", ack.Message)
+	} else if ack.Version > r.Version {
+		logger.LogImportant(r.logPrefix, "new key version %d available (current %d), refetching", ack.Version, r.Version)
+		r.needRefetch = true
+	}
+}
+
+// 发送获取/维持请求
+func (r *Requester) request(us *udpsocket.Socket) {
+	if r.clientPriv == nil {
+		if len(r.Key()) == 0 {
+			us.SendString(newGetKeyReq(r.account, r.exchange, r.user, r.share))
+		} else {
+			us.SendString(newKeepKeyReq(r.account, r.exchange, r.user, r.key_enc, r.share))
+		}
+		return
+	}
+
+	challenge, err := crypto.RandomBytes(16)
+	if err != nil {
+		logger.LogImportant(r.logPrefix, "gen challenge failed: %s", err.Error())
+		return
+	}
+
+	r.mu.Lock()
+	needGet := len(r.key) == 0 || r.needRefetch
+	version := r.Version
+	if needGet {
+		r.getChallenge = challenge
+	} else {
+		r.keepChallenge = challenge
+	}
+	r.mu.Unlock()
+
+	var msg string
+	if needGet {
+		req := getKeyReqV2{Exchange: r.exchange, Account: r.account, User: r.user, Share: r.share, Challenge: challenge, Timestamp: nowTs()}
+		msg, err = sealMsg(opGetKeyReqV2, req, r.clientPub, r.clientPriv, r.serverPub)
+	} else {
+		req := keepKeyReqV2{Exchange: r.exchange, Account: r.account, User: r.user, Share: r.share, Version: version, Challenge: challenge, Timestamp: nowTs()}
+		msg, err = sealMsg(opKeepKeyReqV2, req, r.clientPub, r.clientPriv, r.serverPub)
+	}
+
+	if err == nil {
+		us.SendString(msg)
+	} else {
+		logger.LogImportant(r.logPrefix, "seal request failed: %s", err.Error())
+	}
+}
+
+// 归还key
+func (r *Requester) release(us *udpsocket.Socket) {
+	if len(r.Key()) == 0 {
+		return
+	}
+
+	if r.clientPriv == nil {
+		us.SendString(newReleaseKeyReq(r.user))
+	} else if msg, err := sealMsg(opReleaseKeyReqV2, releaseKeyReqV2{User: r.user, Timestamp: nowTs()}, r.clientPub, r.clientPriv, r.serverPub); err == nil {
+		us.SendString(msg)
+	}
+	logger.LogInfo(r.logPrefix, "key released")
+}
+
+func (r *Requester) update(us *udpsocket.Socket) {
 	// 先请求一次
-	req := newGetKeyReq(account, exchange, user, r.share)
-	us.SendString(req)
+	r.request(us)
 
 	// 每5秒请求/维持一次
 	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+	defer close(r.chDone)
 	for {
-		func() {
+		stopped := func() bool {
 			defer util.DefaultRecover()
 
 			select {
 			case <-ticker.C:
-				if len(r.Key) == 0 {
-					req := newGetKeyReq(account, exchange, user, r.share)
-					us.SendString(req)
-				} else {
-					req := newKeepKeyReq(account, exchange, user, r.key_enc, r.share)
-					us.SendString(req)
-				}
+				r.request(us)
+				return false
 			case <-r.chStop:
-				// 归还key
-				if len(r.Key) > 0 {
-					req := newReleaseKeyReq(user)
-					us.SendString(req)
-					logger.LogInfo(r.logPrefix, "key released")
-				}
-
+				r.release(us)
 				us.Close()
-				return
+				return true
 			}
 		}()
+
+		if stopped {
+			return
+		}
 	}
 }
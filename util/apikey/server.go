@@ -0,0 +1,263 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 16:05:41
+ * @Description: v2协议的服务器端。key来自本地加密key文件(Keystore)，只接受已登记公钥的客户端
+ * 独占模式下一个key同时只能被一个user持有，持有者需定期keep，超时未keep自动释放
+ * 请求中的时间戳超出允许范围、或challenge在时间窗口内重复出现时视为重放，直接丢弃
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/util/crypto"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/udpsocket"
+)
+
+// 客户端每5秒keep一次，超过这个时间没有keep就释放独占
+const serverHoldTimeout = time.Second * 30
+
+// 请求时间戳允许的误差，同时也是challenge去重的窗口
+const serverMaxClockSkew = time.Second * 30
+
+var errUnknownClient = errors.New("unknown client")
+
+type keyHolder struct {
+	client string
+	user   string
+	expire time.Time
+}
+
+type Server struct {
+	logPrefix string
+	ks        *Keystore
+	pub       *crypto.BoxKey
+	priv      *crypto.BoxKey
+	clients   map[string]*crypto.BoxKey // 公钥hex-公钥
+	holders   map[string]*keyHolder     // 交易所/账号-独占持有者
+	seen      map[string]time.Time      // 已处理的challenge-过期时间
+	us        *udpsocket.Socket
+	mu        sync.Mutex
+}
+
+// ks由调用方打开和关闭，priv为服务器私钥
+func NewServer(ks *Keystore, priv *crypto.BoxKey) (*Server, error) {
+	pub, err := crypto.BoxPublicKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		logPrefix: "apikey-server",
+		ks:        ks,
+		pub:       pub,
+		priv:      priv,
+		clients:   make(map[string]*crypto.BoxKey),
+		holders:   make(map[string]*keyHolder),
+		seen:      make(map[string]time.Time),
+	}, nil
+}
+
+// 登记客户端公钥，未登记的客户端发来的请求无法打开
+func (s *Server) AllowClient(pub *crypto.BoxKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[pub.Hex()] = pub
+}
+
+func (s *Server) Listen(port int) bool {
+	s.us = &udpsocket.Socket{}
+	if !s.us.Listen(port, s.onRecvUDPMessage) {
+		return false
+	}
+	logger.LogImportant(s.logPrefix, "listening at %s, public key %s", s.us.LocalAddr().String(), s.pub.Hex())
+	return true
+}
+
+func (s *Server) LocalAddr() net.Addr {
+	return s.us.LocalAddr()
+}
+
+// 关闭socket并清零服务器私钥
+func (s *Server) Close() {
+	if s.us != nil {
+		s.us.Close()
+	}
+	s.priv.Zero()
+}
+
+func (s *Server) onRecvUDPMessage(op string, data []byte, addr *net.UDPAddr) {
+	switch op {
+	case opGetKeyReqV2:
+		req := getKeyReqV2{}
+		if client, err := s.openRequest(data, &req); err != nil {
+			logger.LogInfo(s.logPrefix, "open get-key req from %s failed: %s", addr.String(), err.Error())
+		} else if s.checkFresh(req.Timestamp, req.Challenge) {
+			s.reply(opGetKeyAckV2, s.onGetKey(client.Hex(), req), client, addr)
+		}
+	case opKeepKeyReqV2:
+		req := keepKeyReqV2{}
+		if client, err := s.openRequest(data, &req); err != nil {
+			logger.LogInfo(s.logPrefix, "open keep-key req from %s failed: %s", addr.String(), err.Error())
+		} else if s.checkFresh(req.Timestamp, req.Challenge) {
+			s.reply(opKeepKeyAckV2, s.onKeepKey(client.Hex(), req), client, addr)
+		}
+	case opReleaseKeyReqV2:
+		req := releaseKeyReqV2{}
+		if client, err := s.openRequest(data, &req); err != nil {
+			logger.LogInfo(s.logPrefix, "open release-key req from %s failed: %s", addr.String(), err.Error())
+		} else if s.checkFresh(req.Timestamp, nil) {
+			s.release(client.Hex(), req.User)
+		}
+	default:
+		logger.LogInfo(s.logPrefix, "unknown op %s from %s", op, addr.String())
+	}
+}
+
+func (s *Server) onGetKey(client string, req getKeyReqV2) *getKeyAckV2 {
+	ack := &getKeyAckV2{Challenge: req.Challenge}
+	id := keystoreId(req.Exchange, req.Account)
+	if !s.hold(id, client, req.User, req.Share) {
+		ack.Message = fmt.Sprintf("%s is in use", id)
+		logger.LogImportant(s.logPrefix, "%s rejected for user %s, in use", id, req.User)
+		return ack
+	}
+
+	sec, err := s.ks.Get(req.Exchange, req.Account, 0)
+	if err != nil {
+		s.release(client, req.User)
+		ack.Message = err.Error()
+		return ack
+	}
+
+	ack.Result = true
+	ack.Key, ack.Secret, ack.Password = sec.Key, sec.Secret, sec.Password
+	ack.KeyType = sec.KeyType
+	ack.Version = sec.Version
+	logger.LogInfo(s.logPrefix, "%s(version %d) sent to user %s", id, sec.Version, req.User)
+	return ack
+}
+
+func (s *Server) onKeepKey(client string, req keepKeyReqV2) *keepKeyAckV2 {
+	ack := &keepKeyAckV2{Challenge: req.Challenge}
+	id := keystoreId(req.Exchange, req.Account)
+	if !s.hold(id, client, req.User, req.Share) {
+		ack.Message = fmt.Sprintf("%s is in use", id)
+		return ack
+	}
+
+	vs := s.ks.Versions(req.Exchange, req.Account)
+	if len(vs) == 0 {
+		ack.Message = fmt.Sprintf("no key for %s", id)
+		return ack
+	}
+
+	ack.Result = true
+	ack.Version = vs[len(vs)-1]
+	return ack
+}
+
+// 取得或续期独占。共享模式不占用，但别人独占时同样拒绝
+func (s *Server) hold(id, client, user string, share bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if h, ok := s.holders[id]; ok && now.Before(h.expire) && (h.client != client || h.user != user) {
+		return false
+	}
+
+	if share {
+		delete(s.holders, id)
+	} else {
+		s.holders[id] = &keyHolder{client: client, user: user, expire: now.Add(serverHoldTimeout)}
+	}
+	return true
+}
+
+// 释放某客户端的某user持有的所有key
+func (s *Server) release(client, user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, h := range s.holders {
+		if h.client == client && h.user == user {
+			delete(s.holders, id)
+			logger.LogInfo(s.logPrefix, "%s released by user %s", id, user)
+		}
+	}
+}
+
+// 找到发送方公钥并打开请求
+func (s *Server) openRequest(data []byte, body interface{}) (*crypto.BoxKey, error) {
+	msg := sealedMsg{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	client, ok := s.clients[msg.Client]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errUnknownClient
+	}
+
+	plain, err := crypto.BoxOpen(msg.Data, client, s.priv)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Zero(plain)
+	return client, json.Unmarshal(plain, body)
+}
+
+// 检查时间戳，并记录challenge防止重放。challenge为nil时只检查时间戳
+func (s *Server) checkFresh(ts int64, challenge []byte) bool {
+	now := time.Now()
+	if d := now.Sub(time.UnixMilli(ts)); d > serverMaxClockSkew || d < -serverMaxClockSkew {
+		logger.LogInfo(s.logPrefix, "request expired, ts=%d", ts)
+		return false
+	}
+
+	if challenge == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c, expire := range s.seen {
+		if now.After(expire) {
+			delete(s.seen, c)
+		}
+	}
+
+	c := string(challenge)
+	if _, ok := s.seen[c]; ok {
+		logger.LogImportant(s.logPrefix, "replayed request dropped")
+		return false
+	}
+	s.seen[c] = now.Add(serverMaxClockSkew * 2)
+	return true
+}
+
+func (s *Server) reply(op string, ack interface{}, client *crypto.BoxKey, addr *net.UDPAddr) {
+	msg, err := sealMsg(op, ack, s.pub, s.priv, client)
+	if ack, ok := ack.(*getKeyAckV2); ok {
+		crypto.Zero(ack.Key)
+		crypto.Zero(ack.Secret)
+		crypto.Zero(ack.Password)
+	}
+
+	if err != nil {
+		logger.LogImportant(s.logPrefix, "seal %s failed: %s", op, err.Error())
+		return
+	}
+	s.us.SendStringTo(msg, addr)
+}
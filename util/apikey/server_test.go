@@ -0,0 +1,199 @@
+package apikey
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aztecqt/dagger/util/crypto"
+	"github.com/aztecqt/dagger/util/logger"
+)
+
+// 在本机起一个v2服务器，用Requester走完整的获取/维持/归还流程
+
+func TestMain(m *testing.M) {
+	logger.Setup(logger.NewStdoutSink(logger.ConsoleEncoder{}, logger.LogLevel_Important))
+	os.Exit(m.Run())
+}
+
+type testIdentity struct {
+	pub     *crypto.BoxKey
+	keyFile string
+}
+
+func newTestIdentity(t *testing.T, dir, name string) testIdentity {
+	pub, priv, err := crypto.GenerateBoxKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(priv.Hex()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return testIdentity{pub: pub, keyFile: path}
+}
+
+func newTestServer(t *testing.T) (*Server, *Keystore, string) {
+	ks, _ := newTestKeystore(t)
+	putTestKey(t, ks, "okx", "acc", "k1")
+
+	dir := t.TempDir()
+	_, priv, err := crypto.GenerateBoxKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	svr, err := NewServer(ks, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !svr.Listen(0) {
+		t.Fatal("listen failed")
+	}
+	t.Cleanup(svr.Close)
+
+	pubFile := filepath.Join(dir, "server.public")
+	if err := os.WriteFile(pubFile, []byte(svr.pub.Hex()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return svr, ks, pubFile
+}
+
+func waitFor(t *testing.T, what string, fn func() bool) {
+	deadline := time.Now().Add(time.Second * 5)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestServerRoundTrip(t *testing.T) {
+	svr, ks, serverPub := newTestServer(t)
+	client := newTestIdentity(t, t.TempDir(), "client.private")
+	svr.AllowClient(client.pub)
+
+	r := &Requester{}
+	if err := r.SetIdentity(client.keyFile, serverPub); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan int)
+	go func() {
+		r.Go("okx", "acc", "u1", false, "127.0.0.1", svr.LocalAddr().(*net.UDPAddr).Port)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("requester did not get the key")
+	}
+
+	if r.Key() != "k1" || r.Secret() != "k1-secret" || r.KeyType != "ed25519" || r.Version != 1 {
+		t.Fatalf("key mismatch: %s %s %s %d", r.Key(), r.Secret(), r.KeyType, r.Version)
+	}
+
+	// 独占中，别的user拿不到
+	if svr.hold(keystoreId("okx", "acc"), client.pub.Hex(), "u2", false) {
+		t.Fatal("exclusive key handed to another user")
+	}
+
+	// 轮换后keep回报新版本，Requester重新获取。定时请求间隔太长，这里直接调用两端的处理函数
+	putTestKey(t, ks, "okx", "acc", "k2")
+	rotated := make(chan string, 1)
+	r.OnKeyRotated = func(k, s, p string) { rotated <- k }
+
+	r.mu.Lock()
+	r.keepChallenge = []byte("keep")
+	r.mu.Unlock()
+	keepAck := svr.onKeepKey(client.pub.Hex(), keepKeyReqV2{Exchange: "okx", Account: "acc", User: "u1", Version: 1, Challenge: []byte("keep")})
+	if !keepAck.Result || keepAck.Version != 2 {
+		t.Fatalf("keep ack: %+v", keepAck)
+	}
+	feedAck(t, r, svr, client.pub, opKeepKeyAckV2, keepAck)
+	if !r.needRefetch {
+		t.Fatal("new version not noticed")
+	}
+
+	r.mu.Lock()
+	r.getChallenge = []byte("get")
+	r.mu.Unlock()
+	feedAck(t, r, svr, client.pub, opGetKeyAckV2, svr.onGetKey(client.pub.Hex(), getKeyReqV2{Exchange: "okx", Account: "acc", User: "u1", Challenge: []byte("get")}))
+	select {
+	case k := <-rotated:
+		if k != "k2" || r.Version != 2 {
+			t.Fatalf("rotated to %s version %d", k, r.Version)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rotation callback not called")
+	}
+
+	// 同一个回报再来一次，challenge已用过，被忽略
+	feedAck(t, r, svr, client.pub, opGetKeyAckV2, svr.onGetKey(client.pub.Hex(), getKeyReqV2{Exchange: "okx", Account: "acc", User: "u1", Challenge: []byte("get")}))
+	if r.Version != 2 || r.Key() != "k2" {
+		t.Fatal("stale ack applied")
+	}
+
+	r.Quit()
+	waitFor(t, "release", func() bool {
+		return svr.hold(keystoreId("okx", "acc"), client.pub.Hex(), "u2", false)
+	})
+	if r.Key() != "" {
+		t.Fatal("key not zeroed after quit")
+	}
+}
+
+// 未登记的客户端、过期的请求、重放的请求都不会得到回应
+func TestServerRejects(t *testing.T) {
+	svr, _, _ := newTestServer(t)
+	clientPub, clientPriv, err := crypto.GenerateBoxKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := getKeyReqV2{Exchange: "okx", Account: "acc", User: "u1", Challenge: []byte("c1"), Timestamp: nowTs()}
+	msg, err := sealMsg(opGetKeyReqV2, req, clientPub, clientPriv, svr.pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svr.openRequest([]byte(msg), &getKeyReqV2{}); err != errUnknownClient {
+		t.Fatalf("unknown client: err=%v", err)
+	}
+
+	svr.AllowClient(clientPub)
+	opened := getKeyReqV2{}
+	if _, err := svr.openRequest([]byte(msg), &opened); err != nil || opened.User != "u1" {
+		t.Fatalf("allowed client: err=%v", err)
+	}
+
+	if !svr.checkFresh(req.Timestamp, req.Challenge) {
+		t.Fatal("fresh request rejected")
+	}
+	if svr.checkFresh(req.Timestamp, req.Challenge) {
+		t.Fatal("replayed challenge accepted")
+	}
+	if svr.checkFresh(time.Now().Add(-time.Minute).UnixMilli(), []byte("c2")) {
+		t.Fatal("expired request accepted")
+	}
+
+	// 共享模式不占用，但独占者存在时也拿不到
+	id := keystoreId("okx", "acc")
+	if !svr.hold(id, "c", "u1", true) || !svr.hold(id, "c", "u2", false) || svr.hold(id, "c", "u1", true) {
+		t.Fatal("share/exclusive hold mismatch")
+	}
+	svr.release("c", "u2")
+	if !svr.hold(id, "c", "u1", false) {
+		t.Fatal("hold after release failed")
+	}
+}
+
+// 以服务器身份加密回报，交给Requester处理
+func feedAck(t *testing.T, r *Requester, svr *Server, client *crypto.BoxKey, op string, ack interface{}) {
+	msg, err := sealMsg(op, ack, svr.pub, svr.priv, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.onRecvUDPMessage(op, []byte(msg), nil)
+}
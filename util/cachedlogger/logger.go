@@ -6,32 +6,37 @@
 - @这种日志，平时不会进行磁盘IO，而是维持一个循环队列，最大保存n条日志
 - @当外部条件触发时，才将缓存的日志写入文件并存档（可以多次存档，每个存档是一个独立的文件）
 - @这样可以只用极少的磁盘空间，来调试原本需要大量日志文件才能定位的bug
+- @也实现了logger.Sink，AddSink后可以把结构化日志（含字段）一并缓存
 - @Copyright (c) 2024 by aztec, All Rights Reserved.
 */
 package cachedlogger
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
 )
 
 type Logger struct {
 	name     string
-	cache    [][]string // 双缓存队列
+	cache    [][]*logger.Entry // 双缓存队列
 	cacheLen int
 	i0       int // 当前是哪个cache
 	i1       int // cache内部index
+	mu       sync.Mutex
 }
 
 func NewLogger(cacheLen int, name string) *Logger {
 	l := &Logger{}
 	l.name = name
-	l.cache = make([][]string, 2)
-	l.cache[0] = make([]string, cacheLen)
-	l.cache[1] = make([]string, cacheLen)
+	l.cache = make([][]*logger.Entry, 2)
+	l.cache[0] = make([]*logger.Entry, cacheLen)
+	l.cache[1] = make([]*logger.Entry, cacheLen)
 	l.cacheLen = cacheLen
 	return l
 }
@@ -44,11 +49,21 @@ func (l *Logger) Log(format string, a ...interface{}) {
 	} else {
 		msg = format
 	}
-	now := time.Now()
-	msg = fmt.Sprintf("[%s.%03d] %s\n", now.Format("2006-01-02T15:04:05"), now.UnixMilli()%1000, msg)
+
+	l.Write(&logger.Entry{Time: time.Now(), Level: logger.LogLevel_Debug, Module: l.name, Msg: msg})
+}
+
+// #region 实现logger.Sink
+func (l *Logger) Level() logger.LogLevel {
+	return logger.LogLevel_Debug
+}
+
+func (l *Logger) Write(e *logger.Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
 	if l.i1 < l.cacheLen {
-		l.cache[l.i0][l.i1] = msg
+		l.cache[l.i0][l.i1] = e
 	}
 
 	l.i1++
@@ -58,26 +73,37 @@ func (l *Logger) Log(format string, a ...interface{}) {
 	}
 }
 
+func (l *Logger) Close() {}
+
+// #endregion
+
 // 将循环队列保存到磁盘
 func (l *Logger) Save() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	path := fmt.Sprintf("./log/cached/%s/%s.log", l.name, time.Now().Format("2006-01-02-15-04-05"))
 	util.MakeSureDirForFile(path)
 	i0 := (l.i0 + 1) % 2
 	i1 := l.i0
 	if file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, os.ModePerm); err == nil {
+		enc := logger.ConsoleEncoder{}
+		buf := bytes.Buffer{}
+
 		// 上一段
 		for i := 0; i < l.cacheLen; i++ {
-			if len(l.cache[i0][i]) > 0 {
-				file.WriteString(l.cache[i0][i]) // 自带换行
+			if l.cache[i0][i] != nil {
+				enc.Encode(&buf, l.cache[i0][i]) // 自带换行
 			}
 		}
 
 		// 这一段
 		for i := 0; i < l.i1; i++ {
-			if len(l.cache[i1][i]) > 0 {
-				file.WriteString(l.cache[i1][i]) // 自带换行
+			if l.cache[i1][i] != nil {
+				enc.Encode(&buf, l.cache[i1][i])
 			}
 		}
+		file.Write(buf.Bytes())
 		file.Close()
 	}
 }
@@ -1,13 +1,17 @@
 /*
 - @Author: aztec
 - @Date: 2024-06-14 16:30:45
-- @Description:
-- @
+- @Description: 对接只接受日志函数的模块
+- @未设置日志函数时，输出到logger的各个Sink，logPrefix作为模块名
 - @Copyright (c) 2024 by aztec, All Rights Reserved.
 */
 package compatiblelogger
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/aztecqt/dagger/util/logger"
+)
 
 type Logger struct {
 	logPrefix  string
@@ -23,28 +27,24 @@ func (l *Logger) InitCompatibleLogger(fnLogDebug, fnLogError func(string), logPr
 
 func (l Logger) LogDebug(format string, params ...interface{}) {
 	if l.fnLogDebug == nil {
-		l.fnLogDebug = func(s string) {
-			fmt.Println(s)
-		}
-	}
-
-	if len(l.logPrefix) > 0 {
-		l.fnLogDebug(fmt.Sprintf(l.logPrefix+" "+format, params...))
+		logger.LogDebug(l.logPrefix, format, params...)
 	} else {
-		l.fnLogDebug(fmt.Sprintf(format, params...))
+		l.fnLogDebug(l.format(format, params))
 	}
 }
 
 func (l Logger) LogError(format string, params ...interface{}) {
 	if l.fnLogError == nil {
-		l.fnLogError = func(s string) {
-			fmt.Println(s)
-		}
+		logger.LogImportant(l.logPrefix, format, params...)
+	} else {
+		l.fnLogError(l.format(format, params))
 	}
+}
 
-	if len(l.logPrefix) == 0 {
-		l.fnLogError(fmt.Sprintf(l.logPrefix+" "+format, params...))
+func (l Logger) format(format string, params []interface{}) string {
+	if len(l.logPrefix) > 0 {
+		return fmt.Sprintf(l.logPrefix+" "+format, params...)
 	} else {
-		l.fnLogError(fmt.Sprintf(format, params...))
+		return fmt.Sprintf(format, params...)
 	}
 }
@@ -0,0 +1,173 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 01:32:10
+ * @Description: 认证加密。AES-256-GCM用于本地存储，NaCl box用于与key服务器的通信
+ * 每次加密都使用新的随机nonce，nonce放在密文头部一起传输/存储
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/scrypt"
+)
+
+var ErrDecrypt = errors.New("decrypt failed")
+
+func RandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// 清零，用于用完的密钥和明文。注意string无法清零，敏感数据应尽量保存为[]byte
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// #region AES-GCM
+// 由口令派生32字节密钥（scrypt，N=32768,r=8,p=1）
+func DeriveKey(password, salt []byte) ([]byte, error) {
+	return scrypt.Key(password, salt, 1<<15, 8, 1, 32)
+}
+
+// AES-256-GCM加密，返回nonce|密文。aad为附加认证数据，解密时必须一致，可以为nil
+func AesGcmSeal(key, plain, aad []byte) ([]byte, error) {
+	gcm, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := RandomBytes(gcm.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, aad), nil
+}
+
+func AesGcmOpen(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+func newGcm(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("aes-gcm key must be 32 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// #endregion
+
+// #region NaCl box
+// box使用双方的静态密钥对（Curve25519）：只有持有发送方私钥的一方能生成可被接收方打开的密文，因此双方互相认证
+type BoxKey [32]byte
+
+func GenerateBoxKeyPair() (pub, priv *BoxKey, err error) {
+	p, s, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return (*BoxKey)(p), (*BoxKey)(s), nil
+}
+
+// 由私钥计算公钥
+func BoxPublicKey(priv *BoxKey) (*BoxKey, error) {
+	b, err := curve25519.X25519(priv[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	pub := new(BoxKey)
+	copy(pub[:], b)
+	return pub, nil
+}
+
+func (k *BoxKey) Hex() string {
+	return hex.EncodeToString(k[:])
+}
+
+func (k *BoxKey) Zero() {
+	Zero(k[:])
+}
+
+func ParseBoxKey(s string) (*BoxKey, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	defer Zero(b)
+
+	if len(b) != 32 {
+		return nil, errors.New("box key must be 32 bytes")
+	}
+
+	k := new(BoxKey)
+	copy(k[:], b)
+	return k, nil
+}
+
+// 从文件读取hex格式的密钥
+func LoadBoxKey(path string) (*BoxKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer Zero(b)
+	return ParseBoxKey(string(b))
+}
+
+// 用己方私钥和对方公钥加密，返回nonce|密文
+func BoxSeal(msg []byte, peerPub, priv *BoxKey) ([]byte, error) {
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	return box.Seal(nonce[:], msg, &nonce, (*[32]byte)(peerPub), (*[32]byte)(priv)), nil
+}
+
+func BoxOpen(sealed []byte, peerPub, priv *BoxKey) ([]byte, error) {
+	if len(sealed) < 24 {
+		return nil, ErrDecrypt
+	}
+
+	var nonce [24]byte
+	copy(nonce[:], sealed[:24])
+	plain, ok := box.Open(nil, sealed[24:], &nonce, (*[32]byte)(peerPub), (*[32]byte)(priv))
+	if !ok {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+// #endregion
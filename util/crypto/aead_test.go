@@ -0,0 +1,140 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+// AES-GCM和NaCl box的往返、篡改、错误密钥、附加数据不一致
+
+func deriveTestKey(t *testing.T, password string, salt []byte) []byte {
+	key, err := DeriveKey([]byte(password), salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAesGcmRoundTrip(t *testing.T) {
+	key := deriveTestKey(t, "pass", []byte("salt"))
+	plain := []byte("api secret")
+	aad := []byte("okx/acc#1")
+
+	sealed, err := AesGcmSeal(key, plain, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, plain) {
+		t.Fatal("plain text leaked into sealed data")
+	}
+
+	got, err := AesGcmOpen(key, sealed, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("round trip mismatch: %q", got)
+	}
+
+	// 每次使用新的nonce
+	sealed2, _ := AesGcmSeal(key, plain, aad)
+	if bytes.Equal(sealed, sealed2) {
+		t.Fatal("nonce reused")
+	}
+}
+
+func TestAesGcmTamper(t *testing.T) {
+	key := deriveTestKey(t, "pass", []byte("salt"))
+	sealed, err := AesGcmSeal(key, []byte("api secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range sealed {
+		b := append([]byte(nil), sealed...)
+		b[i] ^= 0x01
+		if _, err := AesGcmOpen(key, b, nil); err != ErrDecrypt {
+			t.Fatalf("tampered byte %d: err=%v", i, err)
+		}
+	}
+
+	if _, err := AesGcmOpen(key, sealed[:len(sealed)-1], nil); err != ErrDecrypt {
+		t.Fatalf("truncated: err=%v", err)
+	}
+	if _, err := AesGcmOpen(key, sealed[:4], nil); err != ErrDecrypt {
+		t.Fatalf("shorter than nonce: err=%v", err)
+	}
+}
+
+func TestAesGcmWrongPassword(t *testing.T) {
+	salt := []byte("salt")
+	sealed, err := AesGcmSeal(deriveTestKey(t, "pass", salt), []byte("api secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AesGcmOpen(deriveTestKey(t, "Pass", salt), sealed, nil); err != ErrDecrypt {
+		t.Fatalf("wrong password: err=%v", err)
+	}
+	if _, err := AesGcmOpen(deriveTestKey(t, "pass", []byte("salt2")), sealed, nil); err != ErrDecrypt {
+		t.Fatalf("wrong salt: err=%v", err)
+	}
+	if _, err := AesGcmSeal([]byte("short"), []byte("x"), nil); err == nil {
+		t.Fatal("short key should fail")
+	}
+}
+
+func TestAesGcmAadMismatch(t *testing.T) {
+	key := deriveTestKey(t, "pass", []byte("salt"))
+	sealed, err := AesGcmSeal(key, []byte("api secret"), []byte("okx/acc#1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, aad := range [][]byte{[]byte("okx/acc#2"), []byte("okx/acc2#1"), nil} {
+		if _, err := AesGcmOpen(key, sealed, aad); err != ErrDecrypt {
+			t.Fatalf("aad %q: err=%v", aad, err)
+		}
+	}
+}
+
+func TestBox(t *testing.T) {
+	aPub, aPriv, err := GenerateBoxKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bPub, bPriv, err := GenerateBoxKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, cPriv, err := GenerateBoxKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pub, err := BoxPublicKey(aPriv); err != nil || *pub != *aPub {
+		t.Fatal("public key derived from private key mismatch")
+	}
+	if k, err := ParseBoxKey(aPub.Hex()); err != nil || *k != *aPub {
+		t.Fatal("hex round trip failed")
+	}
+
+	msg := []byte("hello")
+	sealed, err := BoxSeal(msg, bPub, aPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := BoxOpen(sealed, aPub, bPriv); err != nil || !bytes.Equal(got, msg) {
+		t.Fatalf("box round trip failed: %v", err)
+	}
+
+	// 不是a发的（c冒充a），或者被篡改，都打不开
+	forged, _ := BoxSeal(msg, bPub, cPriv)
+	if _, err := BoxOpen(forged, aPub, bPriv); err != ErrDecrypt {
+		t.Fatalf("forged sender: err=%v", err)
+	}
+	sealed[len(sealed)-1] ^= 0x01
+	if _, err := BoxOpen(sealed, aPub, bPriv); err != ErrDecrypt {
+		t.Fatalf("tampered: err=%v", err)
+	}
+}
@@ -0,0 +1,39 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 01:05:48
+ * @Description: Ed25519私钥解析和签名
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// 解析pem格式（PKCS8）的Ed25519私钥
+func ParseEd25519PrivateKey(pemData []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("invalid pem data")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if edKey, ok := key.(ed25519.PrivateKey); ok {
+		return edKey, nil
+	} else {
+		return nil, errors.New("not an ed25519 private key")
+	}
+}
+
+func Ed25519Sign(key ed25519.PrivateKey, msg []byte) []byte {
+	return ed25519.Sign(key, msg)
+}
@@ -8,10 +8,13 @@
 package crypto
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"log"
 	"os"
 )
@@ -58,3 +61,32 @@ func GenerateRSAKey(bits int) {
 	//保存到文件
 	pem.Encode(publicFile, &publicBlock)
 }
+
+// 解析pem格式的RSA私钥，支持PKCS1和PKCS8
+func ParseRSAPrivateKey(pemData []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("invalid pem data")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		return rsaKey, nil
+	} else {
+		return nil, errors.New("not a rsa private key")
+	}
+}
+
+// RSA-PKCS1v15-SHA256签名
+func RSASignSHA256(key *rsa.PrivateKey, msg []byte) ([]byte, error) {
+	hash := sha256.Sum256(msg)
+	return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+}
@@ -0,0 +1,257 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 07:05:31
+ * @Description: 不依赖CommonDataViewer.exe的图表导出。把DataGroup按LayoutConfig整理成统一的图表数据，再由html/png两种方式输出
+ * 布局：Layout_Single单列，第一个画板占双倍高度；Layout_Row2单列等高；Layout_Square4两列
+ * 所有画板共用时间轴
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package datavisual
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aztecqt/dagger/util"
+)
+
+type chartLine struct {
+	Name   string     `json:"name"`
+	Color  Color      `json:"color"`
+	IsMain bool       `json:"main"`
+	Times  []int64    `json:"t"`
+	Values jsonFloats `json:"v"`
+}
+
+type chartPoints struct {
+	Name   string     `json:"name"`
+	Times  []int64    `json:"t"`
+	Values jsonFloats `json:"v"`
+	Tags   []PointTag `json:"tag"`
+}
+
+type chartEvents struct {
+	Name   string  `json:"name"`
+	Times  []int64 `json:"t"`
+	Colors []Color `json:"color"`
+}
+
+type chartPane struct {
+	Name   string        `json:"name"`
+	Lines  []chartLine   `json:"lines"`
+	Points []chartPoints `json:"points"`
+	Events []chartEvents `json:"events"`
+}
+
+type chartModel struct {
+	Title string      `json:"title"`
+	Cols  int         `json:"cols"`
+	Panes []chartPane `json:"panes"`
+
+	// 各画板的相对高度
+	Weights []int `json:"weights"`
+
+	// 按PointTag索引的点样式
+	PointStyles []chartPointStyle `json:"point_styles"`
+}
+
+type chartPointStyle struct {
+	Color Color `json:"color"`
+	Up    bool  `json:"up"`
+}
+
+// 把DataGroup按LayoutConfig整理成图表数据，layout为nil时每条线各占一个画板
+func buildChartModel(dg *DataGroup, layout *LayoutConfig, title string) chartModel {
+	if layout == nil {
+		layout = defaultLayout(dg)
+	}
+
+	m := chartModel{Title: title, Cols: 1}
+	if layout.Style == Layout_Square4 {
+		m.Cols = 2
+	}
+
+	for tag := PointTag_Buy; tag <= PointTag_SellMod2; tag++ {
+		c, up := pointStyle(tag)
+		m.PointStyles = append(m.PointStyles, chartPointStyle{Color: c, Up: up})
+	}
+
+	for i, pc := range layout.Panes {
+		m.Panes = append(m.Panes, buildChartPane(dg, pc))
+		m.Weights = append(m.Weights, 1)
+		if i == 0 && layout.Style == Layout_Single && len(layout.Panes) > 1 {
+			m.Weights[0] = 2
+		}
+	}
+
+	return m
+}
+
+func buildChartPane(dg *DataGroup, pc *PaneConfig) chartPane {
+	p := chartPane{Name: pc.Name}
+	palette := NewColorGroup(len(pc.Lines), 0.7, 0.45)
+	for i, lc := range pc.Lines {
+		dl, ok := dg.lines[lc.Field]
+		if !ok {
+			continue
+		}
+
+		cl := chartLine{
+			Name:   util.ValueIf(len(lc.FieldText) > 0, lc.FieldText, lc.Field),
+			IsMain: lc.IsMain,
+			Times:  dl.Times,
+			Values: dl.Values,
+		}
+		if c, ok := parseColor(lc.LineColorStr); ok {
+			cl.Color = c
+		} else {
+			cl.Color = palette.Colors[i]
+		}
+		p.Lines = append(p.Lines, cl)
+	}
+
+	for _, ptc := range pc.Points {
+		pts, ok := dg.points[ptc.Field]
+		if !ok {
+			continue
+		}
+
+		cp := chartPoints{Name: ptc.Field}
+		for _, pt := range pts {
+			cp.Times = append(cp.Times, pt.Time.UnixMilli())
+			cp.Values = append(cp.Values, pt.Value)
+			cp.Tags = append(cp.Tags, pt.Tag)
+		}
+		p.Points = append(p.Points, cp)
+	}
+
+	for _, tec := range pc.TimeEvents {
+		tes, ok := dg.timeEvents[tec.Field]
+		if !ok {
+			continue
+		}
+
+		ce := chartEvents{Name: tec.Field}
+		for _, te := range tes {
+			ce.Times = append(ce.Times, te.Time.UnixMilli())
+			ce.Colors = append(ce.Colors, te.Color)
+		}
+		p.Events = append(p.Events, ce)
+	}
+
+	return p
+}
+
+// 没有布局时，每条线一个画板，点和事件放在第一个画板
+func defaultLayout(dg *DataGroup) *LayoutConfig {
+	l := NewLayoutConfig(Layout_Row2)
+	for _, name := range sortedKeys(dg.lines) {
+		p := NewPaneConfig(name)
+		p.AddLineConfig(name, Color_RoyalBlue, true, "")
+		l.AddPane(p)
+	}
+
+	if len(l.Panes) == 0 {
+		l.AddPane(NewPaneConfig(""))
+	}
+	for _, name := range sortedKeys(dg.points) {
+		l.Panes[0].AddPointConfig(name, "")
+	}
+	for _, name := range sortedKeys(dg.timeEvents) {
+		l.Panes[0].AddTimeEventConfig(name, "")
+	}
+	return l
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 时间范围，所有画板共用
+func (m *chartModel) timeRange() (t0, t1 int64, ok bool) {
+	t0, t1 = math.MaxInt64, math.MinInt64
+	update := func(ts []int64) {
+		if len(ts) > 0 {
+			t0 = min(t0, ts[0])
+			t1 = max(t1, ts[len(ts)-1])
+		}
+	}
+
+	for _, p := range m.Panes {
+		for _, l := range p.Lines {
+			update(l.Times)
+		}
+		for _, pts := range p.Points {
+			update(pts.Times)
+		}
+		for _, e := range p.Events {
+			update(e.Times)
+		}
+	}
+	return t0, t1, t0 <= t1
+}
+
+// 买卖点的颜色和朝向，html和png共用
+func pointStyle(tag PointTag) (c Color, up bool) {
+	switch tag {
+	case PointTag_Buy:
+		return Color_Green, true
+	case PointTag_Sell:
+		return Color_Red, false
+	case PointTag_BuyMod1:
+		return Color_DarkCyan, true
+	case PointTag_SellMod1:
+		return Color_DarkOrange, false
+	case PointTag_BuyMod2:
+		return Color_Blue, true
+	case PointTag_SellMod2:
+		return Color_Magenta, false
+	default:
+		return Color_Gray, true
+	}
+}
+
+// LineConfig从json加载时只有"r, g, b"格式的颜色字符串
+func parseColor(str string) (Color, bool) {
+	ss := strings.Split(str, ",")
+	if len(ss) != 3 {
+		return Color{}, false
+	}
+
+	vals := [3]int{}
+	for i, s := range ss {
+		v, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return Color{}, false
+		}
+		vals[i] = v
+	}
+	return NewColorFromRGB(vals[0], vals[1], vals[2]), true
+}
+
+// json不支持NaN/Inf，输出为null
+type jsonFloats []float64
+
+func (f jsonFloats) MarshalJSON() ([]byte, error) {
+	sb := strings.Builder{}
+	sb.WriteByte('[')
+	for i, v := range f {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			sb.WriteString("null")
+		} else {
+			sb.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	}
+	sb.WriteByte(']')
+	return []byte(sb.String()), nil
+}
@@ -0,0 +1,49 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 07:41:08
+ * @Description: 把DataGroup导出为单个html文件，数据和绘图脚本都内嵌在页面中，任何平台用浏览器直接打开即可
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package datavisual
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aztecqt/dagger/util"
+)
+
+//go:embed export.html
+var exportHtmlTemplate string
+
+// 写入html。layout为nil时每条线各占一个画板
+func WriteHtml(w io.Writer, dg *DataGroup, layout *LayoutConfig, title string) error {
+	m := buildChartModel(dg, layout, title)
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	// 防止数据中的"</script>"提前结束脚本
+	data := strings.ReplaceAll(string(b), "</", "<\\/")
+	page := strings.Replace(exportHtmlTemplate, "/*#Data#*/null", data, 1)
+	page = strings.ReplaceAll(page, "#Title#", html.EscapeString(title))
+	_, err = io.WriteString(w, page)
+	return err
+}
+
+// 导出为html文件
+func ExportHtml(dg *DataGroup, layout *LayoutConfig, title, path string) error {
+	util.MakeSureDirForFile(path)
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return WriteHtml(file, dg, layout, title)
+}
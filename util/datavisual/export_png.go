@@ -0,0 +1,397 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 07:56:47
+ * @Description: 把DataGroup绘制为静态png，只依赖标准库。用于在linux上查看结果，或者作为图片发到钉钉
+ * 文字使用内置的点阵字体，只能显示ascii字符
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package datavisual
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/dingtalk"
+)
+
+const pngFontScale = 2
+const pngPaneGap = 8
+const pngMarginLeft = 80
+const pngMarginRight = 12
+const pngMarginTop = 22
+const pngMarginBottom = 20
+
+var pngColorBg = color.RGBA{255, 255, 255, 255}
+var pngColorGrid = color.RGBA{235, 235, 235, 255}
+var pngColorFrame = color.RGBA{150, 150, 150, 255}
+var pngColorText = color.RGBA{50, 50, 50, 255}
+
+// 绘制为图片。layout为nil时每条线各占一个画板
+func RenderImage(dg *DataGroup, layout *LayoutConfig, title string, width, height int) *image.RGBA {
+	m := buildChartModel(dg, layout, title)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fillRect(img, img.Rect, pngColorBg)
+
+	top := 0
+	if len(title) > 0 {
+		th := glyphH * pngFontScale * 2
+		drawText(img, (width-textWidth(title, pngFontScale*2))/2, 8, title, pngColorText, pngFontScale*2)
+		top = th + 16
+	}
+
+	t0, t1, ok := m.timeRange()
+	if !ok || len(m.Panes) == 0 {
+		return img
+	}
+	if t0 == t1 {
+		t1 = t0 + 1
+	}
+
+	for i, r := range paneRects(&m, image.Rect(0, top, width, height)) {
+		renderPane(img, &m.Panes[i], r, t0, t1, m.PointStyles)
+	}
+	return img
+}
+
+func WritePng(w io.Writer, dg *DataGroup, layout *LayoutConfig, title string, width, height int) error {
+	return png.Encode(w, RenderImage(dg, layout, title, width, height))
+}
+
+// 导出为png文件
+func ExportPng(dg *DataGroup, layout *LayoutConfig, title, path string, width, height int) error {
+	util.MakeSureDirForFile(path)
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return WritePng(file, dg, layout, title, width, height)
+}
+
+// 绘制为png并通过钉钉工作消息发送
+func SendPngByDingTalk(n *dingtalk.Notifier, dg *DataGroup, layout *LayoutConfig, title string, width, height int, mobs ...int64) error {
+	file, err := os.CreateTemp("", "datavisual-*.png")
+	if err != nil {
+		return err
+	}
+	path := file.Name()
+	defer os.Remove(path)
+
+	err = WritePng(file, dg, layout, title, width, height)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	mediaId, ok := n.UploadMediaFile(path, "image")
+	if !ok {
+		return errors.New("upload image failed")
+	}
+	if n.SendImageByMob(mediaId, mobs...) == nil {
+		return errors.New("no dingtalk user found")
+	}
+	return nil
+}
+
+// #region 布局
+func paneRects(m *chartModel, area image.Rectangle) []image.Rectangle {
+	n := len(m.Panes)
+	rows := (n + m.Cols - 1) / m.Cols
+	colW := (area.Dx() - pngPaneGap*(m.Cols-1)) / m.Cols
+
+	// 每行的高度按该行画板的最大权重分配
+	rowWeights := make([]int, rows)
+	totalWeight := 0
+	for i := range m.Panes {
+		r := i / m.Cols
+		rowWeights[r] = max(rowWeights[r], m.Weights[i])
+	}
+	for _, w := range rowWeights {
+		totalWeight += w
+	}
+
+	rects := make([]image.Rectangle, 0, n)
+	rowY := make([]int, rows+1)
+	rowY[0] = area.Min.Y
+	avail := area.Dy() - pngPaneGap*(rows-1)
+	for r := 0; r < rows; r++ {
+		rowY[r+1] = rowY[r] + avail*rowWeights[r]/totalWeight + pngPaneGap
+	}
+
+	for i := range m.Panes {
+		r, c := i/m.Cols, i%m.Cols
+		x0 := area.Min.X + c*(colW+pngPaneGap)
+		rects = append(rects, image.Rect(x0, rowY[r], x0+colW, rowY[r+1]-pngPaneGap))
+	}
+	return rects
+}
+
+// #endregion
+
+// #region 画板
+func renderPane(img *image.RGBA, p *chartPane, r image.Rectangle, t0, t1 int64, styles []chartPointStyle) {
+	plot := image.Rect(r.Min.X+pngMarginLeft, r.Min.Y+pngMarginTop, r.Max.X-pngMarginRight, r.Max.Y-pngMarginBottom)
+	if plot.Dx() <= 0 || plot.Dy() <= 0 {
+		return
+	}
+
+	// y范围
+	lo, hi := math.Inf(1), math.Inf(-1)
+	scan := func(vs []float64) {
+		for _, v := range vs {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+	}
+	for _, l := range p.Lines {
+		scan(l.Values)
+	}
+	for _, pts := range p.Points {
+		scan(pts.Values)
+	}
+	if lo > hi {
+		lo, hi = 0, 1
+	} else if lo == hi {
+		lo, hi = lo-1, hi+1
+	} else {
+		pad := (hi - lo) * 0.05
+		lo, hi = lo-pad, hi+pad
+	}
+
+	X := func(t int64) int {
+		return plot.Min.X + int(float64(t-t0)/float64(t1-t0)*float64(plot.Dx()-1))
+	}
+	Y := func(v float64) int {
+		return plot.Min.Y + int((1-(v-lo)/(hi-lo))*float64(plot.Dy()-1))
+	}
+
+	// 网格和刻度
+	textH := glyphH * pngFontScale
+	for _, v := range niceTicks(lo, hi, 5) {
+		y := Y(v)
+		hline(img, plot.Min.X, plot.Max.X, y, pngColorGrid, plot)
+		label := formatTick(v, hi-lo)
+		drawText(img, plot.Min.X-6-textWidth(label, pngFontScale), y-textH/2, label, pngColorText, pngFontScale)
+	}
+	step := timeTickStep(t1-t0, plot.Dx()/140)
+	for t := (t0/step + 1) * step; t < t1; t += step {
+		x := X(t)
+		vline(img, x, plot.Min.Y, plot.Max.Y, pngColorGrid, plot)
+		label := formatTimeTick(t, t1-t0)
+		drawText(img, x-textWidth(label, pngFontScale)/2, plot.Max.Y+5, label, pngColorText, pngFontScale)
+	}
+
+	// 时间事件
+	for _, e := range p.Events {
+		for i, t := range e.Times {
+			vline(img, X(t), plot.Min.Y, plot.Max.Y, e.Colors[i].toRGBA(), plot)
+		}
+	}
+
+	// 线。相邻点之间连线，同一列像素内的多个点自然形成竖线
+	for _, l := range p.Lines {
+		c := l.Color.toRGBA()
+		thick := util.ValueIf(l.IsMain, 2, 1)
+		px, py, pen := 0, 0, false
+		for i, t := range l.Times {
+			v := l.Values[i]
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				pen = false
+				continue
+			}
+			x, y := X(t), Y(v)
+			if pen {
+				line(img, px, py, x, y, c, thick, plot)
+			} else {
+				setClipped(img, x, y, c, plot)
+			}
+			px, py, pen = x, y, true
+		}
+	}
+
+	// 买卖点
+	for _, pts := range p.Points {
+		for i, t := range pts.Times {
+			v := pts.Values[i]
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			st := chartPointStyle{Color: Color_Gray, Up: true}
+			if int(pts.Tags[i]) < len(styles) {
+				st = styles[pts.Tags[i]]
+			}
+			triangle(img, X(t), Y(v), 5, st.Up, st.Color.toRGBA(), plot)
+		}
+	}
+
+	// 边框
+	frame := image.Rect(plot.Min.X-1, plot.Min.Y-1, plot.Max.X, plot.Max.Y)
+	hline(img, frame.Min.X, frame.Max.X, frame.Min.Y, pngColorFrame, img.Rect)
+	hline(img, frame.Min.X, frame.Max.X, frame.Max.Y, pngColorFrame, img.Rect)
+	vline(img, frame.Min.X, frame.Min.Y, frame.Max.Y, pngColorFrame, img.Rect)
+	vline(img, frame.Max.X, frame.Min.Y, frame.Max.Y, pngColorFrame, img.Rect)
+
+	// 画板名和图例
+	lx, ly := plot.Min.X, r.Min.Y+(pngMarginTop-textH)/2
+	if len(p.Name) > 0 {
+		drawText(img, lx, ly, p.Name, pngColorText, pngFontScale)
+		lx += textWidth(p.Name, pngFontScale) + 16
+	}
+	for _, l := range p.Lines {
+		fillRect(img, image.Rect(lx, ly+textH/2-2, lx+14, ly+textH/2+2), l.Color.toRGBA())
+		drawText(img, lx+18, ly, l.Name, l.Color.toRGBA(), pngFontScale)
+		lx += textWidth(l.Name, pngFontScale) + 34
+	}
+}
+
+// #endregion
+
+// #region 刻度
+func niceTicks(lo, hi float64, n int) []float64 {
+	span := hi - lo
+	if span <= 0 || n <= 0 {
+		return []float64{lo}
+	}
+
+	step := niceStep(span / float64(n))
+	ticks := []float64{}
+	for v := math.Ceil(lo/step) * step; v <= hi; v += step {
+		ticks = append(ticks, v)
+	}
+	return ticks
+}
+
+func niceStep(raw float64) float64 {
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, k := range []float64{1, 2, 5, 10} {
+		if k*mag >= raw {
+			return k * mag
+		}
+	}
+	return 10 * mag
+}
+
+func formatTick(v, span float64) string {
+	a := math.Abs(v)
+	if a >= 1e6 || (a > 0 && a < 1e-4) {
+		return fmt.Sprintf("%.2e", v)
+	}
+
+	decimals := max(0, int(-math.Floor(math.Log10(niceStep(span/5)))))
+	return fmt.Sprintf("%.*f", decimals, v)
+}
+
+var timeTickSteps = []time.Duration{
+	time.Second, time.Second * 5, time.Second * 15, time.Second * 30,
+	time.Minute, time.Minute * 5, time.Minute * 15, time.Minute * 30,
+	time.Hour, time.Hour * 2, time.Hour * 4, time.Hour * 6, time.Hour * 12,
+	time.Hour * 24, time.Hour * 48, time.Hour * 24 * 7, time.Hour * 24 * 30, time.Hour * 24 * 90, time.Hour * 24 * 365,
+}
+
+// 时间刻度间隔(ms)
+func timeTickStep(spanMs int64, maxTicks int) int64 {
+	maxTicks = max(maxTicks, 2)
+	for _, d := range timeTickSteps {
+		if spanMs/d.Milliseconds() <= int64(maxTicks) {
+			return d.Milliseconds()
+		}
+	}
+	return timeTickSteps[len(timeTickSteps)-1].Milliseconds()
+}
+
+func formatTimeTick(ms, spanMs int64) string {
+	t := time.UnixMilli(ms)
+	if spanMs > (time.Hour * 24 * 5).Milliseconds() {
+		return t.Format("2006-01-02")
+	} else if spanMs > (time.Hour * 24).Milliseconds() {
+		return t.Format("01-02 15:04")
+	} else {
+		return t.Format("15:04:05")
+	}
+}
+
+// #endregion
+
+// #region 绘图
+func (c Color) toRGBA() color.RGBA {
+	return color.RGBA{uint8(c.R), uint8(c.G), uint8(c.B), 255}
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func setClipped(img *image.RGBA, x, y int, c color.RGBA, clip image.Rectangle) {
+	if image.Pt(x, y).In(clip) {
+		img.SetRGBA(x, y, c)
+	}
+}
+
+func hline(img *image.RGBA, x0, x1, y int, c color.RGBA, clip image.Rectangle) {
+	for x := x0; x <= x1; x++ {
+		setClipped(img, x, y, c, clip)
+	}
+}
+
+func vline(img *image.RGBA, x, y0, y1 int, c color.RGBA, clip image.Rectangle) {
+	for y := y0; y <= y1; y++ {
+		setClipped(img, x, y, c, clip)
+	}
+}
+
+// Bresenham画线，thick为2时向下加粗一个像素
+func line(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA, thick int, clip image.Rectangle) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := util.ValueIf(x0 < x1, 1, -1), util.ValueIf(y0 < y1, 1, -1)
+	e := dx + dy
+	for {
+		for k := 0; k < thick; k++ {
+			setClipped(img, x0, y0+k, c, clip)
+		}
+		if x0 == x1 && y0 == y1 {
+			break
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// 实心三角形，顶点在(x, y)。up表示三角形在点的下方、尖朝上
+func triangle(img *image.RGBA, x, y, size int, up bool, c color.RGBA, clip image.Rectangle) {
+	h := size * 2
+	for i := 0; i <= h; i++ {
+		half := i * size / h
+		yy := util.ValueIf(up, y+i, y-i)
+		hline(img, x-half, x+half, yy, c, clip)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// #endregion
@@ -0,0 +1,103 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 07:48:13
+ * @Description: png导出用的3x5点阵字体，只包含数字、大写字母和常用符号。小写字母按大写绘制，其他字符绘制为?
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package datavisual
+
+import (
+	"image"
+	"image/color"
+	"unicode"
+)
+
+const glyphW = 3
+const glyphH = 5
+
+// 每行3位，高位在左
+var glyphs = map[rune][glyphH]uint8{
+	'0': {0b111, 0b101, 0b101, 0b101, 0b111},
+	'1': {0b010, 0b110, 0b010, 0b010, 0b111},
+	'2': {0b111, 0b001, 0b111, 0b100, 0b111},
+	'3': {0b111, 0b001, 0b111, 0b001, 0b111},
+	'4': {0b101, 0b101, 0b111, 0b001, 0b001},
+	'5': {0b111, 0b100, 0b111, 0b001, 0b111},
+	'6': {0b111, 0b100, 0b111, 0b101, 0b111},
+	'7': {0b111, 0b001, 0b001, 0b001, 0b001},
+	'8': {0b111, 0b101, 0b111, 0b101, 0b111},
+	'9': {0b111, 0b101, 0b111, 0b001, 0b111},
+	'A': {0b010, 0b101, 0b111, 0b101, 0b101},
+	'B': {0b110, 0b101, 0b110, 0b101, 0b110},
+	'C': {0b011, 0b100, 0b100, 0b100, 0b011},
+	'D': {0b110, 0b101, 0b101, 0b101, 0b110},
+	'E': {0b111, 0b100, 0b110, 0b100, 0b111},
+	'F': {0b111, 0b100, 0b110, 0b100, 0b100},
+	'G': {0b011, 0b100, 0b101, 0b101, 0b011},
+	'H': {0b101, 0b101, 0b111, 0b101, 0b101},
+	'I': {0b111, 0b010, 0b010, 0b010, 0b111},
+	'J': {0b001, 0b001, 0b001, 0b101, 0b010},
+	'K': {0b101, 0b101, 0b110, 0b101, 0b101},
+	'L': {0b100, 0b100, 0b100, 0b100, 0b111},
+	'M': {0b101, 0b111, 0b111, 0b101, 0b101},
+	'N': {0b110, 0b101, 0b101, 0b101, 0b101},
+	'O': {0b010, 0b101, 0b101, 0b101, 0b010},
+	'P': {0b110, 0b101, 0b110, 0b100, 0b100},
+	'Q': {0b010, 0b101, 0b101, 0b110, 0b011},
+	'R': {0b110, 0b101, 0b110, 0b101, 0b101},
+	'S': {0b011, 0b100, 0b010, 0b001, 0b110},
+	'T': {0b111, 0b010, 0b010, 0b010, 0b010},
+	'U': {0b101, 0b101, 0b101, 0b101, 0b111},
+	'V': {0b101, 0b101, 0b101, 0b101, 0b010},
+	'W': {0b101, 0b101, 0b111, 0b111, 0b101},
+	'X': {0b101, 0b101, 0b010, 0b101, 0b101},
+	'Y': {0b101, 0b101, 0b010, 0b010, 0b010},
+	'Z': {0b111, 0b001, 0b010, 0b100, 0b111},
+	'.': {0b000, 0b000, 0b000, 0b000, 0b010},
+	',': {0b000, 0b000, 0b000, 0b010, 0b100},
+	':': {0b000, 0b010, 0b000, 0b010, 0b000},
+	'-': {0b000, 0b000, 0b111, 0b000, 0b000},
+	'+': {0b000, 0b010, 0b111, 0b010, 0b000},
+	'=': {0b000, 0b111, 0b000, 0b111, 0b000},
+	'_': {0b000, 0b000, 0b000, 0b000, 0b111},
+	'/': {0b001, 0b001, 0b010, 0b100, 0b100},
+	'%': {0b101, 0b001, 0b010, 0b100, 0b101},
+	'(': {0b001, 0b010, 0b010, 0b010, 0b001},
+	')': {0b100, 0b010, 0b010, 0b010, 0b100},
+	'?': {0b111, 0b001, 0b010, 0b000, 0b010},
+	' ': {},
+}
+
+// 文字宽度（像素）
+func textWidth(s string, scale int) int {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0
+	}
+	return (n*(glyphW+1) - 1) * scale
+}
+
+// 在(x, y)处绘制文字，(x, y)为左上角
+func drawText(img *image.RGBA, x, y int, s string, c color.Color, scale int) {
+	for _, r := range s {
+		g, ok := glyphs[unicode.ToUpper(r)]
+		if !ok {
+			g = glyphs['?']
+		}
+
+		for row := 0; row < glyphH; row++ {
+			for col := 0; col < glyphW; col++ {
+				if g[row]&(1<<(glyphW-1-col)) == 0 {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						img.Set(x+col*scale+dx, y+row*scale+dy, c)
+					}
+				}
+			}
+		}
+		x += (glyphW + 1) * scale
+	}
+}
@@ -340,6 +340,7 @@ func GenerateLayoutGroupConfig(dir string) {
 	util.ObjectToFile(fmt.Sprintf("%s/layout_group.json", dir), "{}")
 }
 
+// 仅windows可用。其他平台使用ExportHtml/ExportPng
 func LaunchVisualTool(dir string) {
 	cmd := exec.Command("CommonDataViewer.exe", dir)
 	go cmd.Run()
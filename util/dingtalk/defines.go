@@ -60,6 +60,16 @@ type sendLinkMsgReq struct {
 	} `json:"msg"`
 }
 
+type sendImageMsgReq struct {
+	sendMsgReq
+	Msg struct {
+		MsgType string `json:"msgtype"`
+		Image   struct {
+			MediaId string `json:"media_id"`
+		} `json:"image"`
+	} `json:"msg"`
+}
+
 type actionCardButton struct {
 	Title string `json:"title"`
 	Url   string `json:"action_url"`
@@ -104,11 +114,6 @@ type sendResultResp struct {
 	commonResp
 }
 
-type uploadMediaReq struct {
-	Type  string `json:"type"`
-	Media string `json:"media"`
-}
-
 type uploadMediaResp struct {
 	commonResp
 	Type    string `json:"type"`
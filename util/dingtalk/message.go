@@ -42,6 +42,7 @@ type Message struct {
 	urlUrl               string
 	urlPic               string
 	urlTitle             string
+	mediaId              string
 	status               MessageStatus
 	errCount             int
 }
@@ -93,6 +94,17 @@ func (m *Message) intiAsLink(ntf *Notifier, userid string, url, pic, title, text
 	logger.LogInfo(m.logPrefix, "init as url, title=%s, url=%s", title, url)
 }
 
+func (m *Message) initAsImage(ntf *Notifier, userid string, mediaId string) {
+	m.index = msgIndex
+	m.logPrefix = fmt.Sprintf("dingtalk-image-msg-%d", m.index)
+	msgIndex++
+	m.ntf = ntf
+	m.userid = userid
+	m.msgType = "image"
+	m.mediaId = mediaId
+	logger.LogInfo(m.logPrefix, "init as image, media_id=%s", mediaId)
+}
+
 // 发送消息
 // 如果发送失败，停一会儿再发
 // 如果发送成功，追踪发送结果并输出日志
@@ -166,6 +178,8 @@ func (m *Message) dosend() bool {
 		taskId = m.ntf.sendLinkMessage(m.userid, m.urlUrl, m.urlPic, m.urlTitle, m.text)
 	} else if m.msgType == "action_card" {
 		taskId = m.ntf.sendActionCardMessage(m.userid, m.actionButtonTitle, m.actionButtonMarkdown, m.actionCardBtns)
+	} else if m.msgType == "image" {
+		taskId = m.ntf.sendImageMessage(m.userid, m.mediaId)
 	} else {
 		logger.LogImportant(m.logPrefix, "unknown msg type: %s", m.msgType)
 		return false
@@ -10,10 +10,14 @@
 package dingtalk
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -155,6 +159,32 @@ func (n *Notifier) SendLinkByUid(url, pic, title, text string, uids ...string) *
 	return nil
 }
 
+// 发送图片，mediaId由UploadMediaFile获得
+func (n *Notifier) SendImageByMob(mediaId string, mobs ...int64) *Message {
+	uids := make([]string, 0)
+	for _, mob := range mobs {
+		uid, ok := n.mobile2UserId(mob)
+		if ok {
+			uids = append(uids, uid)
+		}
+	}
+
+	return n.SendImageByUid(mediaId, uids...)
+}
+
+func (n *Notifier) SendImageByUid(mediaId string, uids ...string) *Message {
+	if len(uids) > 0 {
+		m := new(Message)
+		uidstr := strings.Join(uids, ",")
+		m.initAsImage(n, uidstr, mediaId)
+		logger.LogInfo(n.logPrefix, "sending image msg to user, userid=%s, media_id=%s", uidstr, mediaId)
+		go m.send()
+		return m
+	}
+
+	return nil
+}
+
 func (n *Notifier) mobile2UserId(mob int64) (string, bool) {
 	if uid, ok := n.mob2uid[mob]; ok {
 		return uid, true
@@ -291,6 +321,38 @@ func (n *Notifier) sendLinkMessage(uid string, messageUrl, picUrl, title, text s
 	}
 }
 
+func (n *Notifier) sendImageMessage(uid string, mediaId string) int64 {
+	defer util.DefaultRecover()
+	action := "/topapi/message/corpconversation/asyncsend_v2"
+	method := "POST"
+	params := url.Values{}
+	params.Set("access_token", n.accessToken)
+	action = action + "?" + params.Encode()
+	url := rootURL + action
+
+	req := sendImageMsgReq{}
+	req.AgentId = n.agentId
+	req.UseridList = uid
+	req.ToAllUser = false
+	req.Msg.MsgType = "image"
+	req.Msg.Image.MediaId = mediaId
+
+	b, _ := json.Marshal(req)
+	postStr := string(b)
+
+	resp, err := network.ParseHttpResult[sendMsgResp](n.logPrefix, "sendImageMessage", url, method, postStr, network.JsonHeaders(), nil, nil)
+	if err == nil {
+		if resp.ErrorCode == 0 {
+			return resp.TaskId
+		} else {
+			logger.LogImportant(n.logPrefix, "send image msg failed, errCode=%d, errMsg=%s", resp.ErrorCode, resp.ErrorMsg)
+			return 0
+		}
+	} else {
+		return 0
+	}
+}
+
 // actionButton的拆分逻辑：每一份长度不超过1000
 func splitActionCardTextAndUrls(textAndUrls []string) [][]actionCardButton {
 	groups := [][]actionCardButton{}
@@ -419,6 +481,7 @@ func (n *Notifier) sendResult(taskId int64) (result string, ok bool) {
 	return
 }
 
+// 上传媒体文件，fileType为image/voice/video/file。成功时result为media_id
 func (n *Notifier) UploadMediaFile(filePath, fileType string) (result string, ok bool) {
 	defer util.DefaultRecover()
 	action := "/media/upload"
@@ -429,18 +492,20 @@ func (n *Notifier) UploadMediaFile(filePath, fileType string) (result string, ok
 	action = action + "?" + params.Encode()
 	url := rootURL + action
 
-	req := uploadMediaReq{
-		Type:  fileType,
-		Media: filePath,
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		logger.LogImportant(n.logPrefix, "read media file failed: %s", err.Error())
+		return
 	}
-	b, _ := json.Marshal(req)
-	postStr := string(b)
 
-	headers := make(map[string]string)
-	boundary := "BOUNDARY"
-	headers["Content-Type"] = fmt.Sprintf("multipart/from-data; boundary=%s\r\n--%s\r\nContent-Disposition: form-data; name=\"media\"; filename=\"%s\"\r\n--%s--\r\n", boundary, boundary, filePath, boundary)
-	fmt.Println(headers["Content-Type"])
-	resp, err := network.ParseHttpResult[uploadMediaResp](n.logPrefix, "uploadMediaFile", url, method, postStr, headers, func(resp *http.Response, body []byte) {
+	body := bytes.Buffer{}
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("media", filepath.Base(filePath))
+	part.Write(content)
+	writer.Close()
+
+	headers := map[string]string{"Content-Type": writer.FormDataContentType()}
+	resp, err := network.ParseHttpResult[uploadMediaResp](n.logPrefix, "uploadMediaFile", url, method, body.String(), headers, func(resp *http.Response, body []byte) {
 		result = string(body)
 	}, nil)
 	if err == nil {
@@ -0,0 +1,187 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 17:58:33
+ * @Description: 平均趋向指标ADX（流式），同时输出+DI和-DI。输入为K线
+ * 计算过程与talib一致：DM和TR先累加n-1根，之后按 s = s - s/n + x 平滑
+ * 再取n个DX的平均作为第一个ADX，之后对DX做Wilder平滑
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import (
+	"math"
+
+	"github.com/aztecqt/dagger/framework"
+)
+
+// ADX的计算状态，只包含标量，可以直接复制用于试算
+type adxState struct {
+	n         int
+	count     int // 已处理的K线数量（不含第一根）
+	hasPrev   bool
+	prevHigh  float64
+	prevLow   float64
+	prevClose float64
+	sPlusDM   float64
+	sMinusDM  float64
+	sTR       float64
+	sumDX     float64
+	adx       float64
+}
+
+type adxResult struct {
+	adx, plusDI, minusDI float64
+	diOk, adxOk          bool
+}
+
+func (s adxState) next(b bar) (adxState, adxResult) {
+	r := adxResult{}
+	if !s.hasPrev {
+		s.hasPrev = true
+		s.prevHigh, s.prevLow, s.prevClose = b.h, b.l, b.c
+		return s, r
+	}
+
+	// 趋向变动
+	plusDM, minusDM := 0.0, 0.0
+	diffP := b.h - s.prevHigh
+	diffM := s.prevLow - b.l
+	if diffM > 0 && diffP < diffM {
+		minusDM = diffM
+	} else if diffP > 0 && diffP > diffM {
+		plusDM = diffP
+	}
+	tr := trueRange(b, s.prevClose)
+	s.prevHigh, s.prevLow, s.prevClose = b.h, b.l, b.c
+	s.count++
+
+	nf := float64(s.n)
+	if s.count < s.n {
+		s.sPlusDM += plusDM
+		s.sMinusDM += minusDM
+		s.sTR += tr
+		return s, r
+	}
+
+	s.sPlusDM = s.sPlusDM - s.sPlusDM/nf + plusDM
+	s.sMinusDM = s.sMinusDM - s.sMinusDM/nf + minusDM
+	s.sTR = s.sTR - s.sTR/nf + tr
+
+	dx := 0.0
+	if s.sTR != 0 {
+		r.plusDI = 100 * s.sPlusDM / s.sTR
+		r.minusDI = 100 * s.sMinusDM / s.sTR
+		r.diOk = true
+		if sum := r.plusDI + r.minusDI; sum != 0 {
+			dx = 100 * math.Abs(r.plusDI-r.minusDI) / sum
+		}
+	}
+
+	if s.count < 2*s.n-1 {
+		s.sumDX += dx
+	} else if s.count == 2*s.n-1 {
+		s.sumDX += dx
+		s.adx = s.sumDX / nf
+		r.adxOk = true
+	} else {
+		s.adx = (s.adx*(nf-1) + dx) / nf
+		r.adxOk = true
+	}
+
+	r.adx = s.adx
+	return s, r
+}
+
+type ADX struct {
+	kl         *KLines
+	adx        *framework.DataLine
+	plusDI     *framework.DataLine
+	minusDI    *framework.DataLine
+	state      adxState
+	feeder     lineFeeder
+	n          int
+	ready      bool
+	rebuilding bool
+}
+
+func NewADX(kl *KLines, n int) *ADX {
+	if n < 1 {
+		n = 1
+	}
+
+	a := new(ADX)
+	a.kl = kl
+	a.n = n
+	a.adx = kl.newOutput("adx")
+	a.plusDI = kl.newOutput("+di")
+	a.minusDI = kl.newOutput("-di")
+	a.state = adxState{n: n}
+	return a
+}
+
+func (a *ADX) Value() *framework.DataLine {
+	return a.adx
+}
+
+func (a *ADX) PlusDI() *framework.DataLine {
+	return a.plusDI
+}
+
+func (a *ADX) MinusDI() *framework.DataLine {
+	return a.minusDI
+}
+
+func (a *ADX) Ready() bool {
+	return a.ready
+}
+
+func (a *ADX) WarmUpPeriod() int {
+	return 2 * a.n
+}
+
+func (a *ADX) write(ts int64, r adxResult) {
+	if r.diOk {
+		a.plusDI.Update(ts, r.plusDI)
+		a.minusDI.Update(ts, r.minusDI)
+	}
+
+	if r.adxOk {
+		a.adx.Update(ts, r.adx)
+	}
+}
+
+func (a *ADX) update() {
+	a.feeder.feed(
+		a.kl.Close,
+		func(i int) {
+			var r adxResult
+			a.state, r = a.state.next(a.kl.bar(i))
+			if r.adxOk {
+				a.ready = true
+			}
+			a.write(a.kl.Close.Times[i], r)
+		},
+		func(i int) {
+			_, r := a.state.next(a.kl.bar(i))
+			a.write(a.kl.Close.Times[i], r)
+		})
+}
+
+func (a *ADX) Update() {
+	if a.rebuilding {
+		return
+	}
+	a.update()
+}
+
+func (a *ADX) Rebuild() {
+	a.rebuilding = true
+	a.state = adxState{n: a.n}
+	a.feeder.reset()
+	a.adx.Clear()
+	a.plusDI.Clear()
+	a.minusDI.Clear()
+	a.ready = false
+	a.update()
+	a.rebuilding = false
+}
@@ -0,0 +1,106 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 17:40:16
+ * @Description: 平均真实波幅ATR（流式），输入为K线
+ * 与talib一致：第一根K线没有前收盘价，不参与计算，之后对真实波幅做Wilder平滑
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import (
+	"math"
+
+	"github.com/aztecqt/dagger/framework"
+)
+
+// ATR的计算状态，只包含标量，可以直接复制用于试算
+type atrState struct {
+	n         int
+	hasPrev   bool
+	prevClose float64
+	w         wilderCore
+}
+
+func (s atrState) next(b bar) (atrState, float64, bool) {
+	if !s.hasPrev {
+		s.hasPrev = true
+		s.prevClose = b.c
+		return s, 0, false
+	}
+
+	var ok bool
+	s.w.count, s.w.v, ok = s.w.next(trueRange(b, s.prevClose))
+	s.prevClose = b.c
+	return s, s.w.v, ok
+}
+
+func trueRange(b bar, prevClose float64) float64 {
+	return math.Max(b.h-b.l, math.Max(math.Abs(b.h-prevClose), math.Abs(b.l-prevClose)))
+}
+
+type ATR struct {
+	kl         *KLines
+	value      *framework.DataLine
+	state      atrState
+	feeder     lineFeeder
+	n          int
+	ready      bool
+	rebuilding bool
+}
+
+func NewATR(kl *KLines, n int) *ATR {
+	a := new(ATR)
+	a.kl = kl
+	a.n = n
+	a.value = kl.newOutput("atr")
+	a.state = atrState{n: n, w: *newWilderCore(n)}
+	return a
+}
+
+func (a *ATR) Value() *framework.DataLine {
+	return a.value
+}
+
+func (a *ATR) Ready() bool {
+	return a.ready
+}
+
+func (a *ATR) WarmUpPeriod() int {
+	return a.n + 1
+}
+
+func (a *ATR) update() {
+	a.feeder.feed(
+		a.kl.Close,
+		func(i int) {
+			var v float64
+			var ok bool
+			a.state, v, ok = a.state.next(a.kl.bar(i))
+			if ok {
+				a.ready = true
+				a.value.Update(a.kl.Close.Times[i], v)
+			}
+		},
+		func(i int) {
+			if _, v, ok := a.state.next(a.kl.bar(i)); ok {
+				a.value.Update(a.kl.Close.Times[i], v)
+			}
+		})
+}
+
+func (a *ATR) Update() {
+	if a.rebuilding {
+		return
+	}
+	a.update()
+}
+
+func (a *ATR) Rebuild() {
+	a.rebuilding = true
+	a.state = atrState{n: a.n, w: *newWilderCore(a.n)}
+	a.feeder.reset()
+	a.value.Clear()
+	a.ready = false
+	a.update()
+	a.rebuilding = false
+}
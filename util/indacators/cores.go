@@ -0,0 +1,424 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 16:31:12
+ * @Description: 流式指标的计算核心。每个核心都是O(1)更新的
+ * 预热方式与talib保持一致，便于对照
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import "math"
+
+// #region EMA
+// 前n个值用SMA作为种子，之后 ema = ema + (x - ema) * 2 / (n + 1)
+type emaCore struct {
+	n     int
+	k     float64
+	count int
+	sum   float64
+	ema   float64
+}
+
+func newEmaCore(n int) *emaCore {
+	if n < 1 {
+		n = 1
+	}
+	return &emaCore{n: n, k: 2.0 / float64(n+1)}
+}
+
+func (c *emaCore) next(x float64) (count int, sum, ema float64, ok bool) {
+	count = c.count + 1
+	if count < c.n {
+		return count, c.sum + x, 0, false
+	} else if count == c.n {
+		sum = c.sum + x
+		return count, sum, sum / float64(c.n), true
+	} else {
+		return count, 0, c.ema + (x-c.ema)*c.k, true
+	}
+}
+
+func (c *emaCore) push(x float64) (float64, bool) {
+	var ok bool
+	c.count, c.sum, c.ema, ok = c.next(x)
+	return c.ema, ok
+}
+
+func (c *emaCore) peek(x float64) (float64, bool) {
+	_, _, ema, ok := c.next(x)
+	return ema, ok
+}
+
+func (c *emaCore) reset() {
+	c.count = 0
+	c.sum = 0
+	c.ema = 0
+}
+
+// #endregion
+
+// #region WMA
+// 权重依次为1..n，最新的值权重最大
+// 维护 total = sum(x) 和 num = sum(w*x)，新值进入时 num' = num + n*x - total
+type wmaCore struct {
+	n     int
+	r     *ring
+	total float64
+	num   float64
+	denom float64
+}
+
+func newWmaCore(n int) *wmaCore {
+	if n < 1 {
+		n = 1
+	}
+	return &wmaCore{n: n, r: newRing(n), denom: float64(n*(n+1)) / 2}
+}
+
+func (c *wmaCore) next(x float64) (total, num float64, ok bool) {
+	if c.r.full() {
+		// 窗口已满：所有旧值权重减1，最旧的值移出
+		num = c.num + float64(c.n)*x - c.total
+		total = c.total + x - c.r.oldest()
+		return total, num, true
+	}
+
+	// 窗口未满：新值权重为当前个数+1
+	num = c.num + float64(c.r.size+1)*x
+	total = c.total + x
+	return total, num, c.r.size+1 == c.n
+}
+
+func (c *wmaCore) push(x float64) (float64, bool) {
+	var ok bool
+	c.total, c.num, ok = c.next(x)
+	c.r.push(x)
+	return c.num / c.denom, ok
+}
+
+func (c *wmaCore) peek(x float64) (float64, bool) {
+	_, num, ok := c.next(x)
+	return num / c.denom, ok
+}
+
+func (c *wmaCore) reset() {
+	c.r.reset()
+	c.total = 0
+	c.num = 0
+}
+
+// #endregion
+
+// #region RSI
+// Wilder平滑。前n个涨跌幅取简单平均，之后 avg = (avg * (n-1) + x) / n
+type rsiCore struct {
+	n       int
+	count   int // 已处理的涨跌幅个数
+	hasPrev bool
+	prev    float64
+	avgGain float64
+	avgLoss float64
+}
+
+func newRsiCore(n int) *rsiCore {
+	if n < 1 {
+		n = 1
+	}
+	return &rsiCore{n: n}
+}
+
+func (c *rsiCore) next(x float64) (count int, avgGain, avgLoss, rsi float64, ok bool) {
+	if !c.hasPrev {
+		return 0, 0, 0, 0, false
+	}
+
+	gain := math.Max(x-c.prev, 0)
+	loss := math.Max(c.prev-x, 0)
+	count = c.count + 1
+	nf := float64(c.n)
+	if count <= c.n {
+		avgGain = c.avgGain + gain/nf
+		avgLoss = c.avgLoss + loss/nf
+	} else {
+		avgGain = (c.avgGain*(nf-1) + gain) / nf
+		avgLoss = (c.avgLoss*(nf-1) + loss) / nf
+	}
+
+	if count < c.n {
+		return count, avgGain, avgLoss, 0, false
+	}
+
+	if avgGain+avgLoss == 0 {
+		rsi = 0
+	} else {
+		rsi = 100 * avgGain / (avgGain + avgLoss)
+	}
+	return count, avgGain, avgLoss, rsi, true
+}
+
+func (c *rsiCore) push(x float64) (float64, bool) {
+	count, avgGain, avgLoss, rsi, ok := c.next(x)
+	if c.hasPrev {
+		c.count, c.avgGain, c.avgLoss = count, avgGain, avgLoss
+	}
+	c.prev = x
+	c.hasPrev = true
+	return rsi, ok
+}
+
+func (c *rsiCore) peek(x float64) (float64, bool) {
+	_, _, _, rsi, ok := c.next(x)
+	return rsi, ok
+}
+
+func (c *rsiCore) reset() {
+	c.count = 0
+	c.hasPrev = false
+	c.avgGain = 0
+	c.avgLoss = 0
+}
+
+// #endregion
+
+// #region Wilder平滑
+// 前n个值取简单平均，之后 v = (v * (n-1) + x) / n。用于ATR、ADX
+type wilderCore struct {
+	n     int
+	count int
+	v     float64
+}
+
+func newWilderCore(n int) *wilderCore {
+	if n < 1 {
+		n = 1
+	}
+	return &wilderCore{n: n}
+}
+
+func (c *wilderCore) next(x float64) (int, float64, bool) {
+	count := c.count + 1
+	nf := float64(c.n)
+	if count <= c.n {
+		return count, c.v + x/nf, count == c.n
+	}
+	return count, (c.v*(nf-1) + x) / nf, true
+}
+
+func (c *wilderCore) push(x float64) (float64, bool) {
+	var ok bool
+	c.count, c.v, ok = c.next(x)
+	return c.v, ok
+}
+
+func (c *wilderCore) peek(x float64) (float64, bool) {
+	_, v, ok := c.next(x)
+	return v, ok
+}
+
+func (c *wilderCore) reset() {
+	c.count = 0
+	c.v = 0
+}
+
+// #endregion
+
+// #region 滚动均值/方差
+// 维护窗口内的sum和sumSq
+type rollingStatCore struct {
+	r     *ring
+	sum   float64
+	sumSq float64
+}
+
+func newRollingStatCore(n int) *rollingStatCore {
+	return &rollingStatCore{r: newRing(n)}
+}
+
+func (c *rollingStatCore) next(x float64) (sum, sumSq float64, count int) {
+	sum = c.sum + x
+	sumSq = c.sumSq + x*x
+	count = c.r.size + 1
+	if c.r.full() {
+		old := c.r.oldest()
+		sum -= old
+		sumSq -= old * old
+		count = c.r.size
+	}
+	return
+}
+
+func (c *rollingStatCore) commit(x float64) {
+	c.sum, c.sumSq, _ = c.next(x)
+	c.r.push(x)
+}
+
+func (c *rollingStatCore) reset() {
+	c.r.reset()
+	c.sum = 0
+	c.sumSq = 0
+}
+
+// 总体均值和标准差
+func meanStd(sum, sumSq float64, count int) (float64, float64) {
+	if count == 0 {
+		return 0, 0
+	}
+	mean := sum / float64(count)
+	variance := sumSq/float64(count) - mean*mean
+	if variance < 0 {
+		variance = 0 // 浮点误差
+	}
+	return mean, math.Sqrt(variance)
+}
+
+// z-score = (x - mean) / std
+type zscoreCore struct {
+	n    int
+	stat *rollingStatCore
+}
+
+func newZScoreCore(n int) *zscoreCore {
+	if n < 2 {
+		n = 2
+	}
+	return &zscoreCore{n: n, stat: newRollingStatCore(n)}
+}
+
+func (c *zscoreCore) calc(x float64) (float64, bool) {
+	sum, sumSq, count := c.stat.next(x)
+	mean, std := meanStd(sum, sumSq, count)
+	if std == 0 {
+		return 0, count >= c.n
+	}
+	return (x - mean) / std, count >= c.n
+}
+
+func (c *zscoreCore) push(x float64) (float64, bool) {
+	z, ok := c.calc(x)
+	c.stat.commit(x)
+	return z, ok
+}
+
+func (c *zscoreCore) peek(x float64) (float64, bool) {
+	return c.calc(x)
+}
+
+func (c *zscoreCore) reset() {
+	c.stat.reset()
+}
+
+// #endregion
+
+// #region 滚动协方差
+// 维护窗口内的sx, sy, sxx, syy, sxy
+type rollingCovCore struct {
+	rx, ry                *ring
+	sx, sy, sxx, syy, sxy float64
+}
+
+type covSums struct {
+	sx, sy, sxx, syy, sxy float64
+	count                 int
+}
+
+func newRollingCovCore(n int) *rollingCovCore {
+	return &rollingCovCore{rx: newRing(n), ry: newRing(n)}
+}
+
+func (c *rollingCovCore) next(x, y float64) covSums {
+	s := covSums{
+		sx:    c.sx + x,
+		sy:    c.sy + y,
+		sxx:   c.sxx + x*x,
+		syy:   c.syy + y*y,
+		sxy:   c.sxy + x*y,
+		count: c.rx.size + 1,
+	}
+
+	if c.rx.full() {
+		ox := c.rx.oldest()
+		oy := c.ry.oldest()
+		s.sx -= ox
+		s.sy -= oy
+		s.sxx -= ox * ox
+		s.syy -= oy * oy
+		s.sxy -= ox * oy
+		s.count = c.rx.size
+	}
+	return s
+}
+
+func (c *rollingCovCore) commit(x, y float64) {
+	s := c.next(x, y)
+	c.sx, c.sy, c.sxx, c.syy, c.sxy = s.sx, s.sy, s.sxx, s.syy, s.sxy
+	c.rx.push(x)
+	c.ry.push(y)
+}
+
+func (c *rollingCovCore) reset() {
+	c.rx.reset()
+	c.ry.reset()
+	c.sx, c.sy, c.sxx, c.syy, c.sxy = 0, 0, 0, 0, 0
+}
+
+// 返回协方差、x方差、y方差
+func (s covSums) moments() (cov, varX, varY float64) {
+	if s.count == 0 {
+		return 0, 0, 0
+	}
+	n := float64(s.count)
+	mx := s.sx / n
+	my := s.sy / n
+	cov = s.sxy/n - mx*my
+	varX = math.Max(s.sxx/n-mx*mx, 0)
+	varY = math.Max(s.syy/n-my*my, 0)
+	return
+}
+
+// #endregion
+
+// #region Kalman
+// 一维局部水平模型：x(t) = x(t-1) + w, w~N(0,q)；z(t) = x(t) + v, v~N(0,r)
+type kalmanCore struct {
+	q, r   float64
+	x, p   float64
+	inited bool
+}
+
+func newKalmanCore(q, r float64) *kalmanCore {
+	return &kalmanCore{q: q, r: r}
+}
+
+func (c *kalmanCore) next(z float64) (x, p float64) {
+	if !c.inited {
+		return z, c.r
+	}
+
+	// 预测
+	pp := c.p + c.q
+
+	// 更新
+	k := pp / (pp + c.r)
+	x = c.x + k*(z-c.x)
+	p = (1 - k) * pp
+	return
+}
+
+func (c *kalmanCore) push(z float64) (float64, bool) {
+	c.x, c.p = c.next(z)
+	c.inited = true
+	return c.x, true
+}
+
+func (c *kalmanCore) peek(z float64) (float64, bool) {
+	x, _ := c.next(z)
+	return x, true
+}
+
+func (c *kalmanCore) reset() {
+	c.inited = false
+	c.x = 0
+	c.p = 0
+}
+
+// #endregion
@@ -0,0 +1,230 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 18:53:10
+ * @Description: 两条DataLine之间的滚动相关系数和Beta（流式）
+ * 两条线按时间对齐，以第一条线驱动更新。调用Update前应先更新两条线
+ * 相关系数与talib CORREL一致，直接使用原始值；Beta与talib BETA一致，使用收益率
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import (
+	"math"
+
+	"github.com/aztecqt/dagger/framework"
+)
+
+// 双输入单输出的流式计算核心
+type pairCore interface {
+	push(x, y float64) (float64, bool)
+	peek(x, y float64) (float64, bool)
+	reset()
+}
+
+// 取dl中与ms处于同一周期的值。若dl在该周期尚未完成，则取其最新值
+func alignedValue(dl *framework.DataLine, ms int64) (float64, bool) {
+	n := dl.Length()
+	if n < 2 {
+		return 0, false
+	}
+
+	interval := dl.IntervalMS()
+	j := int((ms/interval*interval - dl.Times[0]) / interval)
+	if j < 0 || j >= n {
+		return 0, false
+	}
+
+	// 最后一个元素是未完成周期的最新值。ms落在该周期内（且不是周期起点）时取最新值
+	if j == n-2 && ms > dl.Times[j] {
+		return dl.Values[n-1], true
+	}
+	return dl.Values[j], true
+}
+
+// 双输入的DataLine指标
+type pairLine struct {
+	x          *framework.DataLine
+	y          *framework.DataLine
+	value      *framework.DataLine
+	core       pairCore
+	feeder     lineFeeder
+	warmUp     int
+	ready      bool
+	rebuilding bool
+}
+
+func (p *pairLine) init(x, y *framework.DataLine, name string, core pairCore, warmUp int) {
+	p.x = x
+	p.y = y
+	p.value = new(framework.DataLine)
+	p.value.Init(name, x.MaxLength(), x.IntervalMS(), 0)
+	p.core = core
+	p.warmUp = warmUp
+}
+
+func (p *pairLine) Value() *framework.DataLine {
+	return p.value
+}
+
+func (p *pairLine) Ready() bool {
+	return p.ready
+}
+
+func (p *pairLine) WarmUpPeriod() int {
+	return p.warmUp
+}
+
+func (p *pairLine) update() {
+	p.feeder.feed(
+		p.x,
+		func(i int) {
+			ts := p.x.Times[i]
+			if y, ok := alignedValue(p.y, ts); ok {
+				if v, ok := p.core.push(p.x.Values[i], y); ok {
+					p.ready = true
+					p.value.Update(ts, v)
+				}
+			}
+		},
+		func(i int) {
+			ts := p.x.Times[i]
+			if y, ok := alignedValue(p.y, ts); ok {
+				if v, ok := p.core.peek(p.x.Values[i], y); ok {
+					p.value.Update(ts, v)
+				}
+			}
+		})
+}
+
+func (p *pairLine) Update() {
+	if p.rebuilding {
+		return
+	}
+	p.update()
+}
+
+func (p *pairLine) Rebuild() {
+	p.rebuilding = true
+	p.core.reset()
+	p.feeder.reset()
+	p.value.Clear()
+	p.ready = false
+	p.update()
+	p.rebuilding = false
+}
+
+// #region 相关系数
+type correlCore struct {
+	n   int
+	cov *rollingCovCore
+}
+
+func (c *correlCore) calc(x, y float64) (float64, bool) {
+	s := c.cov.next(x, y)
+	cov, varX, varY := s.moments()
+	if varX*varY <= 0 {
+		return 0, s.count >= c.n
+	}
+	return cov / math.Sqrt(varX*varY), s.count >= c.n
+}
+
+func (c *correlCore) push(x, y float64) (float64, bool) {
+	v, ok := c.calc(x, y)
+	c.cov.commit(x, y)
+	return v, ok
+}
+
+func (c *correlCore) peek(x, y float64) (float64, bool) {
+	return c.calc(x, y)
+}
+
+func (c *correlCore) reset() {
+	c.cov.reset()
+}
+
+type Correlation struct {
+	pairLine
+	n int
+}
+
+func NewCorrelation(x, y *framework.DataLine, n int) *Correlation {
+	if n < 2 {
+		n = 2
+	}
+
+	c := new(Correlation)
+	c.n = n
+	c.init(x, y, "correl", &correlCore{n: n, cov: newRollingCovCore(n)}, n)
+	return c
+}
+
+// #endregion
+
+// #region Beta
+// x相对于y的Beta，即x收益率对y收益率回归的斜率：cov(rx, ry) / var(ry)
+type betaCore struct {
+	n       int
+	cov     *rollingCovCore
+	hasPrev bool
+	prevX   float64
+	prevY   float64
+}
+
+func ret(prev, cur float64) float64 {
+	if prev == 0 {
+		return 0
+	}
+	return cur/prev - 1
+}
+
+func (c *betaCore) calc(x, y float64) (float64, bool) {
+	if !c.hasPrev {
+		return 0, false
+	}
+
+	// 以y为自变量
+	s := c.cov.next(ret(c.prevY, y), ret(c.prevX, x))
+	cov, varY, _ := s.moments()
+	if varY == 0 {
+		return 0, s.count >= c.n
+	}
+	return cov / varY, s.count >= c.n
+}
+
+func (c *betaCore) push(x, y float64) (float64, bool) {
+	v, ok := c.calc(x, y)
+	if c.hasPrev {
+		c.cov.commit(ret(c.prevY, y), ret(c.prevX, x))
+	}
+	c.prevX, c.prevY = x, y
+	c.hasPrev = true
+	return v, ok
+}
+
+func (c *betaCore) peek(x, y float64) (float64, bool) {
+	return c.calc(x, y)
+}
+
+func (c *betaCore) reset() {
+	c.cov.reset()
+	c.hasPrev = false
+}
+
+type Beta struct {
+	pairLine
+	n int
+}
+
+// x为标的，y为基准
+func NewBeta(x, y *framework.DataLine, n int) *Beta {
+	if n < 1 {
+		n = 1
+	}
+
+	b := new(Beta)
+	b.n = n
+	b.init(x, y, "beta", &betaCore{n: n, cov: newRollingCovCore(n)}, n+1)
+	return b
+}
+
+// #endregion
@@ -0,0 +1,156 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 18:24:51
+ * @Description: 唐奇安通道（流式）。上轨为n根K线最高价，下轨为n根K线最低价，中轨取平均
+ * 用单调队列维护窗口极值，均摊O(1)
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import "github.com/aztecqt/dagger/framework"
+
+// 单调队列。greater为true时队首为最大值，否则为最小值
+type monoDeque struct {
+	idx     []int
+	val     []float64
+	greater bool
+}
+
+func (d *monoDeque) better(a, b float64) bool {
+	if d.greater {
+		return a >= b
+	}
+	return a <= b
+}
+
+// 写入第i个值，并移除下标不大于expire的元素
+func (d *monoDeque) push(i int, x float64, expire int) {
+	for len(d.val) > 0 && d.better(x, d.val[len(d.val)-1]) {
+		d.idx = d.idx[:len(d.idx)-1]
+		d.val = d.val[:len(d.val)-1]
+	}
+	d.idx = append(d.idx, i)
+	d.val = append(d.val, x)
+
+	for len(d.idx) > 0 && d.idx[0] <= expire {
+		d.idx = d.idx[1:]
+		d.val = d.val[1:]
+	}
+}
+
+// 下标大于expire的元素与x合并后的极值，不改变队列
+func (d *monoDeque) peek(x float64, expire int) float64 {
+	for k := range d.idx {
+		if d.idx[k] > expire {
+			if d.better(d.val[k], x) {
+				return d.val[k]
+			}
+			break
+		}
+	}
+	return x
+}
+
+func (d *monoDeque) reset() {
+	d.idx = d.idx[:0]
+	d.val = d.val[:0]
+}
+
+type Donchian struct {
+	kl         *KLines
+	upper      *framework.DataLine
+	middle     *framework.DataLine
+	lower      *framework.DataLine
+	highs      monoDeque
+	lows       monoDeque
+	count      int // 已提交的K线数量
+	feeder     lineFeeder
+	n          int
+	ready      bool
+	rebuilding bool
+}
+
+func NewDonchian(kl *KLines, n int) *Donchian {
+	if n < 1 {
+		n = 1
+	}
+
+	d := new(Donchian)
+	d.kl = kl
+	d.n = n
+	d.upper = kl.newOutput("upper")
+	d.middle = kl.newOutput("middle")
+	d.lower = kl.newOutput("lower")
+	d.highs.greater = true
+	return d
+}
+
+func (d *Donchian) Upper() *framework.DataLine {
+	return d.upper
+}
+
+func (d *Donchian) Middle() *framework.DataLine {
+	return d.middle
+}
+
+func (d *Donchian) Lower() *framework.DataLine {
+	return d.lower
+}
+
+func (d *Donchian) Ready() bool {
+	return d.ready
+}
+
+func (d *Donchian) WarmUpPeriod() int {
+	return d.n
+}
+
+func (d *Donchian) write(ts int64, h, l float64) {
+	d.upper.Update(ts, h)
+	d.lower.Update(ts, l)
+	d.middle.Update(ts, (h+l)/2)
+}
+
+func (d *Donchian) update() {
+	d.feeder.feed(
+		d.kl.Close,
+		func(i int) {
+			b := d.kl.bar(i)
+			expire := d.count - d.n
+			d.highs.push(d.count, b.h, expire)
+			d.lows.push(d.count, b.l, expire)
+			d.count++
+			if d.count >= d.n {
+				d.ready = true
+				d.write(d.kl.Close.Times[i], d.highs.val[0], d.lows.val[0])
+			}
+		},
+		func(i int) {
+			if d.count+1 >= d.n {
+				b := d.kl.bar(i)
+				expire := d.count - d.n
+				d.write(d.kl.Close.Times[i], d.highs.peek(b.h, expire), d.lows.peek(b.l, expire))
+			}
+		})
+}
+
+func (d *Donchian) Update() {
+	if d.rebuilding {
+		return
+	}
+	d.update()
+}
+
+func (d *Donchian) Rebuild() {
+	d.rebuilding = true
+	d.highs.reset()
+	d.lows.reset()
+	d.count = 0
+	d.feeder.reset()
+	d.upper.Clear()
+	d.middle.Clear()
+	d.lower.Clear()
+	d.ready = false
+	d.update()
+	d.rebuilding = false
+}
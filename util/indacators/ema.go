@@ -0,0 +1,22 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 16:52:08
+ * @Description: 指数移动平均（流式）
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import "github.com/aztecqt/dagger/framework"
+
+type EMA struct {
+	singleLine
+	n int
+}
+
+func NewEMA(orign *framework.DataLine, n int) *EMA {
+	ema := new(EMA)
+	ema.n = n
+	ema.init(orign, "ema", newEmaCore(n), n)
+	return ema
+}
@@ -20,3 +20,10 @@ type Band interface {
 	Lower() *framework.DataLine
 	Middle() *framework.DataLine
 }
+
+// 流式指标：每次Update只处理新增数据，并记录预热状态
+type StreamIndicator interface {
+	Indicator
+	Ready() bool       // 是否已完成预热
+	WarmUpPeriod() int // 预热需要的已完成数据个数
+}
@@ -0,0 +1,24 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 17:06:50
+ * @Description: 一维卡尔曼滤波（流式），用于价格平滑
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import "github.com/aztecqt/dagger/framework"
+
+type Kalman struct {
+	singleLine
+	q, r float64
+}
+
+// q：过程噪声方差，越大跟踪越快；r：观测噪声方差，越大越平滑
+func NewKalman(orign *framework.DataLine, q, r float64) *Kalman {
+	k := new(Kalman)
+	k.q = q
+	k.r = r
+	k.init(orign, "kalman", newKalmanCore(q, r), 1)
+	return k
+}
@@ -0,0 +1,112 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 18:12:40
+ * @Description: 肯特纳通道（流式）。中轨为收盘价EMA，上下轨为中轨±k倍ATR
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import (
+	"github.com/aztecqt/dagger/framework"
+	"github.com/aztecqt/dagger/util"
+)
+
+type Keltner struct {
+	kl         *KLines
+	upper      *framework.DataLine
+	middle     *framework.DataLine
+	lower      *framework.DataLine
+	ema        *emaCore
+	atr        atrState
+	feeder     lineFeeder
+	nEma       int
+	nAtr       int
+	k          float64
+	ready      bool
+	rebuilding bool
+}
+
+func NewKeltner(kl *KLines, nEma, nAtr int, k float64) *Keltner {
+	kt := new(Keltner)
+	kt.kl = kl
+	kt.nEma = nEma
+	kt.nAtr = nAtr
+	kt.k = k
+	kt.upper = kl.newOutput("upper")
+	kt.middle = kl.newOutput("middle")
+	kt.lower = kl.newOutput("lower")
+	kt.ema = newEmaCore(nEma)
+	kt.atr = atrState{n: nAtr, w: *newWilderCore(nAtr)}
+	return kt
+}
+
+func (kt *Keltner) Upper() *framework.DataLine {
+	return kt.upper
+}
+
+func (kt *Keltner) Middle() *framework.DataLine {
+	return kt.middle
+}
+
+func (kt *Keltner) Lower() *framework.DataLine {
+	return kt.lower
+}
+
+func (kt *Keltner) Ready() bool {
+	return kt.ready
+}
+
+func (kt *Keltner) WarmUpPeriod() int {
+	return util.MaxInt(kt.nEma, kt.nAtr+1)
+}
+
+func (kt *Keltner) write(ts int64, mid, atr float64) {
+	kt.middle.Update(ts, mid)
+	kt.upper.Update(ts, mid+atr*kt.k)
+	kt.lower.Update(ts, mid-atr*kt.k)
+}
+
+func (kt *Keltner) update() {
+	kt.feeder.feed(
+		kt.kl.Close,
+		func(i int) {
+			b := kt.kl.bar(i)
+			mid, emaOk := kt.ema.push(b.c)
+			var atr float64
+			var atrOk bool
+			kt.atr, atr, atrOk = kt.atr.next(b)
+			if emaOk && atrOk {
+				kt.ready = true
+				kt.write(kt.kl.Close.Times[i], mid, atr)
+			}
+		},
+		func(i int) {
+			b := kt.kl.bar(i)
+			mid, emaOk := kt.ema.peek(b.c)
+			_, atr, atrOk := kt.atr.next(b)
+			if emaOk && atrOk {
+				kt.write(kt.kl.Close.Times[i], mid, atr)
+			}
+		})
+}
+
+func (kt *Keltner) Update() {
+	if kt.rebuilding {
+		return
+	}
+	kt.update()
+}
+
+func (kt *Keltner) Rebuild() {
+	kt.rebuilding = true
+	kt.ema.reset()
+	kt.atr = atrState{n: kt.nAtr, w: *newWilderCore(kt.nAtr)}
+	kt.feeder.reset()
+	kt.upper.Clear()
+	kt.middle.Clear()
+	kt.lower.Clear()
+	kt.ready = false
+	kt.update()
+	kt.rebuilding = false
+}
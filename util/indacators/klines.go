@@ -0,0 +1,111 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 17:25:02
+ * @Description: 一组并行的OHLCV DataLine，作为ATR、ADX等K线类指标的输入
+ * 可以由common.KUnit填充，也可以由逐笔成交实时构建
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import (
+	"github.com/aztecqt/dagger/cex/common"
+	"github.com/aztecqt/dagger/framework"
+)
+
+type KLines struct {
+	Open   *framework.DataLine
+	High   *framework.DataLine
+	Low    *framework.DataLine
+	Close  *framework.DataLine
+	Volume *framework.DataLine
+
+	lastBucket int64
+}
+
+func NewKLines(maxLength int, intervalMs int64) *KLines {
+	k := new(KLines)
+	k.Open = new(framework.DataLine).Init("open", maxLength, intervalMs, 0)
+	k.High = new(framework.DataLine).Init("high", maxLength, intervalMs, 0)
+	k.Low = new(framework.DataLine).Init("low", maxLength, intervalMs, 0)
+	k.Close = new(framework.DataLine).Init("close", maxLength, intervalMs, 0)
+	k.Volume = new(framework.DataLine).Init("volume", maxLength, intervalMs, 0)
+	k.lastBucket = -1
+	return k
+}
+
+func (k *KLines) Length() int {
+	return k.Close.Length()
+}
+
+func (k *KLines) MaxLength() int {
+	return k.Close.MaxLength()
+}
+
+func (k *KLines) IntervalMS() int64 {
+	return k.Close.IntervalMS()
+}
+
+// 写入一根K线。同一周期内重复写入会覆盖（最新的K线通常还未走完）
+func (k *KLines) UpdateBar(ms int64, o, h, l, c, v float64) {
+	k.Open.Update(ms, o)
+	k.High.Update(ms, h)
+	k.Low.Update(ms, l)
+	k.Close.Update(ms, c)
+	k.Volume.Update(ms, v)
+	k.lastBucket = ms / k.IntervalMS()
+}
+
+func (k *KLines) UpdateKUnit(ku common.KUnit) {
+	k.UpdateBar(
+		ku.Time.UnixMilli(),
+		ku.OpenPrice.InexactFloat64(),
+		ku.HighestPrice.InexactFloat64(),
+		ku.LowestPrice.InexactFloat64(),
+		ku.ClosePrice.InexactFloat64(),
+		ku.VolumeUSD.InexactFloat64())
+}
+
+func (k *KLines) UpdateKUnits(kus []common.KUnit) {
+	for _, ku := range kus {
+		k.UpdateKUnit(ku)
+	}
+}
+
+// 用一笔成交更新当前K线。进入新周期时自动开始一根新K线
+func (k *KLines) UpdateTrade(ms int64, px, sz float64) {
+	bucket := ms / k.IntervalMS()
+	if k.Length() == 0 || bucket != k.lastBucket {
+		k.UpdateBar(ms, px, px, px, px, sz)
+		return
+	}
+
+	n := k.Length() - 1
+	h := k.High.Values[n]
+	l := k.Low.Values[n]
+	if px > h {
+		h = px
+	}
+	if px < l {
+		l = px
+	}
+	k.UpdateBar(ms, k.Open.Values[n], h, l, px, k.Volume.Values[n]+sz)
+}
+
+type bar struct {
+	o, h, l, c, v float64
+}
+
+func (k *KLines) bar(i int) bar {
+	return bar{
+		o: k.Open.Values[i],
+		h: k.High.Values[i],
+		l: k.Low.Values[i],
+		c: k.Close.Values[i],
+		v: k.Volume.Values[i],
+	}
+}
+
+// 创建一条与K线时间对齐的输出线
+func (k *KLines) newOutput(name string) *framework.DataLine {
+	return new(framework.DataLine).Init(name, k.MaxLength(), k.IntervalMS(), 0)
+}
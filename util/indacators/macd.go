@@ -0,0 +1,138 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 17:12:35
+ * @Description: MACD（流式）
+ * 与talib一致：快线EMA推迟(slow-fast)个数据开始计算，使快慢线同时完成预热
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import "github.com/aztecqt/dagger/framework"
+
+type MACD struct {
+	orign      *framework.DataLine
+	macd       *framework.DataLine
+	signal     *framework.DataLine
+	hist       *framework.DataLine
+	fast       *emaCore
+	slow       *emaCore
+	sig        *emaCore
+	skip       int // 快线需要跳过的数据个数
+	count      int
+	feeder     lineFeeder
+	nFast      int
+	nSlow      int
+	nSignal    int
+	ready      bool
+	rebuilding bool
+}
+
+func NewMACD(orign *framework.DataLine, nFast, nSlow, nSignal int) *MACD {
+	if nFast > nSlow {
+		nFast, nSlow = nSlow, nFast
+	}
+
+	m := new(MACD)
+	m.orign = orign
+	m.macd = new(framework.DataLine)
+	m.signal = new(framework.DataLine)
+	m.hist = new(framework.DataLine)
+	m.macd.Init("macd", orign.MaxLength(), orign.IntervalMS(), 0)
+	m.signal.Init("signal", orign.MaxLength(), orign.IntervalMS(), 0)
+	m.hist.Init("hist", orign.MaxLength(), orign.IntervalMS(), 0)
+	m.fast = newEmaCore(nFast)
+	m.slow = newEmaCore(nSlow)
+	m.sig = newEmaCore(nSignal)
+	m.skip = nSlow - nFast
+	m.nFast = nFast
+	m.nSlow = nSlow
+	m.nSignal = nSignal
+	return m
+}
+
+func (m *MACD) Macd() *framework.DataLine {
+	return m.macd
+}
+
+func (m *MACD) Signal() *framework.DataLine {
+	return m.signal
+}
+
+func (m *MACD) Hist() *framework.DataLine {
+	return m.hist
+}
+
+func (m *MACD) Ready() bool {
+	return m.ready
+}
+
+func (m *MACD) WarmUpPeriod() int {
+	return m.nSlow + m.nSignal - 1
+}
+
+func (m *MACD) step(x float64, commit bool) (macd, signal float64, ok bool) {
+	var fv, sv float64
+	var fok, sok bool
+	if commit {
+		if m.count >= m.skip {
+			fv, fok = m.fast.push(x)
+		}
+		sv, sok = m.slow.push(x)
+		m.count++
+	} else {
+		if m.count >= m.skip {
+			fv, fok = m.fast.peek(x)
+		}
+		sv, sok = m.slow.peek(x)
+	}
+
+	if !fok || !sok {
+		return 0, 0, false
+	}
+
+	macd = fv - sv
+	if commit {
+		signal, ok = m.sig.push(macd)
+	} else {
+		signal, ok = m.sig.peek(macd)
+	}
+	return
+}
+
+func (m *MACD) update() {
+	write := func(i int, commit bool) {
+		if macd, signal, ok := m.step(m.orign.Values[i], commit); ok {
+			if commit {
+				m.ready = true
+			}
+			ts := m.orign.Times[i]
+			m.macd.Update(ts, macd)
+			m.signal.Update(ts, signal)
+			m.hist.Update(ts, macd-signal)
+		}
+	}
+
+	m.feeder.feed(m.orign, func(i int) { write(i, true) }, func(i int) { write(i, false) })
+}
+
+func (m *MACD) Update() {
+	if m.rebuilding {
+		return
+	}
+	m.update()
+}
+
+func (m *MACD) Rebuild() {
+	m.rebuilding = true
+	m.fast.reset()
+	m.slow.reset()
+	m.sig.reset()
+	m.count = 0
+	m.feeder.reset()
+	m.macd.Clear()
+	m.signal.Clear()
+	m.hist.Clear()
+	m.ready = false
+	m.update()
+	m.rebuilding = false
+}
@@ -0,0 +1,86 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 18:44:27
+ * @Description: 能量潮OBV（流式）
+ * 与talib一致：第一个值为第一根K线的成交量，之后收盘价上涨累加成交量，下跌累减成交量
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import "github.com/aztecqt/dagger/framework"
+
+type OBV struct {
+	kl         *KLines
+	value      *framework.DataLine
+	obv        float64
+	prevClose  float64
+	hasPrev    bool
+	feeder     lineFeeder
+	rebuilding bool
+}
+
+func NewOBV(kl *KLines) *OBV {
+	o := new(OBV)
+	o.kl = kl
+	o.value = kl.newOutput("obv")
+	return o
+}
+
+func (o *OBV) Value() *framework.DataLine {
+	return o.value
+}
+
+func (o *OBV) Ready() bool {
+	return o.hasPrev
+}
+
+func (o *OBV) WarmUpPeriod() int {
+	return 1
+}
+
+func (o *OBV) next(b bar) float64 {
+	if !o.hasPrev {
+		return b.v
+	}
+
+	if b.c > o.prevClose {
+		return o.obv + b.v
+	} else if b.c < o.prevClose {
+		return o.obv - b.v
+	} else {
+		return o.obv
+	}
+}
+
+func (o *OBV) update() {
+	o.feeder.feed(
+		o.kl.Close,
+		func(i int) {
+			b := o.kl.bar(i)
+			o.obv = o.next(b)
+			o.prevClose = b.c
+			o.hasPrev = true
+			o.value.Update(o.kl.Close.Times[i], o.obv)
+		},
+		func(i int) {
+			o.value.Update(o.kl.Close.Times[i], o.next(o.kl.bar(i)))
+		})
+}
+
+func (o *OBV) Update() {
+	if o.rebuilding {
+		return
+	}
+	o.update()
+}
+
+func (o *OBV) Rebuild() {
+	o.rebuilding = true
+	o.obv = 0
+	o.prevClose = 0
+	o.hasPrev = false
+	o.feeder.reset()
+	o.value.Clear()
+	o.update()
+	o.rebuilding = false
+}
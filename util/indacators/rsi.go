@@ -0,0 +1,22 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 16:58:13
+ * @Description: 相对强弱指标（流式），Wilder平滑
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import "github.com/aztecqt/dagger/framework"
+
+type RSI struct {
+	singleLine
+	n int
+}
+
+func NewRSI(orign *framework.DataLine, n int) *RSI {
+	rsi := new(RSI)
+	rsi.n = n
+	rsi.init(orign, "rsi", newRsiCore(n), n+1)
+	return rsi
+}
@@ -0,0 +1,162 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 16:02:37
+ * @Description: 流式指标的公共部分
+ * DataLine的最后一个元素是"未完成"的，会被反复更新；之前的元素已经完成，不再变化
+ * 流式指标只把已完成的元素提交到内部状态（O(1)），对未完成的元素做试算但不提交
+ * 这样每次Update的开销与历史长度无关
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import "github.com/aztecqt/dagger/framework"
+
+// 单输入单输出的流式计算核心
+type scalarCore interface {
+	push(x float64) (float64, bool) // 提交一个已完成的值，返回指标值以及是否已完成预热
+	peek(x float64) (float64, bool) // 用一个未完成的值试算，不改变内部状态
+	reset()
+}
+
+// 负责从DataLine中找出尚未提交的已完成元素
+type lineFeeder struct {
+	lastMs    int64
+	committed bool
+}
+
+func (f *lineFeeder) reset() {
+	f.lastMs = 0
+	f.committed = false
+}
+
+// 依次提交未提交过的已完成元素，再试算最后一个元素
+// 返回值为未完成元素的下标，-1表示没有数据
+func (f *lineFeeder) feed(dl *framework.DataLine, push func(i int), peek func(i int)) int {
+	n := dl.Length()
+	if n == 0 {
+		return -1
+	}
+
+	// 从后往前找到第一个未提交的已完成元素
+	start := n - 1
+	for start > 0 && (!f.committed || dl.Times[start-1] > f.lastMs) {
+		start--
+	}
+
+	for i := start; i < n-1; i++ {
+		push(i)
+		f.lastMs = dl.Times[i]
+		f.committed = true
+	}
+
+	peek(n - 1)
+	return n - 1
+}
+
+// 定长环形缓冲
+type ring struct {
+	buf  []float64
+	head int // 最旧元素的位置
+	size int
+}
+
+func newRing(n int) *ring {
+	if n < 1 {
+		n = 1
+	}
+	return &ring{buf: make([]float64, n)}
+}
+
+func (r *ring) full() bool {
+	return r.size == len(r.buf)
+}
+
+// 最旧的元素。缓冲区满时，下一次push会挤掉它
+func (r *ring) oldest() float64 {
+	return r.buf[r.head]
+}
+
+// 写入一个元素，返回被挤掉的元素
+func (r *ring) push(x float64) (float64, bool) {
+	if r.full() {
+		old := r.buf[r.head]
+		r.buf[r.head] = x
+		r.head = (r.head + 1) % len(r.buf)
+		return old, true
+	}
+
+	r.buf[(r.head+r.size)%len(r.buf)] = x
+	r.size++
+	return 0, false
+}
+
+func (r *ring) reset() {
+	r.head = 0
+	r.size = 0
+}
+
+// 单输入单输出的DataLine指标
+type singleLine struct {
+	orign      *framework.DataLine
+	value      *framework.DataLine
+	core       scalarCore
+	feeder     lineFeeder
+	warmUp     int
+	ready      bool
+	rebuilding bool
+}
+
+func (s *singleLine) init(orign *framework.DataLine, name string, core scalarCore, warmUp int) {
+	s.orign = orign
+	s.value = new(framework.DataLine)
+	s.value.Init(name, orign.MaxLength(), orign.IntervalMS(), 0)
+	s.core = core
+	s.warmUp = warmUp
+}
+
+func (s *singleLine) Value() *framework.DataLine {
+	return s.value
+}
+
+// 是否已完成预热
+func (s *singleLine) Ready() bool {
+	return s.ready
+}
+
+// 预热需要的已完成数据个数
+func (s *singleLine) WarmUpPeriod() int {
+	return s.warmUp
+}
+
+func (s *singleLine) update() {
+	s.feeder.feed(
+		s.orign,
+		func(i int) {
+			if v, ok := s.core.push(s.orign.Values[i]); ok {
+				s.ready = true
+				s.value.Update(s.orign.Times[i], v)
+			}
+		},
+		func(i int) {
+			if v, ok := s.core.peek(s.orign.Values[i]); ok {
+				s.value.Update(s.orign.Times[i], v)
+			}
+		})
+}
+
+func (s *singleLine) Update() {
+	if s.rebuilding {
+		return
+	}
+	s.update()
+}
+
+func (s *singleLine) Rebuild() {
+	s.rebuilding = true
+	s.core.reset()
+	s.feeder.reset()
+	s.value.Clear()
+	s.ready = false
+	s.update()
+	s.rebuilding = false
+}
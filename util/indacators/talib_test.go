@@ -0,0 +1,380 @@
+package indacators
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/aztecqt/dagger/framework"
+	"github.com/markcheno/go-talib"
+)
+
+// 流式指标与talib对照。每根K线写两次（先写未完成的值，再写最终值），
+// 每次Update后指标的最新值都应与talib在当前输入上的最后一个输出一致
+
+const (
+	testBars     = 300
+	testInterval = int64(60000)
+	testBaseMs   = int64(1700000000000)
+)
+
+type testBar struct {
+	o, h, l, c, v float64
+}
+
+func randomBars(seed int64, n int) []testBar {
+	r := rand.New(rand.NewSource(seed))
+	bars := make([]testBar, n)
+	px := 100.0
+	for i := range bars {
+		o := px
+		c := o * (1 + r.NormFloat64()*0.01)
+		h := math.Max(o, c) * (1 + r.Float64()*0.005)
+		l := math.Min(o, c) * (1 - r.Float64()*0.005)
+		bars[i] = testBar{o: o, h: h, l: l, c: c, v: 10 + r.Float64()*100}
+		px = c
+	}
+	return bars
+}
+
+// 未完成时的K线：收盘价在开盘价和最终收盘价之间
+func (b testBar) partial() testBar {
+	c := (b.o + b.c) / 2
+	return testBar{o: b.o, h: math.Max(b.o, c), l: math.Min(b.o, c), c: c, v: b.v / 2}
+}
+
+func nearlyEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-8*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func checkLast(t *testing.T, name string, step int, dl *framework.DataLine, want []float64) {
+	t.Helper()
+	got, ok := dl.LastValue()
+	if !ok {
+		t.Fatalf("%s step %d: no value", name, step)
+	}
+	if w := want[len(want)-1]; !nearlyEqual(got, w) {
+		t.Fatalf("%s step %d: got %v, want %v", name, step, got, w)
+	}
+}
+
+// 已提交（已完成）的元素个数。DataLine的最后一个元素是未完成的
+func committed(dl *framework.DataLine) int {
+	return dl.Length() - 1
+}
+
+func checkReady(t *testing.T, name string, step int, ind StreamIndicator, dl *framework.DataLine) {
+	t.Helper()
+	if want := committed(dl) >= ind.WarmUpPeriod(); ind.Ready() != want {
+		t.Fatalf("%s step %d: ready=%v, committed=%d, warmup=%d", name, step, ind.Ready(), committed(dl), ind.WarmUpPeriod())
+	}
+}
+
+// #region 单输入指标
+type singleCase struct {
+	name  string
+	build func(dl *framework.DataLine) (StreamIndicator, *framework.DataLine)
+	want  func(in []float64) []float64
+}
+
+func TestSingleLineVsTalib(t *testing.T) {
+	cases := []singleCase{
+		{
+			name: "ema",
+			build: func(dl *framework.DataLine) (StreamIndicator, *framework.DataLine) {
+				e := NewEMA(dl, 12)
+				return e, e.Value()
+			},
+			want: func(in []float64) []float64 { return talib.Ema(in, 12) },
+		},
+		{
+			name: "wma",
+			build: func(dl *framework.DataLine) (StreamIndicator, *framework.DataLine) {
+				w := NewWMA(dl, 10)
+				return w, w.Value()
+			},
+			want: func(in []float64) []float64 { return talib.Wma(in, 10) },
+		},
+		{
+			name: "rsi",
+			build: func(dl *framework.DataLine) (StreamIndicator, *framework.DataLine) {
+				r := NewRSI(dl, 14)
+				return r, r.Value()
+			},
+			want: func(in []float64) []float64 { return talib.Rsi(in, 14) },
+		},
+		{
+			name: "zscore",
+			build: func(dl *framework.DataLine) (StreamIndicator, *framework.DataLine) {
+				z := NewZScore(dl, 20)
+				return z, z.Value()
+			},
+			want: func(in []float64) []float64 {
+				sma := talib.Sma(in, 20)
+				std := talib.StdDev(in, 20, 1)
+				out := make([]float64, len(in))
+				for i := range in {
+					if std[i] > 0 {
+						out[i] = (in[i] - sma[i]) / std[i]
+					}
+				}
+				return out
+			},
+		},
+		{
+			name: "kalman",
+			build: func(dl *framework.DataLine) (StreamIndicator, *framework.DataLine) {
+				k := NewKalman(dl, 0.01, 1)
+				return k, k.Value()
+			},
+			want: func(in []float64) []float64 { return refKalman(in, 0.01, 1) },
+		},
+	}
+
+	bars := randomBars(1, testBars)
+	for _, c := range cases {
+		dl := new(framework.DataLine).Init("close", 1000, testInterval, 0)
+		ind, out := c.build(dl)
+		for i, b := range bars {
+			for j, px := range []float64{b.partial().c, b.c} {
+				dl.Update(testBaseMs+int64(i)*testInterval+int64(j), px)
+				ind.Update()
+				checkReady(t, c.name, i, ind, dl)
+				if ind.Ready() {
+					checkLast(t, c.name, i, out, c.want(dl.Values))
+				}
+			}
+		}
+
+		// 重建后结果不变
+		ind.Rebuild()
+		checkLast(t, c.name+" rebuild", testBars, out, c.want(dl.Values))
+	}
+}
+
+// talib没有卡尔曼滤波，按定义逐个计算
+func refKalman(in []float64, q, r float64) []float64 {
+	out := make([]float64, len(in))
+	x, p := in[0], r
+	out[0] = x
+	for i := 1; i < len(in); i++ {
+		pp := p + q
+		k := pp / (pp + r)
+		x = x + k*(in[i]-x)
+		p = (1 - k) * pp
+		out[i] = x
+	}
+	return out
+}
+
+func TestMACDVsTalib(t *testing.T) {
+	bars := randomBars(2, testBars)
+	dl := new(framework.DataLine).Init("close", 1000, testInterval, 0)
+	m := NewMACD(dl, 12, 26, 9)
+	for i, b := range bars {
+		for j, px := range []float64{b.partial().c, b.c} {
+			dl.Update(testBaseMs+int64(i)*testInterval+int64(j), px)
+			m.Update()
+			checkReady(t, "macd", i, m, dl)
+			if m.Ready() {
+				macd, signal, hist := refMacd(dl.Values, 12, 26, 9)
+				checkLast(t, "macd", i, m.Macd(), macd)
+				checkLast(t, "macd signal", i, m.Signal(), signal)
+				checkLast(t, "macd hist", i, m.Hist(), hist)
+			}
+		}
+	}
+}
+
+// TA-Lib的MACD：快线推迟(slow-fast)个数据开始计算，与慢线同时完成预热
+// go-talib的Macd没有推迟快线，与TA-Lib C的输出不一致，这里用talib.Ema组合
+func refMacd(in []float64, fast, slow, signal int) (macd, sig, hist []float64) {
+	macd = make([]float64, len(in))
+	sig = make([]float64, len(in))
+	hist = make([]float64, len(in))
+	skip := slow - fast
+	fastEma := talib.Ema(in[skip:], fast)
+	slowEma := talib.Ema(in, slow)
+	for i := slow - 1; i < len(in); i++ {
+		macd[i] = fastEma[i-skip] - slowEma[i]
+	}
+
+	sigEma := talib.Ema(macd[slow-1:], signal)
+	for i := slow + signal - 2; i < len(in); i++ {
+		sig[i] = sigEma[i-slow+1]
+		hist[i] = macd[i] - sig[i]
+	}
+	return
+}
+
+// #endregion
+
+// #region K线指标
+type klineCase struct {
+	name  string
+	build func(kl *KLines) (StreamIndicator, []*framework.DataLine)
+	want  func(kl *KLines) [][]float64
+}
+
+func TestKLineVsTalib(t *testing.T) {
+	cases := []klineCase{
+		{
+			name: "atr",
+			build: func(kl *KLines) (StreamIndicator, []*framework.DataLine) {
+				a := NewATR(kl, 14)
+				return a, []*framework.DataLine{a.Value()}
+			},
+			want: func(kl *KLines) [][]float64 {
+				return [][]float64{talib.Atr(kl.High.Values, kl.Low.Values, kl.Close.Values, 14)}
+			},
+		},
+		{
+			name: "adx",
+			build: func(kl *KLines) (StreamIndicator, []*framework.DataLine) {
+				a := NewADX(kl, 14)
+				return a, []*framework.DataLine{a.Value(), a.PlusDI(), a.MinusDI()}
+			},
+			want: func(kl *KLines) [][]float64 {
+				h, l, c := kl.High.Values, kl.Low.Values, kl.Close.Values
+				return [][]float64{talib.Adx(h, l, c, 14), talib.PlusDI(h, l, c, 14), talib.MinusDI(h, l, c, 14)}
+			},
+		},
+		{
+			name: "obv",
+			build: func(kl *KLines) (StreamIndicator, []*framework.DataLine) {
+				o := NewOBV(kl)
+				return o, []*framework.DataLine{o.Value()}
+			},
+			want: func(kl *KLines) [][]float64 {
+				return [][]float64{talib.Obv(kl.Close.Values, kl.Volume.Values)}
+			},
+		},
+		{
+			name: "donchian",
+			build: func(kl *KLines) (StreamIndicator, []*framework.DataLine) {
+				d := NewDonchian(kl, 20)
+				return d, []*framework.DataLine{d.Upper(), d.Middle(), d.Lower()}
+			},
+			want: func(kl *KLines) [][]float64 {
+				upper := talib.Max(kl.High.Values, 20)
+				lower := talib.Min(kl.Low.Values, 20)
+				middle := make([]float64, len(upper))
+				for i := range upper {
+					middle[i] = (upper[i] + lower[i]) / 2
+				}
+				return [][]float64{upper, middle, lower}
+			},
+		},
+		{
+			name: "keltner",
+			build: func(kl *KLines) (StreamIndicator, []*framework.DataLine) {
+				k := NewKeltner(kl, 20, 10, 2)
+				return k, []*framework.DataLine{k.Upper(), k.Middle(), k.Lower()}
+			},
+			want: func(kl *KLines) [][]float64 {
+				ema := talib.Ema(kl.Close.Values, 20)
+				atr := talib.Atr(kl.High.Values, kl.Low.Values, kl.Close.Values, 10)
+				upper := make([]float64, len(ema))
+				lower := make([]float64, len(ema))
+				for i := range ema {
+					upper[i] = ema[i] + 2*atr[i]
+					lower[i] = ema[i] - 2*atr[i]
+				}
+				return [][]float64{upper, ema, lower}
+			},
+		},
+		{
+			name: "vwap",
+			build: func(kl *KLines) (StreamIndicator, []*framework.DataLine) {
+				v := NewVWAP(kl, 20)
+				return v, []*framework.DataLine{v.Value()}
+			},
+			want: func(kl *KLines) [][]float64 {
+				return [][]float64{refVWAP(kl, 20)}
+			},
+		},
+	}
+
+	bars := randomBars(3, testBars)
+	for _, c := range cases {
+		kl := NewKLines(1000, testInterval)
+		ind, outs := c.build(kl)
+		for i, b := range bars {
+			for j, bb := range []testBar{b.partial(), b} {
+				kl.UpdateBar(testBaseMs+int64(i)*testInterval+int64(j), bb.o, bb.h, bb.l, bb.c, bb.v)
+				ind.Update()
+				checkReady(t, c.name, i, ind, kl.Close)
+				if ind.Ready() {
+					wants := c.want(kl)
+					for k, out := range outs {
+						checkLast(t, c.name, i, out, wants[k])
+					}
+				}
+			}
+		}
+
+		ind.Rebuild()
+		wants := c.want(kl)
+		for k, out := range outs {
+			checkLast(t, c.name+" rebuild", testBars, out, wants[k])
+		}
+	}
+}
+
+// talib没有VWAP，按定义计算最近n根K线的典型价加权均价
+func refVWAP(kl *KLines, n int) []float64 {
+	l := kl.Length()
+	out := make([]float64, l)
+	for i := n - 1; i < l; i++ {
+		pv, vol := 0.0, 0.0
+		for j := i - n + 1; j <= i; j++ {
+			tp := (kl.High.Values[j] + kl.Low.Values[j] + kl.Close.Values[j]) / 3
+			pv += tp * kl.Volume.Values[j]
+			vol += kl.Volume.Values[j]
+		}
+		out[i] = pv / vol
+	}
+	return out
+}
+
+// #endregion
+
+// #region 双输入指标
+func TestPairVsTalib(t *testing.T) {
+	barsX := randomBars(4, testBars)
+	barsY := randomBars(5, testBars)
+	for i := range barsX {
+		// x部分跟随y，使相关性不为0
+		barsX[i].c = barsX[i].c*0.5 + barsY[i].c*0.5
+	}
+
+	x := new(framework.DataLine).Init("x", 1000, testInterval, 0)
+	y := new(framework.DataLine).Init("y", 1000, testInterval, 0)
+	correl := NewCorrelation(x, y, 30)
+	beta := NewBeta(x, y, 30)
+	for i := range barsX {
+		for j := 0; j < 2; j++ {
+			bx, by := barsX[i], barsY[i]
+			if j == 0 {
+				bx, by = bx.partial(), by.partial()
+			}
+			ms := testBaseMs + int64(i)*testInterval + int64(j)
+			x.Update(ms, bx.c)
+			y.Update(ms, by.c)
+			correl.Update()
+			beta.Update()
+
+			checkReady(t, "correl", i, correl, x)
+			checkReady(t, "beta", i, beta, x)
+			if correl.Ready() {
+				checkLast(t, "correl", i, correl.Value(), talib.Correl(x.Values, y.Values, 30))
+			}
+			if beta.Ready() {
+				// talib.Beta为inReal1收益率对inReal0收益率回归的斜率
+				checkLast(t, "beta", i, beta.Value(), talib.Beta(y.Values, x.Values, 30))
+			}
+		}
+	}
+}
+
+// #endregion
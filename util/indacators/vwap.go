@@ -0,0 +1,128 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 18:36:05
+ * @Description: 成交量加权均价VWAP（流式），价格取典型价(h+l+c)/3
+ * n>0时为最近n根K线的滚动VWAP，n=0时为全部K线的累计VWAP
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import (
+	"github.com/aztecqt/dagger/framework"
+	"github.com/aztecqt/dagger/util"
+)
+
+type VWAP struct {
+	kl         *KLines
+	value      *framework.DataLine
+	pv         *ring // 滚动模式下窗口内的 价格*成交量
+	vol        *ring // 滚动模式下窗口内的成交量
+	sumPV      float64
+	sumVol     float64
+	count      int
+	feeder     lineFeeder
+	n          int
+	ready      bool
+	rebuilding bool
+}
+
+func NewVWAP(kl *KLines, n int) *VWAP {
+	if n < 0 {
+		n = 0
+	}
+
+	v := new(VWAP)
+	v.kl = kl
+	v.n = n
+	v.value = kl.newOutput("vwap")
+	if n > 0 {
+		v.pv = newRing(n)
+		v.vol = newRing(n)
+	}
+	return v
+}
+
+func (v *VWAP) Value() *framework.DataLine {
+	return v.value
+}
+
+func (v *VWAP) Ready() bool {
+	return v.ready
+}
+
+func (v *VWAP) WarmUpPeriod() int {
+	return util.MaxInt(v.n, 1)
+}
+
+func typicalPrice(b bar) float64 {
+	return (b.h + b.l + b.c) / 3
+}
+
+// 加入一根K线后的累计值
+func (v *VWAP) next(b bar) (sumPV, sumVol float64, count int) {
+	pv := typicalPrice(b) * b.v
+	sumPV = v.sumPV + pv
+	sumVol = v.sumVol + b.v
+	count = v.count + 1
+	if v.n > 0 && v.pv.full() {
+		sumPV -= v.pv.oldest()
+		sumVol -= v.vol.oldest()
+		count = v.n
+	}
+	return
+}
+
+func (v *VWAP) write(ts int64, b bar, sumPV, sumVol float64) {
+	if sumVol > 0 {
+		v.value.Update(ts, sumPV/sumVol)
+	} else {
+		v.value.Update(ts, typicalPrice(b))
+	}
+}
+
+func (v *VWAP) update() {
+	v.feeder.feed(
+		v.kl.Close,
+		func(i int) {
+			b := v.kl.bar(i)
+			v.sumPV, v.sumVol, v.count = v.next(b)
+			if v.n > 0 {
+				v.pv.push(typicalPrice(b) * b.v)
+				v.vol.push(b.v)
+			}
+
+			if v.count >= v.WarmUpPeriod() {
+				v.ready = true
+				v.write(v.kl.Close.Times[i], b, v.sumPV, v.sumVol)
+			}
+		},
+		func(i int) {
+			b := v.kl.bar(i)
+			if sumPV, sumVol, count := v.next(b); count >= v.WarmUpPeriod() {
+				v.write(v.kl.Close.Times[i], b, sumPV, sumVol)
+			}
+		})
+}
+
+func (v *VWAP) Update() {
+	if v.rebuilding {
+		return
+	}
+	v.update()
+}
+
+func (v *VWAP) Rebuild() {
+	v.rebuilding = true
+	if v.n > 0 {
+		v.pv.reset()
+		v.vol.reset()
+	}
+	v.sumPV = 0
+	v.sumVol = 0
+	v.count = 0
+	v.feeder.reset()
+	v.value.Clear()
+	v.ready = false
+	v.update()
+	v.rebuilding = false
+}
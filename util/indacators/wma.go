@@ -0,0 +1,22 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 16:55:41
+ * @Description: 加权移动平均（流式），权重线性递增
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import "github.com/aztecqt/dagger/framework"
+
+type WMA struct {
+	singleLine
+	n int
+}
+
+func NewWMA(orign *framework.DataLine, n int) *WMA {
+	wma := new(WMA)
+	wma.n = n
+	wma.init(orign, "wma", newWmaCore(n), n)
+	return wma
+}
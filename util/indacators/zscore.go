@@ -0,0 +1,22 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 17:03:27
+ * @Description: 滚动z-score（流式）。(x - 均值) / 标准差，均值和标准差都包含当前值
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package indacators
+
+import "github.com/aztecqt/dagger/framework"
+
+type ZScore struct {
+	singleLine
+	n int
+}
+
+func NewZScore(orign *framework.DataLine, n int) *ZScore {
+	z := new(ZScore)
+	z.n = n
+	z.init(orign, "zscore", newZScoreCore(n), n)
+	return z
+}
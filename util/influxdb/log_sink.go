@@ -0,0 +1,43 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 04:12:36
+ * @Description: 把日志写入influx的logger.Sink，内容与SaveLog相同。一般只接Important级别，用于集中查看各机器的重要日志
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package influxdb
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/influxdata/influxdb/client/v2"
+)
+
+type LogSink struct {
+	conn  client.Client
+	level logger.LogLevel
+	enc   logger.Encoder
+}
+
+func NewLogSink(conn client.Client, lv logger.LogLevel) *LogSink {
+	return &LogSink{conn: conn, level: lv, enc: logger.ConsoleEncoder{}}
+}
+
+func (s *LogSink) Level() logger.LogLevel {
+	return s.level
+}
+
+func (s *LogSink) Write(e *logger.Entry) {
+	// 本包写入失败时也会输出日志，不能再写回influx
+	if e.Module == logPrefix {
+		return
+	}
+
+	buf := bytes.Buffer{}
+	s.enc.Encode(&buf, e)
+	SaveLog(s.conn, strings.TrimSuffix(buf.String(), "\n"))
+}
+
+func (s *LogSink) Close() {}
@@ -0,0 +1,111 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 03:48:12
+ * @Description: 日志编码。ConsoleEncoder与原来的文本格式一致，字段以key=value附在消息后；JSONEncoder每条日志一行json
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Encoder interface {
+	Encode(buf *bytes.Buffer, e *Entry) // 写入一行，包括结尾的换行
+}
+
+// 2006/01/02 15:04:05.000000 [module] msg k=v
+type ConsoleEncoder struct{}
+
+func (ConsoleEncoder) Encode(buf *bytes.Buffer, e *Entry) {
+	buf.WriteString(e.Time.Format("2006/01/02 15:04:05.000000"))
+	buf.WriteString(" [")
+	buf.WriteString(e.Module)
+	buf.WriteString("] ")
+	buf.WriteString(e.Msg)
+	for _, a := range e.Attrs {
+		buf.WriteByte(' ')
+		buf.WriteString(a.Key)
+		buf.WriteByte('=')
+		s := valueString(a.Value)
+		if strings.ContainsAny(s, " =\"\n") || len(s) == 0 {
+			s = strconv.Quote(s)
+		}
+		buf.WriteString(s)
+	}
+	buf.WriteByte('\n')
+}
+
+// {"time":"...","level":"info","module":"...","msg":"...",字段...}
+type JSONEncoder struct{}
+
+func (JSONEncoder) Encode(buf *bytes.Buffer, e *Entry) {
+	buf.WriteString(`{"time":`)
+	writeJsonString(buf, e.Time.Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJsonString(buf, LogLevel2String(e.Level))
+	buf.WriteString(`,"module":`)
+	writeJsonString(buf, e.Module)
+	buf.WriteString(`,"msg":`)
+	writeJsonString(buf, e.Msg)
+	for _, a := range e.Attrs {
+		buf.WriteByte(',')
+		writeJsonString(buf, a.Key)
+		buf.WriteByte(':')
+		writeJsonValue(buf, a.Value)
+	}
+	buf.WriteString("}\n")
+}
+
+func valueString(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+		return fmt.Sprint(v.Any())
+	default:
+		return v.String()
+	}
+}
+
+func writeJsonString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
+
+func writeJsonValue(buf *bytes.Buffer, v slog.Value) {
+	switch v.Kind() {
+	case slog.KindInt64:
+		buf.WriteString(strconv.FormatInt(v.Int64(), 10))
+	case slog.KindUint64:
+		buf.WriteString(strconv.FormatUint(v.Uint64(), 10))
+	case slog.KindFloat64:
+		if b, err := json.Marshal(v.Float64()); err == nil {
+			buf.Write(b)
+		} else {
+			writeJsonString(buf, v.String()) // NaN/Inf
+		}
+	case slog.KindBool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			writeJsonString(buf, err.Error())
+		} else if b, err := json.Marshal(v.Any()); err == nil {
+			buf.Write(b)
+		} else {
+			writeJsonString(buf, fmt.Sprint(v.Any()))
+		}
+	default:
+		writeJsonString(buf, valueString(v))
+	}
+}
@@ -5,8 +5,9 @@
   - @LastEditTime: 2024-04-30 12:22:28
  * @FilePath: \stratergy_antc:\work\svn\quant\go\src\dagger\util\logger\logger.go
  * @Description:
- * 日志管理器。基于go自带的log包。以hour/day为文件夹存储个个日志文件
- * 可以设置需要保留的日志文件夹个数以防止占用过多磁盘空间
+ * 日志管理器。以hour/day为单位存储各个日志文件，也可以按大小切分，旧文件可以压缩
+ * 可以设置需要保留的日志文件个数以防止占用过多磁盘空间
+ * LogInfo等旧接口以prefix为模块名，转为结构化日志输出，见structured.go
  *
  * Copyright (c) 2022 by aztec, All Rights Reserved.
 */
@@ -15,7 +16,6 @@ package logger
 
 import (
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"strconv"
@@ -30,10 +30,9 @@ const (
 	SplitMode_ByDays SplitMode = iota
 	SplitMode_ByHours
 	SplitMode_ByMinutes
+	SplitMode_None // 不按时间切分，只按大小
 )
 
-var splitMode = SplitMode_ByDays
-
 // 日志等级
 type LogLevel int
 
@@ -45,15 +44,40 @@ const (
 )
 
 func String2LogLevel(str string) LogLevel {
+	if lv, ok := ParseLogLevel(str); ok {
+		return lv
+	} else {
+		panic("invalid log level:" + str)
+	}
+}
+
+func ParseLogLevel(str string) (LogLevel, bool) {
 	switch strings.ToLower(str) {
 	case "debug":
-		return LogLevel_Debug
+		return LogLevel_Debug, true
 	case "info":
-		return LogLevel_Info
+		return LogLevel_Info, true
 	case "important":
-		return LogLevel_Important
+		return LogLevel_Important, true
+	case "none":
+		return LogLevel_None, true
 	default:
-		panic("invalid log level:" + str)
+		return LogLevel_None, false
+	}
+}
+
+func LogLevel2String(lv LogLevel) string {
+	switch lv {
+	case LogLevel_Debug:
+		return "debug"
+	case LogLevel_Info:
+		return "info"
+	case LogLevel_Important:
+		return "important"
+	case LogLevel_None:
+		return "none"
+	default:
+		return "unknown"
 	}
 }
 
@@ -68,33 +92,39 @@ func MinLogLevel() LogLevel {
 	}
 }
 
-// 最大日志文件数量
-var maxFileCount = 0
-
-// 当前日志文件名
+// 日志目录
 var fileDir string = "log/"
-var filePath string
-
-// 上次输出日志的时间
-var lastTime = time.Now()
-
-// go日志对象
-var fileLogger *log.Logger
-var consoleLogger *log.Logger
 
 // 初始化需要手动调用
 var inited bool
 
+// 默认文件日志的额外选项
+type InitOptions struct {
+	MaxSize  int64   // 单个文件的最大字节数，超过后切换到新文件。0表示不限
+	Compress bool    // 切换文件后gzip压缩旧文件
+	Encoder  Encoder // 文件日志的编码，默认为ConsoleEncoder
+}
+
 // 以字符串的方式初始化
 // 如: d3, h24, m120
 func InitByStr(periodConfig string) {
+	InitByStrWithOptions(periodConfig, InitOptions{})
+}
+
+func InitByStrWithOptions(periodConfig string, opt InitOptions) {
 	sMode := SplitMode_ByDays
-	if periodConfig[0] == 'd' {
+	if len(periodConfig) == 0 {
+		msg := "empty logger config str"
+		fmt.Println(msg)
+		panic(msg)
+	} else if periodConfig[0] == 'd' {
 		sMode = SplitMode_ByDays
 	} else if periodConfig[0] == 'h' {
 		sMode = SplitMode_ByHours
 	} else if periodConfig[0] == 'm' {
 		sMode = SplitMode_ByMinutes
+	} else if periodConfig[0] == 's' {
+		sMode = SplitMode_None
 	} else {
 		msg := fmt.Sprintf("invalid logger config str: %s\n", periodConfig)
 		time.Sleep(time.Second)
@@ -115,7 +145,7 @@ func InitByStr(periodConfig string) {
 		panic(msg)
 	}
 
-	Init(sMode, maxCount)
+	InitWithOptions(sMode, maxCount, opt)
 }
 
 func string2Int(s string) (int, bool) {
@@ -133,181 +163,79 @@ func string2Int(s string) (int, bool) {
 
 // 初始化
 func Init(sMode SplitMode, maxCount int) {
-	splitMode = sMode
-	maxFileCount = maxCount
-	switch splitMode {
+	InitWithOptions(sMode, maxCount, InitOptions{})
+}
+
+// 初始化默认的两个输出：文件(受FileLogLevel控制)和控制台(受ConsleLogLevel控制)
+func InitWithOptions(sMode SplitMode, maxCount int, opt InitOptions) {
+	switch sMode {
 	case SplitMode_ByDays:
 		fmt.Printf("log system initializing...set to keep %d days\n", maxCount)
 	case SplitMode_ByHours:
 		fmt.Printf("log system initializing...set to keep %d hours\n", maxCount)
 	case SplitMode_ByMinutes:
 		fmt.Printf("log system initializing...set to keep %d minutes\n", maxCount)
-	}
-
-	createLogDir()
-	createInnerLogger()
-	checkLogFileCount()
-	inited = true
-}
-
-// 当前时间对应的日志文件路径
-func nowFilePath() string {
-	path := ""
-	now := time.Now()
-	switch splitMode {
-	case SplitMode_ByDays:
-		path = fileDir + now.Format("2006-01-02.log")
-	case SplitMode_ByHours:
-		path = fileDir + now.Format("2006-01-02_15.log")
-	case SplitMode_ByMinutes:
-		path = fileDir + now.Format("2006-01-02_15-04.log")
-	}
-
-	return path
-}
-
-// 创建日志根目录
-func createLogDir() {
-	_, err := os.Stat(fileDir)
-	if os.IsNotExist(err) {
-		os.Mkdir(fileDir, os.ModePerm)
-	}
-}
-
-// 创建新的innerLogger
-func createInnerLogger() {
-	filePath = nowFilePath()
-	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
-	if err != nil {
-		log.Panicln("failed to create log file, path:", filePath)
-	} else {
-		fileLogger = log.New(file, "", log.Ldate|log.Ltime|log.Lmicroseconds)
-	}
-
-	consoleLogger = log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lmicroseconds)
-}
-
-// 当日志目录下的日志文件过多时，删除一部分日志文件
-func checkLogFileCount() {
-	fileInfos, err := ioutil.ReadDir(fileDir)
+	case SplitMode_None:
+		fmt.Printf("log system initializing...set to keep %d files\n", maxCount)
+	}
+
+	fs, err := NewFileSink(FileSinkConfig{
+		Dir:      fileDir,
+		Split:    sMode,
+		MaxSize:  opt.MaxSize,
+		MaxFiles: maxCount,
+		Compress: opt.Compress,
+		Encoder:  opt.Encoder,
+	})
 	if err != nil {
-		log.Panicln("failed to get log file list")
-	} else {
-		if len(fileInfos) > maxFileCount && maxFileCount > 0 {
-			for i := 0; i < len(fileInfos)-maxFileCount; i++ {
-				fullPath := fileDir + fileInfos[i].Name()
-				os.Remove(fullPath)
-			}
-		}
+		log.Panicln("failed to create log file:", err.Error())
 	}
-}
+	fs.levelFn = func() LogLevel { return FileLogLevel }
 
-// 判断是否跨越到下一个时间片
-func needNewInnerLogger() bool {
-	now := time.Now()
-	switch splitMode {
-	case SplitMode_ByDays:
-		return lastTime.YearDay() != now.YearDay()
-	case SplitMode_ByHours:
-		return lastTime.Hour() != now.Hour() || (now.Unix()-lastTime.Unix()) > 3600
-	case SplitMode_ByMinutes:
-		return lastTime.Minute() != time.Now().Minute() || (now.Unix()-lastTime.Unix()) > 60
-	}
+	cs := NewWriterSink(os.Stdout, ConsoleEncoder{}, LogLevel_Debug)
+	cs.levelFn = func() LogLevel { return ConsleLogLevel }
 
-	return false
+	Setup(fs, cs)
 }
 
-func doFileLog(msg *string) {
-	if !inited {
-		panic("call logger.Init first!")
+// #region 旧接口，转为结构化日志中的一条，prefix作为模块名
+func logf(lv LogLevel, prefix string, format string, a []interface{}) (string, bool) {
+	if !Enabled(prefix, lv) {
+		return "", false
 	}
 
-	if needNewInnerLogger() {
-		createInnerLogger()
-		checkLogFileCount()
+	msg := format
+	if len(a) > 0 {
+		msg = fmt.Sprintf(format, a...)
 	}
 
-	fileLogger.Println(*msg)
+	dispatch(&Entry{Time: time.Now(), Level: lv, Module: prefix, Msg: msg})
+	return msg, true
 }
 
 func LogDebug(prefix string, format string, a ...interface{}) {
-	if FileLogLevel <= LogLevel_Debug || ConsleLogLevel <= LogLevel_Debug {
-		msg := ""
-		if len(a) > 0 {
-			msg = fmt.Sprintf(format, a...)
-			msg = fmt.Sprintf("[%s] %s", prefix, msg)
-		} else {
-			msg = fmt.Sprintf("[%s] %s", prefix, format)
-		}
-
-		if FileLogLevel <= LogLevel_Debug {
-			doFileLog(&msg)
-		}
-
-		if ConsleLogLevel <= LogLevel_Debug {
-			consoleLogger.Println(msg)
-		}
-	}
+	logf(LogLevel_Debug, prefix, format, a)
 }
 
 func LogInfo(prefix string, format string, a ...interface{}) {
-	if FileLogLevel <= LogLevel_Info || ConsleLogLevel <= LogLevel_Info {
-		msg := ""
-		if len(a) > 0 {
-			msg = fmt.Sprintf(format, a...)
-			msg = fmt.Sprintf("[%s] %s", prefix, msg)
-		} else {
-			msg = fmt.Sprintf("[%s] %s", prefix, format)
-		}
-
-		if FileLogLevel <= LogLevel_Info {
-			doFileLog(&msg)
-		}
-
-		if ConsleLogLevel <= LogLevel_Info {
-			consoleLogger.Println(msg)
-		}
-	}
+	logf(LogLevel_Info, prefix, format, a)
 }
 
 func LogImportant(prefix string, format string, a ...interface{}) {
-	if FileLogLevel <= LogLevel_Important || ConsleLogLevel <= LogLevel_Important {
-		msg := ""
-		if len(a) > 0 {
-			msg = fmt.Sprintf(format, a...)
-			msg = fmt.Sprintf("[%s] %s", prefix, msg)
-		} else {
-			msg = fmt.Sprintf("[%s] %s", prefix, format)
-		}
-
-		if FileLogLevel <= LogLevel_Important {
-			doFileLog(&msg)
-		}
-
-		if ConsleLogLevel <= LogLevel_Important {
-			consoleLogger.Println(msg)
-		}
-	}
+	logf(LogLevel_Important, prefix, format, a)
 }
 
 func LogPanic(prefix string, format string, a ...interface{}) {
-	if FileLogLevel <= LogLevel_Important || ConsleLogLevel <= LogLevel_Important {
-		msg := ""
-		if len(a) > 0 {
-			msg = fmt.Sprintf(format, a...)
-			msg = fmt.Sprintf("[%s] %s", prefix, msg)
-		} else {
-			msg = fmt.Sprintf("[%s] %s", prefix, format)
-		}
-
-		if FileLogLevel <= LogLevel_Important {
-			doFileLog(&msg)
-		}
-
-		if ConsleLogLevel <= LogLevel_Important {
-			consoleLogger.Println(msg)
-		}
+	if msg, ok := logf(LogLevel_Important, prefix, format, a); ok {
+		panic(fmt.Sprintf("[%s] %s", prefix, msg))
+	}
+}
 
-		panic(msg)
+// 按模块和等级生成一个func(string)，用于对接只接受日志函数的模块
+func Fn(module string, lv LogLevel) func(string) {
+	return func(msg string) {
+		logf(lv, module, msg, nil)
 	}
 }
+
+// #endregion
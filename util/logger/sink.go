@@ -0,0 +1,303 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 03:58:27
+ * @Description: 内置的两种Sink：WriterSink写入任意io.Writer（如控制台），FileSink写入日志目录并负责切分、压缩和清理
+ * FileSink的文件名为时间片+序号，如2026-10-19.log、2026-10-19.1.log。时间片变化或文件超过MaxSize时切换到新文件
+ * 文件数超过MaxFiles时按修改时间删除最旧的
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// #region WriterSink
+type WriterSink struct {
+	w       io.Writer
+	enc     Encoder
+	levelFn func() LogLevel
+	buf     bytes.Buffer
+	mu      sync.Mutex
+}
+
+func NewWriterSink(w io.Writer, enc Encoder, lv LogLevel) *WriterSink {
+	s := &WriterSink{w: w, enc: enc}
+	s.SetLevel(lv)
+	return s
+}
+
+func NewStdoutSink(enc Encoder, lv LogLevel) *WriterSink {
+	return NewWriterSink(os.Stdout, enc, lv)
+}
+
+func (s *WriterSink) SetLevel(lv LogLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.levelFn = func() LogLevel { return lv }
+}
+
+func (s *WriterSink) Level() LogLevel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.levelFn()
+}
+
+func (s *WriterSink) Write(e *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf.Reset()
+	s.enc.Encode(&s.buf, e)
+	s.w.Write(s.buf.Bytes())
+}
+
+func (s *WriterSink) Close() {}
+
+// #endregion
+
+// #region FileSink
+type FileSinkConfig struct {
+	Dir      string    // 日志目录，默认log/
+	Split    SplitMode // 按时间切分的方式
+	MaxSize  int64     // 单个文件的最大字节数，0表示不限
+	MaxFiles int       // 最多保留的文件数（含压缩后的），0表示不限
+	Compress bool      // 切换后gzip压缩旧文件
+	Encoder  Encoder   // 默认ConsoleEncoder
+	Level    LogLevel
+}
+
+type FileSink struct {
+	cfg     FileSinkConfig
+	levelFn func() LogLevel
+	start   time.Time
+
+	file   *os.File
+	path   string
+	period string
+	index  int
+	size   int64
+	buf    bytes.Buffer
+	closed bool
+	mu     sync.Mutex
+}
+
+func NewFileSink(cfg FileSinkConfig) (*FileSink, error) {
+	if len(cfg.Dir) == 0 {
+		cfg.Dir = "log/"
+	}
+	if cfg.Encoder == nil {
+		cfg.Encoder = ConsoleEncoder{}
+	}
+
+	if err := os.MkdirAll(cfg.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	s := &FileSink{cfg: cfg, start: time.Now()}
+	s.SetLevel(cfg.Level)
+	if err := s.open(s.periodOf(time.Now()), 0); err != nil {
+		return nil, err
+	}
+	s.cleanup()
+	return s, nil
+}
+
+func (s *FileSink) SetLevel(lv LogLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.levelFn = func() LogLevel { return lv }
+}
+
+func (s *FileSink) Level() LogLevel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.levelFn()
+}
+
+// 当前文件路径
+func (s *FileSink) Path() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.path
+}
+
+func (s *FileSink) Write(e *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	} else if s.file == nil {
+		// 上次切换失败，重试
+		if s.open(s.periodOf(e.Time), s.index) != nil {
+			return
+		}
+	}
+
+	if p := s.periodOf(e.Time); p > s.period {
+		s.rotate(p, 0)
+	} else if s.cfg.MaxSize > 0 && s.size >= s.cfg.MaxSize {
+		s.rotate(s.period, s.index+1)
+	}
+
+	s.buf.Reset()
+	s.cfg.Encoder.Encode(&s.buf, e)
+	if n, err := s.file.Write(s.buf.Bytes()); err == nil {
+		s.size += int64(n)
+	}
+}
+
+func (s *FileSink) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+}
+
+func (s *FileSink) periodOf(t time.Time) string {
+	switch s.cfg.Split {
+	case SplitMode_ByHours:
+		return t.Format("2006-01-02_15")
+	case SplitMode_ByMinutes:
+		return t.Format("2006-01-02_15-04")
+	case SplitMode_None:
+		return s.start.Format("2006-01-02_15-04-05")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+func (s *FileSink) pathOf(period string, index int) string {
+	if index == 0 {
+		return filepath.Join(s.cfg.Dir, period+".log")
+	} else {
+		return filepath.Join(s.cfg.Dir, fmt.Sprintf("%s.%d.log", period, index))
+	}
+}
+
+// 打开文件。重启后接着写同一时间片时，跳过已满或已压缩的文件
+func (s *FileSink) open(period string, index int) error {
+	path := s.pathOf(period, index)
+	for {
+		if _, err := os.Stat(path + ".gz"); err == nil {
+			index++
+		} else if fi, err := os.Stat(path); err == nil && s.cfg.MaxSize > 0 && fi.Size() >= s.cfg.MaxSize {
+			index++
+		} else {
+			break
+		}
+		path = s.pathOf(period, index)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+
+	size := int64(0)
+	if fi, err := f.Stat(); err == nil {
+		size = fi.Size()
+	}
+
+	s.file, s.path, s.period, s.index, s.size = f, path, period, index, size
+	return nil
+}
+
+func (s *FileSink) rotate(period string, index int) {
+	oldPath := s.path
+	s.file.Close()
+	s.file = nil
+
+	if err := s.open(period, index); err != nil {
+		fmt.Printf("failed to create log file %s: %s\n", s.pathOf(period, index), err.Error())
+		return
+	}
+
+	if s.cfg.Compress {
+		go func() {
+			compressFile(oldPath)
+			s.cleanup()
+		}()
+	} else {
+		go s.cleanup()
+	}
+}
+
+// 删除多余的旧文件
+func (s *FileSink) cleanup() {
+	if s.cfg.MaxFiles <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return
+	}
+
+	type logFile struct {
+		path  string
+		mtime time.Time
+	}
+
+	current := s.Path()
+	files := make([]logFile, 0)
+	for _, de := range entries {
+		name := de.Name()
+		if de.IsDir() || !(strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")) {
+			continue
+		}
+		path := filepath.Join(s.cfg.Dir, name)
+		if path == current {
+			continue
+		}
+		if fi, err := de.Info(); err == nil {
+			files = append(files, logFile{path: path, mtime: fi.ModTime()})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime.Before(files[j].mtime) })
+	for i := 0; i < len(files)-(s.cfg.MaxFiles-1); i++ {
+		os.Remove(files[i].path)
+	}
+}
+
+// 压缩为path.gz并删除原文件
+func compressFile(path string) {
+	src, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	dst.Close()
+
+	if err == nil {
+		os.Remove(path)
+	} else {
+		os.Remove(path + ".gz")
+	}
+}
+
+// #endregion
@@ -0,0 +1,305 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 03:32:50
+ * @Description: 结构化日志。每条日志是一个Entry：时间、等级、模块、消息和若干字段
+ * 日志分发给所有已注册的Sink（文件、控制台、influx等），每个Sink有自己的等级
+ * 可以按模块设置等级（前缀匹配，最长优先），设置后该模块的日志在所有Sink上都使用这个等级，运行时可调
+ * New返回一个*slog.Logger，可以携带exchange、instId等字段；旧的LogInfo等接口输出没有字段的Entry
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 常用字段名
+const (
+	FieldKey_Exchange      = "exchange"
+	FieldKey_InstId        = "instId"
+	FieldKey_ClientOrderId = "clientOrderId"
+	FieldKey_Strategy      = "strategy"
+)
+
+type Entry struct {
+	Time   time.Time
+	Level  LogLevel
+	Module string
+	Msg    string
+	Attrs  []slog.Attr // 已展开，不含group
+}
+
+// 日志输出目标
+type Sink interface {
+	Level() LogLevel
+	Write(e *Entry)
+	Close()
+}
+
+var sinks []Sink
+var muSinks sync.RWMutex
+
+// 用指定的Sink替换当前所有Sink（旧的会被关闭）
+func Setup(ss ...Sink) {
+	muSinks.Lock()
+	old := sinks
+	sinks = ss
+	inited = true
+	muSinks.Unlock()
+
+	for _, s := range old {
+		s.Close()
+	}
+}
+
+func AddSink(s Sink) {
+	muSinks.Lock()
+	defer muSinks.Unlock()
+	sinks = append(sinks, s)
+}
+
+func RemoveSink(s Sink) {
+	muSinks.Lock()
+	for i, ss := range sinks {
+		if ss == s {
+			sinks = append(sinks[:i:i], sinks[i+1:]...)
+			break
+		}
+	}
+	muSinks.Unlock()
+	s.Close()
+}
+
+// 关闭所有Sink，进程退出前调用以确保文件写完
+func Close() {
+	Setup()
+}
+
+func dispatch(e *Entry) {
+	muSinks.RLock()
+	defer muSinks.RUnlock()
+
+	if !inited {
+		panic("call logger.Init first!")
+	}
+
+	ml, hasML := moduleLevel(e.Module)
+	for _, s := range sinks {
+		th := s.Level()
+		if hasML {
+			th = ml
+		}
+		if e.Level >= th {
+			s.Write(e)
+		}
+	}
+}
+
+// 某个模块的某个等级是否会被输出，用于在格式化之前过滤
+func Enabled(module string, lv LogLevel) bool {
+	if ml, ok := moduleLevel(module); ok {
+		return lv >= ml
+	}
+
+	muSinks.RLock()
+	defer muSinks.RUnlock()
+	if !inited {
+		return true // 让dispatch报错
+	}
+	for _, s := range sinks {
+		if lv >= s.Level() {
+			return true
+		}
+	}
+	return false
+}
+
+// #region 模块等级
+var moduleLevels = make(map[string]LogLevel)
+var muModuleLevels sync.RWMutex
+
+// 设置模块等级。module按前缀匹配，如"Order-"匹配所有订单的日志
+func SetModuleLevel(module string, lv LogLevel) {
+	muModuleLevels.Lock()
+	defer muModuleLevels.Unlock()
+	moduleLevels[module] = lv
+}
+
+func ResetModuleLevel(module string) {
+	muModuleLevels.Lock()
+	defer muModuleLevels.Unlock()
+	delete(moduleLevels, module)
+}
+
+func ModuleLevels() map[string]LogLevel {
+	muModuleLevels.RLock()
+	defer muModuleLevels.RUnlock()
+	m := make(map[string]LogLevel)
+	for k, v := range moduleLevels {
+		m[k] = v
+	}
+	return m
+}
+
+func moduleLevel(module string) (LogLevel, bool) {
+	muModuleLevels.RLock()
+	defer muModuleLevels.RUnlock()
+	if len(moduleLevels) == 0 {
+		return 0, false
+	}
+
+	best, lv, found := -1, LogLevel_Debug, false
+	for k, v := range moduleLevels {
+		if strings.HasPrefix(module, k) && len(k) > best {
+			best, lv, found = len(k), v, true
+		}
+	}
+	return lv, found
+}
+
+// 处理命令行：loglv [module] [debug/info/important/none/reset]
+// 无参数时列出当前的模块等级
+func OnCommand(args []string) string {
+	switch len(args) {
+	case 0:
+		mls := ModuleLevels()
+		if len(mls) == 0 {
+			return "no module level set"
+		}
+		keys := make([]string, 0, len(mls))
+		for k := range mls {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sb := strings.Builder{}
+		for _, k := range keys {
+			sb.WriteString(k)
+			sb.WriteString(": ")
+			sb.WriteString(LogLevel2String(mls[k]))
+			sb.WriteString("\n")
+		}
+		return sb.String()
+	case 2:
+		if args[1] == "reset" {
+			ResetModuleLevel(args[0])
+			return "module level of " + args[0] + " reset"
+		} else if lv, ok := ParseLogLevel(args[1]); ok {
+			SetModuleLevel(args[0], lv)
+			return "module level of " + args[0] + " set to " + LogLevel2String(lv)
+		} else {
+			return "invalid log level: " + args[1]
+		}
+	default:
+		return "usage: loglv [module] [debug/info/important/none/reset]"
+	}
+}
+
+// #endregion
+
+// #region slog
+func levelFromSlog(l slog.Level) LogLevel {
+	if l < slog.LevelInfo {
+		return LogLevel_Debug
+	} else if l < slog.LevelWarn {
+		return LogLevel_Info
+	} else {
+		return LogLevel_Important
+	}
+}
+
+// slog.Handler的实现，输出到当前所有Sink
+type Handler struct {
+	module string
+	attrs  []slog.Attr
+	group  string
+}
+
+func NewHandler(module string) *Handler {
+	return &Handler{module: module}
+}
+
+func (h *Handler) Enabled(_ context.Context, l slog.Level) bool {
+	return Enabled(h.module, levelFromSlog(l))
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	e := &Entry{Time: r.Time, Level: levelFromSlog(r.Level), Module: h.module, Msg: r.Message}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	e.Attrs = make([]slog.Attr, 0, len(h.attrs)+r.NumAttrs())
+	e.Attrs = append(e.Attrs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		e.Attrs = appendAttr(e.Attrs, h.group, a)
+		return true
+	})
+
+	dispatch(e)
+	return nil
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	h2.attrs = append(h2.attrs, h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = appendAttr(h2.attrs, h.group, a)
+	}
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if len(name) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.group = joinKey(h.group, name)
+	return &h2
+}
+
+// 展开group，key用.连接
+func appendAttr(attrs []slog.Attr, group string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return attrs
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		g := joinKey(group, a.Key)
+		for _, ga := range a.Value.Group() {
+			attrs = appendAttr(attrs, g, ga)
+		}
+		return attrs
+	}
+
+	a.Key = joinKey(group, a.Key)
+	return append(attrs, a)
+}
+
+func joinKey(group, key string) string {
+	if len(group) == 0 {
+		return key
+	} else if len(key) == 0 {
+		return group
+	} else {
+		return group + "." + key
+	}
+}
+
+// 某个模块的日志对象，args为附加的字段，如New("okexv5", logger.FieldKey_InstId, "BTC-USDT")
+func New(module string, args ...any) *slog.Logger {
+	return slog.New(NewHandler(module)).With(args...)
+}
+
+// 将slog的默认日志对象指向本包，使slog.Info等也输出到这里
+func SetSlogDefault(module string) {
+	slog.SetDefault(New(module))
+}
+
+// #endregion
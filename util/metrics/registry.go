@@ -0,0 +1,541 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 02:20:41
+ * @Description: 指标注册表，输出Prometheus文本格式(0.0.4)，不依赖prometheus客户端库
+ * 两种指标来源：
+ * 1. Counter/Gauge/Histogram，由业务代码主动更新。按名字注册，同名重复注册返回同一个对象
+ * 2. Collector，每次抓取时回调，用于把已有的统计数据（仓位、连接状态等）转成指标
+ * 策略可以直接用Default上的NewCounter/NewGauge等注册自定义指标。Gather可以在程序内读取所有指标的当前值
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aztecqt/dagger/util"
+)
+
+type MetricType string
+
+const (
+	MetricType_Counter   MetricType = "counter"
+	MetricType_Gauge     MetricType = "gauge"
+	MetricType_Histogram MetricType = "histogram"
+)
+
+// 默认的延迟分桶（秒）
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Collector每次抓取时被调用，通过Emitter输出指标
+type Collector func(e *Emitter)
+
+type Registry struct {
+	families   map[string]*family
+	collectors map[string]Collector
+	mu         sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families:   make(map[string]*family),
+		collectors: make(map[string]Collector),
+	}
+}
+
+// 全局注册表
+var Default = NewRegistry()
+
+// #region 主动更新的指标
+type family struct {
+	name       string
+	help       string
+	typ        MetricType
+	labelNames []string
+	buckets    []float64
+	series     map[string]*series
+	mu         sync.Mutex
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64 // histogram各分桶（不累加）
+	count       uint64
+	sum         float64
+	mu          sync.Mutex
+}
+
+func (f *family) with(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %s needs %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string{}, labelValues...)}
+		if f.typ == MetricType_Histogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (r *Registry) family(name, help string, typ MetricType, buckets []float64, labelNames []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.typ != typ || len(f.labelNames) != len(labelNames) {
+			panic(fmt.Sprintf("metric %s already registered with different type or labels", name))
+		}
+		return f
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// 删除某个指标，之后可以用不同的标签重新注册
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.families, name)
+}
+
+// 只增不减的计数
+type CounterVec struct{ f *family }
+type Counter struct{ s *series }
+
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{f: r.family(name, help, MetricType_Counter, nil, labelNames)}
+}
+
+// 按标签值取一个计数器，标签值顺序与注册时的标签名一致
+func (v *CounterVec) With(labelValues ...string) Counter {
+	return Counter{s: v.f.with(labelValues)}
+}
+
+func (c Counter) Inc() {
+	c.Add(1)
+}
+
+func (c Counter) Add(d float64) {
+	if d < 0 {
+		return
+	}
+	c.s.mu.Lock()
+	c.s.value += d
+	c.s.mu.Unlock()
+}
+
+// 可增可减的值
+type GaugeVec struct{ f *family }
+type Gauge struct{ s *series }
+
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{f: r.family(name, help, MetricType_Gauge, nil, labelNames)}
+}
+
+func (v *GaugeVec) With(labelValues ...string) Gauge {
+	return Gauge{s: v.f.with(labelValues)}
+}
+
+// 删除一组标签值，用于对象消失后不再输出
+func (v *GaugeVec) Delete(labelValues ...string) {
+	v.f.mu.Lock()
+	defer v.f.mu.Unlock()
+	delete(v.f.series, strings.Join(labelValues, "\xff"))
+}
+
+func (g Gauge) Set(val float64) {
+	g.s.mu.Lock()
+	g.s.value = val
+	g.s.mu.Unlock()
+}
+
+func (g Gauge) Add(d float64) {
+	g.s.mu.Lock()
+	g.s.value += d
+	g.s.mu.Unlock()
+}
+
+func (g Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g Gauge) Dec() {
+	g.Add(-1)
+}
+
+// 分桶统计，用于延迟等分布
+type HistogramVec struct{ f *family }
+type Histogram struct {
+	s       *series
+	buckets []float64
+}
+
+// buckets为各分桶上界，升序，为空时使用DefaultBuckets
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return &HistogramVec{f: r.family(name, help, MetricType_Histogram, buckets, labelNames)}
+}
+
+func (v *HistogramVec) With(labelValues ...string) Histogram {
+	return Histogram{s: v.f.with(labelValues), buckets: v.f.buckets}
+}
+
+func (h Histogram) Observe(val float64) {
+	i := sort.SearchFloat64s(h.buckets, val)
+	h.s.mu.Lock()
+	if i < len(h.s.counts) {
+		h.s.counts[i]++
+	}
+	h.s.count++
+	h.s.sum += val
+	h.s.mu.Unlock()
+}
+
+// #endregion
+
+// #region Collector
+// 注册一个采集回调。同名的回调会被替换
+func (r *Registry) RegisterCollector(name string, c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors[name] = c
+}
+
+func (r *Registry) UnregisterCollector(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.collectors, name)
+}
+
+// 注册一个由函数取值的gauge
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.RegisterCollector(name, func(e *Emitter) {
+		e.Gauge(name, help, fn())
+	})
+}
+
+type sample struct {
+	names  []string
+	values []string
+	value  float64
+}
+
+type emitted struct {
+	help    string
+	typ     MetricType
+	samples []sample
+}
+
+// 采集回调的输出。labels为成对的标签名和标签值
+type Emitter struct {
+	families map[string]*emitted
+}
+
+func (e *Emitter) Gauge(name, help string, val float64, labels ...string) {
+	e.add(name, help, MetricType_Gauge, val, labels)
+}
+
+func (e *Emitter) Counter(name, help string, val float64, labels ...string) {
+	e.add(name, help, MetricType_Counter, val, labels)
+}
+
+func (e *Emitter) add(name, help string, typ MetricType, val float64, labels []string) {
+	f, ok := e.families[name]
+	if !ok {
+		f = &emitted{help: help, typ: typ}
+		e.families[name] = f
+	}
+
+	names := make([]string, 0, len(labels)/2)
+	values := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		names = append(names, labels[i])
+		values = append(values, labels[i+1])
+	}
+	f.samples = append(f.samples, sample{names: names, values: values, value: val})
+}
+
+// 单个回调出错不影响其他指标
+func (e *Emitter) collect(c Collector) {
+	defer util.DefaultRecover()
+	c(e)
+}
+
+// #endregion
+
+// #region 输出
+// 以Prometheus文本格式输出所有指标，按名字排序
+func (r *Registry) Write(w io.Writer) error {
+	families, e := r.snapshot()
+
+	lines := make(map[string][]string)
+	for _, f := range families {
+		lines[f.name] = f.lines()
+	}
+	for name, f := range e.families {
+		lines[name] = f.lines(name)
+	}
+
+	names := make([]string, 0, len(lines))
+	for name := range lines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		for _, l := range lines[name] {
+			bw.WriteString(l)
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// 取出所有主动更新的指标，并调用所有采集回调。同名时采集回调优先
+func (r *Registry) snapshot() ([]*family, *Emitter) {
+	r.mu.Lock()
+	all := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		all = append(all, f)
+	}
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mu.Unlock()
+
+	e := &Emitter{families: make(map[string]*emitted)}
+	for _, c := range collectors {
+		e.collect(c)
+	}
+
+	families := make([]*family, 0, len(all))
+	for _, f := range all {
+		if _, ok := e.families[f.name]; !ok {
+			families = append(families, f)
+		}
+	}
+	return families, e
+}
+
+// 程序内读取的采样值。histogram只给出_count和_sum
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+func labelMap(names, values []string) map[string]string {
+	m := make(map[string]string, len(names))
+	for i, n := range names {
+		m[n] = values[i]
+	}
+	return m
+}
+
+// 所有指标的当前值，用于告警规则等
+func (r *Registry) Gather() []Sample {
+	families, e := r.snapshot()
+
+	samples := make([]Sample, 0)
+	for _, f := range families {
+		f.mu.Lock()
+		for _, s := range f.series {
+			s.mu.Lock()
+			labels := labelMap(f.labelNames, s.labelValues)
+			if f.typ == MetricType_Histogram {
+				samples = append(samples, Sample{Name: f.name + "_count", Labels: labels, Value: float64(s.count)})
+				samples = append(samples, Sample{Name: f.name + "_sum", Labels: labels, Value: s.sum})
+			} else {
+				samples = append(samples, Sample{Name: f.name, Labels: labels, Value: s.value})
+			}
+			s.mu.Unlock()
+		}
+		f.mu.Unlock()
+	}
+
+	for name, f := range e.families {
+		for _, s := range f.samples {
+			samples = append(samples, Sample{Name: name, Labels: labelMap(s.names, s.values), Value: s.value})
+		}
+	}
+	return samples
+}
+
+// 用于注册到web服务的/metrics
+func (r *Registry) Handler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	}
+}
+
+func header(name, help string, typ MetricType) []string {
+	return []string{
+		fmt.Sprintf("# HELP %s %s", name, escapeHelp(help)),
+		fmt.Sprintf("# TYPE %s %s", name, typ),
+	}
+}
+
+func (f *family) lines() []string {
+	f.mu.Lock()
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+	f.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].labelValues, "\xff") < strings.Join(all[j].labelValues, "\xff")
+	})
+
+	ls := header(f.name, f.help, f.typ)
+	for _, s := range all {
+		s.mu.Lock()
+		if f.typ == MetricType_Histogram {
+			cum := uint64(0)
+			for i, b := range f.buckets {
+				cum += s.counts[i]
+				ls = append(ls, fmt.Sprintf("%s_bucket%s %d", f.name, formatLabels(f.labelNames, s.labelValues, "le", formatFloat(b)), cum))
+			}
+			ls = append(ls, fmt.Sprintf("%s_bucket%s %d", f.name, formatLabels(f.labelNames, s.labelValues, "le", "+Inf"), s.count))
+			ls = append(ls, fmt.Sprintf("%s_sum%s %s", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), formatFloat(s.sum)))
+			ls = append(ls, fmt.Sprintf("%s_count%s %d", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), s.count))
+		} else {
+			ls = append(ls, fmt.Sprintf("%s%s %s", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), formatFloat(s.value)))
+		}
+		s.mu.Unlock()
+	}
+	return ls
+}
+
+func (f *emitted) lines(name string) []string {
+	labels := make([]string, len(f.samples))
+	idx := make([]int, len(f.samples))
+	for i, s := range f.samples {
+		labels[i] = formatLabels(s.names, s.values, "", "")
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return labels[idx[i]] < labels[idx[j]] })
+
+	ls := header(name, f.help, f.typ)
+	for _, i := range idx {
+		ls = append(ls, fmt.Sprintf("%s%s %s", name, labels[i], formatFloat(f.samples[i].value)))
+	}
+	return ls
+}
+
+// 生成{a="1",b="2"}，extraName不为空时追加在最后（用于histogram的le）
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && len(extraName) == 0 {
+		return ""
+	}
+
+	sb := strings.Builder{}
+	sb.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(n)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(values[i]))
+		sb.WriteByte('"')
+	}
+	if len(extraName) > 0 {
+		if len(names) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extraName)
+		sb.WriteString(`="`)
+		sb.WriteString(extraValue)
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// #endregion
+
+// #region Default上的快捷函数
+func NewCounter(name, help string, labelNames ...string) *CounterVec {
+	return Default.NewCounter(name, help, labelNames...)
+}
+
+func NewGauge(name, help string, labelNames ...string) *GaugeVec {
+	return Default.NewGauge(name, help, labelNames...)
+}
+
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return Default.NewHistogram(name, help, buckets, labelNames...)
+}
+
+func NewGaugeFunc(name, help string, fn func() float64) {
+	Default.NewGaugeFunc(name, help, fn)
+}
+
+func RegisterCollector(name string, c Collector) {
+	Default.RegisterCollector(name, c)
+}
+
+func UnregisterCollector(name string) {
+	Default.UnregisterCollector(name)
+}
+
+func Handler() func(http.ResponseWriter, *http.Request) {
+	return Default.Handler()
+}
+
+// #endregion
@@ -0,0 +1,46 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 02:41:07
+ * @Description: go运行时指标：协程数、内存、gc、进程启动时间
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package metrics
+
+import (
+	"runtime"
+	"time"
+)
+
+var processStart = time.Now()
+
+// 注册运行时指标采集
+func (r *Registry) RegisterRuntimeCollector() {
+	r.RegisterCollector("go_runtime", collectRuntime)
+}
+
+func RegisterRuntimeCollector() {
+	Default.RegisterRuntimeCollector()
+}
+
+func collectRuntime(e *Emitter) {
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+
+	e.Gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	e.Gauge("go_threads", "Number of OS threads created.", float64(threadCount()))
+	e.Gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(ms.Alloc))
+	e.Gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(ms.HeapInuse))
+	e.Gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(ms.HeapObjects))
+	e.Gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(ms.Sys))
+	e.Counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(ms.TotalAlloc))
+	e.Counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(ms.NumGC))
+	e.Counter("go_gc_pause_seconds_total", "Total GC stop-the-world pause time.", time.Duration(ms.PauseTotalNs).Seconds())
+	e.Gauge("go_gc_last_pause_seconds", "Duration of the most recent GC pause.", time.Duration(ms.PauseNs[(ms.NumGC+255)%256]).Seconds())
+	e.Gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", float64(processStart.Unix()))
+}
+
+func threadCount() int {
+	n, _ := runtime.ThreadCreateProfile(nil)
+	return n
+}
@@ -0,0 +1,431 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-18 23:35:20
+ * @Description: 可配置的http客户端。连接/读取超时、长连接复用、http2、按host选择代理、GET请求的退避重试、延迟和状态码统计
+ * 客户端按host登记，ParseHttpResult/HttpCall根据url的host选取。未登记的host使用默认配置创建
+ * 每个请求的context都派生自客户端的根context，Close时会取消所有进行中的请求
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type HttpClientConfig struct {
+	ConnectTimeout      time.Duration     // 建立连接（含tls握手）的超时
+	ReadTimeout         time.Duration     // 单次请求从发出到读完body的总超时
+	KeepAlive           time.Duration     // tcp keep-alive间隔
+	IdleConnTimeout     time.Duration     // 空闲连接保留时间
+	MaxIdleConnsPerHost int               // 每个host保留的空闲连接数
+	DisableHttp2        bool              // 默认尝试http2
+	Proxies             map[string]string // host->代理地址，如socks5://user:pass@ip:port。"*"表示其他所有host，为空则使用环境变量中的代理
+	MaxRetry            int               // GET请求在网络错误、429、5xx时的最大重试次数
+	RetryBaseDelay      time.Duration     // 重试的基础等待时间，每次翻倍并加上随机抖动
+}
+
+func DefaultHttpClientConfig() HttpClientConfig {
+	return HttpClientConfig{
+		ConnectTimeout:      time.Second * 5,
+		ReadTimeout:         time.Second * 10,
+		KeepAlive:           time.Second * 30,
+		IdleConnTimeout:     time.Second * 90,
+		MaxIdleConnsPerHost: 16,
+		MaxRetry:            2,
+		RetryBaseDelay:      time.Millisecond * 200,
+	}
+}
+
+type HttpClient struct {
+	name   string
+	cfg    HttpClientConfig
+	client *http.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	cookies   []http.Cookie
+	muCookies sync.RWMutex
+
+	proxies       map[string]*url.URL
+	proxySelector ProxySelector
+	muProxies     sync.RWMutex
+
+	stats   map[string]*HttpStat
+	muStats sync.Mutex
+}
+
+func NewHttpClient(name string, cfg HttpClientConfig) *HttpClient {
+	c := new(HttpClient)
+	c.name = name
+	c.cfg = cfg
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.stats = make(map[string]*HttpStat)
+	c.proxies = make(map[string]*url.URL)
+	for host, addr := range cfg.Proxies {
+		c.SetProxy(host, addr)
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: cfg.KeepAlive}
+	transport := &http.Transport{
+		Proxy:               c.proxyOf,
+		DialContext:         dialer.DialContext,
+		ForceAttemptHTTP2:   !cfg.DisableHttp2,
+		TLSHandshakeTimeout: cfg.ConnectTimeout,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+	}
+	c.client = &http.Client{Transport: transport}
+	return c
+}
+
+func (c *HttpClient) Name() string {
+	return c.name
+}
+
+// 取消所有进行中的请求。之后的请求会立即失败
+func (c *HttpClient) Close() {
+	c.cancel()
+	c.client.CloseIdleConnections()
+}
+
+// #region cookie
+func (c *HttpClient) AddCookie(cookie http.Cookie) {
+	c.muCookies.Lock()
+	defer c.muCookies.Unlock()
+	c.cookies = append(c.cookies, cookie)
+}
+
+func (c *HttpClient) SetCookies(v []http.Cookie) {
+	c.muCookies.Lock()
+	defer c.muCookies.Unlock()
+	c.cookies = v
+}
+
+func (c *HttpClient) ClearCookies() {
+	c.muCookies.Lock()
+	defer c.muCookies.Unlock()
+	c.cookies = nil
+}
+
+// #endregion
+
+// #region 代理
+// 动态选择代理，如代理池。返回nil表示没有可用代理，此时使用环境变量中的代理
+type ProxySelector interface {
+	ProxyFor(host string) *url.URL
+}
+
+// 设置代理选择器，优先级低于SetProxy指定的代理。s为nil表示取消
+func (c *HttpClient) SetProxySelector(s ProxySelector) {
+	c.muProxies.Lock()
+	defer c.muProxies.Unlock()
+	c.proxySelector = s
+}
+
+// 设置某个host使用的代理，host为"*"表示默认代理，addr为空表示取消
+func (c *HttpClient) SetProxy(host, addr string) error {
+	c.muProxies.Lock()
+	defer c.muProxies.Unlock()
+
+	if len(addr) == 0 {
+		delete(c.proxies, host)
+		return nil
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return err
+	}
+	c.proxies[host] = u
+	return nil
+}
+
+func (c *HttpClient) proxyOf(req *http.Request) (*url.URL, error) {
+	c.muProxies.RLock()
+	defer c.muProxies.RUnlock()
+
+	if u, ok := c.proxies[req.URL.Hostname()]; ok {
+		return u, nil
+	} else if u, ok := c.proxies["*"]; ok {
+		return u, nil
+	} else {
+		return ProxyOf(c.proxySelector, req)
+	}
+}
+
+// 由选择器决定请求使用的代理，选择器为空或没有可用代理时使用环境变量中的代理
+func ProxyOf(s ProxySelector, req *http.Request) (*url.URL, error) {
+	if s != nil {
+		if u := s.ProxyFor(req.URL.Hostname()); u != nil {
+			return u, nil
+		}
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// #endregion
+
+// 发起请求。callback只会被调用一次，调用结束后body会被关闭
+// GET请求在网络错误、429、5xx时按配置重试；其他方法不重试，避免重复下单等副作用
+func (c *HttpClient) Do(ctx context.Context, rawUrl, method, postData string, headers map[string]string, callback func(*http.Response, error)) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	maxRetry := 0
+	if method == "GET" {
+		maxRetry = c.cfg.MaxRetry
+	}
+
+	for attempt := 0; ; attempt++ {
+		t0 := time.Now()
+		res, err := c.do(ctx, rawUrl, method, postData, headers)
+		c.record(rawUrl, res, err, time.Since(t0))
+
+		if attempt < maxRetry && retryable(res, err) && ctx.Err() == nil {
+			if res != nil {
+				res.Body.Close()
+			}
+
+			select {
+			case <-time.After(c.retryDelay(attempt)):
+				continue
+			case <-ctx.Done():
+				callback(nil, ctx.Err())
+				return
+			}
+		}
+
+		if err != nil {
+			callback(nil, err)
+		} else {
+			defer res.Body.Close()
+			callback(res, nil)
+		}
+		return
+	}
+}
+
+// 请求的context同时受调用方、客户端根context和读取超时约束
+func (c *HttpClient) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(c.ctx, cancel)
+	if c.cfg.ReadTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, c.cfg.ReadTimeout)
+		return ctx, func() {
+			cancelTimeout()
+			stop()
+			cancel()
+		}
+	}
+
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+func (c *HttpClient) do(ctx context.Context, rawUrl, method, postData string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawUrl, strings.NewReader(postData))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(postData) > 0 && (postData[0] != '{' || postData[len(postData)-1] != '}') {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	c.muCookies.RLock()
+	for i := range c.cookies {
+		req.AddCookie(&c.cookies[i])
+	}
+	c.muCookies.RUnlock()
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	return c.client.Do(req)
+}
+
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
+
+// 指数退避加随机抖动，避免多个请求同时重试
+func (c *HttpClient) retryDelay(attempt int) time.Duration {
+	d := c.cfg.RetryBaseDelay << attempt
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+// #region 统计
+type HttpStat struct {
+	Count       int64         // 请求次数（含重试）
+	Errors      int64         // 网络错误次数
+	StatusCodes map[int]int64 // 状态码->次数
+	Last        time.Duration // 最近一次的延迟
+	Avg         time.Duration
+	Max         time.Duration
+	total       time.Duration
+	LastTime    time.Time
+}
+
+func (s HttpStat) String() string {
+	return fmt.Sprintf("count=%d errors=%d status=%v last=%v avg=%v max=%v", s.Count, s.Errors, s.StatusCodes, s.Last, s.Avg, s.Max)
+}
+
+func (c *HttpClient) record(rawUrl string, res *http.Response, err error, d time.Duration) {
+	host := rawUrl
+	if u, e := url.Parse(rawUrl); e == nil {
+		host = u.Host
+	}
+
+	c.muStats.Lock()
+	defer c.muStats.Unlock()
+
+	s, ok := c.stats[host]
+	if !ok {
+		s = &HttpStat{StatusCodes: make(map[int]int64)}
+		c.stats[host] = s
+	}
+
+	s.Count++
+	s.LastTime = time.Now()
+	if err != nil {
+		s.Errors++
+		return
+	}
+
+	s.StatusCodes[res.StatusCode]++
+	s.Last = d
+	s.total += d
+	s.Avg = s.total / time.Duration(s.Count-s.Errors)
+	if d > s.Max {
+		s.Max = d
+	}
+}
+
+// 按host统计的延迟和状态码（拷贝）
+func (c *HttpClient) Stats() map[string]HttpStat {
+	c.muStats.Lock()
+	defer c.muStats.Unlock()
+
+	m := make(map[string]HttpStat)
+	for k, v := range c.stats {
+		s := *v
+		s.StatusCodes = make(map[int]int64)
+		for code, n := range v.StatusCodes {
+			s.StatusCodes[code] = n
+		}
+		m[k] = s
+	}
+	return m
+}
+
+// #endregion
+
+// #region 按host登记客户端
+var clients = make(map[string]*HttpClient)
+var defaultClient *HttpClient
+var muClients sync.Mutex
+
+// 为一组host指定客户端
+func SetHttpClient(c *HttpClient, hosts ...string) {
+	muClients.Lock()
+	defer muClients.Unlock()
+	for _, h := range hosts {
+		clients[h] = c
+	}
+}
+
+// 取url对应的客户端。没有登记的host共用一个默认配置的客户端
+func HttpClientOf(rawUrl string) *HttpClient {
+	host := ""
+	if u, err := url.Parse(rawUrl); err == nil {
+		host = u.Host
+	}
+
+	muClients.Lock()
+	defer muClients.Unlock()
+	if c, ok := clients[host]; ok {
+		return c
+	}
+
+	if defaultClient == nil {
+		defaultClient = NewHttpClient("default", DefaultHttpClientConfig())
+	}
+	return defaultClient
+}
+
+// 当前所有客户端（含默认客户端）
+func allHttpClients() map[*HttpClient]bool {
+	muClients.Lock()
+	defer muClients.Unlock()
+
+	if defaultClient == nil {
+		defaultClient = NewHttpClient("default", DefaultHttpClientConfig())
+	}
+
+	all := make(map[*HttpClient]bool)
+	for _, c := range clients {
+		all[c] = true
+	}
+	all[defaultClient] = true
+	return all
+}
+
+// 所有客户端的统计，key为客户端名称.host
+func AllHttpStats() map[string]HttpStat {
+	all := allHttpClients()
+	m := make(map[string]HttpStat)
+	for c := range all {
+		for host, s := range c.Stats() {
+			m[c.name+"."+host] = s
+		}
+	}
+	return m
+}
+
+// #endregion
+
+// #region 全局cookie（兼容旧接口）
+// 作用于当前所有客户端。之后再登记的客户端不受影响，按host区分cookie请使用HttpClientOf(url).SetCookies
+func AddCookie(cookie http.Cookie) {
+	for c := range allHttpClients() {
+		c.AddCookie(cookie)
+	}
+}
+
+func SetCookies(v []http.Cookie) {
+	for c := range allHttpClients() {
+		c.SetCookies(v)
+	}
+}
+
+func ClearCoockies() {
+	for c := range allHttpClients() {
+		c.ClearCookies()
+	}
+}
+
+// #endregion
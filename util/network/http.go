@@ -10,70 +10,38 @@
 package network
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/aztecqt/dagger/util"
 	"github.com/aztecqt/dagger/util/logger"
 )
 
-var cookies []http.Cookie
-
-func AddCookie(cookie http.Cookie) {
-	cookies = append(cookies, cookie)
-}
-
-func SetCookies(v []http.Cookie) {
-	cookies = v
-}
-
-func ClearCoockies() {
-	cookies = make([]http.Cookie, 0)
+// 使用url的host对应的客户端发起请求
+func HttpCall(url string, method string, postData string, headers map[string]string, callback func(*http.Response, error)) {
+	HttpCallCtx(context.Background(), url, method, postData, headers, callback)
 }
 
-func HttpCall(url string, method string, postData string, headers map[string]string, callback func(*http.Response, error)) {
+func HttpCallCtx(ctx context.Context, url string, method string, postData string, headers map[string]string, callback func(*http.Response, error)) {
 	logPrefix := "http"
 	if callback == nil {
 		logger.LogPanic(logPrefix, "no callback, url=%s", url)
 	}
 
-	req, err := http.NewRequest(method, url, strings.NewReader(postData))
-	if err != nil {
-		callback(nil, err)
-		return
-	}
-
-	if len(postData) > 0 && (postData[0] != '{' || postData[len(postData)-1] != '}') {
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	}
-
-	for _, c := range cookies {
-		req.AddCookie(&c)
-	}
-
-	if headers != nil {
-		for k, v := range headers {
-			req.Header.Set(k, v)
-		}
-	}
-
-	client := &http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		callback(nil, err)
-		return
-	} else {
-		defer res.Body.Close()
-		callback(res, err)
-	}
+	HttpClientOf(url).Do(ctx, url, method, postData, headers, callback)
 }
 
 var EnableHttpLog = false
 var HttpLogMaxLen = 256
 
 func ParseHttpResult[T any](logPref, funcName, url, method, postData string, headers map[string]string, cbRaw func(resp *http.Response, body []byte), cbErr func(e error)) (t *T, e error) {
+	return ParseHttpResultCtx[T](context.Background(), logPref, funcName, url, method, postData, headers, cbRaw, cbErr)
+}
+
+// 同ParseHttpResult，ctx取消或超时时请求立即返回
+func ParseHttpResultCtx[T any](ctx context.Context, logPref, funcName, url, method, postData string, headers map[string]string, cbRaw func(resp *http.Response, body []byte), cbErr func(e error)) (t *T, e error) {
 	defer util.DefaultRecover()
 
 	if method == "GET" {
@@ -90,7 +58,7 @@ func ParseHttpResult[T any](logPref, funcName, url, method, postData string, hea
 		}
 	}
 
-	HttpCall(url, method, postData, headers, func(resp *http.Response, err error) {
+	HttpCallCtx(ctx, url, method, postData, headers, func(resp *http.Response, err error) {
 		t = new(T)
 		var body []byte
 		if err != nil {
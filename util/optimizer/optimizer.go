@@ -0,0 +1,136 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-18 10:21:47
+- @Description: 参数优化器。从搜索器获取参数，在克隆出来的行情驱动器上并行评估
+- @
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package optimizer
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/marketdata"
+)
+
+var logPrefix = "optimizer"
+
+// 评估函数。在driver上以ps为参数跑一遍回测，只应使用[t0, t1]范围内的行情（可借助RunWindow）
+// 返回分数（越大越好）以及其他需要记录的指标
+type Evaluator func(ps ParamSet, d marketdata.Driver, t0, t1 time.Time) (score float64, metrics map[string]float64)
+
+// 一次评估的结果
+type Result struct {
+	Params  ParamSet           `json:"params"`
+	Score   float64            `json:"score"`
+	Metrics map[string]float64 `json:"metrics"`
+	T0      time.Time          `json:"t0"`
+	T1      time.Time          `json:"t1"`
+	Err     string             `json:"err"` // 评估过程panic时记录错误信息
+}
+
+type Optimizer struct {
+	space    *Space
+	driver   marketdata.Driver
+	eval     Evaluator
+	parallel int
+	progress bool
+}
+
+func NewOptimizer(space *Space, driver marketdata.Driver, eval Evaluator) *Optimizer {
+	return &Optimizer{space: space, driver: driver, eval: eval, parallel: runtime.NumCPU()}
+}
+
+// 设置并行数量，默认为cpu核数
+func (o *Optimizer) SetParallel(n int) *Optimizer {
+	if n > 0 {
+		o.parallel = n
+	}
+	return o
+}
+
+// 是否在控制台显示进度
+func (o *Optimizer) SetShowProgress(show bool) *Optimizer {
+	o.progress = show
+	return o
+}
+
+func (o *Optimizer) Space() *Space {
+	return o.space
+}
+
+// 在驱动器的全部时间范围上执行搜索
+func (o *Optimizer) Run(s Searcher) Results {
+	return o.RunRange(s, o.driver.StartTime(), o.driver.EndTime())
+}
+
+// 在[t0, t1]范围上执行搜索。每个评估任务使用独立克隆的驱动器
+func (o *Optimizer) RunRange(s Searcher, t0, t1 time.Time) Results {
+	var mu sync.Mutex
+	results := Results{}
+	done := 0
+	total := s.Total()
+
+	next := func() (ParamSet, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		return s.Next()
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < o.parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				ps, ok := next()
+				if !ok {
+					return
+				}
+
+				r := o.evaluate(ps, t0, t1)
+
+				mu.Lock()
+				results = append(results, r)
+				s.Tell(r)
+				done++
+				if o.progress {
+					fmt.Printf("[%d/%d] score=%.4f, %s\n", done, total, r.Score, r.Params.String())
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	results.Sort()
+	logger.LogInfo(logPrefix, "%d configurations evaluated in range [%s, %s]", len(results), t0.Format(time.DateTime), t1.Format(time.DateTime))
+	return results
+}
+
+// 评估单组参数。评估函数panic时不影响其他任务
+func (o *Optimizer) evaluate(ps ParamSet, t0, t1 time.Time) (r Result) {
+	r = Result{Params: ps, T0: t0, T1: t1}
+	defer util.DefaultRecoverWithCallback(func(err string) {
+		r.Err = err
+		r.Score = minScore
+	})
+
+	r.Score, r.Metrics = o.eval(ps, o.driver.Clone(), t0, t1)
+	return
+}
+
+// 驱动行情，但只把[t0, t1]范围内的行情交给fnUpdate
+func RunWindow(d marketdata.Driver, t0, t1 time.Time, fnUpdate func(now time.Time, tickers []marketdata.Ticker)) {
+	d.Run(func(now time.Time, tickers []marketdata.Ticker) {
+		if now.Before(t0) || now.After(t1) {
+			return
+		}
+		fnUpdate(now, tickers)
+	})
+}
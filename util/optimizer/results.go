@@ -0,0 +1,231 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-18 11:02:13
+- @Description: 优化结果的排序、表格输出，以及参数热力图
+- @ 热力图通过datavisual.DataGroup保存：x参数的取值序号作为横轴，y参数的每个取值一条线
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aztecqt/dagger/util"
+	"github.com/aztecqt/dagger/util/datavisual"
+	"github.com/aztecqt/dagger/util/terminal"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+var minScore = math.Inf(-1)
+
+type Results []Result
+
+// 按分数从高到低排序
+func (rs Results) Sort() {
+	sort.SliceStable(rs, func(i, j int) bool { return rs[i].Score > rs[j].Score })
+}
+
+func (rs Results) Best() (Result, bool) {
+	if len(rs) == 0 {
+		return Result{}, false
+	}
+
+	best := rs[0]
+	for _, r := range rs[1:] {
+		if r.Score > best.Score {
+			best = r
+		}
+	}
+	return best, true
+}
+
+func (rs Results) Top(n int) Results {
+	sorted := make(Results, len(rs))
+	copy(sorted, rs)
+	sorted.Sort()
+	if n < len(sorted) {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// 生成排名表格。n<=0表示全部输出
+func (rs Results) Table(space *Space, n int) table.Writer {
+	top := rs.Top(util.ValueIf(n <= 0, len(rs), n))
+
+	// 列：排名、各参数、分数、各指标
+	metricNames := rs.metricNames()
+	t := terminal.GenTableWriter(false)
+	header := table.Row{"rank"}
+	for _, p := range space.Params {
+		header = append(header, p.Name)
+	}
+	header = append(header, "score")
+	for _, m := range metricNames {
+		header = append(header, m)
+	}
+	t.AppendHeader(header)
+
+	for i, r := range top {
+		row := table.Row{i + 1}
+		for _, p := range space.Params {
+			row = append(row, r.Params[p.Name])
+		}
+		row = append(row, fmt.Sprintf("%.4f", r.Score))
+		for _, m := range metricNames {
+			row = append(row, fmt.Sprintf("%.4f", r.Metrics[m]))
+		}
+		t.AppendRow(row)
+	}
+
+	return t
+}
+
+// 保存为csv
+func (rs Results) SaveCsv(space *Space, path string) bool {
+	metricNames := rs.metricNames()
+	sb := strings.Builder{}
+	for _, p := range space.Params {
+		sb.WriteString(p.Name)
+		sb.WriteString(",")
+	}
+	sb.WriteString("score")
+	for _, m := range metricNames {
+		sb.WriteString(",")
+		sb.WriteString(m)
+	}
+	sb.WriteString("\n")
+
+	for _, r := range rs.Top(len(rs)) {
+		for _, p := range space.Params {
+			sb.WriteString(fmt.Sprintf("%v,", r.Params[p.Name]))
+		}
+		sb.WriteString(fmt.Sprintf("%v", r.Score))
+		for _, m := range metricNames {
+			sb.WriteString(fmt.Sprintf(",%v", r.Metrics[m]))
+		}
+		sb.WriteString("\n")
+	}
+
+	util.MakeSureDirForFile(path)
+	return util.StringToFile(path, sb.String())
+}
+
+func (rs Results) metricNames() []string {
+	names := make(map[string]bool)
+	for _, r := range rs {
+		for k := range r.Metrics {
+			names[k] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for k := range names {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// 以两个离散参数为坐标轴生成热力图数据
+// 其他参数取同坐标下的最佳分数。某个格子没有结果则为NaN
+// 返回值：x取值、y取值、分数矩阵[y][x]
+func (rs Results) Heatmap(space *Space, xName, yName string) ([]interface{}, []interface{}, [][]float64) {
+	px, okx := space.Find(xName)
+	py, oky := space.Find(yName)
+	if !okx || !oky || px.Count() == 0 || py.Count() == 0 {
+		return nil, nil, nil
+	}
+
+	xs := make([]interface{}, px.Count())
+	for i := range xs {
+		xs[i] = px.ValueAt(i)
+	}
+
+	ys := make([]interface{}, py.Count())
+	for i := range ys {
+		ys[i] = py.ValueAt(i)
+	}
+
+	matrix := make([][]float64, len(ys))
+	for i := range matrix {
+		matrix[i] = make([]float64, len(xs))
+		for j := range matrix[i] {
+			matrix[i][j] = math.NaN()
+		}
+	}
+
+	for _, r := range rs {
+		ix := indexOfValue(xs, r.Params[xName])
+		iy := indexOfValue(ys, r.Params[yName])
+		if ix < 0 || iy < 0 {
+			continue
+		}
+
+		if math.IsNaN(matrix[iy][ix]) || r.Score > matrix[iy][ix] {
+			matrix[iy][ix] = r.Score
+		}
+	}
+
+	return xs, ys, matrix
+}
+
+// 把热力图保存到目录中，可用数据查看器打开
+// 每个y取值一条线，横轴时间戳为x参数的取值序号（毫秒），矩阵本身写入info.txt
+func (rs Results) SaveHeatmap(space *Space, xName, yName, dir string) bool {
+	xs, ys, matrix := rs.Heatmap(space, xName, yName)
+	if matrix == nil {
+		return false
+	}
+
+	dg := datavisual.NewDataGroup(1)
+	layout := datavisual.NewLayoutConfig(datavisual.Layout_Single)
+	pane := datavisual.NewPaneConfig(fmt.Sprintf("score(%s, %s)", xName, yName))
+	colors := datavisual.NewColorGroup(len(ys), 0.8, 0.5)
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s\\%s", yName, xName))
+	for _, x := range xs {
+		sb.WriteString(fmt.Sprintf(",%v", x))
+	}
+	sb.WriteString("\n")
+
+	for iy, y := range ys {
+		lineName := fmt.Sprintf("%s=%v", yName, y)
+		sb.WriteString(fmt.Sprintf("%v", y))
+		for ix := range xs {
+			v := matrix[iy][ix]
+			sb.WriteString(fmt.Sprintf(",%v", v))
+			if !math.IsNaN(v) {
+				dg.RecordLine(lineName, v, time.UnixMilli(int64(ix)))
+			}
+		}
+		sb.WriteString("\n")
+		pane.AddLineConfig(lineName, colors.Colors[iy], iy == 0, "")
+	}
+
+	dg.SaveExtraInfo(sb.String())
+	dg.SaveToDir(dir)
+	layout.AddPane(pane)
+	layout.SaveToDir(dir)
+	return true
+}
+
+func indexOfValue(vals []interface{}, v interface{}) int {
+	for i, x := range vals {
+		if fmt.Sprint(x) == fmt.Sprint(v) {
+			return i
+		}
+	}
+
+	// 数值型参数允许浮点误差
+	for i, x := range vals {
+		if fx, ok := x.(float64); ok && math.Abs(fx-toFloat(v)) < 1e-9 {
+			return i
+		}
+	}
+	return -1
+}
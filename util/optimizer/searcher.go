@@ -0,0 +1,255 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-18 09:40:31
+- @Description: 参数搜索器。网格搜索、随机搜索、TPE（Tree-structured Parzen Estimator）搜索
+- @ 搜索器只负责给出下一组参数，评估由Optimizer完成，评估结果通过Tell反馈给搜索器
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package optimizer
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Searcher interface {
+	Next() (ParamSet, bool) // 下一组待评估的参数。false表示搜索结束
+	Tell(r Result)          // 反馈评估结果
+	Total() int             // 预计评估总数，用于显示进度
+}
+
+// #region 网格搜索
+type GridSearcher struct {
+	space *Space
+	index int
+	total int
+}
+
+// 参数空间含连续参数时返回错误
+func NewGridSearcher(space *Space) (*GridSearcher, error) {
+	if err := space.CheckGrid(); err != nil {
+		return nil, err
+	}
+	return &GridSearcher{space: space, total: space.GridSize()}, nil
+}
+
+func (g *GridSearcher) Next() (ParamSet, bool) {
+	if g.index >= g.total {
+		return nil, false
+	}
+
+	// 把index按各参数的取值个数展开（最后一个参数变化最快）
+	ps := ParamSet{}
+	n := g.index
+	for i := len(g.space.Params) - 1; i >= 0; i-- {
+		p := g.space.Params[i]
+		c := p.Count()
+		ps[p.Name] = p.ValueAt(n % c)
+		n /= c
+	}
+
+	g.index++
+	return ps, true
+}
+
+func (g *GridSearcher) Tell(r Result) {}
+
+func (g *GridSearcher) Total() int {
+	return g.total
+}
+
+// #endregion
+
+// #region 随机搜索
+type RandomSearcher struct {
+	space   *Space
+	r       *rand.Rand
+	count   int
+	total   int
+	visited map[string]bool
+}
+
+func NewRandomSearcher(space *Space, total int, seed int64) *RandomSearcher {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	// 离散空间比较小的时候，不可能采样出更多不重复的组合
+	if gs := space.GridSize(); gs > 0 && total > gs {
+		total = gs
+	}
+
+	return &RandomSearcher{space: space, r: rand.New(rand.NewSource(seed)), total: total, visited: make(map[string]bool)}
+}
+
+func (s *RandomSearcher) Next() (ParamSet, bool) {
+	if s.count >= s.total {
+		return nil, false
+	}
+
+	var ps ParamSet
+	for retry := 0; retry < 100; retry++ {
+		ps = ParamSet{}
+		for _, p := range s.space.Params {
+			ps[p.Name] = p.Sample(s.r)
+		}
+
+		if !s.visited[ps.String()] {
+			break
+		}
+	}
+
+	s.visited[ps.String()] = true
+	s.count++
+	return ps, true
+}
+
+func (s *RandomSearcher) Tell(r Result) {}
+
+func (s *RandomSearcher) Total() int {
+	return s.total
+}
+
+// #endregion
+
+// #region TPE搜索
+// 先随机采样nStartup组，之后按分数把历史结果分为好/坏两组（好的占比gamma）
+// 对每个参数分别用高斯核估计好组密度l(x)和坏组密度g(x)
+// 从l(x)中采样nCandidates个候选值，选择l(x)/g(x)最大的那个
+type TPESearcher struct {
+	mu          sync.Mutex
+	space       *Space
+	r           *rand.Rand
+	count       int
+	total       int
+	nStartup    int
+	nCandidates int
+	gamma       float64
+	history     []Result
+}
+
+func NewTPESearcher(space *Space, total int, seed int64) *TPESearcher {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	t := &TPESearcher{
+		space:       space,
+		r:           rand.New(rand.NewSource(seed)),
+		total:       total,
+		nStartup:    int(math.Max(10, float64(total)/5)),
+		nCandidates: 24,
+		gamma:       0.25,
+	}
+
+	if t.nStartup > total {
+		t.nStartup = total
+	}
+
+	return t
+}
+
+func (t *TPESearcher) SetGamma(gamma float64) {
+	t.gamma = gamma
+}
+
+func (t *TPESearcher) SetStartup(n int) {
+	t.nStartup = n
+}
+
+func (t *TPESearcher) Next() (ParamSet, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.count >= t.total {
+		return nil, false
+	}
+	t.count++
+
+	ps := ParamSet{}
+	if len(t.history) < t.nStartup {
+		for _, p := range t.space.Params {
+			ps[p.Name] = p.Sample(t.r)
+		}
+		return ps, true
+	}
+
+	// 按分数从高到低划分好坏两组
+	sorted := make([]Result, len(t.history))
+	copy(sorted, t.history)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	nGood := int(math.Max(1, math.Ceil(t.gamma*float64(len(sorted)))))
+	good := sorted[:nGood]
+	bad := sorted[nGood:]
+
+	for _, p := range t.space.Params {
+		ps[p.Name] = t.suggest(p, good, bad)
+	}
+
+	return ps, true
+}
+
+func (t *TPESearcher) suggest(p Param, good, bad []Result) interface{} {
+	goodX := make([]float64, 0, len(good))
+	for _, r := range good {
+		goodX = append(goodX, p.toUnit(r.Params[p.Name]))
+	}
+
+	badX := make([]float64, 0, len(bad))
+	for _, r := range bad {
+		badX = append(badX, p.toUnit(r.Params[p.Name]))
+	}
+
+	bwGood := parzenBandwidth(len(goodX))
+	bwBad := parzenBandwidth(len(badX))
+
+	bestX := 0.0
+	bestRatio := math.Inf(-1)
+	for i := 0; i < t.nCandidates; i++ {
+		// 从好组密度中采样：随机选一个核，再加上高斯扰动
+		center := goodX[t.r.Intn(len(goodX))]
+		x := math.Max(0, math.Min(1, center+t.r.NormFloat64()*bwGood))
+		l := parzenDensity(x, goodX, bwGood)
+		g := parzenDensity(x, badX, bwBad)
+		ratio := math.Log(l+1e-12) - math.Log(g+1e-12)
+		if ratio > bestRatio {
+			bestRatio = ratio
+			bestX = x
+		}
+	}
+
+	return p.fromUnit(bestX)
+}
+
+func (t *TPESearcher) Tell(r Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.history = append(t.history, r)
+}
+
+func (t *TPESearcher) Total() int {
+	return t.total
+}
+
+// 带先验的Parzen窗口密度。先验为[0,1]上的均匀分布，保证没有样本的区域也有非零密度
+func parzenDensity(x float64, samples []float64, bw float64) float64 {
+	sum := 1.0 // 均匀先验
+	for _, s := range samples {
+		d := (x - s) / bw
+		sum += math.Exp(-0.5*d*d) / (bw * math.Sqrt(2*math.Pi))
+	}
+	return sum / float64(len(samples)+1)
+}
+
+// Scott法则的简化版，样本越多带宽越窄
+func parzenBandwidth(n int) float64 {
+	if n <= 1 {
+		return 0.25
+	}
+	return math.Max(0.02, 1.06*0.3*math.Pow(float64(n), -0.2))
+}
+
+// #endregion
@@ -0,0 +1,124 @@
+package optimizer
+
+import (
+	"math"
+	"testing"
+)
+
+// 在已知最优点的目标函数上检验三种搜索器。直接调用Next/Tell，不经过Optimizer
+
+// 最优点(x=0.3, y=0.7)，最大值0
+func quadratic(ps ParamSet) float64 {
+	dx := ps.Float("x") - 0.3
+	dy := ps.Float("y") - 0.7
+	return -(dx*dx + dy*dy)
+}
+
+func runSearcher(s Searcher, fn func(ParamSet) float64) Results {
+	rs := Results{}
+	for {
+		ps, ok := s.Next()
+		if !ok {
+			return rs
+		}
+		r := Result{Params: ps, Score: fn(ps)}
+		s.Tell(r)
+		rs = append(rs, r)
+	}
+}
+
+func TestGridSearcher(t *testing.T) {
+	space := NewSpace(FloatParam("x", 0, 1, 0.1), FloatParam("y", 0, 1, 0.1), ChoiceParam("mode", "a", "b"))
+	s, err := NewGridSearcher(space)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Total() != 11*11*2 {
+		t.Fatalf("grid size %d", s.Total())
+	}
+
+	rs := runSearcher(s, quadratic)
+	if len(rs) != s.Total() {
+		t.Fatalf("evaluated %d of %d", len(rs), s.Total())
+	}
+
+	seen := map[string]bool{}
+	for _, r := range rs {
+		if seen[r.Params.String()] {
+			t.Fatalf("duplicated %s", r.Params.String())
+		}
+		seen[r.Params.String()] = true
+	}
+
+	best, _ := rs.Best()
+	if math.Abs(best.Params.Float("x")-0.3) > 1e-9 || math.Abs(best.Params.Float("y")-0.7) > 1e-9 {
+		t.Fatalf("grid best %s", best.Params.String())
+	}
+
+	if _, err := NewGridSearcher(NewSpace(FloatParam("x", 0, 1, 0))); err == nil {
+		t.Fatal("continuous param should not be grid searchable")
+	}
+}
+
+func TestRandomSearcher(t *testing.T) {
+	cases := []struct {
+		name  string
+		space *Space
+		total int
+		want  int
+	}{
+		{"continuous", NewSpace(FloatParam("x", 0, 1, 0), FloatParam("y", 0, 1, 0)), 50, 50},
+		{"discrete capped by grid size", NewSpace(IntParam("x", 1, 3, 1), ChoiceParam("y", 0.5, 0.7)), 50, 6},
+	}
+
+	for _, c := range cases {
+		rs := runSearcher(NewRandomSearcher(c.space, c.total, 1), quadratic)
+		if len(rs) != c.want {
+			t.Fatalf("%s: evaluated %d, want %d", c.name, len(rs), c.want)
+		}
+
+		seen := map[string]bool{}
+		for _, r := range rs {
+			for _, p := range c.space.Params {
+				if p.Type != ParamType_Choice {
+					if v := r.Params.Float(p.Name); v < p.Min || v > p.Max {
+						t.Fatalf("%s: %s=%v out of range", c.name, p.Name, v)
+					}
+				}
+			}
+			if p := c.space.Params[0]; p.Count() > 0 && seen[r.Params.String()] {
+				t.Fatalf("%s: duplicated %s", c.name, r.Params.String())
+			}
+			seen[r.Params.String()] = true
+		}
+	}
+}
+
+// TPE在启动阶段之后应集中到最优点附近：最好结果接近最优，且后段的平均分明显好于同样数量的随机采样
+func TestTPESearcher(t *testing.T) {
+	space := NewSpace(FloatParam("x", 0, 1, 0), FloatParam("y", 0, 1, 0))
+	const total = 120
+
+	tpe := runSearcher(NewTPESearcher(space, total, 7), quadratic)
+	random := runSearcher(NewRandomSearcher(space, total, 7), quadratic)
+	if len(tpe) != total {
+		t.Fatalf("tpe evaluated %d", len(tpe))
+	}
+
+	best, _ := tpe.Best()
+	if best.Score < -0.005 {
+		t.Fatalf("tpe best %s score %.5f too far from optimum", best.Params.String(), best.Score)
+	}
+
+	mean := func(rs Results) float64 {
+		sum := 0.0
+		for _, r := range rs {
+			sum += r.Score
+		}
+		return sum / float64(len(rs))
+	}
+	tail := total / 3
+	if mt, mr := mean(tpe[total-tail:]), mean(random[total-tail:]); mt <= mr*0.5 {
+		t.Fatalf("tpe tail mean %.5f not better than random %.5f", mt, mr)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-18 09:12:05
+- @Description: 参数空间描述。支持数值范围（带步长）和枚举两种参数
+- @ 参数名与配置结构体的json tag一致，这样可以直接把一组参数应用到GridConfig等配置上
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package optimizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+type ParamType int
+
+const (
+	ParamType_Float ParamType = iota
+	ParamType_Int
+	ParamType_Choice
+)
+
+// 一个参数的描述
+type Param struct {
+	Name    string        `json:"name"`    // 参数名，对应配置结构体的json tag
+	Type    ParamType     `json:"type"`    // 参数类型
+	Min     float64       `json:"min"`     // 最小值（数值型）
+	Max     float64       `json:"max"`     // 最大值（数值型）
+	Step    float64       `json:"step"`    // 步长（数值型）。0表示连续取值，此时不能用于网格搜索
+	Choices []interface{} `json:"choices"` // 可选值（枚举型）
+}
+
+func FloatParam(name string, min, max, step float64) Param {
+	return Param{Name: name, Type: ParamType_Float, Min: min, Max: max, Step: step}
+}
+
+func IntParam(name string, min, max, step int) Param {
+	return Param{Name: name, Type: ParamType_Int, Min: float64(min), Max: float64(max), Step: float64(step)}
+}
+
+func ChoiceParam(name string, choices ...interface{}) Param {
+	return Param{Name: name, Type: ParamType_Choice, Choices: choices}
+}
+
+// 离散后的取值个数。连续参数返回0
+func (p *Param) Count() int {
+	switch p.Type {
+	case ParamType_Choice:
+		return len(p.Choices)
+	default:
+		if p.Step <= 0 {
+			return 0
+		}
+		return int(math.Floor((p.Max-p.Min)/p.Step+1e-9)) + 1
+	}
+}
+
+// 第i个离散取值
+func (p *Param) ValueAt(i int) interface{} {
+	switch p.Type {
+	case ParamType_Choice:
+		return p.Choices[i]
+	case ParamType_Int:
+		return int64(math.Round(p.Min + p.Step*float64(i)))
+	default:
+		return p.Min + p.Step*float64(i)
+	}
+}
+
+// 随机取一个值
+func (p *Param) Sample(r *rand.Rand) interface{} {
+	if n := p.Count(); n > 0 {
+		return p.ValueAt(r.Intn(n))
+	}
+
+	return p.fromUnit(r.Float64())
+}
+
+// 把[0,1]区间的值映射到参数取值上（枚举型按下标映射）
+func (p *Param) fromUnit(u float64) interface{} {
+	u = math.Max(0, math.Min(1, u))
+	if n := p.Count(); n > 0 {
+		i := int(u * float64(n))
+		if i >= n {
+			i = n - 1
+		}
+		return p.ValueAt(i)
+	}
+
+	v := p.Min + (p.Max-p.Min)*u
+	if p.Type == ParamType_Int {
+		return int64(math.Round(v))
+	}
+	return v
+}
+
+// 把参数值映射到[0,1]区间，fromUnit的逆操作
+func (p *Param) toUnit(v interface{}) float64 {
+	if p.Type == ParamType_Choice {
+		for i, c := range p.Choices {
+			if fmt.Sprint(c) == fmt.Sprint(v) {
+				return (float64(i) + 0.5) / float64(len(p.Choices))
+			}
+		}
+		return 0
+	}
+
+	if p.Max <= p.Min {
+		return 0
+	}
+
+	return (toFloat(v) - p.Min) / (p.Max - p.Min)
+}
+
+// 参数空间
+type Space struct {
+	Params []Param `json:"params"`
+}
+
+func NewSpace(params ...Param) *Space {
+	return &Space{Params: params}
+}
+
+func (s *Space) Add(p Param) *Space {
+	s.Params = append(s.Params, p)
+	return s
+}
+
+func (s *Space) Find(name string) (Param, bool) {
+	for _, p := range s.Params {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// 检查能否网格搜索。连续参数（数值型且未设置步长）无法枚举
+func (s *Space) CheckGrid() error {
+	continuous := make([]string, 0)
+	for _, p := range s.Params {
+		if p.Count() == 0 {
+			continuous = append(continuous, p.Name)
+		}
+	}
+
+	if len(continuous) > 0 {
+		return fmt.Errorf("grid search needs step for continuous params: %s", strings.Join(continuous, ","))
+	}
+	return nil
+}
+
+// 网格总数。含连续参数时返回0，见CheckGrid
+func (s *Space) GridSize() int {
+	n := 1
+	for _, p := range s.Params {
+		c := p.Count()
+		if c == 0 {
+			return 0
+		}
+		n *= c
+	}
+	return n
+}
+
+// 一组具体的参数取值
+type ParamSet map[string]interface{}
+
+func (ps ParamSet) Float(name string) float64 {
+	return toFloat(ps[name])
+}
+
+func (ps ParamSet) Int(name string) int64 {
+	return int64(math.Round(toFloat(ps[name])))
+}
+
+func (ps ParamSet) Clone() ParamSet {
+	c := ParamSet{}
+	for k, v := range ps {
+		c[k] = v
+	}
+	return c
+}
+
+// 稳定的字符串表示，可用作去重的key
+func (ps ParamSet) String() string {
+	keys := make([]string, 0, len(ps))
+	for k := range ps {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sb := strings.Builder{}
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(fmt.Sprintf("%s=%v", k, ps[k]))
+	}
+	return sb.String()
+}
+
+// 把参数应用到一个配置对象上（通过json tag匹配字段）
+// cfg必须是指针，如*adv.GridConfig。未出现在ParamSet中的字段保持原值
+func (ps ParamSet) ApplyTo(cfg interface{}) error {
+	b, err := json.Marshal(ps)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, cfg)
+}
+
+func toFloat(v interface{}) float64 {
+	switch x := v.(type) {
+	case float64:
+		return x
+	case float32:
+		return float64(x)
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case bool:
+		if x {
+			return 1
+		}
+		return 0
+	default:
+		return 0
+	}
+}
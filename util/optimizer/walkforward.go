@@ -0,0 +1,168 @@
+/*
+- @Author: aztec
+- @Date: 2026-10-18 11:35:52
+- @Description: 滚动前进（walk-forward）优化
+- @ 把时间范围切成若干段，每段先在样本内（IS）搜索最优参数，再用样本外（OOS）数据检验
+- @Copyright (c) 2026 by aztec, All Rights Reserved.
+*/
+package optimizer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/terminal"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+type WalkForwardConfig struct {
+	InSample    time.Duration // 样本内长度
+	OutOfSample time.Duration // 样本外长度
+	Step        time.Duration // 每段前进的长度，0表示等于OutOfSample
+	Anchored    bool          // true时样本内起点固定为总起点（扩张窗口）
+}
+
+// 一段walk-forward的结果
+type WalkForwardFold struct {
+	IST0, IST1   time.Time
+	OOST0, OOST1 time.Time
+	InSample     Results // 样本内全部结果
+	Best         Result  // 样本内最优
+	OutOfSample  Result  // 最优参数在样本外的表现
+}
+
+// 样本内没有可用的结果，或样本外评估panic。失败的段不计入汇总
+func (f *WalkForwardFold) Failed() bool {
+	return len(f.InSample) == 0 || len(f.Best.Err) > 0 || len(f.OutOfSample.Err) > 0
+}
+
+type WalkForwardReport struct {
+	Folds []WalkForwardFold
+}
+
+// 切分时间段
+func (c WalkForwardConfig) Split(t0, t1 time.Time) [][4]time.Time {
+	step := c.Step
+	if step <= 0 {
+		step = c.OutOfSample
+	}
+
+	splits := [][4]time.Time{}
+	if c.InSample <= 0 || c.OutOfSample <= 0 {
+		return splits
+	}
+
+	for start := t0; ; start = start.Add(step) {
+		isT0 := start
+		if c.Anchored {
+			isT0 = t0
+		}
+		isT1 := start.Add(c.InSample)
+		oosT0 := isT1
+		oosT1 := oosT0.Add(c.OutOfSample)
+		if oosT1.After(t1) {
+			break
+		}
+		splits = append(splits, [4]time.Time{isT0, isT1, oosT0, oosT1})
+	}
+	return splits
+}
+
+// 执行walk-forward优化。newSearcher为每段创建一个新的搜索器
+func (o *Optimizer) WalkForward(cfg WalkForwardConfig, newSearcher func() Searcher) *WalkForwardReport {
+	report := &WalkForwardReport{}
+	splits := cfg.Split(o.driver.StartTime(), o.driver.EndTime())
+	for i, s := range splits {
+		logger.LogInfo(logPrefix, "walk-forward fold %d/%d: IS [%s, %s], OOS [%s, %s]",
+			i+1, len(splits),
+			s[0].Format(time.DateTime), s[1].Format(time.DateTime),
+			s[2].Format(time.DateTime), s[3].Format(time.DateTime))
+
+		fold := WalkForwardFold{IST0: s[0], IST1: s[1], OOST0: s[2], OOST1: s[3]}
+		fold.InSample = o.RunRange(newSearcher(), s[0], s[1])
+		if best, ok := fold.InSample.Best(); ok {
+			fold.Best = best
+			if len(best.Err) == 0 {
+				fold.OutOfSample = o.evaluate(best.Params, s[2], s[3])
+			}
+		}
+		if fold.Failed() {
+			logger.LogImportant(logPrefix, "walk-forward fold %d failed: %s", i+1, fold.err())
+		}
+		report.Folds = append(report.Folds, fold)
+	}
+
+	return report
+}
+
+// 样本外与样本内分数之比的平均值（walk-forward efficiency）
+// 接近1说明参数在样本外基本保持了样本内的表现
+func (r *WalkForwardReport) Efficiency() float64 {
+	sum := 0.0
+	n := 0
+	for _, f := range r.Folds {
+		if !f.Failed() && f.Best.Score != 0 {
+			sum += f.OutOfSample.Score / f.Best.Score
+			n++
+		}
+	}
+
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// 样本外分数的总和
+func (r *WalkForwardReport) OutOfSampleScore() float64 {
+	sum := 0.0
+	for _, f := range r.Folds {
+		if !f.Failed() {
+			sum += f.OutOfSample.Score
+		}
+	}
+	return sum
+}
+
+// 失败的段数
+func (r *WalkForwardReport) FailedFolds() int {
+	n := 0
+	for _, f := range r.Folds {
+		if f.Failed() {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *WalkForwardFold) err() string {
+	switch {
+	case len(f.InSample) == 0:
+		return "no in-sample result"
+	case len(f.Best.Err) > 0:
+		return "in-sample: " + f.Best.Err
+	default:
+		return "out-of-sample: " + f.OutOfSample.Err
+	}
+}
+
+func (r *WalkForwardReport) Table() table.Writer {
+	t := terminal.GenTableWriter(true)
+	t.AppendHeader(table.Row{"in-sample", "out-of-sample", "params", "is_score", "oos_score"})
+	for _, f := range r.Folds {
+		oos := fmt.Sprintf("%.4f", f.OutOfSample.Score)
+		if f.Failed() {
+			oos = "failed"
+		}
+		t.AppendRow(table.Row{
+			fmt.Sprintf("%s~%s", f.IST0.Format(time.DateOnly), f.IST1.Format(time.DateOnly)),
+			fmt.Sprintf("%s~%s", f.OOST0.Format(time.DateOnly), f.OOST1.Format(time.DateOnly)),
+			f.Best.Params.String(),
+			fmt.Sprintf("%.4f", f.Best.Score),
+			oos,
+		})
+	}
+	t.AppendFooter(table.Row{"", "", "efficiency", fmt.Sprintf("%.4f", r.Efficiency()), fmt.Sprintf("%.4f", r.OutOfSampleScore())})
+	return t
+}
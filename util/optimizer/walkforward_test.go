@@ -0,0 +1,137 @@
+package optimizer
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aztecqt/dagger/util/logger"
+	"github.com/aztecqt/dagger/util/marketdata"
+)
+
+// walk-forward的时间切分，以及样本外评估失败时的汇总
+
+func TestMain(m *testing.M) {
+	logger.Setup(logger.NewStdoutSink(logger.ConsoleEncoder{}, logger.LogLevel_Important))
+	os.Exit(m.Run())
+}
+
+func day(d int) time.Time {
+	return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, d)
+}
+
+func TestWalkForwardSplit(t *testing.T) {
+	const d = time.Hour * 24
+	cases := []struct {
+		name string
+		cfg  WalkForwardConfig
+		t1   time.Time
+		want [][4]int // 以天数表示的IS起止、OOS起止
+	}{
+		{
+			name: "rolling, step defaults to oos",
+			cfg:  WalkForwardConfig{InSample: 10 * d, OutOfSample: 5 * d},
+			t1:   day(25),
+			want: [][4]int{{0, 10, 10, 15}, {5, 15, 15, 20}, {10, 20, 20, 25}},
+		},
+		{
+			name: "anchored",
+			cfg:  WalkForwardConfig{InSample: 10 * d, OutOfSample: 5 * d, Anchored: true},
+			t1:   day(24),
+			want: [][4]int{{0, 10, 10, 15}, {0, 15, 15, 20}},
+		},
+		{
+			name: "custom step overlaps oos",
+			cfg:  WalkForwardConfig{InSample: 10 * d, OutOfSample: 5 * d, Step: 2 * d},
+			t1:   day(19),
+			want: [][4]int{{0, 10, 10, 15}, {2, 12, 12, 17}, {4, 14, 14, 19}},
+		},
+		{
+			name: "range shorter than one fold",
+			cfg:  WalkForwardConfig{InSample: 10 * d, OutOfSample: 5 * d},
+			t1:   day(14),
+			want: [][4]int{},
+		},
+		{
+			name: "invalid config",
+			cfg:  WalkForwardConfig{InSample: 0, OutOfSample: 5 * d},
+			t1:   day(100),
+			want: [][4]int{},
+		},
+	}
+
+	for _, c := range cases {
+		got := c.cfg.Split(day(0), c.t1)
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: %d folds, want %d", c.name, len(got), len(c.want))
+		}
+		for i, w := range c.want {
+			for k := 0; k < 4; k++ {
+				if !got[i][k].Equal(day(w[k])) {
+					t.Fatalf("%s: fold %d point %d is %s, want day %d", c.name, i, k, got[i][k].Format(time.DateOnly), w[k])
+				}
+			}
+		}
+	}
+}
+
+// 只提供时间范围的行情驱动器，每天推送一次
+type fakeDriver struct {
+	t0, t1 time.Time
+}
+
+func (d *fakeDriver) Run(fnUpdate func(now time.Time, tickers []marketdata.Ticker)) {
+	for now := d.t0; !now.After(d.t1); now = now.AddDate(0, 0, 1) {
+		fnUpdate(now, nil)
+	}
+}
+func (d *fakeDriver) ShowProgress()            {}
+func (d *fakeDriver) Clone() marketdata.Driver { return &fakeDriver{t0: d.t0, t1: d.t1} }
+func (d *fakeDriver) StartTime() time.Time     { return d.t0 }
+func (d *fakeDriver) EndTime() time.Time       { return d.t1 }
+
+// 第二段的样本外评估panic：该段标记为失败，不计入效率和样本外总分
+func TestWalkForwardFailedFold(t *testing.T) {
+	const d = time.Hour * 24
+	space := NewSpace(IntParam("x", 1, 3, 1))
+	badOOS := day(15)
+	eval := func(ps ParamSet, drv marketdata.Driver, t0, t1 time.Time) (float64, map[string]float64) {
+		if t0.Equal(badOOS) {
+			panic("boom")
+		}
+		days := 0
+		RunWindow(drv, t0, t1, func(now time.Time, tickers []marketdata.Ticker) { days++ })
+		return ps.Float("x") * float64(days), nil
+	}
+
+	o := NewOptimizer(space, &fakeDriver{t0: day(0), t1: day(25)}, eval).SetParallel(2)
+	report := o.WalkForward(WalkForwardConfig{InSample: 10 * d, OutOfSample: 5 * d}, func() Searcher {
+		s, _ := NewGridSearcher(space)
+		return s
+	})
+
+	if len(report.Folds) != 3 {
+		t.Fatalf("%d folds", len(report.Folds))
+	}
+	for i, f := range report.Folds {
+		if f.Best.Params.Int("x") != 3 {
+			t.Fatalf("fold %d best %s", i, f.Best.Params.String())
+		}
+		if f.Failed() != (i == 1) {
+			t.Fatalf("fold %d failed=%v", i, f.Failed())
+		}
+	}
+	if report.FailedFolds() != 1 {
+		t.Fatalf("failed folds %d", report.FailedFolds())
+	}
+
+	// 窗口两端都包含：IS 11天，OOS 6天
+	if got, want := report.OutOfSampleScore(), 2*3*6.0; got != want {
+		t.Fatalf("oos score %v, want %v", got, want)
+	}
+	if eff := report.Efficiency(); math.IsNaN(eff) || math.IsInf(eff, 0) || math.Abs(eff-6.0/11) > 1e-9 {
+		t.Fatalf("efficiency %v", eff)
+	}
+	report.Table().Render()
+}
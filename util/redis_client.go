@@ -319,3 +319,17 @@ func (r *RedisClient) HDel(key string, fields ...string) bool {
 		return false
 	}
 }
+
+// 执行lua脚本。脚本返回nil时结果为nil、第二个返回值为true
+func (r *RedisClient) Eval(script string, keys []string, args ...interface{}) (interface{}, bool) {
+	cmd := r.c.Eval(script, keys, args...)
+	rst, err := cmd.Result()
+	if err == nil {
+		return rst, true
+	} else if err == redis.Nil {
+		return nil, true
+	} else {
+		r.LogCmdError(cmd, err)
+		return nil, false
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 14:05:37
+ * @Description: 基于redis的租约（分布式锁），带fencing token
+ * 租约的值为"持有者|token"，每次新获得租约时token加一，续期时不变。持有者可以用token判断自己是否还处在同一任期
+ * 获取、续期、释放、校验都用lua脚本完成，保证原子性
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// KEYS[1]=租约, KEYS[2]=token计数器; ARGV[1]=持有者, ARGV[2]=有效期(毫秒)
+// 返回token，租约被其他人持有时返回0
+const leaseAcquireScript = `
+local cur = redis.call('GET', KEYS[1])
+if cur then
+	local sep = string.find(cur, '|', 1, true)
+	if string.sub(cur, 1, sep - 1) ~= ARGV[1] then
+		return 0
+	end
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return tonumber(string.sub(cur, sep + 1))
+end
+local token = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], ARGV[1] .. '|' .. token, 'PX', ARGV[2])
+return token
+`
+
+// KEYS[1]=租约; ARGV[1]=持有者|token
+const leaseReleaseScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+// KEYS[1]=租约; ARGV[1]=持有者|token
+const leaseCheckScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return 1
+end
+return 0
+`
+
+type RedisLease struct {
+	rc    *RedisClient
+	key   string
+	owner string // 不能包含'|'
+	ttl   time.Duration
+}
+
+func NewRedisLease(rc *RedisClient, key, owner string, ttl time.Duration) *RedisLease {
+	return &RedisLease{
+		rc:    rc,
+		key:   key,
+		owner: strings.ReplaceAll(owner, "|", "_"),
+		ttl:   ttl,
+	}
+}
+
+func (l *RedisLease) Owner() string {
+	return l.owner
+}
+
+func (l *RedisLease) TTL() time.Duration {
+	return l.ttl
+}
+
+// 获取或续期租约。ok为false表示redis访问失败；token为0表示租约被其他人持有
+func (l *RedisLease) TryAcquire() (token int64, ok bool) {
+	rst, ok := l.rc.Eval(leaseAcquireScript, []string{l.key, l.key + ":token"}, l.owner, l.ttl.Milliseconds())
+	if !ok {
+		return 0, false
+	}
+	token, _ = rst.(int64)
+	return token, true
+}
+
+// 释放租约，只有持有者和token都匹配时才会删除
+func (l *RedisLease) Release(token int64) bool {
+	rst, ok := l.rc.Eval(leaseReleaseScript, []string{l.key}, l.value(token))
+	n, _ := rst.(int64)
+	return ok && n > 0
+}
+
+// 校验租约仍由自己以该token持有
+func (l *RedisLease) Check(token int64) bool {
+	rst, ok := l.rc.Eval(leaseCheckScript, []string{l.key}, l.value(token))
+	n, _ := rst.(int64)
+	return ok && n > 0
+}
+
+// 当前持有者和token。无人持有时ok为false
+func (l *RedisLease) Holder() (owner string, token int64, ok bool) {
+	v, ok := l.rc.Get(l.key)
+	if !ok || len(v) == 0 {
+		return "", 0, false
+	}
+
+	ss := strings.SplitN(v, "|", 2)
+	if len(ss) != 2 {
+		return "", 0, false
+	}
+	t, _ := String2Int64(ss[1])
+	return ss[0], t, true
+}
+
+func (l *RedisLease) value(token int64) string {
+	return fmt.Sprintf("%s|%d", l.owner, token)
+}
@@ -0,0 +1,329 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 10:05:12
+ * @Description: 命令定义。命令有参数（按位置）、选项（-x/--xx，可以出现在任意位置）、子命令和权限等级
+ * 参数和选项按类型校验，执行时通过CmdCall按名称取值
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package terminal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// 权限等级。本地命令行为Perm_Admin，远程调用的等级由配置决定
+type PermLevel int
+
+const (
+	Perm_Read    PermLevel = iota // 只读查询
+	Perm_Operate                  // 不影响交易的运维操作，如修改日志等级
+	Perm_Trade                    // 影响交易的操作，如撤单、平仓
+	Perm_Admin                    // 退出等管理操作
+)
+
+func PermLevel2String(p PermLevel) string {
+	switch p {
+	case Perm_Read:
+		return "read"
+	case Perm_Operate:
+		return "operate"
+	case Perm_Trade:
+		return "trade"
+	case Perm_Admin:
+		return "admin"
+	default:
+		return "unknown"
+	}
+}
+
+// 无法识别时返回Perm_Read
+func String2PermLevel(s string) PermLevel {
+	switch strings.ToLower(s) {
+	case "operate":
+		return Perm_Operate
+	case "trade":
+		return Perm_Trade
+	case "admin":
+		return Perm_Admin
+	default:
+		return Perm_Read
+	}
+}
+
+type ArgType int
+
+const (
+	ArgType_String ArgType = iota
+	ArgType_Int
+	ArgType_Float
+	ArgType_Decimal
+	ArgType_Bool
+)
+
+func ArgType2String(t ArgType) string {
+	switch t {
+	case ArgType_Int:
+		return "int"
+	case ArgType_Float:
+		return "float"
+	case ArgType_Decimal:
+		return "decimal"
+	case ArgType_Bool:
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+// 位置参数
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Help     string
+	Optional bool     // 可选参数只能出现在必选参数之后
+	Default  string   // 可选参数未提供时的值
+	Choices  []string // 不为空时只能取其中的值，同时用于自动补全
+
+	// 动态补全，如交易对、实例名。不为nil时优先于Choices用于补全，但不做校验
+	Complete func() []string
+}
+
+// 选项。命令行中写作-name或--name，bool类型不需要值，其他类型写作-name value或-name=value
+type Flag struct {
+	Name     string
+	Short    string // 单字母缩写，可以为空
+	Type     ArgType
+	Help     string
+	Default  string
+	Choices  []string
+	Complete func() []string
+}
+
+type CmdFunc func(c *CmdCall)
+
+type Command struct {
+	Name    string
+	Aliases []string
+	Help    string
+	Perm    PermLevel
+	Args    []Arg
+	Flags   []Flag
+	Run     CmdFunc // 只有子命令的命令可以为nil，此时打印帮助
+
+	subs   []*Command
+	parent *Command
+}
+
+// 添加子命令，返回自身以便连续添加
+func (c *Command) Add(subs ...*Command) *Command {
+	for _, sub := range subs {
+		sub.parent = c
+		c.subs = append(c.subs, sub)
+	}
+	return c
+}
+
+func (c *Command) Subs() []*Command {
+	return c.subs
+}
+
+func (c *Command) findSub(name string) *Command {
+	for _, sub := range c.subs {
+		if sub.Name == name {
+			return sub
+		}
+		for _, a := range sub.Aliases {
+			if a == name {
+				return sub
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Command) findFlag(name string) *Flag {
+	for i, f := range c.Flags {
+		if f.Name == name || (len(f.Short) > 0 && f.Short == name) {
+			return &c.Flags[i]
+		}
+	}
+	return nil
+}
+
+// 完整路径，如"alert ack"
+func (c *Command) FullName() string {
+	if c.parent == nil || len(c.parent.Name) == 0 {
+		return c.Name
+	}
+	return c.parent.FullName() + " " + c.Name
+}
+
+// 用法，如"alert ack <id> [-by string]"
+func (c *Command) Usage() string {
+	sb := strings.Builder{}
+	sb.WriteString(c.FullName())
+	if len(c.subs) > 0 {
+		sb.WriteString(" <")
+		names := make([]string, 0, len(c.subs))
+		for _, sub := range c.subs {
+			names = append(names, sub.Name)
+		}
+		sb.WriteString(strings.Join(names, "|"))
+		sb.WriteString(">")
+	}
+	for _, a := range c.Args {
+		if a.Optional {
+			sb.WriteString(fmt.Sprintf(" [%s]", a.Name))
+		} else {
+			sb.WriteString(fmt.Sprintf(" <%s>", a.Name))
+		}
+	}
+	for _, f := range c.Flags {
+		if f.Type == ArgType_Bool {
+			sb.WriteString(fmt.Sprintf(" [-%s]", f.Name))
+		} else {
+			sb.WriteString(fmt.Sprintf(" [-%s %s]", f.Name, ArgType2String(f.Type)))
+		}
+	}
+	return sb.String()
+}
+
+// 详细帮助
+func (c *Command) Detail() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("usage: %s\n", c.Usage()))
+	if len(c.Help) > 0 {
+		sb.WriteString(fmt.Sprintf("  %s\n", c.Help))
+	}
+	if len(c.Aliases) > 0 {
+		sb.WriteString(fmt.Sprintf("  aliases: %s\n", strings.Join(c.Aliases, ", ")))
+	}
+	sb.WriteString(fmt.Sprintf("  permission: %s\n", PermLevel2String(c.Perm)))
+
+	if len(c.subs) > 0 {
+		sb.WriteString("subcommands:\n")
+		for _, sub := range c.subs {
+			sb.WriteString(fmt.Sprintf("  %-16s%s\n", sub.Name, sub.Help))
+		}
+	}
+	if len(c.Args) > 0 {
+		sb.WriteString("arguments:\n")
+		for _, a := range c.Args {
+			sb.WriteString(fmt.Sprintf("  %-16s%s%s\n", a.Name, a.Help, describeValue(a.Type, a.Default, a.Choices)))
+		}
+	}
+	if len(c.Flags) > 0 {
+		sb.WriteString("flags:\n")
+		for _, f := range c.Flags {
+			name := "-" + f.Name
+			if len(f.Short) > 0 {
+				name = fmt.Sprintf("-%s, -%s", f.Short, f.Name)
+			}
+			sb.WriteString(fmt.Sprintf("  %-16s%s%s\n", name, f.Help, describeValue(f.Type, f.Default, f.Choices)))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func describeValue(t ArgType, def string, choices []string) string {
+	ss := []string{ArgType2String(t)}
+	if len(choices) > 0 {
+		ss = append(ss, "one of "+strings.Join(choices, "/"))
+	}
+	if len(def) > 0 {
+		ss = append(ss, "default "+def)
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(ss, ", "))
+}
+
+// 校验值的类型和取值范围
+func checkValue(name string, t ArgType, v string, choices []string) error {
+	if len(choices) > 0 {
+		found := false
+		for _, c := range choices {
+			if c == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s must be one of %s", name, strings.Join(choices, "/"))
+		}
+	}
+
+	var err error
+	switch t {
+	case ArgType_Int:
+		_, err = strconv.ParseInt(v, 10, 64)
+	case ArgType_Float:
+		_, err = strconv.ParseFloat(v, 64)
+	case ArgType_Decimal:
+		_, err = decimal.NewFromString(v)
+	case ArgType_Bool:
+		_, err = strconv.ParseBool(v)
+	}
+	if err != nil {
+		return fmt.Errorf("%s should be %s, got '%s'", name, ArgType2String(t), v)
+	}
+	return nil
+}
+
+// #region 调用
+type CmdCall struct {
+	Cmd    *Command
+	Level  PermLevel // 调用方的权限等级
+	Remote bool      // 是否远程调用
+
+	values map[string]string // 参数和选项的值，已校验
+	given  map[string]bool   // 实际提供的参数和选项
+	onResp func(string)
+}
+
+// 输出结果
+func (c *CmdCall) Resp(format string, params ...interface{}) {
+	if len(params) == 0 {
+		c.onResp(format)
+	} else {
+		c.onResp(fmt.Sprintf(format, params...))
+	}
+}
+
+// 原始回调，用于把调用转发给旧的命令处理函数
+func (c *CmdCall) RespFn() func(string) {
+	return c.onResp
+}
+
+// 参数或选项是否在命令行中提供（而不是默认值）
+func (c *CmdCall) Has(name string) bool {
+	return c.given[name]
+}
+
+func (c *CmdCall) String(name string) string {
+	return c.values[name]
+}
+
+func (c *CmdCall) Int(name string) int64 {
+	v, _ := strconv.ParseInt(c.values[name], 10, 64)
+	return v
+}
+
+func (c *CmdCall) Float(name string) float64 {
+	v, _ := strconv.ParseFloat(c.values[name], 64)
+	return v
+}
+
+func (c *CmdCall) Decimal(name string) decimal.Decimal {
+	v, _ := decimal.NewFromString(c.values[name])
+	return v
+}
+
+func (c *CmdCall) Bool(name string) bool {
+	v, _ := strconv.ParseBool(c.values[name])
+	return v
+}
+
+// #endregion
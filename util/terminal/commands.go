@@ -0,0 +1,385 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 10:31:47
+ * @Description: 命令注册表。负责命令行的解析、校验、权限检查、帮助生成和自动补全
+ * 命令行按空白切分，支持用引号包含空格。"help [命令]"和"[命令] -h"显示帮助
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package terminal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aztecqt/dagger/util"
+)
+
+type Commands struct {
+	root Command
+	mu   sync.RWMutex
+}
+
+func NewCommands() *Commands {
+	return &Commands{}
+}
+
+// 注册顶层命令，同名命令会被替换
+func (cs *Commands) Register(cmds ...*Command) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, cmd := range cmds {
+		cs.removeNoLock(cmd.Name)
+		cs.root.Add(cmd)
+	}
+}
+
+func (cs *Commands) Unregister(name string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.removeNoLock(name)
+}
+
+func (cs *Commands) removeNoLock(name string) {
+	for i, c := range cs.root.subs {
+		if c.Name == name {
+			cs.root.subs = append(cs.root.subs[:i], cs.root.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// 顶层命令是否存在（含help）
+func (cs *Commands) Has(name string) bool {
+	if name == "help" {
+		return true
+	}
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.root.findSub(name) != nil
+}
+
+// 执行命令。命令未注册时返回false，调用方可以交给其他处理函数
+func (cs *Commands) Execute(cmdLine string, level PermLevel, remote bool, onResp func(string)) bool {
+	tokens := SplitCmdLine(cmdLine)
+	if len(tokens) == 0 {
+		return false
+	}
+
+	if tokens[0] == "help" {
+		onResp(cs.help(tokens[1:], level))
+		return true
+	}
+
+	cs.mu.RLock()
+	cmd, rest := cs.find(tokens)
+	cs.mu.RUnlock()
+	if cmd == nil {
+		return false
+	}
+
+	if cmd.Perm > level {
+		onResp(fmt.Sprintf("permission denied: %s requires %s", cmd.FullName(), PermLevel2String(cmd.Perm)))
+		return true
+	}
+
+	for _, t := range rest {
+		if t == "-h" || t == "--help" {
+			onResp(cmd.Detail())
+			return true
+		}
+	}
+
+	if cmd.Run == nil {
+		if len(rest) > 0 {
+			onResp(fmt.Sprintf("unknown subcommand: %s\n%s", rest[0], cmd.Detail()))
+		} else {
+			onResp(cmd.Detail())
+		}
+		return true
+	}
+
+	call, err := parseCall(cmd, rest)
+	if err != nil {
+		onResp(fmt.Sprintf("%s\nusage: %s", err.Error(), cmd.Usage()))
+		return true
+	}
+
+	call.Level = level
+	call.Remote = remote
+	call.onResp = onResp
+	func() {
+		defer util.DefaultRecoverWithCallback(func(e string) {
+			onResp(fmt.Sprintf("%s failed: %s", cmd.FullName(), e))
+		})
+		cmd.Run(call)
+	}()
+	return true
+}
+
+// 沿子命令向下查找，返回最深的命令和剩余的参数
+func (cs *Commands) find(tokens []string) (*Command, []string) {
+	cmd := cs.root.findSub(tokens[0])
+	if cmd == nil {
+		return nil, nil
+	}
+
+	i := 1
+	for ; i < len(tokens); i++ {
+		sub := cmd.findSub(tokens[i])
+		if sub == nil {
+			break
+		}
+		cmd = sub
+	}
+	return cmd, tokens[i:]
+}
+
+// 解析参数和选项
+func parseCall(cmd *Command, tokens []string) (*CmdCall, error) {
+	call := &CmdCall{
+		Cmd:    cmd,
+		values: make(map[string]string),
+		given:  make(map[string]bool),
+	}
+
+	positionals := make([]string, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		name, value, hasValue, isFlag := splitFlag(cmd, t)
+		if !isFlag {
+			positionals = append(positionals, t)
+			continue
+		}
+
+		f := cmd.findFlag(name)
+		if f == nil {
+			return nil, fmt.Errorf("unknown flag: %s", t)
+		}
+
+		if !hasValue {
+			if f.Type == ArgType_Bool {
+				value = "true"
+			} else if i+1 < len(tokens) {
+				i++
+				value = tokens[i]
+			} else {
+				return nil, fmt.Errorf("flag -%s needs a value", f.Name)
+			}
+		}
+
+		if err := checkValue("-"+f.Name, f.Type, value, f.Choices); err != nil {
+			return nil, err
+		}
+		call.values[f.Name] = value
+		call.given[f.Name] = true
+	}
+
+	if len(positionals) > len(cmd.Args) {
+		return nil, fmt.Errorf("too many arguments")
+	}
+
+	for i, a := range cmd.Args {
+		if i < len(positionals) {
+			if err := checkValue(a.Name, a.Type, positionals[i], a.Choices); err != nil {
+				return nil, err
+			}
+			call.values[a.Name] = positionals[i]
+			call.given[a.Name] = true
+		} else if !a.Optional {
+			return nil, fmt.Errorf("missing argument: %s", a.Name)
+		} else {
+			call.values[a.Name] = a.Default
+		}
+	}
+
+	for _, f := range cmd.Flags {
+		if !call.given[f.Name] {
+			call.values[f.Name] = f.Default
+		}
+	}
+
+	return call, nil
+}
+
+// -x、--xx、-x=v。负数在没有同名选项时作为参数
+func splitFlag(cmd *Command, t string) (name, value string, hasValue, isFlag bool) {
+	if len(t) < 2 || t[0] != '-' {
+		return "", "", false, false
+	}
+
+	name = strings.TrimLeft(t, "-")
+	if idx := strings.Index(name, "="); idx >= 0 {
+		name, value, hasValue = name[:idx], name[idx+1:], true
+	}
+
+	if _, ok := util.String2Float64(name); ok && cmd.findFlag(name) == nil {
+		return "", "", false, false
+	}
+	return name, value, hasValue, true
+}
+
+// #region 帮助
+func (cs *Commands) help(tokens []string, level PermLevel) string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	if len(tokens) > 0 {
+		cmd, rest := cs.find(tokens)
+		if cmd == nil || len(rest) > 0 {
+			return fmt.Sprintf("unknown command: %s", strings.Join(tokens, " "))
+		}
+		return cmd.Detail()
+	}
+
+	sb := strings.Builder{}
+	for _, c := range cs.root.subs {
+		if c.Perm <= level {
+			sb.WriteString(fmt.Sprintf("%-16s%s\n", c.Name+":", c.Help))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("%-16s%s", "help:", "show this list, help [command] for details"))
+	return sb.String()
+}
+
+// 命令列表，权限不足的不显示
+func (cs *Commands) Help(level PermLevel) string {
+	return cs.help(nil, level)
+}
+
+// #endregion
+
+// #region 自动补全
+
+// 返回最后一个词的候选项，已排序
+func (cs *Commands) Complete(line string) []string {
+	tokens := SplitCmdLine(line)
+	prefix := ""
+	if len(tokens) > 0 && !strings.HasSuffix(line, " ") {
+		prefix = tokens[len(tokens)-1]
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	candidates := make([]string, 0)
+	if len(tokens) == 0 {
+		candidates = append(candidates, "help")
+		for _, c := range cs.root.subs {
+			candidates = append(candidates, c.Name)
+		}
+		return filterPrefix(candidates, prefix)
+	}
+
+	if tokens[0] == "help" {
+		tokens = tokens[1:]
+		if len(tokens) == 0 {
+			for _, c := range cs.root.subs {
+				candidates = append(candidates, c.Name)
+			}
+			return filterPrefix(candidates, prefix)
+		}
+	}
+
+	cmd, rest := cs.find(tokens)
+	if cmd == nil {
+		return nil
+	}
+
+	// 还没有参数时，可以是子命令
+	if len(rest) == 0 {
+		for _, sub := range cmd.subs {
+			candidates = append(candidates, sub.Name)
+		}
+	}
+
+	if strings.HasPrefix(prefix, "-") {
+		for _, f := range cmd.Flags {
+			candidates = append(candidates, "-"+f.Name)
+		}
+		return filterPrefix(candidates, prefix)
+	}
+
+	// 前一个词是需要值的选项时，补全选项的值
+	if len(rest) > 0 {
+		if name, _, hasValue, isFlag := splitFlag(cmd, rest[len(rest)-1]); isFlag && !hasValue {
+			if f := cmd.findFlag(name); f != nil && f.Type != ArgType_Bool {
+				return filterPrefix(valueCandidates(f.Choices, f.Complete), prefix)
+			}
+		}
+	}
+
+	// 第几个位置参数
+	n := 0
+	for i := 0; i < len(rest); i++ {
+		if name, _, hasValue, isFlag := splitFlag(cmd, rest[i]); isFlag {
+			if f := cmd.findFlag(name); f != nil && f.Type != ArgType_Bool && !hasValue {
+				i++
+			}
+		} else {
+			n++
+		}
+	}
+	if n < len(cmd.Args) {
+		candidates = append(candidates, valueCandidates(cmd.Args[n].Choices, cmd.Args[n].Complete)...)
+	}
+	return filterPrefix(candidates, prefix)
+}
+
+func valueCandidates(choices []string, complete func() []string) []string {
+	if complete != nil {
+		return complete()
+	}
+	return choices
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	result := make([]string, 0, len(candidates))
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) && !seen[c] {
+			result = append(result, c)
+			seen[c] = true
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// #endregion
+
+// 按空白切分命令行，引号中的空白不切分
+func SplitCmdLine(line string) []string {
+	tokens := make([]string, 0)
+	sb := strings.Builder{}
+	var quote rune
+	inToken := false
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				sb.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inToken = true
+		case r == ' ' || r == '\t':
+			if inToken {
+				tokens = append(tokens, sb.String())
+				sb.Reset()
+				inToken = false
+			}
+		default:
+			sb.WriteRune(r)
+			inToken = true
+		}
+	}
+	if inToken {
+		tokens = append(tokens, sb.String())
+	}
+	return tokens
+}
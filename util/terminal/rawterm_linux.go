@@ -0,0 +1,36 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 10:52:30
+ * @Description: 命令行的非规范模式（逐字符读取、不回显），用于Repl的行编辑
+ * 保留OPOST和ISIG，其他协程的输出和ctrl+c不受影响
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package terminal
+
+import "golang.org/x/sys/unix"
+
+const ioctlGetTermios = unix.TCGETS
+const ioctlSetTermios = unix.TCSETS
+
+func isTerminal(fd int) bool {
+	_, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	return err == nil
+}
+
+func makeRaw(fd int) (restore func(), err error) {
+	old, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *old
+	raw.Lflag &^= unix.ECHO | unix.ICANON
+	raw.Iflag &^= unix.ICRNL
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+
+	return func() { unix.IoctlSetTermios(fd, ioctlSetTermios, old) }, nil
+}
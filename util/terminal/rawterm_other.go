@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd
+
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 10:55:16
+ * @Description: 不支持非规范模式的平台，Repl退化为逐行读取
+ *
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package terminal
+
+import "errors"
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw mode not supported")
+}
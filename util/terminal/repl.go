@@ -0,0 +1,306 @@
+/*
+ * @Author: aztec
+ * @Date: 2026-10-19 11:02:38
+ * @Description: 带行编辑的本地命令行。支持Tab补全、上下键翻历史、左右键/Home/End移动光标，历史记录保存到文件
+ * 标准输入不是终端，或平台不支持时，退化为逐行读取
+ * Copyright (c) 2026 by aztec, All Rights Reserved.
+ */
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aztecqt/dagger/util"
+)
+
+const maxHistory = 500
+
+type Repl struct {
+	Prompt   string
+	Complete func(line string) []string // 返回最后一个词的候选项
+
+	historyPath string
+	history     []string
+}
+
+// historyPath为空时不保存历史
+func NewRepl(prompt, historyPath string) *Repl {
+	r := &Repl{Prompt: prompt, historyPath: historyPath}
+	r.loadHistory()
+	return r
+}
+
+// 循环读取命令，阻塞直到输入结束
+func (r *Repl) Run(onLine func(line string)) {
+	fd := int(os.Stdin.Fd())
+	if !isTerminal(fd) {
+		r.runSimple(onLine)
+		return
+	}
+
+	for {
+		line, ok := r.readLine(fd)
+		if !ok {
+			return
+		}
+		r.addHistory(line)
+		onLine(line)
+	}
+}
+
+func (r *Repl) runSimple(onLine func(line string)) {
+	input := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print(r.Prompt)
+		if !input.Scan() {
+			return
+		}
+		line := input.Text()
+		r.addHistory(line)
+		onLine(line)
+	}
+}
+
+// #region 行编辑
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+func (ls *lineState) refresh() {
+	// 回到行首，清除整行，重新输出，再把光标移回
+	s := fmt.Sprintf("\r\x1b[K%s%s", ls.prompt, string(ls.buf))
+	if back := len(ls.buf) - ls.pos; back > 0 {
+		s += fmt.Sprintf("\x1b[%dD", back)
+	}
+	os.Stdout.WriteString(s)
+}
+
+func (ls *lineState) set(s string) {
+	ls.buf = []rune(s)
+	ls.pos = len(ls.buf)
+}
+
+func (ls *lineState) insert(rs ...rune) {
+	buf := make([]rune, 0, len(ls.buf)+len(rs))
+	buf = append(buf, ls.buf[:ls.pos]...)
+	buf = append(buf, rs...)
+	buf = append(buf, ls.buf[ls.pos:]...)
+	ls.buf = buf
+	ls.pos += len(rs)
+}
+
+func (r *Repl) readLine(fd int) (string, bool) {
+	restore, err := makeRaw(fd)
+	if err != nil {
+		input := bufio.NewScanner(os.Stdin)
+		fmt.Print(r.Prompt)
+		if !input.Scan() {
+			return "", false
+		}
+		return input.Text(), true
+	}
+	defer restore()
+
+	ls := &lineState{prompt: r.Prompt}
+	ls.refresh()
+	histIndex := len(r.history)
+	pending := ""       // 翻历史前正在编辑的内容
+	utf8Buf := []byte{} // 未完整的utf8字符
+	b := make([]byte, 64)
+
+	for {
+		n, err := os.Stdin.Read(b)
+		if err != nil || n == 0 {
+			return "", false
+		}
+
+		for i := 0; i < n; i++ {
+			c := b[i]
+			switch {
+			case len(utf8Buf) > 0 || c >= 0x80:
+				utf8Buf = append(utf8Buf, c)
+				if utf8.FullRune(utf8Buf) {
+					rn, _ := utf8.DecodeRune(utf8Buf)
+					utf8Buf = utf8Buf[:0]
+					ls.insert(rn)
+					ls.refresh()
+				}
+			case c == '\r' || c == '\n':
+				os.Stdout.WriteString("\r\n")
+				return string(ls.buf), true
+			case c == 127 || c == 8: // backspace
+				if ls.pos > 0 {
+					ls.buf = append(ls.buf[:ls.pos-1], ls.buf[ls.pos:]...)
+					ls.pos--
+					ls.refresh()
+				}
+			case c == '\t':
+				r.complete(ls)
+			case c == 1: // ctrl+a
+				ls.pos = 0
+				ls.refresh()
+			case c == 5: // ctrl+e
+				ls.pos = len(ls.buf)
+				ls.refresh()
+			case c == 21: // ctrl+u
+				ls.set("")
+				ls.refresh()
+			case c == 4: // ctrl+d，空行时结束输入
+				if len(ls.buf) == 0 {
+					os.Stdout.WriteString("\r\n")
+					return "", false
+				}
+			case c == 27: // 转义序列
+				seq := ""
+				for i+1 < n && len(seq) < 4 {
+					i++
+					seq += string(b[i])
+					if b[i] >= 'A' && b[i] <= '~' && b[i] != '[' && b[i] != 'O' {
+						break
+					}
+				}
+				switch seq {
+				case "[A", "OA": // 上
+					if histIndex > 0 {
+						if histIndex == len(r.history) {
+							pending = string(ls.buf)
+						}
+						histIndex--
+						ls.set(r.history[histIndex])
+						ls.refresh()
+					}
+				case "[B", "OB": // 下
+					if histIndex < len(r.history) {
+						histIndex++
+						if histIndex == len(r.history) {
+							ls.set(pending)
+						} else {
+							ls.set(r.history[histIndex])
+						}
+						ls.refresh()
+					}
+				case "[C", "OC": // 右
+					if ls.pos < len(ls.buf) {
+						ls.pos++
+						ls.refresh()
+					}
+				case "[D", "OD": // 左
+					if ls.pos > 0 {
+						ls.pos--
+						ls.refresh()
+					}
+				case "[H", "OH", "[1~":
+					ls.pos = 0
+					ls.refresh()
+				case "[F", "OF", "[4~":
+					ls.pos = len(ls.buf)
+					ls.refresh()
+				case "[3~": // delete
+					if ls.pos < len(ls.buf) {
+						ls.buf = append(ls.buf[:ls.pos], ls.buf[ls.pos+1:]...)
+						ls.refresh()
+					}
+				}
+			case c >= 32:
+				ls.insert(rune(c))
+				ls.refresh()
+			}
+		}
+	}
+}
+
+// 只补全光标前的部分。唯一候选时补全并加空格，多个候选时补全公共前缀，无法再补全时列出所有候选
+func (r *Repl) complete(ls *lineState) {
+	if r.Complete == nil {
+		return
+	}
+
+	before := string(ls.buf[:ls.pos])
+	cands := r.Complete(before)
+	if len(cands) == 0 {
+		return
+	}
+
+	word := ""
+	if !strings.HasSuffix(before, " ") {
+		if idx := strings.LastIndexAny(before, " \t"); idx >= 0 {
+			word = before[idx+1:]
+		} else {
+			word = before
+		}
+	}
+
+	common := cands[0]
+	for _, c := range cands[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	if len(cands) == 1 {
+		ls.insert([]rune(strings.TrimPrefix(cands[0], word) + " ")...)
+	} else if len(common) > len(word) {
+		ls.insert([]rune(strings.TrimPrefix(common, word))...)
+	} else {
+		os.Stdout.WriteString("\r\n" + strings.Join(cands, "  ") + "\r\n")
+	}
+	ls.refresh()
+}
+
+// #endregion
+
+// #region 历史
+func (r *Repl) loadHistory() {
+	if len(r.historyPath) == 0 {
+		return
+	}
+
+	b, err := os.ReadFile(r.historyPath)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		if len(strings.TrimSpace(line)) > 0 {
+			r.history = append(r.history, line)
+		}
+	}
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+}
+
+func (r *Repl) addHistory(line string) {
+	if len(strings.TrimSpace(line)) == 0 {
+		return
+	}
+	if len(r.history) > 0 && r.history[len(r.history)-1] == line {
+		return
+	}
+
+	r.history = append(r.history, line)
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+
+	if len(r.historyPath) > 0 {
+		util.MakeSureDirForFile(r.historyPath)
+		if file, err := os.OpenFile(r.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+			file.WriteString(line + "\n")
+			file.Close()
+		}
+	}
+}
+
+// 所有历史记录，旧的在前
+func (r *Repl) History() []string {
+	return r.history
+}
+
+// #endregion
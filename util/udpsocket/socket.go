@@ -70,6 +70,11 @@ func (s *Socket) Listen(localPort int, onRecv FnOnRecv) bool {
 	return true
 }
 
+// 本地地址。Listen(0)时用于取得实际端口
+func (s *Socket) LocalAddr() net.Addr {
+	return s.socket.LocalAddr()
+}
+
 func (s *Socket) Close() {
 	s.socket.Close()
 }
@@ -1,33 +1,130 @@
 /*
 - @Author: aztec
 - @Date: 2024-06-05 16:47:00
-- @Description:
-- @
+- @Description: web服务的apikey-secretkey，保存在redis中
+- 新格式用AES-256-GCM加密整条记录，主密钥由环境变量KEYMGR_MASTER_KEY中的口令经scrypt派生，盐保存在redis中
+- 旧的DES格式仍可读取，Migrate可将其转换为新格式
 - @Copyright (c) 2024 by aztec, All Rights Reserved.
 */
 package keymgr
 
 import (
 	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/aztecqt/dagger/util"
 	"github.com/aztecqt/dagger/util/crypto"
+	"github.com/aztecqt/dagger/util/logger"
 )
 
+const logPrefix = "keymgr"
 const deskey = "*#1rg4#*"
 const redisKey = "secrets"
+const redisSaltKey = "secrets.salt"
+const masterKeyEnv = "KEYMGR_MASTER_KEY"
+const sealedPrefix = "v2:"
 
 type SecretKey struct {
 	ApiKey    string `json:"api_key"`
 	SecretKey string `json:"secret_key"`
+	Version   int    `json:"version"` // 每次轮换加1。旧格式为0
 }
 
-func (s *SecretKey) Encrypt() {
-	s.ApiKey = hex.EncodeToString(crypto.DesCBCEncrypt([]byte(s.ApiKey), []byte(deskey), []byte(deskey)))
-	s.SecretKey = hex.EncodeToString(crypto.DesCBCEncrypt([]byte(s.SecretKey), []byte(deskey), []byte(deskey)))
+var masterKey []byte
+var muMasterKey sync.Mutex
+
+// 取主密钥，首次调用时从环境变量派生
+func getMasterKey(rc *util.RedisClient) ([]byte, error) {
+	muMasterKey.Lock()
+	defer muMasterKey.Unlock()
+
+	if masterKey != nil {
+		return masterKey, nil
+	}
+
+	pass := []byte(os.Getenv(masterKeyEnv))
+	defer crypto.Zero(pass)
+	if len(pass) == 0 {
+		return nil, errors.New(masterKeyEnv + " not set")
+	}
+
+	salt, ok := rc.HGetBytes(redisSaltKey, "salt")
+	if !ok || len(salt) == 0 {
+		var err error
+		if salt, err = crypto.RandomBytes(16); err != nil {
+			return nil, err
+		}
+		if !rc.HSet(redisSaltKey, "salt", salt) {
+			return nil, errors.New("save salt failed")
+		}
+	}
+
+	key, err := crypto.DeriveKey(pass, salt)
+	if err != nil {
+		return nil, err
+	}
+	masterKey = key
+	return masterKey, nil
+}
+
+// 清除内存中的主密钥
+func ClearMasterKey() {
+	muMasterKey.Lock()
+	defer muMasterKey.Unlock()
+	crypto.Zero(masterKey)
+	masterKey = nil
+}
+
+func (s *SecretKey) seal(rc *util.RedisClient, tag string) (string, error) {
+	key, err := getMasterKey(rc)
+	if err != nil {
+		return "", err
+	}
+
+	plain := []byte(util.Object2String(s))
+	defer crypto.Zero(plain)
+	sealed, err := crypto.AesGcmSeal(key, plain, []byte(tag))
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + hex.EncodeToString(sealed), nil
+}
+
+func open(rc *util.RedisClient, tag, str string) (SecretKey, error) {
+	k := SecretKey{}
+	if !strings.HasPrefix(str, sealedPrefix) {
+		// 旧格式
+		if err := util.ObjectFromString(str, &k); err != nil {
+			return k, err
+		}
+		k.decryptLegacy()
+		return k, nil
+	}
+
+	key, err := getMasterKey(rc)
+	if err != nil {
+		return k, err
+	}
+
+	sealed, err := hex.DecodeString(str[len(sealedPrefix):])
+	if err != nil {
+		return k, err
+	}
+
+	plain, err := crypto.AesGcmOpen(key, sealed, []byte(tag))
+	if err != nil {
+		return k, err
+	}
+	defer crypto.Zero(plain)
+
+	err = util.ObjectFromString(string(plain), &k)
+	return k, err
 }
 
-func (s *SecretKey) Decrypt() {
+func (s *SecretKey) decryptLegacy() {
 	b0, _ := hex.DecodeString(s.ApiKey)
 	b1, _ := hex.DecodeString(s.SecretKey)
 	s.ApiKey = string(crypto.DesCBCDecrypter(b0, []byte(deskey), []byte(deskey)))
@@ -40,22 +137,23 @@ func Exist(rc *util.RedisClient, tag string) bool {
 }
 
 func Save(rc *util.RedisClient, tag string, s SecretKey) bool {
-	s.Encrypt()
-	if rc.HSet(redisKey, tag, util.Object2String(s)) {
-		return true
-	} else {
+	str, err := s.seal(rc, tag)
+	if err != nil {
+		logger.LogImportant(logPrefix, "seal %s failed: %s", tag, err.Error())
 		return false
 	}
+
+	return rc.HSet(redisKey, tag, str)
 }
 
 func GetAll(rc *util.RedisClient) map[string]SecretKey {
 	keys := map[string]SecretKey{}
 	if v, ok := rc.HGetAll(redisKey); ok {
 		for tag, v := range v {
-			key := SecretKey{}
-			if err := util.ObjectFromString(v, &key); err == nil {
-				key.Decrypt()
+			if key, err := open(rc, tag, v); err == nil {
 				keys[tag] = key
+			} else {
+				logger.LogImportant(logPrefix, "open %s failed: %s", tag, err.Error())
 			}
 		}
 	}
@@ -63,14 +161,14 @@ func GetAll(rc *util.RedisClient) map[string]SecretKey {
 }
 
 func GetByTag(rc *util.RedisClient, tag string) (SecretKey, bool) {
-	k := SecretKey{}
 	if str, ok := rc.HGet(redisKey, tag); ok {
-		if err := util.ObjectFromString(str, &k); err == nil {
-			k.Decrypt()
+		if k, err := open(rc, tag, str); err == nil {
 			return k, true
+		} else {
+			logger.LogImportant(logPrefix, "open %s failed: %s", tag, err.Error())
 		}
 	}
-	return k, false
+	return SecretKey{}, false
 }
 
 func GetByApiKey(rc *util.RedisClient, apikey string) (SecretKey, bool, string) {
@@ -84,6 +182,35 @@ func GetByApiKey(rc *util.RedisClient, apikey string) (SecretKey, bool, string)
 	return SecretKey{}, false, ""
 }
 
+// 轮换secret-key，api-key不变，版本加1
+func Rotate(rc *util.RedisClient, tag string, newSecret string) (SecretKey, bool) {
+	k, ok := GetByTag(rc, tag)
+	if !ok {
+		return k, false
+	}
+
+	k.SecretKey = newSecret
+	k.Version++
+	return k, Save(rc, tag, k)
+}
+
+// 将旧格式的记录转换为新格式，返回转换的数量
+func Migrate(rc *util.RedisClient) int {
+	n := 0
+	if v, ok := rc.HGetAll(redisKey); ok {
+		for tag, str := range v {
+			if strings.HasPrefix(str, sealedPrefix) {
+				continue
+			}
+
+			if k, err := open(rc, tag, str); err == nil && Save(rc, tag, k) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
 func DeleteByTag(rc *util.RedisClient, tag string) bool {
 	return rc.HDel(redisKey, tag)
 }
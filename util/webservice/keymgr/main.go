@@ -45,6 +45,10 @@ func main() {
 		return
 	}
 
+	if len(os.Getenv("KEYMGR_MASTER_KEY")) == 0 {
+		fmt.Println("KEYMGR_MASTER_KEY not set, only legacy keys can be read")
+	}
+
 	for {
 		fmt.Print(">")
 		input.Scan()
@@ -56,6 +60,8 @@ func main() {
 			fmt.Println("gen tag")
 			fmt.Println("del tag")
 			fmt.Println("save tag")
+			fmt.Println("rotate tag")
+			fmt.Println("migrate")
 		case "gen":
 			if len(ss) < 2 {
 				fmt.Println("missing tag")
@@ -73,6 +79,7 @@ func main() {
 					s := keymgr.SecretKey{
 						ApiKey:    string(apiKey),
 						SecretKey: string(secretKey),
+						Version:   1,
 					}
 					if keymgr.Save(rc, tag, s) {
 						fmt.Printf("apikey: %s\n", apiKey)
@@ -89,9 +96,9 @@ func main() {
 		case "ls":
 			keys := keymgr.GetAll(rc)
 			t := terminal.GenTableWriter(true)
-			t.AppendHeader(table.Row{"tag", "api-key", "secret-key"})
+			t.AppendHeader(table.Row{"tag", "api-key", "secret-key", "version"})
 			for tag, k := range keys {
-				t.AppendRow(table.Row{tag, k.ApiKey, k.SecretKey})
+				t.AppendRow(table.Row{tag, k.ApiKey, k.SecretKey, k.Version})
 			}
 			fmt.Println(t.Render())
 		case "save":
@@ -109,6 +116,24 @@ func main() {
 			} else {
 				fmt.Println("load secret-key failed")
 			}
+		case "rotate":
+			if len(ss) < 2 {
+				fmt.Println("missing tag")
+				continue
+			}
+
+			tag := ss[1]
+			if secretKey, err := generateRandomBytes(32); err == nil {
+				if k, ok := keymgr.Rotate(rc, tag, secretKey); ok {
+					fmt.Printf("secretKey: %s (version %d)\n", k.SecretKey, k.Version)
+				} else {
+					fmt.Printf("rotate %s failed\n", tag)
+				}
+			} else {
+				fmt.Println("gen secretkey failed")
+			}
+		case "migrate":
+			fmt.Printf("%d keys migrated\n", keymgr.Migrate(rc))
 		case "del":
 			if len(ss) < 2 {
 				fmt.Println("missing tag")
@@ -134,6 +134,18 @@ func (s *Service) Auth(w http.ResponseWriter, r *http.Request) (bool, string) {
 	}
 }
 
+// 包一层鉴权，失败时返回401
+func (s *Service) WithAuth(h HttpHandler) HttpHandler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, msg := s.Auth(w, r); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			WriteError(w, msg)
+			return
+		}
+		h(w, r)
+	}
+}
+
 func HmacSHA256Sign(message string, secretKey string) (string, error) {
 	mac := hmac.New(sha256.New, []byte(secretKey))
 	_, err := mac.Write([]byte(message))